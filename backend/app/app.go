@@ -1,6 +1,7 @@
 package app
 
 import (
+	"crypto/tls"
 	"fmt"
 	"log"
 	"net/http"
@@ -13,24 +14,48 @@ import (
 	"github.com/hazebio/haze.bio_backend/discord"
 	"github.com/hazebio/haze.bio_backend/middlewares"
 	"github.com/hazebio/haze.bio_backend/routes"
+	"github.com/hazebio/haze.bio_backend/services"
 )
 
-func StartServer(redisClient *redis.Client, bot *discord.Bot) error {
+func StartServer(redisClient *redis.Client, bot *discord.Bot, domainService *services.DomainService) error {
 	r := mux.NewRouter()
 
 	r.Use(middlewares.LogMiddleware)
 
-	routes.RegisterRoutes(r, db.DB, redisClient, bot)
+	routes.RegisterRoutes(r, db.DB, redisClient, bot, domainService)
 
 	log.Println("Server started on port:", config.HttpPort)
 	log.Println("Environment:", config.Environment)
 
+	handler := middlewares.CORSMiddleware(r)
+
 	server := &http.Server{
 		Addr:         fmt.Sprintf(":%d", config.HttpPort),
-		Handler:      middlewares.CORSMiddleware(r),
+		Handler:      handler,
 		ReadTimeout:  15 * time.Second,
 		WriteTimeout: 120 * time.Second,
 	}
 
+	// Custom domains are served over TLS with the certificates ordered for them
+	if services.CertificatesEnabled() {
+		tlsServer := &http.Server{
+			Addr:         fmt.Sprintf(":%d", config.HttpsPort),
+			Handler:      handler,
+			ReadTimeout:  15 * time.Second,
+			WriteTimeout: 120 * time.Second,
+			TLSConfig: &tls.Config{
+				GetCertificate: domainService.GetCertificate,
+				MinVersion:     tls.VersionTLS12,
+			},
+		}
+
+		go func() {
+			log.Println("TLS server for custom domains started on port:", config.HttpsPort)
+			if err := tlsServer.ListenAndServeTLS("", ""); err != nil {
+				log.Println("TLS server for custom domains stopped:", err)
+			}
+		}()
+	}
+
 	return server.ListenAndServe()
 }
@@ -2,7 +2,9 @@ package config
 
 import (
 	"fmt"
+	"net"
 	"os"
+	"strconv"
 	"strings"
 
 	"github.com/hazebio/haze.bio_backend/utils"
@@ -28,6 +30,10 @@ var (
 	DiscordClientSecret string
 	DiscordRedirectURI  string
 
+	DiscordModerationChannelID   string
+	DiscordRegistrationChannelID string
+	DiscordNotificationChannelID string
+
 	RedisAddr     string
 	RedisPassword string
 	RedisDB       int
@@ -43,6 +49,43 @@ var (
 	R2APIKey     = os.Getenv("R2_API_KEY")    // File upload service API key
 	R2PublicURL  = os.Getenv("R2_PUBLIC_URL") // Public CDN URL for file access
 
+	// Public URL of this API, external profile media is rewritten to its media proxy
+	APIPublicURL string
+
+	// Public URL of the file-upload service for direct uploads, defaults to R2URL
+	UploadURL string
+	// Shared with the file-upload service to sign direct upload grants
+	UploadGrantSecret string
+	// Shared with the file-upload service to sign expiring download URLs
+	FileSigningSecret string
+
+	// Used to transcode uploads, processing falls back to the original file without it
+	FFmpegPath string
+
+	// clamd socket path or host:port, uploads are not scanned for malware without it
+	ClamAVAddress string
+	// NSFW classification service, a stub that flags nothing is used without it
+	NSFWClassifierURL string
+	// Score from 0 to 1 at which an image is flagged as NSFW
+	NSFWThreshold float64
+	// Reject uploads when a scanner fails instead of publishing them unscanned
+	ScanFailClosed bool
+
+	// Proxies allowed to forward the requested host (X-Forwarded-Host)
+	TrustedProxies []*net.IPNet
+
+	// Port custom domains are served on over TLS, they are only reachable over HTTP without it
+	HttpsPort int
+	// Hostname custom domains point their CNAME record at
+	CustomDomainTarget string
+	// DNS server (host:port) domain ownership is checked against, the system resolver is used without it
+	DNSResolver string
+	// ACME directory custom domain certificates are ordered from
+	ACMEDirectoryURL string
+	ACMEEmail        string
+	// PEM file with the CA the ACME directory's TLS certificate is issued by, for test CAs like Pebble
+	ACMECACertPath string
+
 	HenrikApiKey string
 )
 
@@ -79,6 +122,9 @@ func LoadConfig() error {
 	DiscordClientID = os.Getenv("DISCORD_CLIENT_ID")
 	DiscordClientSecret = os.Getenv("DISCORD_CLIENT_SECRET")
 	DiscordRedirectURI = os.Getenv("DISCORD_REDIRECT_URI")
+	DiscordModerationChannelID = os.Getenv("DISCORD_MODERATION_CHANNEL_ID")
+	DiscordRegistrationChannelID = os.Getenv("DISCORD_REGISTRATION_CHANNEL_ID")
+	DiscordNotificationChannelID = os.Getenv("DISCORD_NOTIFICATION_CHANNEL_ID")
 
 	RedisAddr = os.Getenv("REDIS_ADDR")
 	RedisPassword = os.Getenv("REDIS_PASSWORD")
@@ -94,6 +140,43 @@ func LoadConfig() error {
 	R2APIKey = os.Getenv("R2_API_KEY")
 	R2PublicURL = os.Getenv("R2_PUBLIC_URL")
 
+	APIPublicURL = strings.TrimSuffix(os.Getenv("API_PUBLIC_URL"), "/")
+
+	UploadURL = os.Getenv("UPLOAD_URL")
+	if UploadURL == "" {
+		UploadURL = R2URL
+	}
+	UploadGrantSecret = os.Getenv("UPLOAD_GRANT_SECRET")
+	FileSigningSecret = os.Getenv("FILE_SIGNING_SECRET")
+
+	FFmpegPath = os.Getenv("FFMPEG_PATH")
+	if FFmpegPath == "" {
+		FFmpegPath = "ffmpeg"
+	}
+
+	ClamAVAddress = os.Getenv("CLAMAV_ADDRESS")
+	NSFWClassifierURL = os.Getenv("NSFW_CLASSIFIER_URL")
+	NSFWThreshold, err = strconv.ParseFloat(os.Getenv("NSFW_THRESHOLD"), 64)
+	if err != nil || NSFWThreshold <= 0 {
+		NSFWThreshold = 0.85
+	}
+	ScanFailClosed = os.Getenv("SCAN_FAIL_CLOSED") == "true"
+
+	TrustedProxies, err = utils.ParseTrustedProxies(os.Getenv("TRUSTED_PROXIES"))
+	if err != nil {
+		return err
+	}
+
+	HttpsPort = utils.StringToInt(os.Getenv("HTTPS_PORT"))
+	CustomDomainTarget = os.Getenv("CUSTOM_DOMAIN_TARGET")
+	DNSResolver = os.Getenv("DNS_RESOLVER")
+	ACMEDirectoryURL = os.Getenv("ACME_DIRECTORY_URL")
+	if ACMEDirectoryURL == "" {
+		ACMEDirectoryURL = "https://acme-v02.api.letsencrypt.org/directory"
+	}
+	ACMEEmail = os.Getenv("ACME_EMAIL")
+	ACMECACertPath = os.Getenv("ACME_CA_CERT")
+
 	HenrikApiKey = os.Getenv("HENRIK_API_KEY")
 
 	return nil
@@ -2,10 +2,14 @@ package db
 
 import (
 	"errors"
+	"fmt"
 	"log"
+	"strings"
 	"time"
 
+	"github.com/hazebio/haze.bio_backend/config"
 	"github.com/hazebio/haze.bio_backend/models"
+	"github.com/hazebio/haze.bio_backend/utils"
 	"gorm.io/gorm"
 )
 
@@ -25,6 +29,14 @@ func StartMigration(db *gorm.DB) error {
 		&models.UserSession{},
 		&models.UserSubscription{},
 		&models.Badge{},
+		&models.BadgeRole{},
+		&models.BadgeRule{},
+		&models.BadgeRuleGrant{},
+		&models.BadgeEdit{},
+		&models.UserFile{},
+		&models.UploadGrant{},
+		&models.QuarantinedFile{},
+		&models.DiscordGuild{},
 		&models.Punishment{},
 		&models.ModerationLog{},
 		&models.Report{},
@@ -34,10 +46,21 @@ func StartMigration(db *gorm.DB) error {
 		&models.Template{},
 		&models.Application{},
 		&models.Response{},
+		&models.ApplicationPosition{},
+		&models.ApplicationFormVersion{},
+		&models.ApplicationReview{},
+		&models.ApplicationScore{},
 		
 		&models.Event{},
 		&models.DataExport{},
 		&models.InviteCode{},
+		&models.Notification{},
+		&models.NotificationPreference{},
+		&models.InviteCampaign{},
+		&models.ReferralRewardRule{},
+		&models.Referral{},
+		&models.ReferralReward{},
+		&models.CustomDomain{},
 	)
 	if err != nil {
 		log.Println("Error migrating models:", err)
@@ -50,6 +73,42 @@ func StartMigration(db *gorm.DB) error {
 		return err
 	}
 
+	// Create the primary guild from the env config
+	if err := SeedDiscordGuilds(db); err != nil {
+		log.Printf("Error seeding discord guilds: %v", err)
+		return err
+	}
+
+	// Move single badge roles into the many-to-many mapping table
+	if err := MigrateBadgeRoles(db); err != nil {
+		log.Printf("Error migrating badge roles: %v", err)
+		return err
+	}
+
+	// Add files uploaded before the media library to it
+	if err := BackfillUserFiles(db); err != nil {
+		log.Printf("Error backfilling user files: %v", err)
+		return err
+	}
+
+	// Serve externally hosted profile media through the media proxy
+	if err := ProxyExternalMediaURLs(db); err != nil {
+		log.Printf("Error proxying external media URLs: %v", err)
+		return err
+	}
+
+	// Move the hard-coded application positions into the database
+	if err := SeedApplicationPositions(db); err != nil {
+		log.Printf("Error seeding application positions: %v", err)
+		return err
+	}
+
+	// Referral codes used to be stored with the single use column default
+	if err := FixReferralCodeMaxUses(db); err != nil {
+		log.Printf("Error fixing referral code max uses: %v", err)
+		return err
+	}
+
 	log.Println("Migration completed")
 	return nil
 }
@@ -239,3 +298,245 @@ func CreateEarlyUserBadge(db *gorm.DB) error {
 	log.Println("Early User badge created successfully")
 	return nil
 }
+
+func SeedApplicationPositions(db *gorm.DB) error {
+	var count int64
+	if err := db.Model(&models.ApplicationPosition{}).Count(&count).Error; err != nil {
+		return err
+	}
+
+	if count > 0 {
+		return nil
+	}
+
+	log.Println("Starting migration: Seeding application positions")
+
+	return db.Transaction(func(tx *gorm.DB) error {
+		for _, position := range models.DefaultPositions {
+			if err := tx.Create(&models.ApplicationPosition{
+				ID:             position.ID,
+				Title:          position.Title,
+				Description:    position.Description,
+				Active:         position.Active,
+				StaffLevel:     position.StaffLevel,
+				CurrentVersion: 1,
+			}).Error; err != nil {
+				return err
+			}
+
+			if err := tx.Create(&models.ApplicationFormVersion{
+				PositionID: position.ID,
+				Version:    1,
+				Questions:  position.Questions,
+			}).Error; err != nil {
+				return err
+			}
+		}
+
+		log.Printf("Seeded %d application positions", len(models.DefaultPositions))
+		return nil
+	})
+}
+
+func MigrateBadgeRoles(db *gorm.DB) error {
+	var primary models.DiscordGuild
+	if err := db.Where("is_primary = ?", true).First(&primary).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil
+		}
+		return err
+	}
+
+	// Mappings created before guilds were configurable belong to the primary guild
+	if err := db.Model(&models.BadgeRole{}).Where("guild_id IS NULL OR guild_id = ''").Update("guild_id", primary.GuildID).Error; err != nil {
+		return err
+	}
+
+	var badges []models.Badge
+	if err := db.Where("discord_role_id IS NOT NULL AND discord_role_id <> ''").Find(&badges).Error; err != nil {
+		return err
+	}
+
+	if len(badges) == 0 {
+		return nil
+	}
+
+	log.Println("Starting migration: Moving badge roles into badge_roles")
+
+	return db.Transaction(func(tx *gorm.DB) error {
+		for _, badge := range badges {
+			var count int64
+			if err := tx.Model(&models.BadgeRole{}).Where("badge_id = ? AND role_id = ?", badge.ID, badge.DiscordRoleID).Count(&count).Error; err != nil {
+				return err
+			}
+
+			if count == 0 {
+				if err := tx.Create(&models.BadgeRole{BadgeID: badge.ID, GuildID: primary.GuildID, RoleID: badge.DiscordRoleID}).Error; err != nil {
+					return err
+				}
+			}
+
+			if err := tx.Model(&models.Badge{}).Where("id = ?", badge.ID).Update("discord_role_id", "").Error; err != nil {
+				return err
+			}
+		}
+
+		log.Printf("Migrated %d badge roles", len(badges))
+		return nil
+	})
+}
+
+func SeedDiscordGuilds(db *gorm.DB) error {
+	if config.DiscordGuildID == "" {
+		return nil
+	}
+
+	var count int64
+	if err := db.Model(&models.DiscordGuild{}).Count(&count).Error; err != nil {
+		return err
+	}
+
+	if count > 0 {
+		return nil
+	}
+
+	log.Println("Starting migration: Seeding primary discord guild")
+
+	// Channels left unset are skipped until an admin sets them through the guild endpoints
+	return db.Create(&models.DiscordGuild{
+		GuildID:               config.DiscordGuildID,
+		Name:                  "Main",
+		IsPrimary:             true,
+		RegistrationChannelID: config.DiscordRegistrationChannelID,
+		NotificationChannelID: config.DiscordNotificationChannelID,
+		ModerationChannelID:   config.DiscordModerationChannelID,
+		LinkedRoleID:          config.DiscordLinkedRoleID,
+		BoosterBadge:          "Booster",
+		BoosterEditCredits:    3,
+	}).Error
+}
+
+/*
+Record profile files uploaded before user_files existed so they show up in
+the media library. Their size is unknown, so they don't count towards the
+storage quota.
+*/
+func BackfillUserFiles(db *gorm.DB) error {
+	if config.R2PublicURL == "" {
+		return nil
+	}
+
+	prefix := config.R2PublicURL + "/"
+
+	for _, field := range []string{"avatar_url", "background_url", "audio_url", "cursor_url", "banner_url"} {
+		query := fmt.Sprintf(`INSERT INTO user_files (uid, key, url, size, usage, created_at)
+			SELECT p.uid, SUBSTRING(p.%[1]s FROM ?), p.%[1]s, 0, '%[1]s', NOW()
+			FROM user_profiles p
+			WHERE p.%[1]s LIKE ? AND NOT EXISTS (SELECT 1 FROM user_files f WHERE f.url = p.%[1]s)
+			ON CONFLICT (key) DO NOTHING`, field)
+
+		result := db.Exec(query, len(prefix)+1, prefix+"%")
+		if result.Error != nil {
+			return result.Error
+		}
+
+		if result.RowsAffected > 0 {
+			log.Printf("Backfilled %d user files for %s", result.RowsAffected, field)
+		}
+	}
+
+	return nil
+}
+
+func ProxyExternalMediaURLs(db *gorm.DB) error {
+	if config.APIPublicURL == "" {
+		return nil
+	}
+
+	proxyPrefix := config.APIPublicURL + "/api/media/proxy/"
+	proxyURL := func(rawURL string) string {
+		if !strings.HasPrefix(rawURL, "https://") && !strings.HasPrefix(rawURL, "http://") {
+			return rawURL
+		}
+		if strings.HasPrefix(rawURL, proxyPrefix) || (config.R2PublicURL != "" && strings.HasPrefix(rawURL, config.R2PublicURL+"/")) {
+			return rawURL
+		}
+		return config.APIPublicURL + "/api" + utils.MediaProxyPath(rawURL, config.SecretKey)
+	}
+
+	var proxiedCount int
+
+	for _, field := range []string{"avatar_url", "background_url", "cursor_url", "banner_url", "decoration_url"} {
+		var rows []struct {
+			UID uint
+			URL string
+		}
+
+		err := db.Model(&models.UserProfile{}).
+			Select(fmt.Sprintf("uid, %s AS url", field)).
+			Where(fmt.Sprintf("%[1]s LIKE 'http%%' AND %[1]s NOT LIKE ?", field), proxyPrefix+"%").
+			Scan(&rows).Error
+		if err != nil {
+			return err
+		}
+
+		for _, row := range rows {
+			proxied := proxyURL(row.URL)
+			if proxied == row.URL {
+				continue
+			}
+
+			if err := db.Model(&models.UserProfile{}).Where("uid = ?", row.UID).Update(field, proxied).Error; err != nil {
+				log.Printf("Error proxying %s for user %d: %v", field, row.UID, err)
+				continue
+			}
+			proxiedCount++
+		}
+	}
+
+	var socials []models.UserSocial
+	err := db.Select("id", "image_url").
+		Where("image_url LIKE 'http%' AND image_url NOT LIKE ?", proxyPrefix+"%").
+		Find(&socials).Error
+	if err != nil {
+		return err
+	}
+
+	for _, social := range socials {
+		proxied := proxyURL(social.ImageURL)
+		if proxied == social.ImageURL {
+			continue
+		}
+
+		if err := db.Model(&models.UserSocial{}).Where("id = ?", social.ID).Update("image_url", proxied).Error; err != nil {
+			log.Printf("Error proxying image of social %d: %v", social.ID, err)
+			continue
+		}
+		proxiedCount++
+	}
+
+	if proxiedCount > 0 {
+		log.Printf("Proxied %d external media URLs", proxiedCount)
+	}
+
+	return nil
+}
+
+/*
+Referral codes were created with MaxUses 0, which GORM replaced with the
+column default of 1, so every code stopped working after one signup.
+*/
+func FixReferralCodeMaxUses(db *gorm.DB) error {
+	result := db.Model(&models.InviteCode{}).
+		Where("is_referral = ? AND max_uses = ?", true, 1).
+		Updates(map[string]interface{}{"max_uses": 0, "used_by": nil})
+	if result.Error != nil {
+		return result.Error
+	}
+
+	if result.RowsAffected > 0 {
+		log.Printf("Made %d referral codes unlimited", result.RowsAffected)
+	}
+
+	return nil
+}
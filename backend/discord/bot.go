@@ -4,7 +4,6 @@ import (
 	"encoding/json"
 	"fmt"
 	"log"
-	"strings"
 	"time"
 
 	"github.com/bwmarrin/discordgo"
@@ -19,6 +18,8 @@ type Bot struct {
 	Session      *discordgo.Session
 	handler      *Handler
 	services     *ServiceManager
+	queue        *ModerationQueue
+	roleSync     *RoleSync
 	eventService *services.EventService
 }
 
@@ -39,12 +40,19 @@ func NewBot(redisClient *redis.Client) (*Bot, error) {
 	badgeService := services.NewBadgeService(db.DB, redisClient)
 	punishService := services.NewPunishService(db.DB, redisClient)
 	profileService := services.NewProfileService(db.DB, redisClient, session, discordService)
-	redeemService := services.NewRedeemService(db.DB, redisClient)
+	redeemService := services.NewRedeemService(db.DB, redisClient, badgeService)
 	statusService := services.NewStatusService(db.DB)
-	imageService := services.NewImageService()
+	imageService := services.NewImageService(redisClient)
 	altAccountService := services.NewAltAccountService(db.DB, redisClient, userService.EventService)
 	eventService := services.NewEventService(db.DB, redisClient, session)
 	inviteService := services.NewInviteService(db.DB, redisClient)
+	emailService := services.NewEmailService(db.DB, redisClient, eventService)
+	applyService := services.NewApplyService(db.DB, redisClient, emailService, userService, badgeService)
+	badgeService.EventService = eventService
+	badgeService.FileService = services.NewFileService(db.DB, redisClient)
+	badgeService.NotificationService = services.NewNotificationService(db.DB, redisClient, session)
+	guildService := discordService.GuildService
+	guildService.EventService = eventService
 
 
 	serviceManager := &ServiceManager{
@@ -59,12 +67,19 @@ func NewBot(redisClient *redis.Client) (*Bot, error) {
 		AltAccount:   altAccountService,
 		Event:        eventService,
 		Invite:       inviteService,
+		Apply:        applyService,
+		Guild:        guildService,
 	}
 
+	queue := NewModerationQueue(serviceManager)
+	roleSync := NewRoleSync(serviceManager, session)
+
 	bot := &Bot{
 		Session:      session,
-		handler:      NewHandler(serviceManager),
+		handler:      NewHandler(serviceManager, queue),
 		services:     serviceManager,
+		queue:        queue,
+		roleSync:     roleSync,
 		eventService: eventService,
 	}
 
@@ -78,10 +93,12 @@ func (b *Bot) Start() error {
 		return err
 	}
 
+	if err := b.handler.RegisterCommands(b.Session); err != nil {
+		log.Println("Error registering slash commands:", err)
+	}
+
 	log.Println("Discord Bot is now running.")
-	go b.handler.StartBoosterCheckCron(b.Session)
-	go b.handler.StartBadgeRoleSync(b.Session)
-	go b.handler.StartLinkedRoleSync(b.Session)
+	go b.roleSync.Run()
 
 	return nil
 }
@@ -93,8 +110,26 @@ func (b *Bot) registerEventHandlers() {
 
 	b.eventService.Subscribe(models.EventDiscordLinked, b.handleDiscordLinked)
 
+	b.eventService.Subscribe(models.EventDiscordUnlinked, b.handleDiscordUnlinked)
+
+	b.eventService.Subscribe(models.EventBadgeAssigned, b.handleBadgeChanged)
+
+	b.eventService.Subscribe(models.EventBadgeRemoved, b.handleBadgeChanged)
+
+	b.eventService.Subscribe(models.EventBadgeRolesUpdated, b.handleBadgeRolesUpdated)
+
+	b.eventService.Subscribe(models.EventGuildUpdated, b.handleGuildUpdated)
+
 	b.eventService.Subscribe(models.EventRedeemCodeUsed, b.handleRedeemCodeUsed)
 
+	b.eventService.Subscribe(models.EventReportCreated, b.handleReportCreated)
+
+	b.eventService.Subscribe(models.EventReportUpdated, b.handleReportUpdated)
+
+	b.eventService.Subscribe(models.EventBadgeEditSubmitted, b.handleBadgeEditSubmitted)
+
+	b.eventService.Subscribe(models.EventBadgeEditReviewed, b.handleBadgeEditReviewed)
+
 	log.Println("Event handlers registered successfully")
 }
 
@@ -109,8 +144,6 @@ func (b *Bot) handleUserRegistration(event *models.Event) error {
 		return fmt.Errorf("error unmarshaling registration data: %w", err)
 	}
 
-	channelID := "1408080627798118400"
-
 	ordinal := getOrdinalSuffix(data.UID)
 
 	profileURL := fmt.Sprintf("https://cutz.lol/%s", data.Username)
@@ -124,7 +157,7 @@ func (b *Bot) handleUserRegistration(event *models.Event) error {
 		},
 	}
 
-	_, err := b.Session.ChannelMessageSendEmbed(channelID, embed)
+	err := b.broadcast(func(guild *models.DiscordGuild) string { return guild.RegistrationChannelID }, embed)
 	if err != nil {
 		return fmt.Errorf("error sending registration notification: %w", err)
 	}
@@ -140,7 +173,7 @@ func (b *Bot) handlePotentialAltAccount(event *models.Event) error {
 			return fmt.Errorf("error unmarshaling alt account data: %w", err)
 		}
 
-		return b.processAltAccountAlert(altData, event.CreatedAt)
+		return b.processAltAccountAlert(event.ID, altData, event.CreatedAt)
 	}
 
 	if event.Type == models.EventUserRegistered || event.Type == models.EventUserLoggedIn {
@@ -175,108 +208,54 @@ func (b *Bot) handlePotentialAltAccount(event *models.Event) error {
 			}
 		}
 
-		return b.processAltAccountAlert(altData, event.CreatedAt)
+		return b.processAltAccountAlert(event.ID, altData, event.CreatedAt)
 	}
 
 	return nil
 }
 
-func (b *Bot) processAltAccountAlert(altData models.AltAccountData, timestamp time.Time) error {
-	channelID := "1401929667165945889"
-
-	altUsernamesFormatted := make([]string, 0, len(altData.AltAccounts))
-
-	for _, alt := range altData.AltAccounts {
-		altText := fmt.Sprintf("**%s** (UID: %d)", alt.Username, alt.UID)
-		if alt.MatchReason != "" {
-			altText += fmt.Sprintf(" - *%s*", alt.MatchReason)
-		}
-		altUsernamesFormatted = append(altUsernamesFormatted, altText)
-	}
-
-	fields := []*discordgo.MessageEmbedField{
-		{
-			Name:   "User",
-			Value:  fmt.Sprintf("**%s** (UID: %d)", altData.Username, altData.UID),
-			Inline: true,
-		},
-		{
-			Name:   "Shared IP",
-			Value:  fmt.Sprintf("`%s`", altData.IPAddress),
-			Inline: true,
-		},
-		{
-			Name:   "Detection Time",
-			Value:  fmt.Sprintf("<t:%d:R>", timestamp.Unix()),
-			Inline: true,
-		},
-	}
-
-	if len(altUsernamesFormatted) > 0 {
-		fields = append(fields, &discordgo.MessageEmbedField{
-			Name:   "Potential Alt Accounts",
-			Value:  strings.Join(altUsernamesFormatted, "\n"),
-			Inline: false,
-		})
-	}
+func (b *Bot) processAltAccountAlert(eventID string, altData models.AltAccountData, timestamp time.Time) error {
+	return b.queue.PostAltAlert(b.Session, eventID, altData, timestamp)
+}
 
-	if altData.Notes != "" {
-		fields = append(fields, &discordgo.MessageEmbedField{
-			Name:   "Notes",
-			Value:  altData.Notes,
-			Inline: false,
-		})
+func (b *Bot) handleReportCreated(event *models.Event) error {
+	var data models.ReportEventData
+	bytes, _ := json.Marshal(event.Data)
+	if err := json.Unmarshal(bytes, &data); err != nil {
+		return fmt.Errorf("error unmarshaling report data: %w", err)
 	}
 
-	if altData.Confidence > 0 {
-		confidenceEmoji := "🟨"
-		if altData.Confidence >= 0.8 {
-			confidenceEmoji = "🟥"
-		} else if altData.Confidence < 0.5 {
-			confidenceEmoji = "🟩"
-		}
+	return b.queue.PostReport(b.Session, data.ReportID)
+}
 
-		fields = append(fields, &discordgo.MessageEmbedField{
-			Name:   "Confidence",
-			Value:  fmt.Sprintf("%s %.0f%%", confidenceEmoji, altData.Confidence*100),
-			Inline: true,
-		})
+func (b *Bot) handleReportUpdated(event *models.Event) error {
+	var data models.ReportEventData
+	bytes, _ := json.Marshal(event.Data)
+	if err := json.Unmarshal(bytes, &data); err != nil {
+		return fmt.Errorf("error unmarshaling report data: %w", err)
 	}
 
-	title := "Potential Alt Account Detected"
-	description := "A potential alt account has been detected during "
+	return b.queue.RefreshReport(b.Session, data.ReportID)
+}
 
-	isNewRegistration := altData.DetectionSource == string(models.EventUserRegistered)
-	if isNewRegistration {
-		description += "registration."
-		title = "⚠️ " + title + " (New Registration)"
-	} else if altData.DetectionSource == string(models.EventUserLoggedIn) {
-		description += "login with a new IP."
-		title = "🔍 " + title + " (Login)"
-	} else {
-		description += altData.DetectionSource + "."
-		title = "🔎 " + title
+func (b *Bot) handleBadgeEditSubmitted(event *models.Event) error {
+	var data models.BadgeEditEventData
+	bytes, _ := json.Marshal(event.Data)
+	if err := json.Unmarshal(bytes, &data); err != nil {
+		return fmt.Errorf("error unmarshaling badge edit data: %w", err)
 	}
 
-	profileURL := fmt.Sprintf("https://cutz.lol/%s", altData.Username)
-	embed := &discordgo.MessageEmbed{
-		URL:         profileURL,
-		Title:       title,
-		Description: description,
-		Color:       0xFFA500,
-		Fields:      fields,
-		Footer: &discordgo.MessageEmbedFooter{
-			Text: "cutz.lol security",
-		},
-		Timestamp: timestamp.Format(time.RFC3339),
-	}
+	return b.queue.PostBadgeEdit(b.Session, data.EditID)
+}
 
-	_, err := b.Session.ChannelMessageSendEmbed(channelID, embed)
-	if err != nil {
-		return fmt.Errorf("error sending alt account notification: %w", err)
+func (b *Bot) handleBadgeEditReviewed(event *models.Event) error {
+	var data models.BadgeEditEventData
+	bytes, _ := json.Marshal(event.Data)
+	if err := json.Unmarshal(bytes, &data); err != nil {
+		return fmt.Errorf("error unmarshaling badge edit data: %w", err)
 	}
 
-	return nil
+	return b.queue.RefreshBadgeEdit(b.Session, data.EditID)
 }
 
 func getOrdinalSuffix(num uint) string {
@@ -298,13 +277,27 @@ func getOrdinalSuffix(num uint) string {
 
 func (b *Bot) registerHandlers() {
 	b.Session.AddHandler(b.handler.HandleMessage)
+	b.Session.AddHandler(b.handler.HandleInteraction)
 	b.Session.AddHandler(func(s *discordgo.Session, p *discordgo.PresenceUpdate) {
-		err := s.State.PresenceAdd(config.DiscordGuildID, &p.Presence)
+		if !b.services.Guild.IsEnabled(p.GuildID) {
+			return
+		}
+
+		err := s.State.PresenceAdd(p.GuildID, &p.Presence)
 		if err != nil {
 			log.Println("PresenceAdd failed:", err)
 		}
+
+		if err := b.services.Discord.PublishPresence(&p.Presence); err != nil {
+			log.Println("Error publishing presence update:", err)
+		}
 	})
 	b.Session.AddHandler(b.handleGuildMemberUpdate)
+	b.Session.AddHandler(func(s *discordgo.Session, m *discordgo.GuildMemberAdd) {
+		if m.Member != nil && m.User != nil && b.services.Guild.IsEnabled(m.GuildID) {
+			b.roleSync.EnqueueMember(m.GuildID, m.User.ID, "member")
+		}
+	})
 }
 
 func (b *Bot) handleDiscordLinked(event *models.Event) error {
@@ -315,7 +308,7 @@ func (b *Bot) handleDiscordLinked(event *models.Event) error {
 		return fmt.Errorf("error unmarshaling discord linked data: %w", err)
 	}
 
-	channelID := "1401929667165945889"
+	b.roleSync.Enqueue(data.DiscordID, "link")
 
 	profileURL := fmt.Sprintf("https://cutz.lol/%s", data.Username)
 	embed := &discordgo.MessageEmbed{
@@ -346,7 +339,7 @@ func (b *Bot) handleDiscordLinked(event *models.Event) error {
 		Timestamp: time.Now().Format(time.RFC3339),
 	}
 
-	_, err := b.Session.ChannelMessageSendEmbed(channelID, embed)
+	err := b.broadcast(func(guild *models.DiscordGuild) string { return guild.NotificationChannelID }, embed)
 	if err != nil {
 		return fmt.Errorf("error sending discord linked notification: %w", err)
 	}
@@ -354,6 +347,98 @@ func (b *Bot) handleDiscordLinked(event *models.Event) error {
 	return nil
 }
 
+func (b *Bot) handleDiscordUnlinked(event *models.Event) error {
+	var data models.DiscordUnlinkedData
+
+	bytes, _ := json.Marshal(event.Data)
+	if err := json.Unmarshal(bytes, &data); err != nil {
+		return fmt.Errorf("error unmarshaling discord unlinked data: %w", err)
+	}
+
+	b.roleSync.Enqueue(data.DiscordID, "link")
+	return nil
+}
+
+func (b *Bot) handleBadgeChanged(event *models.Event) error {
+	var data models.BadgeEventData
+
+	bytes, _ := json.Marshal(event.Data)
+	if err := json.Unmarshal(bytes, &data); err != nil {
+		return fmt.Errorf("error unmarshaling badge data: %w", err)
+	}
+
+	b.roleSync.EnqueueUser(data.UID, "badge")
+	return nil
+}
+
+func (b *Bot) handleBadgeRolesUpdated(event *models.Event) error {
+	var data models.BadgeRolesUpdatedData
+
+	bytes, _ := json.Marshal(event.Data)
+	if err := json.Unmarshal(bytes, &data); err != nil {
+		return fmt.Errorf("error unmarshaling badge roles data: %w", err)
+	}
+
+	var revoke []string
+	if data.RevokedRoleID != "" {
+		revoke = append(revoke, data.RevokedRoleID)
+	}
+
+	return b.roleSync.EnqueueBadgeHolders(data.GuildID, data.BadgeName, "mapping", revoke...)
+}
+
+func (b *Bot) handleGuildUpdated(event *models.Event) error {
+	var data models.GuildUpdatedData
+
+	bytes, _ := json.Marshal(event.Data)
+	if err := json.Unmarshal(bytes, &data); err != nil {
+		return fmt.Errorf("error unmarshaling guild data: %w", err)
+	}
+
+	if data.Deleted {
+		return nil
+	}
+
+	guild, err := b.services.Guild.GetGuild(data.GuildID)
+	if err != nil {
+		return err
+	}
+
+	if !guild.IsEnabled() {
+		return nil
+	}
+
+	if err := b.handler.RegisterGuildCommands(b.Session, guild.GuildID); err != nil {
+		log.Printf("Error registering slash commands in %s: %v", guild.GuildID, err)
+	}
+
+	// Linked role or booster settings may have changed
+	go b.roleSync.ReconcileGuild(guild)
+	return nil
+}
+
+/* Send an embed to a channel of every enabled guild that has it configured */
+func (b *Bot) broadcast(channel func(guild *models.DiscordGuild) string, embed *discordgo.MessageEmbed) error {
+	guilds, err := b.services.Guild.GetEnabledGuilds()
+	if err != nil {
+		return err
+	}
+
+	var lastErr error
+	for i := range guilds {
+		channelID := channel(&guilds[i])
+		if channelID == "" {
+			continue
+		}
+
+		if _, err := b.Session.ChannelMessageSendEmbed(channelID, embed); err != nil {
+			lastErr = err
+		}
+	}
+
+	return lastErr
+}
+
 func (b *Bot) handleRedeemCodeUsed(event *models.Event) error {
 	var data models.RedeemCodeData
 
@@ -362,8 +447,6 @@ func (b *Bot) handleRedeemCodeUsed(event *models.Event) error {
 		return fmt.Errorf("error unmarshaling redeem code data: %w", err)
 	}
 
-	channelID := "1401929667165945889"
-
 	profileURL := fmt.Sprintf("https://cutz.lol/%s", data.Username)
 	embed := &discordgo.MessageEmbed{
 		URL:         profileURL,
@@ -398,7 +481,7 @@ func (b *Bot) handleRedeemCodeUsed(event *models.Event) error {
 		Timestamp: data.RedeemedAt.Format(time.RFC3339),
 	}
 
-	_, err := b.Session.ChannelMessageSendEmbed(channelID, embed)
+	err := b.broadcast(func(guild *models.DiscordGuild) string { return guild.NotificationChannelID }, embed)
 	if err != nil {
 		return fmt.Errorf("error sending redeem code notification: %w", err)
 	}
@@ -407,16 +490,33 @@ func (b *Bot) handleRedeemCodeUsed(event *models.Event) error {
 }
 
 func (b *Bot) handleGuildMemberUpdate(s *discordgo.Session, m *discordgo.GuildMemberUpdate) {
-	// Send boost notification
-	channelID := "1401929667165945889"
-	
+	if m.Member == nil || m.User == nil {
+		return
+	}
+
+	guild, err := b.services.Guild.GetGuild(m.GuildID)
+	if err != nil || !guild.IsEnabled() {
+		return
+	}
+
+	b.roleSync.EnqueueMember(guild.GuildID, m.User.ID, "member")
+
+	// Only thank members when they start boosting, not on every member update
+	if m.PremiumSince == nil || m.BeforeUpdate == nil || m.BeforeUpdate.PremiumSince != nil {
+		return
+	}
+
+	if guild.NotificationChannelID == "" {
+		return
+	}
+
 	embed := &discordgo.MessageEmbed{
 		Title:       "Thank you for boosting! <a:rgbheart:1411330051512995931>",
 		Description: "To claim your cutz.lol booster badge, link your discord account on [cutz.lol](https://cutz.lol)",
 		Color:       0x8B5CF6, // Purple color
 	}
-	
-	_, err := s.ChannelMessageSendEmbed(channelID, embed)
+
+	_, err = s.ChannelMessageSendEmbed(guild.NotificationChannelID, embed)
 	if err != nil {
 		log.Printf("Error sending boost notification: %v", err)
 	}
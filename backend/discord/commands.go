@@ -1,11 +1,10 @@
 package discord
 
 import (
+	"bytes"
 	"fmt"
 	"log"
 	"math"
-	"os"
-	"regexp"
 	"strconv"
 	"strings"
 	"time"
@@ -13,6 +12,7 @@ import (
 	"github.com/bwmarrin/discordgo"
 	"github.com/hazebio/haze.bio_backend/config"
 	"github.com/hazebio/haze.bio_backend/models"
+	"github.com/hazebio/haze.bio_backend/services"
 	"github.com/hazebio/haze.bio_backend/utils"
 )
 
@@ -33,127 +33,622 @@ var staffLevelNames = map[uint]string{
 }
 
 type Commands struct {
-	services *ServiceManager
+	services       *ServiceManager
+	queue          *ModerationQueue
+	commands       map[string]*SlashCommand
+	commandList    []*SlashCommand
+	confirmations  map[string]*confirmAction
+	autocompleters map[string]autocompleteFunc
+
+	// Lookups made on every interaction, replaced in tests so dispatch runs without a database
+	findLinkedUser   func(discordID string) *models.User
+	underMaintenance func() bool
 }
 
-func NewCommands(services *ServiceManager) *Commands {
-	return &Commands{services: services}
+func NewCommands(services *ServiceManager, queue *ModerationQueue) *Commands {
+	c := &Commands{
+		services: services,
+		queue:    queue,
+		commands: map[string]*SlashCommand{},
+	}
+
+	c.findLinkedUser = c.lookupLinkedUser
+	c.underMaintenance = func() bool {
+		activeStatus, _ := services.Status.GetActiveStatus()
+		return activeStatus != nil
+	}
+
+	c.register(c.slashCommands()...)
+
+	c.confirmations = map[string]*confirmAction{
+		"deleteuser":   {StaffLevel: StaffLevelAdmin, Handler: c.confirmDeleteUser},
+		"deletestatus": {StaffLevel: StaffLevelAdmin, Handler: c.confirmDeleteStatus},
+	}
+
+	c.autocompleters = map[string]autocompleteFunc{
+		"username":          c.completeUsernames,
+		"new_username":      c.completeUsernames,
+		"existing_username": c.completeUsernames,
+		"badge":             c.completeBadges,
+		"position":          c.completePositions,
+	}
+
+	return c
 }
 
+/* Prefix commands have been replaced by slash commands, point users to the new command */
 func (c *Commands) Execute(s *discordgo.Session, m *discordgo.MessageCreate) {
 	if len(m.Content) < 1 || m.Content[:1] != config.DiscordPrefix {
 		return
 	}
 
 	args := strings.Fields(m.Content)
-	cmd := strings.TrimPrefix(args[0], config.DiscordPrefix)
+	if len(args) == 0 {
+		return
+	}
 
-	activeStatus, _ := c.services.Status.GetActiveStatus()
-	isUnderMaintenance := activeStatus != nil
+	cmd := strings.ToLower(strings.TrimPrefix(args[0], config.DiscordPrefix))
+	if cmd == "lb" {
+		cmd = "leaderboard"
+	}
 
-	isAdmin := m.Author.Username == "mouviel"
-	if isUnderMaintenance && !isAdmin && cmd != "status" {
-		embed := &discordgo.MessageEmbed{
-			Title:       "System Maintenance",
-			Description: "Only `?status` is available during maintenance.",
-			Color:       0xEAB308,
-			Footer: &discordgo.MessageEmbedFooter{
-				Text: "cutz.lol maintenance system",
-			},
-			Timestamp: time.Now().Format(time.RFC3339),
-		}
-		s.ChannelMessageSendEmbed(m.ChannelID, embed)
+	if _, ok := c.commands[cmd]; !ok {
 		return
 	}
 
-	switch cmd {
-	case "help":
-		if len(args) > 1 {
-			switch args[1] {
-			case "admin":
-				c.handleAdminHelp(s, m)
-			default:
-				c.handleHelp(s, m)
-			}
-		} else {
-			c.handleHelp(s, m)
-		}
-	case "addbadge":
-		c.handleAddBadge(s, m, args)
-	case "removebadge":
-		c.handleRemoveBadge(s, m, args)
-	case "createbadge":
-		c.handleCreateBadge(s, m, args)
-	case "check":
-		c.handleCheck(s, m, args)
-	case "profile":
-		c.handleProfile(s, m, args)
-	case "uidprofile":
-		c.handleUIDProfile(s, m, args)
-	case "me":
-		c.handleMe(s, m)
-	case "leaderboard":
-		c.handleLeaderboard(s, m)
-	case "lb":
-		c.handleLeaderboard(s, m)
-	case "stats":
-		c.handleStats(s, m)
-	case "status":
-		c.handleStatus(s, m)
-	case "createstatus":
-		c.handleCreateStatus(s, m, args)
-	case "deletestatus":
-		c.handleDeleteStatus(s, m, args)
-	case "generate":
-		c.handleGenerate(s, m, args)
-	case "setstafflevel":
-		c.handleSetStaffLevel(s, m, args)
-	case "staffinfo":
-		c.handleStaffInfo(s, m, args)
-	case "triggerevent":
-		c.handleTriggerEvent(s, m, args)
-
-	case "timediff":
-		c.handleTimeDiff(s, m, args)
-	case "createproductcode":
-		c.handleCreateProductCode(s, m, args)
-	case "invite":
-		c.handleInvite(s, m, args)
-	case "invites":
-		c.handleInvites(s, m, args)
-	case "deleteinvite":
-		c.handleDeleteInvite(s, m, args)
-	case "deleteuser":
-		c.handleDeleteUser(s, m, args)
-	case "updatebadgerole":
-		c.handleUpdateBadgeRole(s, m, args)
-	case "claim":
-		c.handleClaim(s, m)
-	default:
-		if cmd != "" {
-			s.ChannelMessageSend(m.ChannelID, "Invalid command. Type `?help` for a list of commands.")
-		}
+	embed := &discordgo.MessageEmbed{
+		Title:       "Commands have moved",
+		Description: fmt.Sprintf("Prefix commands are no longer supported. Use `/%s` instead.", cmd),
+		Color:       0x000000,
+		Footer: &discordgo.MessageEmbedFooter{
+			Text: "cutz.lol",
+		},
+		Timestamp: time.Now().Format(time.RFC3339),
 	}
+	s.ChannelMessageSendEmbed(m.ChannelID, embed)
 }
 
-func (c *Commands) handleCreateProductCode(s *discordgo.Session, m *discordgo.MessageCreate, args []string) {
-	adminUser, err := c.services.Discord.GetUserByDiscordID(m.Author.ID)
-	if err != nil || (adminUser.StaffLevel < StaffLevelAdmin && m.Author.Username != "mouviel") {
-		c.sendUnauthorizedEmbed(s, m, "You are not authorized to create product codes")
-		return
+func floatPtr(value float64) *float64 {
+	return &value
+}
+
+func usernameOption(description string, required bool) *discordgo.ApplicationCommandOption {
+	return &discordgo.ApplicationCommandOption{
+		Type:         discordgo.ApplicationCommandOptionString,
+		Name:         "username",
+		Description:  description,
+		Required:     required,
+		Autocomplete: true,
 	}
+}
 
-	if len(args) < 2 {
-		c.sendInvalidUsageEmbed(s, m, "Please specify a product type",
-			"`?createproductcode <product_type>`",
-			"`?createproductcode premium`\n`?createproductcode custombadge`\n`?createproductcode badgecredits`")
-		return
+func badgeOption(description string) *discordgo.ApplicationCommandOption {
+	return &discordgo.ApplicationCommandOption{
+		Type:         discordgo.ApplicationCommandOptionString,
+		Name:         "badge",
+		Description:  description,
+		Required:     true,
+		Autocomplete: true,
+	}
+}
+
+func positionOption(description string) *discordgo.ApplicationCommandOption {
+	return &discordgo.ApplicationCommandOption{
+		Type:         discordgo.ApplicationCommandOptionString,
+		Name:         "position",
+		Description:  description,
+		Required:     true,
+		Autocomplete: true,
+	}
+}
+
+func (c *Commands) slashCommands() []*SlashCommand {
+	return []*SlashCommand{
+		{
+			Definition: &discordgo.ApplicationCommand{
+				Name:        "help",
+				Description: "List the commands available to you",
+			},
+			Category: "User Commands",
+			Handler:  c.handleHelp,
+		},
+		{
+			Definition: &discordgo.ApplicationCommand{
+				Name:        "profile",
+				Description: "Show a cutz.lol profile",
+				Options: []*discordgo.ApplicationCommandOption{
+					usernameOption("Username of the profile", true),
+				},
+			},
+			Category: "User Commands",
+			Handler:  c.handleProfile,
+		},
+		{
+			Definition: &discordgo.ApplicationCommand{
+				Name:        "uidprofile",
+				Description: "Show a cutz.lol profile by user ID",
+				Options: []*discordgo.ApplicationCommandOption{
+					{
+						Type:        discordgo.ApplicationCommandOptionInteger,
+						Name:        "uid",
+						Description: "User ID of the profile",
+						Required:    true,
+						MinValue:    floatPtr(1),
+					},
+				},
+			},
+			Category: "User Commands",
+			Handler:  c.handleUIDProfile,
+		},
+		{
+			Definition: &discordgo.ApplicationCommand{
+				Name:        "me",
+				Description: "Show the profile linked to your Discord account",
+			},
+			Category: "User Commands",
+			Handler:  c.handleMe,
+		},
+		{
+			Definition: &discordgo.ApplicationCommand{
+				Name:        "leaderboard",
+				Description: "Show the users with the most views",
+			},
+			Category: "User Commands",
+			Handler:  c.handleLeaderboard,
+		},
+		{
+			Definition: &discordgo.ApplicationCommand{
+				Name:        "stats",
+				Description: "Show cutz.lol statistics",
+			},
+			Category: "User Commands",
+			Handler:  c.handleStats,
+		},
+		{
+			Definition: &discordgo.ApplicationCommand{
+				Name:        "status",
+				Description: "Show the current system status",
+			},
+			Category: "User Commands",
+			Handler:  c.handleStatus,
+		},
+		{
+			Definition: &discordgo.ApplicationCommand{
+				Name:        "generate",
+				Description: "Generate a profile card",
+				Options: []*discordgo.ApplicationCommandOption{
+					usernameOption("Username of the profile", true),
+				},
+			},
+			Category: "User Commands",
+			Handler:  c.handleGenerate,
+		},
+		{
+			Definition: &discordgo.ApplicationCommand{
+				Name:        "timediff",
+				Description: "Show the time between two messages in this channel",
+				Options: []*discordgo.ApplicationCommandOption{
+					{
+						Type:        discordgo.ApplicationCommandOptionString,
+						Name:        "first",
+						Description: "ID of the first message",
+						Required:    true,
+					},
+					{
+						Type:        discordgo.ApplicationCommandOptionString,
+						Name:        "second",
+						Description: "ID of the second message",
+						Required:    true,
+					},
+				},
+			},
+			Category: "User Commands",
+			Handler:  c.handleTimeDiff,
+		},
+		{
+			Definition: &discordgo.ApplicationCommand{
+				Name:        "claim",
+				Description: "Claim the Early User badge",
+			},
+			Category: "User Commands",
+			Handler:  c.handleClaim,
+		},
+		{
+			Definition: &discordgo.ApplicationCommand{
+				Name:        "staffinfo",
+				Description: "Show staff information for yourself or another user",
+				Options: []*discordgo.ApplicationCommandOption{
+					usernameOption("Username to check (Moderator or higher)", false),
+				},
+			},
+			Category: "Staff Management",
+			Handler:  c.handleStaffInfo,
+		},
+		{
+			Definition: &discordgo.ApplicationCommand{
+				Name:        "check",
+				Description: "Look up a user",
+				Options: []*discordgo.ApplicationCommandOption{
+					usernameOption("Username of the user", false),
+					{
+						Type:        discordgo.ApplicationCommandOptionUser,
+						Name:        "discord",
+						Description: "Discord account linked to the user",
+					},
+					{
+						Type:        discordgo.ApplicationCommandOptionInteger,
+						Name:        "uid",
+						Description: "User ID of the user",
+						MinValue:    floatPtr(1),
+					},
+					{
+						Type:        discordgo.ApplicationCommandOptionString,
+						Name:        "view",
+						Description: "Information to show",
+						Choices: []*discordgo.ApplicationCommandOptionChoice{
+							{Name: "General", Value: "general"},
+							{Name: "Discord", Value: "discord"},
+							{Name: "Punishments", Value: "punishments"},
+						},
+					},
+				},
+			},
+			Category:   "Moderation",
+			StaffLevel: StaffLevelTrialMod,
+			Handler:    c.handleCheck,
+		},
+		{
+			Definition: &discordgo.ApplicationCommand{
+				Name:        "setstafflevel",
+				Description: "Set the staff level of a user",
+				Options: []*discordgo.ApplicationCommandOption{
+					usernameOption("Username of the user", true),
+					{
+						Type:        discordgo.ApplicationCommandOptionInteger,
+						Name:        "level",
+						Description: "New staff level",
+						Required:    true,
+						Choices: []*discordgo.ApplicationCommandOptionChoice{
+							{Name: staffLevelNames[StaffLevelUser], Value: StaffLevelUser},
+							{Name: staffLevelNames[StaffLevelTrialMod], Value: StaffLevelTrialMod},
+							{Name: staffLevelNames[StaffLevelModerator], Value: StaffLevelModerator},
+							{Name: staffLevelNames[StaffLevelHeadMod], Value: StaffLevelHeadMod},
+							{Name: staffLevelNames[StaffLevelAdmin], Value: StaffLevelAdmin},
+						},
+					},
+				},
+			},
+			Category:   "Staff Management",
+			StaffLevel: StaffLevelAdmin,
+			Handler:    c.handleSetStaffLevel,
+		},
+		{
+			Definition: &discordgo.ApplicationCommand{
+				Name:        "addbadge",
+				Description: "Give a badge to a user",
+				Options: []*discordgo.ApplicationCommandOption{
+					usernameOption("Username of the user", true),
+					badgeOption("Badge to give"),
+					{
+						Type:        discordgo.ApplicationCommandOptionInteger,
+						Name:        "days",
+						Description: "Remove the badge again after this many days",
+						MinValue:    floatPtr(1),
+					},
+				},
+			},
+			Category:   "Badge Management",
+			StaffLevel: StaffLevelAdmin,
+			Handler:    c.handleAddBadge,
+		},
+		{
+			Definition: &discordgo.ApplicationCommand{
+				Name:        "removebadge",
+				Description: "Remove a badge from a user",
+				Options: []*discordgo.ApplicationCommandOption{
+					usernameOption("Username of the user", true),
+					badgeOption("Badge to remove"),
+				},
+			},
+			Category:   "Badge Management",
+			StaffLevel: StaffLevelAdmin,
+			Handler:    c.handleRemoveBadge,
+		},
+		{
+			Definition: &discordgo.ApplicationCommand{
+				Name:        "createbadge",
+				Description: "Create a new badge",
+				Options: []*discordgo.ApplicationCommandOption{
+					{
+						Type:        discordgo.ApplicationCommandOptionString,
+						Name:        "name",
+						Description: "Name of the badge",
+						Required:    true,
+					},
+					{
+						Type:        discordgo.ApplicationCommandOptionString,
+						Name:        "url",
+						Description: "Media URL of the badge",
+						Required:    true,
+					},
+					{
+						Type:        discordgo.ApplicationCommandOptionBoolean,
+						Name:        "custom",
+						Description: "Whether the badge is a custom badge",
+					},
+					{
+						Type:        discordgo.ApplicationCommandOptionRole,
+						Name:        "role",
+						Description: "Discord role synced with the badge",
+					},
+				},
+			},
+			Category:   "Badge Management",
+			StaffLevel: StaffLevelAdmin,
+			Handler:    c.handleCreateBadge,
+		},
+		{
+			Definition: &discordgo.ApplicationCommand{
+				Name:        "badgerole",
+				Description: "Manage the Discord roles synced with a badge",
+				Options: []*discordgo.ApplicationCommandOption{
+					{
+						Type:        discordgo.ApplicationCommandOptionSubCommand,
+						Name:        "add",
+						Description: "Grant a role to everyone holding the badge",
+						Options: []*discordgo.ApplicationCommandOption{
+							badgeOption("Badge to map"),
+							{
+								Type:        discordgo.ApplicationCommandOptionRole,
+								Name:        "role",
+								Description: "Discord role",
+								Required:    true,
+							},
+						},
+					},
+					{
+						Type:        discordgo.ApplicationCommandOptionSubCommand,
+						Name:        "remove",
+						Description: "Stop granting a role for the badge",
+						Options: []*discordgo.ApplicationCommandOption{
+							badgeOption("Badge to unmap"),
+							{
+								Type:        discordgo.ApplicationCommandOptionRole,
+								Name:        "role",
+								Description: "Discord role",
+								Required:    true,
+							},
+						},
+					},
+					{
+						Type:        discordgo.ApplicationCommandOptionSubCommand,
+						Name:        "list",
+						Description: "List the roles granted by a badge",
+						Options: []*discordgo.ApplicationCommandOption{
+							badgeOption("Badge to inspect"),
+						},
+					},
+				},
+			},
+			Category:   "Badge Management",
+			StaffLevel: StaffLevelAdmin,
+			Handler:    c.handleBadgeRole,
+		},
+		{
+			Definition: &discordgo.ApplicationCommand{
+				Name:        "createstatus",
+				Description: "Schedule a maintenance status",
+				Options: []*discordgo.ApplicationCommandOption{
+					{
+						Type:        discordgo.ApplicationCommandOptionString,
+						Name:        "start",
+						Description: "Start date (YYYY-MM-DD HH:MM)",
+						Required:    true,
+					},
+					{
+						Type:        discordgo.ApplicationCommandOptionString,
+						Name:        "end",
+						Description: "End date (YYYY-MM-DD HH:MM)",
+						Required:    true,
+					},
+					{
+						Type:        discordgo.ApplicationCommandOptionString,
+						Name:        "reason",
+						Description: "Reason for the maintenance",
+						Required:    true,
+					},
+				},
+			},
+			Category:   "System Management",
+			StaffLevel: StaffLevelAdmin,
+			Handler:    c.handleCreateStatus,
+		},
+		{
+			Definition: &discordgo.ApplicationCommand{
+				Name:        "deletestatus",
+				Description: "Delete a status",
+				Options: []*discordgo.ApplicationCommandOption{
+					{
+						Type:        discordgo.ApplicationCommandOptionInteger,
+						Name:        "id",
+						Description: "ID of the status",
+						Required:    true,
+						MinValue:    floatPtr(1),
+					},
+				},
+			},
+			Category:   "System Management",
+			StaffLevel: StaffLevelAdmin,
+			Handler:    c.handleDeleteStatus,
+		},
+		{
+			Definition: &discordgo.ApplicationCommand{
+				Name:        "createproductcode",
+				Description: "Create a redeem code for a product",
+				Options: []*discordgo.ApplicationCommandOption{
+					{
+						Type:        discordgo.ApplicationCommandOptionString,
+						Name:        "product",
+						Description: "Product the code redeems",
+						Required:    true,
+						Choices: []*discordgo.ApplicationCommandOptionChoice{
+							{Name: "Premium Upgrade", Value: "premium"},
+							{Name: "Custom Badge", Value: "custombadge"},
+							{Name: "Custom Badge Fee", Value: "badgecredits"},
+						},
+					},
+				},
+			},
+			Category:   "System Management",
+			StaffLevel: StaffLevelAdmin,
+			Handler:    c.handleCreateProductCode,
+		},
+		{
+			Definition: &discordgo.ApplicationCommand{
+				Name:        "triggerevent",
+				Description: "Trigger a system event for testing",
+				Options: []*discordgo.ApplicationCommandOption{
+					{
+						Type:        discordgo.ApplicationCommandOptionSubCommand,
+						Name:        "altaccount",
+						Description: "Trigger an alt account detection",
+						Options: []*discordgo.ApplicationCommandOption{
+							{
+								Type:         discordgo.ApplicationCommandOptionString,
+								Name:         "new_username",
+								Description:  "Username of the new account",
+								Required:     true,
+								Autocomplete: true,
+							},
+							{
+								Type:         discordgo.ApplicationCommandOptionString,
+								Name:         "existing_username",
+								Description:  "Username of the existing account",
+								Required:     true,
+								Autocomplete: true,
+							},
+						},
+					},
+				},
+			},
+			Category:   "System Management",
+			StaffLevel: StaffLevelAdmin,
+			Handler:    c.handleTriggerEvent,
+		},
+		{
+			Definition: &discordgo.ApplicationCommand{
+				Name:        "deleteuser",
+				Description: "Permanently delete a user",
+				Options: []*discordgo.ApplicationCommandOption{
+					usernameOption("Username or UID of the user", true),
+				},
+			},
+			Category:   "System Management",
+			StaffLevel: StaffLevelAdmin,
+			Handler:    c.handleDeleteUser,
+		},
+		{
+			Definition: &discordgo.ApplicationCommand{
+				Name:        "invite",
+				Description: "Create an invite code",
+				Options: []*discordgo.ApplicationCommandOption{
+					{
+						Type:        discordgo.ApplicationCommandOptionInteger,
+						Name:        "uses",
+						Description: "Number of times the code can be used",
+						Required:    true,
+						MinValue:    floatPtr(1),
+					},
+					{
+						Type:        discordgo.ApplicationCommandOptionInteger,
+						Name:        "expires_hours",
+						Description: "Hours until the code expires (0 for never)",
+						MinValue:    floatPtr(0),
+					},
+				},
+			},
+			Category:   "Invite Code Management",
+			StaffLevel: StaffLevelHeadMod,
+			Handler:    c.handleInvite,
+		},
+		{
+			Definition: &discordgo.ApplicationCommand{
+				Name:        "invites",
+				Description: "List the invite codes you created",
+			},
+			Category:   "Invite Code Management",
+			StaffLevel: StaffLevelAdmin,
+			Handler:    c.handleInvites,
+		},
+		{
+			Definition: &discordgo.ApplicationCommand{
+				Name:        "deleteinvite",
+				Description: "Delete one of your invite codes",
+				Options: []*discordgo.ApplicationCommandOption{
+					{
+						Type:        discordgo.ApplicationCommandOptionString,
+						Name:        "code",
+						Description: "Invite code to delete",
+						Required:    true,
+					},
+				},
+			},
+			Category:   "Invite Code Management",
+			StaffLevel: StaffLevelAdmin,
+			Handler:    c.handleDeleteInvite,
+		},
+		{
+			Definition: &discordgo.ApplicationCommand{
+				Name:        "positions",
+				Description: "List application positions",
+			},
+			Category:   "Application Positions",
+			StaffLevel: StaffLevelAdmin,
+			Handler:    c.handlePositions,
+		},
+		{
+			Definition: &discordgo.ApplicationCommand{
+				Name:        "openposition",
+				Description: "Open a position for applications",
+				Options: []*discordgo.ApplicationCommandOption{
+					positionOption("Position to open"),
+				},
+			},
+			Category:   "Application Positions",
+			StaffLevel: StaffLevelAdmin,
+			Handler: func(ctx *CommandContext) {
+				c.handleSetPositionActive(ctx, true)
+			},
+		},
+		{
+			Definition: &discordgo.ApplicationCommand{
+				Name:        "closeposition",
+				Description: "Close a position for applications",
+				Options: []*discordgo.ApplicationCommandOption{
+					positionOption("Position to close"),
+				},
+			},
+			Category:   "Application Positions",
+			StaffLevel: StaffLevelAdmin,
+			Handler: func(ctx *CommandContext) {
+				c.handleSetPositionActive(ctx, false)
+			},
+		},
 	}
+}
 
-	productType := strings.ToLower(args[1])
+/* Resolve a user from a username or UID */
+func (c *Commands) findUser(usernameOrUID string) (*models.User, error) {
+	if uid, err := strconv.ParseUint(usernameOrUID, 10, 32); err == nil {
+		return c.services.User.GetUserByUID(uint(uid))
+	}
+	return c.services.User.GetUserByUsername(usernameOrUID)
+}
+
+func (c *Commands) handleCreateProductCode(ctx *CommandContext) {
 	var productData models.StripeProduct
 
-	switch productType {
+	switch ctx.String("product") {
 	case "premium":
 		productData = models.StripeProduct{
 			ProductName: "Premium Upgrade",
@@ -175,13 +670,13 @@ func (c *Commands) handleCreateProductCode(s *discordgo.Session, m *discordgo.Me
 			availableProducts = append(availableProducts, fmt.Sprintf("• %s", strings.ToLower(strings.ReplaceAll(productName, " ", ""))))
 		}
 
-		c.sendInvalidInputEmbed(s, m, fmt.Sprintf("Invalid product type. Available products:\n%s", strings.Join(availableProducts, "\n")))
+		ctx.InvalidInput(fmt.Sprintf("Invalid product type. Available products:\n%s", strings.Join(availableProducts, "\n")))
 		return
 	}
 
 	redeemCode, err := c.services.Redeem.CreateRedeemCode(productData)
 	if err != nil {
-		c.sendErrorEmbed(s, m, fmt.Sprintf("Failed to create redeem code: %v", err))
+		ctx.Error(fmt.Sprintf("Failed to create redeem code: %v", err))
 		return
 	}
 
@@ -193,7 +688,7 @@ func (c *Commands) handleCreateProductCode(s *discordgo.Session, m *discordgo.Me
 			{Name: "Product", Value: productData.ProductName, Inline: true},
 			{Name: "Redeem Code", Value: fmt.Sprintf("`%s`", redeemCode), Inline: true},
 			{Name: "Price ID", Value: productData.PriceID, Inline: true},
-			{Name: "Created By", Value: adminUser.Username, Inline: true},
+			{Name: "Created By", Value: ctx.User.Username, Inline: true},
 		},
 		Footer: &discordgo.MessageEmbedFooter{
 			Text: "cutz.lol redeem system",
@@ -219,54 +714,28 @@ func (c *Commands) handleCreateProductCode(s *discordgo.Session, m *discordgo.Me
 		})
 	}
 
-	s.ChannelMessageSendEmbed(m.ChannelID, embed)
+	// Only the creator should see the code, anyone reading the channel could redeem it
+	ctx.RespondEphemeral(embed)
 
 	log.Printf("Redeem code created by %s (%d): %s for product %s",
-		adminUser.Username, adminUser.UID, redeemCode, productData.ProductName)
+		ctx.User.Username, ctx.User.UID, redeemCode, productData.ProductName)
 }
 
-func (c *Commands) handleTriggerEvent(s *discordgo.Session, m *discordgo.MessageCreate, args []string) {
-	adminUser, err := c.services.Discord.GetUserByDiscordID(m.Author.ID)
-	if err != nil {
-		c.sendUnauthorizedEmbed(s, m, "You are not authorized to trigger events")
-		return
-	}
-
-	if adminUser.StaffLevel < StaffLevelAdmin && m.Author.Username != "mouviel" {
-		c.sendUnauthorizedEmbed(s, m, "You need administrator permissions to trigger events")
-		return
-	}
-
-	if len(args) < 2 {
-		c.sendInvalidUsageEmbed(s, m, "Please specify an event type",
-			"`?triggerevent <event_type> [params...]`",
-			"`?triggerevent altaccount username1 username2`\n`?triggerevent registration username`")
-		return
-	}
-
-	eventType := strings.ToLower(args[1])
-
-	switch eventType {
+func (c *Commands) handleTriggerEvent(ctx *CommandContext) {
+	switch ctx.Subcommand {
 	case "altaccount":
-		if len(args) < 4 {
-			c.sendInvalidUsageEmbed(s, m, "Please provide two usernames for alt account event",
-				"`?triggerevent altaccount <new_username> <existing_username>`",
-				"`?triggerevent altaccount newuser existinguser`")
-			return
-		}
-
-		newUsername := args[2]
-		existingUsername := args[3]
+		newUsername := ctx.String("new_username")
+		existingUsername := ctx.String("existing_username")
 
 		newUser, err := c.services.User.GetUserByUsername(newUsername)
 		if err != nil {
-			c.sendErrorEmbed(s, m, fmt.Sprintf("New user not found: %s", newUsername))
+			ctx.Error(fmt.Sprintf("New user not found: %s", newUsername))
 			return
 		}
 
 		existingUser, err := c.services.User.GetUserByUsername(existingUsername)
 		if err != nil {
-			c.sendErrorEmbed(s, m, fmt.Sprintf("Existing user not found: %s", existingUsername))
+			ctx.Error(fmt.Sprintf("Existing user not found: %s", existingUsername))
 			return
 		}
 
@@ -274,7 +743,7 @@ func (c *Commands) handleTriggerEvent(s *discordgo.Session, m *discordgo.Message
 
 		err = c.services.AltAccount.NotifyAltAccount(newUser, potentialAlts, "127.0.0.1", false)
 		if err != nil {
-			c.sendErrorEmbed(s, m, fmt.Sprintf("Failed to trigger alt account event: %v", err))
+			ctx.Error(fmt.Sprintf("Failed to trigger alt account event: %v", err))
 			return
 		}
 
@@ -292,73 +761,84 @@ func (c *Commands) handleTriggerEvent(s *discordgo.Session, m *discordgo.Message
 			},
 			Timestamp: time.Now().Format(time.RFC3339),
 		}
-		s.ChannelMessageSendEmbed(m.ChannelID, embed)
+		ctx.Respond(embed)
 
 	default:
-		c.sendInvalidUsageEmbed(s, m, "Unknown event type",
-			"`?triggerevent <event_type> [params...]`",
-			"`?triggerevent altaccount username1 username2`")
+		ctx.InvalidInput("Unknown event type")
+	}
+}
+
+/* Describe the options of a command for the help embed */
+func commandUsage(command *discordgo.ApplicationCommand) string {
+	usage := "/" + command.Name
+	for _, option := range command.Options {
+		if option.Type == discordgo.ApplicationCommandOptionSubCommand {
+			usage += " " + option.Name
+			for _, subOption := range option.Options {
+				usage += fmt.Sprintf(" [%s]", subOption.Name)
+			}
+			continue
+		}
+		usage += fmt.Sprintf(" [%s]", option.Name)
 	}
+	return usage
 }
 
-func (c *Commands) handleHelp(s *discordgo.Session, m *discordgo.MessageCreate) {
+func (c *Commands) handleHelp(ctx *CommandContext) {
+	var categories []string
+	usages := map[string][]string{}
+
+	for _, command := range c.commandList {
+		if !ctx.HasStaffLevel(command.StaffLevel) {
+			continue
+		}
+		if _, ok := usages[command.Category]; !ok {
+			categories = append(categories, command.Category)
+		}
+		usages[command.Category] = append(usages[command.Category], fmt.Sprintf("``%s``", commandUsage(command.Definition)))
+	}
+
+	fields := make([]*discordgo.MessageEmbedField, 0, len(categories))
+	for _, category := range categories {
+		fields = append(fields, &discordgo.MessageEmbedField{
+			Name:  category,
+			Value: strings.Join(usages[category], "\n"),
+		})
+	}
+
 	embed := &discordgo.MessageEmbed{
 		Title:       "cutz.lol commands",
-		Description: fmt.Sprintf("prefix: ``%s``", config.DiscordPrefix),
+		Description: "Type ``/`` to run a command",
 		Color:       0x000000,
 		Thumbnail: &discordgo.MessageEmbedThumbnail{
-			URL: m.Author.AvatarURL("512"),
-		},
-		Fields: []*discordgo.MessageEmbedField{
-			{
-				Name:  "User Commands",
-				Value: "``profile [username]``\n``uidprofile [user_id]``\n``me``\n``leaderboard``\n``stats``\n``status``\n``generate``\n``timediff``\n``claim``\n``help``",
-			},
+			URL: ctx.Author.AvatarURL("512"),
 		},
+		Fields: fields,
 		Footer: &discordgo.MessageEmbedFooter{
 			Text: "cutz.lol",
 		},
 		Timestamp: time.Now().Format(time.RFC3339),
 	}
-	s.ChannelMessageSendEmbed(m.ChannelID, embed)
+	ctx.RespondEphemeral(embed)
 }
 
-func (c *Commands) handleSetStaffLevel(s *discordgo.Session, m *discordgo.MessageCreate, args []string) {
-	adminUser, err := c.services.Discord.GetUserByDiscordID(m.Author.ID)
-	if err != nil {
-		c.sendUnauthorizedEmbed(s, m, "You are not authorized to set staff levels")
-		return
-	}
+func (c *Commands) handleSetStaffLevel(ctx *CommandContext) {
+	adminUser := ctx.User
 
-	if adminUser.StaffLevel < StaffLevelAdmin && m.Author.Username != "mouviel" {
-		c.sendUnauthorizedEmbed(s, m, "You need administrator permissions to set staff levels")
+	level, _ := ctx.Int("level")
+	if level < int64(StaffLevelUser) || level > int64(StaffLevelAdmin) {
+		ctx.InvalidInput("Staff level must be a number between 0-4:\n0 = User\n1 = Trial Mod\n2 = Moderator\n3 = Head Mod\n4 = Admin")
 		return
 	}
 
-	if len(args) < 3 {
-		c.sendInvalidUsageEmbed(s, m, "Please provide a username and staff level",
-			"`?setstafflevel <username> <level>`",
-			"`?setstafflevel example 2`")
-		return
-	}
-
-	username := args[1]
-	levelStr := args[2]
-
-	level, err := strconv.ParseUint(levelStr, 10, 32)
-	if err != nil || level > 4 {
-		c.sendInvalidInputEmbed(s, m, "Staff level must be a number between 0-4:\n0 = User\n1 = Trial Mod\n2 = Moderator\n3 = Head Mod\n4 = Admin")
-		return
-	}
-
-	targetUser, err := c.services.User.GetUserByUsername(username)
+	targetUser, err := c.services.User.GetUserByUsername(ctx.String("username"))
 	if err != nil {
-		c.sendErrorEmbed(s, m, "User not found")
+		ctx.Error("User not found")
 		return
 	}
 
-	if uint(level) > adminUser.StaffLevel && m.Author.Username != "mouviel" {
-		c.sendUnauthorizedEmbed(s, m, "You cannot promote a user to a level higher than your own")
+	if uint(level) > adminUser.StaffLevel {
+		ctx.Unauthorized("You cannot promote a user to a level higher than your own")
 		return
 	}
 
@@ -368,7 +848,7 @@ func (c *Commands) handleSetStaffLevel(s *discordgo.Session, m *discordgo.Messag
 
 	err = c.services.User.UpdateUser(targetUser.UID, fieldsToUpdate)
 	if err != nil {
-		c.sendErrorEmbed(s, m, fmt.Sprintf("Failed to update staff level: %v", err))
+		ctx.Error(fmt.Sprintf("Failed to update staff level: %v", err))
 		return
 	}
 
@@ -386,10 +866,10 @@ func (c *Commands) handleSetStaffLevel(s *discordgo.Session, m *discordgo.Messag
 		},
 		Timestamp: time.Now().Format(time.RFC3339),
 	}
-	s.ChannelMessageSendEmbed(m.ChannelID, embed)
+	ctx.Respond(embed)
 
 	if targetUser.DiscordID != "" {
-		dmChannel, err := s.UserChannelCreate(targetUser.DiscordID)
+		dmChannel, err := ctx.Session.UserChannelCreate(targetUser.DiscordID)
 		if err == nil {
 			notificationEmbed := &discordgo.MessageEmbed{
 				Title: "Your Staff Level Has Been Updated",
@@ -404,34 +884,31 @@ func (c *Commands) handleSetStaffLevel(s *discordgo.Session, m *discordgo.Messag
 					Text: "cutz.lol staff management",
 				},
 			}
-			s.ChannelMessageSendEmbed(dmChannel.ID, notificationEmbed)
+			ctx.Session.ChannelMessageSendEmbed(dmChannel.ID, notificationEmbed)
 		}
 	}
 }
 
-func (c *Commands) handleStaffInfo(s *discordgo.Session, m *discordgo.MessageCreate, args []string) {
-	requestingUser, err := c.services.Discord.GetUserByDiscordID(m.Author.ID)
-	if err != nil {
-		c.sendUnauthorizedEmbed(s, m, "You need to link your Discord account to use this command")
+func (c *Commands) handleStaffInfo(ctx *CommandContext) {
+	if ctx.User == nil {
+		ctx.Unauthorized("You need to link your Discord account to use this command")
 		return
 	}
 
-	var targetUser *models.User
+	targetUser := ctx.User
 
-	if len(args) < 2 {
-		targetUser = requestingUser
-	} else {
-		if requestingUser.StaffLevel < StaffLevelModerator && m.Author.Username != "mouviel" {
-			c.sendUnauthorizedEmbed(s, m, "You need to be a Moderator or higher to check other users' staff information")
+	if username := ctx.String("username"); username != "" && !strings.EqualFold(username, ctx.User.Username) {
+		if !ctx.HasStaffLevel(StaffLevelModerator) {
+			ctx.Unauthorized("You need to be a Moderator or higher to check other users' staff information")
 			return
 		}
 
-		username := args[1]
-		targetUser, err = c.services.User.GetUserByUsername(username)
+		user, err := c.services.User.GetUserByUsername(username)
 		if err != nil {
-			c.sendErrorEmbed(s, m, "User not found")
+			ctx.Error("User not found")
 			return
 		}
+		targetUser = user
 	}
 
 	staffLevelName, exists := staffLevelNames[targetUser.StaffLevel]
@@ -444,7 +921,7 @@ func (c *Commands) handleStaffInfo(s *discordgo.Session, m *discordgo.MessageCre
 		Description: fmt.Sprintf("Staff details for %s", targetUser.Username),
 		Color:       getColorForStaffLevel(targetUser.StaffLevel),
 		Thumbnail: &discordgo.MessageEmbedThumbnail{
-			URL: m.Author.AvatarURL("512"),
+			URL: ctx.Author.AvatarURL("512"),
 		},
 		Fields: []*discordgo.MessageEmbedField{
 			{Name: "Username", Value: targetUser.Username, Inline: true},
@@ -492,7 +969,7 @@ func (c *Commands) handleStaffInfo(s *discordgo.Session, m *discordgo.MessageCre
 		Value: permissionsText,
 	})
 
-	s.ChannelMessageSendEmbed(m.ChannelID, embed)
+	ctx.Respond(embed)
 }
 
 func getColorForStaffLevel(level uint) int {
@@ -510,131 +987,70 @@ func getColorForStaffLevel(level uint) int {
 	}
 }
 
-func (c *Commands) handleAdminHelp(s *discordgo.Session, m *discordgo.MessageCreate) {
-	_, err := c.services.Discord.GetUserByDiscordID(m.Author.ID)
-	if err != nil {
-		c.sendUnauthorizedEmbed(s, m, "You are not authorized to view admin commands")
-		return
-	}
-
-	if m.Author.Username != "mouviel" {
-		c.sendUnauthorizedEmbed(s, m, "You are not authorized to view admin commands")
-		return
-	}
-
-	embed := &discordgo.MessageEmbed{
-		Title:       "cutz.lol admin commands",
-		Description: "Admin commands require special permissions",
-		Color:       0x000000,
-		Thumbnail: &discordgo.MessageEmbedThumbnail{
-			URL: m.Author.AvatarURL("512"),
-		},
-		Fields: []*discordgo.MessageEmbedField{
-			{
-				Name: "Badge Management",
-				Value: "``addbadge [username] [badge]``\n" +
-					"``removebadge [username] [badge]``\n" +
-					"``createbadge [url] [type] [isCustom] [name]``",
-			},
-			{
-				Name: "Staff Management",
-				Value: "``setstafflevel [username] [level]``\n" +
-					"``staffinfo [username]``",
-			},
-			{
-				Name: "System Management",
-				Value: "``createstatus [start] [end] [reason]``\n" +
-					"``deletestatus [id]``\n" +
-					"``createproductcode [type]``\n" +
-					"``deleteuser [username_or_uid]``",
-			},
-			{
-				Name: "Invite Code Management",
-				Value: "``invite [uses] [expires_hours]``\n" +
-					"``invites``\n" +
-					"``deleteinvite [code]``",
-			},
-
-		},
-		Footer: &discordgo.MessageEmbedFooter{
-			Text: "cutz.lol admin system",
-		},
-		Timestamp: time.Now().Format(time.RFC3339),
-	}
-	s.ChannelMessageSendEmbed(m.ChannelID, embed)
-}
+func (c *Commands) handleAddBadge(ctx *CommandContext) {
+	badgeName := ctx.String("badge")
 
-func (c *Commands) handleAddBadge(s *discordgo.Session, m *discordgo.MessageCreate, args []string) {
-	_, err := c.services.Discord.GetUserByDiscordID(m.Author.ID)
+	targetUser, err := c.services.User.GetUserByUsername(ctx.String("username"))
 	if err != nil {
-		c.sendUnauthorizedEmbed(s, m, "You are not authorized to add badges")
+		ctx.Error("User not found")
 		return
 	}
 
-	if m.Author.Username != "mouviel" {
-		c.sendUnauthorizedEmbed(s, m, "You are not authorized to add badges")
-		return
+	var expiresAt *time.Time
+	if days, ok := ctx.Int("days"); ok {
+		expiry := time.Now().AddDate(0, 0, int(days))
+		expiresAt = &expiry
 	}
 
-	if len(args) < 3 {
-		c.sendInvalidUsageEmbed(s, m, "Please provide all required parameters", "`?addbadge <uid> <badge_name>`", "`?addbadge 1 \"Early User\"`")
+	err = c.services.Badge.GrantBadge(targetUser.UID, badgeName, expiresAt, nil)
+	if err != nil {
+		ctx.Error(fmt.Sprintf("Failed to add badge: %v", err))
 		return
 	}
 
-	uid := args[1]
-	badgeName := strings.Join(args[2:], " ")
-
-	err = c.services.Badge.AssignBadge(utils.StringToUint(uid), badgeName)
-	if err != nil {
-		c.sendErrorEmbed(s, m, fmt.Sprintf("Failed to add badge: %v", err))
-		return
+	expires := "Never"
+	if expiresAt != nil {
+		expires = fmt.Sprintf("<t:%d:R>", expiresAt.Unix())
 	}
 
 	embed := &discordgo.MessageEmbed{
 		Title:       "Badge Added",
-		Description: fmt.Sprintf("Successfully added badge to user %s", uid),
+		Description: fmt.Sprintf("Successfully added badge to %s", targetUser.Username),
 		Color:       0x000000,
 		Fields: []*discordgo.MessageEmbedField{
-			{Name: "UID", Value: uid, Inline: true},
+			{Name: "UID", Value: fmt.Sprintf("%d", targetUser.UID), Inline: true},
 			{Name: "Badge", Value: badgeName, Inline: true},
+			{Name: "Expires", Value: expires, Inline: true},
+		},
+		Footer: &discordgo.MessageEmbedFooter{
+			Text: "cutz.lol badge system",
 		},
 		Timestamp: time.Now().Format(time.RFC3339),
 	}
-	s.ChannelMessageSendEmbed(m.ChannelID, embed)
+	ctx.Respond(embed)
 }
 
-func (c *Commands) handleRemoveBadge(s *discordgo.Session, m *discordgo.MessageCreate, args []string) {
-	_, err := c.services.Discord.GetUserByDiscordID(m.Author.ID)
-	if err != nil {
-		c.sendUnauthorizedEmbed(s, m, "You are not authorized to remove badges")
-		return
-	}
-
-	if m.Author.Username != "mouviel" {
-		c.sendUnauthorizedEmbed(s, m, "You are not authorized to remove badges")
-		return
-	}
+func (c *Commands) handleRemoveBadge(ctx *CommandContext) {
+	badgeName := ctx.String("badge")
 
-	if len(args) < 3 {
-		c.sendInvalidUsageEmbed(s, m, "Please provide all required parameters", "`?removebadge <uid> <badge_name>`", "`?removebadge 1 \"Early User\"`")
+	targetUser, err := c.services.User.GetUserByUsername(ctx.String("username"))
+	if err != nil {
+		ctx.Error("User not found")
 		return
 	}
 
-	uid := args[1]
-	badgeName := strings.Join(args[2:], " ")
-
-	err = c.services.Badge.RemoveBadge(utils.StringToUint(uid), badgeName)
+	err = c.services.Badge.RemoveBadge(targetUser.UID, badgeName)
 	if err != nil {
-		c.sendErrorEmbed(s, m, fmt.Sprintf("Failed to remove badge: %v", err))
+		ctx.Error(fmt.Sprintf("Failed to remove badge: %v", err))
 		return
 	}
 
 	embed := &discordgo.MessageEmbed{
 		Title:       "Badge Removed",
-		Description: "Successfully removed badge from user",
+		Description: fmt.Sprintf("Successfully removed badge from %s", targetUser.Username),
 		Color:       0x000000,
 		Fields: []*discordgo.MessageEmbedField{
-			{Name: "UID", Value: uid, Inline: true},
+			{Name: "UID", Value: fmt.Sprintf("%d", targetUser.UID), Inline: true},
 			{Name: "Badge", Value: badgeName, Inline: true},
 		},
 		Footer: &discordgo.MessageEmbedFooter{
@@ -642,55 +1058,33 @@ func (c *Commands) handleRemoveBadge(s *discordgo.Session, m *discordgo.MessageC
 		},
 		Timestamp: time.Now().Format(time.RFC3339),
 	}
-	s.ChannelMessageSendEmbed(m.ChannelID, embed)
+	ctx.Respond(embed)
 }
 
-func (c *Commands) handleCreateBadge(s *discordgo.Session, m *discordgo.MessageCreate, args []string) {
-	_, err := c.services.Discord.GetUserByDiscordID(m.Author.ID)
-	if err != nil {
-		c.sendUnauthorizedEmbed(s, m, "You are not authorized to create badges")
-		return
-	}
-
-	if m.Author.Username != "mouviel" {
-		c.sendUnauthorizedEmbed(s, m, "You are not authorized to create badges")
-		return
-	}
-
-	if len(args) < 5 {
-		c.sendInvalidUsageEmbed(s, m, "Please provide all required parameters", "`?createbadge <url> <isCustom> <name> <role_id>`", "`?createbadge https://example.com/badge.svg false \"Early User\" 123456789`")
-		return
-	}
-
-	url := args[1]
-	isCustom, err := strconv.ParseBool(args[2])
-	name := strings.Join(args[3:len(args)-1], " ")
-	roleID := args[len(args)-1]
-
-	if err != nil {
-		c.sendInvalidInputEmbed(s, m, "isCustom must be 'true' or 'false'")
-		return
-	}
-
-	name = strings.TrimPrefix(name, "\"")
-	name = strings.TrimSuffix(name, "\"")
+func (c *Commands) handleCreateBadge(ctx *CommandContext) {
+	isCustom, _ := ctx.Bool("custom")
 
 	badge := &models.Badge{
-		Name:     name,
-		MediaURL: url,
+		Name:     ctx.String("name"),
+		MediaURL: ctx.String("url"),
 		IsCustom: isCustom,
 	}
 
-	if roleID != "0" {
-		badge.DiscordRoleID = roleID
-	}
-
-	err = c.services.Badge.CreateBadge(badge)
+	err := c.services.Badge.CreateBadge(badge)
 	if err != nil {
-		c.sendErrorEmbed(s, m, fmt.Sprintf("Failed to create badge: %v", err))
+		ctx.Error(fmt.Sprintf("Failed to create badge: %v", err))
 		return
 	}
 
+	roleValue := "Not set"
+	if role := ctx.Role("role"); role != nil {
+		if _, err := c.services.Badge.AddBadgeRole(badge.Name, ctx.Interaction.GuildID, role.ID); err != nil {
+			ctx.Error(fmt.Sprintf("Badge created but the role could not be mapped: %v", err))
+			return
+		}
+		roleValue = fmt.Sprintf("<@&%s>", role.ID)
+	}
+
 	embed := &discordgo.MessageEmbed{
 		Title:       "Badge Created",
 		Description: "New badge has been created successfully",
@@ -706,17 +1100,10 @@ func (c *Commands) handleCreateBadge(s *discordgo.Session, m *discordgo.MessageC
 		Timestamp: time.Now().Format(time.RFC3339),
 	}
 
-	if badge.DiscordRoleID != "" {
-		embed.Fields = append(embed.Fields, &discordgo.MessageEmbedField{
-			Name:  "Discord Role ID",
-			Value: badge.DiscordRoleID,
-		})
-	} else {
-		embed.Fields = append(embed.Fields, &discordgo.MessageEmbedField{
-			Name:  "Discord Role ID",
-			Value: "Not set",
-		})
-	}
+	embed.Fields = append(embed.Fields, &discordgo.MessageEmbedField{
+		Name:  "Discord Role",
+		Value: roleValue,
+	})
 
 	if badge.IsCustom {
 		embed.Thumbnail = &discordgo.MessageEmbedThumbnail{
@@ -724,91 +1111,64 @@ func (c *Commands) handleCreateBadge(s *discordgo.Session, m *discordgo.MessageC
 		}
 	}
 
-	s.ChannelMessageSendEmbed(m.ChannelID, embed)
+	ctx.Respond(embed)
 }
 
-func (c *Commands) handleCheck(s *discordgo.Session, m *discordgo.MessageCreate, args []string) {
+func (c *Commands) handleCheck(ctx *CommandContext) {
 	var user *models.User
 	var err error
 
-	staff, err := c.services.Discord.GetUserByDiscordID(m.Author.ID)
-	if err != nil {
-		c.sendUnauthorizedEmbed(s, m, "You are not authorized to check users")
-		return
-	}
-
-	badges, err := c.services.Badge.GetUserBadges(staff.UID)
-	if err != nil {
-		c.sendUnauthorizedEmbed(s, m, "You are not authorized to check users")
-		return
-	}
-
-	if !hasBadge(badges, "Staff") {
-		c.sendUnauthorizedEmbed(s, m, "You are not authorized to check users")
-		return
-	}
-
-	if len(args) < 2 {
-		c.sendInvalidUsageEmbed(s, m, "Please provide a user identifier", "`?check username:value`, `?check discord:id`, `?check id:id`", "`?check username:john`, `?check discord:123456789`, `?check id:123`")
-		return
-	}
-
-	identifier := strings.SplitN(args[1], ":", 2)
-	if len(identifier) != 2 {
-		c.sendInvalidUsageEmbed(s, m, "Invalid identifier format", "`?check username:value`, `?check discord:id`, `check id:id`", "`?check username:john`, `?check discord:123456789`, `?check id:123`")
-		return
-	}
+	uid, hasUID := ctx.Int("uid")
 
-	identifierType := identifier[0]
-	identifierValue := identifier[1]
-
-	switch identifierType {
-	case "username":
-		user, err = c.services.User.GetUserByUsernamePublic(identifierValue)
-		if err != nil {
-			c.sendErrorEmbed(s, m, "User not found with that username")
-			return
-		}
-	case "discord":
-		user, err = c.services.Discord.GetUserByDiscordID(identifierValue)
+	switch {
+	case ctx.String("username") != "":
+		user, err = c.services.User.GetUserByUsernamePublic(ctx.String("username"))
 		if err != nil {
-			c.sendErrorEmbed(s, m, "User not found with that Discord ID")
+			ctx.Error("User not found with that username")
 			return
 		}
-	case "id":
-		userID, err := strconv.Atoi(identifierValue)
+	case ctx.DiscordUser("discord") != nil:
+		user, err = c.services.Discord.GetUserByDiscordID(ctx.DiscordUser("discord").ID)
 		if err != nil {
-			s.ChannelMessageSend(m.ChannelID, "Invalid user ID. Please enter a valid number.")
+			ctx.Error("User not found with that Discord ID")
 			return
 		}
-		user, err = c.services.User.GetUserByUID(uint(userID))
+	case hasUID:
+		user, err = c.services.User.GetUserByUID(uint(uid))
 		if err != nil {
-			c.sendErrorEmbed(s, m, "User not found with that ID")
+			ctx.Error("User not found with that ID")
 			return
 		}
 	default:
-		c.sendInvalidUsageEmbed(s, m, "Invalid identifier type", "`?check username:value`, `?check discord:id`, `?check id:id`", "`?check username:john`, `?check discord:123456789`, `?check id:123`")
+		ctx.InvalidInput("Please provide a username, Discord user or UID")
 		return
 	}
 
-	if len(args) > 2 && args[2] == "--discord" {
-		var onServer string
-		if user.DiscordID != "" {
-			_, err = s.GuildMember(config.DiscordGuildID, user.DiscordID)
-			if err == nil {
-				onServer = "Yes"
-			} else {
-				onServer = "No"
+	onServer := "Not Linked"
+	if user.DiscordID != "" {
+		guilds, _ := c.services.Guild.GetEnabledGuilds()
+
+		joined := []string{}
+		for _, guild := range guilds {
+			if _, err := ctx.Session.GuildMember(guild.GuildID, user.DiscordID); err == nil {
+				joined = append(joined, guild.Name)
 			}
-		} else {
-			onServer = "Not Linked"
 		}
 
+		onServer = "No"
+		if len(joined) > 0 {
+			onServer = "Yes (" + strings.Join(joined, ", ") + ")"
+		}
+	}
+
+	view := ctx.String("view")
+
+	if view == "discord" {
 		var discordInfo string
 		if user.DiscordID == "" {
 			discordInfo = "User is not linked to Discord"
 		} else {
-			discordMember, err := s.User(user.DiscordID)
+			discordMember, err := ctx.Session.User(user.DiscordID)
 			var discordUsername string
 			if err == nil {
 				discordUsername = discordMember.Username
@@ -831,28 +1191,16 @@ func (c *Commands) handleCheck(s *discordgo.Session, m *discordgo.MessageCreate,
 					Inline: false,
 				},
 			},
-					Footer: &discordgo.MessageEmbedFooter{
-			Text: "cutz.lol discord integration system",
-		},
+			Footer: &discordgo.MessageEmbedFooter{
+				Text: "cutz.lol discord integration system",
+			},
 			Timestamp: time.Now().Format(time.RFC3339),
 		}
 
-		s.ChannelMessageSendEmbed(m.ChannelID, embed)
+		ctx.Respond(embed)
 		return
 	}
 
-	var onServer string
-	if user.DiscordID != "" {
-		_, err = s.GuildMember(config.DiscordGuildID, user.DiscordID)
-		if err == nil {
-			onServer = "Yes"
-		} else {
-			onServer = "No"
-		}
-	} else {
-		onServer = "Not Linked"
-	}
-
 	var discordInfo string
 	if user.DiscordID == "" {
 		discordInfo = "User is not linked"
@@ -861,57 +1209,57 @@ func (c *Commands) handleCheck(s *discordgo.Session, m *discordgo.MessageCreate,
 			user.DiscordID, user.LinkedAt.Unix(), onServer)
 	}
 
-	embed := &discordgo.MessageEmbed{
-		Title:       "User Information",
-		Description: fmt.Sprintf("Information for user %s", user.Username),
-		Color:       0x000000,
-		Fields: []*discordgo.MessageEmbedField{
-			{
-				Name: "General Information",
-				Value: fmt.Sprintf("ID: `%d`\nUsername: `%s`\nAlias: `%s`",
-					user.UID, user.Username, func() string {
-						if user.Alias != nil {
-							return *user.Alias
-						}
-						return "Not set"
-					}()),
-				Inline: false,
-			},
-			{
-				Name: "Security",
-				Value: fmt.Sprintf("2FA Enabled: `%t`\nDiscord Login: `%t`",
-					user.MFAEnabled, user.LoginWithDiscord),
-				Inline: false,
-			},
-			{
-				Name:   "Timestamps",
-				Value:  fmt.Sprintf("Created At: <t:%d:R>", user.CreatedAt.Unix()),
-				Inline: false,
+	embeds := []*discordgo.MessageEmbed{
+		{
+			Title:       "User Information",
+			Description: fmt.Sprintf("Information for user %s", user.Username),
+			Color:       0x000000,
+			Fields: []*discordgo.MessageEmbedField{
+				{
+					Name: "General Information",
+					Value: fmt.Sprintf("ID: `%d`\nUsername: `%s`\nAlias: `%s`",
+						user.UID, user.Username, func() string {
+							if user.Alias != nil {
+								return *user.Alias
+							}
+							return "Not set"
+						}()),
+					Inline: false,
+				},
+				{
+					Name: "Security",
+					Value: fmt.Sprintf("2FA Enabled: `%t`\nDiscord Login: `%t`",
+						user.MFAEnabled, user.LoginWithDiscord),
+					Inline: false,
+				},
+				{
+					Name:   "Timestamps",
+					Value:  fmt.Sprintf("Created At: <t:%d:R>", user.CreatedAt.Unix()),
+					Inline: false,
+				},
+				{
+					Name:   "Discord Information",
+					Value:  discordInfo,
+					Inline: false,
+				},
 			},
-			{
-				Name:   "Discord Information",
-				Value:  discordInfo,
-				Inline: false,
+			Footer: &discordgo.MessageEmbedFooter{
+				Text: "cutz.lol user system",
 			},
+			Timestamp: time.Now().Format(time.RFC3339),
 		},
-		Footer: &discordgo.MessageEmbedFooter{
-			Text: "cutz.lol user system",
-		},
-		Timestamp: time.Now().Format(time.RFC3339),
 	}
 
-	s.ChannelMessageSendEmbed(m.ChannelID, embed)
-
-	if len(args) > 2 && args[2] == "--punishments" {
+	if view == "punishments" {
 		punishments, err := c.services.Punish.GetActivePunishmentsForUser(user.UID)
-		var punishmentEmbedColor int
-		var punishmentEmbedFields []*discordgo.MessageEmbedField
-
 		if err != nil {
-			c.sendErrorEmbed(s, m, "Could not fetch punishments")
+			ctx.Error("Could not fetch punishments")
 			return
 		}
 
+		var punishmentEmbedColor int
+		var punishmentEmbedFields []*discordgo.MessageEmbedField
+
 		if len(punishments) > 0 {
 			punishmentEmbedColor = 0xff0000
 			fields := []*discordgo.MessageEmbedField{}
@@ -940,24 +1288,25 @@ func (c *Commands) handleCheck(s *discordgo.Session, m *discordgo.MessageCreate,
 			}
 		}
 
-		punishmentEmbed := &discordgo.MessageEmbed{
+		embeds = append(embeds, &discordgo.MessageEmbed{
 			Title:       "Punishment Information",
 			Description: fmt.Sprintf("Punishment details for user %s", user.Username),
 			Color:       punishmentEmbedColor,
 			Fields:      punishmentEmbedFields,
-					Footer: &discordgo.MessageEmbedFooter{
-			Text: "cutz.lol punishment system",
-		},
+			Footer: &discordgo.MessageEmbedFooter{
+				Text: "cutz.lol punishment system",
+			},
 			Timestamp: time.Now().Format(time.RFC3339),
-		}
-		s.ChannelMessageSendEmbed(m.ChannelID, punishmentEmbed)
+		})
 	}
+
+	ctx.Respond(embeds...)
 }
 
-func (c *Commands) handleLeaderboard(s *discordgo.Session, m *discordgo.MessageCreate) {
+func (c *Commands) handleLeaderboard(ctx *CommandContext) {
 	topUsers, err := c.services.User.GetTopUsersByViews()
 	if err != nil {
-		c.sendErrorEmbed(s, m, fmt.Sprintf("Failed to fetch leaderboard: %v", err))
+		ctx.Error(fmt.Sprintf("Failed to fetch leaderboard: %v", err))
 		return
 	}
 
@@ -988,20 +1337,13 @@ func (c *Commands) handleLeaderboard(s *discordgo.Session, m *discordgo.MessageC
 		},
 		Timestamp: time.Now().Format(time.RFC3339),
 	}
-	s.ChannelMessageSendEmbed(m.ChannelID, embed)
+	ctx.Respond(embed)
 }
 
-func (c *Commands) handleProfile(s *discordgo.Session, m *discordgo.MessageCreate, args []string) {
-	if len(args) < 2 {
-		s.ChannelMessageSend(m.ChannelID, "Enter a username. Example: `?profile username`")
-		return
-	}
-
-	username := args[1]
-
-	user, err := c.services.User.GetUserByUsernamePublic(username)
+func (c *Commands) handleProfile(ctx *CommandContext) {
+	user, err := c.services.User.GetUserByUsernamePublic(ctx.String("username"))
 	if err != nil {
-		s.ChannelMessageSend(m.ChannelID, "Profile not found.")
+		ctx.Error("Profile not found.")
 		return
 	}
 
@@ -1010,29 +1352,19 @@ func (c *Commands) handleProfile(s *discordgo.Session, m *discordgo.MessageCreat
 
 	punishment, _ := c.services.Punish.GetActivePunishmentForUser(user.UID)
 	if punishment != nil && punishment.Active && punishment.PunishmentType != "partial" {
-		s.ChannelMessageSend(m.ChannelID, "This user is restricted and cannot be viewed.")
+		ctx.Error("This user is restricted and cannot be viewed.")
 		return
 	}
 
-	embed := c.createProfileEmbed(user, profile, badges)
-	s.ChannelMessageSendEmbed(m.ChannelID, embed)
+	ctx.Respond(c.createProfileEmbed(user, profile, badges))
 }
 
-func (c *Commands) handleUIDProfile(s *discordgo.Session, m *discordgo.MessageCreate, args []string) {
-	if len(args) < 2 {
-		s.ChannelMessageSend(m.ChannelID, "Enter a user ID. Example: `?uidprofile 123`")
-		return
-	}
-
-	userID, err := strconv.Atoi(args[1])
-	if err != nil {
-		s.ChannelMessageSend(m.ChannelID, "Invalid user ID. Please enter a valid number.")
-		return
-	}
+func (c *Commands) handleUIDProfile(ctx *CommandContext) {
+	userID, _ := ctx.Int("uid")
 
 	user, err := c.services.User.GetUserByUID(uint(userID))
 	if err != nil {
-		s.ChannelMessageSend(m.ChannelID, "Profile not found.")
+		ctx.Error("Profile not found.")
 		return
 	}
 
@@ -1041,24 +1373,23 @@ func (c *Commands) handleUIDProfile(s *discordgo.Session, m *discordgo.MessageCr
 
 	punishment, _ := c.services.Punish.GetActivePunishmentForUser(user.UID)
 	if punishment != nil && punishment.Active {
-		s.ChannelMessageSend(m.ChannelID, "This user is restricted and cannot be viewed.")
+		ctx.Error("This user is restricted and cannot be viewed.")
 		return
 	}
 
-	embed := c.createProfileEmbed(user, profile, badges)
-	s.ChannelMessageSendEmbed(m.ChannelID, embed)
+	ctx.Respond(c.createProfileEmbed(user, profile, badges))
 }
 
-func (c *Commands) handleMe(s *discordgo.Session, m *discordgo.MessageCreate) {
-	user, err := c.services.Discord.GetUserByDiscordID(m.Author.ID)
-	if err != nil {
-		c.sendErrorEmbed(s, m, "Your Discord account is not linked to a cutz.lol profile.")
+func (c *Commands) handleMe(ctx *CommandContext) {
+	user := ctx.User
+	if user == nil {
+		ctx.Error("Your Discord account is not linked to a cutz.lol profile.")
 		return
 	}
 
 	profile, err := c.services.Profile.GetUserProfileByUID(user.UID)
 	if err != nil {
-		c.sendErrorEmbed(s, m, "Failed to fetch your profile information.")
+		ctx.Error("Failed to fetch your profile information.")
 		return
 	}
 
@@ -1066,12 +1397,11 @@ func (c *Commands) handleMe(s *discordgo.Session, m *discordgo.MessageCreate) {
 
 	punishment, _ := c.services.Punish.GetActivePunishmentForUser(user.UID)
 	if punishment != nil && punishment.Active {
-		c.sendErrorEmbed(s, m, "Your account is currently restricted.")
+		ctx.Error("Your account is currently restricted.")
 		return
 	}
 
-	embed := c.createProfileEmbed(user, profile, badges)
-	s.ChannelMessageSendEmbed(m.ChannelID, embed)
+	ctx.Respond(c.createProfileEmbed(user, profile, badges))
 }
 
 func (c *Commands) createProfileEmbed(user *models.User, profile *models.UserProfile, badges []*models.UserBadge) *discordgo.MessageEmbed {
@@ -1151,10 +1481,10 @@ func (c *Commands) createProfileEmbed(user *models.User, profile *models.UserPro
 	}
 }
 
-func (c *Commands) handleStats(s *discordgo.Session, m *discordgo.MessageCreate) {
+func (c *Commands) handleStats(ctx *CommandContext) {
 	stats, err := c.services.User.GetStats()
 	if err != nil {
-		c.sendErrorEmbed(s, m, fmt.Sprintf("Failed to fetch stats: %v", err))
+		ctx.Error(fmt.Sprintf("Failed to fetch stats: %v", err))
 		return
 	}
 
@@ -1184,10 +1514,10 @@ func (c *Commands) handleStats(s *discordgo.Session, m *discordgo.MessageCreate)
 		},
 		Timestamp: time.Now().Format(time.RFC3339),
 	}
-	s.ChannelMessageSendEmbed(m.ChannelID, embed)
+	ctx.Respond(embed)
 }
 
-func (c *Commands) handleStatus(s *discordgo.Session, m *discordgo.MessageCreate) {
+func (c *Commands) handleStatus(ctx *CommandContext) {
 	activeStatus, err := c.services.Status.GetActiveStatus()
 	upcomingStatuses, _ := c.services.Status.GetUpcomingStatus()
 
@@ -1296,39 +1626,19 @@ func (c *Commands) handleStatus(s *discordgo.Session, m *discordgo.MessageCreate
 		})
 	}
 
-	s.ChannelMessageSendEmbed(m.ChannelID, embed)
+	ctx.Respond(embed)
 }
 
-func (c *Commands) handleCreateStatus(s *discordgo.Session, m *discordgo.MessageCreate, args []string) {
-	if m.Author.Username != "mouviel" {
-		c.sendUnauthorizedEmbed(s, m, "You are not authorized to create status updates")
-		return
-	}
-
-	cmdContent := strings.TrimPrefix(m.Content, config.DiscordPrefix+"createstatus ")
-
-	re := regexp.MustCompile(`"([^"]+)"`)
-	matches := re.FindAllStringSubmatch(cmdContent, 2)
-
-	if len(matches) < 2 {
-		c.sendInvalidUsageEmbed(s, m,
-			"Please provide start and end dates in quotes",
-			"`?createstatus \"YYYY-MM-DD HH:MM\" \"YYYY-MM-DD HH:MM\" <reason>`",
-			"`?createstatus \"2025-03-20 21:00\" \"2025-03-20 22:00\" Database maintenance`")
-		return
-	}
-
-	startDate := matches[0][1]
-	endDate := matches[1][1]
-
-	reasonIndex := strings.Index(cmdContent, matches[1][0]) + len(matches[1][0])
-	reason := strings.TrimSpace(cmdContent[reasonIndex:])
+func (c *Commands) handleCreateStatus(ctx *CommandContext) {
+	startDate := ctx.String("start")
+	endDate := ctx.String("end")
+	reason := ctx.String("reason")
 
 	statusType := models.StatusTypeMaintenance
 
 	id, err := c.services.Status.CreateStatus(statusType, reason, startDate, endDate)
 	if err != nil {
-		c.sendErrorEmbed(s, m, fmt.Sprintf("Failed to create status: %v", err))
+		ctx.Error(fmt.Sprintf("Failed to create status: %v", err))
 		return
 	}
 
@@ -1348,32 +1658,45 @@ func (c *Commands) handleCreateStatus(s *discordgo.Session, m *discordgo.Message
 		},
 		Timestamp: time.Now().Format(time.RFC3339),
 	}
-	s.ChannelMessageSendEmbed(m.ChannelID, embed)
+	ctx.Respond(embed)
 }
 
-func (c *Commands) handleDeleteStatus(s *discordgo.Session, m *discordgo.MessageCreate, args []string) {
-	if m.Author.Username != "mouviel" {
-		c.sendUnauthorizedEmbed(s, m, "You are not authorized to delete status updates")
+func (c *Commands) handleDeleteStatus(ctx *CommandContext) {
+	statusID, _ := ctx.Int("id")
+
+	status, err := c.services.Status.GetStatus(uint(statusID))
+	if err != nil {
+		ctx.Error("Status not found")
 		return
 	}
 
-	if len(args) < 2 {
-		c.sendInvalidUsageEmbed(s, m,
-			"Please provide a status ID",
-			"`?deletestatus <id>`",
-			"`?deletestatus 1`")
-		return
+	embed := &discordgo.MessageEmbed{
+		Title:       "Delete Status?",
+		Description: fmt.Sprintf("Status with ID %d will be permanently deleted.", status.ID),
+		Color:       0xff0000,
+		Fields: []*discordgo.MessageEmbedField{
+			{Name: "Reason", Value: status.Reason},
+			{Name: "Start Date", Value: fmt.Sprintf("<t:%d:F>", status.StartDate.Unix()), Inline: true},
+			{Name: "End Date", Value: fmt.Sprintf("<t:%d:F>", status.EndDate.Unix()), Inline: true},
+		},
+		Footer: &discordgo.MessageEmbedFooter{
+			Text: "cutz.lol status system",
+		},
+		Timestamp: time.Now().Format(time.RFC3339),
 	}
+	ctx.Confirm("deletestatus", strconv.FormatUint(uint64(status.ID), 10), embed)
+}
 
-	statusID, err := strconv.ParseUint(args[1], 10, 32)
+func (c *Commands) confirmDeleteStatus(ctx *CommandContext, arg string) {
+	statusID, err := strconv.ParseUint(arg, 10, 32)
 	if err != nil {
-		c.sendInvalidInputEmbed(s, m, "Invalid status ID")
+		ctx.InvalidInput("Invalid status ID")
 		return
 	}
 
 	err = c.services.Status.DeleteStatus(uint(statusID))
 	if err != nil {
-		c.sendErrorEmbed(s, m, fmt.Sprintf("Failed to delete status: %v", err))
+		ctx.Error(fmt.Sprintf("Failed to delete status: %v", err))
 		return
 	}
 
@@ -1386,143 +1709,87 @@ func (c *Commands) handleDeleteStatus(s *discordgo.Session, m *discordgo.Message
 		},
 		Timestamp: time.Now().Format(time.RFC3339),
 	}
-	s.ChannelMessageSendEmbed(m.ChannelID, embed)
+	ctx.Respond(embed)
 }
 
 var generateCooldowns = make(map[string]time.Time)
 
-func (c *Commands) handleGenerate(s *discordgo.Session, m *discordgo.MessageCreate, args []string) {
+func (c *Commands) handleGenerate(ctx *CommandContext) {
 	cooldownDuration := 1 * time.Minute
-	lastRequest, hasCooldown := generateCooldowns[m.Author.ID]
+	lastRequest, hasCooldown := generateCooldowns[ctx.Author.ID]
 	if hasCooldown {
 		timeRemaining := time.Until(lastRequest.Add(cooldownDuration))
 		if timeRemaining > 0 {
 			seconds := int(timeRemaining.Seconds()) % 60
-			c.sendErrorEmbed(s, m, fmt.Sprintf("Please wait %d seconds.", seconds))
+			ctx.Error(fmt.Sprintf("Please wait %d seconds.", seconds))
 			return
 		}
 	}
 
-	if len(args) < 2 {
-		c.sendInvalidUsageEmbed(s, m,
-			"Please provide a username",
-			"`?generate <username>`",
-			"`?generate test123`")
-		return
-	}
-
-	username := args[1]
-	user, err := c.services.User.GetUserByUsernamePublic(username)
-
+	user, err := c.services.User.GetUserByUsernamePublic(ctx.String("username"))
 	if err != nil {
-		c.sendErrorEmbed(s, m, "User not found. Check the username and try again.")
+		ctx.Error("User not found. Check the username and try again.")
 		return
 	}
 
 	profile, err := c.services.Profile.GetUserProfileByUID(user.UID)
 	if err != nil {
-		c.sendErrorEmbed(s, m, "Failed to fetch user profile information.")
+		ctx.Error("Failed to fetch user profile information.")
 		return
 	}
 
 	punishment, _ := c.services.Punish.GetActivePunishmentForUser(user.UID)
 	if punishment != nil && punishment.Active {
-		c.sendErrorEmbed(s, m, "This user is restricted and cannot be viewed.")
+		ctx.Error("This user is restricted and cannot be viewed.")
 		return
 	}
 
-	statusMsg, err := s.ChannelMessageSend(m.ChannelID, "⏳ Starting generation for **"+user.Username+"**...")
-	if err != nil {
-		c.sendErrorEmbed(s, m, "Failed to send status message.")
+	// Rendering takes longer than the 3 seconds Discord allows for a response
+	if err := ctx.Defer(); err != nil {
 		return
 	}
 
-	s.ChannelMessageEdit(m.ChannelID, statusMsg.ID, "⏳ Generating image for **"+user.Username+"**... Please wait.")
-
-	imageService := c.services.Image
-	imagePath, err := imageService.GenerateUserCard(user, profile)
-	if err != nil {
-		s.ChannelMessageEdit(m.ChannelID, statusMsg.ID, "❌ Failed to generate image: "+err.Error())
-		return
+	editContent := func(content string) {
+		ctx.EditResponse(&discordgo.WebhookEdit{Content: &content})
 	}
 
-	file, err := os.Open(imagePath)
+	card, err := c.services.Image.GenerateUserCard(user, profile, services.CardLayoutClassic, services.CardFormatPNG)
 	if err != nil {
-		s.ChannelMessageEdit(m.ChannelID, statusMsg.ID, "❌ Failed to access generated image.")
+		editContent("❌ Failed to generate image: " + err.Error())
 		return
 	}
-	defer file.Close()
-	defer os.Remove(imagePath)
 
 	statusMessage := fmt.Sprintf("🖼️ Generated profile card for **%s**:", user.Username)
-	_, err = s.ChannelMessageEditComplex(&discordgo.MessageEdit{
-		Channel: m.ChannelID,
-		ID:      statusMsg.ID,
+	err = ctx.EditResponse(&discordgo.WebhookEdit{
 		Content: &statusMessage,
 		Files: []*discordgo.File{
 			{
 				Name:   fmt.Sprintf("%s_card.png", user.Username),
-				Reader: file,
+				Reader: bytes.NewReader(card.Data),
 			},
 		},
 	})
 
 	if err != nil {
-		s.ChannelMessageEdit(m.ChannelID, statusMsg.ID, "❌ Failed to send generated image.")
+		editContent("❌ Failed to send generated image.")
 		return
 	}
 
-	generateCooldowns[m.Author.ID] = time.Now()
+	generateCooldowns[ctx.Author.ID] = time.Now()
 }
 
+func (c *Commands) handleTimeDiff(ctx *CommandContext) {
+	channelID := ctx.Interaction.ChannelID
 
-
-
-
-
-
-
-
-
-
-func (c *Commands) handleTimeDiff(s *discordgo.Session, m *discordgo.MessageCreate, args []string) {
-	if len(args) < 2 {
-		c.sendInvalidUsageEmbed(s, m, "Please provide at least one message ID",
-			"`?timediff <message_id1> [message_id2]`",
-			"`?timediff 1234567890123456789`\n`?timediff 1234567890123456789 9876543210987654321`")
-		return
-	}
-
-	var messageID1, messageID2 string
-
-	if m.ReferencedMessage != nil {
-		if len(args) == 2 {
-			messageID1 = args[1]
-			messageID2 = m.ReferencedMessage.ID
-		} else if len(args) >= 3 {
-			messageID1 = args[1]
-			messageID2 = args[2]
-		}
-	} else {
-		if len(args) < 3 {
-			c.sendInvalidUsageEmbed(s, m, "Please provide two message IDs",
-				"`?timediff <message_id1> <message_id2>`",
-				"`?timediff 1234567890123456789 9876543210987654321`")
-			return
-		}
-		messageID1 = args[1]
-		messageID2 = args[2]
-	}
-
-	message1, err := s.ChannelMessage(m.ChannelID, messageID1)
+	message1, err := ctx.Session.ChannelMessage(channelID, ctx.String("first"))
 	if err != nil {
-		c.sendErrorEmbed(s, m, fmt.Sprintf("Failed to fetch first message: %v", err))
+		ctx.Error(fmt.Sprintf("Failed to fetch first message: %v", err))
 		return
 	}
 
-	message2, err := s.ChannelMessage(m.ChannelID, messageID2)
+	message2, err := ctx.Session.ChannelMessage(channelID, ctx.String("second"))
 	if err != nil {
-		c.sendErrorEmbed(s, m, fmt.Sprintf("Failed to fetch second message: %v", err))
+		ctx.Error(fmt.Sprintf("Failed to fetch second message: %v", err))
 		return
 	}
 
@@ -1557,13 +1824,13 @@ func (c *Commands) handleTimeDiff(s *discordgo.Session, m *discordgo.MessageCrea
 			{
 				Name: "Older Message",
 				Value: fmt.Sprintf("[Jump to message](https://discord.com/channels/%s/%s/%s)\n**Date**: <t:%d:F>\n**Sent by**: %s",
-					m.GuildID, m.ChannelID, olderMessage.ID, olderTimestamp.Unix(), olderMessage.Author.Username),
+					ctx.Interaction.GuildID, channelID, olderMessage.ID, olderTimestamp.Unix(), olderMessage.Author.Username),
 				Inline: true,
 			},
 			{
 				Name: "Newer Message",
 				Value: fmt.Sprintf("[Jump to message](https://discord.com/channels/%s/%s/%s)\n**Date**: <t:%d:F>\n**Sent by**: %s",
-					m.GuildID, m.ChannelID, newerMessage.ID, newerTimestamp.Unix(), newerMessage.Author.Username),
+					ctx.Interaction.GuildID, channelID, newerMessage.ID, newerTimestamp.Unix(), newerMessage.Author.Username),
 				Inline: true,
 			},
 		},
@@ -1573,7 +1840,7 @@ func (c *Commands) handleTimeDiff(s *discordgo.Session, m *discordgo.MessageCrea
 		Timestamp: time.Now().Format(time.RFC3339),
 	}
 
-	s.ChannelMessageSendEmbed(m.ChannelID, embed)
+	ctx.Respond(embed)
 }
 
 func formatTimeDiff(diff time.Duration) string {
@@ -1631,88 +1898,22 @@ func (c *Commands) parseColor(colorStr string) int {
 	return int(color)
 }
 
-func (c *Commands) sendErrorEmbed(s *discordgo.Session, m *discordgo.MessageCreate, description string) {
-	embed := &discordgo.MessageEmbed{
-		Title:       "Error",
-		Description: description,
-		Color:       0xff0000,
-		Timestamp:   time.Now().Format(time.RFC3339),
-	}
-	s.ChannelMessageSendEmbed(m.ChannelID, embed)
-}
-
-func (c *Commands) sendUnauthorizedEmbed(s *discordgo.Session, m *discordgo.MessageCreate, description string) {
-	embed := &discordgo.MessageEmbed{
-		Title:       "Unauthorized",
-		Description: description,
-		Color:       0xff0000,
-		Timestamp:   time.Now().Format(time.RFC3339),
-	}
-	s.ChannelMessageSendEmbed(m.ChannelID, embed)
-}
-
-func (c *Commands) sendInvalidUsageEmbed(s *discordgo.Session, m *discordgo.MessageCreate, description, usage, example string) {
-	embed := &discordgo.MessageEmbed{
-		Title:       "Invalid Usage",
-		Description: description,
-		Color:       0xff0000,
-		Fields: []*discordgo.MessageEmbedField{
-			{
-				Name:  "Usage",
-				Value: usage,
-			},
-			{
-				Name:  "Example",
-				Value: example,
-			},
-		},
-		Timestamp: time.Now().Format(time.RFC3339),
-	}
-	s.ChannelMessageSendEmbed(m.ChannelID, embed)
-}
-
-func (c *Commands) sendInvalidInputEmbed(s *discordgo.Session, m *discordgo.MessageCreate, description string) {
-	embed := &discordgo.MessageEmbed{
-		Title:       "Invalid Input",
-		Description: description,
-		Color:       0xff0000,
-		Timestamp:   time.Now().Format(time.RFC3339),
-	}
-	s.ChannelMessageSendEmbed(m.ChannelID, embed)
-}
-
-func (c *Commands) handleInvite(s *discordgo.Session, m *discordgo.MessageCreate, args []string) {
-	// Check if user is admin, head moderator, or special user
-	user, err := c.services.Discord.GetUserByDiscordID(m.Author.ID)
-	if err != nil || (user.StaffLevel < StaffLevelHeadMod && m.Author.Username != "mouviel" && m.Author.Username != "yrzk") {
-		c.sendUnauthorizedEmbed(s, m, "You need Head Moderator or higher permissions to create invite codes.")
+func (c *Commands) handleInvite(ctx *CommandContext) {
+	maxUses, _ := ctx.Int("uses")
+	if maxUses < 1 {
+		ctx.InvalidInput("Please provide a valid number of uses (minimum 1).")
 		return
 	}
 
-	if len(args) < 2 {
-		c.sendInvalidUsageEmbed(s, m, "Please specify the number of uses for the invite code.", 
-			"?invite <uses> [expires_in_hours]", "?invite 5 24")
+	expiresInHours, _ := ctx.Int("expires_hours")
+	if expiresInHours < 0 {
+		ctx.InvalidInput("Please provide a valid expiration time in hours (0 for no expiration).")
 		return
 	}
 
-	maxUses, err := strconv.Atoi(args[1])
-	if err != nil || maxUses < 1 {
-		c.sendInvalidInputEmbed(s, m, "Please provide a valid number of uses (minimum 1).")
-		return
-	}
-
-	expiresInHours := 0
-	if len(args) > 2 {
-		expiresInHours, err = strconv.Atoi(args[2])
-		if err != nil || expiresInHours < 0 {
-			c.sendInvalidInputEmbed(s, m, "Please provide a valid expiration time in hours (0 for no expiration).")
-			return
-		}
-	}
-
-	inviteCode, err := c.services.Invite.CreateInviteCode(user.UID, maxUses, expiresInHours)
+	inviteCode, err := c.services.Invite.CreateInviteCode(ctx.User.UID, int(maxUses), int(expiresInHours))
 	if err != nil {
-		c.sendErrorEmbed(s, m, "Failed to create invite code: "+err.Error())
+		ctx.Error("Failed to create invite code: " + err.Error())
 		return
 	}
 
@@ -1724,30 +1925,23 @@ func (c *Commands) handleInvite(s *discordgo.Session, m *discordgo.MessageCreate
 	}
 
 	embed := &discordgo.MessageEmbed{
-		Title:       "Invite Code Created",
-		Description: fmt.Sprintf("**Code:** `%s`\n**Max Uses:** %d\n**%s**", 
-			inviteCode.Code, inviteCode.MaxUses, expirationText),
-		Color:       0x00ff00,
+		Title: "Invite Code Created",
+		Description: fmt.Sprintf("**Code:** `%s`\n**Max Uses:** %d\n**%s**",
+			inviteCode.Code, inviteCode.Limit(), expirationText),
+		Color: 0x00ff00,
 		Footer: &discordgo.MessageEmbedFooter{
 			Text: "cutz.lol invite system",
 		},
 		Timestamp: time.Now().Format(time.RFC3339),
 	}
 
-	s.ChannelMessageSendEmbed(m.ChannelID, embed)
+	ctx.RespondEphemeral(embed)
 }
 
-func (c *Commands) handleInvites(s *discordgo.Session, m *discordgo.MessageCreate, args []string) {
-	// Check if user is admin
-	user, err := c.services.Discord.GetUserByDiscordID(m.Author.ID)
-	if err != nil || user.StaffLevel < StaffLevelAdmin {
-		c.sendUnauthorizedEmbed(s, m, "You need administrator permissions to view invite codes.")
-		return
-	}
-
-	codes, err := c.services.Invite.GetInviteCodesByCreator(user.UID)
+func (c *Commands) handleInvites(ctx *CommandContext) {
+	codes, err := c.services.Invite.GetInviteCodesByCreator(ctx.User.UID)
 	if err != nil {
-		c.sendErrorEmbed(s, m, "Failed to fetch invite codes: "+err.Error())
+		ctx.Error("Failed to fetch invite codes: " + err.Error())
 		return
 	}
 
@@ -1761,7 +1955,7 @@ func (c *Commands) handleInvites(s *discordgo.Session, m *discordgo.MessageCreat
 			},
 			Timestamp: time.Now().Format(time.RFC3339),
 		}
-		s.ChannelMessageSendEmbed(m.ChannelID, embed)
+		ctx.RespondEphemeral(embed)
 		return
 	}
 
@@ -1781,7 +1975,7 @@ func (c *Commands) handleInvites(s *discordgo.Session, m *discordgo.MessageCreat
 
 		fields = append(fields, &discordgo.MessageEmbedField{
 			Name:   fmt.Sprintf("`%s` - %s", code.Code, status),
-			Value:  fmt.Sprintf("Uses: %d/%d | Expires: %s", code.CurrentUses, code.MaxUses, expirationText),
+			Value:  fmt.Sprintf("Uses: %d/%d | Expires: %s", code.CurrentUses, code.Limit(), expirationText),
 			Inline: true,
 		})
 	}
@@ -1797,34 +1991,21 @@ func (c *Commands) handleInvites(s *discordgo.Session, m *discordgo.MessageCreat
 		Timestamp: time.Now().Format(time.RFC3339),
 	}
 
-	s.ChannelMessageSendEmbed(m.ChannelID, embed)
+	ctx.RespondEphemeral(embed)
 }
 
-func (c *Commands) handleDeleteInvite(s *discordgo.Session, m *discordgo.MessageCreate, args []string) {
-	// Check if user is admin
-	user, err := c.services.Discord.GetUserByDiscordID(m.Author.ID)
-	if err != nil || user.StaffLevel < StaffLevelAdmin {
-		c.sendUnauthorizedEmbed(s, m, "You need administrator permissions to delete invite codes.")
-		return
-	}
-
-	if len(args) < 2 {
-		c.sendInvalidUsageEmbed(s, m, "Please specify the invite code to delete.", 
-			"?deleteinvite <code>", "?deleteinvite ABC12345")
-		return
-	}
+func (c *Commands) handleDeleteInvite(ctx *CommandContext) {
+	code := ctx.String("code")
 
-	code := args[1]
-	
 	// Find the invite code by code string
 	var inviteCode models.InviteCode
-	if err := c.services.Invite.DB.Where("code = ? AND created_by = ?", code, user.UID).First(&inviteCode).Error; err != nil {
-		c.sendErrorEmbed(s, m, "Invite code not found or you don't have permission to delete it.")
+	if err := c.services.Invite.DB.Where("code = ? AND created_by = ?", code, ctx.User.UID).First(&inviteCode).Error; err != nil {
+		ctx.Error("Invite code not found or you don't have permission to delete it.")
 		return
 	}
 
-	if err := c.services.Invite.DeleteInviteCode(inviteCode.ID, user.UID); err != nil {
-		c.sendErrorEmbed(s, m, "Failed to delete invite code: "+err.Error())
+	if err := c.services.Invite.DeleteInviteCode(inviteCode.ID, ctx.User.UID); err != nil {
+		ctx.Error("Failed to delete invite code: " + err.Error())
 		return
 	}
 
@@ -1838,66 +2019,86 @@ func (c *Commands) handleDeleteInvite(s *discordgo.Session, m *discordgo.Message
 		Timestamp: time.Now().Format(time.RFC3339),
 	}
 
-	s.ChannelMessageSendEmbed(m.ChannelID, embed)
+	ctx.RespondEphemeral(embed)
+}
+
+/* Check that the target of a user deletion can be deleted by the admin */
+func (c *Commands) validateDeleteTarget(ctx *CommandContext, targetUser *models.User) bool {
+	// Prevent deleting admins
+	if targetUser.StaffLevel >= StaffLevelAdmin {
+		ctx.Error("Cannot delete administrator accounts.")
+		return false
+	}
+
+	// Prevent self-deletion
+	if targetUser.UID == ctx.User.UID {
+		ctx.Error("You cannot delete your own account.")
+		return false
+	}
+
+	return true
 }
 
-func (c *Commands) handleDeleteUser(s *discordgo.Session, m *discordgo.MessageCreate, args []string) {
-	// Check if user is admin
-	admin, err := c.services.Discord.GetUserByDiscordID(m.Author.ID)
-	if err != nil || admin.StaffLevel < StaffLevelAdmin {
-		c.sendUnauthorizedEmbed(s, m, "You need administrator permissions to delete users.")
+func (c *Commands) handleDeleteUser(ctx *CommandContext) {
+	targetUser, err := c.findUser(ctx.String("username"))
+	if err != nil {
+		ctx.Error("User not found.")
 		return
 	}
 
-	if len(args) < 2 {
-		c.sendInvalidUsageEmbed(s, m, "Please specify the user to delete (username or UID).", 
-			"?deleteuser <username_or_uid>", "?deleteuser john_doe")
+	if !c.validateDeleteTarget(ctx, targetUser) {
 		return
 	}
 
-	target := args[1]
-	var targetUser *models.User
+	embed := &discordgo.MessageEmbed{
+		Title: "Delete User?",
+		Description: fmt.Sprintf("**%s** (UID: %d) will be completely deleted from the database. This cannot be undone.",
+			targetUser.Username, targetUser.UID),
+		Color: 0xff0000,
+		Footer: &discordgo.MessageEmbedFooter{
+			Text: "cutz.lol user management",
+		},
+		Timestamp: time.Now().Format(time.RFC3339),
+	}
+	ctx.Confirm("deleteuser", strconv.FormatUint(uint64(targetUser.UID), 10), embed)
+}
+
+func (c *Commands) confirmDeleteUser(ctx *CommandContext, arg string) {
+	admin := ctx.User
 
-	// Try to find user by UID first
-	if uid, err := strconv.ParseUint(target, 10, 32); err == nil {
-		targetUser, err = c.services.User.GetUserByUID(uint(uid))
-		if err != nil {
-			c.sendErrorEmbed(s, m, "User not found with that UID.")
-			return
-		}
-	} else {
-		// Try to find user by username
-		targetUser, err = c.services.User.GetUserByUsername(target)
-		if err != nil {
-			c.sendErrorEmbed(s, m, "User not found with that username.")
-			return
-		}
+	uid, err := strconv.ParseUint(arg, 10, 32)
+	if err != nil {
+		ctx.InvalidInput("Invalid user ID")
+		return
 	}
 
-	// Prevent deleting admins
-	if targetUser.StaffLevel >= 4 {
-		c.sendErrorEmbed(s, m, "Cannot delete administrator accounts.")
+	targetUser, err := c.services.User.GetUserByUID(uint(uid))
+	if err != nil {
+		ctx.Error("User not found.")
 		return
 	}
 
-	// Prevent self-deletion
-	if targetUser.UID == admin.UID {
-		c.sendErrorEmbed(s, m, "You cannot delete your own account.")
+	if !c.validateDeleteTarget(ctx, targetUser) {
 		return
 	}
 
 	// Delete the user
 	err = c.services.User.DeleteUser(targetUser.UID, admin.UID)
 	if err != nil {
-		c.sendErrorEmbed(s, m, "Failed to delete user: "+err.Error())
+		ctx.Error("Failed to delete user: " + err.Error())
 		return
 	}
 
+	email := "Not set"
+	if targetUser.Email != nil {
+		email = *targetUser.Email
+	}
+
 	embed := &discordgo.MessageEmbed{
-		Title:       "User Deleted",
-		Description: fmt.Sprintf("**%s** (UID: %d) has been completely deleted from the database.", 
+		Title: "User Deleted",
+		Description: fmt.Sprintf("**%s** (UID: %d) has been completely deleted from the database.",
 			targetUser.Username, targetUser.UID),
-		Color:       0xff0000,
+		Color: 0xff0000,
 		Fields: []*discordgo.MessageEmbedField{
 			{
 				Name:   "Deleted By",
@@ -1906,7 +2107,7 @@ func (c *Commands) handleDeleteUser(s *discordgo.Session, m *discordgo.MessageCr
 			},
 			{
 				Name:   "User Email",
-				Value:  *targetUser.Email,
+				Value:  email,
 				Inline: true,
 			},
 			{
@@ -1921,55 +2122,72 @@ func (c *Commands) handleDeleteUser(s *discordgo.Session, m *discordgo.MessageCr
 		Timestamp: time.Now().Format(time.RFC3339),
 	}
 
-	s.ChannelMessageSendEmbed(m.ChannelID, embed)
+	ctx.Respond(embed)
 }
 
-func (c *Commands) handleUpdateBadgeRole(s *discordgo.Session, m *discordgo.MessageCreate, args []string) {
-	// Check if user is admin
-	adminUser, err := c.services.Discord.GetUserByDiscordID(m.Author.ID)
-	if err != nil || adminUser.StaffLevel < StaffLevelAdmin {
-		c.sendUnauthorizedEmbed(s, m, "You need administrator permissions to update badge roles.")
+func (c *Commands) handleBadgeRole(ctx *CommandContext) {
+	badgeName := ctx.String("badge")
+
+	if ctx.Subcommand == "list" {
+		roles, err := c.services.Badge.GetBadgeRoles(badgeName)
+		if err != nil {
+			ctx.Error(fmt.Sprintf("Failed to get badge roles: %v", err))
+			return
+		}
+
+		description := "No roles are synced with this badge"
+		if len(roles) > 0 {
+			mentions := make([]string, len(roles))
+			for i, role := range roles {
+				mentions[i] = fmt.Sprintf("<@&%s> (`%s`)", role.RoleID, role.GuildID)
+			}
+			description = strings.Join(mentions, "\n")
+		}
+
+		ctx.Respond(&discordgo.MessageEmbed{
+			Title:       fmt.Sprintf("Roles for %s", badgeName),
+			Description: description,
+			Color:       0x000000,
+			Footer: &discordgo.MessageEmbedFooter{
+				Text: "cutz.lol badge system",
+			},
+		})
 		return
 	}
 
-	if len(args) < 3 {
-		c.sendInvalidUsageEmbed(s, m, "Please provide badge name and new role ID", 
-			"?updatebadgerole <badge_name> <new_role_id>", "?updatebadgerole Staff 1234567890123456789")
+	role := ctx.Role("role")
+	if role == nil {
+		ctx.InvalidInput("Please provide a Discord role")
 		return
 	}
 
-	badgeName := args[1]
-	newRoleID := args[2]
-
-	// Find the badge
-	var badge models.Badge
-	if err := c.services.Badge.DB.Where("name = ?", badgeName).First(&badge).Error; err != nil {
-		c.sendErrorEmbed(s, m, "Badge not found: "+badgeName)
+	var err error
+	title := "Badge Role Added"
+	switch ctx.Subcommand {
+	case "add":
+		// Role options only resolve roles of the guild the command was used in
+		_, err = c.services.Badge.AddBadgeRole(badgeName, ctx.Interaction.GuildID, role.ID)
+	case "remove":
+		err = c.services.Badge.RemoveBadgeRole(badgeName, role.ID)
+		title = "Badge Role Removed"
+	default:
+		ctx.InvalidInput("Unknown subcommand")
 		return
 	}
 
-	oldRoleID := badge.DiscordRoleID
-
-	// Update the badge's Discord role ID
-	if err := c.services.Badge.DB.Model(&badge).Update("discord_role_id", newRoleID).Error; err != nil {
-		c.sendErrorEmbed(s, m, "Failed to update badge role: "+err.Error())
+	if err != nil {
+		ctx.Error(fmt.Sprintf("Failed to update badge role: %v", err))
 		return
 	}
 
 	embed := &discordgo.MessageEmbed{
-		Title:       "Badge Role Updated",
-		Description: fmt.Sprintf("Successfully updated Discord role for badge **%s**", badgeName),
+		Title:       title,
+		Description: fmt.Sprintf("Holders of **%s** are being synced", badgeName),
 		Color:       0x00ff00,
 		Fields: []*discordgo.MessageEmbedField{
 			{Name: "Badge Name", Value: badgeName, Inline: true},
-			{Name: "Old Role ID", Value: func() string {
-				if oldRoleID == "" {
-					return "None"
-				}
-				return oldRoleID
-			}(), Inline: true},
-			{Name: "New Role ID", Value: newRoleID, Inline: true},
-			{Name: "Updated By", Value: adminUser.Username, Inline: true},
+			{Name: "Role", Value: fmt.Sprintf("<@&%s>", role.ID), Inline: true},
+			{Name: "Updated By", Value: ctx.User.Username, Inline: true},
 		},
 		Footer: &discordgo.MessageEmbedFooter{
 			Text: "cutz.lol badge system",
@@ -1977,28 +2195,28 @@ func (c *Commands) handleUpdateBadgeRole(s *discordgo.Session, m *discordgo.Mess
 		Timestamp: time.Now().Format(time.RFC3339),
 	}
 
-	s.ChannelMessageSendEmbed(m.ChannelID, embed)
+	ctx.Respond(embed)
 }
 
-func (c *Commands) handleClaim(s *discordgo.Session, m *discordgo.MessageCreate) {
+func (c *Commands) handleClaim(ctx *CommandContext) {
 	// Check if user has Discord account linked
-	user, err := c.services.Discord.GetUserByDiscordID(m.Author.ID)
-	if err != nil {
-		c.sendErrorEmbed(s, m, "You need to link your Discord account to cutz.lol to claim the Early User badge.\n\nVisit https://cutz.lol/dashboard/settings to link your account.")
+	user := ctx.User
+	if user == nil {
+		ctx.Error("You need to link your Discord account to cutz.lol to claim the Early User badge.\n\nVisit https://cutz.lol/dashboard/settings to link your account.")
 		return
 	}
 
 	// Check if user already has the Early User badge
 	badges, err := c.services.Badge.GetUserBadges(user.UID)
 	if err != nil {
-		c.sendErrorEmbed(s, m, "Failed to check your badges. Please try again later.")
+		ctx.Error("Failed to check your badges. Please try again later.")
 		return
 	}
 
 	// Check if user already has Early User badge
 	for _, badge := range badges {
 		if badge.Badge.Name == "Early User" {
-			c.sendErrorEmbed(s, m, "You already have the Early User badge!")
+			ctx.Error("You already have the Early User badge!")
 			return
 		}
 	}
@@ -2007,9 +2225,9 @@ func (c *Commands) handleClaim(s *discordgo.Session, m *discordgo.MessageCreate)
 	err = c.services.Badge.AssignBadge(user.UID, "Early User")
 	if err != nil {
 		if err.Error() == "badge not found" {
-			c.sendErrorEmbed(s, m, "Early User badge is not available at the moment. Please contact an administrator.")
+			ctx.Error("Early User badge is not available at the moment. Please contact an administrator.")
 		} else {
-			c.sendErrorEmbed(s, m, fmt.Sprintf("Failed to claim Early User badge: %v", err))
+			ctx.Error(fmt.Sprintf("Failed to claim Early User badge: %v", err))
 		}
 		return
 	}
@@ -2042,9 +2260,86 @@ func (c *Commands) handleClaim(s *discordgo.Session, m *discordgo.MessageCreate)
 		Timestamp: time.Now().Format(time.RFC3339),
 	}
 
-	s.ChannelMessageSendEmbed(m.ChannelID, embed)
+	ctx.Respond(embed)
 
 	// Log the claim
-	log.Printf("Early User badge claimed by %s (UID: %d, Discord ID: %s)", 
-		user.Username, user.UID, m.Author.ID)
+	log.Printf("Early User badge claimed by %s (UID: %d, Discord ID: %s)",
+		user.Username, user.UID, ctx.Author.ID)
+}
+
+func (c *Commands) handlePositions(ctx *CommandContext) {
+	positions, err := c.services.Apply.GetAllPositions()
+	if err != nil {
+		ctx.Error(fmt.Sprintf("Failed to get positions: %v", err))
+		return
+	}
+
+	if len(positions) == 0 {
+		ctx.Error("No positions found")
+		return
+	}
+
+	now := time.Now()
+	var fields []*discordgo.MessageEmbedField
+	for _, position := range positions {
+		state := "Closed"
+		if position.IsOpen(now) {
+			state = "Open"
+		} else if position.Active {
+			state = "Active, outside window"
+		}
+
+		value := fmt.Sprintf("ID: `%s`\nState: %s\nVersion: %d (%d questions)", position.ID, state, position.Version, len(position.Questions))
+		if position.OpensAt != nil {
+			value += fmt.Sprintf("\nOpens: <t:%d:f>", position.OpensAt.Unix())
+		}
+		if position.ClosesAt != nil {
+			value += fmt.Sprintf("\nCloses: <t:%d:f>", position.ClosesAt.Unix())
+		}
+
+		fields = append(fields, &discordgo.MessageEmbedField{
+			Name:   position.Title,
+			Value:  value,
+			Inline: true,
+		})
+	}
+
+	embed := &discordgo.MessageEmbed{
+		Title:  "Application Positions",
+		Color:  0x000000,
+		Fields: fields,
+		Footer: &discordgo.MessageEmbedFooter{
+			Text: "cutz.lol applications",
+		},
+		Timestamp: time.Now().Format(time.RFC3339),
+	}
+	ctx.Respond(embed)
+}
+
+func (c *Commands) handleSetPositionActive(ctx *CommandContext, active bool) {
+	positionID := strings.ToLower(ctx.String("position"))
+	if err := c.services.Apply.SetPositionActive(positionID, active); err != nil {
+		if err.Error() == "position not found" {
+			ctx.InvalidInput("Position not found. Use `/positions` to list all positions.")
+			return
+		}
+		ctx.Error(fmt.Sprintf("Failed to update position: %v", err))
+		return
+	}
+
+	description := fmt.Sprintf("Position `%s` is now closed for applications", positionID)
+	if active {
+		description = fmt.Sprintf("Position `%s` is now open for applications", positionID)
+	}
+
+	embed := &discordgo.MessageEmbed{
+		Title:       "Position Updated",
+		Description: description,
+		Color:       0x000000,
+		Footer: &discordgo.MessageEmbedFooter{
+			Text: "cutz.lol applications",
+		},
+		Timestamp: time.Now().Format(time.RFC3339),
+	}
+	ctx.Respond(embed)
 }
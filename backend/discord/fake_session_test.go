@@ -0,0 +1,83 @@
+package discord
+
+import (
+	"errors"
+	"sync"
+
+	"github.com/bwmarrin/discordgo"
+)
+
+// fakeSession records what handlers send instead of calling Discord
+type fakeSession struct {
+	mu        sync.Mutex
+	responses []*discordgo.InteractionResponse
+	edits     []*discordgo.WebhookEdit
+	sent      []*discordgo.MessageSend
+	members   map[string]*discordgo.Member
+}
+
+func newFakeSession() *fakeSession {
+	return &fakeSession{members: map[string]*discordgo.Member{}}
+}
+
+func (s *fakeSession) InteractionRespond(interaction *discordgo.Interaction, resp *discordgo.InteractionResponse, options ...discordgo.RequestOption) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.responses = append(s.responses, resp)
+	return nil
+}
+
+func (s *fakeSession) InteractionResponseEdit(interaction *discordgo.Interaction, newresp *discordgo.WebhookEdit, options ...discordgo.RequestOption) (*discordgo.Message, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.edits = append(s.edits, newresp)
+	return &discordgo.Message{}, nil
+}
+
+func (s *fakeSession) ChannelMessage(channelID, messageID string, options ...discordgo.RequestOption) (*discordgo.Message, error) {
+	return &discordgo.Message{ID: messageID, ChannelID: channelID}, nil
+}
+
+func (s *fakeSession) ChannelMessageSendEmbed(channelID string, embed *discordgo.MessageEmbed, options ...discordgo.RequestOption) (*discordgo.Message, error) {
+	return s.ChannelMessageSendComplex(channelID, &discordgo.MessageSend{Embeds: []*discordgo.MessageEmbed{embed}})
+}
+
+func (s *fakeSession) ChannelMessageSendComplex(channelID string, data *discordgo.MessageSend, options ...discordgo.RequestOption) (*discordgo.Message, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.sent = append(s.sent, data)
+	return &discordgo.Message{ChannelID: channelID}, nil
+}
+
+func (s *fakeSession) ChannelMessageEditComplex(m *discordgo.MessageEdit, options ...discordgo.RequestOption) (*discordgo.Message, error) {
+	return &discordgo.Message{ID: m.ID, ChannelID: m.Channel}, nil
+}
+
+func (s *fakeSession) UserChannelCreate(recipientID string, options ...discordgo.RequestOption) (*discordgo.Channel, error) {
+	return &discordgo.Channel{ID: "dm-" + recipientID}, nil
+}
+
+func (s *fakeSession) GuildMember(guildID, userID string, options ...discordgo.RequestOption) (*discordgo.Member, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if member, ok := s.members[userID]; ok {
+		return member, nil
+	}
+	return nil, errors.New("unknown member")
+}
+
+func (s *fakeSession) User(userID string, options ...discordgo.RequestOption) (*discordgo.User, error) {
+	return &discordgo.User{ID: userID}, nil
+}
+
+/* The last interaction response sent, nil when there was none */
+func (s *fakeSession) lastResponse() *discordgo.InteractionResponse {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if len(s.responses) == 0 {
+		return nil
+	}
+	return s.responses[len(s.responses)-1]
+}
+
+var _ Session = (*fakeSession)(nil)
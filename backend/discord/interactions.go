@@ -0,0 +1,492 @@
+package discord
+
+import (
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	"github.com/bwmarrin/discordgo"
+	"github.com/hazebio/haze.bio_backend/models"
+)
+
+const maxAutocompleteChoices = 25
+
+// Session is the subset of *discordgo.Session used by command handlers, so
+// handlers can be run against a fake session.
+type Session interface {
+	InteractionRespond(interaction *discordgo.Interaction, resp *discordgo.InteractionResponse, options ...discordgo.RequestOption) error
+	InteractionResponseEdit(interaction *discordgo.Interaction, newresp *discordgo.WebhookEdit, options ...discordgo.RequestOption) (*discordgo.Message, error)
+	ChannelMessage(channelID, messageID string, options ...discordgo.RequestOption) (*discordgo.Message, error)
+	ChannelMessageSendEmbed(channelID string, embed *discordgo.MessageEmbed, options ...discordgo.RequestOption) (*discordgo.Message, error)
+	ChannelMessageSendComplex(channelID string, data *discordgo.MessageSend, options ...discordgo.RequestOption) (*discordgo.Message, error)
+	ChannelMessageEditComplex(m *discordgo.MessageEdit, options ...discordgo.RequestOption) (*discordgo.Message, error)
+	UserChannelCreate(recipientID string, options ...discordgo.RequestOption) (*discordgo.Channel, error)
+	GuildMember(guildID, userID string, options ...discordgo.RequestOption) (*discordgo.Member, error)
+	User(userID string, options ...discordgo.RequestOption) (*discordgo.User, error)
+}
+
+// SlashCommand is an application command together with the staff level
+// required to run it.
+type SlashCommand struct {
+	Definition *discordgo.ApplicationCommand
+	Category   string
+	StaffLevel uint
+	Handler    func(ctx *CommandContext)
+}
+
+// confirmAction is run when the confirm button of a destructive command is
+// pressed.
+type confirmAction struct {
+	StaffLevel uint
+	Handler    func(ctx *CommandContext, arg string)
+}
+
+type autocompleteFunc func(query string) []*discordgo.ApplicationCommandOptionChoice
+
+// CommandContext carries the interaction being handled and the cutz.lol
+// account linked to the Discord user that triggered it.
+type CommandContext struct {
+	Session     Session
+	Interaction *discordgo.Interaction
+	Author      *discordgo.User
+	User        *models.User
+	Subcommand  string
+
+	options  map[string]*discordgo.ApplicationCommandInteractionDataOption
+	resolved *discordgo.ApplicationCommandInteractionDataResolved
+	update   bool
+}
+
+func newCommandContext(s Session, i *discordgo.Interaction, user *models.User) *CommandContext {
+	ctx := &CommandContext{
+		Session:     s,
+		Interaction: i,
+		Author:      interactionAuthor(i),
+		User:        user,
+		options:     map[string]*discordgo.ApplicationCommandInteractionDataOption{},
+	}
+
+	if i.Type != discordgo.InteractionApplicationCommand && i.Type != discordgo.InteractionApplicationCommandAutocomplete {
+		return ctx
+	}
+
+	data := i.ApplicationCommandData()
+	ctx.resolved = data.Resolved
+
+	options := data.Options
+	if len(options) == 1 && options[0].Type == discordgo.ApplicationCommandOptionSubCommand {
+		ctx.Subcommand = options[0].Name
+		options = options[0].Options
+	}
+	for _, option := range options {
+		ctx.options[option.Name] = option
+	}
+
+	return ctx
+}
+
+func interactionAuthor(i *discordgo.Interaction) *discordgo.User {
+	if i.Member != nil && i.Member.User != nil {
+		return i.Member.User
+	}
+	return i.User
+}
+
+/* Get the staff level of the linked account, users without a linked account have none */
+func (ctx *CommandContext) StaffLevel() uint {
+	if ctx.User == nil {
+		return StaffLevelUser
+	}
+	return ctx.User.StaffLevel
+}
+
+func (ctx *CommandContext) HasStaffLevel(level uint) bool {
+	if level == StaffLevelUser {
+		return true
+	}
+	return ctx.User != nil && ctx.User.StaffLevel >= level
+}
+
+func (ctx *CommandContext) String(name string) string {
+	if option, ok := ctx.options[name]; ok {
+		return strings.TrimSpace(option.StringValue())
+	}
+	return ""
+}
+
+func (ctx *CommandContext) Int(name string) (int64, bool) {
+	if option, ok := ctx.options[name]; ok {
+		return option.IntValue(), true
+	}
+	return 0, false
+}
+
+func (ctx *CommandContext) Bool(name string) (bool, bool) {
+	if option, ok := ctx.options[name]; ok {
+		return option.BoolValue(), true
+	}
+	return false, false
+}
+
+func (ctx *CommandContext) Role(name string) *discordgo.Role {
+	option, ok := ctx.options[name]
+	if !ok || ctx.resolved == nil {
+		return nil
+	}
+	return ctx.resolved.Roles[option.StringValue()]
+}
+
+func (ctx *CommandContext) DiscordUser(name string) *discordgo.User {
+	option, ok := ctx.options[name]
+	if !ok || ctx.resolved == nil {
+		return nil
+	}
+	return ctx.resolved.Users[option.StringValue()]
+}
+
+/* Get the option the user is currently typing in an autocomplete interaction */
+func (ctx *CommandContext) Focused() *discordgo.ApplicationCommandInteractionDataOption {
+	for _, option := range ctx.options {
+		if option.Focused {
+			return option
+		}
+	}
+	return nil
+}
+
+func (ctx *CommandContext) Respond(embeds ...*discordgo.MessageEmbed) error {
+	return ctx.reply(&discordgo.InteractionResponseData{Embeds: embeds}, false)
+}
+
+func (ctx *CommandContext) RespondEphemeral(embeds ...*discordgo.MessageEmbed) error {
+	return ctx.reply(&discordgo.InteractionResponseData{Embeds: embeds}, true)
+}
+
+/* Acknowledge the interaction so the response can be edited in once a slow command finishes */
+func (ctx *CommandContext) Defer() error {
+	return ctx.Session.InteractionRespond(ctx.Interaction, &discordgo.InteractionResponse{
+		Type: discordgo.InteractionResponseDeferredChannelMessageWithSource,
+	})
+}
+
+func (ctx *CommandContext) EditResponse(edit *discordgo.WebhookEdit) error {
+	_, err := ctx.Session.InteractionResponseEdit(ctx.Interaction, edit)
+	return err
+}
+
+/* Replace the message the pressed component belongs to */
+func (ctx *CommandContext) UpdateMessage(embed *discordgo.MessageEmbed, components []discordgo.MessageComponent) error {
+	if components == nil {
+		components = []discordgo.MessageComponent{}
+	}
+
+	err := ctx.Session.InteractionRespond(ctx.Interaction, &discordgo.InteractionResponse{
+		Type: discordgo.InteractionResponseUpdateMessage,
+		Data: &discordgo.InteractionResponseData{
+			Embeds:     []*discordgo.MessageEmbed{embed},
+			Components: components,
+		},
+	})
+	if err != nil {
+		log.Println("Error updating interaction message:", err)
+	}
+	return err
+}
+
+/* Ask the user to confirm a destructive action before running it */
+func (ctx *CommandContext) Confirm(action, arg string, embed *discordgo.MessageEmbed) error {
+	return ctx.reply(&discordgo.InteractionResponseData{
+		Embeds: []*discordgo.MessageEmbed{embed},
+		Components: []discordgo.MessageComponent{
+			discordgo.ActionsRow{
+				Components: []discordgo.MessageComponent{
+					discordgo.Button{
+						Label:    "Confirm",
+						Style:    discordgo.DangerButton,
+						CustomID: confirmCustomID("confirm", action, arg, ctx.Author.ID),
+					},
+					discordgo.Button{
+						Label:    "Cancel",
+						Style:    discordgo.SecondaryButton,
+						CustomID: confirmCustomID("cancel", action, arg, ctx.Author.ID),
+					},
+				},
+			},
+		},
+	}, true)
+}
+
+func (ctx *CommandContext) Error(description string) {
+	ctx.RespondEphemeral(&discordgo.MessageEmbed{
+		Title:       "Error",
+		Description: description,
+		Color:       0xff0000,
+		Timestamp:   time.Now().Format(time.RFC3339),
+	})
+}
+
+func (ctx *CommandContext) Unauthorized(description string) {
+	ctx.RespondEphemeral(&discordgo.MessageEmbed{
+		Title:       "Unauthorized",
+		Description: description,
+		Color:       0xff0000,
+		Timestamp:   time.Now().Format(time.RFC3339),
+	})
+}
+
+func (ctx *CommandContext) InvalidInput(description string) {
+	ctx.RespondEphemeral(&discordgo.MessageEmbed{
+		Title:       "Invalid Input",
+		Description: description,
+		Color:       0xff0000,
+		Timestamp:   time.Now().Format(time.RFC3339),
+	})
+}
+
+func (ctx *CommandContext) reply(data *discordgo.InteractionResponseData, ephemeral bool) error {
+	responseType := discordgo.InteractionResponseChannelMessageWithSource
+	if ctx.update {
+		// Button presses replace the confirmation prompt and drop its buttons
+		responseType = discordgo.InteractionResponseUpdateMessage
+		if data.Components == nil {
+			data.Components = []discordgo.MessageComponent{}
+		}
+	} else if ephemeral {
+		data.Flags = discordgo.MessageFlagsEphemeral
+	}
+
+	err := ctx.Session.InteractionRespond(ctx.Interaction, &discordgo.InteractionResponse{
+		Type: responseType,
+		Data: data,
+	})
+	if err != nil {
+		log.Println("Error responding to interaction:", err)
+	}
+	return err
+}
+
+func confirmCustomID(kind, action, arg, authorID string) string {
+	return fmt.Sprintf("%s:%s:%s:%s", kind, action, arg, authorID)
+}
+
+/* Register the slash commands and confirmation actions handled by the bot */
+func (c *Commands) register(commands ...*SlashCommand) {
+	for _, command := range commands {
+		c.commands[command.Definition.Name] = command
+		c.commandList = append(c.commandList, command)
+	}
+}
+
+/* Get the application command definitions to sync with Discord */
+func (c *Commands) ApplicationCommands() []*discordgo.ApplicationCommand {
+	definitions := make([]*discordgo.ApplicationCommand, 0, len(c.commandList))
+	for _, command := range c.commandList {
+		definitions = append(definitions, command.Definition)
+	}
+	return definitions
+}
+
+/* Route an interaction to the matching command, autocomplete source or button */
+func (c *Commands) Dispatch(s Session, i *discordgo.Interaction) {
+	author := interactionAuthor(i)
+	if author == nil {
+		return
+	}
+
+	switch i.Type {
+	case discordgo.InteractionApplicationCommand:
+		c.runCommand(newCommandContext(s, i, c.linkedUser(author.ID)))
+	case discordgo.InteractionApplicationCommandAutocomplete:
+		c.runAutocomplete(s, i)
+	case discordgo.InteractionMessageComponent:
+		c.runComponent(s, i)
+	}
+}
+
+func (c *Commands) linkedUser(discordID string) *models.User {
+	return c.findLinkedUser(discordID)
+}
+
+func (c *Commands) lookupLinkedUser(discordID string) *models.User {
+	user, err := c.services.Discord.GetUserByDiscordID(discordID)
+	if err != nil {
+		return nil
+	}
+	return user
+}
+
+func (c *Commands) runCommand(ctx *CommandContext) {
+	name := ctx.Interaction.ApplicationCommandData().Name
+	command, ok := c.commands[name]
+	if !ok {
+		ctx.Error("Unknown command")
+		return
+	}
+
+	if name != "status" && !ctx.HasStaffLevel(StaffLevelAdmin) && c.underMaintenance() {
+		ctx.RespondEphemeral(&discordgo.MessageEmbed{
+			Title:       "System Maintenance",
+			Description: "Only `/status` is available during maintenance.",
+			Color:       0xEAB308,
+			Footer: &discordgo.MessageEmbedFooter{
+				Text: "cutz.lol maintenance system",
+			},
+			Timestamp: time.Now().Format(time.RFC3339),
+		})
+		return
+	}
+
+	if !ctx.HasStaffLevel(command.StaffLevel) {
+		if ctx.User == nil {
+			ctx.Unauthorized("You need to link your Discord account to use this command")
+			return
+		}
+		ctx.Unauthorized(fmt.Sprintf("You need %s permissions or higher to use this command", staffLevelNames[command.StaffLevel]))
+		return
+	}
+
+	command.Handler(ctx)
+}
+
+func (c *Commands) runAutocomplete(s Session, i *discordgo.Interaction) {
+	command, ok := c.commands[i.ApplicationCommandData().Name]
+	if !ok {
+		return
+	}
+
+	var user *models.User
+	if command.StaffLevel > StaffLevelUser {
+		user = c.linkedUser(interactionAuthor(i).ID)
+	}
+
+	ctx := newCommandContext(s, i, user)
+	choices := []*discordgo.ApplicationCommandOptionChoice{}
+
+	if focused := ctx.Focused(); focused != nil && ctx.HasStaffLevel(command.StaffLevel) {
+		if complete, ok := c.autocompleters[focused.Name]; ok {
+			choices = complete(strings.TrimSpace(focused.StringValue()))
+		}
+	}
+
+	err := s.InteractionRespond(i, &discordgo.InteractionResponse{
+		Type: discordgo.InteractionApplicationCommandAutocompleteResult,
+		Data: &discordgo.InteractionResponseData{Choices: choices},
+	})
+	if err != nil {
+		log.Println("Error responding to autocomplete:", err)
+	}
+}
+
+func (c *Commands) runComponent(s Session, i *discordgo.Interaction) {
+	customID := i.MessageComponentData().CustomID
+	if strings.HasPrefix(customID, moderationQueuePrefix+":") {
+		c.queue.HandleComponent(newCommandContext(s, i, c.linkedUser(interactionAuthor(i).ID)))
+		return
+	}
+
+	parts := strings.SplitN(customID, ":", 4)
+	if len(parts) != 4 {
+		return
+	}
+	kind, actionName, arg, authorID := parts[0], parts[1], parts[2], parts[3]
+
+	author := interactionAuthor(i)
+	if author.ID != authorID {
+		ctx := newCommandContext(s, i, nil)
+		ctx.Unauthorized("Only the user who ran this command can use these buttons")
+		return
+	}
+
+	action, ok := c.confirmations[actionName]
+	if !ok {
+		return
+	}
+
+	ctx := newCommandContext(s, i, c.linkedUser(author.ID))
+	ctx.update = true
+
+	if kind == "cancel" {
+		ctx.Respond(&discordgo.MessageEmbed{
+			Title:       "Cancelled",
+			Description: "No changes were made.",
+			Color:       0x808080,
+			Timestamp:   time.Now().Format(time.RFC3339),
+		})
+		return
+	}
+
+	if kind != "confirm" {
+		return
+	}
+
+	// The staff level is checked again since it may have changed since the prompt was sent
+	if !ctx.HasStaffLevel(action.StaffLevel) {
+		ctx.Unauthorized("You are no longer authorized to perform this action")
+		return
+	}
+
+	action.Handler(ctx, arg)
+}
+
+func (c *Commands) completeUsernames(query string) []*discordgo.ApplicationCommandOptionChoice {
+	choices := []*discordgo.ApplicationCommandOptionChoice{}
+	if query == "" {
+		return choices
+	}
+
+	usernames, err := c.services.User.AutocompleteUsernames(query, maxAutocompleteChoices)
+	if err != nil {
+		return choices
+	}
+
+	for _, username := range usernames {
+		choices = append(choices, &discordgo.ApplicationCommandOptionChoice{Name: username, Value: username})
+	}
+	return choices
+}
+
+func (c *Commands) completeBadges(query string) []*discordgo.ApplicationCommandOptionChoice {
+	choices := []*discordgo.ApplicationCommandOptionChoice{}
+
+	badges, err := c.services.Badge.GetBadges()
+	if err != nil {
+		return choices
+	}
+
+	query = strings.ToLower(query)
+	for _, badge := range badges {
+		if len(choices) == maxAutocompleteChoices {
+			break
+		}
+		if badge.IsCustom || !strings.Contains(strings.ToLower(badge.Name), query) {
+			continue
+		}
+		choices = append(choices, &discordgo.ApplicationCommandOptionChoice{Name: badge.Name, Value: badge.Name})
+	}
+	return choices
+}
+
+func (c *Commands) completePositions(query string) []*discordgo.ApplicationCommandOptionChoice {
+	choices := []*discordgo.ApplicationCommandOptionChoice{}
+
+	positions, err := c.services.Apply.GetAllPositions()
+	if err != nil {
+		return choices
+	}
+
+	query = strings.ToLower(query)
+	for _, position := range positions {
+		if len(choices) == maxAutocompleteChoices {
+			break
+		}
+		if !strings.Contains(position.ID, query) && !strings.Contains(strings.ToLower(position.Title), query) {
+			continue
+		}
+		choices = append(choices, &discordgo.ApplicationCommandOptionChoice{
+			Name:  fmt.Sprintf("%s (%s)", position.Title, position.ID),
+			Value: position.ID,
+		})
+	}
+	return choices
+}
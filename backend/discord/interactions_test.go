@@ -0,0 +1,302 @@
+package discord
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/bwmarrin/discordgo"
+	"github.com/hazebio/haze.bio_backend/models"
+)
+
+func newTestCommands(users map[string]*models.User) *Commands {
+	return &Commands{
+		commands:         map[string]*SlashCommand{},
+		confirmations:    map[string]*confirmAction{},
+		autocompleters:   map[string]autocompleteFunc{},
+		findLinkedUser:   func(discordID string) *models.User { return users[discordID] },
+		underMaintenance: func() bool { return false },
+	}
+}
+
+func commandInteraction(authorID string, name string, options ...*discordgo.ApplicationCommandInteractionDataOption) *discordgo.Interaction {
+	return &discordgo.Interaction{
+		Type:   discordgo.InteractionApplicationCommand,
+		Member: &discordgo.Member{User: &discordgo.User{ID: authorID}},
+		Data: discordgo.ApplicationCommandInteractionData{
+			Name:    name,
+			Options: options,
+		},
+	}
+}
+
+func componentInteraction(authorID string, customID string) *discordgo.Interaction {
+	return &discordgo.Interaction{
+		Type:   discordgo.InteractionMessageComponent,
+		Member: &discordgo.Member{User: &discordgo.User{ID: authorID}},
+		Data:   discordgo.MessageComponentInteractionData{CustomID: customID},
+	}
+}
+
+func responseTitle(t *testing.T, response *discordgo.InteractionResponse) string {
+	t.Helper()
+	if response == nil || response.Data == nil || len(response.Data.Embeds) == 0 {
+		t.Fatalf("expected a response with an embed, got %+v", response)
+	}
+	return response.Data.Embeds[0].Title
+}
+
+/* Custom IDs of the buttons of a confirmation prompt, confirm first */
+func promptButtons(t *testing.T, response *discordgo.InteractionResponse) (string, string) {
+	t.Helper()
+	if response == nil || response.Data == nil || len(response.Data.Components) != 1 {
+		t.Fatalf("expected a confirmation prompt, got %+v", response)
+	}
+
+	row, ok := response.Data.Components[0].(discordgo.ActionsRow)
+	if !ok || len(row.Components) != 2 {
+		t.Fatalf("expected a row with two buttons, got %+v", response.Data.Components[0])
+	}
+
+	return row.Components[0].(discordgo.Button).CustomID, row.Components[1].(discordgo.Button).CustomID
+}
+
+func TestDispatchRunsCommand(t *testing.T) {
+	c := newTestCommands(map[string]*models.User{})
+
+	var echoed string
+	c.register(&SlashCommand{
+		Definition: &discordgo.ApplicationCommand{Name: "echo"},
+		StaffLevel: StaffLevelUser,
+		Handler: func(ctx *CommandContext) {
+			echoed = ctx.String("text")
+			ctx.Respond(&discordgo.MessageEmbed{Title: echoed})
+		},
+	})
+
+	session := newFakeSession()
+	c.Dispatch(session, commandInteraction("100", "echo", &discordgo.ApplicationCommandInteractionDataOption{
+		Name:  "text",
+		Type:  discordgo.ApplicationCommandOptionString,
+		Value: "hello",
+	}))
+
+	if echoed != "hello" {
+		t.Fatalf("handler got option %q, want hello", echoed)
+	}
+
+	response := session.lastResponse()
+	if response.Type != discordgo.InteractionResponseChannelMessageWithSource {
+		t.Errorf("response type = %v, want a channel message", response.Type)
+	}
+	if title := responseTitle(t, response); title != "hello" {
+		t.Errorf("response title = %q, want hello", title)
+	}
+}
+
+func TestDispatchUnknownCommand(t *testing.T) {
+	c := newTestCommands(map[string]*models.User{})
+
+	session := newFakeSession()
+	c.Dispatch(session, commandInteraction("100", "missing"))
+
+	if title := responseTitle(t, session.lastResponse()); title != "Error" {
+		t.Errorf("response title = %q, want Error", title)
+	}
+}
+
+func TestDispatchDeniesInsufficientStaffLevel(t *testing.T) {
+	c := newTestCommands(map[string]*models.User{
+		"100": {UID: 1, StaffLevel: StaffLevelTrialMod},
+	})
+
+	called := false
+	c.register(&SlashCommand{
+		Definition: &discordgo.ApplicationCommand{Name: "nuke"},
+		StaffLevel: StaffLevelAdmin,
+		Handler:    func(ctx *CommandContext) { called = true },
+	})
+
+	tests := []struct {
+		name        string
+		authorID    string
+		description string
+	}{
+		{"staff level too low", "100", "Administrator permissions or higher"},
+		{"account not linked", "200", "link your Discord account"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			session := newFakeSession()
+			c.Dispatch(session, commandInteraction(tt.authorID, "nuke"))
+
+			if called {
+				t.Fatal("handler ran without the required staff level")
+			}
+
+			response := session.lastResponse()
+			if title := responseTitle(t, response); title != "Unauthorized" {
+				t.Errorf("response title = %q, want Unauthorized", title)
+			}
+			if response.Data.Flags&discordgo.MessageFlagsEphemeral == 0 {
+				t.Error("denial should be ephemeral")
+			}
+			if !strings.Contains(response.Data.Embeds[0].Description, tt.description) {
+				t.Errorf("description = %q, want it to mention %q", response.Data.Embeds[0].Description, tt.description)
+			}
+		})
+	}
+}
+
+func TestDispatchDuringMaintenance(t *testing.T) {
+	c := newTestCommands(map[string]*models.User{
+		"100": {UID: 1},
+		"400": {UID: 4, StaffLevel: StaffLevelAdmin},
+	})
+	c.underMaintenance = func() bool { return true }
+
+	ran := map[string]bool{}
+	for _, name := range []string{"profile", "status"} {
+		name := name
+		c.register(&SlashCommand{
+			Definition: &discordgo.ApplicationCommand{Name: name},
+			Handler:    func(ctx *CommandContext) { ran[ctx.Author.ID+"/"+name] = true },
+		})
+	}
+
+	session := newFakeSession()
+	c.Dispatch(session, commandInteraction("100", "profile"))
+	if title := responseTitle(t, session.lastResponse()); title != "System Maintenance" {
+		t.Errorf("response title = %q, want System Maintenance", title)
+	}
+
+	c.Dispatch(session, commandInteraction("100", "status"))
+	c.Dispatch(session, commandInteraction("400", "profile"))
+
+	want := map[string]bool{"100/status": true, "400/profile": true}
+	for key := range want {
+		if !ran[key] {
+			t.Errorf("%s should run during maintenance", key)
+		}
+	}
+	if ran["100/profile"] {
+		t.Error("users can't run commands other than /status during maintenance")
+	}
+}
+
+/* Commands with a destructive "wipe" command behind a confirmation prompt, and the args it was confirmed with */
+func newConfirmCommands(users map[string]*models.User) (*Commands, *[]string) {
+	c := newTestCommands(users)
+
+	var confirmed []string
+	c.register(&SlashCommand{
+		Definition: &discordgo.ApplicationCommand{Name: "wipe"},
+		StaffLevel: StaffLevelAdmin,
+		Handler: func(ctx *CommandContext) {
+			ctx.Confirm("wipe", "42", &discordgo.MessageEmbed{Title: "Are you sure?"})
+		},
+	})
+	c.confirmations["wipe"] = &confirmAction{
+		StaffLevel: StaffLevelAdmin,
+		Handler: func(ctx *CommandContext, arg string) {
+			confirmed = append(confirmed, arg)
+			ctx.Respond(&discordgo.MessageEmbed{Title: "Wiped"})
+		},
+	}
+
+	return c, &confirmed
+}
+
+func TestConfirmFlow(t *testing.T) {
+	users := map[string]*models.User{
+		"400": {UID: 4, StaffLevel: StaffLevelAdmin},
+		"401": {UID: 5, StaffLevel: StaffLevelAdmin},
+	}
+
+	t.Run("confirm runs the action", func(t *testing.T) {
+		c, confirmed := newConfirmCommands(users)
+		session := newFakeSession()
+
+		c.Dispatch(session, commandInteraction("400", "wipe"))
+		prompt := session.lastResponse()
+		if prompt.Data.Flags&discordgo.MessageFlagsEphemeral == 0 {
+			t.Error("confirmation prompt should be ephemeral")
+		}
+		confirmID, _ := promptButtons(t, prompt)
+
+		c.Dispatch(session, componentInteraction("400", confirmID))
+
+		if len(*confirmed) != 1 || (*confirmed)[0] != "42" {
+			t.Fatalf("action ran with %v, want [42]", *confirmed)
+		}
+
+		response := session.lastResponse()
+		if response.Type != discordgo.InteractionResponseUpdateMessage {
+			t.Errorf("response type = %v, want the prompt to be updated", response.Type)
+		}
+		if response.Data.Components == nil || len(response.Data.Components) != 0 {
+			t.Errorf("updated prompt should drop its buttons, got %+v", response.Data.Components)
+		}
+	})
+
+	t.Run("cancel makes no changes", func(t *testing.T) {
+		c, confirmed := newConfirmCommands(users)
+		session := newFakeSession()
+
+		c.Dispatch(session, commandInteraction("400", "wipe"))
+		_, cancelID := promptButtons(t, session.lastResponse())
+
+		c.Dispatch(session, componentInteraction("400", cancelID))
+
+		if len(*confirmed) != 0 {
+			t.Fatalf("action ran after cancelling: %v", *confirmed)
+		}
+		response := session.lastResponse()
+		if response.Type != discordgo.InteractionResponseUpdateMessage {
+			t.Errorf("response type = %v, want the prompt to be updated", response.Type)
+		}
+		if title := responseTitle(t, response); title != "Cancelled" {
+			t.Errorf("response title = %q, want Cancelled", title)
+		}
+	})
+
+	t.Run("other users can't press the buttons", func(t *testing.T) {
+		c, confirmed := newConfirmCommands(users)
+		session := newFakeSession()
+
+		c.Dispatch(session, commandInteraction("400", "wipe"))
+		confirmID, _ := promptButtons(t, session.lastResponse())
+
+		c.Dispatch(session, componentInteraction("401", confirmID))
+
+		if len(*confirmed) != 0 {
+			t.Fatalf("action ran for another user: %v", *confirmed)
+		}
+		if title := responseTitle(t, session.lastResponse()); title != "Unauthorized" {
+			t.Errorf("response title = %q, want Unauthorized", title)
+		}
+	})
+
+	t.Run("staff level is checked again on confirm", func(t *testing.T) {
+		demoted := map[string]*models.User{"400": {UID: 4, StaffLevel: StaffLevelAdmin}}
+		c, confirmed := newConfirmCommands(demoted)
+		session := newFakeSession()
+
+		c.Dispatch(session, commandInteraction("400", "wipe"))
+		confirmID, _ := promptButtons(t, session.lastResponse())
+
+		demoted["400"] = &models.User{UID: 4, StaffLevel: StaffLevelModerator}
+		c.Dispatch(session, componentInteraction("400", confirmID))
+
+		if len(*confirmed) != 0 {
+			t.Fatalf("action ran after the user was demoted: %v", *confirmed)
+		}
+		response := session.lastResponse()
+		if title := responseTitle(t, response); title != "Unauthorized" {
+			t.Errorf("response title = %q, want Unauthorized", title)
+		}
+		if !strings.Contains(response.Data.Embeds[0].Description, "no longer authorized") {
+			t.Errorf("description = %q", response.Data.Embeds[0].Description)
+		}
+	})
+}
@@ -0,0 +1,720 @@
+package discord
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/bwmarrin/discordgo"
+	"github.com/hazebio/haze.bio_backend/config"
+	"github.com/hazebio/haze.bio_backend/models"
+)
+
+const (
+	moderationQueuePrefix = "modq"
+	moderationQueueTTL    = 30 * 24 * time.Hour
+)
+
+/* The queue lives in the primary guild; the env var covers a database without guilds. Empty when neither is set */
+func (q *ModerationQueue) channelID() string {
+	if guild, err := q.services.Guild.GetPrimaryGuild(); err == nil && guild.ModerationChannelID != "" {
+		return guild.ModerationChannelID
+	}
+	return config.DiscordModerationChannelID
+}
+
+// queueMessage points at the Discord message showing a queue item.
+type queueMessage struct {
+	ChannelID  string `json:"channel_id"`
+	MessageID  string `json:"message_id"`
+	Resolution string `json:"resolution,omitempty"`
+}
+
+// altAlert is the state of an alt account alert in the moderation queue.
+// Alerts are not stored in the database, so the state lives in Redis.
+type altAlert struct {
+	queueMessage
+	Data       models.AltAccountData `json:"data"`
+	DetectedAt time.Time             `json:"detected_at"`
+	ClaimedBy  string                `json:"claimed_by,omitempty"`
+	Resolved   bool                  `json:"resolved"`
+}
+
+// ModerationQueue posts reports, alt account alerts and custom badge edits to the staff channel
+// and handles the buttons staff use to act on them.
+type ModerationQueue struct {
+	services *ServiceManager
+}
+
+func NewModerationQueue(services *ServiceManager) *ModerationQueue {
+	return &ModerationQueue{services: services}
+}
+
+func reportQueueKey(reportID uint) string {
+	return fmt.Sprintf("moderation_queue:report:%d", reportID)
+}
+
+func badgeEditQueueKey(editID uint) string {
+	return fmt.Sprintf("moderation_queue:badge_edit:%d", editID)
+}
+
+func altQueueKey(eventID string) string {
+	return fmt.Sprintf("moderation_queue:alt:%s", eventID)
+}
+
+func queueCustomID(kind, action, id string) string {
+	return fmt.Sprintf("%s:%s:%s:%s", moderationQueuePrefix, kind, action, id)
+}
+
+func (q *ModerationQueue) save(key string, value interface{}) error {
+	data, err := json.Marshal(value)
+	if err != nil {
+		return err
+	}
+	return q.services.Punish.Client.Set(key, data, moderationQueueTTL).Err()
+}
+
+func (q *ModerationQueue) load(key string, value interface{}) error {
+	data, err := q.services.Punish.Client.Get(key).Bytes()
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(data, value)
+}
+
+/* Post a new report to the moderation channel */
+func (q *ModerationQueue) PostReport(s Session, reportID uint) error {
+	report, err := q.services.Punish.GetReportDetails(reportID)
+	if err != nil {
+		return err
+	}
+
+	channelID := q.channelID()
+	if channelID == "" {
+		log.Printf("No moderation channel configured, not posting report %d", reportID)
+		return nil
+	}
+
+	embed, components := q.reportMessage(report, "")
+	message, err := s.ChannelMessageSendComplex(channelID, &discordgo.MessageSend{
+		Embeds:     []*discordgo.MessageEmbed{embed},
+		Components: components,
+	})
+	if err != nil {
+		return fmt.Errorf("error sending report to moderation queue: %w", err)
+	}
+
+	return q.save(reportQueueKey(reportID), &queueMessage{ChannelID: message.ChannelID, MessageID: message.ID})
+}
+
+/* Refresh the queue message of a report after it was changed outside of Discord */
+func (q *ModerationQueue) RefreshReport(s Session, reportID uint) error {
+	var message queueMessage
+	if err := q.load(reportQueueKey(reportID), &message); err != nil {
+		// The report was never posted or the message has expired
+		return nil
+	}
+
+	report, err := q.services.Punish.GetReportDetails(reportID)
+	if err != nil {
+		return err
+	}
+
+	embed, components := q.reportMessage(report, message.Resolution)
+	_, err = s.ChannelMessageEditComplex(&discordgo.MessageEdit{
+		Channel:    message.ChannelID,
+		ID:         message.MessageID,
+		Embeds:     &[]*discordgo.MessageEmbed{embed},
+		Components: &components,
+	})
+	return err
+}
+
+/* Post a custom badge edit waiting for review to the moderation channel */
+func (q *ModerationQueue) PostBadgeEdit(s Session, editID uint) error {
+	edit, err := q.services.Badge.GetBadgeEdit(editID)
+	if err != nil {
+		return err
+	}
+
+	channelID := q.channelID()
+	if channelID == "" {
+		log.Printf("No moderation channel configured, not posting badge edit %d", editID)
+		return nil
+	}
+
+	embed, components := q.badgeEditMessage(edit)
+	message, err := s.ChannelMessageSendComplex(channelID, &discordgo.MessageSend{
+		Embeds:     []*discordgo.MessageEmbed{embed},
+		Components: components,
+	})
+	if err != nil {
+		return fmt.Errorf("error sending badge edit to moderation queue: %w", err)
+	}
+
+	return q.save(badgeEditQueueKey(editID), &queueMessage{ChannelID: message.ChannelID, MessageID: message.ID})
+}
+
+/* Refresh the queue message of a badge edit after it was reviewed from the dashboard */
+func (q *ModerationQueue) RefreshBadgeEdit(s Session, editID uint) error {
+	var message queueMessage
+	if err := q.load(badgeEditQueueKey(editID), &message); err != nil {
+		return nil
+	}
+
+	edit, err := q.services.Badge.GetBadgeEdit(editID)
+	if err != nil {
+		return err
+	}
+
+	embed, components := q.badgeEditMessage(edit)
+	_, err = s.ChannelMessageEditComplex(&discordgo.MessageEdit{
+		Channel:    message.ChannelID,
+		ID:         message.MessageID,
+		Embeds:     &[]*discordgo.MessageEmbed{embed},
+		Components: &components,
+	})
+	return err
+}
+
+/* Post an alt account alert to the moderation channel */
+func (q *ModerationQueue) PostAltAlert(s Session, eventID string, data models.AltAccountData, detectedAt time.Time) error {
+	channelID := q.channelID()
+	if channelID == "" {
+		log.Printf("No moderation channel configured, not posting alt account alert %s", eventID)
+		return nil
+	}
+
+	alert := &altAlert{
+		Data:       data,
+		DetectedAt: detectedAt,
+	}
+
+	embed, components := q.altAlertMessage(eventID, alert)
+	message, err := s.ChannelMessageSendComplex(channelID, &discordgo.MessageSend{
+		Embeds:     []*discordgo.MessageEmbed{embed},
+		Components: components,
+	})
+	if err != nil {
+		return fmt.Errorf("error sending alt account notification: %w", err)
+	}
+
+	alert.ChannelID = message.ChannelID
+	alert.MessageID = message.ID
+	return q.save(altQueueKey(eventID), alert)
+}
+
+/* Handle a button press or template selection on a queue message */
+func (q *ModerationQueue) HandleComponent(ctx *CommandContext) {
+	parts := strings.SplitN(ctx.Interaction.MessageComponentData().CustomID, ":", 4)
+	if len(parts) != 4 {
+		return
+	}
+	kind, action, id := parts[1], parts[2], parts[3]
+
+	if !ctx.HasStaffLevel(StaffLevelModerator) {
+		ctx.Unauthorized("You need Moderator permissions or higher to act on the moderation queue")
+		return
+	}
+
+	switch kind {
+	case "report":
+		reportID, err := strconv.ParseUint(id, 10, 32)
+		if err != nil {
+			ctx.InvalidInput("Invalid report ID")
+			return
+		}
+		q.handleReportAction(ctx, uint(reportID), action)
+	case "alt":
+		q.handleAltAction(ctx, id, action)
+	case "badgeedit":
+		editID, err := strconv.ParseUint(id, 10, 32)
+		if err != nil {
+			ctx.InvalidInput("Invalid badge edit ID")
+			return
+		}
+		q.handleBadgeEditAction(ctx, uint(editID), action)
+	}
+}
+
+func selectedTemplate(ctx *CommandContext) (config.PunishmentTemplate, bool) {
+	values := ctx.Interaction.MessageComponentData().Values
+	if len(values) == 0 {
+		return config.PunishmentTemplate{}, false
+	}
+	return config.GetPunishmentTemplateByID(values[0])
+}
+
+func (q *ModerationQueue) handleReportAction(ctx *CommandContext, reportID uint, action string) {
+	staff := ctx.User
+
+	report, err := q.services.Punish.GetReportDetails(reportID)
+	if err != nil {
+		ctx.Error("Report not found")
+		return
+	}
+
+	var message queueMessage
+	if err := q.load(reportQueueKey(reportID), &message); err != nil {
+		message = queueMessage{ChannelID: ctx.Interaction.ChannelID, MessageID: ctx.Interaction.Message.ID}
+	}
+
+	// Claimed reports can only be resolved by the claiming staff member or a Head Moderator
+	if action != "claim" && report.HandledBy != 0 && report.HandledBy != staff.UID && !ctx.HasStaffLevel(StaffLevelHeadMod) {
+		ctx.Error("This report has been claimed by another staff member")
+		return
+	}
+
+	switch action {
+	case "claim":
+		err = q.services.Punish.AssignReportToStaff(reportID, staff.UID)
+	case "dismiss":
+		if report.Handled {
+			err = errors.New("report has already been handled")
+			break
+		}
+		err = q.services.Punish.HandleReport(reportID, staff.UID)
+		message.Resolution = "Dismissed"
+	case "restrict":
+		template, ok := selectedTemplate(ctx)
+		if !ok {
+			err = errors.New("invalid punishment template")
+			break
+		}
+		if report.Handled {
+			err = errors.New("report has already been handled")
+			break
+		}
+
+		details := fmt.Sprintf("Report #%d: %s", report.ID, report.Reason)
+		_, err = q.services.Punish.CreatePunishmentFromTemplate(report.ReportedUserID, template.ID, details, 0, staff.UID, "full")
+		if err != nil {
+			break
+		}
+		err = q.services.Punish.HandleReport(reportID, staff.UID)
+		message.Resolution = fmt.Sprintf("Restricted (%s)", template.Name)
+	default:
+		return
+	}
+
+	if err != nil {
+		ctx.Error(fmt.Sprintf("Failed to update report: %v", err))
+		return
+	}
+
+	if err := q.save(reportQueueKey(reportID), &message); err != nil {
+		ctx.Error("Failed to save the moderation queue state")
+		return
+	}
+
+	report, err = q.services.Punish.GetReportDetails(reportID)
+	if err != nil {
+		ctx.Error("Report not found")
+		return
+	}
+
+	embed, components := q.reportMessage(report, message.Resolution)
+	ctx.UpdateMessage(embed, components)
+}
+
+func (q *ModerationQueue) handleAltAction(ctx *CommandContext, eventID string, action string) {
+	staff := ctx.User
+
+	alert := &altAlert{}
+	if err := q.load(altQueueKey(eventID), alert); err != nil {
+		ctx.Error("This alert has expired")
+		return
+	}
+
+	if alert.Resolved {
+		ctx.Error("This alert has already been resolved")
+		return
+	}
+
+	if alert.ClaimedBy != "" && alert.ClaimedBy != staff.Username && !ctx.HasStaffLevel(StaffLevelHeadMod) {
+		ctx.Error(fmt.Sprintf("This alert has been claimed by %s", alert.ClaimedBy))
+		return
+	}
+
+	switch action {
+	case "claim":
+		alert.ClaimedBy = staff.Username
+	case "dismiss":
+		alert.Resolved = true
+		alert.Resolution = fmt.Sprintf("Dismissed by %s", staff.Username)
+	case "restrict":
+		template, ok := selectedTemplate(ctx)
+		if !ok {
+			ctx.InvalidInput("Invalid punishment template")
+			return
+		}
+
+		details := fmt.Sprintf("Alt account detected via shared IP during %s", alert.Data.DetectionSource)
+		_, err := q.services.Punish.CreatePunishmentFromTemplate(alert.Data.UID, template.ID, details, 0, staff.UID, "full")
+		if err != nil {
+			ctx.Error(fmt.Sprintf("Failed to restrict user: %v", err))
+			return
+		}
+
+		alert.Resolved = true
+		alert.Resolution = fmt.Sprintf("Restricted (%s) by %s", template.Name, staff.Username)
+	default:
+		return
+	}
+
+	if err := q.save(altQueueKey(eventID), alert); err != nil {
+		ctx.Error("Failed to save the moderation queue state")
+		return
+	}
+
+	embed, components := q.altAlertMessage(eventID, alert)
+	ctx.UpdateMessage(embed, components)
+}
+
+func (q *ModerationQueue) handleBadgeEditAction(ctx *CommandContext, editID uint, action string) {
+	var approve bool
+	switch action {
+	case "approve":
+		approve = true
+	case "reject":
+		approve = false
+	default:
+		return
+	}
+
+	if _, err := q.services.Badge.ReviewBadgeEdit(editID, ctx.User.UID, approve, ""); err != nil {
+		ctx.Error(fmt.Sprintf("Failed to review badge edit: %v", err))
+		return
+	}
+
+	edit, err := q.services.Badge.GetBadgeEdit(editID)
+	if err != nil {
+		ctx.Error("Badge edit not found")
+		return
+	}
+
+	embed, components := q.badgeEditMessage(edit)
+	ctx.UpdateMessage(embed, components)
+}
+
+/* Build the select menu staff use to restrict a user with a punishment template */
+func restrictMenu(customID string) discordgo.SelectMenu {
+	options := make([]discordgo.SelectMenuOption, 0, len(config.PunishmentTemplates))
+	for _, template := range config.PunishmentTemplates {
+		// Custom restrictions need a written reason and are handled from the dashboard
+		if template.ID == "custom" {
+			continue
+		}
+
+		duration := fmt.Sprintf("%dh", template.DurationHours)
+		if template.DurationHours < 0 {
+			duration = "permanent"
+		}
+
+		options = append(options, discordgo.SelectMenuOption{
+			Label:       fmt.Sprintf("%s (%s)", template.Name, duration),
+			Value:       template.ID,
+			Description: template.Description,
+		})
+	}
+
+	return discordgo.SelectMenu{
+		MenuType:    discordgo.StringSelectMenu,
+		CustomID:    customID,
+		Placeholder: "Restrict with template...",
+		Options:     options,
+	}
+}
+
+func profileButton(username string) discordgo.Button {
+	return discordgo.Button{
+		Label: "View Profile",
+		Style: discordgo.LinkButton,
+		URL:   fmt.Sprintf("https://cutz.lol/%s", username),
+	}
+}
+
+/* Build the queue components for an item, resolved items only keep the profile link */
+func queueComponents(kind, id, username string, claimed, resolved bool) []discordgo.MessageComponent {
+	if resolved {
+		return []discordgo.MessageComponent{
+			discordgo.ActionsRow{Components: []discordgo.MessageComponent{profileButton(username)}},
+		}
+	}
+
+	claimLabel := "Claim"
+	if claimed {
+		claimLabel = "Claimed"
+	}
+
+	return []discordgo.MessageComponent{
+		discordgo.ActionsRow{
+			Components: []discordgo.MessageComponent{
+				discordgo.Button{
+					Label:    claimLabel,
+					Style:    discordgo.PrimaryButton,
+					CustomID: queueCustomID(kind, "claim", id),
+					Disabled: claimed,
+				},
+				profileButton(username),
+				discordgo.Button{
+					Label:    "Dismiss",
+					Style:    discordgo.SecondaryButton,
+					CustomID: queueCustomID(kind, "dismiss", id),
+				},
+			},
+		},
+		discordgo.ActionsRow{
+			Components: []discordgo.MessageComponent{
+				restrictMenu(queueCustomID(kind, "restrict", id)),
+			},
+		},
+	}
+}
+
+func (q *ModerationQueue) staffUsername(uid uint) string {
+	staff, err := q.services.User.GetUserByUID(uid)
+	if err != nil {
+		return fmt.Sprintf("UID %d", uid)
+	}
+	return staff.Username
+}
+
+func (q *ModerationQueue) reportMessage(report *models.ReportWithDetails, resolution string) (*discordgo.MessageEmbed, []discordgo.MessageComponent) {
+	status := "Open"
+	color := 0xFFA500
+	if report.Handled {
+		status = fmt.Sprintf("Handled by %s", q.staffUsername(report.HandledBy))
+		if resolution != "" {
+			status = fmt.Sprintf("%s by %s", resolution, q.staffUsername(report.HandledBy))
+		}
+		color = 0x22C55E
+	} else if report.HandledBy != 0 {
+		status = fmt.Sprintf("Claimed by %s", q.staffUsername(report.HandledBy))
+		color = 0x3B82F6
+	}
+
+	details := report.Details
+	if details == "" {
+		details = "No details provided"
+	} else if len(details) > 1000 {
+		details = details[:1000] + "..."
+	}
+
+	activePunishment := "No"
+	if report.HasActivePunishment {
+		activePunishment = "Yes"
+	}
+
+	embed := &discordgo.MessageEmbed{
+		URL:   fmt.Sprintf("https://cutz.lol/%s", report.ReportedUsername),
+		Title: fmt.Sprintf("Report #%d: %s", report.ID, report.Reason),
+		Color: color,
+		Fields: []*discordgo.MessageEmbedField{
+			{Name: "Reported User", Value: fmt.Sprintf("**%s** (UID: %d)", report.ReportedUsername, report.ReportedUserID), Inline: true},
+			{Name: "Reporter", Value: fmt.Sprintf("**%s** (UID: %d)", report.ReporterUsername, report.ReporterUserID), Inline: true},
+			{Name: "Total Reports", Value: fmt.Sprintf("%d", report.TotalReports), Inline: true},
+			{Name: "Details", Value: details},
+			{Name: "Active Punishment", Value: activePunishment, Inline: true},
+			{Name: "Status", Value: status, Inline: true},
+		},
+		Footer: &discordgo.MessageEmbedFooter{
+			Text: "cutz.lol moderation queue",
+		},
+		Timestamp: report.CreatedAt.Format(time.RFC3339),
+	}
+
+	id := strconv.FormatUint(uint64(report.ID), 10)
+	return embed, queueComponents("report", id, report.ReportedUsername, report.HandledBy != 0, report.Handled)
+}
+
+func (q *ModerationQueue) altAlertMessage(eventID string, alert *altAlert) (*discordgo.MessageEmbed, []discordgo.MessageComponent) {
+	altData := alert.Data
+
+	altUsernamesFormatted := make([]string, 0, len(altData.AltAccounts))
+
+	for _, alt := range altData.AltAccounts {
+		altText := fmt.Sprintf("**%s** (UID: %d)", alt.Username, alt.UID)
+		if alt.MatchReason != "" {
+			altText += fmt.Sprintf(" - *%s*", alt.MatchReason)
+		}
+		altUsernamesFormatted = append(altUsernamesFormatted, altText)
+	}
+
+	fields := []*discordgo.MessageEmbedField{
+		{
+			Name:   "User",
+			Value:  fmt.Sprintf("**%s** (UID: %d)", altData.Username, altData.UID),
+			Inline: true,
+		},
+		{
+			Name:   "Shared IP",
+			Value:  fmt.Sprintf("`%s`", altData.IPAddress),
+			Inline: true,
+		},
+		{
+			Name:   "Detection Time",
+			Value:  fmt.Sprintf("<t:%d:R>", alert.DetectedAt.Unix()),
+			Inline: true,
+		},
+	}
+
+	if len(altUsernamesFormatted) > 0 {
+		fields = append(fields, &discordgo.MessageEmbedField{
+			Name:   "Potential Alt Accounts",
+			Value:  strings.Join(altUsernamesFormatted, "\n"),
+			Inline: false,
+		})
+	}
+
+	if altData.Notes != "" {
+		fields = append(fields, &discordgo.MessageEmbedField{
+			Name:   "Notes",
+			Value:  altData.Notes,
+			Inline: false,
+		})
+	}
+
+	if altData.Confidence > 0 {
+		confidenceEmoji := "🟨"
+		if altData.Confidence >= 0.8 {
+			confidenceEmoji = "🟥"
+		} else if altData.Confidence < 0.5 {
+			confidenceEmoji = "🟩"
+		}
+
+		fields = append(fields, &discordgo.MessageEmbedField{
+			Name:   "Confidence",
+			Value:  fmt.Sprintf("%s %.0f%%", confidenceEmoji, altData.Confidence*100),
+			Inline: true,
+		})
+	}
+
+	status := "Open"
+	color := 0xFFA500
+	if alert.Resolved {
+		status = alert.Resolution
+		color = 0x22C55E
+	} else if alert.ClaimedBy != "" {
+		status = fmt.Sprintf("Claimed by %s", alert.ClaimedBy)
+		color = 0x3B82F6
+	}
+
+	fields = append(fields, &discordgo.MessageEmbedField{
+		Name:   "Status",
+		Value:  status,
+		Inline: true,
+	})
+
+	title := "Potential Alt Account Detected"
+	description := "A potential alt account has been detected during "
+
+	isNewRegistration := altData.DetectionSource == string(models.EventUserRegistered)
+	if isNewRegistration {
+		description += "registration."
+		title = "⚠️ " + title + " (New Registration)"
+	} else if altData.DetectionSource == string(models.EventUserLoggedIn) {
+		description += "login with a new IP."
+		title = "🔍 " + title + " (Login)"
+	} else {
+		description += altData.DetectionSource + "."
+		title = "🔎 " + title
+	}
+
+	embed := &discordgo.MessageEmbed{
+		URL:         fmt.Sprintf("https://cutz.lol/%s", altData.Username),
+		Title:       title,
+		Description: description,
+		Color:       color,
+		Fields:      fields,
+		Footer: &discordgo.MessageEmbedFooter{
+			Text: "cutz.lol security",
+		},
+		Timestamp: alert.DetectedAt.Format(time.RFC3339),
+	}
+
+	return embed, queueComponents("alt", eventID, altData.Username, alert.ClaimedBy != "", alert.Resolved)
+}
+
+/* The current media is shown as the thumbnail and the new media as the image */
+func (q *ModerationQueue) badgeEditMessage(edit *models.BadgeEditWithDetails) (*discordgo.MessageEmbed, []discordgo.MessageComponent) {
+	name := fmt.Sprintf("%s (unchanged)", edit.OldName)
+	if edit.NewName != "" {
+		name = fmt.Sprintf("%s → **%s**", edit.OldName, edit.NewName)
+	}
+
+	media := "Unchanged"
+	if edit.NewMediaURL != "" {
+		media = fmt.Sprintf("[Before](%s) → [After](%s)", edit.OldMediaURL, edit.NewMediaURL)
+		if edit.OldMediaURL == "" {
+			media = fmt.Sprintf("None → [After](%s)", edit.NewMediaURL)
+		}
+	}
+
+	status := "Pending"
+	color := 0xFFA500
+	switch edit.Status {
+	case models.BadgeEditApproved:
+		status = fmt.Sprintf("Approved by %s", edit.ReviewerUsername)
+		color = 0x22C55E
+	case models.BadgeEditRejected:
+		status = fmt.Sprintf("Rejected by %s", edit.ReviewerUsername)
+		if edit.Reason != "" {
+			status += fmt.Sprintf(": %s", edit.Reason)
+		}
+		color = 0xEF4444
+	}
+
+	embed := &discordgo.MessageEmbed{
+		URL:   fmt.Sprintf("https://cutz.lol/%s", edit.Username),
+		Title: fmt.Sprintf("Custom Badge Edit #%d", edit.ID),
+		Color: color,
+		Fields: []*discordgo.MessageEmbedField{
+			{Name: "User", Value: fmt.Sprintf("**%s** (UID: %d)", edit.Username, edit.UID), Inline: true},
+			{Name: "Badge ID", Value: fmt.Sprintf("%d", edit.BadgeID), Inline: true},
+			{Name: "Status", Value: status, Inline: true},
+			{Name: "Name", Value: name},
+			{Name: "Media", Value: media},
+		},
+		Footer: &discordgo.MessageEmbedFooter{
+			Text: "cutz.lol moderation queue",
+		},
+		Timestamp: edit.CreatedAt.Format(time.RFC3339),
+	}
+
+	if edit.OldMediaURL != "" {
+		embed.Thumbnail = &discordgo.MessageEmbedThumbnail{URL: edit.OldMediaURL}
+	}
+	if edit.NewMediaURL != "" {
+		embed.Image = &discordgo.MessageEmbedImage{URL: edit.NewMediaURL}
+	}
+
+	if edit.Status != models.BadgeEditPending {
+		return embed, []discordgo.MessageComponent{
+			discordgo.ActionsRow{Components: []discordgo.MessageComponent{profileButton(edit.Username)}},
+		}
+	}
+
+	id := strconv.FormatUint(uint64(edit.ID), 10)
+	return embed, []discordgo.MessageComponent{
+		discordgo.ActionsRow{
+			Components: []discordgo.MessageComponent{
+				discordgo.Button{
+					Label:    "Approve",
+					Style:    discordgo.SuccessButton,
+					CustomID: queueCustomID("badgeedit", "approve", id),
+				},
+				discordgo.Button{
+					Label:    "Reject",
+					Style:    discordgo.DangerButton,
+					CustomID: queueCustomID("badgeedit", "reject", id),
+				},
+				profileButton(edit.Username),
+			},
+		},
+	}
+}
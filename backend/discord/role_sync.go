@@ -0,0 +1,437 @@
+package discord
+
+import (
+	"errors"
+	"log"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/bwmarrin/discordgo"
+	"github.com/hazebio/haze.bio_backend/models"
+	"github.com/hazebio/haze.bio_backend/utils"
+	"gorm.io/gorm"
+)
+
+const (
+	// Full passes only catch drift the events missed, so they run rarely
+	roleSyncFullInterval = 6 * time.Hour
+	// Minimum gap between Discord REST calls made by the sync
+	roleSyncCallInterval = 250 * time.Millisecond
+	roleSyncPageSize     = 1000
+
+	roleSyncTriggerFull = "full"
+)
+
+type roleSyncKey struct {
+	guildID   string
+	discordID string
+}
+
+type roleSyncJob struct {
+	trigger string
+	// Roles to strip even if no badge maps to them anymore
+	revoke []string
+}
+
+/*
+RoleSync keeps the roles of every configured guild in step with badges and
+Discord links. Members are queued by events and reconciled one at a time
+behind a shared rate limit; a periodic full pass corrects anything the
+events missed.
+*/
+type RoleSync struct {
+	services *ServiceManager
+	session  *discordgo.Session
+	limiter  *time.Ticker
+
+	mu      sync.Mutex
+	pending map[roleSyncKey]*roleSyncJob
+	wake    chan struct{}
+	// Guilds with a full pass in progress
+	running map[string]bool
+}
+
+func NewRoleSync(services *ServiceManager, session *discordgo.Session) *RoleSync {
+	return &RoleSync{
+		services: services,
+		session:  session,
+		limiter:  time.NewTicker(roleSyncCallInterval),
+		pending:  map[roleSyncKey]*roleSyncJob{},
+		wake:     make(chan struct{}, 1),
+		running:  map[string]bool{},
+	}
+}
+
+/* Start the queue worker and the periodic full reconcile */
+func (rs *RoleSync) Run() {
+	go rs.work()
+
+	rs.FullReconcile()
+
+	ticker := time.NewTicker(roleSyncFullInterval)
+	defer ticker.Stop()
+
+	for range ticker.C {
+		rs.FullReconcile()
+	}
+}
+
+/* Queue a Discord account for reconciliation in every configured guild */
+func (rs *RoleSync) Enqueue(discordID, trigger string, revoke ...string) {
+	for _, guildID := range rs.services.Guild.GuildIDs() {
+		rs.EnqueueMember(guildID, discordID, trigger, revoke...)
+	}
+}
+
+/* Queue a member of one guild, merging with any pending job */
+func (rs *RoleSync) EnqueueMember(guildID, discordID, trigger string, revoke ...string) {
+	if guildID == "" || discordID == "" {
+		return
+	}
+
+	key := roleSyncKey{guildID: guildID, discordID: discordID}
+
+	rs.mu.Lock()
+	job, ok := rs.pending[key]
+	if !ok {
+		job = &roleSyncJob{trigger: trigger}
+		rs.pending[key] = job
+	}
+	job.revoke = append(job.revoke, revoke...)
+	utils.RoleSyncQueueLength.Set(float64(len(rs.pending)))
+	rs.mu.Unlock()
+
+	select {
+	case rs.wake <- struct{}{}:
+	default:
+	}
+}
+
+/* Queue the Discord account linked to a user */
+func (rs *RoleSync) EnqueueUser(uid uint, trigger string) {
+	user, err := rs.services.User.GetUserByUIDNoCache(uid)
+	if err != nil || user.DiscordID == "" {
+		return
+	}
+
+	rs.Enqueue(user.DiscordID, trigger)
+}
+
+/* Queue every linked holder of a badge in a guild, e.g. after its role mappings change */
+func (rs *RoleSync) EnqueueBadgeHolders(guildID, badgeName, trigger string, revoke ...string) error {
+	discordIDs, err := rs.services.Badge.GetBadgeHolderDiscordIDs(badgeName)
+	if err != nil {
+		return err
+	}
+
+	for _, discordID := range discordIDs {
+		rs.EnqueueMember(guildID, discordID, trigger, revoke...)
+	}
+
+	return nil
+}
+
+/* Walk every member of every enabled guild and correct their roles */
+func (rs *RoleSync) FullReconcile() {
+	guilds, err := rs.services.Guild.GetEnabledGuilds()
+	if err != nil {
+		log.Printf("Role sync: error loading guilds: %v", err)
+		utils.RoleSyncErrors.Inc()
+		return
+	}
+
+	for i := range guilds {
+		rs.ReconcileGuild(&guilds[i])
+	}
+}
+
+/* Walk every member of a guild and correct their roles, unless a pass is already running */
+func (rs *RoleSync) ReconcileGuild(guild *models.DiscordGuild) {
+	rs.mu.Lock()
+	if rs.running[guild.GuildID] {
+		rs.mu.Unlock()
+		return
+	}
+	rs.running[guild.GuildID] = true
+	rs.mu.Unlock()
+
+	defer func() {
+		rs.mu.Lock()
+		delete(rs.running, guild.GuildID)
+		rs.mu.Unlock()
+	}()
+
+	start := time.Now()
+
+	managed, err := rs.managedRoles(guild)
+	if err != nil {
+		log.Printf("Role sync: error loading managed roles of %s: %v", guild.GuildID, err)
+		utils.RoleSyncErrors.Inc()
+		return
+	}
+
+	checked, drifted := 0, 0
+	after := ""
+	for {
+		rs.throttle()
+		members, err := rs.session.GuildMembers(guild.GuildID, after, roleSyncPageSize)
+		if err != nil {
+			log.Printf("Role sync: error listing members of %s: %v", guild.GuildID, err)
+			utils.RoleSyncErrors.Inc()
+			break
+		}
+
+		for _, member := range members {
+			changed, err := rs.reconcileMember(guild, member, managed, roleSyncTriggerFull)
+			if err != nil {
+				log.Printf("Role sync: error reconciling %s in %s: %v", member.User.ID, guild.GuildID, err)
+				utils.RoleSyncErrors.Inc()
+				continue
+			}
+
+			checked++
+			if changed {
+				drifted++
+			}
+		}
+
+		if len(members) < roleSyncPageSize {
+			break
+		}
+		after = members[len(members)-1].User.ID
+	}
+
+	duration := time.Since(start)
+	utils.RoleSyncDriftedMembers.WithLabelValues(guild.GuildID).Set(float64(drifted))
+	utils.RoleSyncFullReconcileDuration.WithLabelValues(guild.GuildID).Set(duration.Seconds())
+
+	log.Printf("Role sync: full reconcile of %s checked %d members, corrected %d in %s", guild.Name, checked, drifted, duration.Round(time.Second))
+}
+
+func (rs *RoleSync) work() {
+	for range rs.wake {
+		for {
+			key, job, ok := rs.next()
+			if !ok {
+				break
+			}
+
+			if err := rs.reconcile(key, job); err != nil {
+				log.Printf("Role sync: error reconciling %s in %s: %v", key.discordID, key.guildID, err)
+				utils.RoleSyncErrors.Inc()
+			}
+		}
+	}
+}
+
+func (rs *RoleSync) next() (roleSyncKey, *roleSyncJob, bool) {
+	rs.mu.Lock()
+	defer rs.mu.Unlock()
+
+	for key, job := range rs.pending {
+		delete(rs.pending, key)
+		utils.RoleSyncQueueLength.Set(float64(len(rs.pending)))
+		return key, job, true
+	}
+
+	return roleSyncKey{}, nil, false
+}
+
+func (rs *RoleSync) reconcile(key roleSyncKey, job *roleSyncJob) error {
+	guild, err := rs.services.Guild.GetGuild(key.guildID)
+	if err != nil || !guild.IsEnabled() {
+		// Guilds removed from the config are left alone
+		return nil
+	}
+
+	member, err := rs.member(guild.GuildID, key.discordID)
+	if err != nil || member == nil {
+		return err
+	}
+
+	managed, err := rs.managedRoles(guild)
+	if err != nil {
+		return err
+	}
+
+	for _, roleID := range job.revoke {
+		managed[roleID] = true
+	}
+
+	_, err = rs.reconcileMember(guild, member, managed, job.trigger)
+	return err
+}
+
+/*
+Bring a member's managed roles in line with what the database says they
+should hold. Roles no badge maps to are never touched. Returns whether any
+change was needed.
+*/
+func (rs *RoleSync) reconcileMember(guild *models.DiscordGuild, member *discordgo.Member, managed map[string]bool, trigger string) (bool, error) {
+	if member.User == nil || member.User.Bot {
+		return false, nil
+	}
+
+	user, err := rs.linkedUser(member.User.ID)
+	if err != nil {
+		return false, err
+	}
+
+	desired := map[string]bool{}
+	if user != nil {
+		if err := rs.syncBoosterBadge(guild, user, member); err != nil {
+			return false, err
+		}
+
+		roleIDs, err := rs.services.Badge.GetUserRoleIDs(user.UID, guild.GuildID)
+		if err != nil {
+			return false, err
+		}
+
+		for _, roleID := range roleIDs {
+			desired[roleID] = true
+		}
+
+		if guild.LinkedRoleID != "" {
+			desired[guild.LinkedRoleID] = true
+		}
+	}
+
+	current := map[string]bool{}
+	roles := []string{}
+	for _, roleID := range member.Roles {
+		current[roleID] = true
+
+		if managed[roleID] && !desired[roleID] {
+			rs.throttle()
+			if err := rs.session.GuildMemberRoleRemove(guild.GuildID, member.User.ID, roleID); err != nil {
+				log.Printf("Role sync: error removing role %s from %s: %v", roleID, member.User.ID, err)
+				utils.RoleSyncErrors.Inc()
+				roles = append(roles, roleID)
+				continue
+			}
+
+			utils.RoleSyncChanges.WithLabelValues(guild.GuildID, "removed", trigger).Inc()
+			continue
+		}
+
+		roles = append(roles, roleID)
+	}
+
+	changed := len(roles) != len(member.Roles)
+	for roleID := range desired {
+		if current[roleID] {
+			continue
+		}
+
+		rs.throttle()
+		if err := rs.session.GuildMemberRoleAdd(guild.GuildID, member.User.ID, roleID); err != nil {
+			log.Printf("Role sync: error adding role %s to %s: %v", roleID, member.User.ID, err)
+			utils.RoleSyncErrors.Inc()
+			continue
+		}
+
+		utils.RoleSyncChanges.WithLabelValues(guild.GuildID, "added", trigger).Inc()
+		roles = append(roles, roleID)
+		changed = true
+	}
+
+	if changed {
+		// Update the cached member so events queued before Discord echoes
+		// the change back don't redo it
+		updated := *member
+		updated.GuildID = guild.GuildID
+		updated.Roles = roles
+		rs.session.State.MemberAdd(&updated)
+	}
+
+	return changed, nil
+}
+
+/* Give boosters the guild's booster badge and take it away once they stop boosting */
+func (rs *RoleSync) syncBoosterBadge(guild *models.DiscordGuild, user *models.User, member *discordgo.Member) error {
+	if guild.BoosterBadge == "" {
+		return nil
+	}
+
+	badges, err := rs.services.Badge.GetUserBadges(user.UID)
+	if err != nil {
+		return err
+	}
+
+	boosting := member.PremiumSince != nil
+	hasBooster := hasBadge(badges, guild.BoosterBadge)
+
+	if boosting && !hasBooster {
+		if err := rs.services.Badge.AssignBadge(user.UID, guild.BoosterBadge); err != nil {
+			return err
+		}
+
+		if guild.BoosterEditCredits > 0 {
+			return rs.services.User.AddBadgeEditCredits(user.UID, guild.BoosterEditCredits)
+		}
+		return nil
+	}
+
+	if !boosting && hasBooster {
+		return rs.services.Badge.RemoveBadge(user.UID, guild.BoosterBadge)
+	}
+
+	return nil
+}
+
+func (rs *RoleSync) managedRoles(guild *models.DiscordGuild) (map[string]bool, error) {
+	roleIDs, err := rs.services.Badge.GetManagedRoleIDs(guild.GuildID)
+	if err != nil {
+		return nil, err
+	}
+
+	managed := map[string]bool{}
+	for _, roleID := range roleIDs {
+		managed[roleID] = true
+	}
+
+	if guild.LinkedRoleID != "" {
+		managed[guild.LinkedRoleID] = true
+	}
+
+	return managed, nil
+}
+
+/* Look up the user linked to a Discord account, nil if there is none */
+func (rs *RoleSync) linkedUser(discordID string) (*models.User, error) {
+	var user models.User
+	err := rs.services.User.DB.Where("discord_id = ?", discordID).First(&user).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return &user, nil
+}
+
+/* Fetch a guild member from state, falling back to the API. Nil if they are not in the guild */
+func (rs *RoleSync) member(guildID, discordID string) (*discordgo.Member, error) {
+	if member, err := rs.session.State.Member(guildID, discordID); err == nil {
+		return member, nil
+	}
+
+	rs.throttle()
+	member, err := rs.session.GuildMember(guildID, discordID)
+	if err != nil {
+		var restErr *discordgo.RESTError
+		if errors.As(err, &restErr) && restErr.Response != nil && restErr.Response.StatusCode == http.StatusNotFound {
+			return nil, nil
+		}
+		return nil, err
+	}
+
+	return member, nil
+}
+
+func (rs *RoleSync) throttle() {
+	<-rs.limiter.C
+}
@@ -16,4 +16,6 @@ type ServiceManager struct {
 	AltAccount   *services.AltAccountService
 	Event        *services.EventService
 	Invite       *services.InviteService
+	Apply        *services.ApplyService
+	Guild        *services.GuildService
 }
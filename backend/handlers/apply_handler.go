@@ -6,6 +6,7 @@ import (
 	"net/http"
 	"regexp"
 	"strconv"
+	"strings"
 
 	"github.com/gorilla/mux"
 	"github.com/hazebio/haze.bio_backend/middlewares"
@@ -68,6 +69,11 @@ func (ah *ApplyHandler) StartApplication(w http.ResponseWriter, r *http.Request)
 			return
 		}
 
+		if err.Error() == "position not found" || err.Error() == "position is not accepting applications" {
+			utils.RespondError(w, http.StatusBadRequest, "This position is not accepting applications")
+			return
+		}
+
 		if matched, _ := regexp.MatchString(`you were recently rejected.*wait \d+ more days`, err.Error()); matched {
 			utils.RespondError(w, http.StatusTooManyRequests, err.Error())
 			return
@@ -85,10 +91,11 @@ func (ah *ApplyHandler) SaveAnswer(w http.ResponseWriter, r *http.Request) {
 	userID := middlewares.GetUserIDFromContext(r.Context())
 
 	var request struct {
-		PositionID string `json:"position_id"`
-		QuestionID string `json:"question_id"`
-		Answer     string `json:"answer"`
-		TimeSpent  int64  `json:"time_spent"` // Time in seconds
+		PositionID  string `json:"position_id"`
+		QuestionID  string `json:"question_id"`
+		Answer      string `json:"answer"`
+		TimeSpent   int64  `json:"time_spent"`   // Time in seconds
+		PasteEvents int    `json:"paste_events"` // Number of paste events since the last save
 	}
 
 	if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
@@ -101,8 +108,13 @@ func (ah *ApplyHandler) SaveAnswer(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	session, err := ah.ApplyService.SaveAnswer(userID, request.PositionID, request.QuestionID, request.Answer, request.TimeSpent)
+	session, err := ah.ApplyService.SaveAnswer(userID, request.PositionID, request.QuestionID, request.Answer, request.TimeSpent, request.PasteEvents)
 	if err != nil {
+		if strings.HasPrefix(err.Error(), "invalid answer") || err.Error() == "question not found" {
+			utils.RespondError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+
 		log.Printf("Error saving answer: %v", err)
 		utils.RespondError(w, http.StatusInternalServerError, "Failed to save answer")
 		return
@@ -277,7 +289,7 @@ func (ah *ApplyHandler) GetApplicationDetail(w http.ResponseWriter, r *http.Requ
 		return
 	}
 
-	position := ah.ApplyService.GetPositionByID(application.PositionID)
+	position := ah.ApplyService.GetPositionVersion(application.PositionID, application.FormVersion)
 	if position == nil {
 		utils.RespondError(w, http.StatusNotFound, "Position not found")
 		return
@@ -300,9 +312,13 @@ func (ah *ApplyHandler) GetApplicationDetail(w http.ResponseWriter, r *http.Requ
 			AvatarURL   string `json:"avatar_url,omitempty"`
 			MemberSince string `json:"member_since"`
 		} `json:"applicant"`
-		Position  models.Position    `json:"position"`
-		Responses []enrichedResponse `json:"responses"`
-		Reviewer  *struct {
+		Position      models.Position                  `json:"position"`
+		Responses     []enrichedResponse               `json:"responses"`
+		Reviews       []*enrichedReview                `json:"reviews"`
+		ReviewSummary *models.ApplicationReviewSummary `json:"review_summary"`
+		Rubric        map[int]string                   `json:"rubric"`
+		Risk          *models.ApplicationRiskSummary   `json:"risk"`
+		Reviewer      *struct {
 			UID         uint   `json:"uid"`
 			Username    string `json:"username"`
 			DisplayName string `json:"display_name"`
@@ -312,6 +328,22 @@ func (ah *ApplyHandler) GetApplicationDetail(w http.ResponseWriter, r *http.Requ
 	enriched := enrichedApplication{
 		Application: *application,
 		Position:    *position,
+		Rubric:      models.ApplicationRubric,
+	}
+
+	reviews, err := ah.ApplyService.GetReviews(application.ID)
+	if err != nil {
+		log.Printf("Error getting application reviews: %v", err)
+		utils.RespondError(w, http.StatusInternalServerError, "Failed to retrieve application reviews")
+		return
+	}
+
+	enriched.Reviews = ah.enrichReviews(reviews)
+	enriched.ReviewSummary, _ = ah.ApplyService.GetReviewSummary(application.ID)
+
+	enriched.Risk, err = ah.ApplyService.GetRiskSummary(application)
+	if err != nil {
+		log.Printf("Error getting application risk summary: %v", err)
 	}
 
 	enriched.Applicant.UID = applicant.UID
@@ -338,7 +370,7 @@ func (ah *ApplyHandler) GetApplicationDetail(w http.ResponseWriter, r *http.Requ
 	}
 
 	for _, resp := range application.Responses {
-		question := models.GetQuestionByID(application.PositionID, resp.QuestionID)
+		question := position.GetQuestion(resp.QuestionID)
 		if question == nil {
 			continue
 		}
@@ -406,3 +438,199 @@ func (ah *ApplyHandler) ReviewApplication(w http.ResponseWriter, r *http.Request
 
 	utils.RespondSuccess(w, "Application reviewed successfully", nil)
 }
+
+/* Get all positions including inactive ones */
+func (ah *ApplyHandler) GetAllPositions(w http.ResponseWriter, r *http.Request) {
+	positions, err := ah.ApplyService.GetAllPositions()
+	if err != nil {
+		log.Printf("Error getting positions: %v", err)
+		utils.RespondError(w, http.StatusInternalServerError, "Something went wrong. Please try again later")
+		return
+	}
+
+	utils.RespondSuccess(w, "Positions retrieved successfully", positions)
+}
+
+/* Create a position */
+func (ah *ApplyHandler) CreatePosition(w http.ResponseWriter, r *http.Request) {
+	var position models.Position
+	if err := json.NewDecoder(r.Body).Decode(&position); err != nil {
+		utils.RespondError(w, http.StatusBadRequest, "Invalid request format")
+		return
+	}
+
+	userID := middlewares.GetUserIDFromContext(r.Context())
+	if err := ah.ApplyService.CreatePosition(&position, userID); err != nil {
+		utils.RespondError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	utils.RespondSuccess(w, "Position created successfully", position)
+}
+
+/* Update the details of a position */
+func (ah *ApplyHandler) UpdatePosition(w http.ResponseWriter, r *http.Request) {
+	positionID := mux.Vars(r)["id"]
+
+	var fields map[string]interface{}
+	if err := json.NewDecoder(r.Body).Decode(&fields); err != nil {
+		utils.RespondError(w, http.StatusBadRequest, "Invalid request format")
+		return
+	}
+
+	if err := ah.ApplyService.UpdatePosition(positionID, fields); err != nil {
+		if err.Error() == "position not found" {
+			utils.RespondError(w, http.StatusNotFound, "Position not found")
+			return
+		}
+
+		utils.RespondError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	utils.RespondSuccess(w, "Position updated successfully", nil)
+}
+
+/* Publish a new version of the questions of a position */
+func (ah *ApplyHandler) UpdatePositionQuestions(w http.ResponseWriter, r *http.Request) {
+	positionID := mux.Vars(r)["id"]
+
+	var request struct {
+		Questions []models.Question `json:"questions"`
+	}
+
+	if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+		utils.RespondError(w, http.StatusBadRequest, "Invalid request format")
+		return
+	}
+
+	userID := middlewares.GetUserIDFromContext(r.Context())
+	version, err := ah.ApplyService.UpdateQuestions(positionID, request.Questions, userID)
+	if err != nil {
+		if err.Error() == "position not found" {
+			utils.RespondError(w, http.StatusNotFound, "Position not found")
+			return
+		}
+
+		utils.RespondError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	utils.RespondSuccess(w, "Questions updated successfully", map[string]int{"version": version})
+}
+
+/* Get the question history of a position */
+func (ah *ApplyHandler) GetPositionVersions(w http.ResponseWriter, r *http.Request) {
+	positionID := mux.Vars(r)["id"]
+
+	versions, err := ah.ApplyService.GetFormVersions(positionID)
+	if err != nil {
+		log.Printf("Error getting position versions: %v", err)
+		utils.RespondError(w, http.StatusInternalServerError, "Something went wrong. Please try again later")
+		return
+	}
+
+	utils.RespondSuccess(w, "Position versions retrieved successfully", versions)
+}
+
+/* Delete a position without applications */
+func (ah *ApplyHandler) DeletePosition(w http.ResponseWriter, r *http.Request) {
+	positionID := mux.Vars(r)["id"]
+
+	if err := ah.ApplyService.DeletePosition(positionID); err != nil {
+		if err.Error() == "position not found" {
+			utils.RespondError(w, http.StatusNotFound, "Position not found")
+			return
+		}
+
+		utils.RespondError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	utils.RespondSuccess(w, "Position deleted successfully", nil)
+}
+
+type enrichedReview struct {
+	models.ApplicationReview
+	ReviewerUsername    string `json:"reviewer_username"`
+	ReviewerDisplayName string `json:"reviewer_display_name"`
+}
+
+func (ah *ApplyHandler) enrichReviews(reviews []models.ApplicationReview) []*enrichedReview {
+	enrichedReviews := make([]*enrichedReview, 0, len(reviews))
+	for _, review := range reviews {
+		enriched := &enrichedReview{ApplicationReview: review}
+
+		reviewer, err := ah.UserService.GetUserByUID(review.ReviewerID)
+		if err == nil {
+			enriched.ReviewerUsername = reviewer.Username
+			enriched.ReviewerDisplayName = reviewer.DisplayName
+		}
+
+		enrichedReviews = append(enrichedReviews, enriched)
+	}
+	return enrichedReviews
+}
+
+/* Get the reviews and aggregated scores of an application */
+func (ah *ApplyHandler) GetApplicationReviews(w http.ResponseWriter, r *http.Request) {
+	appID := utils.StringToUint(mux.Vars(r)["id"])
+	if appID == 0 {
+		utils.RespondError(w, http.StatusBadRequest, "Invalid application ID")
+		return
+	}
+
+	reviews, err := ah.ApplyService.GetReviews(appID)
+	if err != nil {
+		log.Printf("Error getting application reviews: %v", err)
+		utils.RespondError(w, http.StatusInternalServerError, "Failed to retrieve application reviews")
+		return
+	}
+
+	summary, err := ah.ApplyService.GetReviewSummary(appID)
+	if err != nil {
+		log.Printf("Error getting application review summary: %v", err)
+		utils.RespondError(w, http.StatusInternalServerError, "Failed to retrieve application reviews")
+		return
+	}
+
+	utils.RespondSuccess(w, "Application reviews retrieved successfully", map[string]interface{}{
+		"reviews": ah.enrichReviews(reviews),
+		"summary": summary,
+		"rubric":  models.ApplicationRubric,
+	})
+}
+
+/* Score the answers of an application and vote on it */
+func (ah *ApplyHandler) SubmitApplicationReview(w http.ResponseWriter, r *http.Request) {
+	appID := utils.StringToUint(mux.Vars(r)["id"])
+	if appID == 0 {
+		utils.RespondError(w, http.StatusBadRequest, "Invalid application ID")
+		return
+	}
+
+	var request struct {
+		Vote    models.ReviewVote `json:"vote"`
+		Comment string            `json:"comment"`
+		Scores  map[string]int    `json:"scores"`
+	}
+
+	if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+		utils.RespondError(w, http.StatusBadRequest, "Invalid request format")
+		return
+	}
+
+	userID := middlewares.GetUserIDFromContext(r.Context())
+	summary, err := ah.ApplyService.SubmitReview(appID, userID, request.Vote, request.Comment, request.Scores)
+	if err != nil {
+		if err.Error() == "application not found" {
+			utils.RespondError(w, http.StatusNotFound, "Application not found")
+			return
+		}
+
+		utils.RespondError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	utils.RespondSuccess(w, "Review submitted successfully", summary)
+}
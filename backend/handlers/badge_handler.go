@@ -8,17 +8,20 @@ import (
 
 	"github.com/gorilla/mux"
 	"github.com/hazebio/haze.bio_backend/middlewares"
+	"github.com/hazebio/haze.bio_backend/models"
 	"github.com/hazebio/haze.bio_backend/services"
 	"github.com/hazebio/haze.bio_backend/utils"
 )
 
 type BadgeHandler struct {
-	BadgeService *services.BadgeService
+	BadgeService     *services.BadgeService
+	BadgeRuleService *services.BadgeRuleService
 }
 
-func NewBadgeHandler(badgeService *services.BadgeService) *BadgeHandler {
+func NewBadgeHandler(badgeService *services.BadgeService, badgeRuleService *services.BadgeRuleService) *BadgeHandler {
 	return &BadgeHandler{
-		BadgeService: badgeService,
+		BadgeService:     badgeService,
+		BadgeRuleService: badgeRuleService,
 	}
 }
 
@@ -73,25 +76,40 @@ func (bh *BadgeHandler) EditCustomBadge(w http.ResponseWriter, r *http.Request)
 		return
 	}
 
-	if err := bh.BadgeService.EditCustomBadge(uid, badgeID, req.NewName, req.NewMediaURL); err != nil {
-		if err.Error() == "no badge edit credits" {
+	edit, err := bh.BadgeService.EditCustomBadge(uid, badgeID, req.NewName, req.NewMediaURL)
+	if err != nil {
+		switch err.Error() {
+		case "no badge edit credits":
 			utils.RespondError(w, http.StatusNotFound, "You don't have enough badge edit credits")
-			return
-		}
-
-		if err.Error() == "badge name already exists" {
+		case "badge name already exists":
 			utils.RespondError(w, http.StatusNotFound, "Badge name already exists")
-			return
+		case "badge not found":
+			utils.RespondError(w, http.StatusNotFound, "Badge not found")
+		case "badge edit already pending":
+			utils.RespondError(w, http.StatusConflict, "This badge already has an edit waiting for review")
+		case "badge is not a custom badge", "badge name cannot contain HTML", "invalid media url", "nothing to change":
+			utils.RespondError(w, http.StatusBadRequest, err.Error())
+		default:
+			log.Println("Error editing custom badge:", err)
+			utils.RespondError(w, http.StatusInternalServerError, "Something went wrong. Please try again later")
 		}
+		return
+	}
+
+	utils.RespondSuccess(w, "Badge edit submitted for review", edit)
+}
 
-		log.Println("Error editing custom badge:", err)
+/* Get the custom badge edits of the current user */
+func (bh *BadgeHandler) GetUserBadgeEdits(w http.ResponseWriter, r *http.Request) {
+	uid := middlewares.GetUserIDFromContext(r.Context())
+
+	edits, err := bh.BadgeService.GetUserBadgeEdits(uid)
+	if err != nil {
 		utils.RespondError(w, http.StatusInternalServerError, "Something went wrong. Please try again later")
 		return
 	}
 
-	log.Println("Custom badge updated successfully")
-
-	utils.RespondSuccess(w, "Badge updated successfully", nil)
+	utils.RespondSuccess(w, "Badge edits retrieved successfully", edits)
 }
 
 /* Hide user badge */
@@ -124,3 +142,212 @@ func (bh *BadgeHandler) HideUserBadge(w http.ResponseWriter, r *http.Request) {
 
 	utils.RespondSuccess(w, "Badge visibility updated successfully", nil)
 }
+
+/* Get the badge catalog */
+func (bh *BadgeHandler) GetBadgeCatalog(w http.ResponseWriter, r *http.Request) {
+	badges, err := bh.BadgeService.GetBadgeCatalog()
+	if err != nil {
+		utils.RespondError(w, http.StatusInternalServerError, "Something went wrong. Please try again later")
+		return
+	}
+
+	utils.RespondSuccess(w, "Badges retrieved successfully", badges)
+}
+
+/* Create a catalog badge */
+func (bh *BadgeHandler) CreateBadge(w http.ResponseWriter, r *http.Request) {
+	var badge models.Badge
+	if err := json.NewDecoder(r.Body).Decode(&badge); err != nil {
+		utils.RespondError(w, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+
+	badge.ID = 0
+	badge.IsCustom = false
+
+	if err := bh.BadgeService.CreateBadge(&badge); err != nil {
+		utils.RespondError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	utils.RespondSuccess(w, "Badge created successfully", badge)
+}
+
+/* Update the catalog fields of a badge */
+func (bh *BadgeHandler) UpdateBadge(w http.ResponseWriter, r *http.Request) {
+	badgeID := utils.StringToUint(mux.Vars(r)["id"])
+	if badgeID == 0 {
+		utils.RespondError(w, http.StatusBadRequest, "Invalid badge ID")
+		return
+	}
+
+	var fields map[string]interface{}
+	if err := json.NewDecoder(r.Body).Decode(&fields); err != nil {
+		utils.RespondError(w, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+
+	if err := bh.BadgeService.UpdateBadge(badgeID, fields); err != nil {
+		if err.Error() == "badge not found" {
+			utils.RespondError(w, http.StatusNotFound, "Badge not found")
+			return
+		}
+
+		utils.RespondError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	utils.RespondSuccess(w, "Badge updated successfully", nil)
+}
+
+/* Get all badge rules */
+func (bh *BadgeHandler) GetBadgeRules(w http.ResponseWriter, r *http.Request) {
+	rules, err := bh.BadgeRuleService.GetRules()
+	if err != nil {
+		utils.RespondError(w, http.StatusInternalServerError, "Something went wrong. Please try again later")
+		return
+	}
+
+	utils.RespondSuccess(w, "Badge rules retrieved successfully", rules)
+}
+
+/* Create a badge rule */
+func (bh *BadgeHandler) CreateBadgeRule(w http.ResponseWriter, r *http.Request) {
+	var rule models.BadgeRule
+	if err := json.NewDecoder(r.Body).Decode(&rule); err != nil {
+		utils.RespondError(w, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+
+	rule.ID = 0
+
+	if err := bh.BadgeRuleService.CreateRule(&rule); err != nil {
+		utils.RespondError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	utils.RespondSuccess(w, "Badge rule created successfully", rule)
+}
+
+/* Update a badge rule */
+func (bh *BadgeHandler) UpdateBadgeRule(w http.ResponseWriter, r *http.Request) {
+	ruleID := utils.StringToUint(mux.Vars(r)["id"])
+	if ruleID == 0 {
+		utils.RespondError(w, http.StatusBadRequest, "Invalid rule ID")
+		return
+	}
+
+	var fields map[string]interface{}
+	if err := json.NewDecoder(r.Body).Decode(&fields); err != nil {
+		utils.RespondError(w, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+
+	if err := bh.BadgeRuleService.UpdateRule(ruleID, fields); err != nil {
+		if err.Error() == "badge rule not found" {
+			utils.RespondError(w, http.StatusNotFound, "Badge rule not found")
+			return
+		}
+
+		utils.RespondError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	utils.RespondSuccess(w, "Badge rule updated successfully", nil)
+}
+
+/* Delete a badge rule */
+func (bh *BadgeHandler) DeleteBadgeRule(w http.ResponseWriter, r *http.Request) {
+	ruleID := utils.StringToUint(mux.Vars(r)["id"])
+	if ruleID == 0 {
+		utils.RespondError(w, http.StatusBadRequest, "Invalid rule ID")
+		return
+	}
+
+	if err := bh.BadgeRuleService.DeleteRule(ruleID); err != nil {
+		if err.Error() == "badge rule not found" {
+			utils.RespondError(w, http.StatusNotFound, "Badge rule not found")
+			return
+		}
+
+		utils.RespondError(w, http.StatusInternalServerError, "Something went wrong. Please try again later")
+		return
+	}
+
+	utils.RespondSuccess(w, "Badge rule deleted successfully", nil)
+}
+
+/* Evaluate every active badge rule now instead of waiting for the job */
+func (bh *BadgeHandler) EvaluateBadgeRules(w http.ResponseWriter, r *http.Request) {
+	results, err := bh.BadgeRuleService.EvaluateAll()
+	if err != nil {
+		log.Println("Error evaluating badge rules:", err)
+		utils.RespondError(w, http.StatusInternalServerError, "Something went wrong. Please try again later")
+		return
+	}
+
+	utils.RespondSuccess(w, "Badge rules evaluated successfully", results)
+}
+
+/* Get custom badge edits for review, pending by default */
+func (bh *BadgeHandler) GetBadgeEdits(w http.ResponseWriter, r *http.Request) {
+	status := models.BadgeEditStatus(r.URL.Query().Get("status"))
+	if status == "" {
+		status = models.BadgeEditPending
+	}
+
+	if status != models.BadgeEditPending && status != models.BadgeEditApproved && status != models.BadgeEditRejected {
+		utils.RespondError(w, http.StatusBadRequest, "Invalid status")
+		return
+	}
+
+	edits, err := bh.BadgeService.GetBadgeEdits(status)
+	if err != nil {
+		utils.RespondError(w, http.StatusInternalServerError, "Something went wrong. Please try again later")
+		return
+	}
+
+	utils.RespondSuccess(w, "Badge edits retrieved successfully", edits)
+}
+
+/* Approve or reject a custom badge edit */
+func (bh *BadgeHandler) ReviewBadgeEdit(w http.ResponseWriter, r *http.Request) {
+	staffUID := middlewares.GetUserIDFromContext(r.Context())
+
+	editID := utils.StringToUint(mux.Vars(r)["id"])
+	if editID == 0 {
+		utils.RespondError(w, http.StatusBadRequest, "Invalid badge edit ID")
+		return
+	}
+
+	var req struct {
+		Approve bool   `json:"approve"`
+		Reason  string `json:"reason"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		utils.RespondError(w, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+
+	if len(req.Reason) > 500 {
+		utils.RespondError(w, http.StatusBadRequest, "Reason must be at most 500 characters")
+		return
+	}
+
+	edit, err := bh.BadgeService.ReviewBadgeEdit(editID, staffUID, req.Approve, req.Reason)
+	if err != nil {
+		switch err.Error() {
+		case "badge edit not found":
+			utils.RespondError(w, http.StatusNotFound, "Badge edit not found")
+		case "badge edit has already been reviewed":
+			utils.RespondError(w, http.StatusConflict, "Badge edit has already been reviewed")
+		case "badge name already exists":
+			utils.RespondError(w, http.StatusConflict, "Badge name is already taken, reject the edit instead")
+		default:
+			utils.RespondError(w, http.StatusInternalServerError, "Something went wrong. Please try again later")
+		}
+		return
+	}
+
+	utils.RespondSuccess(w, "Badge edit reviewed successfully", edit)
+}
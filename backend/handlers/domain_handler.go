@@ -0,0 +1,190 @@
+package handlers
+
+import (
+	"encoding/json"
+	"log"
+	"net"
+	"net/http"
+	"net/http/httputil"
+	"net/url"
+
+	"github.com/gorilla/mux"
+	"github.com/hazebio/haze.bio_backend/config"
+	"github.com/hazebio/haze.bio_backend/middlewares"
+	"github.com/hazebio/haze.bio_backend/models"
+	"github.com/hazebio/haze.bio_backend/services"
+	"github.com/hazebio/haze.bio_backend/utils"
+)
+
+type DomainHandler struct {
+	DomainService  *services.DomainService
+	ProfileService *services.ProfileService
+
+	frontend *httputil.ReverseProxy
+}
+
+func NewDomainHandler(domainService *services.DomainService, profileService *services.ProfileService) *DomainHandler {
+	origin, err := url.Parse(config.Origin)
+	if err != nil {
+		log.Println("Invalid ORIGIN, custom domains can't be served:", err)
+		origin = &url.URL{}
+	}
+
+	return &DomainHandler{
+		DomainService:  domainService,
+		ProfileService: profileService,
+		frontend: &httputil.ReverseProxy{
+			Rewrite: func(pr *httputil.ProxyRequest) {
+				pr.SetURL(origin)
+				pr.SetXForwarded()
+			},
+		},
+	}
+}
+
+/* Get the custom domain of the current user */
+func (dh *DomainHandler) GetDomain(w http.ResponseWriter, r *http.Request) {
+	uid := middlewares.GetUserIDFromContext(r.Context())
+
+	domain, err := dh.DomainService.GetDomain(uid)
+	if err != nil {
+		if err.Error() == "domain not found" {
+			utils.RespondError(w, http.StatusNotFound, "No custom domain set")
+			return
+		}
+
+		log.Println("Error getting custom domain:", err)
+		utils.RespondError(w, http.StatusInternalServerError, "Something went wrong. Please try again later")
+		return
+	}
+
+	utils.RespondSuccess(w, "Custom domain found", domain)
+}
+
+/* Set the custom domain of the current user */
+func (dh *DomainHandler) AddDomain(w http.ResponseWriter, r *http.Request) {
+	uid := middlewares.GetUserIDFromContext(r.Context())
+
+	var request models.CustomDomainRequest
+	if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+		utils.RespondError(w, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+
+	domain, err := dh.DomainService.AddDomain(uid, request.Domain)
+	if err != nil {
+		switch err.Error() {
+		case "custom domains require premium":
+			utils.RespondError(w, http.StatusForbidden, "Custom domains require premium")
+		case "invalid domain":
+			utils.RespondError(w, http.StatusBadRequest, "Invalid domain")
+		case "domain not allowed":
+			utils.RespondError(w, http.StatusBadRequest, "This domain can't be used")
+		case "domain already in use":
+			utils.RespondError(w, http.StatusConflict, "This domain is already in use")
+		default:
+			log.Println("Error adding custom domain:", err)
+			utils.RespondError(w, http.StatusInternalServerError, "Something went wrong. Please try again later")
+		}
+		return
+	}
+
+	utils.RespondSuccess(w, "Custom domain added, create the verification record to verify it", domain)
+}
+
+/* Check the verification record of the current user's custom domain */
+func (dh *DomainHandler) VerifyDomain(w http.ResponseWriter, r *http.Request) {
+	uid := middlewares.GetUserIDFromContext(r.Context())
+
+	domain, err := dh.DomainService.VerifyDomain(uid)
+	if err != nil {
+		switch err.Error() {
+		case "domain not found":
+			utils.RespondError(w, http.StatusNotFound, "No custom domain set")
+		case "verification checked too recently":
+			utils.RespondError(w, http.StatusTooManyRequests, "Please wait a few seconds before checking again")
+		case "verification record not found":
+			utils.RespondError(w, http.StatusBadRequest, "Verification record not found, DNS changes can take a while to propagate")
+		case "dns lookup failed":
+			utils.RespondError(w, http.StatusBadGateway, "Failed to look up DNS records, please try again later")
+		default:
+			log.Println("Error verifying custom domain:", err)
+			utils.RespondError(w, http.StatusInternalServerError, "Something went wrong. Please try again later")
+		}
+		return
+	}
+
+	utils.RespondSuccess(w, "Custom domain verified", domain)
+}
+
+/* Remove the custom domain of the current user */
+func (dh *DomainHandler) RemoveDomain(w http.ResponseWriter, r *http.Request) {
+	uid := middlewares.GetUserIDFromContext(r.Context())
+
+	if err := dh.DomainService.RemoveDomain(uid); err != nil {
+		if err.Error() == "domain not found" {
+			utils.RespondError(w, http.StatusNotFound, "No custom domain set")
+			return
+		}
+
+		log.Println("Error removing custom domain:", err)
+		utils.RespondError(w, http.StatusInternalServerError, "Something went wrong. Please try again later")
+		return
+	}
+
+	utils.RespondSuccess(w, "Custom domain removed", nil)
+}
+
+/* Host a request was sent to, forwarded hosts aren't trusted so API requests can't be routed to a custom domain */
+func customDomainHost(r *http.Request) string {
+	if host, _, err := net.SplitHostPort(r.Host); err == nil {
+		return host
+	}
+	return r.Host
+}
+
+/* Route matcher for requests made to a verified custom domain */
+func (dh *DomainHandler) MatchCustomDomain(r *http.Request, _ *mux.RouteMatch) bool {
+	_, ok := dh.DomainService.ResolveHost(customDomainHost(r))
+	return ok
+}
+
+/* Answer an ACME HTTP-01 challenge for a custom domain certificate */
+func (dh *DomainHandler) ACMEChallenge(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+
+	response, ok := dh.DomainService.ChallengeResponse(vars["token"])
+	if !ok {
+		http.NotFound(w, r)
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/plain")
+	w.Write([]byte(response))
+}
+
+/* Serve a custom domain from the frontend, its root is the profile the domain points at */
+func (dh *DomainHandler) ServeCustomDomain(w http.ResponseWriter, r *http.Request) {
+	if r.URL.Path != "/" {
+		dh.frontend.ServeHTTP(w, r)
+		return
+	}
+
+	user, err := dh.ProfileService.GetPublicProfileByDomain(customDomainHost(r))
+	if err != nil || user.HasActivePunishment() {
+		if err != nil && err.Error() != "profile not found" {
+			log.Println("Error getting custom domain profile:", err)
+		}
+
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		w.WriteHeader(http.StatusNotFound)
+		w.Write(services.RenderProfileNotFoundPage())
+		return
+	}
+
+	profileRequest := r.Clone(r.Context())
+	profileRequest.URL.Path = "/" + url.PathEscape(user.Username)
+	profileRequest.URL.RawPath = ""
+
+	dh.frontend.ServeHTTP(w, profileRequest)
+}
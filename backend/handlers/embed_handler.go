@@ -0,0 +1,82 @@
+package handlers
+
+import (
+	"encoding/json"
+	"log"
+	"net/http"
+	"strconv"
+
+	"github.com/gorilla/mux"
+	"github.com/hazebio/haze.bio_backend/services"
+	"github.com/hazebio/haze.bio_backend/utils"
+)
+
+type EmbedHandler struct {
+	ProfileService *services.ProfileService
+}
+
+func NewEmbedHandler(profileService *services.ProfileService) *EmbedHandler {
+	return &EmbedHandler{
+		ProfileService: profileService,
+	}
+}
+
+/* oEmbed provider for profile URLs, answers with the bare oEmbed document consumers expect */
+func (eh *EmbedHandler) OEmbed(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+
+	rawURL := query.Get("url")
+	if rawURL == "" {
+		utils.RespondError(w, http.StatusBadRequest, "Missing url")
+		return
+	}
+
+	if format := query.Get("format"); format != "" && format != "json" {
+		utils.RespondError(w, http.StatusNotImplemented, "Only the json format is supported")
+		return
+	}
+
+	maxWidth, _ := strconv.Atoi(query.Get("maxwidth"))
+	maxHeight, _ := strconv.Atoi(query.Get("maxheight"))
+
+	response, err := eh.ProfileService.OEmbed(rawURL, query.Get("type"), maxWidth, maxHeight, services.ParseEmbedTheme(query))
+	if err != nil {
+		switch err.Error() {
+		case "unsupported url", "profile not found", "size not available":
+			utils.RespondError(w, http.StatusNotFound, "No embed available for this URL")
+		case "unsupported type":
+			utils.RespondError(w, http.StatusBadRequest, "Unsupported type")
+		default:
+			log.Println("Error building oEmbed response:", err)
+			utils.RespondError(w, http.StatusInternalServerError, "Something went wrong. Please try again later")
+		}
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	w.Header().Set("Cache-Control", "public, max-age=3600")
+	json.NewEncoder(w).Encode(response)
+}
+
+/* Compact profile view for embedding in an iframe on other sites */
+func (eh *EmbedHandler) GetProfileEmbed(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+
+	page, err := eh.ProfileService.RenderProfileEmbed(vars["identifier"], services.ParseEmbedTheme(r.URL.Query()))
+	if err != nil {
+		if err.Error() == "profile not found" {
+			utils.RespondError(w, http.StatusNotFound, "Profile not found")
+			return
+		}
+
+		log.Println("Error rendering profile embed:", err)
+		utils.RespondError(w, http.StatusInternalServerError, "Something went wrong. Please try again later")
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.Header().Set("Cache-Control", "public, max-age=300")
+	// Any site may frame the view, but it can't run scripts or load anything but images
+	w.Header().Set("Content-Security-Policy", "default-src 'none'; img-src https: data:; style-src 'unsafe-inline'; frame-ancestors *")
+	w.Write(page)
+}
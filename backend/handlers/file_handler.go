@@ -6,7 +6,9 @@ import (
 	"log"
 	"net/http"
 
+	"github.com/gorilla/mux"
 	"github.com/hazebio/haze.bio_backend/middlewares"
+	"github.com/hazebio/haze.bio_backend/models"
 	"github.com/hazebio/haze.bio_backend/services"
 	"github.com/hazebio/haze.bio_backend/utils"
 )
@@ -22,29 +24,40 @@ func NewFileHandler(fileService *services.FileService) *FileHandler {
 func (h *FileHandler) UploadFile(w http.ResponseWriter, r *http.Request) {
 	fileType := r.FormValue("fileType")
 	if fileType == "custom_badge" {
-		file, fileHeader, err := r.FormFile("file")
+		file, _, err := r.FormFile("file")
 		if err != nil {
 			utils.RespondError(w, http.StatusBadRequest, "Failed to get file")
 			return
 		}
 		defer file.Close()
 
-		fileBytes, err := io.ReadAll(file)
+		// Read one byte past the limit so oversized files fail validation
+		fileBytes, err := io.ReadAll(io.LimitReader(file, int64(utils.CustomBadgeImageLimits.MaxBytes)+1))
 		if err != nil {
 			utils.RespondError(w, http.StatusInternalServerError, "Failed to read file")
 			return
 		}
 
-		if len(fileBytes) > 75*1024*1024 {
-			utils.RespondError(w, http.StatusBadRequest, "File size should not exceed 75MB")
+		uid := middlewares.GetUserIDFromContext(r.Context())
+		badgeID := utils.StringToUint(r.FormValue("badgeID"))
+		if badgeID == 0 {
+			utils.RespondError(w, http.StatusBadRequest, "Badge id is required")
 			return
 		}
 
-		badgeID := utils.StringToUint(r.FormValue("badgeID"))
-		fileName := fileHeader.Filename
-
-		fileURL, err := h.FileService.UploadCustomBadgeMedia(badgeID, fileName, fileBytes)
+		fileURL, err := h.FileService.UploadCustomBadgeMedia(uid, badgeID, fileBytes)
 		if err != nil {
+			if utils.IsMediaError(err) {
+				utils.RespondError(w, http.StatusBadRequest, err.Error())
+				return
+			}
+
+			if err.Error() == "badge not found" {
+				utils.RespondError(w, http.StatusNotFound, "Badge not found")
+				return
+			}
+
+			log.Println("Error uploading custom badge media:", err)
 			utils.RespondError(w, http.StatusInternalServerError, "Failed to upload file")
 			return
 		}
@@ -72,9 +85,15 @@ func (h *FileHandler) UploadFile(w http.ResponseWriter, r *http.Request) {
 			return
 		}
 
+		uid := middlewares.GetUserIDFromContext(r.Context())
 		fileName := fileHeader.Filename
-		fileURL, err := h.FileService.UploadCustomSocialMedia(fileName, fileBytes)
+		fileURL, err := h.FileService.UploadCustomSocialMedia(uid, fileType, fileName, fileBytes)
 		if err != nil {
+			if utils.IsMediaError(err) {
+				utils.RespondError(w, http.StatusBadRequest, err.Error())
+				return
+			}
+
 			utils.RespondError(w, http.StatusInternalServerError, "Failed to upload file")
 			return
 		}
@@ -84,7 +103,7 @@ func (h *FileHandler) UploadFile(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	file, fileHeader, err := r.FormFile("file")
+	file, _, err := r.FormFile("file")
 	if err != nil {
 		utils.RespondError(w, http.StatusBadRequest, "Failed to get file")
 		return
@@ -102,11 +121,15 @@ func (h *FileHandler) UploadFile(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	fileName := fileHeader.Filename
 	userID := middlewares.GetUserIDFromContext(r.Context())
 
-	fileURL, err := h.FileService.UploadFile(fileType, fileName, userID, fileBytes)
+	fileURL, err := h.FileService.UploadFile(fileType, userID, fileBytes)
 	if err != nil {
+		if utils.IsMediaError(err) {
+			utils.RespondError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+
 		log.Println("Error uploading file:", err)
 		utils.RespondError(w, http.StatusInternalServerError, "Failed to upload file")
 		return
@@ -134,3 +157,169 @@ func (h *FileHandler) DeleteFile(w http.ResponseWriter, r *http.Request) {
 
 	utils.RespondSuccess(w, "File deleted successfully", nil)
 }
+
+func (h *FileHandler) GetLibrary(w http.ResponseWriter, r *http.Request) {
+	uid := middlewares.GetUserIDFromContext(r.Context())
+
+	files, usage, err := h.FileService.GetLibrary(uid)
+	if err != nil {
+		log.Println("Error fetching media library:", err)
+		utils.RespondError(w, http.StatusInternalServerError, "Something went wrong. Please try again later")
+		return
+	}
+
+	utils.RespondSuccess(w, "Media library fetched successfully", map[string]interface{}{
+		"files": files,
+		"usage": usage,
+	})
+}
+
+func (h *FileHandler) UseLibraryFile(w http.ResponseWriter, r *http.Request) {
+	var request struct {
+		Field string `json:"field"`
+	}
+
+	if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+		utils.RespondError(w, http.StatusBadRequest, "Invalid request payload")
+		return
+	}
+
+	uid := middlewares.GetUserIDFromContext(r.Context())
+	fileID := utils.StringToUint(mux.Vars(r)["id"])
+
+	fileURL, err := h.FileService.UseLibraryFile(uid, fileID, request.Field)
+	if err != nil {
+		switch err.Error() {
+		case "file not found":
+			utils.RespondError(w, http.StatusNotFound, "File not found")
+		case "file was not uploaded for this field":
+			utils.RespondError(w, http.StatusBadRequest, "File was not uploaded for this field")
+		default:
+			log.Println("Error using library file:", err)
+			utils.RespondError(w, http.StatusInternalServerError, "Something went wrong. Please try again later")
+		}
+		return
+	}
+
+	utils.RespondSuccess(w, "File applied successfully", fileURL)
+}
+
+func (h *FileHandler) RemoveLibraryFile(w http.ResponseWriter, r *http.Request) {
+	uid := middlewares.GetUserIDFromContext(r.Context())
+	fileID := utils.StringToUint(mux.Vars(r)["id"])
+
+	if err := h.FileService.RemoveLibraryFile(uid, fileID); err != nil {
+		if err.Error() == "file not found" {
+			utils.RespondError(w, http.StatusNotFound, "File not found")
+			return
+		}
+
+		log.Println("Error removing library file:", err)
+		utils.RespondError(w, http.StatusInternalServerError, "Something went wrong. Please try again later")
+		return
+	}
+
+	utils.RespondSuccess(w, "File removed successfully", nil)
+}
+
+func (h *FileHandler) GetQuarantinedFiles(w http.ResponseWriter, r *http.Request) {
+	files, err := h.FileService.GetQuarantinedFiles()
+	if err != nil {
+		log.Println("Error getting quarantined files:", err)
+		utils.RespondError(w, http.StatusInternalServerError, "Failed to get quarantined files")
+		return
+	}
+
+	utils.RespondSuccess(w, "Quarantined files retrieved successfully", files)
+}
+
+func (h *FileHandler) ResolveQuarantinedFile(w http.ResponseWriter, r *http.Request) {
+	var request models.QuarantineResolveRequest
+	if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+		utils.RespondError(w, http.StatusBadRequest, "Invalid request payload")
+		return
+	}
+
+	staffID := middlewares.GetUserIDFromContext(r.Context())
+	fileID := utils.StringToUint(mux.Vars(r)["id"])
+
+	if err := h.FileService.ResolveQuarantinedFile(fileID, staffID, request.Action); err != nil {
+		if utils.IsMediaError(err) {
+			utils.RespondError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+
+		switch err.Error() {
+		case "file not found":
+			utils.RespondError(w, http.StatusNotFound, "File not found")
+		case "invalid action":
+			utils.RespondError(w, http.StatusBadRequest, "Action must be release or delete")
+		default:
+			log.Println("Error resolving quarantined file:", err)
+			utils.RespondError(w, http.StatusInternalServerError, "Failed to resolve quarantined file")
+		}
+		return
+	}
+
+	utils.RespondSuccess(w, "Quarantined file resolved successfully", nil)
+}
+
+func (h *FileHandler) CreateUploadGrant(w http.ResponseWriter, r *http.Request) {
+	var request models.UploadGrantRequest
+	if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+		utils.RespondError(w, http.StatusBadRequest, "Invalid request payload")
+		return
+	}
+
+	uid := middlewares.GetUserIDFromContext(r.Context())
+
+	grant, err := h.FileService.CreateUploadGrant(uid, request)
+	if err != nil {
+		if utils.IsMediaError(err) {
+			utils.RespondError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+
+		switch err.Error() {
+		case "invalid usage":
+			utils.RespondError(w, http.StatusBadRequest, "Invalid usage")
+		case "direct uploads are not configured":
+			utils.RespondError(w, http.StatusServiceUnavailable, "Direct uploads are not available")
+		default:
+			log.Println("Error creating upload grant:", err)
+			utils.RespondError(w, http.StatusInternalServerError, "Something went wrong. Please try again later")
+		}
+		return
+	}
+
+	utils.RespondSuccess(w, "Upload grant created successfully", grant)
+}
+
+func (h *FileHandler) CompleteUpload(w http.ResponseWriter, r *http.Request) {
+	uid := middlewares.GetUserIDFromContext(r.Context())
+
+	fileURL, err := h.FileService.CompleteUpload(uid, mux.Vars(r)["id"])
+	if err != nil {
+		if utils.IsMediaError(err) {
+			utils.RespondError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+
+		switch err.Error() {
+		case "upload not found":
+			utils.RespondError(w, http.StatusNotFound, "Upload not found")
+		case "upload expired":
+			utils.RespondError(w, http.StatusGone, "Upload expired")
+		case "upload already completed":
+			utils.RespondError(w, http.StatusConflict, "Upload already completed")
+		case "file not uploaded":
+			utils.RespondError(w, http.StatusBadRequest, "File has not been uploaded yet")
+		default:
+			log.Println("Error completing upload:", err)
+			utils.RespondError(w, http.StatusInternalServerError, "Failed to upload file")
+		}
+		return
+	}
+
+	utils.RespondSuccess(w, "File uploaded successfully", fileURL)
+}
@@ -0,0 +1,160 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/gorilla/mux"
+	"github.com/hazebio/haze.bio_backend/models"
+	"github.com/hazebio/haze.bio_backend/services"
+	"github.com/hazebio/haze.bio_backend/utils"
+)
+
+type GuildHandler struct {
+	GuildService *services.GuildService
+	BadgeService *services.BadgeService
+}
+
+func NewGuildHandler(guildService *services.GuildService, badgeService *services.BadgeService) *GuildHandler {
+	return &GuildHandler{
+		GuildService: guildService,
+		BadgeService: badgeService,
+	}
+}
+
+/* Get all configured Discord guilds */
+func (gh *GuildHandler) GetGuilds(w http.ResponseWriter, r *http.Request) {
+	guilds, err := gh.GuildService.GetGuilds()
+	if err != nil {
+		utils.RespondError(w, http.StatusInternalServerError, "Something went wrong. Please try again later")
+		return
+	}
+
+	utils.RespondSuccess(w, "Guilds retrieved successfully", guilds)
+}
+
+/* Add a Discord guild */
+func (gh *GuildHandler) CreateGuild(w http.ResponseWriter, r *http.Request) {
+	var guild models.DiscordGuild
+	if err := json.NewDecoder(r.Body).Decode(&guild); err != nil {
+		utils.RespondError(w, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+
+	if err := gh.GuildService.CreateGuild(&guild); err != nil {
+		utils.RespondError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	utils.RespondSuccess(w, "Guild created successfully", guild)
+}
+
+/* Update the configuration of a Discord guild */
+func (gh *GuildHandler) UpdateGuild(w http.ResponseWriter, r *http.Request) {
+	guildID := mux.Vars(r)["id"]
+
+	var fields map[string]interface{}
+	if err := json.NewDecoder(r.Body).Decode(&fields); err != nil {
+		utils.RespondError(w, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+
+	if err := gh.GuildService.UpdateGuild(guildID, fields); err != nil {
+		if err.Error() == "guild not found" {
+			utils.RespondError(w, http.StatusNotFound, "Guild not found")
+			return
+		}
+
+		utils.RespondError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	utils.RespondSuccess(w, "Guild updated successfully", nil)
+}
+
+/* Remove a Discord guild */
+func (gh *GuildHandler) DeleteGuild(w http.ResponseWriter, r *http.Request) {
+	guildID := mux.Vars(r)["id"]
+
+	if err := gh.GuildService.DeleteGuild(guildID); err != nil {
+		switch err.Error() {
+		case "guild not found":
+			utils.RespondError(w, http.StatusNotFound, "Guild not found")
+		case "cannot delete the primary guild":
+			utils.RespondError(w, http.StatusBadRequest, "Cannot delete the primary guild")
+		default:
+			utils.RespondError(w, http.StatusInternalServerError, "Something went wrong. Please try again later")
+		}
+		return
+	}
+
+	utils.RespondSuccess(w, "Guild deleted successfully", nil)
+}
+
+/* Get the badge role mappings of a guild */
+func (gh *GuildHandler) GetBadgeRoles(w http.ResponseWriter, r *http.Request) {
+	guildID := mux.Vars(r)["id"]
+
+	if _, err := gh.GuildService.GetGuild(guildID); err != nil {
+		utils.RespondError(w, http.StatusNotFound, "Guild not found")
+		return
+	}
+
+	roles, err := gh.BadgeService.GetGuildBadgeRoles(guildID)
+	if err != nil {
+		utils.RespondError(w, http.StatusInternalServerError, "Something went wrong. Please try again later")
+		return
+	}
+
+	utils.RespondSuccess(w, "Badge roles retrieved successfully", roles)
+}
+
+/* Map a role of a guild to a badge */
+func (gh *GuildHandler) AddBadgeRole(w http.ResponseWriter, r *http.Request) {
+	guildID := mux.Vars(r)["id"]
+
+	var req struct {
+		Badge  string `json:"badge"`
+		RoleID string `json:"role_id"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil || req.Badge == "" || req.RoleID == "" {
+		utils.RespondError(w, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+
+	if _, err := gh.GuildService.GetGuild(guildID); err != nil {
+		utils.RespondError(w, http.StatusNotFound, "Guild not found")
+		return
+	}
+
+	role, err := gh.BadgeService.AddBadgeRole(req.Badge, guildID, req.RoleID)
+	if err != nil {
+		utils.RespondError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	utils.RespondSuccess(w, "Badge role added successfully", role)
+}
+
+/* Remove a badge role mapping from a guild */
+func (gh *GuildHandler) RemoveBadgeRole(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+
+	mappingID := utils.StringToUint(vars["mapping"])
+	if mappingID == 0 {
+		utils.RespondError(w, http.StatusBadRequest, "Invalid mapping ID")
+		return
+	}
+
+	if err := gh.BadgeService.RemoveBadgeRoleByID(vars["id"], mappingID); err != nil {
+		if err.Error() == "role is not mapped to this badge" {
+			utils.RespondError(w, http.StatusNotFound, "Badge role not found")
+			return
+		}
+
+		utils.RespondError(w, http.StatusInternalServerError, "Something went wrong. Please try again later")
+		return
+	}
+
+	utils.RespondSuccess(w, "Badge role removed successfully", nil)
+}
@@ -1,12 +1,12 @@
 package handlers
 
 import (
-	"io"
 	"log"
 	"net/http"
-	"os"
+	"strings"
 
 	"github.com/gorilla/mux"
+	"github.com/hazebio/haze.bio_backend/models"
 	"github.com/hazebio/haze.bio_backend/services"
 	"github.com/hazebio/haze.bio_backend/utils"
 )
@@ -27,6 +27,21 @@ func NewImageHandler(imageService *services.ImageService, userService *services.
 	}
 }
 
+/* Layout and format of a card from the query, classic PNG by default */
+func cardOptions(r *http.Request) (string, string) {
+	layout := r.URL.Query().Get("layout")
+	if layout == "" {
+		layout = services.CardLayoutClassic
+	}
+
+	format := r.URL.Query().Get("format")
+	if format == "" {
+		format = services.CardFormatPNG
+	}
+
+	return layout, format
+}
+
 func (ih *ImageHandler) GenerateUserCard(w http.ResponseWriter, r *http.Request) {
 	vars := mux.Vars(r)
 	identifier := vars["identifier"]
@@ -38,28 +53,83 @@ func (ih *ImageHandler) GenerateUserCard(w http.ResponseWriter, r *http.Request)
 		return
 	}
 
-	imagePath, err := ih.ImageService.GenerateUserCard(user, user.Profile)
+	if user.HasActivePunishment() {
+		utils.RespondError(w, http.StatusNotFound, "User not found")
+		return
+	}
+
+	layout, format := cardOptions(r)
+	card, err := ih.ImageService.GenerateUserCard(user, user.Profile, layout, format)
 	if err != nil {
-		log.Printf("Error generating user card: %v", err)
-		utils.RespondError(w, http.StatusInternalServerError, "Failed to generate image")
+		respondCardError(w, err)
 		return
 	}
-	defer os.Remove(imagePath)
 
-	w.Header().Set("Content-Type", "image/png")
-	w.Header().Set("Content-Disposition", "inline; filename=\""+user.Username+"-c2ard.png\"")
+	writeCard(w, r, card, user.Username)
+}
 
-	imgFile, err := os.Open(imagePath)
-	if err != nil {
-		log.Printf("Error opening image file: %v", err)
-		utils.RespondError(w, http.StatusInternalServerError, "Failed to read image")
+func (ih *ImageHandler) GenerateTemplateCard(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	templateID := utils.StringToUint(vars["templateID"])
+
+	template, err := ih.TemplateService.GetTemplateByID(templateID)
+	if err != nil || !template.Shareable {
+		utils.RespondError(w, http.StatusNotFound, "Template not found")
 		return
 	}
-	defer imgFile.Close()
 
-	_, err = io.Copy(w, imgFile)
+	profile, err := ih.TemplateService.PreviewProfile(template)
 	if err != nil {
-		log.Printf("Error sending image: %v", err)
+		log.Printf("Error previewing template %d: %v", template.ID, err)
+		profile = &models.UserProfile{}
+	}
+
+	layout, format := cardOptions(r)
+	card, err := ih.ImageService.GenerateTemplateCard(template, profile, layout, format)
+	if err != nil {
+		respondCardError(w, err)
 		return
 	}
+
+	writeCard(w, r, card, "template-"+vars["templateID"])
+}
+
+func respondCardError(w http.ResponseWriter, err error) {
+	switch err.Error() {
+	case "invalid layout":
+		utils.RespondError(w, http.StatusBadRequest, "Invalid layout")
+	case "invalid format":
+		utils.RespondError(w, http.StatusBadRequest, "Invalid format")
+	default:
+		log.Printf("Error generating card: %v", err)
+		utils.RespondError(w, http.StatusInternalServerError, "Failed to generate image")
+	}
+}
+
+/* Write a card, answering 304 when the client already has it */
+func writeCard(w http.ResponseWriter, r *http.Request, card *services.Card, name string) {
+	etag := `"` + card.Hash + `"`
+	w.Header().Set("ETag", etag)
+	w.Header().Set("Cache-Control", "public, max-age=3600")
+
+	for _, candidate := range strings.Split(r.Header.Get("If-None-Match"), ",") {
+		if strings.TrimSpace(candidate) == etag {
+			w.WriteHeader(http.StatusNotModified)
+			return
+		}
+	}
+
+	extension := "png"
+	if card.ContentType == "image/svg+xml" {
+		extension = "svg"
+		// SVG cards are only ever images, never documents that run scripts
+		w.Header().Set("Content-Security-Policy", "default-src 'none'; img-src data:; style-src 'unsafe-inline'")
+	}
+
+	w.Header().Set("Content-Type", card.ContentType)
+	w.Header().Set("Content-Disposition", "inline; filename=\""+name+"-card."+extension+"\"")
+
+	if _, err := w.Write(card.Data); err != nil {
+		log.Printf("Error sending image: %v", err)
+	}
 }
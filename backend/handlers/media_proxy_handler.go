@@ -0,0 +1,37 @@
+package handlers
+
+import (
+	"net/http"
+
+	"github.com/gorilla/mux"
+	"github.com/hazebio/haze.bio_backend/services"
+	"github.com/hazebio/haze.bio_backend/utils"
+)
+
+type MediaProxyHandler struct {
+	MediaProxyService *services.MediaProxyService
+}
+
+func NewMediaProxyHandler(mediaProxyService *services.MediaProxyService) *MediaProxyHandler {
+	return &MediaProxyHandler{
+		MediaProxyService: mediaProxyService,
+	}
+}
+
+func (mph *MediaProxyHandler) ProxyMedia(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+
+	fileURL, err := mph.MediaProxyService.Resolve(vars["signature"], vars["target"])
+	if err != nil {
+		switch err.Error() {
+		case "invalid signature":
+			utils.RespondError(w, http.StatusForbidden, "Invalid media URL")
+		default:
+			utils.RespondError(w, http.StatusNotFound, "Media unavailable")
+		}
+		return
+	}
+
+	w.Header().Set("Cache-Control", "public, max-age=3600")
+	http.Redirect(w, r, fileURL, http.StatusFound)
+}
@@ -0,0 +1,141 @@
+package handlers
+
+import (
+	"encoding/json"
+	"log"
+	"net/http"
+
+	"github.com/hazebio/haze.bio_backend/middlewares"
+	"github.com/hazebio/haze.bio_backend/models"
+	"github.com/hazebio/haze.bio_backend/services"
+	"github.com/hazebio/haze.bio_backend/utils"
+)
+
+type NotificationHandler struct {
+	NotificationService *services.NotificationService
+}
+
+func NewNotificationHandler(notificationService *services.NotificationService) *NotificationHandler {
+	return &NotificationHandler{
+		NotificationService: notificationService,
+	}
+}
+
+/* Get notifications of the current user */
+func (nh *NotificationHandler) GetNotifications(w http.ResponseWriter, r *http.Request) {
+	uid := middlewares.GetUserIDFromContext(r.Context())
+	query := r.URL.Query()
+
+	unreadOnly := utils.StringToBool(query.Get("unread"))
+	limit := utils.StringToInt(query.Get("limit"))
+	offset := utils.StringToInt(query.Get("offset"))
+
+	notifications, err := nh.NotificationService.GetNotifications(uid, unreadOnly, limit, offset)
+	if err != nil {
+		utils.RespondError(w, http.StatusInternalServerError, "Something went wrong. Please try again later")
+		return
+	}
+
+	utils.RespondSuccess(w, "Notifications retrieved successfully", notifications)
+}
+
+/* Get unread notification count of the current user */
+func (nh *NotificationHandler) GetUnreadCount(w http.ResponseWriter, r *http.Request) {
+	uid := middlewares.GetUserIDFromContext(r.Context())
+
+	count, err := nh.NotificationService.GetUnreadCount(uid)
+	if err != nil {
+		utils.RespondError(w, http.StatusInternalServerError, "Something went wrong. Please try again later")
+		return
+	}
+
+	utils.RespondSuccess(w, "Unread count retrieved successfully", map[string]int64{"count": count})
+}
+
+/* Mark notifications as read or unread */
+func (nh *NotificationHandler) MarkNotifications(w http.ResponseWriter, r *http.Request) {
+	var req struct {
+		IDs  []uint `json:"ids"`
+		Read *bool  `json:"read"`
+	}
+
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		utils.RespondError(w, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+
+	if len(req.IDs) == 0 {
+		utils.RespondError(w, http.StatusBadRequest, "Notification ids are required")
+		return
+	}
+
+	uid := middlewares.GetUserIDFromContext(r.Context())
+
+	var (
+		updated int64
+		err     error
+	)
+	if req.Read == nil || *req.Read {
+		updated, err = nh.NotificationService.MarkAsRead(uid, req.IDs)
+	} else {
+		updated, err = nh.NotificationService.MarkAsUnread(uid, req.IDs)
+	}
+	if err != nil {
+		log.Println("Error marking notifications:", err)
+		utils.RespondError(w, http.StatusInternalServerError, "Something went wrong. Please try again later")
+		return
+	}
+
+	utils.RespondSuccess(w, "Notifications updated successfully", map[string]int64{"updated": updated})
+}
+
+/* Mark all notifications as read */
+func (nh *NotificationHandler) MarkAllAsRead(w http.ResponseWriter, r *http.Request) {
+	uid := middlewares.GetUserIDFromContext(r.Context())
+
+	updated, err := nh.NotificationService.MarkAllAsRead(uid)
+	if err != nil {
+		utils.RespondError(w, http.StatusInternalServerError, "Something went wrong. Please try again later")
+		return
+	}
+
+	utils.RespondSuccess(w, "All notifications marked as read", map[string]int64{"updated": updated})
+}
+
+/* Get notification preferences */
+func (nh *NotificationHandler) GetPreferences(w http.ResponseWriter, r *http.Request) {
+	uid := middlewares.GetUserIDFromContext(r.Context())
+
+	preferences, err := nh.NotificationService.GetPreferences(uid)
+	if err != nil {
+		utils.RespondError(w, http.StatusInternalServerError, "Something went wrong. Please try again later")
+		return
+	}
+
+	utils.RespondSuccess(w, "Notification preferences retrieved successfully", preferences)
+}
+
+/* Update notification preferences */
+func (nh *NotificationHandler) UpdatePreferences(w http.ResponseWriter, r *http.Request) {
+	var req struct {
+		Preferences []models.NotificationPreference `json:"preferences"`
+	}
+
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		utils.RespondError(w, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+
+	uid := middlewares.GetUserIDFromContext(r.Context())
+	if err := nh.NotificationService.UpdatePreferences(uid, req.Preferences); err != nil {
+		if err.Error() == "invalid notification category" {
+			utils.RespondError(w, http.StatusBadRequest, "Invalid notification category")
+			return
+		}
+
+		utils.RespondError(w, http.StatusInternalServerError, "Something went wrong. Please try again later")
+		return
+	}
+
+	utils.RespondSuccess(w, "Notification preferences updated successfully", nil)
+}
@@ -0,0 +1,123 @@
+package handlers
+
+import (
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/gorilla/websocket"
+	"github.com/hazebio/haze.bio_backend/config"
+	"github.com/hazebio/haze.bio_backend/models"
+	"github.com/hazebio/haze.bio_backend/services"
+	"github.com/hazebio/haze.bio_backend/utils"
+)
+
+type PresenceHandler struct {
+	DiscordService *services.DiscordService
+	PresenceHub    *services.PresenceHub
+}
+
+const (
+	presenceWriteWait  = 10 * time.Second
+	presencePongWait   = 60 * time.Second
+	presencePingPeriod = (presencePongWait * 9) / 10
+)
+
+var presenceUpgrader = websocket.Upgrader{
+	ReadBufferSize:  512,
+	WriteBufferSize: 1024,
+	CheckOrigin: func(r *http.Request) bool {
+		origin := r.Header.Get("Origin")
+		if origin == "" {
+			return true
+		}
+		for _, allowed := range config.Origins {
+			if origin == allowed {
+				return true
+			}
+		}
+		return false
+	},
+}
+
+func NewPresenceHandler(discordService *services.DiscordService, presenceHub *services.PresenceHub) *PresenceHandler {
+	return &PresenceHandler{
+		DiscordService: discordService,
+		PresenceHub:    presenceHub,
+	}
+}
+
+/* Stream Discord presence updates of a user over a websocket */
+func (ph *PresenceHandler) StreamPresence(w http.ResponseWriter, r *http.Request) {
+	uid := utils.StringToUint(mux.Vars(r)["uid"])
+	if uid == 0 {
+		utils.RespondError(w, http.StatusBadRequest, "Invalid user ID")
+		return
+	}
+
+	snapshot, err := ph.DiscordService.GetPresenceSnapshot(uid)
+	if err != nil {
+		log.Println("Error getting Discord presence snapshot:", err)
+		utils.RespondError(w, http.StatusNotFound, "Discord presence not available")
+		return
+	}
+
+	conn, err := presenceUpgrader.Upgrade(w, r, nil)
+	if err != nil {
+		log.Println("Error upgrading presence websocket:", err)
+		return
+	}
+	defer conn.Close()
+
+	updates, unsubscribe := ph.PresenceHub.Subscribe(uid)
+	defer unsubscribe()
+
+	if err := writePresence(conn, models.PresenceMessage{Type: "snapshot", Presence: snapshot}); err != nil {
+		return
+	}
+
+	// Viewers never send data, the read loop only handles pongs and detects disconnects
+	closed := make(chan struct{})
+	go func() {
+		defer close(closed)
+		conn.SetReadLimit(512)
+		conn.SetReadDeadline(time.Now().Add(presencePongWait))
+		conn.SetPongHandler(func(string) error {
+			return conn.SetReadDeadline(time.Now().Add(presencePongWait))
+		})
+		for {
+			if _, _, err := conn.NextReader(); err != nil {
+				return
+			}
+		}
+	}()
+
+	ticker := time.NewTicker(presencePingPeriod)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-closed:
+			return
+		case payload, ok := <-updates:
+			if !ok {
+				return
+			}
+			conn.SetWriteDeadline(time.Now().Add(presenceWriteWait))
+			if err := conn.WriteMessage(websocket.TextMessage, payload); err != nil {
+				return
+			}
+		case <-ticker.C:
+			conn.SetWriteDeadline(time.Now().Add(presenceWriteWait))
+			if err := conn.WriteMessage(websocket.PingMessage, nil); err != nil {
+				return
+			}
+		}
+	}
+}
+
+func writePresence(conn *websocket.Conn, message models.PresenceMessage) error {
+	conn.SetWriteDeadline(time.Now().Add(presenceWriteWait))
+	return conn.WriteJSON(message)
+}
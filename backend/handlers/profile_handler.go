@@ -6,6 +6,7 @@ import (
 	"net/http"
 
 	"github.com/gorilla/mux"
+	"github.com/hazebio/haze.bio_backend/config"
 	"github.com/hazebio/haze.bio_backend/middlewares"
 	"github.com/hazebio/haze.bio_backend/services"
 	"github.com/hazebio/haze.bio_backend/utils"
@@ -21,11 +22,25 @@ func NewProfileHandler(profileService *services.ProfileService) *ProfileHandler
 	}
 }
 
-/* Get public profile by identifier */
+/* Get public profile by identifier, or by the custom domain the request was made to without one */
 func (ph *ProfileHandler) GetPublicProfile(w http.ResponseWriter, r *http.Request) {
 	vars := mux.Vars(r)
 	identifier := vars["identifier"]
 
+	if identifier == "" {
+		profile, err := ph.ProfileService.GetPublicProfileByDomain(utils.RequestHost(r, config.TrustedProxies))
+		if err != nil {
+			if err.Error() != "profile not found" {
+				log.Println("Error getting custom domain profile:", err)
+			}
+			utils.RespondError(w, http.StatusNotFound, "Profile not found")
+			return
+		}
+
+		utils.RespondSuccess(w, "Profile found", profile)
+		return
+	}
+
 	profile, err := ph.ProfileService.GetPublicProfile(identifier)
 	if err != nil {
 		log.Println("Error getting user profile:", err)
@@ -36,6 +51,31 @@ func (ph *ProfileHandler) GetPublicProfile(w http.ResponseWriter, r *http.Reques
 	utils.RespondSuccess(w, "Profile found", profile)
 }
 
+/* Get the server rendered profile document for crawlers and link unfurlers */
+func (ph *ProfileHandler) GetPublicProfilePage(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	identifier := vars["identifier"]
+
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+
+	page, err := ph.ProfileService.RenderProfilePage(identifier)
+	if err != nil {
+		if err.Error() != "profile not found" {
+			log.Println("Error rendering profile page:", err)
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+
+		w.Header().Set("Cache-Control", "public, max-age=60")
+		w.WriteHeader(http.StatusNotFound)
+		w.Write(services.RenderProfileNotFoundPage())
+		return
+	}
+
+	w.Header().Set("Cache-Control", "public, max-age=300")
+	w.Write(page)
+}
+
 /* Update user profile */
 func (ph *ProfileHandler) UpdateUserProfile(w http.ResponseWriter, r *http.Request) {
 	uid := middlewares.GetUserIDFromContext(r.Context())
@@ -53,3 +53,15 @@ func (ph *PublicHandler) GetLeaderboardUsersByBadges(w http.ResponseWriter, r *h
 
 	utils.RespondSuccess(w, "Leaderboard users (badges) found", leaderboardUsers)
 }
+
+/* Get Leaderboard Users by Referrals */
+func (ph *PublicHandler) GetLeaderboardUsersByReferrals(w http.ResponseWriter, r *http.Request) {
+	leaderboardUsers, err := ph.PublicService.GetReferralLeaderboard()
+	if err != nil {
+		log.Println("Error getting leaderboard users by referrals:", err)
+		utils.RespondError(w, http.StatusInternalServerError, "Something went wrong. Please try again later")
+		return
+	}
+
+	utils.RespondSuccess(w, "Leaderboard users (referrals) found", leaderboardUsers)
+}
@@ -0,0 +1,128 @@
+package handlers
+
+import (
+	"encoding/json"
+	"log"
+	"net/http"
+
+	"github.com/gorilla/mux"
+	"github.com/hazebio/haze.bio_backend/middlewares"
+	"github.com/hazebio/haze.bio_backend/models"
+	"github.com/hazebio/haze.bio_backend/services"
+	"github.com/hazebio/haze.bio_backend/utils"
+)
+
+type ReferralHandler struct {
+	ReferralService *services.ReferralService
+}
+
+func NewReferralHandler(referralService *services.ReferralService) *ReferralHandler {
+	return &ReferralHandler{
+		ReferralService: referralService,
+	}
+}
+
+/* Get referral code and stats of the current user */
+func (rh *ReferralHandler) GetReferralSummary(w http.ResponseWriter, r *http.Request) {
+	uid := middlewares.GetUserIDFromContext(r.Context())
+
+	summary, err := rh.ReferralService.GetReferralSummary(uid)
+	if err != nil {
+		log.Println("Error getting referral summary:", err)
+		utils.RespondError(w, http.StatusInternalServerError, "Something went wrong. Please try again later")
+		return
+	}
+
+	utils.RespondSuccess(w, "Referral summary retrieved successfully", summary)
+}
+
+/* Get all invite campaigns */
+func (rh *ReferralHandler) GetCampaigns(w http.ResponseWriter, r *http.Request) {
+	campaigns, err := rh.ReferralService.GetCampaigns()
+	if err != nil {
+		utils.RespondError(w, http.StatusInternalServerError, "Something went wrong. Please try again later")
+		return
+	}
+
+	utils.RespondSuccess(w, "Campaigns retrieved successfully", campaigns)
+}
+
+/* Create an invite campaign */
+func (rh *ReferralHandler) CreateCampaign(w http.ResponseWriter, r *http.Request) {
+	var campaign models.InviteCampaign
+	if err := json.NewDecoder(r.Body).Decode(&campaign); err != nil {
+		utils.RespondError(w, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+
+	campaign.ID = 0
+	campaign.CreatedBy = middlewares.GetUserIDFromContext(r.Context())
+
+	if err := rh.ReferralService.CreateCampaign(&campaign); err != nil {
+		utils.RespondError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	utils.RespondSuccess(w, "Campaign created successfully", campaign)
+}
+
+/* Update an invite campaign */
+func (rh *ReferralHandler) UpdateCampaign(w http.ResponseWriter, r *http.Request) {
+	campaignID := utils.StringToUint(mux.Vars(r)["id"])
+	if campaignID == 0 {
+		utils.RespondError(w, http.StatusBadRequest, "Invalid campaign ID")
+		return
+	}
+
+	var fields map[string]interface{}
+	if err := json.NewDecoder(r.Body).Decode(&fields); err != nil {
+		utils.RespondError(w, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+
+	if err := rh.ReferralService.UpdateCampaign(campaignID, fields); err != nil {
+		utils.RespondError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	utils.RespondSuccess(w, "Campaign updated successfully", nil)
+}
+
+/* Add a referral reward rule */
+func (rh *ReferralHandler) AddRewardRule(w http.ResponseWriter, r *http.Request) {
+	var rule models.ReferralRewardRule
+	if err := json.NewDecoder(r.Body).Decode(&rule); err != nil {
+		utils.RespondError(w, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+
+	rule.ID = 0
+
+	if err := rh.ReferralService.AddRewardRule(&rule); err != nil {
+		utils.RespondError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	utils.RespondSuccess(w, "Reward rule created successfully", rule)
+}
+
+/* Delete a referral reward rule */
+func (rh *ReferralHandler) DeleteRewardRule(w http.ResponseWriter, r *http.Request) {
+	ruleID := utils.StringToUint(mux.Vars(r)["id"])
+	if ruleID == 0 {
+		utils.RespondError(w, http.StatusBadRequest, "Invalid rule ID")
+		return
+	}
+
+	if err := rh.ReferralService.DeleteRewardRule(ruleID); err != nil {
+		if err.Error() == "reward rule not found" {
+			utils.RespondError(w, http.StatusNotFound, "Reward rule not found")
+			return
+		}
+
+		utils.RespondError(w, http.StatusInternalServerError, "Something went wrong. Please try again later")
+		return
+	}
+
+	utils.RespondSuccess(w, "Reward rule deleted successfully", nil)
+}
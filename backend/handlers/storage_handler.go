@@ -0,0 +1,41 @@
+package handlers
+
+import (
+	"encoding/json"
+	"log"
+	"net/http"
+
+	"github.com/hazebio/haze.bio_backend/services"
+	"github.com/hazebio/haze.bio_backend/utils"
+)
+
+type StorageHandler struct {
+	StorageService *services.StorageService
+}
+
+func NewStorageHandler(storageService *services.StorageService) *StorageHandler {
+	return &StorageHandler{StorageService: storageService}
+}
+
+func (h *StorageHandler) ReconcileStorage(w http.ResponseWriter, r *http.Request) {
+	// Deleting has to be asked for explicitly, an empty body only reports
+	request := struct {
+		DryRun bool `json:"dry_run"`
+	}{DryRun: true}
+
+	if r.ContentLength != 0 {
+		if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+			utils.RespondError(w, http.StatusBadRequest, "Invalid request payload")
+			return
+		}
+	}
+
+	report, err := h.StorageService.Reconcile(request.DryRun)
+	if err != nil {
+		log.Println("Error reconciling storage:", err)
+		utils.RespondError(w, http.StatusInternalServerError, "Failed to reconcile storage")
+		return
+	}
+
+	utils.RespondSuccess(w, "Storage reconciled successfully", report)
+}
@@ -0,0 +1,35 @@
+package jobs
+
+import (
+	"log"
+
+	"github.com/go-redis/redis"
+	"github.com/hazebio/haze.bio_backend/services"
+	"gorm.io/gorm"
+)
+
+type BadgeExpireJob struct {
+	DB           *gorm.DB
+	Client       *redis.Client
+	BadgeService *services.BadgeService
+}
+
+func NewBadgeExpireJob(db *gorm.DB, client *redis.Client, badgeService *services.BadgeService) *BadgeExpireJob {
+	return &BadgeExpireJob{
+		DB:           db,
+		Client:       client,
+		BadgeService: badgeService,
+	}
+}
+
+func (j *BadgeExpireJob) Run() {
+	log.Println("Running badge expire job")
+
+	removed, err := j.BadgeService.ExpireBadges()
+	if err != nil {
+		log.Printf("Error expiring badges: %v", err)
+		return
+	}
+
+	log.Printf("Badge expire job completed, removed %d expired badges", removed)
+}
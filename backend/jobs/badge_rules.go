@@ -0,0 +1,41 @@
+package jobs
+
+import (
+	"log"
+
+	"github.com/go-redis/redis"
+	"github.com/hazebio/haze.bio_backend/services"
+	"gorm.io/gorm"
+)
+
+type BadgeRuleJob struct {
+	DB               *gorm.DB
+	Client           *redis.Client
+	BadgeRuleService *services.BadgeRuleService
+}
+
+func NewBadgeRuleJob(db *gorm.DB, client *redis.Client, badgeService *services.BadgeService) *BadgeRuleJob {
+	return &BadgeRuleJob{
+		DB:               db,
+		Client:           client,
+		BadgeRuleService: services.NewBadgeRuleService(db, client, badgeService),
+	}
+}
+
+func (j *BadgeRuleJob) Run() {
+	log.Println("Running badge rule job")
+
+	results, err := j.BadgeRuleService.EvaluateAll()
+	if err != nil {
+		log.Printf("Error evaluating badge rules: %v", err)
+		return
+	}
+
+	granted, revoked := 0, 0
+	for _, result := range results {
+		granted += result.Granted
+		revoked += result.Revoked
+	}
+
+	log.Printf("Badge rule job completed, evaluated %d rules: granted %d, revoked %d", len(results), granted, revoked)
+}
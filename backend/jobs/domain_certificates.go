@@ -0,0 +1,48 @@
+package jobs
+
+import (
+	"log"
+
+	"github.com/go-redis/redis"
+	"github.com/hazebio/haze.bio_backend/services"
+	"gorm.io/gorm"
+)
+
+type DomainCertificateJob struct {
+	DB            *gorm.DB
+	Client        *redis.Client
+	DomainService *services.DomainService
+}
+
+func NewDomainCertificateJob(db *gorm.DB, client *redis.Client, domainService *services.DomainService) *DomainCertificateJob {
+	return &DomainCertificateJob{
+		DB:            db,
+		Client:        client,
+		DomainService: domainService,
+	}
+}
+
+/* Order certificates for verified custom domains that have none and renew the ones about to expire */
+func (j *DomainCertificateJob) Run() {
+	if !services.CertificatesEnabled() {
+		return
+	}
+
+	log.Println("Running domain certificate job")
+
+	domains, err := j.DomainService.DomainsNeedingCertificates()
+	if err != nil {
+		log.Printf("Error fetching domains needing certificates: %v", err)
+		return
+	}
+
+	issued := 0
+	for _, domain := range domains {
+		if err := j.DomainService.ProvisionCertificate(domain.ID); err != nil {
+			continue
+		}
+		issued++
+	}
+
+	log.Printf("Domain certificate job completed, issued %d of %d certificates", issued, len(domains))
+}
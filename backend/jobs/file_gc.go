@@ -0,0 +1,35 @@
+package jobs
+
+import (
+	"log"
+
+	"github.com/go-redis/redis"
+	"github.com/hazebio/haze.bio_backend/services"
+	"gorm.io/gorm"
+)
+
+type FileGCJob struct {
+	DB          *gorm.DB
+	Client      *redis.Client
+	FileService *services.FileService
+}
+
+func NewFileGCJob(db *gorm.DB, client *redis.Client) *FileGCJob {
+	return &FileGCJob{
+		DB:          db,
+		Client:      client,
+		FileService: services.NewFileService(db, client),
+	}
+}
+
+func (j *FileGCJob) Run() {
+	log.Println("Running file garbage collection job")
+
+	deleted, err := j.FileService.CollectGarbage()
+	if err != nil {
+		log.Printf("Error collecting unreferenced files: %v", err)
+		return
+	}
+
+	log.Printf("File garbage collection job completed, deleted %d files", deleted)
+}
@@ -18,13 +18,13 @@ type PremiumExpireJob struct {
 	BadgeService   *services.BadgeService
 }
 
-func NewPremiumExpireJob(db *gorm.DB, client *redis.Client) *PremiumExpireJob {
+func NewPremiumExpireJob(db *gorm.DB, client *redis.Client, badgeService *services.BadgeService) *PremiumExpireJob {
 	return &PremiumExpireJob{
 		DB:             db,
 		Client:         client,
 		UserService:    &services.UserService{DB: db, Client: client},
 		ProfileService: &services.ProfileService{DB: db, Client: client},
-		BadgeService:   &services.BadgeService{DB: db, Client: client, UserService: &services.UserService{DB: db, Client: client}},
+		BadgeService:   badgeService,
 	}
 }
 
@@ -0,0 +1,56 @@
+package jobs
+
+import (
+	"log"
+	"time"
+
+	"github.com/go-redis/redis"
+	"github.com/hazebio/haze.bio_backend/services"
+	"gorm.io/gorm"
+)
+
+type ReferralVerifyJob struct {
+	DB              *gorm.DB
+	Client          *redis.Client
+	ReferralService *services.ReferralService
+}
+
+func NewReferralVerifyJob(db *gorm.DB, client *redis.Client, badgeService *services.BadgeService) *ReferralVerifyJob {
+	return &ReferralVerifyJob{
+		DB:              db,
+		Client:          client,
+		ReferralService: services.NewReferralService(db, client, badgeService),
+	}
+}
+
+func (j *ReferralVerifyJob) Run() {
+	log.Println("Running referral verify job")
+
+	j.verifyPendingReferrals()
+
+	log.Println("Referral verify job completed")
+}
+
+func (j *ReferralVerifyJob) verifyPendingReferrals() {
+	var pendingReferrals []struct {
+		ID uint
+	}
+
+	err := j.DB.Table("referrals").
+		Select("id").
+		Where("status = ? AND created_at < ?", "pending", time.Now().Add(-services.ReferralVerificationDelay)).
+		Scan(&pendingReferrals).Error
+
+	if err != nil {
+		log.Printf("Error finding pending referrals: %v", err)
+		return
+	}
+
+	log.Printf("Found %d pending referrals to verify", len(pendingReferrals))
+
+	for _, referral := range pendingReferrals {
+		if err := j.ReferralService.VerifyReferral(referral.ID); err != nil {
+			log.Printf("Error verifying referral ID %d: %v", referral.ID, err)
+		}
+	}
+}
@@ -16,11 +16,13 @@ type Scheduler struct {
 	ProfileService      *services.ProfileService
 	BadgeService        *services.BadgeService
 	PunishService       *services.PunishService
+	EventService        *services.EventService
+	DomainService       *services.DomainService
 }
 
-func NewScheduler(db *gorm.DB, client *redis.Client) *Scheduler {
+func NewScheduler(db *gorm.DB, client *redis.Client, domainService *services.DomainService) *Scheduler {
 	userService := &services.UserService{DB: db, Client: client}
-	badgeService := &services.BadgeService{DB: db, Client: client}
+	badgeService := services.NewBadgeService(db, client)
 	profileService := &services.ProfileService{
 		DB:          db,
 		Client:      client,
@@ -28,6 +30,11 @@ func NewScheduler(db *gorm.DB, client *redis.Client) *Scheduler {
 	}
 
 	punishService := services.NewPunishService(db, client)
+	punishService.NotificationService = services.NewNotificationService(db, client, nil)
+
+	// Badge changes made by jobs are published so the bot can sync Discord roles
+	eventService := services.NewEventService(db, client, nil)
+	badgeService.EventService = eventService
 
 	return &Scheduler{
 		DB:                  db,
@@ -37,6 +44,8 @@ func NewScheduler(db *gorm.DB, client *redis.Client) *Scheduler {
 		BadgeService:        badgeService,
 
 		PunishService:       punishService,
+		EventService:        eventService,
+		DomainService:       domainService,
 	}
 }
 
@@ -52,6 +61,37 @@ func (s *Scheduler) Start() {
 		job.Run()
 	})
 
+	go s.scheduleJob(15*time.Minute, func() {
+		job := NewReferralVerifyJob(s.DB, s.Client, s.BadgeService)
+		job.Run()
+	})
+
+	go s.scheduleJob(15*time.Minute, func() {
+		job := NewBadgeExpireJob(s.DB, s.Client, s.BadgeService)
+		job.Run()
+	})
+
+	go s.scheduleJob(1*time.Hour, func() {
+		job := NewBadgeRuleJob(s.DB, s.Client, s.BadgeService)
+		job.BadgeRuleService.NotificationService = s.PunishService.NotificationService
+		job.Run()
+	})
+
+	go s.scheduleJob(6*time.Hour, func() {
+		job := NewFileGCJob(s.DB, s.Client)
+		job.Run()
+	})
+
+	go s.scheduleJob(24*time.Hour, func() {
+		job := NewStorageReconcileJob(s.DB, s.Client)
+		job.Run()
+	})
+
+	go s.scheduleJob(12*time.Hour, func() {
+		job := NewDomainCertificateJob(s.DB, s.Client, s.DomainService)
+		job.Run()
+	})
+
 	go s.scheduleJob(1*time.Hour, func() {
 		// job := &PremiumExpireJob{
 		// 	DB:             s.DB,
@@ -83,6 +123,9 @@ func (s *Scheduler) RunOnce() {
 	}
 	job2.Run()
 
+	job4 := NewReferralVerifyJob(s.DB, s.Client, s.BadgeService)
+	job4.Run()
+
 	// job3 := &PremiumExpireJob{
 	// 	DB:             s.DB,
 	// 	Client:         s.Client,
@@ -0,0 +1,44 @@
+package jobs
+
+import (
+	"log"
+
+	"github.com/go-redis/redis"
+	"github.com/hazebio/haze.bio_backend/services"
+	"gorm.io/gorm"
+)
+
+// StorageReconcileJob reports drift between the bucket and the database.
+// Scheduled runs are dry runs, orphans are only deleted from the admin endpoint.
+type StorageReconcileJob struct {
+	DB             *gorm.DB
+	Client         *redis.Client
+	StorageService *services.StorageService
+	DryRun         bool
+}
+
+func NewStorageReconcileJob(db *gorm.DB, client *redis.Client) *StorageReconcileJob {
+	return &StorageReconcileJob{
+		DB:             db,
+		Client:         client,
+		StorageService: services.NewStorageService(db, client),
+		DryRun:         true,
+	}
+}
+
+func (j *StorageReconcileJob) Run() {
+	log.Println("Running storage reconcile job")
+
+	report, err := j.StorageService.Reconcile(j.DryRun)
+	if err != nil {
+		log.Printf("Error reconciling storage: %v", err)
+		return
+	}
+
+	for _, missing := range report.Missing {
+		log.Printf("Storage reconcile: %s %d %s points at missing object %s", missing.Table, missing.RowID, missing.Field, missing.URL)
+	}
+
+	log.Printf("Storage reconcile job completed, %d objects, %d orphans (%d bytes), %d deleted, %d missing",
+		report.Objects, len(report.Orphans), report.OrphanSize, report.Deleted, len(report.Missing))
+}
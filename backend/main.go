@@ -10,6 +10,7 @@ import (
 	"github.com/hazebio/haze.bio_backend/discord"
 	"github.com/hazebio/haze.bio_backend/jobs"
 	"github.com/hazebio/haze.bio_backend/redis"
+	"github.com/hazebio/haze.bio_backend/services"
 )
 
 func main() {
@@ -34,7 +35,10 @@ func main() {
 		return
 	}
 
-	jobScheduler := jobs.NewScheduler(db.DB, redisClient)
+	// Shared by the API, the TLS server and the certificate job so they use one certificate cache
+	domainService := services.NewDomainService(db.DB, redisClient)
+
+	jobScheduler := jobs.NewScheduler(db.DB, redisClient, domainService)
 	go jobScheduler.Start()
 	log.Println("Job scheduler initialized")
 
@@ -45,7 +49,7 @@ func main() {
 	}
 	discordBot.Start()
 
-	if err := app.StartServer(redisClient, discordBot); err != nil {
+	if err := app.StartServer(redisClient, discordBot, domainService); err != nil {
 		fmt.Println(err)
 		return
 	}
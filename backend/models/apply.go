@@ -1,6 +1,9 @@
 package models
 
 import (
+	"database/sql/driver"
+	"encoding/json"
+	"errors"
 	"time"
 )
 
@@ -14,8 +17,33 @@ const (
 	StatusRejected  ApplicationStatus = "rejected"
 
 	ApplicationRejectionCooldown = 7 // in days
+
+	ApplicationReviewQuorum = 3 // approve/reject votes needed before a decision is made
+	ApplicationMinScore     = 1
+	ApplicationMaxScore     = 5
+)
+
+type ReviewVote string
+
+const (
+	ReviewVoteApprove ReviewVote = "approve"
+	ReviewVoteReject  ReviewVote = "reject"
+	ReviewVoteAbstain ReviewVote = "abstain"
 )
 
+func IsValidReviewVote(vote ReviewVote) bool {
+	return vote == ReviewVoteApprove || vote == ReviewVoteReject || vote == ReviewVoteAbstain
+}
+
+// ApplicationRubric describes what each score means when rating an answer
+var ApplicationRubric = map[int]string{
+	1: "Insufficient - missing, off-topic or copied answer",
+	2: "Weak - answers the question with little effort or understanding",
+	3: "Adequate - meets the expectations of the role",
+	4: "Strong - thoughtful answer with relevant experience",
+	5: "Excellent - exceptional answer that stands out",
+}
+
 type InputType string
 
 const (
@@ -23,40 +51,195 @@ const (
 	InputTypeLongText  InputType = "long_text"
 	InputTypeSelect    InputType = "select"
 	InputTypeCheckbox  InputType = "checkbox"
+	InputTypeNumber    InputType = "number"
+	InputTypeDate      InputType = "date"
+	InputTypeURL       InputType = "url"
 )
 
+var InputTypes = []InputType{
+	InputTypeShortText,
+	InputTypeLongText,
+	InputTypeSelect,
+	InputTypeCheckbox,
+	InputTypeNumber,
+	InputTypeDate,
+	InputTypeURL,
+}
+
+func IsValidInputType(inputType InputType) bool {
+	for _, t := range InputTypes {
+		if t == inputType {
+			return true
+		}
+	}
+	return false
+}
+
+// Position is the API representation of a position together with one version of its questions
 type Position struct {
 	ID          string     `json:"id"`
 	Title       string     `json:"title"`
 	Description string     `json:"description"`
 	Active      bool       `json:"active"`
+	StaffLevel  uint       `json:"staff_level"` // granted when an application is approved
+	OpensAt     *time.Time `json:"opens_at,omitempty"`
+	ClosesAt    *time.Time `json:"closes_at,omitempty"`
+	Version     int        `json:"version"`
 	Questions   []Question `json:"questions"`
 }
 
+// IsOpen checks if the position is active and inside its application window
+func (p *Position) IsOpen(at time.Time) bool {
+	if !p.Active {
+		return false
+	}
+	if p.OpensAt != nil && at.Before(*p.OpensAt) {
+		return false
+	}
+	if p.ClosesAt != nil && at.After(*p.ClosesAt) {
+		return false
+	}
+	return true
+}
+
+func (p *Position) GetQuestion(questionID string) *Question {
+	for i := range p.Questions {
+		if p.Questions[i].ID == questionID {
+			return &p.Questions[i]
+		}
+	}
+	return nil
+}
+
+// IsQuestionVisible checks the conditional visibility of a question against the given answers
+func (p *Position) IsQuestionVisible(question *Question, answers map[string]string) bool {
+	if question.VisibleIf == nil {
+		return true
+	}
+
+	answer := answers[question.VisibleIf.QuestionID]
+	if len(question.VisibleIf.Equals) == 0 {
+		return answer != ""
+	}
+
+	for _, value := range question.VisibleIf.Equals {
+		if answer == value {
+			return true
+		}
+	}
+	return false
+}
+
 type Question struct {
-	ID        string    `json:"id"`
-	Title     string    `json:"title"`
-	Subtitle  string    `json:"subtitle"`
-	InputType InputType `json:"input_type"`
-	Required  bool      `json:"required"`
-	Options   []string  `json:"options"` // input type
-	SortOrder uint      `json:"sort_order"`
+	ID         string              `json:"id"`
+	Title      string              `json:"title"`
+	Subtitle   string              `json:"subtitle"`
+	InputType  InputType           `json:"input_type"`
+	Required   bool                `json:"required"`
+	Options    []string            `json:"options"` // input type
+	SortOrder  uint                `json:"sort_order"`
+	Validation *QuestionValidation `json:"validation,omitempty"`
+	VisibleIf  *QuestionCondition  `json:"visible_if,omitempty"`
+}
+
+// QuestionValidation holds optional rules for an answer. Length rules apply to text based
+// input types, Min and Max to numbers and Pattern is a regular expression for text answers.
+type QuestionValidation struct {
+	MinLength int      `json:"min_length,omitempty"`
+	MaxLength int      `json:"max_length,omitempty"`
+	Min       *float64 `json:"min,omitempty"`
+	Max       *float64 `json:"max,omitempty"`
+	Pattern   string   `json:"pattern,omitempty"`
+}
+
+// QuestionCondition only shows a question when an earlier question was answered with one of
+// the given values, or was answered at all if no values are given
+type QuestionCondition struct {
+	QuestionID string   `json:"question_id"`
+	Equals     []string `json:"equals,omitempty"`
+}
+
+type QuestionList []Question
+
+func (ql *QuestionList) Scan(value interface{}) error {
+	var bytes []byte
+	switch v := value.(type) {
+	case string:
+		bytes = []byte(v)
+	case []byte:
+		bytes = v
+	default:
+		return errors.New("failed to unmarshal QuestionList value")
+	}
+
+	return json.Unmarshal(bytes, ql)
+}
+
+func (ql QuestionList) Value() (driver.Value, error) {
+	return json.Marshal(ql)
+}
+
+type ApplicationPosition struct {
+	ID             string     `json:"id" gorm:"primaryKey;type:varchar(50)"`
+	Title          string     `json:"title" gorm:"not null;type:varchar(100)"`
+	Description    string     `json:"description" gorm:"type:text"`
+	Active         bool       `json:"active" gorm:"default:false"`
+	StaffLevel     uint       `json:"staff_level"` // 0 grants no staff level
+	OpensAt        *time.Time `json:"opens_at" gorm:"default:null"`
+	ClosesAt       *time.Time `json:"closes_at" gorm:"default:null"`
+	CurrentVersion int        `json:"current_version" gorm:"not null;default:1"`
+	CreatedAt      time.Time  `json:"created_at" gorm:"autoCreateTime"`
+	UpdatedAt      time.Time  `json:"updated_at" gorm:"autoUpdateTime"`
+}
+
+// ApplicationFormVersion is an immutable snapshot of the questions of a position.
+// Every edit creates a new version so submitted responses keep pointing at the questions they answered.
+type ApplicationFormVersion struct {
+	ID         uint         `json:"id" gorm:"primaryKey;autoIncrement"`
+	PositionID string       `json:"position_id" gorm:"type:varchar(50);uniqueIndex:idx_form_version_position_version;not null"`
+	Version    int          `json:"version" gorm:"uniqueIndex:idx_form_version_position_version;not null"`
+	Questions  QuestionList `json:"questions" gorm:"type:json;not null"`
+	CreatedBy  uint         `json:"created_by" gorm:"default:0"`
+	CreatedAt  time.Time    `json:"created_at" gorm:"autoCreateTime"`
+}
+
+// ToPosition combines the position with one of its question versions
+func (ap *ApplicationPosition) ToPosition(version *ApplicationFormVersion) *Position {
+	position := &Position{
+		ID:          ap.ID,
+		Title:       ap.Title,
+		Description: ap.Description,
+		Active:      ap.Active,
+		StaffLevel:  ap.StaffLevel,
+		OpensAt:     ap.OpensAt,
+		ClosesAt:    ap.ClosesAt,
+		Questions:   []Question{},
+	}
+
+	if version != nil {
+		position.Version = version.Version
+		position.Questions = version.Questions
+	}
+
+	return position
 }
 
 type Application struct {
-	ID             uint              `json:"id" gorm:"primaryKey;autoIncrement"`
-	UserID         uint              `json:"user_id" gorm:"not null;index"`
-	PositionID     string            `json:"position_id" gorm:"not null;index"`
-	Status         ApplicationStatus `json:"status" gorm:"type:varchar(20);default:'draft'"`
-	StartedAt      time.Time         `json:"started_at" gorm:"autoCreateTime"`
-	LastUpdatedAt  time.Time         `json:"last_updated_at" gorm:"autoUpdateTime"`
-	SubmittedAt    *time.Time        `json:"submitted_at"`
-	ExpiresAt      time.Time         `json:"expires_at"`
-	TimeToComplete int64             `json:"time_to_complete"` // Time in seconds
-	ReviewedBy     *uint             `json:"reviewed_by"`
-	ReviewedAt     *time.Time        `json:"reviewed_at"`
-	FeedbackNote   string            `json:"feedback_note" gorm:"type:text"`
-	Responses      []Response        `json:"responses" gorm:"foreignKey:ApplicationID;references:ID;constraint:OnDelete:CASCADE"`
+	ID             uint                `json:"id" gorm:"primaryKey;autoIncrement"`
+	UserID         uint                `json:"user_id" gorm:"not null;index"`
+	PositionID     string              `json:"position_id" gorm:"not null;index"`
+	FormVersion    int                 `json:"form_version" gorm:"not null;default:1"`
+	Status         ApplicationStatus   `json:"status" gorm:"type:varchar(20);default:'draft'"`
+	StartedAt      time.Time           `json:"started_at" gorm:"autoCreateTime"`
+	LastUpdatedAt  time.Time           `json:"last_updated_at" gorm:"autoUpdateTime"`
+	SubmittedAt    *time.Time          `json:"submitted_at"`
+	ExpiresAt      time.Time           `json:"expires_at"`
+	TimeToComplete int64               `json:"time_to_complete"` // Time in seconds
+	ReviewedBy     *uint               `json:"reviewed_by"`
+	ReviewedAt     *time.Time          `json:"reviewed_at"`
+	FeedbackNote   string              `json:"feedback_note" gorm:"type:text"`
+	Responses      []Response          `json:"responses" gorm:"foreignKey:ApplicationID;references:ID;constraint:OnDelete:CASCADE"`
+	Reviews        []ApplicationReview `json:"-" gorm:"foreignKey:ApplicationID;references:ID;constraint:OnDelete:CASCADE"`
 }
 
 type Response struct {
@@ -64,15 +247,93 @@ type Response struct {
 	ApplicationID uint      `json:"application_id" gorm:"not null;index"`
 	QuestionID    string    `json:"question_id" gorm:"not null"`
 	Answer        string    `json:"answer" gorm:"type:text"`
-	TimeToAnswer  int64     `json:"time_to_answer"` // Time in seconds
+	TimeToAnswer  int64     `json:"time_to_answer"`                // Time in seconds
+	PasteEvents   int       `json:"paste_events" gorm:"default:0"` // reported by the client
 	CreatedAt     time.Time `json:"created_at" gorm:"autoCreateTime"`
 	UpdatedAt     time.Time `json:"updated_at" gorm:"autoUpdateTime"`
 }
 
+// ApplicationReview is the vote of a single staff member. Comments are only visible to staff.
+type ApplicationReview struct {
+	ID            uint               `json:"id" gorm:"primaryKey;autoIncrement"`
+	ApplicationID uint               `json:"application_id" gorm:"uniqueIndex:idx_application_review_reviewer;not null"`
+	ReviewerID    uint               `json:"reviewer_id" gorm:"uniqueIndex:idx_application_review_reviewer;not null"`
+	Vote          ReviewVote         `json:"vote" gorm:"type:varchar(20);not null"`
+	Comment       string             `json:"comment" gorm:"type:text"`
+	CreatedAt     time.Time          `json:"created_at" gorm:"autoCreateTime"`
+	UpdatedAt     time.Time          `json:"updated_at" gorm:"autoUpdateTime"`
+	Scores        []ApplicationScore `json:"scores" gorm:"foreignKey:ReviewID;references:ID;constraint:OnDelete:CASCADE"`
+}
+
+type ApplicationScore struct {
+	ID         uint   `json:"id" gorm:"primaryKey;autoIncrement"`
+	ReviewID   uint   `json:"review_id" gorm:"index;not null"`
+	QuestionID string `json:"question_id" gorm:"not null"`
+	Score      int    `json:"score" gorm:"not null"`
+}
+
+type ApplicationReviewSummary struct {
+	Reviews        int                `json:"reviews"`
+	Approvals      int                `json:"approvals"`
+	Rejections     int                `json:"rejections"`
+	Abstentions    int                `json:"abstentions"`
+	Quorum         int                `json:"quorum"`
+	AverageScore   float64            `json:"average_score"`
+	QuestionScores map[string]float64 `json:"question_scores"`
+	Decision       ApplicationStatus  `json:"decision,omitempty"`
+}
+
+type RiskSignalType string
+
+const (
+	RiskSignalPasted        RiskSignalType = "pasted"
+	RiskSignalFastAnswer    RiskSignalType = "fast_answer"
+	RiskSignalFastComplete  RiskSignalType = "fast_completion"
+	RiskSignalSimilarAnswer RiskSignalType = "similar_answer"
+	RiskSignalAltAccount    RiskSignalType = "alt_account"
+	RiskSignalPunishment    RiskSignalType = "punishment_history"
+)
+
+type RiskLevel string
+
+const (
+	RiskLevelLow    RiskLevel = "low"
+	RiskLevelMedium RiskLevel = "medium"
+	RiskLevelHigh   RiskLevel = "high"
+)
+
+type ApplicationRiskSignal struct {
+	Type       RiskSignalType `json:"type"`
+	Weight     int            `json:"weight"`
+	QuestionID string         `json:"question_id,omitempty"`
+	Message    string         `json:"message"`
+}
+
+type ApplicationRiskSummary struct {
+	Score   int                     `json:"score"`
+	Level   RiskLevel               `json:"level"`
+	Signals []ApplicationRiskSignal `json:"signals"`
+}
+
+func (rs *ApplicationRiskSummary) Add(signal ApplicationRiskSignal) {
+	rs.Signals = append(rs.Signals, signal)
+	rs.Score += signal.Weight
+
+	switch {
+	case rs.Score >= 60:
+		rs.Level = RiskLevelHigh
+	case rs.Score >= 25:
+		rs.Level = RiskLevelMedium
+	default:
+		rs.Level = RiskLevelLow
+	}
+}
+
 type ApplicationSession struct {
 	ApplicationID   uint              `json:"application_id"`
 	UserID          uint              `json:"user_id"`
 	PositionID      string            `json:"position_id"`
+	FormVersion     int               `json:"form_version"`
 	CurrentQuestion int               `json:"current_question"`
 	Answers         map[string]string `json:"answers"`
 	TimePerQuestion map[string]int64  `json:"time_per_question"`
@@ -81,12 +342,14 @@ type ApplicationSession struct {
 	ExpiresAt       time.Time         `json:"expires_at"`
 }
 
-var AvailablePositions = []Position{
+// DefaultPositions are seeded into the database as version 1 when no positions exist yet
+var DefaultPositions = []Position{
 	{
 		ID:          "moderator",
 		Title:       "Community Moderator",
 		Description: "Help maintain a healthy community by enforcing rules and assisting users.",
 		Active:      true,
+		StaffLevel:  1, // Trial Moderator
 		Questions: []Question{
 			{
 				ID:        "mod_exp",
@@ -200,6 +463,7 @@ var AvailablePositions = []Position{
 		Title:       "Concept Creator",
 		Description: "Develop creative and innovative concepts for new features or community events.",
 		Active:      true,
+		StaffLevel:  1, // Trial Moderator
 		Questions: []Question{
 			{
 				ID:        "concept_exp",
@@ -301,26 +565,3 @@ var AvailablePositions = []Position{
 		},
 	},
 }
-
-func GetPositionByID(positionID string) *Position {
-	for _, position := range AvailablePositions {
-		if position.ID == positionID {
-			return &position
-		}
-	}
-	return nil
-}
-
-func GetQuestionByID(positionID string, questionID string) *Question {
-	position := GetPositionByID(positionID)
-	if position == nil {
-		return nil
-	}
-
-	for _, question := range position.Questions {
-		if question.ID == questionID {
-			return &question
-		}
-	}
-	return nil
-}
@@ -2,12 +2,131 @@ package models
 
 import "time"
 
+type BadgeRarity string
+
+const (
+	BadgeRarityCommon    BadgeRarity = "common"
+	BadgeRarityUncommon  BadgeRarity = "uncommon"
+	BadgeRarityRare      BadgeRarity = "rare"
+	BadgeRarityEpic      BadgeRarity = "epic"
+	BadgeRarityLegendary BadgeRarity = "legendary"
+)
+
+var BadgeRarities = []BadgeRarity{
+	BadgeRarityCommon,
+	BadgeRarityUncommon,
+	BadgeRarityRare,
+	BadgeRarityEpic,
+	BadgeRarityLegendary,
+}
+
+func IsValidBadgeRarity(rarity BadgeRarity) bool {
+	for _, r := range BadgeRarities {
+		if r == rarity {
+			return true
+		}
+	}
+	return false
+}
+
+const DefaultBadgeCategory = "general"
+
 type Badge struct {
-	ID            uint      `json:"id" gorm:"primaryKey;autoIncrement"`
-	Name          string    `json:"name" gorm:"unique;not null"`
-	MediaURL      string    `json:"media_url" gorm:"not null"`
-	IsCustom      bool      `json:"is_custom" gorm:"default:false"`
-	DiscordRoleID string    `json:"discord_role_id"`
-	CreatedAt     time.Time `json:"created_at"`
-	UpdatedAt     time.Time `json:"updated_at"`
+	ID          uint        `json:"id" gorm:"primaryKey;autoIncrement"`
+	Name        string      `json:"name" gorm:"unique;not null"`
+	Description string      `json:"description" gorm:"default:null"`
+	Category    string      `json:"category" gorm:"type:varchar(50);index;default:'general'"`
+	Rarity      BadgeRarity `json:"rarity" gorm:"type:varchar(20);default:'common'"`
+	MediaURL    string      `json:"media_url" gorm:"not null"`
+	IsCustom    bool        `json:"is_custom" gorm:"default:false"`
+	// Deprecated: roles are mapped through BadgeRole. Kept so the column can
+	// be migrated into badge_roles on existing databases.
+	DiscordRoleID string      `json:"discord_role_id"`
+	Roles         []BadgeRole `json:"roles,omitempty" gorm:"foreignKey:BadgeID"`
+	Rules         []BadgeRule `json:"rules,omitempty" gorm:"foreignKey:BadgeID"`
+	CreatedAt     time.Time   `json:"created_at"`
+	UpdatedAt     time.Time   `json:"updated_at"`
+}
+
+// BadgeRole maps a badge to a Discord role in one guild. A badge may grant
+// several roles and the same role may be granted by several badges.
+type BadgeRole struct {
+	ID        uint      `json:"id" gorm:"primaryKey;autoIncrement"`
+	BadgeID   uint      `json:"badge_id" gorm:"not null;uniqueIndex:idx_badge_role"`
+	GuildID   string    `json:"guild_id" gorm:"type:varchar(32);index"`
+	RoleID    string    `json:"role_id" gorm:"type:varchar(32);not null;uniqueIndex:idx_badge_role;index"`
+	Badge     *Badge    `json:"badge,omitempty" gorm:"foreignKey:BadgeID"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+type BadgeRuleType string
+
+const (
+	// Threshold is the number of profile views
+	BadgeRuleProfileViews BadgeRuleType = "profile_views"
+	// Threshold is the account age in days
+	BadgeRuleAccountAge BadgeRuleType = "account_age"
+	// Threshold is the number of verified referrals
+	BadgeRuleReferrals BadgeRuleType = "referrals"
+	// Users with an active premium subscription, Threshold is unused
+	BadgeRulePremium BadgeRuleType = "premium"
+)
+
+// BadgeRule awards a badge automatically once a user meets its condition.
+// Revocable rules take the badge back when the condition stops holding,
+// rules with a duration grant the badge for a limited number of days. Rules
+// created without Active set are active.
+type BadgeRule struct {
+	ID           uint          `json:"id" gorm:"primaryKey;autoIncrement"`
+	BadgeID      uint          `json:"badge_id" gorm:"index;not null"`
+	Type         BadgeRuleType `json:"type" gorm:"type:varchar(30);not null"`
+	Threshold    int           `json:"threshold" gorm:"default:0"`
+	DurationDays int           `json:"duration_days" gorm:"default:0"`
+	Revocable    bool          `json:"revocable" gorm:"default:false"`
+	Active       *bool         `json:"active" gorm:"default:true"`
+	Badge        *Badge        `json:"badge,omitempty" gorm:"foreignKey:BadgeID"`
+	CreatedAt    time.Time     `json:"created_at" gorm:"autoCreateTime"`
+	UpdatedAt    time.Time     `json:"updated_at" gorm:"autoUpdateTime"`
+}
+
+// BadgeRuleGrant records that a rule already awarded its badge to a user, so
+// time-limited or manually removed badges are not granted again
+type BadgeRuleGrant struct {
+	ID        uint      `json:"id" gorm:"primaryKey;autoIncrement"`
+	RuleID    uint      `json:"rule_id" gorm:"uniqueIndex:idx_badge_rule_grant_rule_uid;not null"`
+	UID       uint      `json:"uid" gorm:"uniqueIndex:idx_badge_rule_grant_rule_uid;not null"`
+	GrantedAt time.Time `json:"granted_at" gorm:"autoCreateTime"`
+}
+
+type BadgeEditStatus string
+
+const (
+	BadgeEditPending  BadgeEditStatus = "pending"
+	BadgeEditApproved BadgeEditStatus = "approved"
+	BadgeEditRejected BadgeEditStatus = "rejected"
+)
+
+// BadgeEdit is a change to the name or media of a custom badge waiting for
+// staff review. The badge keeps its current name and media until the edit is
+// approved, rejected edits refund the badge edit credit.
+type BadgeEdit struct {
+	ID          uint            `json:"id" gorm:"primaryKey;autoIncrement"`
+	BadgeID     uint            `json:"badge_id" gorm:"not null;uniqueIndex:idx_badge_edit_pending,where:status = 'pending'"`
+	UID         uint            `json:"uid" gorm:"index;not null"`
+	OldName     string          `json:"old_name"`
+	NewName     string          `json:"new_name"`
+	OldMediaURL string          `json:"old_media_url"`
+	NewMediaURL string          `json:"new_media_url"`
+	Status      BadgeEditStatus `json:"status" gorm:"type:varchar(20);index;default:'pending'"`
+	ReviewedBy  uint            `json:"reviewed_by" gorm:"default:0"`
+	Reason      string          `json:"reason,omitempty"`
+	ReviewedAt  *time.Time      `json:"reviewed_at,omitempty"`
+	CreatedAt   time.Time       `json:"created_at" gorm:"autoCreateTime"`
+	UpdatedAt   time.Time       `json:"updated_at" gorm:"autoUpdateTime"`
+}
+
+type BadgeEditWithDetails struct {
+	BadgeEdit
+	Username         string `json:"username"`
+	ReviewerUsername string `json:"reviewer_username,omitempty"`
 }
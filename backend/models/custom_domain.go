@@ -0,0 +1,53 @@
+package models
+
+import "time"
+
+const (
+	CertificateNone    = "none"
+	CertificatePending = "pending"
+	CertificateIssued  = "issued"
+	CertificateFailed  = "failed"
+)
+
+// CustomDomain points a domain a user owns at their profile. It only resolves
+// once ownership is proven with a DNS TXT record, after which a certificate is
+// ordered for it through ACME.
+type CustomDomain struct {
+	ID                uint       `json:"id" gorm:"primaryKey;autoIncrement"`
+	UID               uint       `json:"uid" gorm:"uniqueIndex;not null"`
+	Domain            string     `json:"domain" gorm:"type:varchar(253);uniqueIndex;not null"`
+	VerificationToken string     `json:"verification_token" gorm:"type:varchar(64);not null"`
+	VerifiedAt        *time.Time `json:"verified_at,omitempty"`
+	LastCheckedAt     *time.Time `json:"last_checked_at,omitempty"`
+	// Status of the certificate and why the last order failed
+	CertificateStatus    string     `json:"certificate_status" gorm:"type:varchar(10);default:none"`
+	CertificateError     string     `json:"certificate_error,omitempty"`
+	CertificateExpiresAt *time.Time `json:"certificate_expires_at,omitempty"`
+	// Orders failed in a row, the next one isn't attempted before CertificateRetryAt
+	CertificateFailures int        `json:"-" gorm:"default:0"`
+	CertificateRetryAt  *time.Time `json:"certificate_retry_at,omitempty"`
+	// PEM certificate chain and encrypted PEM private key
+	Certificate    string    `json:"-" gorm:"type:text"`
+	CertificateKey string    `json:"-" gorm:"type:text"`
+	CreatedAt      time.Time `json:"created_at" gorm:"autoCreateTime"`
+	UpdatedAt      time.Time `json:"updated_at" gorm:"autoUpdateTime"`
+
+	/* Virtual fields */
+	Verification *DomainVerification `json:"verification,omitempty" gorm:"-"`
+	Target       string              `json:"target,omitempty" gorm:"-"`
+}
+
+func (d *CustomDomain) IsVerified() bool {
+	return d.VerifiedAt != nil
+}
+
+// DomainVerification is the DNS record that proves ownership of a domain
+type DomainVerification struct {
+	Type  string `json:"type"`
+	Name  string `json:"name"`
+	Value string `json:"value"`
+}
+
+type CustomDomainRequest struct {
+	Domain string `json:"domain"`
+}
@@ -0,0 +1,28 @@
+package models
+
+import "time"
+
+// DiscordGuild is the per-server configuration of the Discord integration.
+// The primary guild is preferred for presence, avatars and the moderation
+// queue; every enabled guild gets slash commands, notifications and role sync.
+type DiscordGuild struct {
+	GuildID               string `json:"guild_id" gorm:"primaryKey;type:varchar(32)"`
+	Name                  string `json:"name" gorm:"type:varchar(100);not null"`
+	IsPrimary             bool   `json:"is_primary" gorm:"default:false"`
+	Enabled               *bool  `json:"enabled" gorm:"default:true"`
+	RegistrationChannelID string `json:"registration_channel_id" gorm:"type:varchar(32)"`
+	NotificationChannelID string `json:"notification_channel_id" gorm:"type:varchar(32)"`
+	ModerationChannelID   string `json:"moderation_channel_id" gorm:"type:varchar(32)"`
+	LinkedRoleID          string `json:"linked_role_id" gorm:"type:varchar(32)"`
+	// Badge given to members boosting this guild, must not be shared with another guild
+	BoosterBadge       string    `json:"booster_badge" gorm:"type:varchar(100)"`
+	BoosterEditCredits int       `json:"booster_edit_credits" gorm:"default:0"`
+	CreatedAt          time.Time `json:"created_at"`
+	UpdatedAt          time.Time `json:"updated_at"`
+}
+
+// IsEnabled reports whether events from the guild are handled, guilds created
+// without Enabled set are enabled
+func (g *DiscordGuild) IsEnabled() bool {
+	return g.Enabled == nil || *g.Enabled
+}
@@ -0,0 +1,21 @@
+package models
+
+// OEmbedResponse is an oEmbed 1.0 response, rich responses embed the profile
+// view in an iframe and photo responses point at the profile card
+type OEmbedResponse struct {
+	Version         string `json:"version"`
+	Type            string `json:"type"`
+	Title           string `json:"title"`
+	AuthorName      string `json:"author_name"`
+	AuthorURL       string `json:"author_url"`
+	ProviderName    string `json:"provider_name"`
+	ProviderURL     string `json:"provider_url"`
+	CacheAge        int    `json:"cache_age"`
+	ThumbnailURL    string `json:"thumbnail_url"`
+	ThumbnailWidth  int    `json:"thumbnail_width"`
+	ThumbnailHeight int    `json:"thumbnail_height"`
+	HTML            string `json:"html,omitempty"`
+	URL             string `json:"url,omitempty"`
+	Width           int    `json:"width"`
+	Height          int    `json:"height"`
+}
@@ -15,7 +15,16 @@ const (
 	EventAltAccountDetected EventType = "user.alt_account_detected"
 	EventUserDeleted        EventType = "user.deleted"
 	EventDiscordLinked      EventType = "user.discord_linked"
+	EventDiscordUnlinked    EventType = "user.discord_unlinked"
+	EventBadgeAssigned      EventType = "badge.assigned"
+	EventBadgeRemoved       EventType = "badge.removed"
+	EventBadgeRolesUpdated  EventType = "badge.roles_updated"
+	EventBadgeEditSubmitted EventType = "badge.edit_submitted"
+	EventBadgeEditReviewed  EventType = "badge.edit_reviewed"
+	EventGuildUpdated       EventType = "discord.guild_updated"
 	EventRedeemCodeUsed     EventType = "redeem.code_used"
+	EventReportCreated      EventType = "report.created"
+	EventReportUpdated      EventType = "report.updated"
 )
 
 type Event struct {
@@ -64,6 +73,13 @@ type UserLoginData struct {
 	LoginTime time.Time `json:"login_time"`
 }
 
+type ReportEventData struct {
+	ReportID       uint `json:"report_id"`
+	ReportedUserID uint `json:"reported_user_id"`
+	Handled        bool `json:"handled"`
+	HandledBy      uint `json:"handled_by"`
+}
+
 type AltAccountData struct {
 	UID             uint                 `json:"uid"`              // Current user's UID
 	Username        string               `json:"username"`         // Current user's username
@@ -92,6 +108,36 @@ type DiscordLinkedData struct {
 	LinkedAt        time.Time `json:"linked_at"`
 }
 
+type DiscordUnlinkedData struct {
+	UID       uint   `json:"uid"`
+	DiscordID string `json:"discord_id"`
+}
+
+type BadgeEventData struct {
+	UID       uint   `json:"uid"`
+	BadgeID   uint   `json:"badge_id"`
+	BadgeName string `json:"badge_name"`
+}
+
+type BadgeRolesUpdatedData struct {
+	BadgeName string `json:"badge_name"`
+	GuildID   string `json:"guild_id"`
+	// Set when a mapping was removed so holders lose the role
+	RevokedRoleID string `json:"revoked_role_id,omitempty"`
+}
+
+type BadgeEditEventData struct {
+	EditID  uint            `json:"edit_id"`
+	UID     uint            `json:"uid"`
+	BadgeID uint            `json:"badge_id"`
+	Status  BadgeEditStatus `json:"status"`
+}
+
+type GuildUpdatedData struct {
+	GuildID string `json:"guild_id"`
+	Deleted bool   `json:"deleted"`
+}
+
 type RedeemCodeData struct {
 	UID         uint      `json:"uid"`
 	Username    string    `json:"username"`
@@ -9,9 +9,10 @@ type InviteCode struct {
 	Code        string    `json:"code" gorm:"unique;not null;type:varchar(20)"`
 	CreatedBy   uint      `json:"created_by" gorm:"not null"` // User ID who created this code
 	UsedBy      *uint     `json:"used_by" gorm:"default:null"` // User ID who used this code (if single use)
-	MaxUses     int       `json:"max_uses" gorm:"default:1"`   // Maximum number of uses (0 = unlimited)
+	MaxUses     *int      `json:"max_uses" gorm:"default:1"`   // Maximum number of uses (0 = unlimited)
 	CurrentUses int       `json:"current_uses" gorm:"default:0"` // Current number of uses
 	ExpiresAt   *time.Time `json:"expires_at" gorm:"default:null"` // When the code expires
+	IsReferral  bool      `json:"is_referral" gorm:"default:false"` // Personal referral code of the creator
 	CreatedAt   time.Time `json:"created_at" gorm:"autoCreateTime"`
 	UpdatedAt   time.Time `json:"updated_at" gorm:"autoUpdateTime"`
 
@@ -20,6 +21,14 @@ type InviteCode struct {
 	User    *User `json:"user" gorm:"foreignKey:UsedBy;references:UID"`
 }
 
+// Limit returns the maximum number of uses, 0 if unlimited
+func (ic *InviteCode) Limit() int {
+	if ic.MaxUses == nil {
+		return 0
+	}
+	return *ic.MaxUses
+}
+
 // IsValid checks if the invite code is still valid
 func (ic *InviteCode) IsValid() bool {
 	// Check if expired
@@ -28,7 +37,7 @@ func (ic *InviteCode) IsValid() bool {
 	}
 
 	// Check if max uses reached
-	if ic.MaxUses > 0 && ic.CurrentUses >= ic.MaxUses {
+	if ic.Limit() > 0 && ic.CurrentUses >= ic.Limit() {
 		return false
 	}
 
@@ -43,7 +52,7 @@ func (ic *InviteCode) CanBeUsed() bool {
 // Use increments the usage count
 func (ic *InviteCode) Use(userID uint) {
 	ic.CurrentUses++
-	if ic.MaxUses == 1 {
+	if ic.Limit() == 1 {
 		ic.UsedBy = &userID
 	}
 }
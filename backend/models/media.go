@@ -0,0 +1,49 @@
+package models
+
+import (
+	"database/sql/driver"
+	"encoding/json"
+	"errors"
+)
+
+// MediaVariant is one processed rendition of an uploaded file, such as a
+// resized WebP, its fallback or the poster frame of a video
+type MediaVariant struct {
+	Label  string `json:"label"`
+	URL    string `json:"url"`
+	Format string `json:"format"`
+	Width  int    `json:"width,omitempty"`
+	Height int    `json:"height,omitempty"`
+}
+
+// ProcessedMedia holds the variants generated for the file a profile field
+// points at. Source is the URL the variants were made from, variants whose
+// source no longer matches the field are stale and should be ignored.
+type ProcessedMedia struct {
+	Source   string         `json:"source"`
+	Variants []MediaVariant `json:"variants"`
+}
+
+// MediaVariants maps a profile field such as avatar_url to its processed media
+type MediaVariants map[string]ProcessedMedia
+
+func (mv *MediaVariants) Scan(value interface{}) error {
+	if value == nil {
+		*mv = nil
+		return nil
+	}
+
+	bytes, ok := value.([]byte)
+	if !ok {
+		return errors.New("failed to unmarshal MediaVariants value")
+	}
+
+	return json.Unmarshal(bytes, mv)
+}
+
+func (mv MediaVariants) Value() (driver.Value, error) {
+	if mv == nil {
+		return nil, nil
+	}
+	return json.Marshal(mv)
+}
@@ -0,0 +1,62 @@
+package models
+
+import "time"
+
+type NotificationCategory string
+
+const (
+	NotificationCategorySecurity   NotificationCategory = "security"
+	NotificationCategoryModeration NotificationCategory = "moderation"
+	NotificationCategoryBilling    NotificationCategory = "billing"
+	NotificationCategorySocial     NotificationCategory = "social"
+)
+
+var NotificationCategories = []NotificationCategory{
+	NotificationCategorySecurity,
+	NotificationCategoryModeration,
+	NotificationCategoryBilling,
+	NotificationCategorySocial,
+}
+
+type Notification struct {
+	ID        uint                 `json:"id" gorm:"primaryKey;autoIncrement"`
+	UID       uint                 `json:"uid" gorm:"index;not null;constraint:OnDelete:CASCADE;"`
+	Category  NotificationCategory `json:"category" gorm:"type:varchar(20);index;not null"`
+	Title     string               `json:"title" gorm:"type:varchar(255);not null"`
+	Message   string               `json:"message" gorm:"type:text;not null"`
+	Link      string               `json:"link" gorm:"default:null"`
+	Read      bool                 `json:"read" gorm:"index;not null"`
+	ReadAt    *time.Time           `json:"read_at" gorm:"default:null"`
+	CreatedAt time.Time            `json:"created_at" gorm:"autoCreateTime"`
+}
+
+// NotificationPreference holds the delivery channels a user enabled for one category
+type NotificationPreference struct {
+	ID        uint                 `json:"-" gorm:"primaryKey;autoIncrement"`
+	UID       uint                 `json:"-" gorm:"uniqueIndex:idx_notification_pref_uid_category;not null;constraint:OnDelete:CASCADE;"`
+	Category  NotificationCategory `json:"category" gorm:"type:varchar(20);uniqueIndex:idx_notification_pref_uid_category;not null"`
+	InApp     bool                 `json:"in_app" gorm:"not null"`
+	Email     bool                 `json:"email" gorm:"not null"`
+	Discord   bool                 `json:"discord" gorm:"not null"`
+	UpdatedAt time.Time            `json:"updated_at" gorm:"autoUpdateTime"`
+}
+
+// DefaultNotificationPreferences is used for categories a user has not configured yet
+var DefaultNotificationPreferences = map[NotificationCategory]NotificationPreference{
+	NotificationCategorySecurity:   {Category: NotificationCategorySecurity, InApp: true, Email: true, Discord: true},
+	NotificationCategoryModeration: {Category: NotificationCategoryModeration, InApp: true, Email: false, Discord: true},
+	NotificationCategoryBilling:    {Category: NotificationCategoryBilling, InApp: true, Email: true, Discord: false},
+	NotificationCategorySocial:     {Category: NotificationCategorySocial, InApp: true, Email: false, Discord: false},
+}
+
+// ViewMilestones are the profile view counts that trigger a social notification
+var ViewMilestones = []uint{100, 500, 1000, 5000, 10000, 25000, 50000, 100000, 250000, 500000, 1000000}
+
+func IsValidNotificationCategory(category NotificationCategory) bool {
+	for _, c := range NotificationCategories {
+		if c == category {
+			return true
+		}
+	}
+	return false
+}
@@ -9,6 +9,7 @@ type PremiumFeatures struct {
 	LayoutMaxWidth       bool
 	AllowHTMLDescription bool
 	ParallaxEffect       bool
+	CustomDomain         bool
 }
 
 func NewPremiumFeatures() *PremiumFeatures {
@@ -31,6 +32,7 @@ func NewPremiumFeatures() *PremiumFeatures {
 		LayoutMaxWidth:       true,
 		AllowHTMLDescription: true,
 		ParallaxEffect:       true,
+		CustomDomain:         true,
 	}
 }
 
@@ -65,3 +67,7 @@ func (pf *PremiumFeatures) IsLayoutMaxWidthPremium() bool {
 func (pf *PremiumFeatures) IsAllowHTMLDescriptionPremium() bool {
 	return pf.AllowHTMLDescription
 }
+
+func (pf *PremiumFeatures) IsCustomDomainPremium() bool {
+	return pf.CustomDomain
+}
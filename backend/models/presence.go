@@ -0,0 +1,42 @@
+package models
+
+import "time"
+
+type DiscordPresence struct {
+	UID          uint                  `json:"uid"`
+	Username     string                `json:"username"`
+	Avatar       string                `json:"avatar"`
+	Status       string                `json:"status"`
+	Activity     *PresenceActivity     `json:"activity"`
+	CustomStatus *PresenceCustomStatus `json:"custom_status"`
+	Badges       []map[string]string   `json:"badges"`
+	UpdatedAt    time.Time             `json:"updated_at"`
+}
+
+type PresenceActivity struct {
+	Type       string `json:"type"` // playing, streaming, listening, watching or competing
+	Name       string `json:"name"`
+	Text       string `json:"text"`
+	State      string `json:"state,omitempty"`
+	Details    string `json:"details,omitempty"`
+	CoverImage string `json:"cover_image,omitempty"`
+	LargeText  string `json:"large_text,omitempty"`
+}
+
+type PresenceCustomStatus struct {
+	Text  string         `json:"text,omitempty"`
+	Emoji *PresenceEmoji `json:"emoji,omitempty"`
+}
+
+type PresenceEmoji struct {
+	ID       string `json:"id,omitempty"`
+	Name     string `json:"name"`
+	Animated bool   `json:"animated"`
+	URL      string `json:"url,omitempty"`
+}
+
+// PresenceMessage is sent to websocket subscribers, "snapshot" on connect and "update" afterwards
+type PresenceMessage struct {
+	Type     string           `json:"type"`
+	Presence *DiscordPresence `json:"presence"`
+}
@@ -37,6 +37,9 @@ type Report struct {
 	CreatedAt      time.Time `json:"created_at" gorm:"autoCreateTime"`
 }
 
+// Reports raised automatically, such as for flagged uploads, have no reporting user
+const SystemReporterID uint = 0
+
 type ReportWithDetails struct {
 	Report
 	ReportedUsername    string   `json:"reported_username"`
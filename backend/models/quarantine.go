@@ -0,0 +1,36 @@
+package models
+
+import "time"
+
+const (
+	QuarantinePending  = "pending"
+	QuarantineReleased = "released"
+	QuarantineDeleted  = "deleted"
+)
+
+// QuarantinedFile is an upload a content scanner refused to publish. The
+// object is stored with signed access only, so it is never served publicly,
+// until a moderator releases it to the uploader's media library or deletes it.
+type QuarantinedFile struct {
+	ID    uint   `json:"id" gorm:"primaryKey;autoIncrement"`
+	UID   uint   `json:"uid" gorm:"index;not null"`
+	Key   string `json:"key" gorm:"uniqueIndex;not null"`
+	Usage string `json:"usage" gorm:"type:varchar(30)"`
+	Size  int64  `json:"size" gorm:"default:0"`
+	// Scanner that flagged the file and what it found, such as a malware signature
+	Scanner    string     `json:"scanner" gorm:"type:varchar(20)"`
+	Label      string     `json:"label"`
+	Score      float64    `json:"score"`
+	ReportID   uint       `json:"report_id"`
+	Status     string     `json:"status" gorm:"type:varchar(10);default:pending;index"`
+	ReviewedBy uint       `json:"reviewed_by,omitempty"`
+	ReviewedAt *time.Time `json:"reviewed_at,omitempty"`
+	CreatedAt  time.Time  `json:"created_at" gorm:"autoCreateTime"`
+
+	/* Virtual fields */
+	PreviewURL string `json:"preview_url,omitempty" gorm:"-"`
+}
+
+type QuarantineResolveRequest struct {
+	Action string `json:"action"` // release or delete
+}
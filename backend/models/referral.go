@@ -0,0 +1,95 @@
+package models
+
+import "time"
+
+type ReferralStatus string
+
+const (
+	ReferralStatusPending  ReferralStatus = "pending"
+	ReferralStatusVerified ReferralStatus = "verified"
+	ReferralStatusRejected ReferralStatus = "rejected"
+)
+
+type ReferralRewardType string
+
+const (
+	ReferralRewardBadgeEditCredits ReferralRewardType = "badge_edit_credits"
+	ReferralRewardBadge            ReferralRewardType = "badge"
+)
+
+type InviteCampaign struct {
+	ID          uint       `json:"id" gorm:"primaryKey;autoIncrement"`
+	Name        string     `json:"name" gorm:"unique;not null;type:varchar(100)"`
+	Description string     `json:"description" gorm:"default:null"`
+	Active      bool       `json:"active" gorm:"not null"`
+	StartsAt    *time.Time `json:"starts_at" gorm:"default:null"`
+	EndsAt      *time.Time `json:"ends_at" gorm:"default:null"`
+	CreatedBy   uint       `json:"created_by" gorm:"not null"`
+	CreatedAt   time.Time  `json:"created_at" gorm:"autoCreateTime"`
+	UpdatedAt   time.Time  `json:"updated_at" gorm:"autoUpdateTime"`
+
+	RewardRules []ReferralRewardRule `json:"reward_rules" gorm:"foreignKey:CampaignID;references:ID;constraint:OnDelete:CASCADE"`
+}
+
+// IsRunning checks if the campaign is active and inside its time window
+func (c *InviteCampaign) IsRunning(at time.Time) bool {
+	if !c.Active {
+		return false
+	}
+	if c.StartsAt != nil && at.Before(*c.StartsAt) {
+		return false
+	}
+	if c.EndsAt != nil && at.After(*c.EndsAt) {
+		return false
+	}
+	return true
+}
+
+// ReferralRewardRule grants a reward once a referrer reaches a number of verified referrals.
+// Rules without a campaign count every verified referral, campaign rules only count referrals
+// made while that campaign was running.
+type ReferralRewardRule struct {
+	ID         uint               `json:"id" gorm:"primaryKey;autoIncrement"`
+	CampaignID *uint              `json:"campaign_id" gorm:"index;default:null"`
+	Threshold  int                `json:"threshold" gorm:"not null"`
+	RewardType ReferralRewardType `json:"reward_type" gorm:"type:varchar(30);not null"`
+	Amount     int                `json:"amount" gorm:"default:0"`
+	BadgeName  string             `json:"badge_name" gorm:"default:null"`
+	CreatedAt  time.Time          `json:"created_at" gorm:"autoCreateTime"`
+}
+
+type Referral struct {
+	ID           uint           `json:"id" gorm:"primaryKey;autoIncrement"`
+	ReferrerID   uint           `json:"referrer_id" gorm:"index;not null"`
+	ReferredID   uint           `json:"referred_id" gorm:"uniqueIndex;not null"`
+	InviteCodeID uint           `json:"invite_code_id" gorm:"not null"`
+	CampaignID   *uint          `json:"campaign_id" gorm:"index;default:null"`
+	Status       ReferralStatus `json:"status" gorm:"type:varchar(20);index;not null"`
+	RejectReason string         `json:"reject_reason,omitempty" gorm:"default:null"`
+	IPAddress    string         `json:"-" gorm:"default:null"`
+	VerifiedAt   *time.Time     `json:"verified_at" gorm:"default:null"`
+	CreatedAt    time.Time      `json:"created_at" gorm:"autoCreateTime"`
+}
+
+// ReferralReward records that a rule was already granted to a referrer
+type ReferralReward struct {
+	ID         uint      `json:"id" gorm:"primaryKey;autoIncrement"`
+	ReferrerID uint      `json:"referrer_id" gorm:"uniqueIndex:idx_referral_reward_referrer_rule;not null"`
+	RuleID     uint      `json:"rule_id" gorm:"uniqueIndex:idx_referral_reward_referrer_rule;not null"`
+	GrantedAt  time.Time `json:"granted_at" gorm:"autoCreateTime"`
+}
+
+type ReferredUser struct {
+	Username    string         `json:"username"`
+	DisplayName string         `json:"display_name"`
+	Status      ReferralStatus `json:"status"`
+	CreatedAt   time.Time      `json:"created_at"`
+}
+
+type ReferralSummary struct {
+	Code     string          `json:"code"`
+	Pending  int64           `json:"pending"`
+	Verified int64           `json:"verified"`
+	Rejected int64           `json:"rejected"`
+	Referred []*ReferredUser `json:"referred"`
+}
@@ -0,0 +1,29 @@
+package models
+
+import "time"
+
+// StoredObject is an object in the bucket as listed by the file-upload service
+type StoredObject struct {
+	Key          string    `json:"key"`
+	Size         int64     `json:"size"`
+	LastModified time.Time `json:"last_modified"`
+}
+
+// MissingObject is a database row pointing at an object that is not in the bucket
+type MissingObject struct {
+	Table string `json:"table"`
+	RowID uint   `json:"row_id"`
+	Field string `json:"field"`
+	URL   string `json:"url"`
+}
+
+// StorageReconcileReport is the result of cross-referencing the bucket with the database
+type StorageReconcileReport struct {
+	DryRun     bool            `json:"dry_run"`
+	Objects    int             `json:"objects"`
+	Referenced int             `json:"referenced"`
+	Orphans    []StoredObject  `json:"orphans"`
+	OrphanSize int64           `json:"orphan_size"`
+	Deleted    int             `json:"deleted"`
+	Missing    []MissingObject `json:"missing"`
+}
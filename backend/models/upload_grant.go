@@ -0,0 +1,35 @@
+package models
+
+import "time"
+
+// UploadGrant lets a browser upload one file directly to the file-upload
+// service, to Key under Prefix. Completing the grant processes the file and
+// attaches it to the profile field in Usage.
+type UploadGrant struct {
+	ID          string     `json:"id" gorm:"primaryKey;type:varchar(36)"`
+	UID         uint       `json:"uid" gorm:"index;not null"`
+	Usage       string     `json:"usage" gorm:"type:varchar(30);not null"`
+	Prefix      string     `json:"prefix" gorm:"not null"`
+	Key         string     `json:"key"`
+	MaxSize     int64      `json:"max_size" gorm:"not null"`
+	ContentType string     `json:"content_type" gorm:"type:varchar(50);not null"`
+	ExpiresAt   time.Time  `json:"expires_at"`
+	CompletedAt *time.Time `json:"completed_at,omitempty"`
+	CreatedAt   time.Time  `json:"created_at" gorm:"autoCreateTime"`
+}
+
+type UploadGrantRequest struct {
+	Usage       string `json:"usage"`
+	ContentType string `json:"content_type"`
+	Size        int64  `json:"size"`
+}
+
+type UploadGrantResponse struct {
+	ID          string    `json:"id"`
+	Token       string    `json:"token"`
+	UploadURL   string    `json:"upload_url"`
+	Key         string    `json:"key"`
+	MaxSize     int64     `json:"max_size"`
+	ContentType string    `json:"content_type"`
+	ExpiresAt   time.Time `json:"expires_at"`
+}
@@ -123,15 +123,20 @@ type UserProfile struct {
 	ViewsAnimation         bool    `json:"views_animation" gorm:"default:false"`
 	UseDiscordAvatar       bool    `json:"use_discord_avatar" gorm:"default:false"`
 	UseDiscordDecoration   bool    `json:"use_discord_decoration" gorm:"default:false"`
+
+	// Processed renditions of the uploaded files, keyed by profile field
+	MediaVariants MediaVariants `json:"media_variants" gorm:"type:jsonb;default:null"`
 }
 
 type UserBadge struct {
-	ID      uint  `json:"id" gorm:"primaryKey;autoIncrement"`
-	UID     uint  `json:"uid" gorm:"not null;constraint:OnDelete:CASCADE;"`
-	BadgeID uint  `json:"badge_id" gorm:"not null"`
-	Sort    uint  `json:"sort" gorm:"default:0"`
-	Hidden  bool  `json:"hidden" gorm:"default:false"`
-	Badge   Badge `json:"badge" gorm:"foreignKey:BadgeID;references:ID"`
+	ID        uint       `json:"id" gorm:"primaryKey;autoIncrement"`
+	UID       uint       `json:"uid" gorm:"not null;constraint:OnDelete:CASCADE;"`
+	BadgeID   uint       `json:"badge_id" gorm:"not null"`
+	Sort      uint       `json:"sort" gorm:"default:0"`
+	Hidden    bool       `json:"hidden" gorm:"default:false"`
+	ExpiresAt *time.Time `json:"expires_at" gorm:"index;default:null"` // nil for permanent badges
+	RuleID    *uint      `json:"rule_id,omitempty" gorm:"index;default:null"` // set when awarded by a BadgeRule
+	Badge     Badge      `json:"badge" gorm:"foreignKey:BadgeID;references:ID"`
 }
 
 type UserSocial struct {
@@ -0,0 +1,32 @@
+package models
+
+import "time"
+
+// UserFile records an object uploaded to the bucket by a user. Originals make
+// up the user's media library, processed variants point at their original
+// through ParentID. Removed files are deleted from the bucket by the garbage
+// collector once no profile or social references them anymore.
+type UserFile struct {
+	ID     uint   `json:"id" gorm:"primaryKey;autoIncrement"`
+	UID    uint   `json:"uid" gorm:"index;not null"`
+	Key    string `json:"key" gorm:"uniqueIndex;not null"`
+	URL    string `json:"url" gorm:"index;not null"`
+	Size   int64  `json:"size" gorm:"default:0"`
+	Kind   string `json:"kind" gorm:"type:varchar(10)"`
+	Format string `json:"format" gorm:"type:varchar(10)"`
+	Width  int    `json:"width,omitempty"`
+	Height int    `json:"height,omitempty"`
+	// Profile field or feature the file was processed for, such as avatar_url
+	Usage     string     `json:"usage" gorm:"type:varchar(30);index"`
+	ParentID  *uint      `json:"parent_id,omitempty" gorm:"index"`
+	Label     string     `json:"label,omitempty" gorm:"type:varchar(20)"`
+	Variants  []UserFile `json:"variants,omitempty" gorm:"foreignKey:ParentID"`
+	RemovedAt *time.Time `json:"removed_at,omitempty" gorm:"index"`
+	CreatedAt time.Time  `json:"created_at" gorm:"autoCreateTime"`
+}
+
+// StorageUsage is the storage a user has used against their quota, in bytes
+type StorageUsage struct {
+	Used  int64 `json:"used"`
+	Quota int64 `json:"quota"`
+}
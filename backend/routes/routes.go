@@ -17,7 +17,7 @@ import (
 	"gorm.io/gorm"
 )
 
-func RegisterRoutes(router *mux.Router, db *gorm.DB, redisClient *redis.Client, bot *discord.Bot) {
+func RegisterRoutes(router *mux.Router, db *gorm.DB, redisClient *redis.Client, bot *discord.Bot, domainService *services.DomainService) {
 	eventService := services.NewEventService(db, redisClient, bot.Session)
 
 	userService := services.NewUserService(db, redisClient, bot.Session)
@@ -38,8 +38,14 @@ func RegisterRoutes(router *mux.Router, db *gorm.DB, redisClient *redis.Client,
 	userHandler := handlers.NewUserHandler(userService, emailService)
 	discordService := services.NewDiscordService(db, redisClient, userService, bot.Session)
 	discordHandler := handlers.NewDiscordHandler(discordService, userService)
+	presenceHub := services.NewPresenceHub(redisClient)
+	presenceHandler := handlers.NewPresenceHandler(discordService, presenceHub)
 	profileService := services.NewProfileService(db, redisClient, bot.Session, discordService)
 	profileHandler := handlers.NewProfileHandler(profileService)
+	embedHandler := handlers.NewEmbedHandler(profileService)
+
+	domainHandler := handlers.NewDomainHandler(domainService, profileService)
+	profileService.DomainService = domainService
 	socialService := services.NewSocialService(db, redisClient)
 	socialHandler := handlers.NewSocialHandler(socialService)
 	mfaService := services.NewMFAService(db, redisClient, userService)
@@ -47,17 +53,30 @@ func RegisterRoutes(router *mux.Router, db *gorm.DB, redisClient *redis.Client,
 	widgetService := services.NewWidgetService(db, redisClient)
 	widgetHandler := handlers.NewWidgetHandler(widgetService)
 	badgeService := services.NewBadgeService(db, redisClient)
-	badgeHandler := handlers.NewBadgeHandler(badgeService)
+	badgeService.EventService = eventService
+	badgeRuleService := services.NewBadgeRuleService(db, redisClient, badgeService)
+	badgeHandler := handlers.NewBadgeHandler(badgeService, badgeRuleService)
+	guildService := discordService.GuildService
+	guildService.EventService = eventService
+	guildHandler := handlers.NewGuildHandler(guildService, badgeService)
 	fileService := services.NewFileService(db, redisClient)
+	badgeService.FileService = fileService
 	fileHandler := handlers.NewFileHandler(fileService)
+	storageService := services.NewStorageService(db, redisClient)
+	storageService.FileService = fileService
+	storageHandler := handlers.NewStorageHandler(storageService)
+	mediaProxyService := services.NewMediaProxyService(db, redisClient)
+	mediaProxyService.FileService = fileService
+	mediaProxyHandler := handlers.NewMediaProxyHandler(mediaProxyService)
 	analyticsService := services.NewAnalyticsService(db, redisClient)
 	analyticsHandler := handlers.NewAnalyticsHandler(analyticsService)
 	viewService := services.NewViewService(db, redisClient, profileService, analyticsService)
 	viewHandler := handlers.NewViewHandler(viewService)
-	redeemService := services.NewRedeemService(db, redisClient)
+	redeemService := services.NewRedeemService(db, redisClient, badgeService)
 	redeemService.EventService = eventService
 	redeemHandler := handlers.NewRedeemHandler(redeemService, userService)
 	punishService := services.NewPunishService(db, redisClient)
+	fileService.PunishService = punishService
 	punishHandler := handlers.NewPunishHandler(punishService, redeemService)
 	sessionService := services.NewSessionService(db, redisClient)
 	sessionHandler := handlers.NewSessionHandler(sessionService)
@@ -69,14 +88,33 @@ func RegisterRoutes(router *mux.Router, db *gorm.DB, redisClient *redis.Client,
 	passwordHandler := handlers.NewPasswordHandler(passwordService, userService, emailService)
 	templateService := services.NewTemplateService(db, redisClient)
 	templateHandler := handlers.NewTemplateHandler(templateService)
-	imageService := services.NewImageService()
+	imageService := services.NewImageService(redisClient)
 	imageHandler := handlers.NewImageHandler(imageService, userService, profileService, templateService)
-	applyService := services.NewApplyService(db, redisClient, emailService, userService)
+	applyService := services.NewApplyService(db, redisClient, emailService, userService, badgeService)
+	applyService.AltAccountService = altAccountService
 	applyHandler := handlers.NewApplyHandler(applyService, userService)
 
 	dataExportService := services.NewDataExportService(db, redisClient)
 	dataExportHandler := handlers.NewDataExportHandler(dataExportService)
 
+	notificationService := services.NewNotificationService(db, redisClient, bot.Session)
+	notificationService.EmailService = emailService
+	notificationHandler := handlers.NewNotificationHandler(notificationService)
+	userService.NotificationService = notificationService
+	punishService.NotificationService = notificationService
+	punishService.EventService = eventService
+	redeemService.NotificationService = notificationService
+	viewService.NotificationService = notificationService
+
+	referralService := services.NewReferralService(db, redisClient, badgeService)
+	referralService.UserService = userService
+	referralService.AltAccountService = altAccountService
+	referralService.NotificationService = notificationService
+	badgeRuleService.NotificationService = notificationService
+	badgeService.NotificationService = notificationService
+	referralHandler := handlers.NewReferralHandler(referralService)
+	emailService.ReferralService = referralService
+
 	shutdownstatsservice := services.NewShutdownStatsService(db, redisClient)
 	stats, _ := shutdownstatsservice.GenerateShutdownStats()
 
@@ -85,6 +123,11 @@ func RegisterRoutes(router *mux.Router, db *gorm.DB, redisClient *redis.Client,
 
 
 
+	/* Custom domain routes, matched before everything else by Host */
+	customDomainRoutes := router.MatcherFunc(domainHandler.MatchCustomDomain).Subrouter()
+	customDomainRoutes.HandleFunc("/.well-known/acme-challenge/{token}", domainHandler.ACMEChallenge).Methods("GET")
+	customDomainRoutes.PathPrefix("/").HandlerFunc(domainHandler.ServeCustomDomain)
+
 	/* Public routes (do not require authentication) */
 	apiRoutes := router.PathPrefix("/api").Subrouter()
 	apiRoutes.HandleFunc("/register", userHandler.Register).Methods("POST")
@@ -93,14 +136,21 @@ func RegisterRoutes(router *mux.Router, db *gorm.DB, redisClient *redis.Client,
 	apiRoutes.HandleFunc("/discord/oauth2", discordHandler.GetOAuth2URL).Methods("GET")
 	apiRoutes.HandleFunc("/discord/oauth2/login", discordHandler.OAuth2Login).Methods("GET")
 	apiRoutes.HandleFunc("/discord/presence/{uid}", discordHandler.GetDiscordPresence).Methods("GET")
+	apiRoutes.HandleFunc("/ws/presence/{uid}", presenceHandler.StreamPresence).Methods("GET")
 	apiRoutes.HandleFunc("/discord/server/{invite}", discordHandler.GetDiscordServer).Methods("GET")
 	apiRoutes.HandleFunc("/widget/github/{username}", widgetHandler.GetGitHubRepos).Methods("GET")
 	apiRoutes.HandleFunc("/widget/valorant/{name}/{tag}", widgetHandler.GetValorantData).Methods("GET")
 	apiRoutes.HandleFunc("/views/{uid}/increment", viewHandler.IncrementViewCount).Methods("POST")
+	apiRoutes.HandleFunc("/profile", profileHandler.GetPublicProfile).Methods("GET")
 	apiRoutes.HandleFunc("/profile/{identifier}", profileHandler.GetPublicProfile).Methods("GET")
+	apiRoutes.HandleFunc("/profile/{identifier}/html", profileHandler.GetPublicProfilePage).Methods("GET")
+	apiRoutes.HandleFunc("/oembed", embedHandler.OEmbed).Methods("GET")
+	apiRoutes.HandleFunc("/embed/{identifier}", embedHandler.GetProfileEmbed).Methods("GET")
 	apiRoutes.HandleFunc("/marquee_users", publicHandler.GetMarqueeUsers).Methods("GET")
 	apiRoutes.HandleFunc("/leaderboard/views", publicHandler.GetLeaderboardUsersByViews).Methods("GET")
 	apiRoutes.HandleFunc("/leaderboard/badges", publicHandler.GetLeaderboardUsersByBadges).Methods("GET")
+	apiRoutes.HandleFunc("/badges", badgeHandler.GetBadgeCatalog).Methods("GET")
+	apiRoutes.HandleFunc("/leaderboard/referrals", publicHandler.GetLeaderboardUsersByReferrals).Methods("GET")
 	apiRoutes.HandleFunc("/stats", userHandler.GetStats).Methods("GET")
 	apiRoutes.HandleFunc("/status", statusHandler.GetActiveStatus).Methods("GET")
 	apiRoutes.HandleFunc("/status/upcoming", statusHandler.GetUpcomingStatus).Methods("GET")
@@ -113,7 +163,8 @@ func RegisterRoutes(router *mux.Router, db *gorm.DB, redisClient *redis.Client,
 	apiRoutes.HandleFunc("/templates/stats", templateHandler.GetTemplateStats).Methods("GET")
 	apiRoutes.HandleFunc("/analytics/social-click", analyticsHandler.TrackSocialClick).Methods("POST")
 	apiRoutes.HandleFunc("/images/user/{identifier}", imageHandler.GenerateUserCard).Methods("GET")
-	//apiRoutes.HandleFunc("/images/template/{templateID}", imageHandler.GenerateTemplateCard).Methods("GET")
+	apiRoutes.HandleFunc("/media/proxy/{signature}/{target}", mediaProxyHandler.ProxyMedia).Methods("GET")
+	apiRoutes.HandleFunc("/images/template/{templateID}", imageHandler.GenerateTemplateCard).Methods("GET")
 	apiRoutes.HandleFunc("/applications/positions", applyHandler.GetActivePositions).Methods("GET")
 	apiRoutes.HandleFunc("/applications/positions/{id}", applyHandler.GetPositionByID).Methods("GET")
 
@@ -163,6 +214,12 @@ func RegisterRoutes(router *mux.Router, db *gorm.DB, redisClient *redis.Client,
 	/* Profile Routes */
 	restrictedRoutes.HandleFunc("/profile", profileHandler.UpdateUserProfile).Methods("PUT")
 
+	/* Custom Domain Routes */
+	privateRoutes.HandleFunc("/domain", domainHandler.GetDomain).Methods("GET")
+	restrictedRoutes.HandleFunc("/domain", domainHandler.AddDomain).Methods("PUT")
+	restrictedRoutes.HandleFunc("/domain/verify", domainHandler.VerifyDomain).Methods("POST")
+	restrictedRoutes.HandleFunc("/domain", domainHandler.RemoveDomain).Methods("DELETE")
+
 	/* Data Export Routes */
 	privateRoutes.HandleFunc("/data-export/request", dataExportHandler.RequestDataExport).Methods("POST")
 	privateRoutes.HandleFunc("/data-export/latest", dataExportHandler.GetLatestExportStatus).Methods("GET")
@@ -192,11 +249,17 @@ func RegisterRoutes(router *mux.Router, db *gorm.DB, redisClient *redis.Client,
 	/* Badge Routes */
 	restrictedRoutes.HandleFunc("/badges", badgeHandler.ReorderUserBadge).Methods("PUT")
 	restrictedRoutes.HandleFunc("/badges/{badgeID}/hide", badgeHandler.HideUserBadge).Methods("PUT")
+	restrictedRoutes.HandleFunc("/badges/custom/edits", badgeHandler.GetUserBadgeEdits).Methods("GET")
 	restrictedRoutes.HandleFunc("/badges/custom/{badgeID}", badgeHandler.EditCustomBadge).Methods("PUT")
 
 	/* File Routes */
 	restrictedRoutes.HandleFunc("/files/upload", fileHandler.UploadFile).Methods("POST")
 	restrictedRoutes.HandleFunc("/files/delete", fileHandler.DeleteFile).Methods("POST")
+	restrictedRoutes.HandleFunc("/files/uploads", fileHandler.CreateUploadGrant).Methods("POST")
+	restrictedRoutes.HandleFunc("/files/uploads/{id}/complete", fileHandler.CompleteUpload).Methods("POST")
+	restrictedRoutes.HandleFunc("/files", fileHandler.GetLibrary).Methods("GET")
+	restrictedRoutes.HandleFunc("/files/{id}/use", fileHandler.UseLibraryFile).Methods("PUT")
+	restrictedRoutes.HandleFunc("/files/{id}", fileHandler.RemoveLibraryFile).Methods("DELETE")
 
 	/* View Routes */
 	privateRoutes.HandleFunc("/views", viewHandler.GetUserViewsData).Methods("GET")
@@ -226,6 +289,17 @@ func RegisterRoutes(router *mux.Router, db *gorm.DB, redisClient *redis.Client,
 	/* Analytics Routes */
 	privateRoutes.HandleFunc("/analytics", analyticsHandler.GetAnalytics).Methods("GET")
 
+	/* Referral Routes */
+	privateRoutes.HandleFunc("/referrals", referralHandler.GetReferralSummary).Methods("GET")
+
+	/* Notification Routes */
+	privateRoutes.HandleFunc("/notifications", notificationHandler.GetNotifications).Methods("GET")
+	privateRoutes.HandleFunc("/notifications/unread-count", notificationHandler.GetUnreadCount).Methods("GET")
+	privateRoutes.HandleFunc("/notifications/read", notificationHandler.MarkNotifications).Methods("POST")
+	privateRoutes.HandleFunc("/notifications/read-all", notificationHandler.MarkAllAsRead).Methods("POST")
+	privateRoutes.HandleFunc("/notifications/preferences", notificationHandler.GetPreferences).Methods("GET")
+	privateRoutes.HandleFunc("/notifications/preferences", notificationHandler.UpdatePreferences).Methods("PUT")
+
 	/* Application Routes */
 	privateRoutes.HandleFunc("/applications", applyHandler.GetUserApplications).Methods("GET")
 	restrictedRoutes.HandleFunc("/applications/start", applyHandler.StartApplication).Methods("POST")
@@ -243,6 +317,7 @@ func RegisterRoutes(router *mux.Router, db *gorm.DB, redisClient *redis.Client,
 	staffRoutes.HandleFunc("/moderation/search-users", punishHandler.SearchUsers).Methods("GET")
 	staffRoutes.HandleFunc("/moderation/reports/count", punishHandler.GetOpenReportCount).Methods("GET")
 	staffRoutes.HandleFunc("/moderation/reports", punishHandler.GetOpenReports).Methods("GET")
+	staffRoutes.HandleFunc("/moderation/badge-edits", badgeHandler.GetBadgeEdits).Methods("GET")
 
 	// Moderator routes (full mod+)
 	moderatorRoutes := privateRoutes.NewRoute().Subrouter()
@@ -254,15 +329,50 @@ func RegisterRoutes(router *mux.Router, db *gorm.DB, redisClient *redis.Client,
 	moderatorRoutes.HandleFunc("/moderation/reports/{id}/handle", punishHandler.HandleReport).Methods("POST")
 	moderatorRoutes.HandleFunc("/moderation/reports/{id}", punishHandler.GetReport).Methods("GET")
 	moderatorRoutes.HandleFunc("/moderation/reports/{id}/assign", punishHandler.AssignReportToStaff).Methods("POST")
+	moderatorRoutes.HandleFunc("/moderation/quarantine", fileHandler.GetQuarantinedFiles).Methods("GET")
+	moderatorRoutes.HandleFunc("/moderation/quarantine/{id}/resolve", fileHandler.ResolveQuarantinedFile).Methods("POST")
+	moderatorRoutes.HandleFunc("/moderation/badge-edits/{id}/review", badgeHandler.ReviewBadgeEdit).Methods("POST")
+	moderatorRoutes.HandleFunc("/moderation/applications/{status}", applyHandler.GetApplications).Methods("GET")
+	moderatorRoutes.HandleFunc("/moderation/applications/detail/{id}", applyHandler.GetApplicationDetail).Methods("GET")
+	moderatorRoutes.HandleFunc("/moderation/applications/{id}/reviews", applyHandler.GetApplicationReviews).Methods("GET")
+	moderatorRoutes.HandleFunc("/moderation/applications/{id}/reviews", applyHandler.SubmitApplicationReview).Methods("POST")
 
 	/* Routes that should be blocked for staff */
 	adminRoutes := privateRoutes.NewRoute().Subrouter()
 	adminMiddleware := middlewares.AdminMiddleware(userService)
 	adminRoutes.Use(adminMiddleware)
 
-	adminRoutes.HandleFunc("/moderation/applications/{status}", applyHandler.GetApplications).Methods("GET")
-	adminRoutes.HandleFunc("/moderation/applications/detail/{id}", applyHandler.GetApplicationDetail).Methods("GET")
 	adminRoutes.HandleFunc("/moderation/applications/review/{id}", applyHandler.ReviewApplication).Methods("POST")
+	adminRoutes.HandleFunc("/moderation/positions", applyHandler.GetAllPositions).Methods("GET")
+	adminRoutes.HandleFunc("/moderation/positions", applyHandler.CreatePosition).Methods("POST")
+	adminRoutes.HandleFunc("/moderation/positions/{id}", applyHandler.UpdatePosition).Methods("PUT")
+	adminRoutes.HandleFunc("/moderation/positions/{id}", applyHandler.DeletePosition).Methods("DELETE")
+	adminRoutes.HandleFunc("/moderation/positions/{id}/questions", applyHandler.UpdatePositionQuestions).Methods("PUT")
+	adminRoutes.HandleFunc("/moderation/positions/{id}/versions", applyHandler.GetPositionVersions).Methods("GET")
+
+	adminRoutes.HandleFunc("/moderation/referrals/campaigns", referralHandler.GetCampaigns).Methods("GET")
+	adminRoutes.HandleFunc("/moderation/referrals/campaigns", referralHandler.CreateCampaign).Methods("POST")
+	adminRoutes.HandleFunc("/moderation/referrals/campaigns/{id}", referralHandler.UpdateCampaign).Methods("PUT")
+	adminRoutes.HandleFunc("/moderation/referrals/rules", referralHandler.AddRewardRule).Methods("POST")
+	adminRoutes.HandleFunc("/moderation/referrals/rules/{id}", referralHandler.DeleteRewardRule).Methods("DELETE")
+
+	adminRoutes.HandleFunc("/moderation/badges", badgeHandler.CreateBadge).Methods("POST")
+	adminRoutes.HandleFunc("/moderation/badges/rules", badgeHandler.GetBadgeRules).Methods("GET")
+	adminRoutes.HandleFunc("/moderation/badges/rules", badgeHandler.CreateBadgeRule).Methods("POST")
+	adminRoutes.HandleFunc("/moderation/badges/rules/evaluate", badgeHandler.EvaluateBadgeRules).Methods("POST")
+	adminRoutes.HandleFunc("/moderation/badges/rules/{id}", badgeHandler.UpdateBadgeRule).Methods("PUT")
+	adminRoutes.HandleFunc("/moderation/badges/rules/{id}", badgeHandler.DeleteBadgeRule).Methods("DELETE")
+	adminRoutes.HandleFunc("/moderation/badges/{id}", badgeHandler.UpdateBadge).Methods("PUT")
+
+	adminRoutes.HandleFunc("/moderation/storage/reconcile", storageHandler.ReconcileStorage).Methods("POST")
+
+	adminRoutes.HandleFunc("/moderation/discord/guilds", guildHandler.GetGuilds).Methods("GET")
+	adminRoutes.HandleFunc("/moderation/discord/guilds", guildHandler.CreateGuild).Methods("POST")
+	adminRoutes.HandleFunc("/moderation/discord/guilds/{id}", guildHandler.UpdateGuild).Methods("PUT")
+	adminRoutes.HandleFunc("/moderation/discord/guilds/{id}", guildHandler.DeleteGuild).Methods("DELETE")
+	adminRoutes.HandleFunc("/moderation/discord/guilds/{id}/badge-roles", guildHandler.GetBadgeRoles).Methods("GET")
+	adminRoutes.HandleFunc("/moderation/discord/guilds/{id}/badge-roles", guildHandler.AddBadgeRole).Methods("POST")
+	adminRoutes.HandleFunc("/moderation/discord/guilds/{id}/badge-roles/{mapping}", guildHandler.RemoveBadgeRole).Methods("DELETE")
 
 
 
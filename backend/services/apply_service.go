@@ -5,11 +5,18 @@ import (
 	"errors"
 	"fmt"
 	"log"
+	"net/url"
+	"regexp"
+	"strconv"
+	"strings"
 	"time"
+	"unicode/utf8"
 
 	"github.com/go-redis/redis"
 	"github.com/hazebio/haze.bio_backend/models"
+	"github.com/hazebio/haze.bio_backend/utils"
 	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
 )
 
 type ApplyService struct {
@@ -17,37 +24,478 @@ type ApplyService struct {
 	Client       *redis.Client
 	EmailService *EmailService
 	UserService  *UserService
+	BadgeService *BadgeService
+
+	AltAccountService *AltAccountService
 }
 
-func NewApplyService(db *gorm.DB, client *redis.Client, emailService *EmailService, userService *UserService) *ApplyService {
+func NewApplyService(db *gorm.DB, client *redis.Client, emailService *EmailService, userService *UserService, badgeService *BadgeService) *ApplyService {
 	return &ApplyService{
 		DB:           db,
 		Client:       client,
 		EmailService: emailService,
 		UserService:  userService,
+		BadgeService: badgeService,
 	}
 }
 
+const (
+	activePositionsCacheKey = "application_positions_active"
+	activePositionsCacheTTL = 30 * time.Minute
+)
+
+var positionIDRegex = regexp.MustCompile(`^[a-z0-9_]{2,50}$`)
+
+const (
+	riskMaxCharsPerSecond     = 12  // typing faster than this on long answers is implausible
+	riskMinAnswerLength       = 80  // shorter answers are ignored for speed and similarity checks
+	riskMinSecondsPerQuestion = 15  // completing faster than this per answered question is flagged
+	riskSimilarityThreshold   = 0.6 // jaccard similarity of word shingles
+	riskShingleSize           = 3
+	riskMaxComparedResponses  = 500
+)
+
+/* Get all positions that currently accept applications */
 func (as *ApplyService) GetActivePositions() []models.Position {
-	var activePositions []models.Position
-	for _, position := range models.AvailablePositions {
-		if position.Active {
+	activePositions := []models.Position{}
+
+	cached, err := as.Client.Get(activePositionsCacheKey).Result()
+	if err == nil && json.Unmarshal([]byte(cached), &activePositions) == nil {
+		return activePositions
+	}
+
+	positions, err := as.GetAllPositions()
+	if err != nil {
+		log.Println("Error getting active positions:", err)
+		return activePositions
+	}
+
+	now := time.Now()
+	for _, position := range positions {
+		if position.IsOpen(now) {
 			activePositions = append(activePositions, position)
 		}
 	}
+
+	if data, err := json.Marshal(activePositions); err == nil {
+		as.Client.Set(activePositionsCacheKey, data, activePositionsCacheTTL)
+	}
+
 	return activePositions
 }
 
+/* Get all positions with their current questions, including inactive ones */
+func (as *ApplyService) GetAllPositions() ([]models.Position, error) {
+	var stored []models.ApplicationPosition
+	if err := as.DB.Order("created_at ASC").Find(&stored).Error; err != nil {
+		return nil, err
+	}
+
+	positions := make([]models.Position, 0, len(stored))
+	for i := range stored {
+		version, err := as.getFormVersion(stored[i].ID, stored[i].CurrentVersion)
+		if err != nil {
+			log.Printf("Error getting form version %d of position %s: %v", stored[i].CurrentVersion, stored[i].ID, err)
+			continue
+		}
+		positions = append(positions, *stored[i].ToPosition(version))
+	}
+
+	return positions, nil
+}
+
+/* Get a position with its current questions */
 func (as *ApplyService) GetPositionByID(id string) *models.Position {
-	return models.GetPositionByID(id)
+	var stored models.ApplicationPosition
+	if err := as.DB.Where("id = ?", id).First(&stored).Error; err != nil {
+		return nil
+	}
+
+	version, err := as.getFormVersion(stored.ID, stored.CurrentVersion)
+	if err != nil {
+		return nil
+	}
+
+	return stored.ToPosition(version)
+}
+
+/* Get a position with the questions of a specific version */
+func (as *ApplyService) GetPositionVersion(id string, version int) *models.Position {
+	if version <= 0 {
+		version = 1
+	}
+
+	var stored models.ApplicationPosition
+	if err := as.DB.Where("id = ?", id).First(&stored).Error; err != nil {
+		return nil
+	}
+
+	formVersion, err := as.getFormVersion(stored.ID, version)
+	if err != nil {
+		return nil
+	}
+
+	return stored.ToPosition(formVersion)
+}
+
+/* Get every question version of a position */
+func (as *ApplyService) GetFormVersions(id string) ([]models.ApplicationFormVersion, error) {
+	var versions []models.ApplicationFormVersion
+	err := as.DB.Where("position_id = ?", id).Order("version DESC").Find(&versions).Error
+	return versions, err
+}
+
+func (as *ApplyService) getFormVersion(positionID string, version int) (*models.ApplicationFormVersion, error) {
+	var formVersion models.ApplicationFormVersion
+	err := as.DB.Where("position_id = ? AND version = ?", positionID, version).First(&formVersion).Error
+	if err != nil {
+		return nil, err
+	}
+	return &formVersion, nil
+}
+
+/* Create a new position with its first question version */
+func (as *ApplyService) CreatePosition(position *models.Position, createdBy uint) error {
+	if !positionIDRegex.MatchString(position.ID) {
+		return errors.New("position id must be 2-50 lowercase letters, numbers or underscores")
+	}
+	if position.Title == "" {
+		return errors.New("position title is required")
+	}
+	if position.StaffLevel > utils.StaffLevelHeadMod {
+		return errors.New("positions cannot grant a staff level above head moderator")
+	}
+	if err := validateQuestions(position.Questions); err != nil {
+		return err
+	}
+	if err := validateWindow(position.OpensAt, position.ClosesAt); err != nil {
+		return err
+	}
+
+	err := as.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(&models.ApplicationPosition{
+			ID:             position.ID,
+			Title:          position.Title,
+			Description:    position.Description,
+			Active:         position.Active,
+			StaffLevel:     position.StaffLevel,
+			OpensAt:        position.OpensAt,
+			ClosesAt:       position.ClosesAt,
+			CurrentVersion: 1,
+		}).Error; err != nil {
+			return err
+		}
+
+		return tx.Create(&models.ApplicationFormVersion{
+			PositionID: position.ID,
+			Version:    1,
+			Questions:  position.Questions,
+			CreatedBy:  createdBy,
+		}).Error
+	})
+	if err != nil {
+		if utils.IsError(err, utils.ErrDuplicateKey) {
+			return errors.New("a position with this id already exists")
+		}
+		return err
+	}
+
+	position.Version = 1
+	as.Client.Del(activePositionsCacheKey)
+	return nil
+}
+
+/* Update the title, description, active flag or window of a position */
+func (as *ApplyService) UpdatePosition(id string, fields map[string]interface{}) error {
+	allowed := map[string]bool{
+		"title":       true,
+		"description": true,
+		"active":      true,
+		"staff_level": true,
+		"opens_at":    true,
+		"closes_at":   true,
+	}
+
+	updates := make(map[string]interface{})
+	for key, value := range fields {
+		if !allowed[key] {
+			return fmt.Errorf("field %s cannot be updated", key)
+		}
+		updates[key] = value
+	}
+
+	if len(updates) == 0 {
+		return errors.New("no fields to update")
+	}
+
+	if title, ok := updates["title"]; ok && title == "" {
+		return errors.New("position title is required")
+	}
+
+	if level, ok := updates["staff_level"].(float64); ok && (level < 0 || uint(level) > utils.StaffLevelHeadMod) {
+		return errors.New("positions cannot grant a staff level above head moderator")
+	}
+
+	_, opensSet := updates["opens_at"]
+	_, closesSet := updates["closes_at"]
+	if opensSet || closesSet {
+		// The new window is checked together with the bound the update keeps
+		var stored models.ApplicationPosition
+		if err := as.DB.Select("opens_at", "closes_at").Where("id = ?", id).First(&stored).Error; err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return errors.New("position not found")
+			}
+			return err
+		}
+
+		opensAt, closesAt := stored.OpensAt, stored.ClosesAt
+		if opensSet {
+			parsed, err := parseWindowTime(updates["opens_at"])
+			if err != nil {
+				return errors.New("opens_at must be an RFC 3339 time")
+			}
+			opensAt = parsed
+			updates["opens_at"] = parsed
+		}
+		if closesSet {
+			parsed, err := parseWindowTime(updates["closes_at"])
+			if err != nil {
+				return errors.New("closes_at must be an RFC 3339 time")
+			}
+			closesAt = parsed
+			updates["closes_at"] = parsed
+		}
+
+		if err := validateWindow(opensAt, closesAt); err != nil {
+			return err
+		}
+	}
+
+	result := as.DB.Model(&models.ApplicationPosition{}).Where("id = ?", id).Updates(updates)
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return errors.New("position not found")
+	}
+
+	as.Client.Del(activePositionsCacheKey)
+	return nil
+}
+
+/* Open or close a position for applications */
+func (as *ApplyService) SetPositionActive(id string, active bool) error {
+	return as.UpdatePosition(id, map[string]interface{}{"active": active})
+}
+
+/* Replace the questions of a position by creating a new version */
+func (as *ApplyService) UpdateQuestions(id string, questions []models.Question, createdBy uint) (int, error) {
+	if err := validateQuestions(questions); err != nil {
+		return 0, err
+	}
+
+	var newVersion int
+	err := as.DB.Transaction(func(tx *gorm.DB) error {
+		var stored models.ApplicationPosition
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).Where("id = ?", id).First(&stored).Error; err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return errors.New("position not found")
+			}
+			return err
+		}
+
+		newVersion = stored.CurrentVersion + 1
+		if err := tx.Create(&models.ApplicationFormVersion{
+			PositionID: id,
+			Version:    newVersion,
+			Questions:  questions,
+			CreatedBy:  createdBy,
+		}).Error; err != nil {
+			return err
+		}
+
+		return tx.Model(&models.ApplicationPosition{}).Where("id = ?", id).Update("current_version", newVersion).Error
+	})
+	if err != nil {
+		return 0, err
+	}
+
+	as.Client.Del(activePositionsCacheKey)
+	return newVersion, nil
+}
+
+/* Delete a position that has no applications yet */
+func (as *ApplyService) DeletePosition(id string) error {
+	var count int64
+	if err := as.DB.Model(&models.Application{}).Where("position_id = ?", id).Count(&count).Error; err != nil {
+		return err
+	}
+
+	if count > 0 {
+		return errors.New("position has applications, deactivate it instead")
+	}
+
+	err := as.DB.Transaction(func(tx *gorm.DB) error {
+		result := tx.Where("id = ?", id).Delete(&models.ApplicationPosition{})
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return errors.New("position not found")
+		}
+
+		return tx.Where("position_id = ?", id).Delete(&models.ApplicationFormVersion{}).Error
+	})
+	if err != nil {
+		return err
+	}
+
+	as.Client.Del(activePositionsCacheKey)
+	return nil
+}
+
+/* Parse a window bound from a JSON update, null or an empty string clears it */
+func parseWindowTime(value interface{}) (*time.Time, error) {
+	switch v := value.(type) {
+	case nil:
+		return nil, nil
+	case string:
+		if v == "" {
+			return nil, nil
+		}
+		parsed, err := time.Parse(time.RFC3339, v)
+		if err != nil {
+			return nil, err
+		}
+		return &parsed, nil
+	default:
+		return nil, fmt.Errorf("unexpected time value %v", value)
+	}
+}
+
+func validateWindow(opensAt *time.Time, closesAt *time.Time) error {
+	if opensAt != nil && closesAt != nil && !closesAt.After(*opensAt) {
+		return errors.New("position must close after it opens")
+	}
+	return nil
+}
+
+func validateQuestions(questions []models.Question) error {
+	if len(questions) == 0 {
+		return errors.New("at least one question is required")
+	}
+
+	seen := make(map[string]bool, len(questions))
+	for _, question := range questions {
+		if question.ID == "" || question.Title == "" {
+			return errors.New("every question needs an id and a title")
+		}
+		if seen[question.ID] {
+			return fmt.Errorf("duplicate question id %s", question.ID)
+		}
+		if !models.IsValidInputType(question.InputType) {
+			return fmt.Errorf("invalid input type %s for question %s", question.InputType, question.ID)
+		}
+		if question.InputType == models.InputTypeSelect && len(question.Options) == 0 {
+			return fmt.Errorf("select question %s needs options", question.ID)
+		}
+
+		if question.VisibleIf != nil && !seen[question.VisibleIf.QuestionID] {
+			return fmt.Errorf("question %s can only depend on an earlier question", question.ID)
+		}
+
+		if v := question.Validation; v != nil {
+			if v.MinLength < 0 || (v.MaxLength > 0 && v.MaxLength < v.MinLength) {
+				return fmt.Errorf("invalid length rules for question %s", question.ID)
+			}
+			if v.Min != nil && v.Max != nil && *v.Max < *v.Min {
+				return fmt.Errorf("invalid number range for question %s", question.ID)
+			}
+			if v.Pattern != "" {
+				if _, err := regexp.Compile(v.Pattern); err != nil {
+					return fmt.Errorf("invalid pattern for question %s", question.ID)
+				}
+			}
+		}
+
+		seen[question.ID] = true
+	}
+
+	return nil
+}
+
+/* Check an answer against the input type and validation rules of its question */
+func validateAnswer(question *models.Question, answer string) error {
+	if answer == "" {
+		return nil
+	}
+
+	switch question.InputType {
+	case models.InputTypeSelect:
+		for _, option := range question.Options {
+			if option == answer {
+				return nil
+			}
+		}
+		return fmt.Errorf("invalid answer: %s is not a valid option", answer)
+	case models.InputTypeCheckbox:
+		if answer != "true" && answer != "false" {
+			return errors.New("invalid answer: expected true or false")
+		}
+	case models.InputTypeNumber:
+		number, err := strconv.ParseFloat(answer, 64)
+		if err != nil {
+			return errors.New("invalid answer: expected a number")
+		}
+		if v := question.Validation; v != nil {
+			if v.Min != nil && number < *v.Min {
+				return fmt.Errorf("invalid answer: must be at least %g", *v.Min)
+			}
+			if v.Max != nil && number > *v.Max {
+				return fmt.Errorf("invalid answer: must be at most %g", *v.Max)
+			}
+		}
+		return nil
+	case models.InputTypeDate:
+		if _, err := time.Parse("2006-01-02", answer); err != nil {
+			return errors.New("invalid answer: expected a date in YYYY-MM-DD format")
+		}
+		return nil
+	case models.InputTypeURL:
+		parsed, err := url.ParseRequestURI(answer)
+		if err != nil || (parsed.Scheme != "http" && parsed.Scheme != "https") || parsed.Host == "" {
+			return errors.New("invalid answer: expected a valid http(s) url")
+		}
+	}
+
+	if v := question.Validation; v != nil {
+		length := utf8.RuneCountInString(answer)
+		if v.MinLength > 0 && length < v.MinLength {
+			return fmt.Errorf("invalid answer: must be at least %d characters", v.MinLength)
+		}
+		if v.MaxLength > 0 && length > v.MaxLength {
+			return fmt.Errorf("invalid answer: must be at most %d characters", v.MaxLength)
+		}
+		if v.Pattern != "" {
+			if matched, _ := regexp.MatchString(v.Pattern, answer); !matched {
+				return errors.New("invalid answer: does not match the expected format")
+			}
+		}
+	}
+
+	return nil
 }
 
 func (as *ApplyService) StartApplication(userID uint, positionID string) (*models.ApplicationSession, error) {
-	position := models.GetPositionByID(positionID)
+	position := as.GetPositionByID(positionID)
 	if position == nil {
 		return nil, errors.New("position not found")
 	}
 
+	if !position.IsOpen(time.Now()) {
+		return nil, errors.New("position is not accepting applications")
+	}
+
 	var activeApplications []models.Application
 	err := as.DB.Where("user_id = ? AND position_id = ? AND status IN (?, ?, ?)",
 		userID, positionID, models.StatusSubmitted, models.StatusInReview, models.StatusApproved).Find(&activeApplications).Error
@@ -111,6 +559,7 @@ func (as *ApplyService) StartApplication(userID uint, positionID string) (*model
 			ApplicationID:   existingApp.ID,
 			UserID:          userID,
 			PositionID:      positionID,
+			FormVersion:     existingApp.FormVersion,
 			CurrentQuestion: 0,
 			Answers:         make(map[string]string),
 			TimePerQuestion: make(map[string]int64),
@@ -133,10 +582,11 @@ func (as *ApplyService) StartApplication(userID uint, positionID string) (*model
 	}
 
 	application := &models.Application{
-		UserID:     userID,
-		PositionID: positionID,
-		Status:     models.StatusDraft,
-		ExpiresAt:  time.Now().AddDate(0, 0, 7),
+		UserID:      userID,
+		PositionID:  positionID,
+		FormVersion: position.Version,
+		Status:      models.StatusDraft,
+		ExpiresAt:   time.Now().AddDate(0, 0, 7),
 	}
 
 	err = as.DB.Create(application).Error
@@ -148,6 +598,7 @@ func (as *ApplyService) StartApplication(userID uint, positionID string) (*model
 		ApplicationID:   application.ID,
 		UserID:          userID,
 		PositionID:      positionID,
+		FormVersion:     application.FormVersion,
 		CurrentQuestion: 0,
 		Answers:         make(map[string]string),
 		TimePerQuestion: make(map[string]int64),
@@ -162,7 +613,7 @@ func (as *ApplyService) StartApplication(userID uint, positionID string) (*model
 	return &session, nil
 }
 
-func (as *ApplyService) SaveAnswer(userID uint, positionID string, questionID string, answer string, timeSpent int64) (*models.ApplicationSession, error) {
+func (as *ApplyService) SaveAnswer(userID uint, positionID string, questionID string, answer string, timeSpent int64, pasteEvents int) (*models.ApplicationSession, error) {
 	sessionKey := fmt.Sprintf("application:session:%d:%s", userID, positionID)
 	sessionData, err := as.Client.Get(sessionKey).Result()
 
@@ -179,11 +630,24 @@ func (as *ApplyService) SaveAnswer(userID uint, positionID string, questionID st
 		return nil, err
 	}
 
-	position := models.GetPositionByID(positionID)
+	position := as.GetPositionVersion(positionID, session.FormVersion)
 	if position == nil {
 		return nil, errors.New("position not found")
 	}
 
+	question := position.GetQuestion(questionID)
+	if question == nil {
+		return nil, errors.New("question not found")
+	}
+
+	if err := validateAnswer(question, answer); err != nil {
+		return nil, err
+	}
+
+	if pasteEvents < 0 {
+		pasteEvents = 0
+	}
+
 	session.Answers[questionID] = answer
 	session.TimePerQuestion[questionID] = timeSpent
 	session.LastActiveTime = time.Now()
@@ -198,6 +662,7 @@ func (as *ApplyService) SaveAnswer(userID uint, positionID string, questionID st
 	if err == nil {
 		existingResponse.Answer = answer
 		existingResponse.TimeToAnswer = timeSpent
+		existingResponse.PasteEvents += pasteEvents
 		err = as.DB.Save(&existingResponse).Error
 		if err != nil {
 			return nil, err
@@ -208,6 +673,7 @@ func (as *ApplyService) SaveAnswer(userID uint, positionID string, questionID st
 			QuestionID:    questionID,
 			Answer:        answer,
 			TimeToAnswer:  timeSpent,
+			PasteEvents:   pasteEvents,
 		}
 		err = as.DB.Create(&response).Error
 		if err != nil {
@@ -241,16 +707,24 @@ func (as *ApplyService) SubmitApplication(userID uint, positionID string) error
 		return err
 	}
 
-	position := models.GetPositionByID(positionID)
+	position := as.GetPositionVersion(positionID, session.FormVersion)
 	if position == nil {
 		return errors.New("position not found")
 	}
 
-	for _, question := range position.Questions {
-		if question.Required {
-			if answer, exists := session.Answers[question.ID]; !exists || answer == "" {
-				return errors.New("not all required questions have been answered")
-			}
+	for i := range position.Questions {
+		question := &position.Questions[i]
+		if !position.IsQuestionVisible(question, session.Answers) {
+			continue
+		}
+
+		answer := session.Answers[question.ID]
+		if question.Required && answer == "" {
+			return errors.New("not all required questions have been answered")
+		}
+
+		if err := validateAnswer(question, answer); err != nil {
+			return fmt.Errorf("%s: %s", question.Title, err.Error())
 		}
 	}
 
@@ -377,9 +851,20 @@ func (as *ApplyService) ReviewApplication(applicationID uint, reviewerID uint, s
 		"feedback_note": feedbackNote,
 	}
 
-	err = as.DB.Model(&models.Application{}).Where("id = ?", applicationID).Updates(updates).Error
-	if err != nil {
-		return err
+	// Only one decision wins when staff review concurrently or the quorum is
+	// reached at the same time, the others must not email or grant again
+	result := as.DB.Model(&models.Application{}).
+		Where("id = ? AND status IN ?", applicationID, []models.ApplicationStatus{models.StatusSubmitted, models.StatusInReview}).
+		Updates(updates)
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected != 1 {
+		return errors.New("application is not in a reviewable status")
+	}
+
+	if status == models.StatusApproved {
+		as.grantPosition(application)
 	}
 
 	user, err := as.UserService.GetUserByUID(application.UserID)
@@ -392,7 +877,7 @@ func (as *ApplyService) ReviewApplication(applicationID uint, reviewerID uint, s
 		return nil
 	}
 
-	position := models.GetPositionByID(application.PositionID)
+	position := as.GetPositionByID(application.PositionID)
 	if position == nil {
 		log.Printf("Position not found for email notification: %s", application.PositionID)
 		return nil
@@ -455,3 +940,326 @@ func (as *ApplyService) CleanupExpiredApplications() error {
 	return as.DB.Where("status = ? AND expires_at < ?",
 		models.StatusDraft, now).Delete(&models.Application{}).Error
 }
+
+/* Give an approved applicant the staff level of the position and the staff badge */
+func (as *ApplyService) grantPosition(application *models.Application) {
+	position := as.GetPositionByID(application.PositionID)
+	if position == nil || position.StaffLevel == 0 {
+		return
+	}
+
+	user, err := as.UserService.GetUserByUID(application.UserID)
+	if err != nil {
+		log.Printf("Error getting approved applicant %d: %v", application.UserID, err)
+		return
+	}
+
+	if user.StaffLevel < position.StaffLevel {
+		if err := as.UserService.UpdateUser(user.UID, map[string]interface{}{"staff_level": position.StaffLevel}); err != nil {
+			log.Printf("Error setting staff level for approved applicant %d: %v", user.UID, err)
+			return
+		}
+	}
+
+	if as.BadgeService != nil {
+		if err := as.BadgeService.AssignBadge(user.UID, "Staff"); err != nil {
+			log.Printf("Error assigning staff badge to approved applicant %d: %v", user.UID, err)
+		}
+	}
+}
+
+/* Add or update the review of a staff member and decide the application once the quorum is reached */
+func (as *ApplyService) SubmitReview(applicationID uint, reviewerID uint, vote models.ReviewVote, comment string, scores map[string]int) (*models.ApplicationReviewSummary, error) {
+	if !models.IsValidReviewVote(vote) {
+		return nil, errors.New("invalid vote")
+	}
+
+	application, err := as.GetApplicationByID(applicationID)
+	if err != nil {
+		return nil, errors.New("application not found")
+	}
+
+	if application.UserID == reviewerID {
+		return nil, errors.New("you cannot review your own application")
+	}
+
+	if application.Status != models.StatusSubmitted && application.Status != models.StatusInReview {
+		return nil, errors.New("application is not in a reviewable status")
+	}
+
+	position := as.GetPositionVersion(application.PositionID, application.FormVersion)
+	if position == nil {
+		return nil, errors.New("position not found")
+	}
+
+	for questionID, score := range scores {
+		if position.GetQuestion(questionID) == nil {
+			return nil, fmt.Errorf("question %s is not part of this application", questionID)
+		}
+		if score < models.ApplicationMinScore || score > models.ApplicationMaxScore {
+			return nil, fmt.Errorf("scores must be between %d and %d", models.ApplicationMinScore, models.ApplicationMaxScore)
+		}
+	}
+
+	err = as.DB.Transaction(func(tx *gorm.DB) error {
+		review := models.ApplicationReview{
+			ApplicationID: applicationID,
+			ReviewerID:    reviewerID,
+			Vote:          vote,
+			Comment:       comment,
+		}
+
+		if err := tx.Clauses(clause.OnConflict{
+			Columns:   []clause.Column{{Name: "application_id"}, {Name: "reviewer_id"}},
+			DoUpdates: clause.AssignmentColumns([]string{"vote", "comment", "updated_at"}),
+		}).Create(&review).Error; err != nil {
+			return err
+		}
+
+		if err := tx.Where("application_id = ? AND reviewer_id = ?", applicationID, reviewerID).First(&review).Error; err != nil {
+			return err
+		}
+
+		if err := tx.Where("review_id = ?", review.ID).Delete(&models.ApplicationScore{}).Error; err != nil {
+			return err
+		}
+
+		for questionID, score := range scores {
+			if err := tx.Create(&models.ApplicationScore{
+				ReviewID:   review.ID,
+				QuestionID: questionID,
+				Score:      score,
+			}).Error; err != nil {
+				return err
+			}
+		}
+
+		if application.Status == models.StatusSubmitted {
+			return tx.Model(&models.Application{}).
+				Where("id = ? AND status = ?", applicationID, models.StatusSubmitted).
+				Update("status", models.StatusInReview).Error
+		}
+
+		return nil
+	})
+	if err != nil {
+		log.Printf("Error saving review for application %d: %v", applicationID, err)
+		return nil, err
+	}
+
+	summary, err := as.GetReviewSummary(applicationID)
+	if err != nil {
+		return nil, err
+	}
+
+	if summary.Decision != "" {
+		feedback := "Thank you for applying. After reviewing your application our team has decided not to move forward at this time."
+		if summary.Decision == models.StatusApproved {
+			feedback = "Welcome to the team! A staff member will reach out to you with the next steps."
+		}
+
+		// Another review may have decided the application in the meantime
+		err := as.ReviewApplication(applicationID, reviewerID, summary.Decision, feedback)
+		if err != nil && err.Error() != "application is not in a reviewable status" {
+			log.Printf("Error finalizing application %d after quorum: %v", applicationID, err)
+			return nil, err
+		}
+	}
+
+	return summary, nil
+}
+
+/* Get all reviews of an application */
+func (as *ApplyService) GetReviews(applicationID uint) ([]models.ApplicationReview, error) {
+	var reviews []models.ApplicationReview
+	err := as.DB.Where("application_id = ?", applicationID).
+		Preload("Scores").
+		Order("created_at ASC").
+		Find(&reviews).Error
+	return reviews, err
+}
+
+/* Aggregate the votes and scores of an application */
+func (as *ApplyService) GetReviewSummary(applicationID uint) (*models.ApplicationReviewSummary, error) {
+	reviews, err := as.GetReviews(applicationID)
+	if err != nil {
+		return nil, err
+	}
+
+	return summarizeReviews(reviews), nil
+}
+
+func summarizeReviews(reviews []models.ApplicationReview) *models.ApplicationReviewSummary {
+	summary := &models.ApplicationReviewSummary{
+		Reviews:        len(reviews),
+		Quorum:         models.ApplicationReviewQuorum,
+		QuestionScores: make(map[string]float64),
+	}
+
+	var total, count int
+	questionTotals := make(map[string]int)
+	questionCounts := make(map[string]int)
+
+	for _, review := range reviews {
+		switch review.Vote {
+		case models.ReviewVoteApprove:
+			summary.Approvals++
+		case models.ReviewVoteReject:
+			summary.Rejections++
+		default:
+			summary.Abstentions++
+		}
+
+		for _, score := range review.Scores {
+			total += score.Score
+			count++
+			questionTotals[score.QuestionID] += score.Score
+			questionCounts[score.QuestionID]++
+		}
+	}
+
+	if count > 0 {
+		summary.AverageScore = float64(total) / float64(count)
+	}
+	for questionID, questionTotal := range questionTotals {
+		summary.QuestionScores[questionID] = float64(questionTotal) / float64(questionCounts[questionID])
+	}
+
+	// A decision needs enough approve/reject votes and a clear majority, ties wait for more reviews
+	if summary.Approvals+summary.Rejections >= summary.Quorum {
+		if summary.Approvals > summary.Rejections {
+			summary.Decision = models.StatusApproved
+		} else if summary.Rejections > summary.Approvals {
+			summary.Decision = models.StatusRejected
+		}
+	}
+
+	return summary
+}
+
+/* Collect anti-cheat signals for an application */
+func (as *ApplyService) GetRiskSummary(application *models.Application) (*models.ApplicationRiskSummary, error) {
+	summary := &models.ApplicationRiskSummary{
+		Level:   models.RiskLevelLow,
+		Signals: []models.ApplicationRiskSignal{},
+	}
+
+	position := as.GetPositionVersion(application.PositionID, application.FormVersion)
+	if position == nil {
+		return summary, errors.New("position not found")
+	}
+
+	for _, response := range application.Responses {
+		question := position.GetQuestion(response.QuestionID)
+		if question == nil || question.InputType != models.InputTypeLongText {
+			continue
+		}
+
+		if response.PasteEvents > 0 {
+			summary.Add(models.ApplicationRiskSignal{
+				Type:       models.RiskSignalPasted,
+				Weight:     10,
+				QuestionID: response.QuestionID,
+				Message:    fmt.Sprintf("\"%s\" contains pasted text (%d paste events)", question.Title, response.PasteEvents),
+			})
+		}
+
+		length := utf8.RuneCountInString(response.Answer)
+		if length >= riskMinAnswerLength && response.PasteEvents == 0 {
+			if response.TimeToAnswer <= 0 || float64(length)/float64(response.TimeToAnswer) > riskMaxCharsPerSecond {
+				summary.Add(models.ApplicationRiskSignal{
+					Type:       models.RiskSignalFastAnswer,
+					Weight:     10,
+					QuestionID: response.QuestionID,
+					Message:    fmt.Sprintf("\"%s\" (%d characters) was answered in %d seconds", question.Title, length, response.TimeToAnswer),
+				})
+			}
+		}
+
+		if length >= riskMinAnswerLength {
+			as.checkSimilarAnswers(summary, application, question, &response)
+		}
+	}
+
+	if application.SubmittedAt != nil && len(application.Responses) > 0 {
+		minimum := int64(len(application.Responses) * riskMinSecondsPerQuestion)
+		if application.TimeToComplete < minimum {
+			summary.Add(models.ApplicationRiskSignal{
+				Type:    models.RiskSignalFastComplete,
+				Weight:  25,
+				Message: fmt.Sprintf("Completed %d answers in %d seconds", len(application.Responses), application.TimeToComplete),
+			})
+		}
+	}
+
+	if as.AltAccountService != nil {
+		alts, err := as.AltAccountService.GetAltAccounts(application.UserID)
+		if err != nil {
+			log.Printf("Error getting alt accounts for applicant %d: %v", application.UserID, err)
+		} else if len(alts) > 0 {
+			usernames := make([]string, 0, len(alts))
+			for _, alt := range alts {
+				usernames = append(usernames, alt.Username)
+			}
+
+			summary.Add(models.ApplicationRiskSignal{
+				Type:    models.RiskSignalAltAccount,
+				Weight:  30,
+				Message: fmt.Sprintf("Flagged as a possible alt of: %s", strings.Join(usernames, ", ")),
+			})
+		}
+	}
+
+	var punishments []models.Punishment
+	if err := as.DB.Where("user_id = ?", application.UserID).Find(&punishments).Error; err != nil {
+		return summary, err
+	}
+
+	for _, punishment := range punishments {
+		weight := 15
+		state := "past"
+		if punishment.Active {
+			weight = 30
+			state = "active"
+		}
+
+		summary.Add(models.ApplicationRiskSignal{
+			Type:    models.RiskSignalPunishment,
+			Weight:  weight,
+			Message: fmt.Sprintf("Has a %s punishment from %s: %s", state, punishment.CreatedAt.Format("2006-01-02"), punishment.Reason),
+		})
+	}
+
+	return summary, nil
+}
+
+func (as *ApplyService) checkSimilarAnswers(summary *models.ApplicationRiskSummary, application *models.Application, question *models.Question, response *models.Response) {
+	var others []models.Response
+	err := as.DB.Joins("JOIN applications ON applications.id = responses.application_id").
+		Where("responses.question_id = ? AND applications.position_id = ? AND applications.user_id <> ?",
+			response.QuestionID, application.PositionID, application.UserID).
+		Where("LENGTH(responses.answer) >= ?", riskMinAnswerLength).
+		Order("responses.updated_at DESC").
+		Limit(riskMaxComparedResponses).
+		Find(&others).Error
+	if err != nil {
+		log.Printf("Error loading responses for similarity check: %v", err)
+		return
+	}
+
+	shingles := utils.Shingles(response.Answer, riskShingleSize)
+	for _, other := range others {
+		similarity := utils.JaccardSimilarity(shingles, utils.Shingles(other.Answer, riskShingleSize))
+		if similarity < riskSimilarityThreshold {
+			continue
+		}
+
+		summary.Add(models.ApplicationRiskSignal{
+			Type:       models.RiskSignalSimilarAnswer,
+			Weight:     20,
+			QuestionID: response.QuestionID,
+			Message:    fmt.Sprintf("\"%s\" is %.0f%% similar to an answer in application #%d", question.Title, similarity*100, other.ApplicationID),
+		})
+		return
+	}
+}
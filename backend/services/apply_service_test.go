@@ -0,0 +1,35 @@
+package services
+
+import (
+	"testing"
+
+	"github.com/hazebio/haze.bio_backend/models"
+)
+
+func TestReviewApplicationDecidesOnce(t *testing.T) {
+	db := newTestDB(t, &models.Application{}, &models.Response{}, &models.User{})
+	client, _ := newFakeRedis(t)
+	as := &ApplyService{DB: db, Client: client, UserService: &UserService{DB: db, Client: client}}
+
+	application := &models.Application{UserID: 2, PositionID: "moderator", Status: models.StatusSubmitted}
+	if err := db.Create(application).Error; err != nil {
+		t.Fatal(err)
+	}
+
+	if err := as.ReviewApplication(application.ID, 1, models.StatusRejected, "no"); err != nil {
+		t.Fatal(err)
+	}
+
+	err := as.ReviewApplication(application.ID, 3, models.StatusApproved, "yes")
+	if err == nil || err.Error() != "application is not in a reviewable status" {
+		t.Fatalf("second decision = %v, want it refused", err)
+	}
+
+	var stored models.Application
+	if err := db.First(&stored, application.ID).Error; err != nil {
+		t.Fatal(err)
+	}
+	if stored.Status != models.StatusRejected || stored.ReviewedBy == nil || *stored.ReviewedBy != 1 {
+		t.Fatalf("stored application = %s by %v, want rejected by 1", stored.Status, stored.ReviewedBy)
+	}
+}
@@ -0,0 +1,302 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/go-redis/redis"
+	"github.com/hazebio/haze.bio_backend/models"
+	"github.com/hazebio/haze.bio_backend/utils"
+	"gorm.io/gorm"
+)
+
+type BadgeRuleService struct {
+	DB                  *gorm.DB
+	Client              *redis.Client
+	BadgeService        *BadgeService
+	NotificationService *NotificationService
+}
+
+func NewBadgeRuleService(db *gorm.DB, client *redis.Client, badgeService *BadgeService) *BadgeRuleService {
+	return &BadgeRuleService{
+		DB:           db,
+		Client:       client,
+		BadgeService: badgeService,
+	}
+}
+
+// BadgeRuleResult summarises one evaluation of a rule
+type BadgeRuleResult struct {
+	RuleID  uint `json:"rule_id"`
+	Granted int  `json:"granted"`
+	Revoked int  `json:"revoked"`
+}
+
+/* Get all badge rules */
+func (brs *BadgeRuleService) GetRules() ([]*models.BadgeRule, error) {
+	rules := []*models.BadgeRule{}
+	if err := brs.DB.Preload("Badge").Order("badge_id ASC, threshold ASC").Find(&rules).Error; err != nil {
+		log.Println("Error getting badge rules:", err)
+		return nil, err
+	}
+	return rules, nil
+}
+
+/* Create a badge rule */
+func (brs *BadgeRuleService) CreateRule(rule *models.BadgeRule) error {
+	if err := brs.validateRule(rule); err != nil {
+		return err
+	}
+
+	if err := brs.DB.Omit("Badge").Create(rule).Error; err != nil {
+		log.Println("Error creating badge rule:", err)
+		return err
+	}
+
+	return nil
+}
+
+/* Update the fields of a badge rule */
+func (brs *BadgeRuleService) UpdateRule(ruleID uint, fields map[string]interface{}) error {
+	var rule models.BadgeRule
+	if err := brs.DB.First(&rule, ruleID).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return errors.New("badge rule not found")
+		}
+		return err
+	}
+
+	allowed := map[string]bool{"threshold": true, "duration_days": true, "revocable": true, "active": true}
+	updates := make(map[string]interface{})
+	for key, value := range fields {
+		if allowed[key] {
+			updates[key] = value
+		}
+	}
+
+	if len(updates) == 0 {
+		return errors.New("no valid fields to update")
+	}
+
+	// Validate the rule as it would look after the update
+	if threshold, ok := updates["threshold"].(float64); ok {
+		rule.Threshold = int(threshold)
+	}
+	if duration, ok := updates["duration_days"].(float64); ok {
+		rule.DurationDays = int(duration)
+	}
+	if err := brs.validateRule(&rule); err != nil {
+		return err
+	}
+
+	if err := brs.DB.Model(&models.BadgeRule{}).Where("id = ?", ruleID).Updates(updates).Error; err != nil {
+		log.Println("Error updating badge rule:", err)
+		return err
+	}
+
+	return nil
+}
+
+/* Delete a badge rule. Badges it already awarded are kept */
+func (brs *BadgeRuleService) DeleteRule(ruleID uint) error {
+	result := brs.DB.Where("id = ?", ruleID).Delete(&models.BadgeRule{})
+	if result.Error != nil {
+		log.Println("Error deleting badge rule:", result.Error)
+		return result.Error
+	}
+
+	if result.RowsAffected == 0 {
+		return errors.New("badge rule not found")
+	}
+
+	if err := brs.DB.Where("rule_id = ?", ruleID).Delete(&models.BadgeRuleGrant{}).Error; err != nil {
+		log.Println("Error deleting badge rule grants:", err)
+		return err
+	}
+
+	if err := brs.DB.Model(&models.UserBadge{}).Where("rule_id = ?", ruleID).Update("rule_id", nil).Error; err != nil {
+		log.Println("Error detaching badges from rule:", err)
+		return err
+	}
+
+	return nil
+}
+
+func (brs *BadgeRuleService) validateRule(rule *models.BadgeRule) error {
+	switch rule.Type {
+	case models.BadgeRuleProfileViews, models.BadgeRuleAccountAge, models.BadgeRuleReferrals:
+		if rule.Threshold <= 0 {
+			return errors.New("threshold must be greater than 0")
+		}
+	case models.BadgeRulePremium:
+	default:
+		return errors.New("invalid rule type")
+	}
+
+	if rule.DurationDays < 0 {
+		return errors.New("duration must not be negative")
+	}
+
+	var badge models.Badge
+	if err := brs.DB.First(&badge, rule.BadgeID).Error; err != nil {
+		return errors.New("badge not found")
+	}
+
+	if badge.IsCustom {
+		return errors.New("custom badges cannot be awarded by rules")
+	}
+
+	return nil
+}
+
+/* Evaluate every active rule against every user */
+func (brs *BadgeRuleService) EvaluateAll() ([]BadgeRuleResult, error) {
+	var rules []models.BadgeRule
+	if err := brs.DB.Where("active = ?", true).Preload("Badge").Find(&rules).Error; err != nil {
+		return nil, err
+	}
+
+	results := []BadgeRuleResult{}
+	for i := range rules {
+		result, err := brs.EvaluateRule(&rules[i])
+		if err != nil {
+			log.Printf("Error evaluating badge rule %d: %v", rules[i].ID, err)
+			continue
+		}
+		results = append(results, *result)
+	}
+
+	return results, nil
+}
+
+/*
+Grant the rule's badge to every user meeting its condition who has not been
+granted it by this rule before, and take it back from users who no longer
+qualify if the rule is revocable.
+*/
+func (brs *BadgeRuleService) EvaluateRule(rule *models.BadgeRule) (*BadgeRuleResult, error) {
+	if rule.Badge == nil {
+		return nil, errors.New("badge not loaded")
+	}
+
+	qualifying, err := brs.qualifyingUsers(rule)
+	if err != nil {
+		return nil, err
+	}
+
+	result := &BadgeRuleResult{RuleID: rule.ID}
+
+	var newUIDs []uint
+	err = brs.DB.Table("(?) AS qualifying", qualifying).
+		Where("uid NOT IN (?)", brs.DB.Model(&models.BadgeRuleGrant{}).Select("uid").Where("rule_id = ?", rule.ID)).
+		Pluck("uid", &newUIDs).Error
+	if err != nil {
+		return nil, err
+	}
+
+	for _, uid := range newUIDs {
+		if err := brs.grant(rule, uid); err != nil {
+			log.Printf("Error granting badge rule %d to user %d: %v", rule.ID, uid, err)
+			continue
+		}
+		result.Granted++
+	}
+
+	if rule.Revocable {
+		var staleUIDs []uint
+		err = brs.DB.Model(&models.BadgeRuleGrant{}).
+			Where("rule_id = ? AND uid NOT IN (?)", rule.ID, qualifying).
+			Pluck("uid", &staleUIDs).Error
+		if err != nil {
+			return nil, err
+		}
+
+		for _, uid := range staleUIDs {
+			if err := brs.revoke(rule, uid); err != nil {
+				log.Printf("Error revoking badge rule %d from user %d: %v", rule.ID, uid, err)
+				continue
+			}
+			result.Revoked++
+		}
+	}
+
+	if result.Granted > 0 || result.Revoked > 0 {
+		log.Printf("Badge rule %d (%s): granted %d, revoked %d", rule.ID, rule.Badge.Name, result.Granted, result.Revoked)
+	}
+
+	return result, nil
+}
+
+/* Subquery selecting the uid of every user meeting the rule's condition */
+func (brs *BadgeRuleService) qualifyingUsers(rule *models.BadgeRule) (*gorm.DB, error) {
+	switch rule.Type {
+	case models.BadgeRuleProfileViews:
+		return brs.DB.Model(&models.UserProfile{}).Select("uid").Where("views >= ?", rule.Threshold), nil
+	case models.BadgeRuleAccountAge:
+		cutoff := time.Now().AddDate(0, 0, -rule.Threshold)
+		return brs.DB.Model(&models.User{}).Select("uid").Where("created_at <= ?", cutoff), nil
+	case models.BadgeRuleReferrals:
+		return brs.DB.Model(&models.Referral{}).
+			Select("referrer_id AS uid").
+			Where("status = ?", models.ReferralStatusVerified).
+			Group("referrer_id").
+			Having("COUNT(*) >= ?", rule.Threshold), nil
+	case models.BadgeRulePremium:
+		return brs.DB.Model(&models.UserSubscription{}).Select("user_id AS uid").Where("status = ?", "active"), nil
+	}
+
+	return nil, errors.New("invalid rule type")
+}
+
+func (brs *BadgeRuleService) grant(rule *models.BadgeRule, uid uint) error {
+	grant := &models.BadgeRuleGrant{
+		RuleID: rule.ID,
+		UID:    uid,
+	}
+
+	if err := brs.DB.Create(grant).Error; err != nil {
+		if utils.IsError(err, utils.ErrDuplicateKey) {
+			return nil
+		}
+		return err
+	}
+
+	var expiresAt *time.Time
+	if rule.DurationDays > 0 {
+		expiry := time.Now().AddDate(0, 0, rule.DurationDays)
+		expiresAt = &expiry
+	}
+
+	if err := brs.BadgeService.GrantBadge(uid, rule.Badge.Name, expiresAt, &rule.ID); err != nil {
+		brs.DB.Delete(grant)
+		return err
+	}
+
+	if brs.NotificationService != nil {
+		message := fmt.Sprintf("You unlocked the %s badge.", rule.Badge.Name)
+		if err := brs.NotificationService.NotifyWithoutEmail(uid, models.NotificationCategorySocial, "Badge unlocked", message, "/dashboard/badges"); err != nil {
+			log.Printf("Error sending badge unlocked notification: %v", err)
+		}
+	}
+
+	return nil
+}
+
+/* Take back a badge awarded by the rule. Badges granted by hand are left alone */
+func (brs *BadgeRuleService) revoke(rule *models.BadgeRule, uid uint) error {
+	var count int64
+	if err := brs.DB.Model(&models.UserBadge{}).Where("uid = ? AND badge_id = ? AND rule_id = ?", uid, rule.BadgeID, rule.ID).Count(&count).Error; err != nil {
+		return err
+	}
+
+	if count > 0 {
+		if err := brs.BadgeService.RemoveBadge(uid, rule.Badge.Name); err != nil {
+			return err
+		}
+	}
+
+	// Forget the grant so the badge can be earned again
+	return brs.DB.Where("rule_id = ? AND uid = ?", rule.ID, uid).Delete(&models.BadgeRuleGrant{}).Error
+}
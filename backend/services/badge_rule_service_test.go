@@ -0,0 +1,53 @@
+package services
+
+import (
+	"testing"
+
+	"github.com/hazebio/haze.bio_backend/models"
+)
+
+func TestCreateRuleActive(t *testing.T) {
+	db := newTestDB(t, &models.Badge{}, &models.BadgeRule{})
+	client, _ := newFakeRedis(t)
+	brs := NewBadgeRuleService(db, client, NewBadgeService(db, client))
+
+	badge := &models.Badge{Name: "Veteran"}
+	if err := db.Create(badge).Error; err != nil {
+		t.Fatal(err)
+	}
+
+	inactive := false
+	tests := []struct {
+		name   string
+		active *bool
+		want   bool
+	}{
+		{"unset", nil, true},
+		{"inactive", &inactive, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rule := &models.BadgeRule{BadgeID: badge.ID, Type: models.BadgeRulePremium, Active: tt.active}
+			if err := brs.CreateRule(rule); err != nil {
+				t.Fatal(err)
+			}
+
+			var stored models.BadgeRule
+			if err := db.First(&stored, rule.ID).Error; err != nil {
+				t.Fatal(err)
+			}
+			if stored.Active == nil || *stored.Active != tt.want {
+				t.Fatalf("stored active = %v, want %v", stored.Active, tt.want)
+			}
+
+			var count int64
+			if err := db.Model(&models.BadgeRule{}).Where("id = ? AND active = ?", rule.ID, true).Count(&count).Error; err != nil {
+				t.Fatal(err)
+			}
+			if (count == 1) != tt.want {
+				t.Fatalf("rule picked up by active query = %v, want %v", count == 1, tt.want)
+			}
+		})
+	}
+}
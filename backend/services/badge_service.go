@@ -2,7 +2,9 @@ package services
 
 import (
 	"errors"
+	"fmt"
 	"log"
+	"time"
 
 	"github.com/go-redis/redis"
 	"github.com/hazebio/haze.bio_backend/models"
@@ -11,9 +13,12 @@ import (
 )
 
 type BadgeService struct {
-	DB          *gorm.DB
-	Client      *redis.Client
-	UserService *UserService
+	DB                  *gorm.DB
+	Client              *redis.Client
+	UserService         *UserService
+	EventService        *EventService
+	FileService         *FileService
+	NotificationService *NotificationService
 }
 
 func NewBadgeService(db *gorm.DB, client *redis.Client) *BadgeService {
@@ -31,60 +36,387 @@ type BadgeOrderUpdate struct {
 
 /* Create a new badge (for default badges) */
 func (b *BadgeService) CreateBadge(badge *models.Badge) error {
-	if err := b.DB.Create(badge).Error; err != nil {
+	if badge.Name == "" || badge.MediaURL == "" {
+		return errors.New("badge name and media url are required")
+	}
+
+	if badge.Category == "" {
+		badge.Category = models.DefaultBadgeCategory
+	}
+
+	if badge.Rarity == "" {
+		badge.Rarity = models.BadgeRarityCommon
+	}
+
+	if err := validateBadgeCatalogFields(badge.Category, badge.Rarity); err != nil {
+		return err
+	}
+
+	if err := b.DB.Omit("Roles", "Rules").Create(badge).Error; err != nil {
+		if utils.IsError(err, utils.ErrDuplicateKey) {
+			return errors.New("badge already exists")
+		}
 		log.Println("Error creating badge:", err)
 		return err
 	}
 	return nil
 }
 
-/* Edit Custom Badge name or mediaURL */
-func (b *BadgeService) EditCustomBadge(uid uint, badgeID uint, newName, newMediaURL string) error {
+/* Update the catalog fields of a badge */
+func (b *BadgeService) UpdateBadge(badgeID uint, fields map[string]interface{}) error {
+	allowed := map[string]bool{"name": true, "description": true, "category": true, "rarity": true, "media_url": true}
+	updates := make(map[string]interface{})
+	for key, value := range fields {
+		if allowed[key] {
+			updates[key] = value
+		}
+	}
+
+	if len(updates) == 0 {
+		return errors.New("no valid fields to update")
+	}
+
+	category, _ := updates["category"].(string)
+	if _, ok := updates["category"]; ok && category == "" {
+		return errors.New("invalid category")
+	}
+
+	rarity, _ := updates["rarity"].(string)
+	if _, ok := updates["rarity"]; ok && !models.IsValidBadgeRarity(models.BadgeRarity(rarity)) {
+		return errors.New("invalid rarity")
+	}
+
+	if err := validateBadgeCatalogFields(category, models.BadgeRarity(rarity)); err != nil {
+		return err
+	}
+
+	if name, ok := updates["name"].(string); ok && (name == "" || utils.ContainsHTML(name)) {
+		return errors.New("invalid badge name")
+	}
+
+	result := b.DB.Model(&models.Badge{}).Where("id = ?", badgeID).Updates(updates)
+	if result.Error != nil {
+		if utils.IsError(result.Error, utils.ErrDuplicateKey) {
+			return errors.New("badge name already exists")
+		}
+		log.Println("Error updating badge:", result.Error)
+		return result.Error
+	}
+
+	if result.RowsAffected == 0 {
+		return errors.New("badge not found")
+	}
+
+	return nil
+}
+
+func validateBadgeCatalogFields(category string, rarity models.BadgeRarity) error {
+	if len(category) > 50 {
+		return errors.New("category must be at most 50 characters")
+	}
+
+	if rarity != "" && !models.IsValidBadgeRarity(rarity) {
+		return errors.New("invalid rarity")
+	}
+
+	return nil
+}
+
+/* Get the catalog of non-custom badges grouped by category */
+func (b *BadgeService) GetBadgeCatalog() ([]*models.Badge, error) {
+	badges := []*models.Badge{}
+	err := b.DB.Where("is_custom = ?", false).Preload("Rules", "active = ?", true).Order("category ASC, name ASC").Find(&badges).Error
+	if err != nil {
+		log.Println("Error getting badge catalog:", err)
+		return nil, err
+	}
+	return badges, nil
+}
+
+/*
+Submit a change to the name or media of a custom badge for staff review. A
+badge edit credit is used now and refunded if the edit is rejected.
+*/
+func (b *BadgeService) EditCustomBadge(uid uint, badgeID uint, newName, newMediaURL string) (*models.BadgeEdit, error) {
 	var badge models.Badge
 	if err := b.DB.Where("id = ?", badgeID).First(&badge).Error; err != nil {
 		if errors.Is(err, gorm.ErrRecordNotFound) {
 			log.Printf("Badge with id '%d' not found", badgeID)
-			return errors.New("badge not found")
+			return nil, errors.New("badge not found")
 		}
 		log.Println("Error retrieving badge:", err)
-		return err
+		return nil, err
 	}
 
 	if !badge.IsCustom {
-		return errors.New("badge is not a custom badge")
+		return nil, errors.New("badge is not a custom badge")
+	}
+
+	var count int64
+	if err := b.DB.Model(&models.UserBadge{}).Where("uid = ? AND badge_id = ?", uid, badgeID).Count(&count).Error; err != nil {
+		return nil, err
+	}
+	if count == 0 {
+		return nil, errors.New("badge not found")
+	}
+
+	if err := b.DB.Model(&models.BadgeEdit{}).Where("badge_id = ? AND status = ?", badgeID, models.BadgeEditPending).Count(&count).Error; err != nil {
+		return nil, err
+	}
+	if count > 0 {
+		return nil, errors.New("badge edit already pending")
+	}
+
+	edit := &models.BadgeEdit{
+		BadgeID:     badgeID,
+		UID:         uid,
+		OldName:     badge.Name,
+		OldMediaURL: badge.MediaURL,
+		Status:      models.BadgeEditPending,
 	}
 
 	if newName != "" && newName != badge.Name {
 		if utils.ContainsHTML(newName) {
-			return errors.New("badge name cannot contain HTML")
+			return nil, errors.New("badge name cannot contain HTML")
 		}
 
 		var existingBadge models.Badge
 		if err := b.DB.Where("LOWER(name) = ?", utils.ToLowerCase(newName)).First(&existingBadge).Error; err == nil {
-			return errors.New("badge name already exists")
+			return nil, errors.New("badge name already exists")
 		} else if !errors.Is(err, gorm.ErrRecordNotFound) {
 			log.Println("Error checking if badge exists:", err)
-			return err
+			return nil, err
+		}
+
+		// Another pending edit may already claim the name
+		if err := b.DB.Model(&models.BadgeEdit{}).Where("LOWER(new_name) = ? AND status = ?", utils.ToLowerCase(newName), models.BadgeEditPending).Count(&count).Error; err != nil {
+			return nil, err
+		}
+		if count > 0 {
+			return nil, errors.New("badge name already exists")
 		}
 
-		badge.Name = newName
+		edit.NewName = newName
 	}
 
 	if newMediaURL != "" && newMediaURL != badge.MediaURL {
-		badge.MediaURL = newMediaURL
+		// Only media uploaded through the custom badge upload has been validated
+		if _, ok := customBadgeMediaKey(badgeID, newMediaURL); !ok {
+			return nil, errors.New("invalid media url")
+		}
+
+		edit.NewMediaURL = newMediaURL
 	}
 
-	if err := b.DB.Where("id = ?", badgeID).Updates(&badge).Error; err != nil {
-		log.Println("Error updating badge:", err)
-		return err
+	if edit.NewName == "" && edit.NewMediaURL == "" {
+		return nil, errors.New("nothing to change")
 	}
 
 	if err := b.UserService.UseBadgeEditCredits(uid); err != nil {
 		log.Println("Error using badge edit credits:", err)
-		return err
+		return nil, err
 	}
 
-	return nil
+	if err := b.DB.Create(edit).Error; err != nil {
+		log.Println("Error creating badge edit:", err)
+		if refundErr := b.UserService.AddBadgeEditCredits(uid, 1); refundErr != nil {
+			log.Println("Error refunding badge edit credit:", refundErr)
+		}
+
+		if utils.IsError(err, utils.ErrDuplicateKey) {
+			return nil, errors.New("badge edit already pending")
+		}
+		return nil, err
+	}
+
+	b.publishBadgeEditEvent(models.EventBadgeEditSubmitted, edit)
+	return edit, nil
+}
+
+/* Get badge edits with the given status, oldest first */
+func (b *BadgeService) GetBadgeEdits(status models.BadgeEditStatus) ([]models.BadgeEditWithDetails, error) {
+	edits := []models.BadgeEditWithDetails{}
+	err := b.badgeEditQuery().
+		Where("badge_edits.status = ?", status).
+		Order("badge_edits.created_at ASC").
+		Limit(100).
+		Scan(&edits).Error
+	if err != nil {
+		log.Println("Error getting badge edits:", err)
+		return nil, err
+	}
+	return edits, nil
+}
+
+/* Get a single badge edit */
+func (b *BadgeService) GetBadgeEdit(editID uint) (*models.BadgeEditWithDetails, error) {
+	edits := []models.BadgeEditWithDetails{}
+	if err := b.badgeEditQuery().Where("badge_edits.id = ?", editID).Limit(1).Scan(&edits).Error; err != nil {
+		log.Println("Error getting badge edit:", err)
+		return nil, err
+	}
+
+	if len(edits) == 0 {
+		return nil, errors.New("badge edit not found")
+	}
+
+	return &edits[0], nil
+}
+
+/* Get the most recent badge edits submitted by a user */
+func (b *BadgeService) GetUserBadgeEdits(uid uint) ([]models.BadgeEdit, error) {
+	edits := []models.BadgeEdit{}
+	if err := b.DB.Where("uid = ?", uid).Order("created_at DESC").Limit(25).Find(&edits).Error; err != nil {
+		log.Println("Error getting user badge edits:", err)
+		return nil, err
+	}
+	return edits, nil
+}
+
+func (b *BadgeService) badgeEditQuery() *gorm.DB {
+	return b.DB.Table("badge_edits").
+		Select("badge_edits.*, users.username AS username, reviewers.username AS reviewer_username").
+		Joins("LEFT JOIN users ON users.uid = badge_edits.uid").
+		Joins("LEFT JOIN users AS reviewers ON reviewers.uid = badge_edits.reviewed_by")
+}
+
+/*
+Approve or reject a pending badge edit. Approved edits are applied to the
+badge and replace its old media, rejected edits refund the badge edit credit
+and delete the uploaded media.
+*/
+func (b *BadgeService) ReviewBadgeEdit(editID uint, reviewerID uint, approve bool, reason string) (*models.BadgeEdit, error) {
+	var edit models.BadgeEdit
+	if err := b.DB.First(&edit, editID).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, errors.New("badge edit not found")
+		}
+		return nil, err
+	}
+
+	if edit.Status != models.BadgeEditPending {
+		return nil, errors.New("badge edit has already been reviewed")
+	}
+
+	status := models.BadgeEditRejected
+	if approve {
+		status = models.BadgeEditApproved
+	}
+	now := time.Now()
+
+	err := b.DB.Transaction(func(tx *gorm.DB) error {
+		// Only one reviewer can resolve the edit
+		result := tx.Model(&models.BadgeEdit{}).
+			Where("id = ? AND status = ?", editID, models.BadgeEditPending).
+			Updates(map[string]interface{}{
+				"status":      status,
+				"reviewed_by": reviewerID,
+				"reason":      reason,
+				"reviewed_at": now,
+			})
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return errors.New("badge edit has already been reviewed")
+		}
+
+		if !approve {
+			return nil
+		}
+
+		updates := make(map[string]interface{})
+		if edit.NewName != "" {
+			updates["name"] = edit.NewName
+		}
+		if edit.NewMediaURL != "" {
+			updates["media_url"] = edit.NewMediaURL
+		}
+
+		if err := tx.Model(&models.Badge{}).Where("id = ?", edit.BadgeID).Updates(updates).Error; err != nil {
+			if utils.IsError(err, utils.ErrDuplicateKey) {
+				return errors.New("badge name already exists")
+			}
+			return err
+		}
+
+		return nil
+	})
+	if err != nil {
+		if err.Error() != "badge edit has already been reviewed" && err.Error() != "badge name already exists" {
+			log.Println("Error reviewing badge edit:", err)
+		}
+		return nil, err
+	}
+
+	edit.Status = status
+	edit.ReviewedBy = reviewerID
+	edit.Reason = reason
+	edit.ReviewedAt = &now
+
+	if approve {
+		if edit.NewMediaURL != "" && edit.OldMediaURL != "" {
+			b.deleteBadgeMedia(edit.BadgeID, edit.OldMediaURL)
+		}
+	} else {
+		if err := b.UserService.AddBadgeEditCredits(edit.UID, 1); err != nil {
+			log.Printf("Error refunding badge edit credit to user %d: %v", edit.UID, err)
+		}
+		if edit.NewMediaURL != "" {
+			b.deleteBadgeMedia(edit.BadgeID, edit.NewMediaURL)
+		}
+	}
+
+	b.notifyBadgeEditReviewed(&edit)
+	b.publishBadgeEditEvent(models.EventBadgeEditReviewed, &edit)
+	return &edit, nil
+}
+
+func (b *BadgeService) deleteBadgeMedia(badgeID uint, mediaURL string) {
+	if b.FileService == nil {
+		return
+	}
+
+	if err := b.FileService.DeleteCustomBadgeMedia(badgeID, mediaURL); err != nil {
+		log.Printf("Error deleting custom badge media %s: %v", mediaURL, err)
+	}
+}
+
+func (b *BadgeService) notifyBadgeEditReviewed(edit *models.BadgeEdit) {
+	if b.NotificationService == nil {
+		return
+	}
+
+	title := "Badge edit approved"
+	message := "Your custom badge edit has been approved."
+	if edit.Status == models.BadgeEditRejected {
+		title = "Badge edit rejected"
+		message = "Your custom badge edit has been rejected and your badge edit credit was refunded."
+		if edit.Reason != "" {
+			message = fmt.Sprintf("Your custom badge edit has been rejected: %s. Your badge edit credit was refunded.", edit.Reason)
+		}
+	}
+
+	if err := b.NotificationService.NotifyWithoutEmail(edit.UID, models.NotificationCategoryModeration, title, message, "/dashboard/badges"); err != nil {
+		log.Printf("Error sending badge edit notification: %v", err)
+	}
+}
+
+func (b *BadgeService) publishBadgeEditEvent(eventType models.EventType, edit *models.BadgeEdit) {
+	if b.EventService == nil {
+		return
+	}
+
+	data := models.BadgeEditEventData{
+		EditID:  edit.ID,
+		UID:     edit.UID,
+		BadgeID: edit.BadgeID,
+		Status:  edit.Status,
+	}
+
+	if _, err := b.EventService.Publish(eventType, data); err != nil {
+		log.Printf("Failed to publish %s event: %v", eventType, err)
+	}
 }
 
 /* Create a custom badge for a user */
@@ -126,6 +458,15 @@ func (b *BadgeService) CreateCustomBadge(uid uint, name string, mediaURL string)
 
 /* Assign a badge to a user */
 func (b *BadgeService) AssignBadge(uid uint, badgeName string) error {
+	return b.GrantBadge(uid, badgeName, nil, nil)
+}
+
+/*
+Assign a badge to a user, optionally until expiresAt and on behalf of a rule.
+Granting a badge the user already holds extends a time-limited grant but
+never shortens a permanent one.
+*/
+func (b *BadgeService) GrantBadge(uid uint, badgeName string, expiresAt *time.Time, ruleID *uint) error {
 	var badge models.Badge
 	if err := b.DB.Where("name = ?", badgeName).First(&badge).Error; err != nil {
 		log.Printf("Badge with name %s not found: %v", badgeName, err)
@@ -134,6 +475,9 @@ func (b *BadgeService) AssignBadge(uid uint, badgeName string) error {
 
 	var existingUserBadge models.UserBadge
 	if err := b.DB.Where("uid = ? AND badge_id = ?", uid, badge.ID).First(&existingUserBadge).Error; err == nil {
+		if existingUserBadge.ExpiresAt != nil && (expiresAt == nil || expiresAt.After(*existingUserBadge.ExpiresAt)) {
+			return b.DB.Model(&existingUserBadge).Update("expires_at", expiresAt).Error
+		}
 		log.Printf("User %d already has the badge %d", uid, badge.ID)
 		return nil
 	} else if err != gorm.ErrRecordNotFound {
@@ -142,8 +486,10 @@ func (b *BadgeService) AssignBadge(uid uint, badgeName string) error {
 	}
 
 	userBadge := &models.UserBadge{
-		UID:     uid,
-		BadgeID: badge.ID,
+		UID:       uid,
+		BadgeID:   badge.ID,
+		ExpiresAt: expiresAt,
+		RuleID:    ruleID,
 	}
 
 	log.Println("Assigning badge to user:", userBadge)
@@ -153,6 +499,7 @@ func (b *BadgeService) AssignBadge(uid uint, badgeName string) error {
 		return err
 	}
 
+	b.publishBadgeEvent(models.EventBadgeAssigned, uid, &badge)
 	return nil
 }
 
@@ -183,6 +530,7 @@ func (b *BadgeService) RemoveBadge(uid uint, badgeName string) error {
 		return err
 	}
 
+	b.publishBadgeEvent(models.EventBadgeRemoved, uid, &badge)
 	return nil
 }
 
@@ -208,6 +556,21 @@ func (b *BadgeService) DeleteBadge(badgeName string) error {
 		return err
 	}
 
+	if err := b.DB.Where("badge_id = ?", badge.ID).Delete(&models.BadgeRole{}).Error; err != nil {
+		log.Println("Error deleting role mappings for badge:", err)
+		return err
+	}
+
+	if err := b.DB.Where("rule_id IN (?)", b.DB.Model(&models.BadgeRule{}).Select("id").Where("badge_id = ?", badge.ID)).Delete(&models.BadgeRuleGrant{}).Error; err != nil {
+		log.Println("Error deleting rule grants for badge:", err)
+		return err
+	}
+
+	if err := b.DB.Where("badge_id = ?", badge.ID).Delete(&models.BadgeRule{}).Error; err != nil {
+		log.Println("Error deleting rules for badge:", err)
+		return err
+	}
+
 	return nil
 }
 
@@ -285,3 +648,188 @@ func (b *BadgeService) HideUserBadge(uid uint, badgeID uint, hidden bool) error
 
 	return nil
 }
+
+/* Get the Discord roles granted by a badge */
+func (b *BadgeService) GetBadgeRoles(badgeName string) ([]models.BadgeRole, error) {
+	badge, err := b.GetBadge(badgeName)
+	if err != nil {
+		return nil, errors.New("badge not found")
+	}
+
+	var roles []models.BadgeRole
+	if err := b.DB.Where("badge_id = ?", badge.ID).Order("guild_id, id").Find(&roles).Error; err != nil {
+		log.Println("Error getting badge roles:", err)
+		return nil, err
+	}
+
+	return roles, nil
+}
+
+/* Get the badge role mappings of a guild */
+func (b *BadgeService) GetGuildBadgeRoles(guildID string) ([]models.BadgeRole, error) {
+	var roles []models.BadgeRole
+	if err := b.DB.Where("guild_id = ?", guildID).Preload("Badge").Order("id").Find(&roles).Error; err != nil {
+		log.Println("Error getting guild badge roles:", err)
+		return nil, err
+	}
+
+	return roles, nil
+}
+
+/* Map a Discord role of a guild to a badge */
+func (b *BadgeService) AddBadgeRole(badgeName string, guildID string, roleID string) (*models.BadgeRole, error) {
+	badge, err := b.GetBadge(badgeName)
+	if err != nil {
+		return nil, errors.New("badge not found")
+	}
+
+	var existing models.BadgeRole
+	if err := b.DB.Where("badge_id = ? AND role_id = ?", badge.ID, roleID).First(&existing).Error; err == nil {
+		return nil, errors.New("role already mapped to this badge")
+	} else if !errors.Is(err, gorm.ErrRecordNotFound) {
+		log.Println("Error checking badge role:", err)
+		return nil, err
+	}
+
+	role := &models.BadgeRole{BadgeID: badge.ID, GuildID: guildID, RoleID: roleID}
+	if err := b.DB.Create(role).Error; err != nil {
+		log.Println("Error creating badge role:", err)
+		return nil, err
+	}
+
+	b.publishRolesUpdated(badge.Name, guildID, "")
+	return role, nil
+}
+
+/* Remove a Discord role mapping from a badge */
+func (b *BadgeService) RemoveBadgeRole(badgeName string, roleID string) error {
+	badge, err := b.GetBadge(badgeName)
+	if err != nil {
+		return errors.New("badge not found")
+	}
+
+	return b.removeBadgeRole(b.DB.Where("badge_id = ? AND role_id = ?", badge.ID, roleID))
+}
+
+/* Remove a badge role mapping by its ID, scoped to a guild */
+func (b *BadgeService) RemoveBadgeRoleByID(guildID string, id uint) error {
+	return b.removeBadgeRole(b.DB.Where("id = ? AND guild_id = ?", id, guildID))
+}
+
+func (b *BadgeService) removeBadgeRole(query *gorm.DB) error {
+	var role models.BadgeRole
+	if err := query.Preload("Badge").First(&role).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return errors.New("role is not mapped to this badge")
+		}
+		log.Println("Error getting badge role:", err)
+		return err
+	}
+
+	if err := b.DB.Delete(&role).Error; err != nil {
+		log.Println("Error deleting badge role:", err)
+		return err
+	}
+
+	if role.Badge != nil {
+		b.publishRolesUpdated(role.Badge.Name, role.GuildID, role.RoleID)
+	}
+	return nil
+}
+
+/* Get every role of a guild that is managed by a badge mapping */
+func (b *BadgeService) GetManagedRoleIDs(guildID string) ([]string, error) {
+	var roleIDs []string
+	if err := b.DB.Model(&models.BadgeRole{}).Where("guild_id = ?", guildID).Distinct("role_id").Pluck("role_id", &roleIDs).Error; err != nil {
+		log.Println("Error getting managed roles:", err)
+		return nil, err
+	}
+
+	return roleIDs, nil
+}
+
+/* Get the roles of a guild a user should hold based on their badges */
+func (b *BadgeService) GetUserRoleIDs(uid uint, guildID string) ([]string, error) {
+	var roleIDs []string
+	err := b.DB.Model(&models.BadgeRole{}).
+		Distinct("badge_roles.role_id").
+		Joins("JOIN user_badges ON user_badges.badge_id = badge_roles.badge_id").
+		Where("user_badges.uid = ? AND badge_roles.guild_id = ?", uid, guildID).
+		Pluck("badge_roles.role_id", &roleIDs).Error
+	if err != nil {
+		log.Println("Error getting user roles:", err)
+		return nil, err
+	}
+
+	return roleIDs, nil
+}
+
+func (b *BadgeService) publishBadgeEvent(eventType models.EventType, uid uint, badge *models.Badge) {
+	if b.EventService == nil {
+		return
+	}
+
+	data := models.BadgeEventData{
+		UID:       uid,
+		BadgeID:   badge.ID,
+		BadgeName: badge.Name,
+	}
+
+	if _, err := b.EventService.Publish(eventType, data); err != nil {
+		log.Printf("Failed to publish %s event: %v", eventType, err)
+	}
+}
+
+func (b *BadgeService) publishRolesUpdated(badgeName, guildID, revokedRoleID string) {
+	if b.EventService == nil {
+		return
+	}
+
+	data := models.BadgeRolesUpdatedData{
+		BadgeName:     badgeName,
+		GuildID:       guildID,
+		RevokedRoleID: revokedRoleID,
+	}
+
+	if _, err := b.EventService.Publish(models.EventBadgeRolesUpdated, data); err != nil {
+		log.Printf("Failed to publish badge roles updated event: %v", err)
+	}
+}
+
+/* Get the Discord IDs of linked users holding a badge */
+func (b *BadgeService) GetBadgeHolderDiscordIDs(badgeName string) ([]string, error) {
+	var discordIDs []string
+	err := b.DB.Model(&models.UserBadge{}).
+		Joins("JOIN badges ON badges.id = user_badges.badge_id").
+		Joins("JOIN users ON users.uid = user_badges.uid").
+		Where("badges.name = ? AND users.discord_id IS NOT NULL AND users.discord_id <> ''", badgeName).
+		Pluck("users.discord_id", &discordIDs).Error
+	if err != nil {
+		log.Println("Error getting badge holders:", err)
+		return nil, err
+	}
+
+	return discordIDs, nil
+}
+
+/* Remove every time-limited badge whose grant has run out */
+func (b *BadgeService) ExpireBadges() (int, error) {
+	var expired []models.UserBadge
+	if err := b.DB.Where("expires_at IS NOT NULL AND expires_at <= ?", time.Now()).Preload("Badge").Find(&expired).Error; err != nil {
+		log.Println("Error finding expired badges:", err)
+		return 0, err
+	}
+
+	removed := 0
+	for _, userBadge := range expired {
+		if err := b.DB.Delete(&models.UserBadge{}, userBadge.ID).Error; err != nil {
+			log.Printf("Error removing expired badge %d from user %d: %v", userBadge.BadgeID, userBadge.UID, err)
+			continue
+		}
+
+		b.publishBadgeEvent(models.EventBadgeRemoved, userBadge.UID, &userBadge.Badge)
+		removed++
+	}
+
+	return removed, nil
+}
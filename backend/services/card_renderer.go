@@ -0,0 +1,516 @@
+package services
+
+import (
+	"bytes"
+	"encoding/base64"
+	"fmt"
+	"html"
+	"image"
+	"image/color"
+	"image/draw"
+	"image/jpeg"
+	"image/png"
+	"math"
+	"strconv"
+	"strings"
+
+	"github.com/golang/freetype/truetype"
+	xdraw "golang.org/x/image/draw"
+	"golang.org/x/image/font"
+	"golang.org/x/image/math/fixed"
+)
+
+const (
+	cardWidth  = 1200
+	cardHeight = 630
+
+	CardLayoutClassic = "classic"
+	CardLayoutSplit   = "split"
+	CardLayoutBanner  = "banner"
+
+	CardFormatPNG = "png"
+	CardFormatSVG = "svg"
+
+	cardMaxBadges = 10
+)
+
+var cardLayouts = map[string]bool{CardLayoutClassic: true, CardLayoutSplit: true, CardLayoutBanner: true}
+
+// cardSpec is everything a card is rendered from, its hash is the cache key
+// of the card
+type cardSpec struct {
+	Layout        string   `json:"layout"`
+	Title         string   `json:"title"`
+	Subtitle      string   `json:"subtitle"`
+	Description   string   `json:"description"`
+	AvatarURL     string   `json:"avatar_url"`
+	AvatarShape   string   `json:"avatar_shape"`
+	BackgroundURL string   `json:"background_url"`
+	Background    string   `json:"background_color"`
+	GradientFrom  string   `json:"gradient_from_color"`
+	GradientTo    string   `json:"gradient_to_color"`
+	AccentColor   string   `json:"accent_color"`
+	TextColor     string   `json:"text_color"`
+	Font          string   `json:"text_font"`
+	BadgeURLs     []string `json:"badge_urls"`
+}
+
+// cardImage is an image placed on the card, avatars are clipped to a rounded
+// rectangle and badges are fit whole
+type cardImage struct {
+	URL    string
+	Rect   image.Rectangle
+	Radius int
+}
+
+type cardText struct {
+	Text   string
+	X, Y   int // Baseline, X is the center for centered text
+	Size   float64
+	Bold   bool
+	Center bool
+	Color  color.NRGBA
+}
+
+// cardRasters are the images of a scene scaled to their place, nil where an
+// image could not be loaded
+type cardRasters struct {
+	Background image.Image
+	Avatar     image.Image
+	Badges     []image.Image
+}
+
+// cardScene is a laid out card, rendered to PNG or SVG
+type cardScene struct {
+	Background      color.RGBA
+	GradientFrom    *color.RGBA
+	GradientTo      *color.RGBA
+	BackgroundImage string
+	// Darkens the background so text stays readable
+	Shade uint8
+
+	Accent     color.RGBA
+	AccentRect image.Rectangle
+
+	Avatar *cardImage
+	Badges []cardImage
+	Texts  []cardText
+
+	Font *cardFont
+}
+
+/* Parse #rgb or #rrggbb, anything else is the fallback */
+func parseHexColor(value string, fallback color.RGBA) color.RGBA {
+	value = strings.TrimPrefix(strings.TrimSpace(value), "#")
+	if len(value) == 3 {
+		value = string([]byte{value[0], value[0], value[1], value[1], value[2], value[2]})
+	}
+	if len(value) != 6 {
+		return fallback
+	}
+
+	rgb, err := strconv.ParseUint(value, 16, 32)
+	if err != nil {
+		return fallback
+	}
+
+	return color.RGBA{uint8(rgb >> 16), uint8(rgb >> 8), uint8(rgb), 255}
+}
+
+func hexColor(c color.Color) string {
+	n := color.NRGBAModel.Convert(c).(color.NRGBA)
+	return fmt.Sprintf("#%02x%02x%02x", n.R, n.G, n.B)
+}
+
+/* Corner radius of an avatar of the given size from the profile's avatar shape, rounded-2xl by default */
+func avatarRadius(shape string, size int) int {
+	switch shape {
+	case "rounded-full":
+		return size / 2
+	case "rounded-none":
+		return 0
+	}
+
+	if value, err := strconv.Atoi(strings.TrimPrefix(shape, "rounded-")); err == nil {
+		return int(math.Min(float64(value*4), float64(size/2)))
+	}
+
+	return size / 12
+}
+
+/* Cut text to fit maxWidth, ending it with an ellipsis when cut */
+func fitText(f *truetype.Font, size float64, text string, maxWidth int) string {
+	face := truetype.NewFace(f, &truetype.Options{Size: size, DPI: 96})
+	if font.MeasureString(face, text).Ceil() <= maxWidth {
+		return text
+	}
+
+	runes := []rune(text)
+	for len(runes) > 0 {
+		runes = runes[:len(runes)-1]
+		candidate := strings.TrimSpace(string(runes)) + "..."
+		if font.MeasureString(face, candidate).Ceil() <= maxWidth {
+			return candidate
+		}
+	}
+
+	return ""
+}
+
+/* Word wrap text into at most maxLines lines of maxWidth, the last line is cut if the text doesn't fit */
+func wrapText(f *truetype.Font, size float64, text string, maxWidth int, maxLines int) []string {
+	face := truetype.NewFace(f, &truetype.Options{Size: size, DPI: 96})
+
+	var lines []string
+	words := strings.Fields(text)
+	for len(words) > 0 && len(lines) < maxLines {
+		line := words[0]
+		words = words[1:]
+		for len(words) > 0 && font.MeasureString(face, line+" "+words[0]).Ceil() <= maxWidth {
+			line += " " + words[0]
+			words = words[1:]
+		}
+
+		if len(lines) == maxLines-1 && len(words) > 0 {
+			line = fitText(f, size, line+" "+strings.Join(words, " "), maxWidth)
+		}
+		lines = append(lines, fitText(f, size, line, maxWidth))
+	}
+
+	return lines
+}
+
+/* Lay out a card, fonts are needed to fit the text */
+func layoutCard(spec *cardSpec, fonts *cardFont) *cardScene {
+	text := parseHexColor(spec.TextColor, color.RGBA{255, 255, 255, 255})
+	textColor := color.NRGBA{text.R, text.G, text.B, 255}
+	mutedColor := color.NRGBA{text.R, text.G, text.B, 190}
+
+	scene := &cardScene{
+		Background:      parseHexColor(spec.Background, color.RGBA{40, 40, 40, 255}),
+		BackgroundImage: spec.BackgroundURL,
+		Shade:           120,
+		Accent:          parseHexColor(spec.AccentColor, color.RGBA{180, 180, 180, 255}),
+		Font:            fonts,
+	}
+
+	accent := color.NRGBA{scene.Accent.R, scene.Accent.G, scene.Accent.B, 255}
+
+	if spec.GradientFrom != "" && spec.GradientTo != "" {
+		from := parseHexColor(spec.GradientFrom, scene.Background)
+		to := parseHexColor(spec.GradientTo, scene.Background)
+		scene.GradientFrom, scene.GradientTo = &from, &to
+	}
+
+	if spec.BackgroundURL == "" {
+		scene.Shade = 40
+	}
+
+	avatar := func(x, y, size int) {
+		if spec.AvatarURL == "" {
+			return
+		}
+		scene.Avatar = &cardImage{
+			URL:    spec.AvatarURL,
+			Rect:   image.Rect(x, y, x+size, y+size),
+			Radius: avatarRadius(spec.AvatarShape, size),
+		}
+	}
+
+	badges := func(x, y, size, gap int, centered bool, alignRight bool) {
+		urls := spec.BadgeURLs
+		if len(urls) > cardMaxBadges {
+			urls = urls[:cardMaxBadges]
+		}
+
+		width := len(urls)*(size+gap) - gap
+		switch {
+		case centered:
+			x -= width / 2
+		case alignRight:
+			x -= width
+		}
+
+		for i, url := range urls {
+			left := x + i*(size+gap)
+			scene.Badges = append(scene.Badges, cardImage{
+				URL:  url,
+				Rect: image.Rect(left, y, left+size, y+size),
+			})
+		}
+	}
+
+	addText := func(value string, x, y int, size float64, bold bool, center bool, maxWidth int, c color.NRGBA) {
+		f := fonts.regular
+		if bold {
+			f = fonts.bold
+		}
+		value = fitText(f, size, value, maxWidth)
+		if value == "" {
+			return
+		}
+		scene.Texts = append(scene.Texts, cardText{Text: value, X: x, Y: y, Size: size, Bold: bold, Center: center, Color: c})
+	}
+
+	switch spec.Layout {
+	case CardLayoutSplit:
+		avatar(90, 165, 300)
+		column := 450
+		scene.AccentRect = image.Rect(column, 175, column+96, 183)
+		addText(spec.Title, column, 265, 68, true, false, 680, textColor)
+		addText(spec.Subtitle, column, 322, 32, false, false, 680, accent)
+		for i, line := range wrapText(fonts.regular, 26, spec.Description, 680, 2) {
+			addText(line, column, 385+i*40, 26, false, false, 680, mutedColor)
+		}
+		badges(column, 470, 44, 12, false, false)
+
+	case CardLayoutBanner:
+		scene.AccentRect = image.Rect(0, cardHeight-12, cardWidth, cardHeight)
+		addText(spec.Title, cardWidth/2, 290, 96, true, true, 1080, textColor)
+		for i, line := range wrapText(fonts.regular, 30, spec.Description, 960, 1) {
+			addText(line, cardWidth/2, 360+i*44, 30, false, true, 960, mutedColor)
+		}
+		avatar(60, 486, 84)
+		subtitleX := 60
+		if scene.Avatar != nil {
+			subtitleX = 168
+		}
+		addText(spec.Subtitle, subtitleX, 540, 32, false, false, 560, accent)
+		badges(cardWidth-60, 506, 44, 12, false, true)
+
+	default:
+		avatar(cardWidth/2-140, 60, 280)
+		titleY := 420
+		if scene.Avatar == nil {
+			titleY = 300
+		}
+		addText(spec.Title, cardWidth/2, titleY, 64, true, true, 1080, textColor)
+		addText(spec.Subtitle, cardWidth/2, titleY+62, 34, false, true, 1080, accent)
+		badges(cardWidth/2, titleY+98, 40, 12, true, false)
+	}
+
+	return scene
+}
+
+/* Fill dst with a diagonal gradient from the top left to the bottom right */
+func fillGradient(dst *image.RGBA, from color.RGBA, to color.RGBA) {
+	bounds := dst.Bounds()
+	w, h := bounds.Dx(), bounds.Dy()
+	span := float64(w + h - 2)
+
+	for y := 0; y < h; y++ {
+		row := dst.Pix[y*dst.Stride:]
+		for x := 0; x < w; x++ {
+			t := float64(x+y) / span
+			i := x * 4
+			row[i] = uint8(float64(from.R) + t*(float64(to.R)-float64(from.R)))
+			row[i+1] = uint8(float64(from.G) + t*(float64(to.G)-float64(from.G)))
+			row[i+2] = uint8(float64(from.B) + t*(float64(to.B)-float64(from.B)))
+			row[i+3] = 255
+		}
+	}
+}
+
+/* Scale src to size, covering it and cropping the overflow or fitting it whole when contain is set */
+func scaleImage(src image.Image, width int, height int, contain bool) *image.RGBA {
+	dst := image.NewRGBA(image.Rect(0, 0, width, height))
+	bounds := src.Bounds()
+	if bounds.Empty() {
+		return dst
+	}
+
+	scaleX := float64(width) / float64(bounds.Dx())
+	scaleY := float64(height) / float64(bounds.Dy())
+	scale := math.Max(scaleX, scaleY)
+	if contain {
+		scale = math.Min(scaleX, scaleY)
+	}
+
+	scaledWidth := int(math.Round(float64(bounds.Dx()) * scale))
+	scaledHeight := int(math.Round(float64(bounds.Dy()) * scale))
+	offsetX := (width - scaledWidth) / 2
+	offsetY := (height - scaledHeight) / 2
+
+	target := image.Rect(offsetX, offsetY, offsetX+scaledWidth, offsetY+scaledHeight)
+	xdraw.CatmullRom.Scale(dst, target, src, bounds, xdraw.Over, nil)
+	return dst
+}
+
+/* Antialiased mask of a rounded rectangle */
+func roundedMask(width int, height int, radius int) *image.Alpha {
+	mask := image.NewAlpha(image.Rect(0, 0, width, height))
+	r := float64(radius)
+
+	for y := 0; y < height; y++ {
+		for x := 0; x < width; x++ {
+			px, py := float64(x)+0.5, float64(y)+0.5
+
+			// Distance into the corner circle, pixels outside the corners are fully covered
+			cx := math.Max(r-px, px-(float64(width)-r))
+			cy := math.Max(r-py, py-(float64(height)-r))
+			coverage := 1.0
+			if cx > 0 && cy > 0 {
+				coverage = math.Max(0, math.Min(1, r-math.Hypot(cx, cy)+0.5))
+			}
+
+			mask.Pix[y*mask.Stride+x] = uint8(coverage * 255)
+		}
+	}
+
+	return mask
+}
+
+func renderCardPNG(scene *cardScene, rasters *cardRasters) ([]byte, error) {
+	img := image.NewRGBA(image.Rect(0, 0, cardWidth, cardHeight))
+
+	if scene.GradientFrom != nil {
+		fillGradient(img, *scene.GradientFrom, *scene.GradientTo)
+	} else {
+		draw.Draw(img, img.Bounds(), image.NewUniform(scene.Background), image.Point{}, draw.Src)
+	}
+
+	if rasters.Background != nil {
+		draw.Draw(img, img.Bounds(), rasters.Background, image.Point{}, draw.Over)
+	}
+
+	draw.Draw(img, img.Bounds(), image.NewUniform(color.RGBA{0, 0, 0, scene.Shade}), image.Point{}, draw.Over)
+
+	if !scene.AccentRect.Empty() {
+		draw.Draw(img, scene.AccentRect, image.NewUniform(scene.Accent), image.Point{}, draw.Over)
+	}
+
+	if scene.Avatar != nil && rasters.Avatar != nil {
+		rect := scene.Avatar.Rect
+		mask := roundedMask(rect.Dx(), rect.Dy(), scene.Avatar.Radius)
+		draw.DrawMask(img, rect, rasters.Avatar, image.Point{}, mask, image.Point{}, draw.Over)
+	}
+
+	for i, badge := range scene.Badges {
+		if rasters.Badges[i] != nil {
+			draw.Draw(img, badge.Rect, rasters.Badges[i], image.Point{}, draw.Over)
+		}
+	}
+
+	shadow := image.NewUniform(color.RGBA{0, 0, 0, 150})
+	for _, t := range scene.Texts {
+		f := scene.Font.regular
+		if t.Bold {
+			f = scene.Font.bold
+		}
+		face := truetype.NewFace(f, &truetype.Options{Size: t.Size, DPI: 96, Hinting: font.HintingFull})
+
+		x := t.X
+		if t.Center {
+			x -= font.MeasureString(face, t.Text).Ceil() / 2
+		}
+
+		(&font.Drawer{Dst: img, Src: shadow, Face: face, Dot: fixed.P(x+2, t.Y+2)}).DrawString(t.Text)
+		(&font.Drawer{Dst: img, Src: image.NewUniform(t.Color), Face: face, Dot: fixed.P(x, t.Y)}).DrawString(t.Text)
+	}
+
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, img); err != nil {
+		return nil, fmt.Errorf("failed to encode card: %w", err)
+	}
+
+	return buf.Bytes(), nil
+}
+
+/* Data URI of a raster, photos are embedded as JPEG and everything with transparency as PNG */
+func imageDataURI(img image.Image, opaque bool) (string, error) {
+	var buf bytes.Buffer
+	mime := "image/png"
+
+	if opaque {
+		mime = "image/jpeg"
+		if err := jpeg.Encode(&buf, img, &jpeg.Options{Quality: 85}); err != nil {
+			return "", err
+		}
+	} else if err := png.Encode(&buf, img); err != nil {
+		return "", err
+	}
+
+	return "data:" + mime + ";base64," + base64.StdEncoding.EncodeToString(buf.Bytes()), nil
+}
+
+/*
+Render a scene to SVG. Text stays vector and names the profile's font, the
+rasters are embedded so the document renders the same wherever it is used.
+*/
+func renderCardSVG(scene *cardScene, rasters *cardRasters) ([]byte, error) {
+	var svg strings.Builder
+
+	fmt.Fprintf(&svg, `<svg xmlns="http://www.w3.org/2000/svg" width="%d" height="%d" viewBox="0 0 %d %d">`, cardWidth, cardHeight, cardWidth, cardHeight)
+	svg.WriteString("<defs>")
+	if scene.GradientFrom != nil {
+		fmt.Fprintf(&svg, `<linearGradient id="background" x1="0" y1="0" x2="1" y2="1"><stop offset="0" stop-color="%s"/><stop offset="1" stop-color="%s"/></linearGradient>`,
+			hexColor(*scene.GradientFrom), hexColor(*scene.GradientTo))
+	}
+	svg.WriteString(`<filter id="shadow"><feDropShadow dx="2" dy="2" stdDeviation="0" flood-color="#000" flood-opacity="0.6"/></filter>`)
+	if scene.Avatar != nil {
+		rect := scene.Avatar.Rect
+		fmt.Fprintf(&svg, `<clipPath id="avatar"><rect x="%d" y="%d" width="%d" height="%d" rx="%d"/></clipPath>`,
+			rect.Min.X, rect.Min.Y, rect.Dx(), rect.Dy(), scene.Avatar.Radius)
+	}
+	svg.WriteString("</defs>")
+
+	fill := hexColor(scene.Background)
+	if scene.GradientFrom != nil {
+		fill = "url(#background)"
+	}
+	fmt.Fprintf(&svg, `<rect width="%d" height="%d" fill="%s"/>`, cardWidth, cardHeight, fill)
+
+	writeImage := func(img image.Image, rect image.Rectangle, opaque bool, attrs string) error {
+		uri, err := imageDataURI(img, opaque)
+		if err != nil {
+			return err
+		}
+		fmt.Fprintf(&svg, `<image x="%d" y="%d" width="%d" height="%d" href="%s"%s/>`, rect.Min.X, rect.Min.Y, rect.Dx(), rect.Dy(), uri, attrs)
+		return nil
+	}
+
+	if rasters.Background != nil {
+		if err := writeImage(rasters.Background, image.Rect(0, 0, cardWidth, cardHeight), true, ""); err != nil {
+			return nil, err
+		}
+	}
+
+	fmt.Fprintf(&svg, `<rect width="%d" height="%d" fill="#000" fill-opacity="%.3f"/>`, cardWidth, cardHeight, float64(scene.Shade)/255)
+
+	if !scene.AccentRect.Empty() {
+		rect := scene.AccentRect
+		fmt.Fprintf(&svg, `<rect x="%d" y="%d" width="%d" height="%d" fill="%s"/>`, rect.Min.X, rect.Min.Y, rect.Dx(), rect.Dy(), hexColor(scene.Accent))
+	}
+
+	if scene.Avatar != nil && rasters.Avatar != nil {
+		if err := writeImage(rasters.Avatar, scene.Avatar.Rect, false, ` clip-path="url(#avatar)"`); err != nil {
+			return nil, err
+		}
+	}
+
+	for i, badge := range scene.Badges {
+		if rasters.Badges[i] != nil {
+			if err := writeImage(rasters.Badges[i], badge.Rect, false, ""); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	family := html.EscapeString(fmt.Sprintf("'%s', 'Poppins', sans-serif", scene.Font.Family))
+	for _, t := range scene.Texts {
+		anchor := "start"
+		if t.Center {
+			anchor = "middle"
+		}
+		weight := "normal"
+		if t.Bold {
+			weight = "bold"
+		}
+
+		fmt.Fprintf(&svg, `<text x="%d" y="%d" font-family="%s" font-size="%.0fpx" font-weight="%s" text-anchor="%s" fill="%s" fill-opacity="%.3f" filter="url(#shadow)">%s</text>`,
+			t.X, t.Y, family, t.Size*96/72, weight, anchor, hexColor(t.Color), float64(t.Color.A)/255, html.EscapeString(t.Text))
+	}
+
+	svg.WriteString("</svg>")
+	return []byte(svg.String()), nil
+}
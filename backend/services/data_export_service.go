@@ -3,6 +3,7 @@ package services
 import (
 	"bytes"
 	"crypto/rand"
+	"crypto/subtle"
 	"errors"
 	"fmt"
 	"log"
@@ -28,6 +29,8 @@ const (
 	DataExportCachePrefix   = "data_export:"
 	DataExportRequestPeriod = time.Minute    // 7 days between export requests
 	DataExportExpiration    = 24 * time.Hour // Export links expire after 24 hours
+
+	exportDownloadURLTTL = 10 * time.Minute // Signed download links are single use and short lived
 )
 
 func NewDataExportService(db *gorm.DB, client *redis.Client) *DataExportService {
@@ -456,7 +459,7 @@ func (des *DataExportService) ValidateExportDownload(exportID uint, password str
 		return nil, errors.New("the export link has expired")
 	}
 
-	if export.FilePassword != password {
+	if subtle.ConstantTimeCompare([]byte(export.FilePassword), []byte(password)) != 1 {
 		return nil, errors.New("invalid password")
 	}
 
@@ -468,14 +471,19 @@ func (des *DataExportService) ValidateExportDownload(exportID uint, password str
 		return nil, errors.New("this export has already been downloaded")
 	}
 
-	downloadURL := export.FileURL
+	// The file itself is password protected, hand out a short lived link instead
+	downloadURL, expiresAt, err := des.FileService.SignedFileURL(export.FileURL, exportDownloadURLTTL, true)
+	if err != nil {
+		log.Printf("Error signing export download URL: %v", err)
+		return nil, errors.New("failed to generate download link")
+	}
 
 	now := time.Now()
 	des.DB.Model(&export).Update("downloaded_at", now)
 
 	return &models.DataExportDownloadResponse{
 		DownloadURL: downloadURL,
-		ExpiresAt:   export.ExpiresAt,
+		ExpiresAt:   expiresAt,
 	}, nil
 }
 
@@ -2,6 +2,7 @@ package services
 
 import (
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"log"
@@ -15,22 +16,25 @@ import (
 	"github.com/go-redis/redis"
 	"github.com/hazebio/haze.bio_backend/config"
 	"github.com/hazebio/haze.bio_backend/models"
+	"github.com/hazebio/haze.bio_backend/utils"
 	"gorm.io/gorm"
 )
 
 type DiscordService struct {
-	DB          *gorm.DB
-	Client      *redis.Client
-	UserService *UserService
-	BotSession  *discordgo.Session
+	DB           *gorm.DB
+	Client       *redis.Client
+	UserService  *UserService
+	GuildService *GuildService
+	BotSession   *discordgo.Session
 }
 
 func NewDiscordService(db *gorm.DB, client *redis.Client, userService *UserService, botSession *discordgo.Session) *DiscordService {
 	return &DiscordService{
-		DB:          db,
-		Client:      client,
-		UserService: userService,
-		BotSession:  botSession,
+		DB:           db,
+		Client:       client,
+		UserService:  userService,
+		GuildService: NewGuildService(db, client),
+		BotSession:   botSession,
 	}
 }
 
@@ -101,6 +105,8 @@ func (ds *DiscordService) LinkDiscordAccount(uid uint, discordID string) error {
 		return fmt.Errorf("failed to update user: %w", err)
 	}
 
+	ds.clearPresenceCache(user.UID, discordID)
+
 	// Get Discord username for the event
 	discordUsername := ""
 	if ds.BotSession != nil {
@@ -146,9 +152,26 @@ func (ds *DiscordService) UnlinkDiscordAccount(uid uint) error {
 		return fmt.Errorf("failed to update user: %w", err)
 	}
 
+	ds.clearPresenceCache(user.UID, user.DiscordID)
+	ds.publishUnlinked(user.UID, user.DiscordID)
 	return nil
 }
 
+func (ds *DiscordService) publishUnlinked(uid uint, discordID string) {
+	if ds.UserService.EventService == nil || discordID == "" {
+		return
+	}
+
+	eventData := models.DiscordUnlinkedData{
+		UID:       uid,
+		DiscordID: discordID,
+	}
+
+	if _, err := ds.UserService.EventService.Publish(models.EventDiscordUnlinked, eventData); err != nil {
+		log.Printf("Failed to publish discord unlinked event: %v", err)
+	}
+}
+
 /* Get user by Discord ID */
 func (ds *DiscordService) GetUserByDiscordID(discordID string) (*models.User, error) {
 	var user models.User
@@ -186,6 +209,8 @@ func (ds *DiscordService) DeleteDiscordID(uid uint) error {
 		return fmt.Errorf("failed to update user: %w", err)
 	}
 
+	ds.clearPresenceCache(user.UID, user.DiscordID)
+	ds.publishUnlinked(user.UID, user.DiscordID)
 	return nil
 }
 
@@ -380,39 +405,190 @@ func (ds *DiscordService) GetUserDecorationURL(UID uint) (string, error) {
 
 /* Discord Presence */
 func (ds *DiscordService) GetDiscordPresence(UID uint) (map[string]interface{}, error) {
-	log.Println("Getting presence for user:", UID)
+	snapshot, err := ds.GetPresenceSnapshot(UID)
+	if err != nil {
+		return nil, err
+	}
+
+	return legacyPresenceMap(snapshot), nil
+}
+
+const (
+	presenceChannelPrefix  = "presence:"
+	presenceSnapshotPrefix = "presence_snapshot:"
+	presenceSnapshotTTL    = 10 * time.Minute
+	presenceUIDPrefix      = "presence_uid:"
+	presenceUIDTTL         = 5 * time.Minute
+)
+
+func PresenceChannel(uid uint) string {
+	return fmt.Sprintf("%s%d", presenceChannelPrefix, uid)
+}
+
+/* Get the current typed Discord presence of a user */
+func (ds *DiscordService) GetPresenceSnapshot(UID uint) (*models.DiscordPresence, error) {
+	snapshotKey := fmt.Sprintf("%s%d", presenceSnapshotPrefix, UID)
+	if cached, err := ds.Client.Get(snapshotKey).Result(); err == nil {
+		var snapshot models.DiscordPresence
+		if json.Unmarshal([]byte(cached), &snapshot) == nil {
+			return &snapshot, nil
+		}
+	}
 
 	user, err := ds.UserService.GetUserByUID(UID)
 	if err != nil {
 		return nil, fmt.Errorf("failed to get user: %w", err)
 	}
 
-	member, err := ds.BotSession.GuildMember(config.DiscordGuildID, user.DiscordID)
+	if user.DiscordID == "" {
+		return nil, fmt.Errorf("user %d has no linked discord account", UID)
+	}
+
+	member, err := ds.GetGuildMember(user.DiscordID)
 	if err != nil {
-		return nil, fmt.Errorf("failed to get member from Discord API: %w", err)
+		return nil, err
 	}
 
-	presence, err := ds.BotSession.State.Presence(config.DiscordGuildID, user.DiscordID)
+	presence, err := ds.getPresence(user.DiscordID)
 	if err != nil {
-		log.Printf("Presence not found for user %s (possibly offline or uncached): %v\n", user.DiscordID, err)
 		presence = &discordgo.Presence{
 			User:   &discordgo.User{ID: user.DiscordID},
 			Status: discordgo.StatusOffline,
 		}
 	}
 
-	status := string(presence.Status)
-	activities := presence.Activities
-	activityText := "N/A"
-	state := "N/A"
-	details := "N/A"
-	coverImage := "N/A"
-	description := "N/A"
-	customEmoji := "N/A"
-	emojiID := "N/A"
-	emojiName := "N/A"
-	emojiAnimated := false
-	largeText := "N/A"
+	snapshot := ds.BuildPresence(UID, member, presence)
+	ds.cachePresence(snapshot)
+
+	return snapshot, nil
+}
+
+/* Publish a gateway presence update to every subscribed viewer */
+func (ds *DiscordService) PublishPresence(presence *discordgo.Presence) error {
+	if presence == nil || presence.User == nil {
+		return nil
+	}
+
+	uid, err := ds.getUIDByDiscordID(presence.User.ID)
+	if err != nil || uid == 0 {
+		return err
+	}
+
+	member, err := ds.GetGuildMember(presence.User.ID)
+	if err != nil {
+		return err
+	}
+
+	snapshot := ds.BuildPresence(uid, member, presence)
+	if _, err := ds.cachePresence(snapshot); err != nil {
+		return err
+	}
+
+	data, err := json.Marshal(models.PresenceMessage{Type: "update", Presence: snapshot})
+	if err != nil {
+		return err
+	}
+
+	return ds.Client.Publish(PresenceChannel(uid), data).Err()
+}
+
+func (ds *DiscordService) cachePresence(snapshot *models.DiscordPresence) ([]byte, error) {
+	data, err := json.Marshal(snapshot)
+	if err != nil {
+		return nil, err
+	}
+
+	ds.Client.Set(fmt.Sprintf("%s%d", presenceSnapshotPrefix, snapshot.UID), data, presenceSnapshotTTL)
+	return data, nil
+}
+
+/* Resolve the user of a Discord ID, caching misses so unlinked members do not hit the database */
+func (ds *DiscordService) getUIDByDiscordID(discordID string) (uint, error) {
+	key := presenceUIDPrefix + discordID
+	if cached, err := ds.Client.Get(key).Result(); err == nil {
+		return utils.StringToUint(cached), nil
+	}
+
+	var uid uint
+	if err := ds.DB.Model(&models.User{}).Select("uid").Where("discord_id = ?", discordID).Scan(&uid).Error; err != nil {
+		return 0, err
+	}
+
+	ds.Client.Set(key, uid, presenceUIDTTL)
+	return uid, nil
+}
+
+func (ds *DiscordService) clearPresenceCache(uid uint, discordID string) {
+	ds.Client.Del(fmt.Sprintf("%s%d", presenceSnapshotPrefix, uid), presenceUIDPrefix+discordID)
+}
+
+func (ds *DiscordService) guildIDs() []string {
+	if ds.GuildService == nil {
+		return []string{config.DiscordGuildID}
+	}
+	return ds.GuildService.GuildIDs()
+}
+
+/* Get a member from the first configured guild they are in, primary first */
+func (ds *DiscordService) GetGuildMember(discordID string) (*discordgo.Member, error) {
+	guildIDs := ds.guildIDs()
+	for _, guildID := range guildIDs {
+		if member, err := ds.BotSession.State.Member(guildID, discordID); err == nil {
+			return member, nil
+		}
+	}
+
+	err := errors.New("no discord guild configured")
+	for _, guildID := range guildIDs {
+		var member *discordgo.Member
+		member, err = ds.BotSession.GuildMember(guildID, discordID)
+		if err == nil {
+			return member, nil
+		}
+	}
+
+	return nil, fmt.Errorf("failed to get member from Discord API: %w", err)
+}
+
+func (ds *DiscordService) getPresence(discordID string) (*discordgo.Presence, error) {
+	for _, guildID := range ds.guildIDs() {
+		if presence, err := ds.BotSession.State.Presence(guildID, discordID); err == nil {
+			return presence, nil
+		}
+	}
+
+	return nil, errors.New("presence not found")
+}
+
+var activityTypeNames = map[discordgo.ActivityType]string{
+	discordgo.ActivityTypeGame:      "playing",
+	discordgo.ActivityTypeStreaming: "streaming",
+	discordgo.ActivityTypeListening: "listening",
+	discordgo.ActivityTypeWatching:  "watching",
+	discordgo.ActivityTypeCompeting: "competing",
+}
+
+/* Build a typed presence from a guild member and their gateway presence */
+func (ds *DiscordService) BuildPresence(UID uint, member *discordgo.Member, presence *discordgo.Presence) *models.DiscordPresence {
+	snapshot := &models.DiscordPresence{
+		UID:       UID,
+		Status:    string(discordgo.StatusOffline),
+		UpdatedAt: time.Now(),
+	}
+
+	if member != nil && member.User != nil {
+		snapshot.Username = member.User.Username
+		snapshot.Avatar = member.AvatarURL("512")
+		snapshot.Badges, _ = ds.GetUserBadges(member)
+	}
+
+	if presence == nil {
+		return snapshot
+	}
+
+	if presence.Status != "" {
+		snapshot.Status = string(presence.Status)
+	}
 
 	priority := map[discordgo.ActivityType]int{
 		discordgo.ActivityTypeStreaming: 0,
@@ -422,98 +598,138 @@ func (ds *DiscordService) GetDiscordPresence(UID uint) (map[string]interface{},
 		discordgo.ActivityTypeCustom:    4,
 	}
 
+	activities := make([]*discordgo.Activity, len(presence.Activities))
+	copy(activities, presence.Activities)
 	sort.SliceStable(activities, func(i, j int) bool {
 		return priority[activities[i].Type] < priority[activities[j].Type]
 	})
 
-	prettyJson, _ := json.MarshalIndent(presence, "", "    ")
-	log.Println("Presence JSON:", string(prettyJson))
-
 	for _, act := range activities {
 		if act.Type == discordgo.ActivityTypeCustom {
-			if act.State != "" {
-				description = act.State
+			if snapshot.CustomStatus == nil {
+				snapshot.CustomStatus = buildCustomStatus(act)
 			}
+			continue
+		}
 
-			if act.Emoji.Name != "" {
-				emojiName = act.Emoji.Name
-				customEmoji = act.Emoji.Name
-			}
+		if snapshot.Activity == nil {
+			snapshot.Activity = buildActivity(act)
+		}
+	}
 
-			if act.Emoji.ID != "" {
-				emojiID = act.Emoji.ID
-				emojiAnimated = act.Emoji.Animated
+	return snapshot
+}
 
-				if emojiAnimated {
-					customEmoji = fmt.Sprintf("https://cdn.discordapp.com/emojis/%s.gif", emojiID)
-				} else {
-					customEmoji = fmt.Sprintf("https://cdn.discordapp.com/emojis/%s.png", emojiID)
-				}
-			}
-			continue
-		}
+func buildCustomStatus(act *discordgo.Activity) *models.PresenceCustomStatus {
+	status := &models.PresenceCustomStatus{Text: act.State}
 
-		state = act.State
-		details = act.Details
-
-		switch act.Type {
-		case discordgo.ActivityTypeGame, discordgo.ActivityTypeWatching:
-			activityText = act.Name
-		case discordgo.ActivityTypeStreaming:
-			activityText = fmt.Sprintf("Streaming %s", act.Name)
-		case discordgo.ActivityTypeListening:
-			if strings.HasPrefix(act.Assets.LargeImageID, "spotify:") {
-				activityText = fmt.Sprintf("Listening to %s by %s", act.Details, act.State)
-				coverImage = fmt.Sprintf("https://i.scdn.co/image/%s", strings.TrimPrefix(act.Assets.LargeImageID, "spotify:"))
-			} else {
-				activityText = fmt.Sprintf("Listening to %s", act.Name)
-			}
+	if act.Emoji.Name != "" || act.Emoji.ID != "" {
+		emoji := &models.PresenceEmoji{
+			ID:       act.Emoji.ID,
+			Name:     act.Emoji.Name,
+			Animated: act.Emoji.Animated,
 		}
-		asset := act.Assets
-		if asset.LargeImageID != "" {
-			if strings.HasPrefix(asset.LargeImageID, "mp:external/") {
-				externalID := strings.TrimPrefix(asset.LargeImageID, "mp:external/")
-				coverImage = fmt.Sprintf("https://media.discordapp.net/external/%s", externalID)
-			} else {
-				appID := act.ApplicationID
-				coverImage = fmt.Sprintf("https://cdn.discordapp.com/app-assets/%s/%s.png", appID, asset.LargeImageID)
-			}
 
-			if asset.LargeText != "" {
-				largeText = asset.LargeText
+		if emoji.ID != "" {
+			extension := "png"
+			if emoji.Animated {
+				extension = "gif"
 			}
+			emoji.URL = fmt.Sprintf("https://cdn.discordapp.com/emojis/%s.%s", emoji.ID, extension)
 		}
 
-		if act.Assets.LargeImageID != "" && act.Assets.SmallImageID != "" {
-			largeText = act.Assets.LargeText
+		status.Emoji = emoji
+	}
+
+	if status.Text == "" && status.Emoji == nil {
+		return nil
+	}
+	return status
+}
+
+func buildActivity(act *discordgo.Activity) *models.PresenceActivity {
+	activity := &models.PresenceActivity{
+		Type:    activityTypeNames[act.Type],
+		Name:    act.Name,
+		Text:    act.Name,
+		State:   act.State,
+		Details: act.Details,
+	}
+
+	switch act.Type {
+	case discordgo.ActivityTypeStreaming:
+		activity.Text = fmt.Sprintf("Streaming %s", act.Name)
+	case discordgo.ActivityTypeListening:
+		if strings.HasPrefix(act.Assets.LargeImageID, "spotify:") {
+			activity.Text = fmt.Sprintf("Listening to %s by %s", act.Details, act.State)
+		} else {
+			activity.Text = fmt.Sprintf("Listening to %s", act.Name)
 		}
-		break
 	}
 
-	if activityText == "N/A" && description != "N/A" {
-		activityText = description
+	asset := act.Assets
+	switch {
+	case strings.HasPrefix(asset.LargeImageID, "spotify:"):
+		activity.CoverImage = fmt.Sprintf("https://i.scdn.co/image/%s", strings.TrimPrefix(asset.LargeImageID, "spotify:"))
+	case strings.HasPrefix(asset.LargeImageID, "mp:external/"):
+		activity.CoverImage = fmt.Sprintf("https://media.discordapp.net/external/%s", strings.TrimPrefix(asset.LargeImageID, "mp:external/"))
+	case asset.LargeImageID != "":
+		activity.CoverImage = fmt.Sprintf("https://cdn.discordapp.com/app-assets/%s/%s.png", act.ApplicationID, asset.LargeImageID)
 	}
 
-	badges, _ := ds.GetUserBadges(member)
+	activity.LargeText = asset.LargeText
+	return activity
+}
+
+/* Convert a typed presence into the flat format the profile page used to poll */
+func legacyPresenceMap(snapshot *models.DiscordPresence) map[string]interface{} {
+	orNA := func(value string) string {
+		if value == "" {
+			return "N/A"
+		}
+		return value
+	}
 
 	result := map[string]interface{}{
-		"avatar":         member.AvatarURL("512"),
-		"username":       member.User.Username,
-		"status":         status,
-		"description":    description,
-		"emoji":          customEmoji,
-		"emoji_id":       emojiID,
-		"emoji_name":     emojiName,
-		"emoji_animated": emojiAnimated,
-		"activity":       activityText,
-		"state":          state,
-		"details":        details,
-		"cover_image":    coverImage,
-		"badges":         badges,
-		"large_text":     largeText,
-	}
-
-	return result, nil
+		"avatar":         snapshot.Avatar,
+		"username":       snapshot.Username,
+		"status":         snapshot.Status,
+		"description":    "N/A",
+		"emoji":          "N/A",
+		"emoji_id":       "N/A",
+		"emoji_name":     "N/A",
+		"emoji_animated": false,
+		"activity":       "N/A",
+		"state":          "N/A",
+		"details":        "N/A",
+		"cover_image":    "N/A",
+		"badges":         snapshot.Badges,
+		"large_text":     "N/A",
+	}
+
+	if custom := snapshot.CustomStatus; custom != nil {
+		result["description"] = orNA(custom.Text)
+		result["activity"] = orNA(custom.Text)
+		if custom.Emoji != nil {
+			result["emoji"] = orNA(custom.Emoji.URL)
+			if custom.Emoji.URL == "" {
+				result["emoji"] = orNA(custom.Emoji.Name)
+			}
+			result["emoji_id"] = orNA(custom.Emoji.ID)
+			result["emoji_name"] = orNA(custom.Emoji.Name)
+			result["emoji_animated"] = custom.Emoji.Animated
+		}
+	}
+
+	if activity := snapshot.Activity; activity != nil {
+		result["activity"] = activity.Text
+		result["state"] = activity.State
+		result["details"] = activity.Details
+		result["cover_image"] = orNA(activity.CoverImage)
+		result["large_text"] = orNA(activity.LargeText)
+	}
+
+	return result
 }
 
 func (ds *DiscordService) GetUserBadges(member *discordgo.Member) ([]map[string]string, error) {
@@ -0,0 +1,766 @@
+package services
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/hex"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"log"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/go-redis/redis"
+	"github.com/hazebio/haze.bio_backend/config"
+	"github.com/hazebio/haze.bio_backend/models"
+	"github.com/hazebio/haze.bio_backend/utils"
+	"golang.org/x/crypto/acme"
+	"golang.org/x/net/idna"
+	"golang.org/x/sync/singleflight"
+	"gorm.io/gorm"
+)
+
+const (
+	CustomDomainCachePrefix = "custom_domain:"
+	customDomainCacheTTL    = 10 * time.Minute
+	// Hosts that aren't custom domains are remembered for less time so newly verified domains resolve quickly
+	customDomainMissTTL = time.Minute
+
+	domainVerificationPrefix = "_haze-verification."
+	domainVerificationValue  = "haze-verification="
+	// Claims that are never verified stop blocking the domain for other users
+	domainClaimExpiry       = 72 * time.Hour
+	domainVerifyCooldown    = 10 * time.Second
+	domainVerifyLookupLimit = 10 * time.Second
+
+	acmeChallengePrefix = "acme_challenge:"
+	acmeChallengeTTL    = 15 * time.Minute
+	acmeAccountKey      = "acme:account_key"
+	acmeOrderTimeout    = 5 * time.Minute
+
+	// Certificates are renewed once they expire within this window
+	CertificateRenewBefore = 30 * 24 * time.Hour
+	// Certificates loaded for handshakes are read again after this, so renewals are picked up
+	certificateCacheTTL = time.Hour
+	// Failed orders are retried after certificateRetryBase, doubling with every failure in a row
+	certificateRetryBase = 6 * time.Hour
+	certificateRetryMax  = 7 * 24 * time.Hour
+)
+
+/*
+DomainService lets users point a domain they own at their profile. Ownership
+is proven with a DNS TXT record, verified domains are mapped to their owner's
+UID and get a certificate ordered through ACME with HTTP-01 challenges.
+*/
+type DomainService struct {
+	DB          *gorm.DB
+	Client      *redis.Client
+	UserService *UserService
+	Resolver    *net.Resolver
+
+	acmeMu     sync.Mutex
+	acmeClient *acme.Client
+
+	certMu       sync.RWMutex
+	certificates map[string]*cachedCertificate
+
+	orders singleflight.Group
+}
+
+type cachedCertificate struct {
+	certificate *tls.Certificate
+	loadedAt    time.Time
+}
+
+func NewDomainService(db *gorm.DB, client *redis.Client) *DomainService {
+	return &DomainService{
+		DB:           db,
+		Client:       client,
+		UserService:  &UserService{DB: db, Client: client},
+		Resolver:     newDNSResolver(config.DNSResolver),
+		certificates: map[string]*cachedCertificate{},
+	}
+}
+
+/* Resolver that queries a single DNS server, the system resolver when no address is set */
+func newDNSResolver(address string) *net.Resolver {
+	if address == "" {
+		return net.DefaultResolver
+	}
+
+	return &net.Resolver{
+		PreferGo: true,
+		Dial: func(ctx context.Context, network string, _ string) (net.Conn, error) {
+			var dialer net.Dialer
+			return dialer.DialContext(ctx, network, address)
+		},
+	}
+}
+
+/* Whether custom domains are served over TLS, certificates are only ordered then */
+func CertificatesEnabled() bool {
+	return config.HttpsPort != 0
+}
+
+/* Hosts of our own that can never be claimed as custom domains */
+func ownHosts() []string {
+	hosts := []string{profileHost(), "localhost"}
+	for _, raw := range []string{config.Origin, config.APIPublicURL} {
+		if parsed, err := url.Parse(raw); err == nil && parsed.Hostname() != "" {
+			hosts = append(hosts, strings.ToLower(parsed.Hostname()))
+		}
+	}
+	if config.CustomDomainTarget != "" {
+		hosts = append(hosts, strings.ToLower(config.CustomDomainTarget))
+	}
+
+	return hosts
+}
+
+func isOwnHost(host string) bool {
+	for _, own := range ownHosts() {
+		if host == own || strings.HasSuffix(host, "."+own) {
+			return true
+		}
+	}
+	return false
+}
+
+/* Normalize a domain to lowercase ASCII, refusing IP addresses, single labels and our own hosts */
+func NormalizeDomain(domain string) (string, error) {
+	domain = strings.TrimSuffix(strings.ToLower(strings.TrimSpace(domain)), ".")
+
+	ascii, err := idna.Lookup.ToASCII(domain)
+	if err != nil || ascii == "" || len(ascii) > 253 || net.ParseIP(ascii) != nil {
+		return "", errors.New("invalid domain")
+	}
+
+	labels := strings.Split(ascii, ".")
+	if len(labels) < 2 {
+		return "", errors.New("invalid domain")
+	}
+
+	for _, label := range labels {
+		if len(label) == 0 || len(label) > 63 || label[0] == '-' || label[len(label)-1] == '-' {
+			return "", errors.New("invalid domain")
+		}
+		for _, c := range label {
+			if (c < 'a' || c > 'z') && (c < '0' || c > '9') && c != '-' {
+				return "", errors.New("invalid domain")
+			}
+		}
+	}
+
+	if isOwnHost(ascii) {
+		return "", errors.New("domain not allowed")
+	}
+
+	return ascii, nil
+}
+
+/* Attach the DNS records the user has to create to a domain */
+func withDomainInstructions(domain *models.CustomDomain) *models.CustomDomain {
+	domain.Verification = &models.DomainVerification{
+		Type:  "TXT",
+		Name:  domainVerificationPrefix + domain.Domain,
+		Value: domainVerificationValue + domain.VerificationToken,
+	}
+	domain.Target = config.CustomDomainTarget
+
+	return domain
+}
+
+/* Get the custom domain of a user */
+func (ds *DomainService) GetDomain(uid uint) (*models.CustomDomain, error) {
+	var domain models.CustomDomain
+	if err := ds.DB.Where("uid = ?", uid).First(&domain).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, errors.New("domain not found")
+		}
+		return nil, err
+	}
+
+	return withDomainInstructions(&domain), nil
+}
+
+/*
+Claim a domain for a user, replacing the domain they had. The domain doesn't
+resolve until it is verified, unverified claims by other users stop blocking
+it after a while.
+*/
+func (ds *DomainService) AddDomain(uid uint, rawDomain string) (*models.CustomDomain, error) {
+	user, err := ds.UserService.GetUserByUIDNoCache(uid)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get user: %w", err)
+	}
+
+	premiumFeatures := models.NewPremiumFeatures()
+	if premiumFeatures.IsCustomDomainPremium() && !user.HasActivePremiumSubscription() {
+		return nil, errors.New("custom domains require premium")
+	}
+
+	name, err := NormalizeDomain(rawDomain)
+	if err != nil {
+		return nil, err
+	}
+
+	var existing models.CustomDomain
+	err = ds.DB.Where("domain = ?", name).First(&existing).Error
+	if err == nil {
+		if existing.UID == uid {
+			return withDomainInstructions(&existing), nil
+		}
+		if existing.IsVerified() || time.Since(existing.CreatedAt) < domainClaimExpiry {
+			return nil, errors.New("domain already in use")
+		}
+	} else if !errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, err
+	}
+
+	token := make([]byte, 16)
+	if _, err := rand.Read(token); err != nil {
+		return nil, err
+	}
+
+	domain := &models.CustomDomain{
+		UID:               uid,
+		Domain:            name,
+		VerificationToken: hex.EncodeToString(token),
+		CertificateStatus: models.CertificateNone,
+	}
+
+	var previous []models.CustomDomain
+	err = ds.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("uid = ? OR domain = ?", uid, name).Find(&previous).Error; err != nil {
+			return err
+		}
+		if err := tx.Where("uid = ? OR domain = ?", uid, name).Delete(&models.CustomDomain{}).Error; err != nil {
+			return err
+		}
+		return tx.Create(domain).Error
+	})
+	if err != nil {
+		log.Println("Error adding custom domain:", err)
+		return nil, err
+	}
+
+	for _, replaced := range previous {
+		ds.forget(replaced.Domain)
+	}
+
+	return withDomainInstructions(domain), nil
+}
+
+/* Remove the custom domain of a user */
+func (ds *DomainService) RemoveDomain(uid uint) error {
+	domain, err := ds.GetDomain(uid)
+	if err != nil {
+		return err
+	}
+
+	if err := ds.DB.Delete(&models.CustomDomain{}, domain.ID).Error; err != nil {
+		return err
+	}
+
+	ds.forget(domain.Domain)
+	return nil
+}
+
+/* Drop a domain from the host and certificate caches */
+func (ds *DomainService) forget(domain string) {
+	ds.Client.Del(CustomDomainCachePrefix + domain)
+
+	ds.certMu.Lock()
+	delete(ds.certificates, domain)
+	ds.certMu.Unlock()
+}
+
+/*
+Look up the verification TXT record of a user's domain and mark the domain
+verified when it matches. The certificate for it is ordered in the background.
+*/
+func (ds *DomainService) VerifyDomain(uid uint) (*models.CustomDomain, error) {
+	domain, err := ds.GetDomain(uid)
+	if err != nil {
+		return nil, err
+	}
+
+	if domain.IsVerified() {
+		return domain, nil
+	}
+
+	if domain.LastCheckedAt != nil && time.Since(*domain.LastCheckedAt) < domainVerifyCooldown {
+		return nil, errors.New("verification checked too recently")
+	}
+
+	found, lookupErr := ds.lookupVerification(domain)
+
+	now := time.Now()
+	updates := map[string]interface{}{"last_checked_at": now}
+	domain.LastCheckedAt = &now
+
+	if lookupErr == nil && found {
+		updates["verified_at"] = now
+		domain.VerifiedAt = &now
+
+		if CertificatesEnabled() {
+			updates["certificate_status"] = models.CertificatePending
+			domain.CertificateStatus = models.CertificatePending
+		}
+	}
+
+	if err := ds.DB.Model(&models.CustomDomain{}).Where("id = ?", domain.ID).Updates(updates).Error; err != nil {
+		return nil, err
+	}
+
+	if lookupErr != nil {
+		log.Printf("Error looking up verification record of %s: %v", domain.Domain, lookupErr)
+		return nil, errors.New("dns lookup failed")
+	}
+	if !found {
+		return nil, errors.New("verification record not found")
+	}
+
+	// A lookup made before verification may have cached the domain as unknown
+	ds.forget(domain.Domain)
+	log.Printf("Verified custom domain %s for user %d", domain.Domain, uid)
+
+	if CertificatesEnabled() {
+		go ds.ProvisionCertificate(domain.ID)
+	}
+
+	return domain, nil
+}
+
+/* Whether the domain has a TXT record with its verification token, missing records aren't an error */
+func (ds *DomainService) lookupVerification(domain *models.CustomDomain) (bool, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), domainVerifyLookupLimit)
+	defer cancel()
+
+	records, err := ds.Resolver.LookupTXT(ctx, domainVerificationPrefix+domain.Domain)
+	if err != nil {
+		var dnsErr *net.DNSError
+		if errors.As(err, &dnsErr) && dnsErr.IsNotFound {
+			return false, nil
+		}
+		return false, err
+	}
+
+	expected := domainVerificationValue + domain.VerificationToken
+	for _, record := range records {
+		if strings.TrimSpace(record) == expected {
+			return true, nil
+		}
+	}
+
+	return false, nil
+}
+
+/* UID of the user a host is the verified custom domain of */
+func (ds *DomainService) ResolveHost(host string) (uint, bool) {
+	host = strings.TrimSuffix(strings.ToLower(host), ".")
+	if host == "" || net.ParseIP(host) != nil || isOwnHost(host) {
+		return 0, false
+	}
+
+	cacheKey := CustomDomainCachePrefix + host
+	cached, err := ds.Client.Get(cacheKey).Result()
+	if err == nil {
+		if cached == "" {
+			return 0, false
+		}
+		return utils.StringToUint(cached), true
+	} else if err != redis.Nil {
+		log.Printf("Error reading custom domain cache: %v", err)
+	}
+
+	var domain models.CustomDomain
+	err = ds.DB.Select("uid").Where("domain = ? AND verified_at IS NOT NULL", host).First(&domain).Error
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			ds.Client.Set(cacheKey, "", customDomainMissTTL)
+		} else {
+			log.Printf("Error resolving custom domain %s: %v", host, err)
+		}
+		return 0, false
+	}
+
+	ds.Client.Set(cacheKey, fmt.Sprint(domain.UID), customDomainCacheTTL)
+	return domain.UID, true
+}
+
+/* Key authorization for a pending HTTP-01 challenge, served under /.well-known/acme-challenge/ */
+func (ds *DomainService) ChallengeResponse(token string) (string, bool) {
+	response, err := ds.Client.Get(acmeChallengePrefix + token).Result()
+	if err != nil {
+		return "", false
+	}
+	return response, true
+}
+
+/* Certificate for a TLS handshake on a custom domain, used as tls.Config.GetCertificate */
+func (ds *DomainService) GetCertificate(hello *tls.ClientHelloInfo) (*tls.Certificate, error) {
+	name := strings.TrimSuffix(strings.ToLower(hello.ServerName), ".")
+	if _, ok := ds.ResolveHost(name); !ok {
+		return nil, errors.New("unknown domain")
+	}
+
+	ds.certMu.RLock()
+	cached := ds.certificates[name]
+	ds.certMu.RUnlock()
+	if cached != nil && time.Since(cached.loadedAt) < certificateCacheTTL {
+		return cached.certificate, nil
+	}
+
+	var domain models.CustomDomain
+	err := ds.DB.Select("certificate, certificate_key").
+		Where("domain = ? AND certificate <> ''", name).
+		First(&domain).Error
+	if err != nil {
+		return nil, errors.New("no certificate for domain")
+	}
+
+	keyPEM, err := utils.DecryptString(domain.CertificateKey)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decrypt certificate key: %w", err)
+	}
+
+	certificate, err := tls.X509KeyPair([]byte(domain.Certificate), []byte(keyPEM))
+	if err != nil {
+		return nil, err
+	}
+
+	ds.certMu.Lock()
+	ds.certificates[name] = &cachedCertificate{certificate: &certificate, loadedAt: time.Now()}
+	ds.certMu.Unlock()
+
+	return &certificate, nil
+}
+
+/*
+Verified domains whose certificate is missing, failed, stuck or about to
+expire. Domains of users who lost premium are skipped, failed domains only
+once their backoff has passed.
+*/
+func (ds *DomainService) DomainsNeedingCertificates() ([]models.CustomDomain, error) {
+	var domains []models.CustomDomain
+
+	now := time.Now()
+	query := ds.DB.Model(&models.CustomDomain{}).
+		Select("custom_domains.id, custom_domains.domain").
+		Where("custom_domains.verified_at IS NOT NULL").
+		Where("custom_domains.certificate_retry_at IS NULL OR custom_domains.certificate_retry_at < ?", now).
+		Where("custom_domains.certificate_status IN ? OR (custom_domains.certificate_status = ? AND custom_domains.updated_at < ?) OR custom_domains.certificate_expires_at < ?",
+			[]string{models.CertificateNone, models.CertificateFailed},
+			models.CertificatePending, now.Add(-2*acmeOrderTimeout),
+			now.Add(CertificateRenewBefore))
+
+	if models.NewPremiumFeatures().IsCustomDomainPremium() {
+		query = query.
+			Joins("JOIN user_subscriptions ON user_subscriptions.user_id = custom_domains.uid").
+			Where("user_subscriptions.status = ?", "active").
+			Distinct()
+	}
+
+	err := query.Find(&domains).Error
+	return domains, err
+}
+
+/* How long to wait before ordering again after a number of failed orders in a row */
+func certificateRetryDelay(failures int) time.Duration {
+	delay := certificateRetryBase
+	for i := 1; i < failures && delay < certificateRetryMax; i++ {
+		delay *= 2
+	}
+
+	if delay > certificateRetryMax {
+		return certificateRetryMax
+	}
+	return delay
+}
+
+/* Order a certificate for a verified domain, concurrent orders for the same domain are shared */
+func (ds *DomainService) ProvisionCertificate(id uint) error {
+	_, err, _ := ds.orders.Do(fmt.Sprint(id), func() (interface{}, error) {
+		return nil, ds.provisionCertificate(id)
+	})
+	return err
+}
+
+func (ds *DomainService) provisionCertificate(id uint) error {
+	var domain models.CustomDomain
+	if err := ds.DB.First(&domain, id).Error; err != nil {
+		return err
+	}
+
+	if !domain.IsVerified() {
+		return errors.New("domain not verified")
+	}
+
+	ds.DB.Model(&domain).Update("certificate_status", models.CertificatePending)
+
+	chainPEM, keyPEM, expiresAt, err := ds.orderCertificate(domain.Domain)
+	if err == nil {
+		keyPEM, err = utils.EncryptString(keyPEM)
+	}
+	if err != nil {
+		failures := domain.CertificateFailures + 1
+		retryAt := time.Now().Add(certificateRetryDelay(failures))
+
+		log.Printf("Error ordering certificate for %s (%d failures in a row, retrying after %s): %v",
+			domain.Domain, failures, retryAt.Format(time.RFC3339), err)
+		ds.DB.Model(&domain).Updates(map[string]interface{}{
+			"certificate_status":   models.CertificateFailed,
+			"certificate_error":    err.Error(),
+			"certificate_failures": failures,
+			"certificate_retry_at": retryAt,
+		})
+		return err
+	}
+
+	err = ds.DB.Model(&domain).Updates(map[string]interface{}{
+		"certificate_status":     models.CertificateIssued,
+		"certificate_error":      "",
+		"certificate_failures":   0,
+		"certificate_retry_at":   nil,
+		"certificate_expires_at": expiresAt,
+		"certificate":            chainPEM,
+		"certificate_key":        keyPEM,
+	}).Error
+	if err != nil {
+		return err
+	}
+
+	ds.certMu.Lock()
+	delete(ds.certificates, domain.Domain)
+	ds.certMu.Unlock()
+
+	log.Printf("Issued certificate for %s, expires %s", domain.Domain, expiresAt.Format(time.RFC3339))
+	return nil
+}
+
+/* Order a certificate through ACME, returning the PEM chain, the PEM key and when it expires */
+func (ds *DomainService) orderCertificate(name string) (string, string, time.Time, error) {
+	client, err := ds.acme()
+	if err != nil {
+		return "", "", time.Time{}, err
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), acmeOrderTimeout)
+	defer cancel()
+
+	order, err := client.AuthorizeOrder(ctx, acme.DomainIDs(name))
+	if err != nil {
+		return "", "", time.Time{}, fmt.Errorf("failed to create order: %w", err)
+	}
+
+	for _, authzURL := range order.AuthzURLs {
+		if err := ds.authorize(ctx, client, authzURL); err != nil {
+			return "", "", time.Time{}, err
+		}
+	}
+
+	order, err = client.WaitOrder(ctx, order.URI)
+	if err != nil {
+		return "", "", time.Time{}, fmt.Errorf("order not ready: %w", err)
+	}
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		return "", "", time.Time{}, err
+	}
+
+	csr, err := x509.CreateCertificateRequest(rand.Reader, &x509.CertificateRequest{DNSNames: []string{name}}, key)
+	if err != nil {
+		return "", "", time.Time{}, err
+	}
+
+	chain, _, err := client.CreateOrderCert(ctx, order.FinalizeURL, csr, true)
+	if err != nil {
+		return "", "", time.Time{}, fmt.Errorf("failed to finalize order: %w", err)
+	}
+	if len(chain) == 0 {
+		return "", "", time.Time{}, errors.New("no certificate issued")
+	}
+
+	leaf, err := x509.ParseCertificate(chain[0])
+	if err != nil {
+		return "", "", time.Time{}, fmt.Errorf("invalid certificate issued: %w", err)
+	}
+	if err := leaf.VerifyHostname(name); err != nil {
+		return "", "", time.Time{}, fmt.Errorf("invalid certificate issued: %w", err)
+	}
+
+	var chainPEM strings.Builder
+	for _, der := range chain {
+		chainPEM.Write(pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}))
+	}
+
+	keyPEM, err := encodeECKey(key)
+	if err != nil {
+		return "", "", time.Time{}, err
+	}
+
+	return chainPEM.String(), keyPEM, leaf.NotAfter, nil
+}
+
+/* Complete the HTTP-01 challenge of an authorization, any instance can answer it through Redis */
+func (ds *DomainService) authorize(ctx context.Context, client *acme.Client, authzURL string) error {
+	authz, err := client.GetAuthorization(ctx, authzURL)
+	if err != nil {
+		return fmt.Errorf("failed to get authorization: %w", err)
+	}
+
+	if authz.Status == acme.StatusValid {
+		return nil
+	}
+
+	var challenge *acme.Challenge
+	for _, c := range authz.Challenges {
+		if c.Type == "http-01" {
+			challenge = c
+			break
+		}
+	}
+	if challenge == nil {
+		return errors.New("no http-01 challenge offered")
+	}
+
+	response, err := client.HTTP01ChallengeResponse(challenge.Token)
+	if err != nil {
+		return err
+	}
+
+	challengeKey := acmeChallengePrefix + challenge.Token
+	if err := ds.Client.Set(challengeKey, response, acmeChallengeTTL).Err(); err != nil {
+		return err
+	}
+	defer ds.Client.Del(challengeKey)
+
+	if _, err := client.Accept(ctx, challenge); err != nil {
+		return fmt.Errorf("failed to accept challenge: %w", err)
+	}
+
+	if _, err := client.WaitAuthorization(ctx, authz.URI); err != nil {
+		return fmt.Errorf("authorization failed: %w", err)
+	}
+
+	return nil
+}
+
+/* ACME client registered with the configured directory, created on first use */
+func (ds *DomainService) acme() (*acme.Client, error) {
+	ds.acmeMu.Lock()
+	defer ds.acmeMu.Unlock()
+
+	if ds.acmeClient != nil {
+		return ds.acmeClient, nil
+	}
+
+	key, err := ds.accountKey()
+	if err != nil {
+		return nil, fmt.Errorf("failed to load acme account key: %w", err)
+	}
+
+	httpClient := &http.Client{Timeout: 30 * time.Second}
+	if config.ACMECACertPath != "" {
+		caPEM, err := os.ReadFile(config.ACMECACertPath)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read acme ca certificate: %w", err)
+		}
+
+		roots := x509.NewCertPool()
+		if !roots.AppendCertsFromPEM(caPEM) {
+			return nil, errors.New("invalid acme ca certificate")
+		}
+		httpClient.Transport = &http.Transport{TLSClientConfig: &tls.Config{RootCAs: roots}}
+	}
+
+	client := &acme.Client{
+		Key:          key,
+		DirectoryURL: config.ACMEDirectoryURL,
+		HTTPClient:   httpClient,
+		UserAgent:    "haze.bio",
+	}
+
+	account := &acme.Account{}
+	if config.ACMEEmail != "" {
+		account.Contact = []string{"mailto:" + config.ACMEEmail}
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	if _, err := client.Register(ctx, account, acme.AcceptTOS); err != nil && !errors.Is(err, acme.ErrAccountAlreadyExists) {
+		return nil, fmt.Errorf("failed to register acme account: %w", err)
+	}
+
+	ds.acmeClient = client
+	return client, nil
+}
+
+/* ACME account key, created once and stored encrypted in Redis so every instance shares the account */
+func (ds *DomainService) accountKey() (*ecdsa.PrivateKey, error) {
+	stored, err := ds.Client.Get(acmeAccountKey).Result()
+	if err == nil {
+		keyPEM, err := utils.DecryptString(stored)
+		if err != nil {
+			return nil, err
+		}
+		return decodeECKey(keyPEM)
+	} else if err != redis.Nil {
+		return nil, err
+	}
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		return nil, err
+	}
+
+	keyPEM, err := encodeECKey(key)
+	if err != nil {
+		return nil, err
+	}
+
+	encrypted, err := utils.EncryptString(keyPEM)
+	if err != nil {
+		return nil, err
+	}
+
+	// Another instance may have created the key first, theirs is used then
+	created, err := ds.Client.SetNX(acmeAccountKey, encrypted, 0).Result()
+	if err != nil {
+		return nil, err
+	}
+	if !created {
+		return ds.accountKey()
+	}
+
+	return key, nil
+}
+
+func encodeECKey(key *ecdsa.PrivateKey) (string, error) {
+	der, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		return "", err
+	}
+	return string(pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: der})), nil
+}
+
+func decodeECKey(keyPEM string) (*ecdsa.PrivateKey, error) {
+	block, _ := pem.Decode([]byte(keyPEM))
+	if block == nil {
+		return nil, errors.New("invalid key")
+	}
+	return x509.ParseECPrivateKey(block.Bytes)
+}
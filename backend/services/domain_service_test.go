@@ -0,0 +1,401 @@
+package services
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"net"
+	"net/http"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/hazebio/haze.bio_backend/config"
+	"github.com/hazebio/haze.bio_backend/models"
+	"golang.org/x/net/dns/dnsmessage"
+)
+
+/*
+DNS server answering TXT queries from records, keyed by fully qualified name.
+Names in failing get SERVFAIL, everything else NXDOMAIN.
+*/
+func newStubDNS(t *testing.T, records map[string][]string, failing ...string) string {
+	t.Helper()
+
+	conn, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { conn.Close() })
+
+	go func() {
+		buf := make([]byte, 512)
+		for {
+			n, addr, err := conn.ReadFrom(buf)
+			if err != nil {
+				return
+			}
+
+			var query dnsmessage.Message
+			if err := query.Unpack(buf[:n]); err != nil || len(query.Questions) != 1 {
+				continue
+			}
+			question := query.Questions[0]
+			name := strings.ToLower(question.Name.String())
+
+			response := dnsmessage.Message{
+				Header:    dnsmessage.Header{ID: query.ID, Response: true, Authoritative: true, RCode: dnsmessage.RCodeNameError},
+				Questions: query.Questions,
+			}
+
+			for _, failed := range failing {
+				if name == failed {
+					response.RCode = dnsmessage.RCodeServerFailure
+				}
+			}
+
+			if values, ok := records[name]; ok {
+				response.RCode = dnsmessage.RCodeSuccess
+				if question.Type == dnsmessage.TypeTXT {
+					// One record per value, strings of a single record are joined by the resolver
+					for _, value := range values {
+						response.Answers = append(response.Answers, dnsmessage.Resource{
+							Header: dnsmessage.ResourceHeader{Name: question.Name, Type: dnsmessage.TypeTXT, Class: dnsmessage.ClassINET, TTL: 60},
+							Body:   &dnsmessage.TXTResource{TXT: []string{value}},
+						})
+					}
+				}
+			}
+
+			packed, err := response.Pack()
+			if err != nil {
+				continue
+			}
+			conn.WriteTo(packed, addr)
+		}
+	}()
+
+	return conn.LocalAddr().String()
+}
+
+/* Domain service on a throwaway SQLite database and a fake Redis */
+func newTestDomainService(t *testing.T, resolverAddress string) *DomainService {
+	t.Helper()
+
+	db := newTestDB(t,
+		&models.CustomDomain{},
+		&models.User{},
+		&models.UserProfile{},
+		&models.UserSocial{},
+		&models.UserWidget{},
+		&models.UserSubscription{},
+		&models.Punishment{},
+		&models.Badge{},
+		&models.UserBadge{},
+	)
+
+	client, _ := newFakeRedis(t)
+
+	ds := NewDomainService(db, client)
+	if resolverAddress != "" {
+		ds.Resolver = newDNSResolver(resolverAddress)
+	}
+	return ds
+}
+
+func createDomain(t *testing.T, ds *DomainService, domain *models.CustomDomain) {
+	t.Helper()
+
+	if domain.CertificateStatus == "" {
+		domain.CertificateStatus = models.CertificateNone
+	}
+	if err := ds.DB.Create(domain).Error; err != nil {
+		t.Fatal(err)
+	}
+}
+
+func createUser(t *testing.T, ds *DomainService, uid uint, premium bool) {
+	t.Helper()
+
+	user := &models.User{UID: uid, Username: "user" + strings.Repeat("x", int(uid)), DisplayName: "User", Password: "-"}
+	if err := ds.DB.Create(user).Error; err != nil {
+		t.Fatal(err)
+	}
+
+	if premium {
+		subscription := &models.UserSubscription{UserID: uid, SubscriptionType: "lifetime", Status: "active", NextPaymentDate: time.Now()}
+		if err := ds.DB.Create(subscription).Error; err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func TestVerifyDomain(t *testing.T) {
+	dns := newStubDNS(t, map[string][]string{
+		"_haze-verification.match.test.":    {"v=spf1 -all", "haze-verification=match-token"},
+		"_haze-verification.mismatch.test.": {"haze-verification=someone-elses-token"},
+	}, "_haze-verification.broken.test.")
+	ds := newTestDomainService(t, dns)
+
+	createDomain(t, ds, &models.CustomDomain{UID: 1, Domain: "match.test", VerificationToken: "match-token"})
+	createDomain(t, ds, &models.CustomDomain{UID: 2, Domain: "mismatch.test", VerificationToken: "mismatch-token"})
+	createDomain(t, ds, &models.CustomDomain{UID: 3, Domain: "missing.test", VerificationToken: "missing-token"})
+	createDomain(t, ds, &models.CustomDomain{UID: 4, Domain: "broken.test", VerificationToken: "broken-token"})
+
+	t.Run("matching record", func(t *testing.T) {
+		// A lookup before verification caches the host as unknown
+		if _, ok := ds.ResolveHost("match.test"); ok {
+			t.Fatal("unverified domain resolved")
+		}
+
+		domain, err := ds.VerifyDomain(1)
+		if err != nil {
+			t.Fatalf("VerifyDomain() error = %v", err)
+		}
+		if !domain.IsVerified() {
+			t.Error("domain not marked verified")
+		}
+
+		stored, err := ds.GetDomain(1)
+		if err != nil || !stored.IsVerified() {
+			t.Fatalf("stored domain not verified: %+v, %v", stored, err)
+		}
+
+		if uid, ok := ds.ResolveHost("match.test"); !ok || uid != 1 {
+			t.Errorf("ResolveHost() = %d, %t, want the verified domain to resolve to 1", uid, ok)
+		}
+
+		// Verified domains are not looked up again
+		if _, err := ds.VerifyDomain(1); err != nil {
+			t.Errorf("VerifyDomain() of a verified domain error = %v", err)
+		}
+	})
+
+	t.Run("mismatched token", func(t *testing.T) {
+		_, err := ds.VerifyDomain(2)
+		if err == nil || err.Error() != "verification record not found" {
+			t.Fatalf("VerifyDomain() error = %v, want verification record not found", err)
+		}
+
+		stored, _ := ds.GetDomain(2)
+		if stored.IsVerified() {
+			t.Error("domain verified with someone else's token")
+		}
+		if stored.LastCheckedAt == nil {
+			t.Error("failed check not recorded")
+		}
+	})
+
+	t.Run("cooldown", func(t *testing.T) {
+		_, err := ds.VerifyDomain(2)
+		if err == nil || err.Error() != "verification checked too recently" {
+			t.Fatalf("VerifyDomain() error = %v, want verification checked too recently", err)
+		}
+
+		past := time.Now().Add(-domainVerifyCooldown - time.Second)
+		ds.DB.Model(&models.CustomDomain{}).Where("uid = ?", 2).Update("last_checked_at", past)
+
+		_, err = ds.VerifyDomain(2)
+		if err == nil || err.Error() != "verification record not found" {
+			t.Fatalf("VerifyDomain() after the cooldown error = %v, want verification record not found", err)
+		}
+	})
+
+	t.Run("missing record", func(t *testing.T) {
+		_, err := ds.VerifyDomain(3)
+		if err == nil || err.Error() != "verification record not found" {
+			t.Fatalf("VerifyDomain() error = %v, want verification record not found", err)
+		}
+	})
+
+	t.Run("lookup failure", func(t *testing.T) {
+		_, err := ds.VerifyDomain(4)
+		if err == nil || err.Error() != "dns lookup failed" {
+			t.Fatalf("VerifyDomain() error = %v, want dns lookup failed", err)
+		}
+	})
+
+	t.Run("no domain", func(t *testing.T) {
+		_, err := ds.VerifyDomain(99)
+		if err == nil || err.Error() != "domain not found" {
+			t.Fatalf("VerifyDomain() error = %v, want domain not found", err)
+		}
+	})
+}
+
+func TestAddDomainClaims(t *testing.T) {
+	ds := newTestDomainService(t, "")
+	createUser(t, ds, 1, true)
+	createUser(t, ds, 2, true)
+	createUser(t, ds, 3, false)
+
+	if _, err := ds.AddDomain(3, "free.test"); err == nil || err.Error() != "custom domains require premium" {
+		t.Fatalf("AddDomain() without premium error = %v", err)
+	}
+
+	claim, err := ds.AddDomain(1, "Claimed.Test.")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if claim.Domain != "claimed.test" || claim.Verification == nil {
+		t.Fatalf("AddDomain() = %+v, want the normalized domain with its verification record", claim)
+	}
+
+	t.Run("fresh claim blocks others", func(t *testing.T) {
+		if _, err := ds.AddDomain(2, "claimed.test"); err == nil || err.Error() != "domain already in use" {
+			t.Fatalf("AddDomain() error = %v, want domain already in use", err)
+		}
+	})
+
+	t.Run("expired claim can be taken over", func(t *testing.T) {
+		expired := time.Now().Add(-domainClaimExpiry - time.Hour)
+		ds.DB.Model(&models.CustomDomain{}).Where("id = ?", claim.ID).Update("created_at", expired)
+
+		taken, err := ds.AddDomain(2, "claimed.test")
+		if err != nil {
+			t.Fatalf("AddDomain() of an expired claim error = %v", err)
+		}
+		if taken.UID != 2 || taken.VerificationToken == claim.VerificationToken {
+			t.Errorf("AddDomain() = %+v, want a new claim for user 2", taken)
+		}
+
+		if _, err := ds.GetDomain(1); err == nil || err.Error() != "domain not found" {
+			t.Errorf("previous owner still has the domain: %v", err)
+		}
+	})
+
+	t.Run("verified domains are never taken over", func(t *testing.T) {
+		verifiedAt := time.Now().Add(-domainClaimExpiry - time.Hour)
+		createDomain(t, ds, &models.CustomDomain{UID: 3, Domain: "owned.test", VerificationToken: "owned", VerifiedAt: &verifiedAt, CreatedAt: verifiedAt})
+
+		if _, err := ds.AddDomain(1, "owned.test"); err == nil || err.Error() != "domain already in use" {
+			t.Fatalf("AddDomain() error = %v, want domain already in use", err)
+		}
+	})
+}
+
+/* Point the ACME settings at a test CA for the duration of a test */
+func useTestCA(t *testing.T) *CAServer {
+	t.Helper()
+	t.Setenv("ENCRYPTION_KEY", strings.Repeat("ab", 32))
+
+	ca := NewCAServer(t).ChallengeTypes("http-01").Start()
+
+	directoryURL, caCertPath, email := config.ACMEDirectoryURL, config.ACMECACertPath, config.ACMEEmail
+	t.Cleanup(func() {
+		config.ACMEDirectoryURL, config.ACMECACertPath, config.ACMEEmail = directoryURL, caCertPath, email
+	})
+	config.ACMEDirectoryURL = ca.URL()
+	config.ACMECACertPath = ""
+	config.ACMEEmail = "certificates@example.test"
+
+	return ca
+}
+
+/* HTTP-01 responder answering from ChallengeResponse like the ACME challenge route, recording the tokens it served */
+func challengeHandler(ds *DomainService, served *[]string, mu *sync.Mutex) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		token := strings.TrimPrefix(r.URL.Path, "/.well-known/acme-challenge/")
+
+		response, ok := ds.ChallengeResponse(token)
+		if !ok {
+			http.NotFound(w, r)
+			return
+		}
+
+		mu.Lock()
+		*served = append(*served, token)
+		mu.Unlock()
+		w.Write([]byte(response))
+	})
+}
+
+func TestOrderCertificate(t *testing.T) {
+	ca := useTestCA(t)
+	client, _ := newFakeRedis(t)
+	ds := NewDomainService(nil, client)
+
+	var mu sync.Mutex
+	var served []string
+	ca.ResolveHandler("shop.example.test", challengeHandler(ds, &served, &mu))
+
+	chainPEM, keyPEM, expiresAt, err := ds.orderCertificate("shop.example.test")
+	if err != nil {
+		t.Fatalf("orderCertificate() error = %v", err)
+	}
+
+	certificate, err := tls.X509KeyPair([]byte(chainPEM), []byte(keyPEM))
+	if err != nil {
+		t.Fatalf("issued chain and key don't match: %v", err)
+	}
+
+	leaf, err := x509.ParseCertificate(certificate.Certificate[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := leaf.Verify(x509.VerifyOptions{DNSName: "shop.example.test", Roots: ca.Roots()}); err != nil {
+		t.Errorf("issued certificate doesn't verify: %v", err)
+	}
+	if !leaf.NotAfter.Equal(expiresAt) {
+		t.Errorf("expiresAt = %s, want the certificate's NotAfter %s", expiresAt, leaf.NotAfter)
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+	if len(served) == 0 {
+		t.Fatal("HTTP-01 challenge was never answered")
+	}
+	if _, ok := ds.ChallengeResponse(served[0]); ok {
+		t.Error("challenge response kept after the order")
+	}
+
+	// Other instances share the account through Redis
+	other := NewDomainService(nil, client)
+	ownKey, err := ds.accountKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+	otherKey, err := other.accountKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !ownKey.Equal(otherKey) {
+		t.Error("instances created separate ACME accounts")
+	}
+}
+
+func TestOrderCertificateUnansweredChallenge(t *testing.T) {
+	ca := useTestCA(t)
+	client, _ := newFakeRedis(t)
+	ds := NewDomainService(nil, client)
+
+	// Another instance without access to the challenge responses answers the validation
+	unrelated, _ := newFakeRedis(t)
+	var mu sync.Mutex
+	var served []string
+	ca.ResolveHandler("shop.example.test", challengeHandler(NewDomainService(nil, unrelated), &served, &mu))
+
+	if _, _, _, err := ds.orderCertificate("shop.example.test"); err == nil {
+		t.Fatal("orderCertificate() succeeded without answering the challenge")
+	}
+}
+
+func TestCertificateRetryDelay(t *testing.T) {
+	tests := []struct {
+		failures int
+		want     time.Duration
+	}{
+		{1, 6 * time.Hour},
+		{2, 12 * time.Hour},
+		{3, 24 * time.Hour},
+		{5, 96 * time.Hour},
+		{6, certificateRetryMax},
+		{100, certificateRetryMax},
+	}
+
+	for _, tt := range tests {
+		if got := certificateRetryDelay(tt.failures); got != tt.want {
+			t.Errorf("certificateRetryDelay(%d) = %s, want %s", tt.failures, got, tt.want)
+		}
+	}
+}
@@ -25,6 +25,7 @@ type EmailService struct {
 	EventService      *EventService
 	AltAccountService *AltAccountService
 	InviteService     *InviteService
+	ReferralService   *ReferralService
 }
 
 const (
@@ -363,6 +364,12 @@ func (s *EmailService) CompleteRegistrationWithIP(token string, ipAddress string
 		// Don't fail registration if invite code usage fails
 	}
 
+	if s.ReferralService != nil {
+		if err := s.ReferralService.RecordReferral(reg.InviteCode, user.UID, ipAddress); err != nil {
+			log.Printf("Error recording referral: %v", err)
+		}
+	}
+
 	key := fmt.Sprintf("%s%s", registrationPrefix, token)
 	s.Client.Del(key)
 
@@ -0,0 +1,177 @@
+package services
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/go-redis/redis"
+)
+
+// fakeRedis speaks enough of the Redis protocol for the string commands the
+// services use, so they can be tested without a Redis server
+type fakeRedis struct {
+	mu      sync.Mutex
+	values  map[string]string
+	expires map[string]time.Time
+}
+
+/* Client connected to a fake Redis server that is stopped with the test */
+func newFakeRedis(t *testing.T) (*redis.Client, *fakeRedis) {
+	t.Helper()
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	server := &fakeRedis{values: map[string]string{}, expires: map[string]time.Time{}}
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			go server.serve(conn)
+		}
+	}()
+
+	client := redis.NewClient(&redis.Options{Addr: listener.Addr().String()})
+	t.Cleanup(func() {
+		client.Close()
+		listener.Close()
+	})
+
+	return client, server
+}
+
+func (f *fakeRedis) serve(conn net.Conn) {
+	defer conn.Close()
+
+	reader := bufio.NewReader(conn)
+	for {
+		args, err := readCommand(reader)
+		if err != nil {
+			return
+		}
+		if _, err := io.WriteString(conn, f.execute(args)); err != nil {
+			return
+		}
+	}
+}
+
+func readCommand(reader *bufio.Reader) ([]string, error) {
+	line, err := reader.ReadString('\n')
+	if err != nil {
+		return nil, err
+	}
+
+	count, err := strconv.Atoi(strings.TrimSpace(strings.TrimPrefix(line, "*")))
+	if err != nil {
+		return nil, err
+	}
+
+	args := make([]string, count)
+	for i := range args {
+		line, err := reader.ReadString('\n')
+		if err != nil {
+			return nil, err
+		}
+
+		size, err := strconv.Atoi(strings.TrimSpace(strings.TrimPrefix(line, "$")))
+		if err != nil {
+			return nil, err
+		}
+
+		data := make([]byte, size+2)
+		if _, err := io.ReadFull(reader, data); err != nil {
+			return nil, err
+		}
+		args[i] = string(data[:size])
+	}
+
+	return args, nil
+}
+
+func bulkString(value string) string {
+	return fmt.Sprintf("$%d\r\n%s\r\n", len(value), value)
+}
+
+func (f *fakeRedis) get(key string) (string, bool) {
+	if expiresAt, ok := f.expires[key]; ok && time.Now().After(expiresAt) {
+		delete(f.values, key)
+		delete(f.expires, key)
+	}
+
+	value, ok := f.values[key]
+	return value, ok
+}
+
+func (f *fakeRedis) execute(args []string) string {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	switch strings.ToUpper(args[0]) {
+	case "PING":
+		return "+PONG\r\n"
+	case "GET":
+		value, ok := f.get(args[1])
+		if !ok {
+			return "$-1\r\n"
+		}
+		return bulkString(value)
+	case "SET":
+		key, value := args[1], args[2]
+		var ttl time.Duration
+		onlyNew := false
+		for i := 3; i < len(args); i++ {
+			switch strings.ToUpper(args[i]) {
+			case "EX":
+				seconds, _ := strconv.Atoi(args[i+1])
+				ttl = time.Duration(seconds) * time.Second
+				i++
+			case "PX":
+				millis, _ := strconv.Atoi(args[i+1])
+				ttl = time.Duration(millis) * time.Millisecond
+				i++
+			case "NX":
+				onlyNew = true
+			}
+		}
+
+		if _, exists := f.get(key); exists && onlyNew {
+			return "$-1\r\n"
+		}
+
+		f.values[key] = value
+		delete(f.expires, key)
+		if ttl > 0 {
+			f.expires[key] = time.Now().Add(ttl)
+		}
+		return "+OK\r\n"
+	case "SETNX":
+		if _, exists := f.get(args[1]); exists {
+			return ":0\r\n"
+		}
+		f.values[args[1]] = args[2]
+		return ":1\r\n"
+	case "DEL":
+		deleted := 0
+		for _, key := range args[1:] {
+			if _, ok := f.get(key); ok {
+				deleted++
+			}
+			delete(f.values, key)
+			delete(f.expires, key)
+		}
+		return fmt.Sprintf(":%d\r\n", deleted)
+	default:
+		return fmt.Sprintf("-ERR unknown command '%s'\r\n", args[0])
+	}
+}
@@ -2,10 +2,14 @@ package services
 
 import (
 	"bytes"
+	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"log"
 	"net/http"
+	"net/url"
+	"path"
 	"strings"
 	"time"
 
@@ -18,92 +22,131 @@ import (
 )
 
 type FileService struct {
-	DB     *gorm.DB
-	Client *redis.Client
+	DB           *gorm.DB
+	Client       *redis.Client
+	MediaService *MediaService
+	ScanService  *ScanService
+
+	// Reports uploads flagged by content scanning, optional
+	PunishService *PunishService
 }
 
 func NewFileService(db *gorm.DB, client *redis.Client) *FileService {
 	return &FileService{
-		DB:     db,
-		Client: client,
+		DB:           db,
+		Client:       client,
+		MediaService: NewMediaService(),
+		ScanService:  NewScanService(),
 	}
 }
 
-/* Upload a file */
-func (fs *FileService) UploadFile(fileType, fileName string, userID uint, fileBytes []byte) (string, error) {
-	fileExtension := utils.GetFileExtension(fileName)
-
-	allowedExtensions := map[string]bool{
-		".mp4":  true,
-		".webm": true,
-		".ogg":  true,
-		".mp3":  true,
-		".wav":  true,
-		".jpg":  true,
-		".jpeg": true,
-		".png":  true,
-		".gif":  true,
-	}
-
-	if !allowedExtensions[strings.ToLower(fileExtension)] {
-		return "", fmt.Errorf("file type %s is not allowed", fileExtension)
-	}
+const (
+	freeStorageQuota    int64 = 250 << 20
+	premiumStorageQuota int64 = 2 << 30
 
-	uuid := uuid.New().String()
-	fileKey := uuid + fileExtension
+	// Removed files stay in the bucket for a while so in-flight page loads don't break
+	fileGCGracePeriod = 24 * time.Hour
 
-	// Change to file upload service endpoint
-	putURL := fmt.Sprintf("%s/%s", config.R2URL, fileKey)
+	quarantineKeyPrefix  = "quarantine/"
+	quarantinePreviewTTL = 10 * time.Minute
+	// Quarantined files are downloaded again when a moderator releases them
+	maxQuarantineDownload = 300 << 20
+)
 
-	log.Printf("Attempting to upload file to file-upload service: %s", putURL)
+// Profile fields that can point at a file from the media library
+var profileMediaFields = []string{"avatar_url", "background_url", "audio_url", "cursor_url", "banner_url"}
+
+/*
+Upload a profile file. The upload is processed and scanned first, the profile
+field points at the processed original and its resized variants are recorded
+in MediaVariants under the field name. Every stored object is recorded in the
+user's media library and counts towards their storage quota.
+*/
+func (fs *FileService) UploadFile(fileType string, userID uint, fileBytes []byte) (string, error) {
+	processed, err := fs.MediaService.Process(fileType, fileBytes)
+	if err != nil {
+		return "", err
+	}
 
-	req, err := http.NewRequest("PUT", putURL, bytes.NewReader(fileBytes))
+	profile := &models.UserProfile{}
+	err = fs.DB.Where("uid = ?", userID).First(profile).Error
 	if err != nil {
-		log.Printf("Error creating request: %v", err)
 		return "", err
 	}
 
-	req.Header.Set("Content-Type", "application/octet-stream")
-	req.Header.Set("X-Api-Key", config.R2APIKey)
+	if err := fs.checkQuota(userID, processed.Size()); err != nil {
+		return "", err
+	}
 
-	// Set up HTTP client with extended timeout for large uploads
-	client := &http.Client{
-		Timeout: 5 * time.Minute,
+	if err := fs.scanUpload(userID, fileType, fileBytes, processed.Kind); err != nil {
+		return "", err
 	}
 
-	log.Printf("Sending request to S3 server...")
-	resp, err := client.Do(req)
+	fileURL, media, err := fs.storeProcessed(userID, fileType, processed)
 	if err != nil {
-		log.Printf("Error sending request to S3: %v", err)
-		return "", fmt.Errorf("error communicating with storage server: %w", err)
+		return "", err
 	}
-	defer resp.Body.Close()
 
-	// Read response body
-	body, err := io.ReadAll(resp.Body)
+	if err := setProfileMedia(profile, fileType, fileURL, media); err != nil {
+		return "", err
+	}
+
+	err = fs.UpdateUserProfile(profile)
 	if err != nil {
-		log.Printf("Error reading response body: %v", err)
-		return "", fmt.Errorf("error reading server response: %w", err)
+		return "", err
 	}
 
-	log.Printf("S3 server responded with status: %d", resp.StatusCode)
+	return fileURL, nil
+}
 
-	if resp.StatusCode != http.StatusOK {
-		log.Printf("S3 upload failed. Status: %d, Response: %s", resp.StatusCode, string(body))
-		return "", fmt.Errorf("failed to upload file: error code: %d, response: %s",
-			resp.StatusCode, string(body))
+/* Store a processed upload and its variants and record them in the media library */
+func (fs *FileService) storeProcessed(userID uint, fileType string, processed *ProcessedUpload) (string, models.ProcessedMedia, error) {
+	id := uuid.New().String()
+	fileKey := id + processed.Original.Extension
+	if err := fs.putObject(fileKey, processed.Original.Data); err != nil {
+		return "", models.ProcessedMedia{}, err
 	}
 
 	fileURL := fmt.Sprintf("%s/%s", config.R2PublicURL, fileKey)
 	log.Printf("File uploaded successfully. URL: %s", fileURL)
 
-	profile := &models.UserProfile{}
-	err = fs.DB.Where("uid = ?", userID).First(profile).Error
+	original, err := fs.recordFile(userID, fileKey, fileType, processed.Kind, processed.Original, nil)
 	if err != nil {
-		return "", err
+		return "", models.ProcessedMedia{}, err
+	}
+
+	media := models.ProcessedMedia{
+		Source:   fileURL,
+		Variants: []models.MediaVariant{},
+	}
+
+	for _, variant := range processed.Variants {
+		variantKey := fmt.Sprintf("%s_%s%s", id, variant.Label, variant.Extension)
+		if err := fs.putObject(variantKey, variant.Data); err != nil {
+			// The original works on its own, a missing variant only costs bandwidth
+			log.Printf("Error uploading %s variant %s: %v", fileType, variant.Label, err)
+			continue
+		}
+
+		if _, err := fs.recordFile(userID, variantKey, fileType, processed.Kind, variant, &original.ID); err != nil {
+			log.Printf("Error recording %s variant %s: %v", fileType, variant.Label, err)
+		}
+
+		media.Variants = append(media.Variants, models.MediaVariant{
+			Label:  variant.Label,
+			URL:    fmt.Sprintf("%s/%s", config.R2PublicURL, variantKey),
+			Format: variant.Format,
+			Width:  variant.Width,
+			Height: variant.Height,
+		})
 	}
 
-	switch fileType {
+	return fileURL, media, nil
+}
+
+/* Point a profile field at a file and record its variants */
+func setProfileMedia(profile *models.UserProfile, field string, fileURL string, media models.ProcessedMedia) error {
+	switch field {
 	case "avatar_url":
 		profile.AvatarURL = fileURL
 	case "background_url":
@@ -115,15 +158,117 @@ func (fs *FileService) UploadFile(fileType, fileName string, userID uint, fileBy
 	case "banner_url":
 		profile.BannerURL = fileURL
 	default:
-		return "", fmt.Errorf("invalid file type: %s", fileType)
+		return fmt.Errorf("invalid file type: %s", field)
 	}
 
-	err = fs.UpdateUserProfile(profile)
+	if profile.MediaVariants == nil {
+		profile.MediaVariants = models.MediaVariants{}
+	}
+	profile.MediaVariants[field] = media
+
+	return nil
+}
+
+/* Record a stored object in the user's media library */
+func (fs *FileService) recordFile(uid uint, fileKey string, usage string, kind utils.MediaKind, output MediaOutput, parentID *uint) (*models.UserFile, error) {
+	file := &models.UserFile{
+		UID:      uid,
+		Key:      fileKey,
+		URL:      fmt.Sprintf("%s/%s", config.R2PublicURL, fileKey),
+		Size:     int64(len(output.Data)),
+		Kind:     string(kind),
+		Format:   output.Format,
+		Width:    output.Width,
+		Height:   output.Height,
+		Usage:    usage,
+		ParentID: parentID,
+		Label:    output.Label,
+	}
+
+	if err := fs.DB.Create(file).Error; err != nil {
+		return nil, err
+	}
+
+	return file, nil
+}
+
+/* Get the storage a user has used and their quota, premium users get a larger quota */
+func (fs *FileService) GetStorageUsage(uid uint) (*models.StorageUsage, error) {
+	usage := &models.StorageUsage{Quota: freeStorageQuota}
+
+	err := fs.DB.Model(&models.UserFile{}).
+		Where("uid = ? AND removed_at IS NULL", uid).
+		Select("COALESCE(SUM(size), 0)").
+		Scan(&usage.Used).Error
 	if err != nil {
-		return "", err
+		return nil, err
 	}
 
-	return fileURL, nil
+	var premium int64
+	err = fs.DB.Model(&models.UserSubscription{}).Where("user_id = ? AND status = ?", uid, "active").Count(&premium).Error
+	if err != nil {
+		return nil, err
+	}
+
+	if premium > 0 {
+		usage.Quota = premiumStorageQuota
+	}
+
+	return usage, nil
+}
+
+func (fs *FileService) checkQuota(uid uint, size int64) error {
+	usage, err := fs.GetStorageUsage(uid)
+	if err != nil {
+		return err
+	}
+
+	if usage.Used+size > usage.Quota {
+		return utils.NewMediaError("storage quota exceeded, remove files from your media library to free up space")
+	}
+
+	return nil
+}
+
+/* Store a file in the bucket through the file-upload service */
+func (fs *FileService) putObject(fileKey string, data []byte) error {
+	return fs.putObjectWithAccess(fileKey, data, "")
+}
+
+/* Store a file with an access policy of the file-upload service, public when empty */
+func (fs *FileService) putObjectWithAccess(fileKey string, data []byte, access string) error {
+	putURL := fmt.Sprintf("%s/%s", config.R2URL, fileKey)
+	if access != "" {
+		putURL += "?access=" + url.QueryEscape(access)
+	}
+
+	req, err := http.NewRequest("PUT", putURL, bytes.NewReader(data))
+	if err != nil {
+		return err
+	}
+
+	req.Header.Set("Content-Type", "application/octet-stream")
+	req.Header.Set("X-Api-Key", config.R2APIKey)
+
+	// Set up HTTP client with extended timeout for large uploads
+	client := &http.Client{
+		Timeout: 5 * time.Minute,
+	}
+
+	resp, err := client.Do(req)
+	if err != nil {
+		log.Printf("Error sending request to S3: %v", err)
+		return fmt.Errorf("error communicating with storage server: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(resp.Body)
+		log.Printf("S3 upload failed. Status: %d, Response: %s", resp.StatusCode, string(body))
+		return fmt.Errorf("failed to upload file: error code: %d, response: %s", resp.StatusCode, string(body))
+	}
+
+	return nil
 }
 
 func (fs *FileService) CheckR2Health() error {
@@ -153,44 +298,169 @@ func (fs *FileService) CheckR2Health() error {
 	return nil
 }
 
-/* Upload Custom Badge Media but in path https://s3.haze.bio/custom_badges/{badgeName}.png */
-func (fs *FileService) UploadCustomBadgeMedia(badgeID uint, fileName string, fileBytes []byte) (string, error) {
-	fileExtension := utils.GetFileExtension(fileName)
-	fileKey := fmt.Sprintf("custom_badges/%d%s", badgeID, fileExtension)
+/*
+Upload new media for a custom badge to custom_badges/{badgeID}/{uuid}.{ext}.
+The badge keeps its current media until an edit using the returned URL is
+approved, so uploads never overwrite the live file.
+*/
+func (fs *FileService) UploadCustomBadgeMedia(uid uint, badgeID uint, fileBytes []byte) (string, error) {
+	var count int64
+	err := fs.DB.Model(&models.UserBadge{}).
+		Joins("JOIN badges ON badges.id = user_badges.badge_id").
+		Where("user_badges.uid = ? AND user_badges.badge_id = ? AND badges.is_custom = ?", uid, badgeID, true).
+		Count(&count).Error
+	if err != nil {
+		return "", err
+	}
 
-	oldFileURL := fmt.Sprintf("%s/custom_badges/%d%s", config.R2URL, badgeID, fileExtension)
-	err := fs.DeleteFileByURL(oldFileURL)
-	if err != nil && !strings.Contains(err.Error(), "file URL not found") {
-		log.Printf("Error deleting old badge: %v", err)
+	if count == 0 {
+		return "", errors.New("badge not found")
 	}
 
-	putURL := fmt.Sprintf("%s/%s", config.R2URL, fileKey)
-	req, err := http.NewRequest("PUT", putURL, bytes.NewReader(fileBytes))
+	format, err := utils.ValidateImage(fileBytes, utils.CustomBadgeImageLimits)
 	if err != nil {
 		return "", err
 	}
-	req.Header.Set("Content-Type", "application/octet-stream")
-	req.Header.Set("X-Api-Key", config.R2APIKey) // Changed header name
 
-	client := &http.Client{Timeout: 5 * time.Minute}
+	if err := fs.scanUpload(uid, "custom_badge", fileBytes, utils.MediaKindImage); err != nil {
+		return "", err
+	}
+
+	fileKey := fmt.Sprintf("%s%s%s", customBadgeMediaKeyPrefix(badgeID), uuid.New().String(), utils.ImageExtension(format))
+
+	if err := fs.putObject(fileKey, fileBytes); err != nil {
+		return "", err
+	}
+
+	fileURL := fmt.Sprintf("%s/%s", config.R2PublicURL, fileKey)
+
+	return fileURL, nil
+}
+
+func customBadgeMediaKeyPrefix(badgeID uint) string {
+	return fmt.Sprintf("custom_badges/%d/", badgeID)
+}
+
+/* Public URL prefix of the media uploaded for a custom badge */
+func CustomBadgeMediaURLPrefix(badgeID uint) string {
+	return fmt.Sprintf("%s/%s", config.R2PublicURL, customBadgeMediaKeyPrefix(badgeID))
+}
+
+/*
+Object key of media uploaded for a custom badge. The URL has to name exactly
+one file directly in the badge's folder, dot segments, encoded slashes and
+query strings are rejected rather than resolved.
+*/
+func customBadgeMediaKey(badgeID uint, fileURL string) (string, bool) {
+	parsed, err := url.Parse(fileURL)
+	if err != nil || parsed.RawQuery != "" || parsed.Fragment != "" || parsed.User != nil {
+		return "", false
+	}
+
+	prefix, err := url.Parse(CustomBadgeMediaURLPrefix(badgeID))
+	if err != nil || parsed.Scheme != prefix.Scheme || parsed.Host != prefix.Host {
+		return "", false
+	}
+
+	cleaned := path.Clean(parsed.Path)
+	if cleaned != parsed.Path || !strings.HasPrefix(cleaned, prefix.Path) {
+		return "", false
+	}
+
+	name := strings.TrimPrefix(cleaned, prefix.Path)
+	if name == "" || name == "." || name == ".." || strings.ContainsAny(name, "/\\") {
+		return "", false
+	}
+
+	return customBadgeMediaKeyPrefix(badgeID) + name, true
+}
+
+/* Delete media uploaded for a custom badge, URLs outside the badge's folder are ignored */
+func (fs *FileService) DeleteCustomBadgeMedia(badgeID uint, fileURL string) error {
+	fileKey, ok := customBadgeMediaKey(badgeID, fileURL)
+	if !ok {
+		return nil
+	}
+
+	return fs.deleteObject(fileKey)
+}
+
+/* List one page of objects in the bucket, pass the returned cursor to get the next page */
+func (fs *FileService) ListObjects(prefix string, cursor string) ([]models.StoredObject, string, error) {
+	query := url.Values{}
+	query.Set("prefix", prefix)
+	query.Set("cursor", cursor)
+
+	req, err := http.NewRequest("GET", fmt.Sprintf("%s/api/objects?%s", config.R2URL, query.Encode()), nil)
+	if err != nil {
+		return nil, "", err
+	}
+	req.Header.Set("X-Api-Key", config.R2APIKey)
+
+	client := &http.Client{Timeout: 30 * time.Second}
 	resp, err := client.Do(req)
 	if err != nil {
-		return "", err
+		return nil, "", fmt.Errorf("error communicating with storage server: %w", err)
 	}
 	defer resp.Body.Close()
 
 	if resp.StatusCode != http.StatusOK {
 		body, _ := io.ReadAll(resp.Body)
-		return "", fmt.Errorf("failed to upload file: %s", body)
+		return nil, "", fmt.Errorf("failed to list objects: error code: %d, response: %s", resp.StatusCode, string(body))
 	}
 
-	fileURL := fmt.Sprintf("%s/%s", config.R2PublicURL, fileKey)
+	var result struct {
+		Data struct {
+			Objects []struct {
+				Key          string    `json:"key"`
+				Size         int64     `json:"size"`
+				LastModified time.Time `json:"lastModified"`
+			} `json:"objects"`
+			NextCursor string `json:"nextCursor"`
+		} `json:"data"`
+	}
 
-	return fileURL, nil
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return nil, "", err
+	}
+
+	objects := make([]models.StoredObject, 0, len(result.Data.Objects))
+	for _, obj := range result.Data.Objects {
+		objects = append(objects, models.StoredObject{
+			Key:          obj.Key,
+			Size:         obj.Size,
+			LastModified: obj.LastModified,
+		})
+	}
+
+	return objects, result.Data.NextCursor, nil
+}
+
+/* Delete a file from the bucket through the file-upload service, missing files are not an error */
+func (fs *FileService) deleteObject(fileKey string) error {
+	req, err := http.NewRequest("DELETE", fmt.Sprintf("%s/%s", config.R2URL, fileKey), nil)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("X-Api-Key", config.R2APIKey)
+
+	client := &http.Client{Timeout: 30 * time.Second}
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK && resp.StatusCode != http.StatusNotFound {
+		body, _ := io.ReadAll(resp.Body)
+		return fmt.Errorf("failed to delete file: %s", body)
+	}
+
+	return nil
 }
 
 // upload custom social, but in the normal directory, but not set any stuff in the database
-func (fs *FileService) UploadCustomSocialMedia(fileName string, fileBytes []byte) (string, error) {
+func (fs *FileService) UploadCustomSocialMedia(uid uint, usage string, fileName string, fileBytes []byte) (string, error) {
 	fileExtension := utils.GetFileExtension(fileName)
 
 	allowedExtensions := map[string]bool{
@@ -209,27 +479,27 @@ func (fs *FileService) UploadCustomSocialMedia(fileName string, fileBytes []byte
 		return "", fmt.Errorf("file type %s is not allowed", fileExtension)
 	}
 
-	uuid := uuid.New().String()
-	fileKey := uuid + fileExtension
+	if err := fs.checkQuota(uid, int64(len(fileBytes))); err != nil {
+		return "", err
+	}
 
-	putURL := fmt.Sprintf("%s/%s", config.R2URL, fileKey)
-	req, err := http.NewRequest("PUT", putURL, bytes.NewReader(fileBytes))
-	if err != nil {
+	// The type is only informational here, the extension check above decides what is accepted
+	mediaType, _ := utils.DetectMediaType(fileBytes)
+
+	if err := fs.scanUpload(uid, usage, fileBytes, mediaType.Kind); err != nil {
 		return "", err
 	}
-	req.Header.Set("Content-Type", "application/octet-stream")
-	req.Header.Set("X-Api-Key", config.R2APIKey)
 
-	client := &http.Client{Timeout: 5 * time.Minute}
-	resp, err := client.Do(req)
-	if err != nil {
+	uuid := uuid.New().String()
+	fileKey := uuid + fileExtension
+
+	if err := fs.putObject(fileKey, fileBytes); err != nil {
 		return "", err
 	}
-	defer resp.Body.Close()
 
-	if resp.StatusCode != http.StatusOK {
-		body, _ := io.ReadAll(resp.Body)
-		return "", fmt.Errorf("failed to upload file: %s", body)
+	output := MediaOutput{Data: fileBytes, Format: mediaType.Format}
+	if _, err := fs.recordFile(uid, fileKey, usage, mediaType.Kind, output, nil); err != nil {
+		log.Printf("Error recording %s upload: %v", usage, err)
 	}
 
 	fileURL := fmt.Sprintf("%s/%s", config.R2PublicURL, fileKey)
@@ -252,8 +522,9 @@ func (fs *FileService) UploadDataExport(fileName string, fileBytes []byte, passw
 	uuid := uuid.New().String()
 	fileKey := "data_exports/" + uuid + fileExtension
 
-	putURL := fmt.Sprintf("%s/%s?type=temporary-protected&expiration=24&password=%s",
-		config.R2URL, fileKey, password)
+	// The password is only sent in a header, downloads go through signed single-use URLs
+	putURL := fmt.Sprintf("%s/%s?type=temporary-protected&expiration=24&single_use=true",
+		config.R2URL, fileKey)
 
 	req, err := http.NewRequest("PUT", putURL, bytes.NewReader(fileBytes))
 	if err != nil {
@@ -283,6 +554,31 @@ func (fs *FileService) UploadDataExport(fileName string, fileBytes []byte, passw
 	return fileURL, nil
 }
 
+/*
+Signed file-upload service URL for a stored file, valid for ttl. Single-use
+URLs stop working after the first download.
+*/
+func (fs *FileService) SignedFileURL(fileURL string, ttl time.Duration, singleUse bool) (string, time.Time, error) {
+	if config.FileSigningSecret == "" {
+		return "", time.Time{}, errors.New("file signing is not configured")
+	}
+
+	key, ok := storageKey(fileURL)
+	if !ok {
+		return "", time.Time{}, errors.New("file is not in storage")
+	}
+
+	nonce := ""
+	if singleUse {
+		nonce = uuid.New().String()
+	}
+
+	expiresAt := time.Now().Add(ttl)
+	query := utils.SignFileQuery(key, expiresAt, nonce, config.FileSigningSecret)
+
+	return fmt.Sprintf("%s/%s?%s", config.UploadURL, key, query), expiresAt, nil
+}
+
 // DeleteFileByURL deletes a file from R2 storage by its URL.
 func (fs *FileService) DeleteFileByURL(fileURL string) error {
 	fileKey := fileURL[len(config.R2URL)+1:]
@@ -338,16 +634,22 @@ func (fs *FileService) DeleteMediaURL(uid uint, fileURL string) error {
 		return err
 	}
 
+	field := ""
 	if profile.AvatarURL == fileURL {
 		profile.AvatarURL = ""
+		field = "avatar_url"
 	} else if profile.BackgroundURL == fileURL {
 		profile.BackgroundURL = ""
+		field = "background_url"
 	} else if profile.AudioURL == fileURL {
 		profile.AudioURL = ""
+		field = "audio_url"
 	} else if profile.CursorURL == fileURL {
 		profile.CursorURL = ""
+		field = "cursor_url"
 	} else if profile.BannerURL == fileURL {
 		profile.BannerURL = ""
+		field = "banner_url"
 	} else {
 		var userSocials []models.UserSocial
 		err = fs.DB.Where("uid = ? AND image_url = ?", uid, fileURL).Find(&userSocials).Error
@@ -366,6 +668,8 @@ func (fs *FileService) DeleteMediaURL(uid uint, fileURL string) error {
 		return fmt.Errorf("file URL not found in profile or user_socials")
 	}
 
+	delete(profile.MediaVariants, field)
+
 	err = fs.UpdateUserProfile(profile)
 	if err != nil {
 		return err
@@ -373,3 +677,593 @@ func (fs *FileService) DeleteMediaURL(uid uint, fileURL string) error {
 
 	return nil
 }
+
+/* Get the files in a user's media library with their storage usage */
+func (fs *FileService) GetLibrary(uid uint) ([]models.UserFile, *models.StorageUsage, error) {
+	var files []models.UserFile
+	err := fs.DB.Where("uid = ? AND parent_id IS NULL AND removed_at IS NULL", uid).
+		Preload("Variants").
+		Order("created_at DESC").
+		Find(&files).Error
+	if err != nil {
+		return nil, nil, err
+	}
+
+	usage, err := fs.GetStorageUsage(uid)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return files, usage, nil
+}
+
+func (fs *FileService) getLibraryFile(uid uint, fileID uint) (*models.UserFile, error) {
+	file := &models.UserFile{}
+	err := fs.DB.Where("id = ? AND uid = ? AND parent_id IS NULL AND removed_at IS NULL", fileID, uid).
+		Preload("Variants").
+		First(file).Error
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, errors.New("file not found")
+		}
+		return nil, err
+	}
+
+	return file, nil
+}
+
+/*
+Point a profile field at a file from the media library. Files are processed
+for the field they were uploaded for, so an avatar can't be reused as a
+banner.
+*/
+func (fs *FileService) UseLibraryFile(uid uint, fileID uint, field string) (string, error) {
+	file, err := fs.getLibraryFile(uid, fileID)
+	if err != nil {
+		return "", err
+	}
+
+	if file.Usage != field {
+		return "", errors.New("file was not uploaded for this field")
+	}
+
+	profile := &models.UserProfile{}
+	err = fs.DB.Where("uid = ?", uid).First(profile).Error
+	if err != nil {
+		return "", err
+	}
+
+	media := models.ProcessedMedia{
+		Source:   file.URL,
+		Variants: []models.MediaVariant{},
+	}
+
+	for _, variant := range file.Variants {
+		media.Variants = append(media.Variants, models.MediaVariant{
+			Label:  variant.Label,
+			URL:    variant.URL,
+			Format: variant.Format,
+			Width:  variant.Width,
+			Height: variant.Height,
+		})
+	}
+
+	if err := setProfileMedia(profile, field, file.URL, media); err != nil {
+		return "", err
+	}
+
+	if err := fs.UpdateUserProfile(profile); err != nil {
+		return "", err
+	}
+
+	return file.URL, nil
+}
+
+/*
+Remove a file from the media library. The profile stops using it right away,
+the objects are deleted by the garbage collector once the grace period has
+passed.
+*/
+func (fs *FileService) RemoveLibraryFile(uid uint, fileID uint) error {
+	file, err := fs.getLibraryFile(uid, fileID)
+	if err != nil {
+		return err
+	}
+
+	now := time.Now()
+	err = fs.DB.Model(&models.UserFile{}).
+		Where("id = ? OR parent_id = ?", file.ID, file.ID).
+		Update("removed_at", now).Error
+	if err != nil {
+		return err
+	}
+
+	profile := &models.UserProfile{}
+	err = fs.DB.Where("uid = ?", uid).First(profile).Error
+	if err != nil {
+		return err
+	}
+
+	changed := false
+	for _, field := range profileMediaFields {
+		if profileMediaURL(profile, field) == file.URL {
+			_ = setProfileMedia(profile, field, "", models.ProcessedMedia{})
+			delete(profile.MediaVariants, field)
+			changed = true
+		}
+	}
+
+	if changed {
+		return fs.UpdateUserProfile(profile)
+	}
+
+	return nil
+}
+
+func profileMediaURL(profile *models.UserProfile, field string) string {
+	switch field {
+	case "avatar_url":
+		return profile.AvatarURL
+	case "background_url":
+		return profile.BackgroundURL
+	case "audio_url":
+		return profile.AudioURL
+	case "cursor_url":
+		return profile.CursorURL
+	case "banner_url":
+		return profile.BannerURL
+	}
+	return ""
+}
+
+/*
+Delete removed files from the bucket. A file is kept while any profile,
+social or template still references it, templates copy profile URLs so a
+file can outlive its owner removing it. Returns the number of files deleted.
+*/
+func (fs *FileService) CollectGarbage() (int, error) {
+	var files []models.UserFile
+	err := fs.DB.Where("parent_id IS NULL AND removed_at IS NOT NULL AND removed_at < ?", time.Now().Add(-fileGCGracePeriod)).
+		Where("NOT EXISTS (SELECT 1 FROM user_profiles p WHERE p.avatar_url = user_files.url OR p.background_url = user_files.url OR p.audio_url = user_files.url OR p.cursor_url = user_files.url OR p.banner_url = user_files.url)").
+		Where("NOT EXISTS (SELECT 1 FROM user_socials s WHERE s.image_url = user_files.url)").
+		Where("NOT EXISTS (SELECT 1 FROM templates t WHERE t.banner_url = user_files.url OR t.template_data LIKE '%' || user_files.url || '%')").
+		Preload("Variants").
+		Limit(500).
+		Find(&files).Error
+	if err != nil {
+		return 0, err
+	}
+
+	deleted := 0
+	for _, file := range files {
+		failed := false
+		for _, variant := range file.Variants {
+			if err := fs.deleteObject(variant.Key); err != nil {
+				log.Printf("Error deleting file %s: %v", variant.Key, err)
+				failed = true
+			}
+		}
+
+		if err := fs.deleteObject(file.Key); err != nil {
+			log.Printf("Error deleting file %s: %v", file.Key, err)
+			failed = true
+		}
+
+		// Retry on the next run instead of losing track of the objects
+		if failed {
+			continue
+		}
+
+		if err := fs.DB.Where("id = ? OR parent_id = ?", file.ID, file.ID).Delete(&models.UserFile{}).Error; err != nil {
+			return deleted, err
+		}
+
+		deleted++
+	}
+
+	return deleted, nil
+}
+
+const (
+	uploadGrantTTL = time.Hour
+	// Staged uploads that were never completed are left to the storage reconcile job
+	uploadCompleteWindow = 24 * time.Hour
+	// Signed link the backend reads a staged upload with
+	stagedReadTTL = 5 * time.Minute
+
+	directUploadMaxSize      int64 = 75 << 20
+	directVideoUploadMaxSize int64 = 250 << 20
+)
+
+// Content types accepted for direct uploads and the extension the staged object gets
+var directUploadContentTypes = map[string]struct {
+	Kind      utils.MediaKind
+	Extension string
+}{
+	"image/png":  {utils.MediaKindImage, ".png"},
+	"image/jpeg": {utils.MediaKindImage, ".jpg"},
+	"image/gif":  {utils.MediaKindImage, ".gif"},
+	"image/webp": {utils.MediaKindImage, ".webp"},
+	"video/mp4":  {utils.MediaKindVideo, ".mp4"},
+	"video/webm": {utils.MediaKindVideo, ".webm"},
+	"audio/mpeg": {utils.MediaKindAudio, ".mp3"},
+	"audio/ogg":  {utils.MediaKindAudio, ".ogg"},
+	"audio/wav":  {utils.MediaKindAudio, ".wav"},
+}
+
+/*
+Issue a grant to upload a profile file directly to the file-upload service.
+The grant is scoped to a single key under uploads/{uid}/{grantID}/, the
+declared content type and a size limit, and expires after an hour. Large
+files can be uploaded in parts and resumed while the grant is valid.
+*/
+func (fs *FileService) CreateUploadGrant(uid uint, request models.UploadGrantRequest) (*models.UploadGrantResponse, error) {
+	if config.UploadGrantSecret == "" {
+		return nil, errors.New("direct uploads are not configured")
+	}
+
+	spec, ok := profileMediaSpecs[request.Usage]
+	if !ok {
+		return nil, errors.New("invalid usage")
+	}
+
+	contentType, ok := directUploadContentTypes[request.ContentType]
+	if !ok || !spec.accepts(contentType.Kind) {
+		return nil, utils.NewMediaError("%s files cannot be used for %s", request.ContentType, strings.TrimSuffix(request.Usage, "_url"))
+	}
+
+	maxSize := directUploadMaxSize
+	if contentType.Kind == utils.MediaKindVideo {
+		maxSize = directVideoUploadMaxSize
+	}
+
+	if request.Size <= 0 || request.Size > maxSize {
+		return nil, utils.NewMediaError("file size should not exceed %dMB", maxSize>>20)
+	}
+
+	if err := fs.checkQuota(uid, request.Size); err != nil {
+		return nil, err
+	}
+
+	grant := &models.UploadGrant{
+		ID:          uuid.New().String(),
+		UID:         uid,
+		Usage:       request.Usage,
+		MaxSize:     request.Size,
+		ContentType: request.ContentType,
+		ExpiresAt:   time.Now().Add(uploadGrantTTL),
+	}
+	grant.Prefix = fmt.Sprintf("uploads/%d/%s/", uid, grant.ID)
+	grant.Key = grant.Prefix + "original" + contentType.Extension
+
+	token, err := utils.SignUploadGrant(utils.UploadGrantClaims{
+		ID:          grant.ID,
+		Key:         grant.Key,
+		MaxSize:     grant.MaxSize,
+		ContentType: grant.ContentType,
+		ExpiresAt:   grant.ExpiresAt.Unix(),
+	}, config.UploadGrantSecret)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := fs.DB.Create(grant).Error; err != nil {
+		return nil, err
+	}
+
+	return &models.UploadGrantResponse{
+		ID:          grant.ID,
+		Token:       token,
+		UploadURL:   config.UploadURL + "/api/uploads",
+		Key:         grant.Key,
+		MaxSize:     grant.MaxSize,
+		ContentType: grant.ContentType,
+		ExpiresAt:   grant.ExpiresAt,
+	}, nil
+}
+
+/*
+Complete a direct upload once the browser has finished uploading. The staged
+object is processed like a regular upload and attached to the profile, then
+removed from the staging prefix.
+*/
+func (fs *FileService) CompleteUpload(uid uint, grantID string) (string, error) {
+	grant := &models.UploadGrant{}
+	err := fs.DB.Where("id = ? AND uid = ?", grantID, uid).First(grant).Error
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return "", errors.New("upload not found")
+		}
+		return "", err
+	}
+
+	if grant.CreatedAt.Before(time.Now().Add(-uploadCompleteWindow)) {
+		return "", errors.New("upload expired")
+	}
+
+	// Claim the grant so concurrent completions don't process the file twice
+	result := fs.DB.Model(&models.UploadGrant{}).
+		Where("id = ? AND completed_at IS NULL", grant.ID).
+		Update("completed_at", time.Now())
+	if result.Error != nil {
+		return "", result.Error
+	}
+	if result.RowsAffected == 0 {
+		return "", errors.New("upload already completed")
+	}
+
+	objects, _, err := fs.ListObjects(grant.Prefix, "")
+
+	var staged *models.StoredObject
+	for i := range objects {
+		if objects[i].Key == grant.Key {
+			staged = &objects[i]
+		}
+	}
+	if err == nil && staged == nil {
+		err = errors.New("file not uploaded")
+	}
+
+	fileURL := ""
+	if err == nil {
+		fileURL, err = fs.processStagedUpload(grant, *staged)
+	}
+
+	if err != nil && !utils.IsMediaError(err) {
+		// Release the grant so the client can retry
+		fs.DB.Model(&models.UploadGrant{}).Where("id = ?", grant.ID).Update("completed_at", nil)
+		return "", err
+	}
+
+	// The staged file is not needed anymore once it was processed or rejected
+	for _, obj := range objects {
+		if err := fs.deleteObject(obj.Key); err != nil {
+			log.Printf("Error deleting staged upload %s: %v", obj.Key, err)
+		}
+	}
+
+	return fileURL, err
+}
+
+/*
+Process a staged upload. Staged objects are only readable with a signature,
+so the file is streamed from the file-upload service with a single-use link
+and never goes through the public CDN. The read stops at the declared size.
+*/
+func (fs *FileService) processStagedUpload(grant *models.UploadGrant, staged models.StoredObject) (string, error) {
+	if staged.Size > grant.MaxSize {
+		return "", utils.NewMediaError("file is larger than declared")
+	}
+
+	signedURL, _, err := fs.SignedFileURL(fmt.Sprintf("%s/%s", config.R2PublicURL, staged.Key), stagedReadTTL, true)
+	if err != nil {
+		return "", err
+	}
+
+	client := &http.Client{Timeout: 5 * time.Minute}
+	resp, err := client.Get(signedURL)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("failed to read staged upload: status %d", resp.StatusCode)
+	}
+
+	if resp.ContentLength > grant.MaxSize {
+		return "", utils.NewMediaError("file is larger than declared")
+	}
+
+	data, err := io.ReadAll(io.LimitReader(resp.Body, grant.MaxSize+1))
+	if err != nil {
+		return "", err
+	}
+
+	if int64(len(data)) > grant.MaxSize {
+		return "", utils.NewMediaError("file is larger than declared")
+	}
+
+	return fs.UploadFile(grant.Usage, grant.UID, data)
+}
+
+/*
+Scan an upload before it is published. Flagged content is quarantined where
+only moderators can reach it and the uploader is reported, the upload itself
+is rejected with a media error.
+*/
+func (fs *FileService) scanUpload(uid uint, usage string, data []byte, kind utils.MediaKind) error {
+	if fs.ScanService == nil {
+		return nil
+	}
+
+	finding, err := fs.ScanService.Scan(data, kind)
+	if err != nil {
+		return err
+	}
+
+	if finding == nil {
+		return nil
+	}
+
+	log.Printf("Upload by %d for %s flagged by %s: %s (%.2f)", uid, usage, finding.Scanner, finding.Label, finding.Score)
+
+	if err := fs.quarantine(uid, usage, data, finding); err != nil {
+		log.Printf("Error quarantining upload by %d: %v", uid, err)
+	}
+
+	return utils.NewMediaError("this file was flagged by our content scanner and has been sent to moderation")
+}
+
+/* Keep a flagged upload for review and report the uploader */
+func (fs *FileService) quarantine(uid uint, usage string, data []byte, finding *ScanFinding) error {
+	mediaType, _ := utils.DetectMediaType(data)
+	fileKey := fmt.Sprintf("%s%d/%s%s", quarantineKeyPrefix, uid, uuid.New().String(), mediaType.Extension)
+
+	// Signed access keeps the file off the public CDN
+	if err := fs.putObjectWithAccess(fileKey, data, "signed"); err != nil {
+		return err
+	}
+
+	file := &models.QuarantinedFile{
+		UID:     uid,
+		Key:     fileKey,
+		Usage:   usage,
+		Size:    int64(len(data)),
+		Scanner: finding.Scanner,
+		Label:   finding.Label,
+		Score:   finding.Score,
+		Status:  models.QuarantinePending,
+	}
+
+	if err := fs.DB.Create(file).Error; err != nil {
+		return err
+	}
+
+	if fs.PunishService == nil {
+		return nil
+	}
+
+	details := fmt.Sprintf("Automatic report: a %s upload was flagged by the %s scanner (%s, score %.2f) and quarantined as file #%d.",
+		usage, finding.Scanner, finding.Label, finding.Score, file.ID)
+
+	report, err := fs.PunishService.CreateSystemReport(uid, finding.Reason, details)
+	if err != nil {
+		return err
+	}
+
+	return fs.DB.Model(file).Update("report_id", report.ID).Error
+}
+
+/*
+Quarantined files waiting for review, newest first. Flagged images and media
+come with a short-lived signed link to look at them, files flagged as malware
+never do.
+*/
+func (fs *FileService) GetQuarantinedFiles() ([]models.QuarantinedFile, error) {
+	var files []models.QuarantinedFile
+	err := fs.DB.Where("status = ?", models.QuarantinePending).
+		Order("created_at DESC").
+		Find(&files).Error
+	if err != nil {
+		return nil, err
+	}
+
+	for i, file := range files {
+		if file.Scanner == clamAVScannerName {
+			continue
+		}
+
+		previewURL, _, err := fs.SignedFileURL(fmt.Sprintf("%s/%s", config.R2PublicURL, file.Key), quarantinePreviewTTL, false)
+		if err != nil {
+			log.Printf("Error signing preview of quarantined file %d: %v", file.ID, err)
+			continue
+		}
+		files[i].PreviewURL = previewURL
+	}
+
+	return files, nil
+}
+
+/*
+Resolve a quarantined file. Releasing publishes it to the uploader's media
+library, where they can pick it again, deleting drops it for good. The
+quarantined object is removed either way.
+*/
+func (fs *FileService) ResolveQuarantinedFile(fileID uint, staffID uint, action string) error {
+	var file models.QuarantinedFile
+	err := fs.DB.Where("id = ? AND status = ?", fileID, models.QuarantinePending).First(&file).Error
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return errors.New("file not found")
+		}
+		return err
+	}
+
+	var status string
+	switch action {
+	case "release":
+		status = models.QuarantineReleased
+	case "delete":
+		status = models.QuarantineDeleted
+	default:
+		return errors.New("invalid action")
+	}
+
+	// Claim the file so two moderators resolving it at once don't both release it
+	result := fs.DB.Model(&models.QuarantinedFile{}).
+		Where("id = ? AND status = ?", file.ID, models.QuarantinePending).
+		Updates(map[string]interface{}{
+			"status":      status,
+			"reviewed_by": staffID,
+			"reviewed_at": time.Now(),
+		})
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected != 1 {
+		return errors.New("file not found")
+	}
+
+	if action == "release" {
+		if err := fs.releaseQuarantinedFile(&file); err != nil {
+			// Put the file back in the queue so the release can be retried
+			fs.DB.Model(&models.QuarantinedFile{}).Where("id = ?", file.ID).Updates(map[string]interface{}{
+				"status":      models.QuarantinePending,
+				"reviewed_by": 0,
+				"reviewed_at": nil,
+			})
+			return err
+		}
+	}
+
+	if err := fs.deleteObject(file.Key); err != nil {
+		log.Printf("Error deleting quarantined object %s: %v", file.Key, err)
+	}
+
+	return nil
+}
+
+/* Publish a quarantined file to the uploader's media library, profile media goes through processing again */
+func (fs *FileService) releaseQuarantinedFile(file *models.QuarantinedFile) error {
+	signedURL, _, err := fs.SignedFileURL(fmt.Sprintf("%s/%s", config.R2PublicURL, file.Key), quarantinePreviewTTL, false)
+	if err != nil {
+		return err
+	}
+
+	client := &http.Client{Timeout: 5 * time.Minute}
+	resp, err := client.Get(signedURL)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("failed to download quarantined file: status %d", resp.StatusCode)
+	}
+
+	data, err := io.ReadAll(io.LimitReader(resp.Body, maxQuarantineDownload))
+	if err != nil {
+		return err
+	}
+
+	if _, ok := profileMediaSpecs[file.Usage]; ok {
+		processed, err := fs.MediaService.Process(file.Usage, data)
+		if err != nil {
+			return err
+		}
+
+		_, _, err = fs.storeProcessed(file.UID, file.Usage, processed)
+		return err
+	}
+
+	mediaType, _ := utils.DetectMediaType(data)
+	fileKey := uuid.New().String() + mediaType.Extension
+	if err := fs.putObject(fileKey, data); err != nil {
+		return err
+	}
+
+	output := MediaOutput{Data: data, Format: mediaType.Format}
+	_, err = fs.recordFile(file.UID, fileKey, file.Usage, mediaType.Kind, output, nil)
+	return err
+}
@@ -0,0 +1,67 @@
+package services
+
+import (
+	"testing"
+
+	"github.com/hazebio/haze.bio_backend/config"
+	"github.com/hazebio/haze.bio_backend/models"
+)
+
+func TestCustomBadgeMediaKey(t *testing.T) {
+	publicURL := config.R2PublicURL
+	config.R2PublicURL = "https://cdn.example.com"
+	t.Cleanup(func() { config.R2PublicURL = publicURL })
+
+	tests := []struct {
+		url  string
+		want string
+	}{
+		{"https://cdn.example.com/custom_badges/7/badge.png", "custom_badges/7/badge.png"},
+		{"https://cdn.example.com/custom_badges/7/", ""},
+		{"https://cdn.example.com/custom_badges/7/.", ""},
+		{"https://cdn.example.com/custom_badges/7/../8/badge.png", ""},
+		{"https://cdn.example.com/custom_badges/7/%2e%2e/8/badge.png", ""},
+		{"https://cdn.example.com/custom_badges/7/a%2Fb.png", ""},
+		{"https://cdn.example.com/custom_badges/7/sub/badge.png", ""},
+		{"https://cdn.example.com/custom_badges/7//badge.png", ""},
+		{"https://cdn.example.com/custom_badges/7/badge.png?x=1", ""},
+		{"https://cdn.example.com/custom_badges/70/badge.png", ""},
+		{"https://cdn.example.com.evil.net/custom_badges/7/badge.png", ""},
+		{"http://cdn.example.com/custom_badges/7/badge.png", ""},
+	}
+
+	for _, tt := range tests {
+		key, ok := customBadgeMediaKey(7, tt.url)
+		if key != tt.want || ok != (tt.want != "") {
+			t.Errorf("customBadgeMediaKey(%q) = %q, %v, want %q", tt.url, key, ok, tt.want)
+		}
+	}
+}
+
+func TestResolveQuarantinedFileOnce(t *testing.T) {
+	db := newTestDB(t, &models.QuarantinedFile{})
+	client, _ := newFakeRedis(t)
+	fs := &FileService{DB: db, Client: client}
+
+	file := &models.QuarantinedFile{UID: 2, Key: "quarantine/2/file.png", Usage: "avatar_url", Status: models.QuarantinePending}
+	if err := db.Create(file).Error; err != nil {
+		t.Fatal(err)
+	}
+
+	if err := fs.ResolveQuarantinedFile(file.ID, 1, "delete"); err != nil {
+		t.Fatal(err)
+	}
+
+	err := fs.ResolveQuarantinedFile(file.ID, 3, "release")
+	if err == nil || err.Error() != "file not found" {
+		t.Fatalf("second resolution = %v, want file not found", err)
+	}
+
+	var stored models.QuarantinedFile
+	if err := db.First(&stored, file.ID).Error; err != nil {
+		t.Fatal(err)
+	}
+	if stored.Status != models.QuarantineDeleted || stored.ReviewedBy != 1 {
+		t.Fatalf("stored file = %s by %d, want deleted by 1", stored.Status, stored.ReviewedBy)
+	}
+}
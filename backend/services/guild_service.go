@@ -0,0 +1,401 @@
+package services
+
+import (
+	"encoding/json"
+	"errors"
+	"log"
+	"math"
+	"time"
+
+	"github.com/go-redis/redis"
+	"github.com/hazebio/haze.bio_backend/config"
+	"github.com/hazebio/haze.bio_backend/models"
+	"github.com/hazebio/haze.bio_backend/utils"
+	"gorm.io/gorm"
+)
+
+const (
+	guildsCacheKey = "discord_guilds"
+	guildsCacheTTL = 10 * time.Minute
+)
+
+type GuildService struct {
+	DB           *gorm.DB
+	Client       *redis.Client
+	EventService *EventService
+}
+
+func NewGuildService(db *gorm.DB, client *redis.Client) *GuildService {
+	return &GuildService{
+		DB:     db,
+		Client: client,
+	}
+}
+
+/* Get every configured guild, primary first */
+func (gs *GuildService) GetGuilds() ([]models.DiscordGuild, error) {
+	guilds := []models.DiscordGuild{}
+
+	cached, err := gs.Client.Get(guildsCacheKey).Result()
+	if err == nil && json.Unmarshal([]byte(cached), &guilds) == nil {
+		return guilds, nil
+	}
+
+	if err := gs.DB.Order("is_primary DESC, created_at ASC").Find(&guilds).Error; err != nil {
+		log.Println("Error getting discord guilds:", err)
+		return nil, err
+	}
+
+	if data, err := json.Marshal(guilds); err == nil {
+		gs.Client.Set(guildsCacheKey, data, guildsCacheTTL)
+	}
+
+	return guilds, nil
+}
+
+/* Get the enabled guilds, primary first */
+func (gs *GuildService) GetEnabledGuilds() ([]models.DiscordGuild, error) {
+	guilds, err := gs.GetGuilds()
+	if err != nil {
+		return nil, err
+	}
+
+	enabled := []models.DiscordGuild{}
+	for _, guild := range guilds {
+		if guild.IsEnabled() {
+			enabled = append(enabled, guild)
+		}
+	}
+
+	return enabled, nil
+}
+
+/* Get the configuration of a single guild */
+func (gs *GuildService) GetGuild(guildID string) (*models.DiscordGuild, error) {
+	guilds, err := gs.GetGuilds()
+	if err != nil {
+		return nil, err
+	}
+
+	for _, guild := range guilds {
+		if guild.GuildID == guildID {
+			return &guild, nil
+		}
+	}
+
+	return nil, errors.New("guild not found")
+}
+
+/* Get the primary guild */
+func (gs *GuildService) GetPrimaryGuild() (*models.DiscordGuild, error) {
+	guilds, err := gs.GetEnabledGuilds()
+	if err != nil {
+		return nil, err
+	}
+
+	for _, guild := range guilds {
+		if guild.IsPrimary {
+			return &guild, nil
+		}
+	}
+
+	return nil, errors.New("no primary guild configured")
+}
+
+/*
+Get the IDs of the enabled guilds, primary first. Falls back to the
+DISCORD_GUILD_ID env var so a database without guilds keeps working.
+*/
+func (gs *GuildService) GuildIDs() []string {
+	guildIDs := []string{}
+
+	guilds, err := gs.GetEnabledGuilds()
+	if err == nil {
+		for _, guild := range guilds {
+			guildIDs = append(guildIDs, guild.GuildID)
+		}
+	}
+
+	if len(guildIDs) == 0 && config.DiscordGuildID != "" {
+		guildIDs = append(guildIDs, config.DiscordGuildID)
+	}
+
+	return guildIDs
+}
+
+/* Check whether events from a guild should be handled */
+func (gs *GuildService) IsEnabled(guildID string) bool {
+	for _, id := range gs.GuildIDs() {
+		if id == guildID {
+			return true
+		}
+	}
+	return false
+}
+
+/* Add a guild */
+func (gs *GuildService) CreateGuild(guild *models.DiscordGuild) error {
+	if !isSnowflake(guild.GuildID) {
+		return errors.New("invalid guild id")
+	}
+
+	if guild.Name == "" {
+		return errors.New("guild name is required")
+	}
+
+	if err := gs.validateChannels(guild); err != nil {
+		return err
+	}
+
+	if err := gs.checkBoosterBadge(guild.GuildID, guild.BoosterBadge); err != nil {
+		return err
+	}
+
+	if guild.IsPrimary && !guild.IsEnabled() {
+		return errors.New("cannot disable the primary guild")
+	}
+
+	if guild.BoosterEditCredits < 0 {
+		return errors.New("invalid booster_edit_credits")
+	}
+
+	err := gs.DB.Transaction(func(tx *gorm.DB) error {
+		if guild.IsPrimary {
+			if err := tx.Model(&models.DiscordGuild{}).Where("is_primary = ?", true).Update("is_primary", false).Error; err != nil {
+				return err
+			}
+		}
+
+		return tx.Create(guild).Error
+	})
+	if err != nil {
+		if utils.IsError(err, utils.ErrDuplicateKey) {
+			return errors.New("guild already exists")
+		}
+		log.Println("Error creating discord guild:", err)
+		return err
+	}
+
+	gs.invalidate(guild.GuildID, false)
+	return nil
+}
+
+/* Update the configuration of a guild */
+func (gs *GuildService) UpdateGuild(guildID string, fields map[string]interface{}) error {
+	allowed := map[string]bool{
+		"name":                    true,
+		"is_primary":              true,
+		"enabled":                 true,
+		"registration_channel_id": true,
+		"notification_channel_id": true,
+		"moderation_channel_id":   true,
+		"linked_role_id":          true,
+		"booster_badge":           true,
+		"booster_edit_credits":    true,
+	}
+
+	updates := make(map[string]interface{})
+	for key, value := range fields {
+		if allowed[key] {
+			updates[key] = value
+		}
+	}
+
+	if len(updates) == 0 {
+		return errors.New("no valid fields to update")
+	}
+
+	for _, key := range []string{"registration_channel_id", "notification_channel_id", "moderation_channel_id", "linked_role_id"} {
+		if value, ok := updates[key]; ok {
+			id, isString := value.(string)
+			if !isString || (id != "" && !isSnowflake(id)) {
+				return errors.New("invalid " + key)
+			}
+		}
+	}
+
+	if badge, ok := updates["booster_badge"]; ok {
+		name, isString := badge.(string)
+		if !isString {
+			return errors.New("invalid booster_badge")
+		}
+		if err := gs.checkBoosterBadge(guildID, name); err != nil {
+			return err
+		}
+	}
+
+	if name, ok := updates["name"]; ok {
+		if name, isString := name.(string); !isString || name == "" {
+			return errors.New("guild name is required")
+		}
+	}
+
+	primary, setPrimary := updates["is_primary"].(bool)
+	if _, ok := updates["is_primary"]; ok && !setPrimary {
+		return errors.New("invalid is_primary")
+	}
+	if setPrimary && !primary {
+		return errors.New("set another guild as primary instead")
+	}
+
+	enabled, setEnabled := updates["enabled"].(bool)
+	if _, ok := updates["enabled"]; ok && !setEnabled {
+		return errors.New("invalid enabled")
+	}
+
+	if credits, ok := updates["booster_edit_credits"]; ok {
+		// JSON numbers are decoded as float64
+		number, isNumber := credits.(float64)
+		if !isNumber || number < 0 || number != math.Trunc(number) {
+			return errors.New("invalid booster_edit_credits")
+		}
+		updates["booster_edit_credits"] = int(number)
+	}
+
+	err := gs.DB.Transaction(func(tx *gorm.DB) error {
+		// The primary guild has to stay enabled, GetPrimaryGuild skips disabled guilds
+		if setPrimary || setEnabled {
+			var guild models.DiscordGuild
+			if err := tx.Where("guild_id = ?", guildID).First(&guild).Error; err != nil {
+				if errors.Is(err, gorm.ErrRecordNotFound) {
+					return errors.New("guild not found")
+				}
+				return err
+			}
+
+			isEnabled := guild.IsEnabled()
+			if setEnabled {
+				isEnabled = enabled
+			}
+
+			if (guild.IsPrimary || setPrimary) && !isEnabled {
+				return errors.New("cannot disable the primary guild")
+			}
+		}
+
+		if setPrimary {
+			if err := tx.Model(&models.DiscordGuild{}).Where("guild_id <> ?", guildID).Update("is_primary", false).Error; err != nil {
+				return err
+			}
+		}
+
+		result := tx.Model(&models.DiscordGuild{}).Where("guild_id = ?", guildID).Updates(updates)
+		if result.Error != nil {
+			return result.Error
+		}
+
+		if result.RowsAffected == 0 {
+			return errors.New("guild not found")
+		}
+
+		return nil
+	})
+	if err != nil {
+		if err.Error() != "guild not found" {
+			log.Println("Error updating discord guild:", err)
+		}
+		return err
+	}
+
+	gs.invalidate(guildID, false)
+	return nil
+}
+
+/* Remove a guild and its badge role mappings */
+func (gs *GuildService) DeleteGuild(guildID string) error {
+	guild, err := gs.GetGuild(guildID)
+	if err != nil {
+		return err
+	}
+
+	if guild.IsPrimary {
+		return errors.New("cannot delete the primary guild")
+	}
+
+	err = gs.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("guild_id = ?", guildID).Delete(&models.BadgeRole{}).Error; err != nil {
+			return err
+		}
+
+		return tx.Where("guild_id = ?", guildID).Delete(&models.DiscordGuild{}).Error
+	})
+	if err != nil {
+		log.Println("Error deleting discord guild:", err)
+		return err
+	}
+
+	gs.invalidate(guildID, true)
+	return nil
+}
+
+func (gs *GuildService) validateChannels(guild *models.DiscordGuild) error {
+	ids := map[string]string{
+		"registration_channel_id": guild.RegistrationChannelID,
+		"notification_channel_id": guild.NotificationChannelID,
+		"moderation_channel_id":   guild.ModerationChannelID,
+		"linked_role_id":          guild.LinkedRoleID,
+	}
+
+	for key, id := range ids {
+		if id != "" && !isSnowflake(id) {
+			return errors.New("invalid " + key)
+		}
+	}
+
+	return nil
+}
+
+/* A booster badge shared by two guilds would be taken away by whichever one the member isn't boosting */
+func (gs *GuildService) checkBoosterBadge(guildID, badgeName string) error {
+	if badgeName == "" {
+		return nil
+	}
+
+	var count int64
+	if err := gs.DB.Model(&models.Badge{}).Where("name = ?", badgeName).Count(&count).Error; err != nil {
+		return err
+	}
+	if count == 0 {
+		return errors.New("booster badge not found")
+	}
+
+	if err := gs.DB.Model(&models.DiscordGuild{}).Where("booster_badge = ? AND guild_id <> ?", badgeName, guildID).Count(&count).Error; err != nil {
+		return err
+	}
+	if count > 0 {
+		return errors.New("booster badge is already used by another guild")
+	}
+
+	return nil
+}
+
+func (gs *GuildService) invalidate(guildID string, deleted bool) {
+	gs.Client.Del(guildsCacheKey)
+
+	if gs.EventService == nil {
+		return
+	}
+
+	data := models.GuildUpdatedData{
+		GuildID: guildID,
+		Deleted: deleted,
+	}
+
+	if _, err := gs.EventService.Publish(models.EventGuildUpdated, data); err != nil {
+		log.Printf("Failed to publish guild updated event: %v", err)
+	}
+}
+
+func isSnowflake(id string) bool {
+	if len(id) < 15 || len(id) > 20 {
+		return false
+	}
+
+	for _, c := range id {
+		if c < '0' || c > '9' {
+			return false
+		}
+	}
+
+	return true
+}
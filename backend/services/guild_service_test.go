@@ -0,0 +1,108 @@
+package services
+
+import (
+	"testing"
+
+	"github.com/hazebio/haze.bio_backend/models"
+)
+
+const (
+	testPrimaryGuildID   = "100000000000000001"
+	testSecondaryGuildID = "100000000000000002"
+)
+
+func newTestGuildService(t *testing.T) *GuildService {
+	t.Helper()
+
+	db := newTestDB(t, &models.DiscordGuild{}, &models.Badge{})
+	client, _ := newFakeRedis(t)
+	gs := NewGuildService(db, client)
+
+	if err := gs.CreateGuild(&models.DiscordGuild{GuildID: testPrimaryGuildID, Name: "Main", IsPrimary: true}); err != nil {
+		t.Fatal(err)
+	}
+	return gs
+}
+
+func TestCreateGuildDisabled(t *testing.T) {
+	gs := newTestGuildService(t)
+
+	disabled := false
+	if err := gs.CreateGuild(&models.DiscordGuild{GuildID: testSecondaryGuildID, Name: "Second", Enabled: &disabled}); err != nil {
+		t.Fatal(err)
+	}
+
+	guild, err := gs.GetGuild(testSecondaryGuildID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if guild.IsEnabled() {
+		t.Fatal("guild created as disabled is enabled")
+	}
+
+	primary, err := gs.GetGuild(testPrimaryGuildID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !primary.IsEnabled() {
+		t.Fatal("guild created without enabled is disabled")
+	}
+
+	err = gs.CreateGuild(&models.DiscordGuild{GuildID: "100000000000000003", Name: "Third", IsPrimary: true, Enabled: &disabled})
+	if err == nil || err.Error() != "cannot disable the primary guild" {
+		t.Fatalf("create disabled primary guild = %v", err)
+	}
+}
+
+func TestUpdateGuild(t *testing.T) {
+	gs := newTestGuildService(t)
+
+	disabled := false
+	if err := gs.CreateGuild(&models.DiscordGuild{GuildID: testSecondaryGuildID, Name: "Second", Enabled: &disabled}); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name    string
+		guildID string
+		fields  map[string]interface{}
+		wantErr string
+	}{
+		{"disable primary", testPrimaryGuildID, map[string]interface{}{"enabled": false}, "cannot disable the primary guild"},
+		{"make disabled guild primary", testSecondaryGuildID, map[string]interface{}{"is_primary": true}, "cannot disable the primary guild"},
+		{"enabled not a bool", testSecondaryGuildID, map[string]interface{}{"enabled": "true"}, "invalid enabled"},
+		{"negative credits", testSecondaryGuildID, map[string]interface{}{"booster_edit_credits": float64(-1)}, "invalid booster_edit_credits"},
+		{"fractional credits", testSecondaryGuildID, map[string]interface{}{"booster_edit_credits": 1.5}, "invalid booster_edit_credits"},
+		{"credits not a number", testSecondaryGuildID, map[string]interface{}{"booster_edit_credits": "3"}, "invalid booster_edit_credits"},
+		{"enable and credits", testSecondaryGuildID, map[string]interface{}{"enabled": true, "booster_edit_credits": float64(2)}, ""},
+		{"disable secondary", testSecondaryGuildID, map[string]interface{}{"enabled": false}, ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := gs.UpdateGuild(tt.guildID, tt.fields)
+			if tt.wantErr == "" && err != nil {
+				t.Fatal(err)
+			}
+			if tt.wantErr != "" && (err == nil || err.Error() != tt.wantErr) {
+				t.Fatalf("update = %v, want %s", err, tt.wantErr)
+			}
+		})
+	}
+
+	primary, err := gs.GetGuild(testPrimaryGuildID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !primary.IsEnabled() || !primary.IsPrimary {
+		t.Fatal("primary guild was disabled or replaced")
+	}
+
+	secondary, err := gs.GetGuild(testSecondaryGuildID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if secondary.IsEnabled() || secondary.BoosterEditCredits != 2 {
+		t.Fatalf("secondary guild = enabled %v, credits %d", secondary.IsEnabled(), secondary.BoosterEditCredits)
+	}
+}
@@ -1,281 +1,471 @@
 package services
 
 import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
 	"fmt"
 	"image"
-	"image/color"
-	"image/draw"
-	"image/png"
 	"io"
-	"math"
+	"log"
 	"net/http"
-	"os"
-	"path/filepath"
-	"strconv"
+	"net/url"
+	"sort"
 	"strings"
+	"sync"
 	"time"
 
+	"github.com/go-redis/redis"
 	"github.com/golang/freetype/truetype"
 	"github.com/hazebio/haze.bio_backend/config"
 	"github.com/hazebio/haze.bio_backend/models"
-	"golang.org/x/image/font"
-	"golang.org/x/image/math/fixed"
+	"github.com/hazebio/haze.bio_backend/utils"
 )
 
+const (
+	// Bump when the layouts change so cached cards are rendered again
+	cardRendererVersion = "1"
+
+	cardCachePrefix = "card:"
+	// Set of the cached cards of a user or template, dropped when it changes
+	cardIndexPrefix = "card_index:"
+	cardCacheTTL    = 24 * time.Hour
+
+	// Decoded profile images are kept in memory between renders
+	cardSourceTTL        = 10 * time.Minute
+	cardSourceFailureTTL = time.Minute
+	cardSourceCacheBytes = 96 << 20
+	cardSourceMaxSide    = 1200
+)
+
+// Card is a rendered card, Hash identifies its inputs and is its ETag
+type Card struct {
+	Data        []byte
+	ContentType string
+	Hash        string
+}
+
+/*
+ImageService renders the Open Graph cards of profiles and templates. Cards
+are cached by a hash of everything they are rendered from, so an unchanged
+profile is never rendered twice.
+*/
 type ImageService struct {
 	BaseURL string
+	Client  *redis.Client
 }
 
-func NewImageService() *ImageService {
+func NewImageService(client *redis.Client) *ImageService {
 	return &ImageService{
 		BaseURL: config.R2PublicURL,
+		Client:  client,
 	}
 }
 
-func (is *ImageService) GenerateUserCard(user *models.User, profile *models.UserProfile) (string, error) {
-	width := 1200
-	height := 630
-	img := image.NewRGBA(image.Rect(0, 0, width, height))
-	var bgDrawn bool
-	if profile != nil && profile.BackgroundURL != "" {
-		bgImg, err := loadImageFromURL(profile.BackgroundURL)
-		if err == nil {
-			bgBounds := bgImg.Bounds()
-			bgWidth, bgHeight := bgBounds.Dx(), bgBounds.Dy()
-			scaleX := float64(width) / float64(bgWidth)
-			scaleY := float64(height) / float64(bgHeight)
-			scale := scaleX
-			if scaleY > scaleX {
-				scale = scaleY
-			}
-			scaledWidth := int(float64(bgWidth) * scale)
-			scaledHeight := int(float64(bgHeight) * scale)
-			offsetX := (width - scaledWidth) / 2
-			offsetY := (height - scaledHeight) / 2
-			for y := 0; y < height; y++ {
-				for x := 0; x < width; x++ {
-					srcX := int(float64(x-offsetX) / scale)
-					srcY := int(float64(y-offsetY) / scale)
-					if srcX >= 0 && srcX < bgWidth && srcY >= 0 && srcY < bgHeight {
-						img.Set(x, y, bgImg.At(srcX+bgBounds.Min.X, srcY+bgBounds.Min.Y))
-					} else {
-						if profile.BackgroundColor != "" {
-							r, _ := strconv.ParseUint(profile.BackgroundColor[1:3], 16, 8)
-							g, _ := strconv.ParseUint(profile.BackgroundColor[3:5], 16, 8)
-							b, _ := strconv.ParseUint(profile.BackgroundColor[5:7], 16, 8)
-							img.Set(x, y, color.RGBA{uint8(r), uint8(g), uint8(b), 255})
-						} else {
-							img.Set(x, y, color.RGBA{40, 40, 40, 255})
-						}
-					}
-				}
-			}
-			bgDrawn = true
-		}
+func userCardSubject(uid uint) string {
+	return fmt.Sprintf("user:%d", uid)
+}
+
+func templateCardSubject(templateID uint) string {
+	return fmt.Sprintf("template:%d", templateID)
+}
+
+/* Drop the cached cards of a user or template */
+func invalidateCards(client *redis.Client, subject string) {
+	indexKey := cardIndexPrefix + subject
+
+	keys, err := client.SMembers(indexKey).Result()
+	if err != nil {
+		log.Printf("Error reading cached cards of %s: %v", subject, err)
+		return
 	}
-	if !bgDrawn {
-		var bgColor color.RGBA
-		if profile != nil && profile.BackgroundColor != "" && len(profile.BackgroundColor) >= 7 {
-			r, _ := strconv.ParseUint(profile.BackgroundColor[1:3], 16, 8)
-			g, _ := strconv.ParseUint(profile.BackgroundColor[3:5], 16, 8)
-			b, _ := strconv.ParseUint(profile.BackgroundColor[5:7], 16, 8)
-			bgColor = color.RGBA{uint8(r), uint8(g), uint8(b), 255}
-		} else {
-			bgColor = color.RGBA{40, 40, 40, 255}
-		}
-		for y := 0; y < height; y++ {
-			for x := 0; x < width; x++ {
-				img.Set(x, y, bgColor)
-			}
-		}
+
+	client.Del(append(keys, indexKey)...)
+}
+
+/* Host profiles are served on, shown on cards as host/username */
+func profileHost() string {
+	if parsed, err := url.Parse(config.Origin); err == nil && parsed.Host != "" {
+		return parsed.Host
 	}
-	overlay := image.NewRGBA(image.Rect(0, 0, width, height))
-	draw.Draw(overlay, overlay.Bounds(), image.NewUniform(color.RGBA{0, 0, 0, 120}), image.Point{}, draw.Src)
-	draw.Draw(img, img.Bounds(), overlay, image.Point{}, draw.Over)
-	poppinsRegular, _ := loadPoppinsFont("regular")
-	poppinsBold, _ := loadPoppinsFont("bold")
-	avatarSize := 320
-	avatarX := width / 2
-	avatarY := height/2 - 80
-
-	if profile != nil && profile.AvatarURL != "" {
-		avatarImg, err := loadImageFromURL(profile.AvatarURL)
-		if err == nil {
-			avatarRadius := 24
-
-			if profile.AvatarShape == "rounded-full" {
-				avatarRadius = avatarSize / 2
-			} else if strings.HasPrefix(profile.AvatarShape, "rounded-") {
-				radiusStr := strings.TrimPrefix(profile.AvatarShape, "rounded-")
-				if radiusValue, err := strconv.Atoi(radiusStr); err == nil {
-					avatarRadius = radiusValue * 4
-				}
-			}
+	return "haze.bio"
+}
 
-			drawRoundedAvatar(img, avatarImg, avatarX-avatarSize/2, avatarY-avatarSize/2, avatarSize, avatarRadius)
-		} else {
-			avatarImg, err = loadImageFromURL(is.BaseURL + "/default_avatar.jpeg")
-			if err != nil {
-				return "", fmt.Errorf("failed to load default avatar: %w", err)
-			}
-			drawRoundedAvatar(img, avatarImg, avatarX-avatarSize/2, avatarY-avatarSize/2, avatarSize, avatarSize/2)
-		}
+/* Render the card of a user's profile */
+func (is *ImageService) GenerateUserCard(user *models.User, profile *models.UserProfile, layout string, format string) (*Card, error) {
+	if profile == nil {
+		profile = &models.UserProfile{}
 	}
-	displayNameSize := 64
-	displayNameFace := truetype.NewFace(poppinsBold, &truetype.Options{Size: float64(displayNameSize), DPI: 96, Hinting: font.HintingFull})
+
 	displayName := user.DisplayName
 	if displayName == "" {
 		displayName = user.Username
 	}
-	if len(displayName) > 20 {
-		displayName = displayName[:17] + "..."
-	}
-	displayNameWidth := font.MeasureString(displayNameFace, displayName).Ceil()
-	displayNameX := (width - displayNameWidth) / 2
-	displayNameY := avatarY + avatarSize/2 + 80
-	(&font.Drawer{Dst: img, Src: image.NewUniform(color.RGBA{0, 0, 0, 150}), Face: displayNameFace, Dot: fixed.P(displayNameX+2, displayNameY+2)}).DrawString(displayName)
-	(&font.Drawer{Dst: img, Src: image.NewUniform(color.RGBA{255, 255, 255, 255}), Face: displayNameFace, Dot: fixed.P(displayNameX, displayNameY)}).DrawString(displayName)
-	usernameSize := 36
-	usernameFace := truetype.NewFace(poppinsRegular, &truetype.Options{Size: float64(usernameSize), DPI: 96, Hinting: font.HintingFull})
-	username := "cutz.lol/" + user.Username
-	usernameWidth := font.MeasureString(usernameFace, username).Ceil()
-	usernameX := (width - usernameWidth) / 2
-	usernameY := displayNameY + 70
-	(&font.Drawer{Dst: img, Src: image.NewUniform(color.RGBA{0, 0, 0, 150}), Face: usernameFace, Dot: fixed.P(usernameX+2, usernameY+2)}).DrawString(username)
-	(&font.Drawer{Dst: img, Src: image.NewUniform(color.RGBA{180, 180, 180, 255}), Face: usernameFace, Dot: fixed.P(usernameX, usernameY)}).DrawString(username)
-	tmpDir := os.TempDir()
-	outputPath := filepath.Join(tmpDir, fmt.Sprintf("user_card_%d_%d.png", user.UID, time.Now().Unix()))
-	outputFile, err := os.Create(outputPath)
+
+	badges := visibleBadges(user)
+	badgeURLs := make([]string, 0, len(badges))
+	for _, badge := range badges {
+		badgeURLs = append(badgeURLs, badge.Badge.MediaURL)
+	}
+
+	spec := profileCardSpec(profile)
+	spec.Layout = layout
+	spec.Title = displayName
+	spec.Subtitle = profileHost() + "/" + user.Username
+	spec.Description = utils.StripHTML(profile.Description)
+	spec.BadgeURLs = badgeURLs
+
+	return is.renderCard(userCardSubject(user.UID), spec, format)
+}
+
+/* Render the preview card of a template, profile is the template applied to an empty profile */
+func (is *ImageService) GenerateTemplateCard(template *models.Template, profile *models.UserProfile, layout string, format string) (*Card, error) {
+	spec := profileCardSpec(profile)
+	if spec.AvatarURL == "" {
+		spec.AvatarURL = template.CreatorAvatar
+	}
+	if spec.BackgroundURL == "" {
+		spec.BackgroundURL = template.BannerURL
+	}
+
+	spec.Layout = layout
+	spec.Title = template.Name
+	spec.Subtitle = "Template by @" + template.CreatorUsername
+
+	details := []string{fmt.Sprintf("%d uses", template.Uses)}
+	if len(template.Tags) > 0 {
+		details = append(details, strings.Join(template.Tags, ", "))
+	}
+	spec.Description = strings.Join(details, " · ")
+
+	return is.renderCard(templateCardSubject(template.ID), spec, format)
+}
+
+/* Badges a user shows on their profile, in their order */
+func visibleBadges(user *models.User) []models.UserBadge {
+	badges := make([]models.UserBadge, 0, len(user.Badges))
+	for _, badge := range user.Badges {
+		if badge.Hidden || badge.Badge.MediaURL == "" || (badge.ExpiresAt != nil && badge.ExpiresAt.Before(time.Now())) {
+			continue
+		}
+		badges = append(badges, badge)
+	}
+	sort.SliceStable(badges, func(i, j int) bool { return badges[i].Sort < badges[j].Sort })
+
+	return badges
+}
+
+func profileCardSpec(profile *models.UserProfile) *cardSpec {
+	return &cardSpec{
+		AvatarURL:     profile.AvatarURL,
+		AvatarShape:   profile.AvatarShape,
+		BackgroundURL: profile.BackgroundURL,
+		Background:    profile.BackgroundColor,
+		GradientFrom:  profile.GradientFromColor,
+		GradientTo:    profile.GradientToColor,
+		AccentColor:   profile.AccentColor,
+		TextColor:     profile.TextColor,
+		Font:          profile.TextFont,
+	}
+}
+
+/* Render a card or serve it from the cache */
+func (is *ImageService) renderCard(subject string, spec *cardSpec, format string) (*Card, error) {
+	if !cardLayouts[spec.Layout] {
+		return nil, errors.New("invalid layout")
+	}
+
+	contentType := "image/png"
+	switch format {
+	case CardFormatPNG:
+	case CardFormatSVG:
+		contentType = "image/svg+xml"
+	default:
+		return nil, errors.New("invalid format")
+	}
+
+	specJSON, err := json.Marshal(spec)
+	if err != nil {
+		return nil, err
+	}
+
+	sum := sha256.Sum256([]byte(cardRendererVersion + "\n" + format + "\n" + string(specJSON)))
+	card := &Card{ContentType: contentType, Hash: hex.EncodeToString(sum[:])}
+	cacheKey := cardCachePrefix + card.Hash
+
+	if cached, err := is.Client.Get(cacheKey).Bytes(); err == nil {
+		card.Data = cached
+		return card, nil
+	} else if err != redis.Nil {
+		log.Printf("Error reading card cache: %v", err)
+	}
+
+	fonts, err := loadCardFont(spec.Font)
 	if err != nil {
-		return "", fmt.Errorf("failed to create output file: %w", err)
+		return nil, fmt.Errorf("failed to load font: %w", err)
 	}
-	defer outputFile.Close()
-	if err := png.Encode(outputFile, img); err != nil {
-		return "", fmt.Errorf("failed to encode image: %w", err)
+
+	scene := layoutCard(spec, fonts)
+	rasters := is.loadRasters(scene)
+
+	if format == CardFormatSVG {
+		card.Data, err = renderCardSVG(scene, rasters)
+	} else {
+		card.Data, err = renderCardPNG(scene, rasters)
 	}
-	return outputPath, nil
+	if err != nil {
+		return nil, err
+	}
+
+	pipe := is.Client.TxPipeline()
+	pipe.Set(cacheKey, card.Data, cardCacheTTL)
+	pipe.SAdd(cardIndexPrefix+subject, cacheKey)
+	pipe.Expire(cardIndexPrefix+subject, cardCacheTTL)
+	if _, err := pipe.Exec(); err != nil {
+		log.Printf("Error caching card: %v", err)
+	}
+
+	return card, nil
+}
+
+/* Load and scale the images of a scene, images that fail to load are left out */
+func (is *ImageService) loadRasters(scene *cardScene) *cardRasters {
+	rasters := &cardRasters{Badges: make([]image.Image, len(scene.Badges))}
+
+	var wg sync.WaitGroup
+	load := func(url string, width int, height int, contain bool, fallback string, dst *image.Image) {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+
+			src, err := loadSourceImage(url)
+			if err != nil && fallback != "" {
+				src, err = loadSourceImage(fallback)
+			}
+			if err != nil {
+				return
+			}
+
+			*dst = scaleImage(src, width, height, contain)
+		}()
+	}
+
+	if scene.BackgroundImage != "" {
+		load(scene.BackgroundImage, cardWidth, cardHeight, false, "", &rasters.Background)
+	}
+
+	if scene.Avatar != nil {
+		load(scene.Avatar.URL, scene.Avatar.Rect.Dx(), scene.Avatar.Rect.Dy(), false, is.BaseURL+"/default_avatar.jpeg", &rasters.Avatar)
+	}
+
+	for i, badge := range scene.Badges {
+		load(badge.URL, badge.Rect.Dx(), badge.Rect.Dy(), true, "", &rasters.Badges[i])
+	}
+
+	wg.Wait()
+	return rasters
+}
+
+// cardFont is the typeface a card is set in. Family is the name SVG cards
+// ask for, PNG cards are rasterized with the regular and bold faces.
+type cardFont struct {
+	Family  string
+	regular *truetype.Font
+	bold    *truetype.Font
+}
+
+type cardFontSource struct {
+	Family  string
+	Regular string
+	Bold    string
 }
 
-func drawRoundedAvatar(dst draw.Image, src image.Image, x, y, size, radius int) {
-	srcBounds := src.Bounds()
-	srcWidth, srcHeight := srcBounds.Dx(), srcBounds.Dy()
+const googleFontsURL = "https://github.com/google/fonts/raw/main/ofl/"
 
-	destRect := image.Rect(x, y, x+size, y+size)
+// TTF sources of the profile fonts. Fonts without a source are rasterized
+// with Poppins, SVG cards still name them.
+var cardFontSources = map[string]cardFontSource{
+	"poppins": {
+		Family:  "Poppins",
+		Regular: googleFontsURL + "poppins/Poppins-Regular.ttf",
+		Bold:    googleFontsURL + "poppins/Poppins-Bold.ttf",
+	},
+	"anton": {
+		Family:  "Anton",
+		Regular: googleFontsURL + "anton/Anton-Regular.ttf",
+		Bold:    googleFontsURL + "anton/Anton-Regular.ttf",
+	},
+	"jetbrains-mono": {
+		Family:  "JetBrains Mono",
+		Regular: googleFontsURL + "jetbrainsmono/JetBrainsMono%5Bwght%5D.ttf",
+		Bold:    googleFontsURL + "jetbrainsmono/JetBrainsMono%5Bwght%5D.ttf",
+	},
+	"chillax":          {Family: "Chillax"},
+	"minecraft":        {Family: "Minecraft"},
+	"drippy":           {Family: "Drippy"},
+	"grand-theft-auto": {Family: "Pricedown Bl"},
+}
 
-	mask := image.NewRGBA(image.Rect(0, 0, size, size))
+var fontCache = struct {
+	sync.Mutex
+	fonts map[string]*truetype.Font
+}{fonts: make(map[string]*truetype.Font)}
 
-	scaleX := float64(size) / float64(srcWidth)
-	scaleY := float64(size) / float64(srcHeight)
-	scale := scaleX
-	if scaleY < scaleX {
-		scale = scaleY
+/* Faces for a profile font, falling back to Poppins */
+func loadCardFont(name string) (*cardFont, error) {
+	fallback := cardFontSources["poppins"]
+	source, ok := cardFontSources[name]
+	if !ok {
+		source = fallback
 	}
 
-	scaledWidth := int(float64(srcWidth) * scale)
-	scaledHeight := int(float64(srcHeight) * scale)
-	offsetX := (size - scaledWidth) / 2
-	offsetY := (size - scaledHeight) / 2
+	result := &cardFont{Family: source.Family}
 
-	for iy := 0; iy < size; iy++ {
-		for ix := 0; ix < size; ix++ {
-			mask.Set(ix, iy, color.RGBA{0, 0, 0, 0})
+	var err error
+	if source.Regular != "" {
+		result.regular, err = loadFont(source.Regular)
+		if err == nil {
+			result.bold, err = loadFont(source.Bold)
+		}
+		if err != nil {
+			log.Printf("Error loading font %s, using Poppins: %v", name, err)
 		}
 	}
 
-	fullRounded := radius >= size/2
-
-	for iy := 0; iy < size; iy++ {
-		for ix := 0; ix < size; ix++ {
-			dx := float64(ix - size/2)
-			dy := float64(iy - size/2)
-			distance := math.Sqrt(dx*dx + dy*dy)
-
-			if fullRounded {
-				if distance <= float64(size/2) {
-					srcX := int(float64(ix-offsetX) / scale)
-					srcY := int(float64(iy-offsetY) / scale)
-
-					if srcX >= 0 && srcX < srcWidth && srcY >= 0 && srcY < srcHeight {
-						mask.Set(ix, iy, src.At(srcX+srcBounds.Min.X, srcY+srcBounds.Min.Y))
-					}
-				}
-			} else {
-				inRadius := false
-
-				inCorner := false
-				cornerX, cornerY := 0, 0
-
-				if ix < radius && iy < radius {
-					inCorner = true
-					cornerX, cornerY = radius, radius
-				} else if ix >= (size-radius) && iy < radius {
-					inCorner = true
-					cornerX, cornerY = size-radius, radius
-				} else if ix < radius && iy >= (size-radius) {
-					inCorner = true
-					cornerX, cornerY = radius, size-radius
-				} else if ix >= (size-radius) && iy >= (size-radius) {
-					inCorner = true
-					cornerX, cornerY = size-radius, size-radius
-				}
-
-				if inCorner {
-					cornerDx := float64(ix - cornerX)
-					cornerDy := float64(iy - cornerY)
-					cornerDistance := math.Sqrt(cornerDx*cornerDx + cornerDy*cornerDy)
-
-					if cornerDistance <= float64(radius) {
-						inRadius = true
-					}
-				} else {
-					inRadius = true
-				}
-
-				if inRadius {
-					srcX := int(float64(ix-offsetX) / scale)
-					srcY := int(float64(iy-offsetY) / scale)
-
-					if srcX >= 0 && srcX < srcWidth && srcY >= 0 && srcY < srcHeight {
-						mask.Set(ix, iy, src.At(srcX+srcBounds.Min.X, srcY+srcBounds.Min.Y))
-					}
-				}
-			}
+	if result.regular == nil || result.bold == nil {
+		if result.regular, err = loadFont(fallback.Regular); err != nil {
+			return nil, err
+		}
+		if result.bold, err = loadFont(fallback.Bold); err != nil {
+			return nil, err
 		}
 	}
 
-	draw.Draw(dst, destRect, mask, image.Point{0, 0}, draw.Over)
+	return result, nil
 }
 
-var fontCache = make(map[string][]byte)
+func loadFont(url string) (*truetype.Font, error) {
+	fontCache.Lock()
+	defer fontCache.Unlock()
 
-func loadPoppinsFont(style string) (*truetype.Font, error) {
-	capitalizedStyle := strings.ToUpper(style[:1]) + style[1:]
-	if cachedFontData, ok := fontCache[capitalizedStyle]; ok {
-		return truetype.Parse(cachedFontData)
+	if cached, ok := fontCache.fonts[url]; ok {
+		return cached, nil
 	}
-	url := fmt.Sprintf("https://github.com/google/fonts/raw/main/ofl/poppins/Poppins-%s.ttf", capitalizedStyle)
+
 	client := &http.Client{Timeout: 10 * time.Second}
 	resp, err := client.Get(url)
 	if err != nil {
 		return nil, err
 	}
 	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("unexpected status %d", resp.StatusCode)
+	}
+
 	fontData, err := io.ReadAll(resp.Body)
 	if err != nil {
 		return nil, err
 	}
-	fontCache[capitalizedStyle] = fontData
-	return truetype.Parse(fontData)
+
+	parsed, err := truetype.Parse(fontData)
+	if err != nil {
+		return nil, err
+	}
+
+	fontCache.fonts[url] = parsed
+	return parsed, nil
+}
+
+type sourceImage struct {
+	img       image.Image
+	err       error
+	size      int
+	expiresAt time.Time
+}
+
+// Decoded images by URL, bounded by the memory their pixels take
+var sourceImages = struct {
+	sync.Mutex
+	images map[string]*sourceImage
+	size   int
+}{images: make(map[string]*sourceImage)}
+
+/* Fetch and decode an image, downscaled so cards never hold more pixels than they draw */
+func loadSourceImage(url string) (image.Image, error) {
+	sourceImages.Lock()
+	cached, ok := sourceImages.images[url]
+	sourceImages.Unlock()
+	if ok && time.Now().Before(cached.expiresAt) {
+		return cached.img, cached.err
+	}
+
+	entry := &sourceImage{expiresAt: time.Now().Add(cardSourceTTL)}
+	entry.img, entry.err = loadImageFromURL(url)
+	if entry.err != nil {
+		entry.expiresAt = time.Now().Add(cardSourceFailureTTL)
+	} else {
+		bounds := entry.img.Bounds()
+		if longest := max(bounds.Dx(), bounds.Dy()); longest > cardSourceMaxSide {
+			width := bounds.Dx() * cardSourceMaxSide / longest
+			height := bounds.Dy() * cardSourceMaxSide / longest
+			entry.img = scaleImage(entry.img, max(width, 1), max(height, 1), false)
+		}
+		bounds = entry.img.Bounds()
+		entry.size = bounds.Dx() * bounds.Dy() * 4
+	}
+
+	sourceImages.Lock()
+	defer sourceImages.Unlock()
+
+	if previous, ok := sourceImages.images[url]; ok {
+		sourceImages.size -= previous.size
+		delete(sourceImages.images, url)
+	}
+
+	// Expired images go first, then whatever expires soonest
+	for sourceImages.size+entry.size > cardSourceCacheBytes && len(sourceImages.images) > 0 {
+		var oldestURL string
+		var oldest *sourceImage
+		for u, img := range sourceImages.images {
+			if oldest == nil || img.expiresAt.Before(oldest.expiresAt) {
+				oldestURL, oldest = u, img
+			}
+		}
+		sourceImages.size -= oldest.size
+		delete(sourceImages.images, oldestURL)
+	}
+
+	sourceImages.images[url] = entry
+	sourceImages.size += entry.size
+
+	return entry.img, entry.err
 }
 
+// Profile images the card is rendered from may be hosted anywhere, only our
+// own bucket is fetched without the SSRF protections
+var cardImageClient = utils.NewSafeHTTPClient(5*time.Second, 3)
+
+const cardImageMaxBytes = 25 << 20
+
 func loadImageFromURL(url string) (image.Image, error) {
-	client := &http.Client{Timeout: 5 * time.Second}
+	client := cardImageClient
+	if config.R2PublicURL != "" && strings.HasPrefix(url, config.R2PublicURL+"/") {
+		client = &http.Client{Timeout: 5 * time.Second}
+	}
+
 	resp, err := client.Get(url)
 	if err != nil {
 		return nil, err
 	}
 	defer resp.Body.Close()
-	img, _, err := image.Decode(resp.Body)
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("unexpected status %d", resp.StatusCode)
+	}
+
+	img, _, err := image.Decode(io.LimitReader(resp.Body, cardImageMaxBytes))
 	if err != nil {
 		return nil, err
 	}
@@ -49,7 +49,7 @@ func (is *InviteService) CreateInviteCode(createdBy uint, maxUses int, expiresIn
 	inviteCode := &models.InviteCode{
 		Code:      code,
 		CreatedBy: createdBy,
-		MaxUses:   maxUses,
+		MaxUses:   &maxUses,
 		ExpiresAt: expiresAt,
 	}
 
@@ -0,0 +1,207 @@
+package services
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/go-redis/redis"
+	"github.com/hazebio/haze.bio_backend/config"
+	"github.com/hazebio/haze.bio_backend/models"
+	"github.com/hazebio/haze.bio_backend/utils"
+	"golang.org/x/sync/singleflight"
+	"gorm.io/gorm"
+)
+
+const (
+	MediaProxyCachePrefix = "media_proxy:"
+	mediaProxyKeyPrefix   = "proxy/"
+
+	mediaProxyMaxBytes     = 25 << 20
+	mediaProxyFetchTimeout = 15 * time.Second
+	mediaProxyMaxRedirects = 3
+
+	// Copies are fetched again once their cache entry expires
+	mediaProxyCacheTTL = 7 * 24 * time.Hour
+	// Failed fetches are not retried for a while so broken links can't be used to hammer a host
+	mediaProxyFailureTTL = 10 * time.Minute
+)
+
+var errMediaFlagged = errors.New("media was flagged by the content scanner")
+
+// Profile fields that may point at externally hosted media
+var proxiedProfileFields = []string{"avatar_url", "background_url", "cursor_url", "banner_url", "decoration_url"}
+
+/*
+MediaProxyService serves externally hosted profile media from our own
+storage, so visitors never connect to third parties. External media is
+fetched once with SSRF protections, processed and scanned like an upload of
+the profile owner and cached in the bucket under proxy/.
+*/
+type MediaProxyService struct {
+	DB          *gorm.DB
+	Client      *redis.Client
+	FileService *FileService
+	HTTPClient  *http.Client
+
+	fetches singleflight.Group
+}
+
+func NewMediaProxyService(db *gorm.DB, client *redis.Client) *MediaProxyService {
+	return &MediaProxyService{
+		DB:          db,
+		Client:      client,
+		FileService: NewFileService(db, client),
+		HTTPClient:  utils.NewSafeHTTPClient(mediaProxyFetchTimeout, mediaProxyMaxRedirects),
+	}
+}
+
+/* Rewrite an external media URL to its media proxy URL, our own and already proxied URLs are kept */
+func ProxyMediaURL(rawURL string) string {
+	if config.APIPublicURL == "" || !isExternalMediaURL(rawURL) {
+		return rawURL
+	}
+
+	return config.APIPublicURL + "/api" + utils.MediaProxyPath(rawURL, config.SecretKey)
+}
+
+func isExternalMediaURL(rawURL string) bool {
+	if !strings.HasPrefix(rawURL, "https://") && !strings.HasPrefix(rawURL, "http://") {
+		return false
+	}
+
+	if config.R2PublicURL != "" && strings.HasPrefix(rawURL, config.R2PublicURL+"/") {
+		return false
+	}
+
+	return !strings.HasPrefix(rawURL, config.APIPublicURL+"/api/media/proxy/")
+}
+
+/* Rewrite the external media URLs in a set of profile field updates */
+func proxyProfileFields(fields map[string]interface{}) {
+	for _, field := range proxiedProfileFields {
+		if value, ok := fields[field].(string); ok {
+			fields[field] = ProxyMediaURL(value)
+		}
+	}
+}
+
+/* Public URL of our copy of the media behind a proxy path, fetching it on first use */
+func (mps *MediaProxyService) Resolve(signature string, target string) (string, error) {
+	rawURL, ok := utils.ParseMediaProxyPath(signature, target, config.SecretKey)
+	if !ok {
+		return "", errors.New("invalid signature")
+	}
+
+	sum := sha256.Sum256([]byte(rawURL))
+	hash := hex.EncodeToString(sum[:])
+	cacheKey := MediaProxyCachePrefix + hash
+
+	cached, err := mps.Client.Get(cacheKey).Result()
+	if err == nil {
+		if cached == "" {
+			return "", errors.New("media unavailable")
+		}
+		return cached, nil
+	} else if err != redis.Nil {
+		log.Printf("Error reading media proxy cache: %v", err)
+	}
+
+	// Visitors loading the same profile at once share a single fetch
+	result, err, _ := mps.fetches.Do(hash, func() (interface{}, error) {
+		fileURL, err := mps.fetch(rawURL, hash)
+		if err != nil {
+			log.Printf("Error proxying %s: %v", rawURL, err)
+
+			// Flagged media stays unavailable instead of being fetched and reported again
+			ttl := mediaProxyFailureTTL
+			if errors.Is(err, errMediaFlagged) {
+				ttl = mediaProxyCacheTTL
+			}
+			mps.Client.Set(cacheKey, "", ttl)
+			return "", errors.New("media unavailable")
+		}
+
+		mps.Client.Set(cacheKey, fileURL, mediaProxyCacheTTL)
+		return fileURL, nil
+	})
+	if err != nil {
+		return "", err
+	}
+
+	return result.(string), nil
+}
+
+/* Fetch external media, process and scan it and store the result in the bucket */
+func (mps *MediaProxyService) fetch(rawURL string, hash string) (string, error) {
+	uid, usage, err := mps.owner(rawURL)
+	if err != nil {
+		return "", err
+	}
+
+	data, err := utils.FetchPublicURL(mps.HTTPClient, rawURL, mediaProxyMaxBytes)
+	if err != nil {
+		return "", err
+	}
+
+	processed, err := mps.FileService.MediaService.ProcessExternal(data)
+	if err != nil {
+		return "", err
+	}
+
+	if err := mps.FileService.scanUpload(uid, usage, data, processed.Kind); err != nil {
+		if utils.IsMediaError(err) {
+			return "", errMediaFlagged
+		}
+		return "", err
+	}
+
+	fileKey := mediaProxyKeyPrefix + hash + processed.Original.Extension
+	if err := mps.FileService.putObject(fileKey, processed.Original.Data); err != nil {
+		return "", err
+	}
+
+	return fmt.Sprintf("%s/%s", config.R2PublicURL, fileKey), nil
+}
+
+/*
+Find a user whose profile or socials use the media, scans of it are
+attributed to them. Media nobody uses is not fetched at all.
+*/
+func (mps *MediaProxyService) owner(rawURL string) (uint, string, error) {
+	proxyURL := ProxyMediaURL(rawURL)
+
+	var uids []uint
+	for _, field := range proxiedProfileFields {
+		err := mps.DB.Model(&models.UserProfile{}).
+			Where(fmt.Sprintf("%s = ?", field), proxyURL).
+			Order("uid ASC").
+			Limit(1).
+			Pluck("uid", &uids).Error
+		if err != nil {
+			return 0, "", err
+		}
+		if len(uids) > 0 {
+			return uids[0], field, nil
+		}
+	}
+
+	err := mps.DB.Model(&models.UserSocial{}).
+		Where("image_url = ?", proxyURL).
+		Order("uid ASC").
+		Limit(1).
+		Pluck("uid", &uids).Error
+	if err != nil {
+		return 0, "", err
+	}
+	if len(uids) > 0 {
+		return uids[0], "social_image", nil
+	}
+
+	return 0, "", errors.New("media is not used by any profile")
+}
@@ -0,0 +1,581 @@
+package services
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"image"
+	"image/jpeg"
+	"image/png"
+	"log"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/hazebio/haze.bio_backend/config"
+	"github.com/hazebio/haze.bio_backend/utils"
+	xdraw "golang.org/x/image/draw"
+)
+
+const (
+	mediaProcessTimeout = 2 * time.Minute
+	// Larger images are rejected before decoding to avoid decompression bombs
+	maxSourcePixels = 40_000_000
+)
+
+// mediaSpec describes how uploads for one profile field are processed
+type mediaSpec struct {
+	Kinds     []utils.MediaKind
+	MaxWidth  int
+	MaxHeight int
+	// Crop to a centered square, used for avatars
+	Square bool
+	// Widths of the resized variants, widths above the image's own are skipped
+	Widths []int
+}
+
+var profileMediaSpecs = map[string]mediaSpec{
+	"avatar_url": {
+		Kinds:     []utils.MediaKind{utils.MediaKindImage},
+		MaxWidth:  512,
+		MaxHeight: 512,
+		Square:    true,
+		Widths:    []int{128, 256},
+	},
+	"banner_url": {
+		Kinds:     []utils.MediaKind{utils.MediaKindImage},
+		MaxWidth:  1920,
+		MaxHeight: 1080,
+		Widths:    []int{640, 1280},
+	},
+	"background_url": {
+		Kinds:     []utils.MediaKind{utils.MediaKindImage, utils.MediaKindVideo},
+		MaxWidth:  2560,
+		MaxHeight: 1440,
+		Widths:    []int{1280, 1920},
+	},
+	"cursor_url": {
+		Kinds:     []utils.MediaKind{utils.MediaKindImage},
+		MaxWidth:  128,
+		MaxHeight: 128,
+	},
+	"audio_url": {
+		Kinds: []utils.MediaKind{utils.MediaKindAudio},
+	},
+}
+
+// External media fetched by the media proxy is processed like an upload
+// without variants
+var externalMediaSpec = mediaSpec{
+	Kinds:     []utils.MediaKind{utils.MediaKindImage, utils.MediaKindVideo},
+	MaxWidth:  2560,
+	MaxHeight: 1440,
+}
+
+func (spec mediaSpec) accepts(kind utils.MediaKind) bool {
+	for _, k := range spec.Kinds {
+		if k == kind {
+			return true
+		}
+	}
+	return false
+}
+
+// MediaOutput is one file produced by processing an upload
+type MediaOutput struct {
+	Label     string
+	Data      []byte
+	Format    string
+	Extension string
+	Width     int
+	Height    int
+}
+
+// ProcessedUpload is the result of processing an upload. Original replaces
+// the uploaded file and doubles as the fallback for browsers without WebP.
+type ProcessedUpload struct {
+	Kind     utils.MediaKind
+	Original MediaOutput
+	Variants []MediaOutput
+}
+
+type MediaService struct {
+	FFmpegPath string
+
+	ffmpegOnce      sync.Once
+	ffmpegAvailable bool
+	// Limits how many uploads are processed at once
+	slots chan struct{}
+}
+
+func NewMediaService() *MediaService {
+	return &MediaService{
+		FFmpegPath: config.FFmpegPath,
+		slots:      make(chan struct{}, runtime.NumCPU()),
+	}
+}
+
+/*
+Validate an upload by its content and turn it into the files that are
+stored: images are re-encoded without metadata and resized to variants,
+videos get a poster frame and audio is normalized to 128kbps MP3.
+*/
+func (ms *MediaService) Process(fileType string, data []byte) (*ProcessedUpload, error) {
+	spec, ok := profileMediaSpecs[fileType]
+	if !ok {
+		return nil, fmt.Errorf("invalid file type: %s", fileType)
+	}
+
+	return ms.process(spec, strings.TrimSuffix(fileType, "_url"), data)
+}
+
+/* Process an image or video fetched from an external URL */
+func (ms *MediaService) ProcessExternal(data []byte) (*ProcessedUpload, error) {
+	return ms.process(externalMediaSpec, "external media", data)
+}
+
+func (ms *MediaService) process(spec mediaSpec, usage string, data []byte) (*ProcessedUpload, error) {
+	mediaType, err := utils.DetectMediaType(data)
+	if err != nil {
+		return nil, err
+	}
+
+	if !spec.accepts(mediaType.Kind) {
+		return nil, utils.NewMediaError("%s files cannot be used for %s", mediaType.Format, usage)
+	}
+
+	ms.slots <- struct{}{}
+	defer func() { <-ms.slots }()
+
+	ctx, cancel := context.WithTimeout(context.Background(), mediaProcessTimeout)
+	defer cancel()
+
+	var processed *ProcessedUpload
+	switch mediaType.Kind {
+	case utils.MediaKindImage:
+		processed, err = ms.processImage(ctx, spec, mediaType, data)
+	case utils.MediaKindVideo:
+		processed, err = ms.processVideo(ctx, spec, mediaType, data)
+	default:
+		processed, err = ms.processAudio(ctx, mediaType, data)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	processed.Kind = mediaType.Kind
+	return processed, nil
+}
+
+/* Total size of the files that are stored for the upload */
+func (p *ProcessedUpload) Size() int64 {
+	size := int64(len(p.Original.Data))
+	for _, variant := range p.Variants {
+		size += int64(len(variant.Data))
+	}
+	return size
+}
+
+func (ms *MediaService) processImage(ctx context.Context, spec mediaSpec, mediaType utils.MediaType, data []byte) (*ProcessedUpload, error) {
+	cfg, _, err := image.DecodeConfig(bytes.NewReader(data))
+	if err != nil {
+		return nil, utils.NewMediaError("image is corrupted")
+	}
+
+	if cfg.Width*cfg.Height > maxSourcePixels {
+		return nil, utils.NewMediaError("image dimensions are too large")
+	}
+
+	frames, err := utils.ImageFrameCount(data, mediaType.Format, 1)
+	if err != nil {
+		return nil, utils.NewMediaError("image is corrupted")
+	}
+
+	if frames > 1 {
+		return ms.processAnimatedImage(ctx, spec, mediaType, data, cfg)
+	}
+
+	img, _, err := image.Decode(bytes.NewReader(data))
+	if err != nil {
+		return nil, utils.NewMediaError("image is corrupted")
+	}
+
+	if mediaType.Format == "jpeg" {
+		img = applyOrientation(img, utils.JPEGOrientation(data))
+	}
+
+	if spec.Square {
+		img = cropSquare(img)
+	}
+
+	width, height := fitWithin(img.Bounds().Dx(), img.Bounds().Dy(), spec.MaxWidth, spec.MaxHeight)
+	if width != img.Bounds().Dx() || height != img.Bounds().Dy() {
+		img = resizeImage(img, width, height)
+	}
+
+	original, err := encodeStill(img, "original")
+	if err != nil {
+		return nil, err
+	}
+
+	processed := &ProcessedUpload{Original: *original}
+
+	for _, variantWidth := range variantWidths(spec.Widths, width) {
+		variant := img
+		variantHeight := height
+		if variantWidth != width {
+			variantHeight = max(1, height*variantWidth/width)
+			variant = resizeImage(img, variantWidth, variantHeight)
+		}
+
+		label := strconv.Itoa(variantWidth)
+		if variantWidth != width {
+			fallback, err := encodeStill(variant, label)
+			if err != nil {
+				return nil, err
+			}
+			processed.Variants = append(processed.Variants, *fallback)
+		}
+
+		if webp := ms.encodeWebP(ctx, variant, label); webp != nil {
+			processed.Variants = append(processed.Variants, *webp)
+		}
+	}
+
+	return processed, nil
+}
+
+/* Animated images keep their original as the fallback, WebP variants are made with ffmpeg */
+func (ms *MediaService) processAnimatedImage(ctx context.Context, spec mediaSpec, mediaType utils.MediaType, data []byte, cfg image.Config) (*ProcessedUpload, error) {
+	processed := &ProcessedUpload{
+		Original: MediaOutput{
+			Label:     "original",
+			Data:      data,
+			Format:    mediaType.Format,
+			Extension: mediaType.Extension,
+			Width:     cfg.Width,
+			Height:    cfg.Height,
+		},
+	}
+
+	if !ms.hasFFmpeg() || mediaType.Format != "gif" {
+		return processed, nil
+	}
+
+	sourceWidth, sourceHeight := cfg.Width, cfg.Height
+	crop := ""
+	if spec.Square {
+		sourceWidth = min(cfg.Width, cfg.Height)
+		sourceHeight = sourceWidth
+		crop = "crop='min(iw,ih)':'min(iw,ih)',"
+	}
+
+	width, height := fitWithin(sourceWidth, sourceHeight, spec.MaxWidth, spec.MaxHeight)
+
+	// Oversized GIFs are scaled down so the fallback stays small as well
+	if width != cfg.Width || height != cfg.Height {
+		output, err := ms.runFFmpeg(ctx, data, ".gif", ".gif",
+			"-vf", fmt.Sprintf("%sscale=%d:%d:flags=lanczos,split[a][b];[a]palettegen[p];[b][p]paletteuse", crop, width, height),
+			"-loop", "0",
+		)
+		if err != nil {
+			log.Printf("Error resizing animated gif: %v", err)
+		} else {
+			processed.Original.Data = output
+			processed.Original.Width = width
+			processed.Original.Height = height
+		}
+	}
+
+	for _, variantWidth := range variantWidths(spec.Widths, width) {
+		variantHeight := max(1, height*variantWidth/width)
+
+		output, err := ms.runFFmpeg(ctx, data, ".gif", ".webp",
+			"-vf", fmt.Sprintf("%sscale=%d:%d:flags=lanczos", crop, variantWidth, variantHeight),
+			"-c:v", "libwebp", "-lossless", "0", "-q:v", "75", "-loop", "0", "-an",
+		)
+		if err != nil {
+			log.Printf("Error creating animated webp variant: %v", err)
+			continue
+		}
+
+		processed.Variants = append(processed.Variants, MediaOutput{
+			Label:     strconv.Itoa(variantWidth),
+			Data:      output,
+			Format:    "webp",
+			Extension: ".webp",
+			Width:     variantWidth,
+			Height:    variantHeight,
+		})
+	}
+
+	return processed, nil
+}
+
+/* Videos are remuxed without metadata and get a poster frame shown while they load */
+func (ms *MediaService) processVideo(ctx context.Context, spec mediaSpec, mediaType utils.MediaType, data []byte) (*ProcessedUpload, error) {
+	processed := &ProcessedUpload{
+		Original: MediaOutput{
+			Label:     "original",
+			Data:      data,
+			Format:    mediaType.Format,
+			Extension: mediaType.Extension,
+		},
+	}
+
+	if !ms.hasFFmpeg() {
+		return processed, nil
+	}
+
+	args := []string{"-map_metadata", "-1", "-c", "copy"}
+	if mediaType.Format == "mp4" {
+		// Move the index to the front so playback starts before the download finishes
+		args = append(args, "-movflags", "+faststart")
+	}
+
+	if remuxed, err := ms.runFFmpeg(ctx, data, mediaType.Extension, mediaType.Extension, args...); err == nil {
+		processed.Original.Data = remuxed
+	} else {
+		log.Printf("Error remuxing video: %v", err)
+	}
+
+	frame, err := ms.runFFmpeg(ctx, data, mediaType.Extension, ".png", "-frames:v", "1", "-an")
+	if err != nil {
+		// A video without a decodable frame is not usable as a background
+		log.Printf("Error extracting poster frame: %v", err)
+		return nil, utils.NewMediaError("video could not be processed")
+	}
+
+	poster, _, err := image.Decode(bytes.NewReader(frame))
+	if err != nil {
+		return nil, utils.NewMediaError("video could not be processed")
+	}
+
+	width, height := fitWithin(poster.Bounds().Dx(), poster.Bounds().Dy(), spec.MaxWidth, spec.MaxHeight)
+	if width != poster.Bounds().Dx() || height != poster.Bounds().Dy() {
+		poster = resizeImage(poster, width, height)
+	}
+
+	fallback, err := encodeStill(poster, "poster")
+	if err != nil {
+		return nil, err
+	}
+	processed.Variants = append(processed.Variants, *fallback)
+
+	if webp := ms.encodeWebP(ctx, poster, "poster"); webp != nil {
+		processed.Variants = append(processed.Variants, *webp)
+	}
+
+	return processed, nil
+}
+
+/* Audio is transcoded to 128kbps stereo MP3 without metadata */
+func (ms *MediaService) processAudio(ctx context.Context, mediaType utils.MediaType, data []byte) (*ProcessedUpload, error) {
+	if !ms.hasFFmpeg() {
+		return &ProcessedUpload{
+			Original: MediaOutput{
+				Label:     "original",
+				Data:      data,
+				Format:    mediaType.Format,
+				Extension: mediaType.Extension,
+			},
+		}, nil
+	}
+
+	output, err := ms.runFFmpeg(ctx, data, mediaType.Extension, ".mp3",
+		"-vn", "-map_metadata", "-1", "-ac", "2", "-ar", "44100", "-c:a", "libmp3lame", "-b:a", "128k",
+	)
+	if err != nil {
+		log.Printf("Error transcoding audio: %v", err)
+		return nil, utils.NewMediaError("audio file could not be processed")
+	}
+
+	return &ProcessedUpload{
+		Original: MediaOutput{
+			Label:     "original",
+			Data:      output,
+			Format:    "mp3",
+			Extension: ".mp3",
+		},
+	}, nil
+}
+
+/* Encode a WebP variant, returns nil when ffmpeg is unavailable or fails */
+func (ms *MediaService) encodeWebP(ctx context.Context, img image.Image, label string) *MediaOutput {
+	if !ms.hasFFmpeg() {
+		return nil
+	}
+
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, img); err != nil {
+		return nil
+	}
+
+	output, err := ms.runFFmpeg(ctx, buf.Bytes(), ".png", ".webp", "-c:v", "libwebp", "-q:v", "80")
+	if err != nil {
+		log.Printf("Error creating webp variant: %v", err)
+		return nil
+	}
+
+	return &MediaOutput{
+		Label:     label,
+		Data:      output,
+		Format:    "webp",
+		Extension: ".webp",
+		Width:     img.Bounds().Dx(),
+		Height:    img.Bounds().Dy(),
+	}
+}
+
+func (ms *MediaService) hasFFmpeg() bool {
+	ms.ffmpegOnce.Do(func() {
+		_, err := exec.LookPath(ms.FFmpegPath)
+		ms.ffmpegAvailable = err == nil
+		if !ms.ffmpegAvailable {
+			log.Printf("ffmpeg not found at %s, uploads are stored without transcoding", ms.FFmpegPath)
+		}
+	})
+	return ms.ffmpegAvailable
+}
+
+/* Run ffmpeg on the input and return the output file. Temporary files are used because mp4 input needs to be seekable */
+func (ms *MediaService) runFFmpeg(ctx context.Context, input []byte, inputExt, outputExt string, args ...string) ([]byte, error) {
+	dir, err := os.MkdirTemp("", "media-*")
+	if err != nil {
+		return nil, err
+	}
+	defer os.RemoveAll(dir)
+
+	inputPath := filepath.Join(dir, "input"+inputExt)
+	outputPath := filepath.Join(dir, "output"+outputExt)
+
+	if err := os.WriteFile(inputPath, input, 0600); err != nil {
+		return nil, err
+	}
+
+	cmdArgs := append([]string{"-hide_banner", "-loglevel", "error", "-nostdin", "-y", "-i", inputPath}, args...)
+	cmdArgs = append(cmdArgs, outputPath)
+
+	var stderr bytes.Buffer
+	cmd := exec.CommandContext(ctx, ms.FFmpegPath, cmdArgs...)
+	cmd.Stderr = &stderr
+
+	if err := cmd.Run(); err != nil {
+		return nil, fmt.Errorf("ffmpeg failed: %w: %s", err, strings.TrimSpace(stderr.String()))
+	}
+
+	return os.ReadFile(outputPath)
+}
+
+/* Encode a still image as PNG when it has transparency and JPEG otherwise */
+func encodeStill(img image.Image, label string) (*MediaOutput, error) {
+	output := &MediaOutput{
+		Label:  label,
+		Width:  img.Bounds().Dx(),
+		Height: img.Bounds().Dy(),
+	}
+
+	var buf bytes.Buffer
+	if opaque, ok := img.(interface{ Opaque() bool }); ok && opaque.Opaque() {
+		if err := jpeg.Encode(&buf, img, &jpeg.Options{Quality: 85}); err != nil {
+			return nil, err
+		}
+		output.Format, output.Extension = "jpeg", ".jpg"
+	} else {
+		if err := png.Encode(&buf, img); err != nil {
+			return nil, err
+		}
+		output.Format, output.Extension = "png", ".png"
+	}
+
+	output.Data = buf.Bytes()
+	return output, nil
+}
+
+/* Widths to create variants at, the full width is always included */
+func variantWidths(widths []int, full int) []int {
+	result := []int{}
+	for _, width := range widths {
+		if width < full {
+			result = append(result, width)
+		}
+	}
+	return append(result, full)
+}
+
+/* Scale width and height down to fit the bounds while keeping the aspect ratio */
+func fitWithin(width, height, maxWidth, maxHeight int) (int, int) {
+	if maxWidth > 0 && width > maxWidth {
+		height = max(1, height*maxWidth/width)
+		width = maxWidth
+	}
+	if maxHeight > 0 && height > maxHeight {
+		width = max(1, width*maxHeight/height)
+		height = maxHeight
+	}
+	return width, height
+}
+
+func resizeImage(src image.Image, width, height int) image.Image {
+	dst := image.NewRGBA(image.Rect(0, 0, width, height))
+	xdraw.CatmullRom.Scale(dst, dst.Bounds(), src, src.Bounds(), xdraw.Over, nil)
+	return dst
+}
+
+func cropSquare(src image.Image) image.Image {
+	bounds := src.Bounds()
+	size := min(bounds.Dx(), bounds.Dy())
+	x := bounds.Min.X + (bounds.Dx()-size)/2
+	y := bounds.Min.Y + (bounds.Dy()-size)/2
+
+	dst := image.NewRGBA(image.Rect(0, 0, size, size))
+	xdraw.Draw(dst, dst.Bounds(), src, image.Pt(x, y), xdraw.Src)
+	return dst
+}
+
+/* Rotate and flip an image according to its EXIF orientation (1-8) */
+func applyOrientation(src image.Image, orientation int) image.Image {
+	if orientation <= 1 || orientation > 8 {
+		return src
+	}
+
+	bounds := src.Bounds()
+	width, height := bounds.Dx(), bounds.Dy()
+
+	// Orientations 5-8 swap width and height
+	dstWidth, dstHeight := width, height
+	if orientation >= 5 {
+		dstWidth, dstHeight = height, width
+	}
+	dst := image.NewRGBA(image.Rect(0, 0, dstWidth, dstHeight))
+
+	for y := 0; y < height; y++ {
+		for x := 0; x < width; x++ {
+			var dx, dy int
+			switch orientation {
+			case 2: // Mirrored horizontally
+				dx, dy = width-1-x, y
+			case 3: // Rotated 180
+				dx, dy = width-1-x, height-1-y
+			case 4: // Mirrored vertically
+				dx, dy = x, height-1-y
+			case 5: // Mirrored and rotated 90 counter clockwise
+				dx, dy = y, x
+			case 6: // Rotated 90 clockwise
+				dx, dy = height-1-y, x
+			case 7: // Mirrored and rotated 90 clockwise
+				dx, dy = height-1-y, width-1-x
+			case 8: // Rotated 90 counter clockwise
+				dx, dy = y, width-1-x
+			}
+			dst.Set(dx, dy, src.At(bounds.Min.X+x, bounds.Min.Y+y))
+		}
+	}
+
+	return dst
+}
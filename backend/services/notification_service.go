@@ -0,0 +1,344 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/bwmarrin/discordgo"
+	"github.com/go-redis/redis"
+	"github.com/hazebio/haze.bio_backend/models"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+type NotificationService struct {
+	DB           *gorm.DB
+	Client       *redis.Client
+	EmailService *EmailService
+	BotSession   *discordgo.Session
+}
+
+const (
+	notificationMilestonePrefix = "notification:milestone:"
+	maxNotificationsPerPage     = 100
+)
+
+func NewNotificationService(db *gorm.DB, client *redis.Client, botSession *discordgo.Session) *NotificationService {
+	return &NotificationService{
+		DB:           db,
+		Client:       client,
+		EmailService: &EmailService{DB: db, Client: client},
+		BotSession:   botSession,
+	}
+}
+
+/* Send a notification through every channel the user enabled for the category */
+func (ns *NotificationService) Notify(uid uint, category models.NotificationCategory, title string, message string, link string) error {
+	return ns.notify(uid, category, title, message, link, true)
+}
+
+/* Send a notification without the email channel (used when a dedicated email was already sent) */
+func (ns *NotificationService) NotifyWithoutEmail(uid uint, category models.NotificationCategory, title string, message string, link string) error {
+	return ns.notify(uid, category, title, message, link, false)
+}
+
+func (ns *NotificationService) notify(uid uint, category models.NotificationCategory, title string, message string, link string, allowEmail bool) error {
+	if !models.IsValidNotificationCategory(category) {
+		return errors.New("invalid notification category")
+	}
+
+	preference, err := ns.GetPreference(uid, category)
+	if err != nil {
+		return err
+	}
+
+	if preference.InApp {
+		notification := &models.Notification{
+			UID:      uid,
+			Category: category,
+			Title:    title,
+			Message:  message,
+			Link:     link,
+		}
+
+		if err := ns.DB.Create(notification).Error; err != nil {
+			log.Println("Error creating notification:", err)
+			return err
+		}
+	}
+
+	sendEmail := allowEmail && preference.Email
+	if !sendEmail && !preference.Discord {
+		return nil
+	}
+
+	var user models.User
+	if err := ns.DB.Select("uid", "email", "display_name", "discord_id").Where("uid = ?", uid).First(&user).Error; err != nil {
+		log.Printf("Error getting user %d for notification delivery: %v", uid, err)
+		return err
+	}
+
+	go func() {
+		if sendEmail {
+			ns.deliverEmail(&user, title, message, link)
+		}
+		if preference.Discord {
+			ns.deliverDiscord(&user, category, title, message, link)
+		}
+	}()
+
+	return nil
+}
+
+func (ns *NotificationService) deliverEmail(user *models.User, title string, message string, link string) {
+	if user.Email == nil || ns.EmailService == nil {
+		return
+	}
+
+	content := &models.EmailContent{
+		To:      *user.Email,
+		Subject: title,
+		Body:    "notification",
+		Data: map[string]string{
+			"Title":   title,
+			"Message": message,
+			"Link":    "https://cutz.lol" + link,
+		},
+	}
+
+	if err := ns.EmailService.SendTemplateEmail(content); err != nil {
+		log.Printf("Error sending notification email to user %d: %v", user.UID, err)
+	}
+}
+
+func (ns *NotificationService) deliverDiscord(user *models.User, category models.NotificationCategory, title string, message string, link string) {
+	if user.DiscordID == "" || ns.BotSession == nil {
+		return
+	}
+
+	channel, err := ns.BotSession.UserChannelCreate(user.DiscordID)
+	if err != nil {
+		log.Printf("Error opening DM channel for user %d: %v", user.UID, err)
+		return
+	}
+
+	embed := &discordgo.MessageEmbed{
+		Title:       title,
+		Description: message,
+		Color:       notificationColor(category),
+		Footer: &discordgo.MessageEmbedFooter{
+			Text: "cutz.lol notifications",
+		},
+		Timestamp: time.Now().Format(time.RFC3339),
+	}
+
+	if link != "" {
+		embed.URL = "https://cutz.lol" + link
+	}
+
+	if _, err := ns.BotSession.ChannelMessageSendEmbed(channel.ID, embed); err != nil {
+		log.Printf("Error sending notification DM to user %d: %v", user.UID, err)
+	}
+}
+
+func notificationColor(category models.NotificationCategory) int {
+	switch category {
+	case models.NotificationCategorySecurity:
+		return 0xEF4444
+	case models.NotificationCategoryModeration:
+		return 0xEAB308
+	case models.NotificationCategoryBilling:
+		return 0x8B5CF6
+	default:
+		return 0x22D3D3
+	}
+}
+
+/* Notify a user once when their profile reaches a view milestone */
+func (ns *NotificationService) CheckViewMilestone(uid uint) error {
+	var views uint
+	if err := ns.DB.Model(&models.UserProfile{}).Select("views").Where("uid = ?", uid).Scan(&views).Error; err != nil {
+		return err
+	}
+
+	for _, milestone := range models.ViewMilestones {
+		if views != milestone {
+			continue
+		}
+
+		key := fmt.Sprintf("%s%d:%d", notificationMilestonePrefix, uid, milestone)
+		isNew, err := ns.Client.SetNX(key, true, 0).Result()
+		if err != nil || !isNew {
+			return err
+		}
+
+		return ns.Notify(uid, models.NotificationCategorySocial,
+			fmt.Sprintf("Your profile reached %d views!", milestone),
+			fmt.Sprintf("Congratulations, your profile just hit %d views.", milestone),
+			"/dashboard/analytics")
+	}
+
+	return nil
+}
+
+/* Get notifications for a user */
+func (ns *NotificationService) GetNotifications(uid uint, unreadOnly bool, limit int, offset int) ([]*models.Notification, error) {
+	if limit <= 0 || limit > maxNotificationsPerPage {
+		limit = maxNotificationsPerPage
+	}
+	if offset < 0 {
+		offset = 0
+	}
+
+	notifications := []*models.Notification{}
+	query := ns.DB.Where("uid = ?", uid)
+	if unreadOnly {
+		query = query.Where("read = ?", false)
+	}
+
+	if err := query.Order("created_at DESC").Limit(limit).Offset(offset).Find(&notifications).Error; err != nil {
+		log.Println("Error getting notifications:", err)
+		return nil, err
+	}
+
+	return notifications, nil
+}
+
+/* Get the number of unread notifications for a user */
+func (ns *NotificationService) GetUnreadCount(uid uint) (int64, error) {
+	var count int64
+	if err := ns.DB.Model(&models.Notification{}).Where("uid = ? AND read = ?", uid, false).Count(&count).Error; err != nil {
+		log.Println("Error counting unread notifications:", err)
+		return 0, err
+	}
+
+	return count, nil
+}
+
+/* Mark the given notifications as read */
+func (ns *NotificationService) MarkAsRead(uid uint, notificationIDs []uint) (int64, error) {
+	if len(notificationIDs) == 0 {
+		return 0, errors.New("no notifications specified")
+	}
+
+	result := ns.DB.Model(&models.Notification{}).
+		Where("uid = ? AND id IN ? AND read = ?", uid, notificationIDs, false).
+		Updates(map[string]interface{}{
+			"read":    true,
+			"read_at": time.Now(),
+		})
+	if result.Error != nil {
+		log.Println("Error marking notifications as read:", result.Error)
+		return 0, result.Error
+	}
+
+	return result.RowsAffected, nil
+}
+
+/* Mark the given notifications as unread */
+func (ns *NotificationService) MarkAsUnread(uid uint, notificationIDs []uint) (int64, error) {
+	if len(notificationIDs) == 0 {
+		return 0, errors.New("no notifications specified")
+	}
+
+	result := ns.DB.Model(&models.Notification{}).
+		Where("uid = ? AND id IN ? AND read = ?", uid, notificationIDs, true).
+		Updates(map[string]interface{}{
+			"read":    false,
+			"read_at": nil,
+		})
+	if result.Error != nil {
+		log.Println("Error marking notifications as unread:", result.Error)
+		return 0, result.Error
+	}
+
+	return result.RowsAffected, nil
+}
+
+/* Mark all notifications of a user as read */
+func (ns *NotificationService) MarkAllAsRead(uid uint) (int64, error) {
+	result := ns.DB.Model(&models.Notification{}).
+		Where("uid = ? AND read = ?", uid, false).
+		Updates(map[string]interface{}{
+			"read":    true,
+			"read_at": time.Now(),
+		})
+	if result.Error != nil {
+		log.Println("Error marking all notifications as read:", result.Error)
+		return 0, result.Error
+	}
+
+	return result.RowsAffected, nil
+}
+
+/* Get the preference for a single category, falling back to the default */
+func (ns *NotificationService) GetPreference(uid uint, category models.NotificationCategory) (*models.NotificationPreference, error) {
+	var preference models.NotificationPreference
+	err := ns.DB.Where("uid = ? AND category = ?", uid, category).First(&preference).Error
+	if err == nil {
+		return &preference, nil
+	}
+
+	if !errors.Is(err, gorm.ErrRecordNotFound) {
+		log.Println("Error getting notification preference:", err)
+		return nil, err
+	}
+
+	preference = models.DefaultNotificationPreferences[category]
+	preference.UID = uid
+	return &preference, nil
+}
+
+/* Get the preferences of every category for a user */
+func (ns *NotificationService) GetPreferences(uid uint) ([]models.NotificationPreference, error) {
+	var stored []models.NotificationPreference
+	if err := ns.DB.Where("uid = ?", uid).Find(&stored).Error; err != nil {
+		log.Println("Error getting notification preferences:", err)
+		return nil, err
+	}
+
+	byCategory := make(map[models.NotificationCategory]models.NotificationPreference, len(stored))
+	for _, preference := range stored {
+		byCategory[preference.Category] = preference
+	}
+
+	preferences := make([]models.NotificationPreference, 0, len(models.NotificationCategories))
+	for _, category := range models.NotificationCategories {
+		if preference, ok := byCategory[category]; ok {
+			preferences = append(preferences, preference)
+			continue
+		}
+		preferences = append(preferences, models.DefaultNotificationPreferences[category])
+	}
+
+	return preferences, nil
+}
+
+/* Update the preferences of a user */
+func (ns *NotificationService) UpdatePreferences(uid uint, preferences []models.NotificationPreference) error {
+	for _, preference := range preferences {
+		if !models.IsValidNotificationCategory(preference.Category) {
+			return errors.New("invalid notification category")
+		}
+	}
+
+	tx := ns.DB.Begin()
+	for _, preference := range preferences {
+		preference.ID = 0
+		preference.UID = uid
+
+		err := tx.Clauses(clause.OnConflict{
+			Columns:   []clause.Column{{Name: "uid"}, {Name: "category"}},
+			DoUpdates: clause.AssignmentColumns([]string{"in_app", "email", "discord", "updated_at"}),
+		}).Create(&preference).Error
+		if err != nil {
+			tx.Rollback()
+			log.Println("Error updating notification preference:", err)
+			return err
+		}
+	}
+
+	return tx.Commit().Error
+}
@@ -0,0 +1,98 @@
+package services
+
+import (
+	"log"
+	"strings"
+	"sync"
+
+	"github.com/go-redis/redis"
+	"github.com/hazebio/haze.bio_backend/utils"
+)
+
+// PresenceHub keeps a single Redis pattern subscription per backend instance and
+// fans presence updates out to the websocket viewers connected to this instance.
+type PresenceHub struct {
+	Client *redis.Client
+
+	mu          sync.RWMutex
+	subscribers map[uint]map[chan []byte]struct{}
+	start       sync.Once
+}
+
+const presenceSubscriberBuffer = 8
+
+func NewPresenceHub(client *redis.Client) *PresenceHub {
+	return &PresenceHub{
+		Client:      client,
+		subscribers: make(map[uint]map[chan []byte]struct{}),
+	}
+}
+
+/* Subscribe to presence updates of a user, the returned function must be called to unsubscribe */
+func (h *PresenceHub) Subscribe(uid uint) (<-chan []byte, func()) {
+	h.start.Do(func() {
+		go h.run()
+	})
+
+	ch := make(chan []byte, presenceSubscriberBuffer)
+
+	h.mu.Lock()
+	if h.subscribers[uid] == nil {
+		h.subscribers[uid] = make(map[chan []byte]struct{})
+	}
+	h.subscribers[uid][ch] = struct{}{}
+	h.mu.Unlock()
+
+	var once sync.Once
+	return ch, func() {
+		once.Do(func() {
+			h.mu.Lock()
+			delete(h.subscribers[uid], ch)
+			if len(h.subscribers[uid]) == 0 {
+				delete(h.subscribers, uid)
+			}
+			h.mu.Unlock()
+			close(ch)
+		})
+	}
+}
+
+/* Get the number of viewers connected to this instance */
+func (h *PresenceHub) SubscriberCount() int {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+
+	count := 0
+	for _, subscribers := range h.subscribers {
+		count += len(subscribers)
+	}
+	return count
+}
+
+func (h *PresenceHub) run() {
+	pubsub := h.Client.PSubscribe(presenceChannelPrefix + "*")
+	defer pubsub.Close()
+
+	log.Println("Presence hub subscribed to Redis")
+
+	for msg := range pubsub.Channel() {
+		uid := utils.StringToUint(strings.TrimPrefix(msg.Channel, presenceChannelPrefix))
+		if uid == 0 {
+			continue
+		}
+		h.broadcast(uid, []byte(msg.Payload))
+	}
+}
+
+func (h *PresenceHub) broadcast(uid uint, payload []byte) {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+
+	for ch := range h.subscribers[uid] {
+		// Slow viewers miss intermediate updates instead of blocking everyone else
+		select {
+		case ch <- payload:
+		default:
+		}
+	}
+}
@@ -0,0 +1,309 @@
+package services
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"html/template"
+	"image/color"
+	"net/url"
+	"strconv"
+	"strings"
+
+	"github.com/hazebio/haze.bio_backend/config"
+	"github.com/hazebio/haze.bio_backend/models"
+)
+
+const (
+	oEmbedVersion  = "1.0"
+	oEmbedCacheAge = 3600
+
+	OEmbedTypeRich  = "rich"
+	OEmbedTypePhoto = "photo"
+
+	// Size of the iframe rich responses embed
+	embedWidth  = 420
+	embedHeight = 240
+	// The compact view doesn't fit in anything smaller
+	embedMinWidth  = 280
+	embedMinHeight = 160
+
+	embedMaxSocials = 4
+	embedMaxBadges  = 8
+)
+
+// EmbedTheme is how an embedded profile is styled, from the embedding page's
+// query parameters
+type EmbedTheme struct {
+	Light bool
+	// Accent overrides the profile's accent color
+	Accent string
+	Radius int
+}
+
+/* Parse theme, accent and radius query parameters, invalid values are ignored */
+func ParseEmbedTheme(query url.Values) EmbedTheme {
+	theme := EmbedTheme{
+		Light:  query.Get("theme") == "light",
+		Radius: 16,
+	}
+
+	if accent := query.Get("accent"); accent != "" {
+		if c := parseHexColor(accent, color.RGBA{}); c.A != 0 {
+			theme.Accent = hexColor(c)
+		}
+	}
+
+	if radius, err := strconv.Atoi(query.Get("radius")); err == nil && radius >= 0 && radius <= 32 {
+		theme.Radius = radius
+	}
+
+	return theme
+}
+
+/* Query parameters that reproduce a theme, so oEmbed consumers get the view they asked for */
+func (t EmbedTheme) query() string {
+	query := url.Values{}
+	if t.Light {
+		query.Set("theme", "light")
+	}
+	if t.Accent != "" {
+		query.Set("accent", t.Accent)
+	}
+	if t.Radius != 16 {
+		query.Set("radius", strconv.Itoa(t.Radius))
+	}
+
+	if len(query) == 0 {
+		return ""
+	}
+	return "?" + query.Encode()
+}
+
+/* URL of the embeddable view of a profile */
+func ProfileEmbedURL(username string, theme EmbedTheme) string {
+	return apiBaseURL() + "/api/embed/" + url.PathEscape(username) + theme.query()
+}
+
+/* Username or alias a profile URL points at, false for anything but a profile on our host */
+func profileIdentifierFromURL(rawURL string) (string, bool) {
+	parsed, err := url.Parse(rawURL)
+	if err != nil || (parsed.Scheme != "https" && parsed.Scheme != "http") {
+		return "", false
+	}
+
+	host := strings.TrimPrefix(strings.ToLower(parsed.Host), "www.")
+	if host != strings.ToLower(profileHost()) {
+		return "", false
+	}
+
+	identifier := strings.Trim(parsed.Path, "/")
+	if identifier == "" || strings.Contains(identifier, "/") {
+		return "", false
+	}
+
+	return identifier, true
+}
+
+/*
+Build the oEmbed response for a profile URL. Rich responses embed the compact
+profile view and photo responses point at the profile card, both fit within
+maxWidth and maxHeight when they are set.
+*/
+func (ps *ProfileService) OEmbed(rawURL string, kind string, maxWidth int, maxHeight int, theme EmbedTheme) (*models.OEmbedResponse, error) {
+	identifier, ok := profileIdentifierFromURL(rawURL)
+	if !ok {
+		return nil, errors.New("unsupported url")
+	}
+
+	user, err := ps.GetPublicProfile(identifier)
+	if err != nil || user.HasActivePunishment() || user.Profile == nil {
+		return nil, errors.New("profile not found")
+	}
+
+	displayName := user.DisplayName
+	if displayName == "" {
+		displayName = user.Username
+	}
+
+	profileURL := ProfileURL(user.Username)
+	response := &models.OEmbedResponse{
+		Version:         oEmbedVersion,
+		Title:           fmt.Sprintf("%s (@%s)", displayName, user.Username),
+		AuthorName:      displayName,
+		AuthorURL:       profileURL,
+		ProviderName:    profileHost(),
+		ProviderURL:     strings.TrimSuffix(config.Origin, "/"),
+		CacheAge:        oEmbedCacheAge,
+		ThumbnailURL:    UserCardURL(user.UID),
+		ThumbnailWidth:  cardWidth,
+		ThumbnailHeight: cardHeight,
+	}
+
+	switch kind {
+	case "", OEmbedTypeRich:
+		width, height := embedWidth, embedHeight
+		if maxWidth > 0 && maxWidth < width {
+			width = maxWidth
+		}
+		if maxHeight > 0 && maxHeight < height {
+			height = maxHeight
+		}
+		if width < embedMinWidth || height < embedMinHeight {
+			return nil, errors.New("size not available")
+		}
+
+		var iframe bytes.Buffer
+		embedIframeTemplate.Execute(&iframe, map[string]interface{}{
+			"URL":    ProfileEmbedURL(user.Username, theme),
+			"Title":  response.Title,
+			"Width":  width,
+			"Height": height,
+		})
+
+		response.Type = OEmbedTypeRich
+		response.HTML = iframe.String()
+		response.Width, response.Height = width, height
+
+	case OEmbedTypePhoto:
+		width, height := cardWidth, cardHeight
+		if maxWidth > 0 && maxWidth < width {
+			width, height = maxWidth, maxWidth*cardHeight/cardWidth
+		}
+		if maxHeight > 0 && maxHeight < height {
+			width, height = maxHeight*cardWidth/cardHeight, maxHeight
+		}
+
+		response.Type = OEmbedTypePhoto
+		response.URL = response.ThumbnailURL
+		response.Width, response.Height = width, height
+
+	default:
+		return nil, errors.New("unsupported type")
+	}
+
+	return response, nil
+}
+
+var embedIframeTemplate = template.Must(template.New("iframe").Parse(
+	`<iframe src="{{.URL}}" title="{{.Title}}" width="{{.Width}}" height="{{.Height}}" style="border:0;max-width:100%" loading="lazy" sandbox="allow-popups allow-popups-to-escape-sandbox"></iframe>`))
+
+// profileEmbed is what the compact profile view shows
+type profileEmbed struct {
+	DisplayName string
+	Username    string
+	ProfileURL  string
+	AvatarURL   string
+	Badges      []profileEmbedBadge
+	Socials     []ProfilePageLink
+	Views       string
+	Joined      string
+	Style       template.CSS
+}
+
+type profileEmbedBadge struct {
+	Name     string
+	MediaURL string
+}
+
+var profileEmbedTemplate = template.Must(template.New("embed").Parse(`<!DOCTYPE html>
+<html lang="en">
+<head>
+<meta charset="utf-8">
+<meta name="viewport" content="width=device-width, initial-scale=1">
+<meta name="robots" content="noindex">
+<title>{{.DisplayName}} (@{{.Username}})</title>
+<style>{{.Style}}
+*{box-sizing:border-box}
+html,body{margin:0;background:transparent;font-family:system-ui,-apple-system,"Segoe UI",sans-serif}
+.card{display:flex;flex-direction:column;gap:12px;padding:16px;border-radius:var(--radius);background:var(--background);color:var(--text);border:1px solid var(--border);max-width:420px}
+.head{display:flex;gap:12px;align-items:center}
+.avatar{width:64px;height:64px;border-radius:50%;object-fit:cover;border:2px solid var(--accent)}
+.name{margin:0;font-size:18px;font-weight:700;white-space:nowrap;overflow:hidden;text-overflow:ellipsis}
+.name a{color:inherit;text-decoration:none}
+.username{margin:2px 0 0;font-size:14px;color:var(--accent)}
+.badges{display:flex;flex-wrap:wrap;gap:6px}
+.badges img{width:20px;height:20px;object-fit:contain}
+.socials{display:flex;flex-wrap:wrap;gap:6px;margin:0;padding:0;list-style:none}
+.socials a{display:block;padding:4px 10px;border-radius:999px;font-size:13px;color:var(--text);background:var(--chip);text-decoration:none;text-transform:capitalize}
+.meta{display:flex;gap:12px;font-size:12px;color:var(--muted)}
+</style>
+</head>
+<body>
+<div class="card">
+<div class="head">
+{{if .AvatarURL}}<img class="avatar" src="{{.AvatarURL}}" alt="">{{end}}
+<div>
+<p class="name"><a href="{{.ProfileURL}}" target="_blank" rel="noopener">{{.DisplayName}}</a></p>
+<p class="username">@{{.Username}}</p>
+</div>
+</div>
+{{if .Badges}}<div class="badges">{{range .Badges}}<img src="{{.MediaURL}}" alt="{{.Name}}" title="{{.Name}}">{{end}}</div>{{end}}
+{{if .Socials}}<ul class="socials">{{range .Socials}}<li><a href="{{.URL}}" target="_blank" rel="noopener nofollow">{{.Platform}}</a></li>{{end}}</ul>{{end}}
+{{if or .Views .Joined}}<div class="meta">{{if .Views}}<span>{{.Views}}</span>{{end}}{{if .Joined}}<span>{{.Joined}}</span>{{end}}</div>{{end}}
+</div>
+</body>
+</html>
+`))
+
+/* Render the compact, iframe embeddable view of a profile */
+func (ps *ProfileService) RenderProfileEmbed(identifier string, theme EmbedTheme) ([]byte, error) {
+	user, err := ps.GetPublicProfile(identifier)
+	if err != nil || user.HasActivePunishment() || user.Profile == nil {
+		return nil, errors.New("profile not found")
+	}
+
+	profile := user.Profile
+
+	embed := &profileEmbed{
+		DisplayName: user.DisplayName,
+		Username:    user.Username,
+		ProfileURL:  ProfileURL(user.Username),
+		AvatarURL:   profile.AvatarURL,
+	}
+	if embed.DisplayName == "" {
+		embed.DisplayName = user.Username
+	}
+
+	for _, badge := range visibleBadges(user) {
+		if len(embed.Badges) == embedMaxBadges {
+			break
+		}
+		embed.Badges = append(embed.Badges, profileEmbedBadge{Name: badge.Badge.Name, MediaURL: badge.Badge.MediaURL})
+	}
+
+	for _, social := range linkedSocials(user) {
+		if len(embed.Socials) == embedMaxSocials {
+			break
+		}
+		embed.Socials = append(embed.Socials, ProfilePageLink{Platform: social.Platform, URL: social.Link})
+	}
+
+	if !profile.HideViewsCount {
+		embed.Views = fmt.Sprintf("%d views", profile.Views)
+	}
+	if !profile.HideJoinedDate {
+		embed.Joined = "Joined " + user.CreatedAt.Format("January 2006")
+	}
+
+	accent := theme.Accent
+	if accent == "" {
+		accent = hexColor(parseHexColor(profile.AccentColor, color.RGBA{168, 85, 247, 255}))
+	}
+
+	background, text, muted, border, chip := "#111113", "#fafafa", "#a1a1aa", "#27272a", "#27272a"
+	if theme.Light {
+		background, text, muted, border, chip = "#ffffff", "#18181b", "#71717a", "#e4e4e7", "#f4f4f5"
+	}
+
+	// Every value is a validated color or a number, so the rule is safe CSS
+	embed.Style = template.CSS(fmt.Sprintf(":root{--background:%s;--text:%s;--muted:%s;--border:%s;--chip:%s;--accent:%s;--radius:%dpx}",
+		background, text, muted, border, chip, accent, theme.Radius))
+
+	var buf bytes.Buffer
+	if err := profileEmbedTemplate.Execute(&buf, embed); err != nil {
+		return nil, err
+	}
+
+	return buf.Bytes(), nil
+}
@@ -0,0 +1,250 @@
+package services
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"html/template"
+	"net/url"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/hazebio/haze.bio_backend/config"
+	"github.com/hazebio/haze.bio_backend/models"
+	"github.com/hazebio/haze.bio_backend/utils"
+	"gorm.io/gorm"
+)
+
+const profilePageDescriptionLength = 160
+
+// ProfilePage is what the server rendered profile document shows
+type ProfilePage struct {
+	Title        string
+	DisplayName  string
+	Username     string
+	Description  string
+	CanonicalURL string
+	CardURL      string
+	OEmbedURL    string
+	AvatarURL    string
+	SiteName     string
+	Socials      []ProfilePageLink
+	// Schema.org ProfilePage, already JSON encoded
+	StructuredData template.JS
+}
+
+type ProfilePageLink struct {
+	Platform string
+	URL      string
+}
+
+var profilePageTemplate = template.Must(template.New("profile").Parse(`<!DOCTYPE html>
+<html lang="en">
+<head>
+<meta charset="utf-8">
+<meta name="viewport" content="width=device-width, initial-scale=1">
+<title>{{.Title}}</title>
+<meta name="description" content="{{.Description}}">
+<link rel="canonical" href="{{.CanonicalURL}}">
+<link rel="alternate" type="application/json+oembed" href="{{.OEmbedURL}}" title="{{.Title}}">
+<meta property="og:type" content="profile">
+<meta property="og:site_name" content="{{.SiteName}}">
+<meta property="og:title" content="{{.Title}}">
+<meta property="og:description" content="{{.Description}}">
+<meta property="og:url" content="{{.CanonicalURL}}">
+<meta property="og:image" content="{{.CardURL}}">
+<meta property="og:image:type" content="image/png">
+<meta property="og:image:width" content="1200">
+<meta property="og:image:height" content="630">
+<meta property="og:image:alt" content="{{.DisplayName}}'s profile card">
+<meta property="profile:username" content="{{.Username}}">
+<meta name="twitter:card" content="summary_large_image">
+<meta name="twitter:title" content="{{.Title}}">
+<meta name="twitter:description" content="{{.Description}}">
+<meta name="twitter:image" content="{{.CardURL}}">
+<script type="application/ld+json">{{.StructuredData}}</script>
+</head>
+<body>
+<main>
+{{if .AvatarURL}}<img src="{{.AvatarURL}}" alt="{{.DisplayName}}" width="128" height="128">{{end}}
+<h1>{{.DisplayName}}</h1>
+<p>@{{.Username}}</p>
+{{if .Description}}<p>{{.Description}}</p>{{end}}
+{{if .Socials}}<ul>
+{{range .Socials}}<li><a href="{{.URL}}" rel="me nofollow">{{.Platform}}</a></li>
+{{end}}</ul>{{end}}
+<p><a href="{{.CanonicalURL}}">View {{.DisplayName}} on {{.SiteName}}</a></p>
+</main>
+</body>
+</html>
+`))
+
+var profileNotFoundTemplate = template.Must(template.New("not_found").Parse(`<!DOCTYPE html>
+<html lang="en">
+<head>
+<meta charset="utf-8">
+<title>Profile not found | {{.}}</title>
+<meta name="robots" content="noindex">
+</head>
+<body><h1>Profile not found</h1></body>
+</html>
+`))
+
+/* Public URL of a profile on the frontend */
+func ProfileURL(username string) string {
+	return strings.TrimSuffix(config.Origin, "/") + "/" + url.PathEscape(username)
+}
+
+/* Public URL of this API, the frontend proxies /api when it isn't configured */
+func apiBaseURL() string {
+	if config.APIPublicURL != "" {
+		return config.APIPublicURL
+	}
+	return strings.TrimSuffix(config.Origin, "/")
+}
+
+/* Absolute URL of the Open Graph card of a user */
+func UserCardURL(uid uint) string {
+	return fmt.Sprintf("%s/api/images/user/%d", apiBaseURL(), uid)
+}
+
+/* Cut a description to at most length runes on a word boundary */
+func truncateDescription(text string, length int) string {
+	runes := []rune(text)
+	if len(runes) <= length {
+		return text
+	}
+
+	cut := string(runes[:length-3])
+	if i := strings.LastIndex(cut, " "); i > length/2 {
+		cut = cut[:i]
+	}
+	return strings.TrimSpace(cut) + "..."
+}
+
+/*
+Render the server side profile document crawlers and link unfurlers are
+served. Profiles are reachable by username and alias, the username URL is
+always the canonical one.
+*/
+func (ps *ProfileService) RenderProfilePage(identifier string) ([]byte, error) {
+	user, err := ps.GetPublicProfile(identifier)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, errors.New("profile not found")
+		}
+		return nil, err
+	}
+
+	if user.HasActivePunishment() || user.Profile == nil {
+		return nil, errors.New("profile not found")
+	}
+
+	page := buildProfilePage(user)
+
+	var buf bytes.Buffer
+	if err := profilePageTemplate.Execute(&buf, page); err != nil {
+		return nil, err
+	}
+
+	return buf.Bytes(), nil
+}
+
+/* Render the document served for profiles that don't exist or can't be shown */
+func RenderProfileNotFoundPage() []byte {
+	var buf bytes.Buffer
+	profileNotFoundTemplate.Execute(&buf, profileHost())
+	return buf.Bytes()
+}
+
+/* Socials of a user that link to a web page, in their order */
+func linkedSocials(user *models.User) []models.UserSocial {
+	socials := make([]models.UserSocial, 0, len(user.Socials))
+	for _, social := range user.Socials {
+		if social.Hidden || social.SocialType == models.SocialTypeCopyLink {
+			continue
+		}
+		if !strings.HasPrefix(social.Link, "https://") && !strings.HasPrefix(social.Link, "http://") {
+			continue
+		}
+		socials = append(socials, social)
+	}
+	sort.SliceStable(socials, func(i, j int) bool { return socials[i].Sort < socials[j].Sort })
+
+	return socials
+}
+
+func buildProfilePage(user *models.User) *ProfilePage {
+	profile := user.Profile
+	siteName := profileHost()
+
+	displayName := user.DisplayName
+	if displayName == "" {
+		displayName = user.Username
+	}
+
+	description := truncateDescription(strings.Join(strings.Fields(utils.StripHTML(profile.Description)), " "), profilePageDescriptionLength)
+	if description == "" {
+		description = fmt.Sprintf("Check out %s's profile on %s.", displayName, siteName)
+	}
+
+	socials := linkedSocials(user)
+
+	page := &ProfilePage{
+		Title:        fmt.Sprintf("%s (@%s) | %s", displayName, user.Username, siteName),
+		DisplayName:  displayName,
+		Username:     user.Username,
+		Description:  description,
+		CanonicalURL: ProfileURL(user.Username),
+		CardURL:      UserCardURL(user.UID),
+		OEmbedURL:    apiBaseURL() + "/api/oembed?url=" + url.QueryEscape(ProfileURL(user.Username)),
+		AvatarURL:    profile.AvatarURL,
+		SiteName:     siteName,
+	}
+
+	sameAs := make([]string, 0, len(socials))
+	for _, social := range socials {
+		page.Socials = append(page.Socials, ProfilePageLink{Platform: social.Platform, URL: social.Link})
+		sameAs = append(sameAs, social.Link)
+	}
+
+	person := map[string]interface{}{
+		"@type":         "Person",
+		"name":          displayName,
+		"alternateName": "@" + user.Username,
+		"identifier":    fmt.Sprint(user.UID),
+		"description":   description,
+		"url":           page.CanonicalURL,
+	}
+	if profile.AvatarURL != "" {
+		person["image"] = profile.AvatarURL
+	}
+	if len(sameAs) > 0 {
+		person["sameAs"] = sameAs
+	}
+	if !profile.HideViewsCount {
+		person["interactionStatistic"] = map[string]interface{}{
+			"@type":                "InteractionCounter",
+			"interactionType":      "https://schema.org/ViewAction",
+			"userInteractionCount": profile.Views,
+		}
+	}
+
+	structuredData := map[string]interface{}{
+		"@context":   "https://schema.org",
+		"@type":      "ProfilePage",
+		"url":        page.CanonicalURL,
+		"mainEntity": person,
+	}
+	if !profile.HideJoinedDate {
+		structuredData["dateCreated"] = user.CreatedAt.UTC().Format(time.RFC3339)
+	}
+
+	// encoding/json escapes <, > and &, so the data can't close the script element
+	data, _ := json.Marshal(structuredData)
+	page.StructuredData = template.JS(data)
+
+	return page
+}
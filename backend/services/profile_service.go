@@ -9,7 +9,6 @@ import (
 
 	"github.com/bwmarrin/discordgo"
 	"github.com/go-redis/redis"
-	"github.com/hazebio/haze.bio_backend/config"
 	"github.com/hazebio/haze.bio_backend/models"
 	"github.com/hazebio/haze.bio_backend/utils"
 	"gorm.io/gorm"
@@ -21,6 +20,7 @@ type ProfileService struct {
 	UserService    *UserService
 	DiscordService *DiscordService
 	BotSession     *discordgo.Session
+	DomainService  *DomainService
 }
 
 func NewProfileService(db *gorm.DB, client *redis.Client, botSession *discordgo.Session, discordService *DiscordService) *ProfileService {
@@ -188,6 +188,33 @@ func (ps *ProfileService) GetPublicProfileByUID(uid uint) (*models.User, error)
 	return &user, nil
 }
 
+/* Get the public profile a verified custom domain points at */
+func (ps *ProfileService) GetPublicProfileByDomain(host string) (*models.User, error) {
+	if ps.DomainService == nil {
+		return nil, errors.New("profile not found")
+	}
+
+	uid, ok := ps.DomainService.ResolveHost(host)
+	if !ok {
+		return nil, errors.New("profile not found")
+	}
+
+	user, err := ps.GetPublicProfileByUID(uid)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, errors.New("profile not found")
+		}
+		return nil, err
+	}
+
+	// Domains stop resolving when their owner's premium runs out
+	if models.NewPremiumFeatures().IsCustomDomainPremium() && !user.HasPremium {
+		return nil, errors.New("profile not found")
+	}
+
+	return user, nil
+}
+
 /* Get user profile by UID */
 func (ps *ProfileService) GetUserProfileByUID(uid uint) (*models.UserProfile, error) {
 	profile := &models.UserProfile{}
@@ -271,7 +298,7 @@ func (ps *ProfileService) UpdateUserProfileFields(uid uint, fields map[string]in
 
 		if useDiscordAvatar {
 			log.Println("using discord id " + user.DiscordID)
-			member, err := ps.BotSession.GuildMember(config.DiscordGuildID, user.DiscordID)
+			member, err := ps.DiscordService.GetGuildMember(user.DiscordID)
 			if err != nil {
 				return nil, err
 			}
@@ -301,6 +328,8 @@ func (ps *ProfileService) UpdateUserProfileFields(uid uint, fields map[string]in
 		}
 	}
 
+	proxyProfileFields(fields)
+
 	delete(fields, "uid")
 	delete(fields, "views")
 
@@ -309,6 +338,8 @@ func (ps *ProfileService) UpdateUserProfileFields(uid uint, fields map[string]in
 		return nil, err
 	}
 
+	invalidateCards(ps.Client, userCardSubject(uid))
+
 	updatedProfile, err := ps.GetUserProfileByUID(uid)
 	if err != nil {
 		return nil, err
@@ -405,6 +436,8 @@ func (ps *ProfileService) UpdateUserProfile(profile *models.UserProfile) error {
 		return err
 	}
 
+	invalidateCards(ps.Client, userCardSubject(profile.UID))
+
 	return nil
 }
 
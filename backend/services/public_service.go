@@ -189,3 +189,59 @@ func (ps *PublicService) GetLeaderboardUsers(sortBy SortBy) ([]*LeaderboardUser,
 
 	return leaderboardUsers, nil
 }
+
+type ReferralLeaderboardUser struct {
+	Username    string `json:"username"`
+	DisplayName string `json:"display_name"`
+	AvatarURL   string `json:"avatar_url"`
+	Referrals   int64  `json:"referrals"`
+}
+
+/* Get Referral Leaderboard (Top 50 by verified referrals, Cached) */
+func (ps *PublicService) GetReferralLeaderboard() ([]*ReferralLeaderboardUser, error) {
+	cacheKey := "leaderboard_users_referrals"
+	leaderboardUsers := []*ReferralLeaderboardUser{}
+
+	val, err := ps.Client.Get(cacheKey).Result()
+	if err == nil {
+		err = json.Unmarshal([]byte(val), &leaderboardUsers)
+		if err != nil {
+			log.Printf("Error decoding referral leaderboard from cache: %v", err)
+			ps.Client.Del(cacheKey)
+		} else {
+			return leaderboardUsers, nil
+		}
+	} else if err != redis.Nil {
+		log.Printf("Error getting referral leaderboard from cache: %v", err)
+	}
+
+	bannedUsers := ps.DB.Model(&models.Punishment{}).
+		Select("user_id").
+		Where("active = ? AND end_date > ?", true, time.Now())
+
+	err = ps.DB.Table("referrals").
+		Select("users.username, users.display_name, user_profiles.avatar_url, COUNT(referrals.id) AS referrals").
+		Joins("JOIN users ON users.uid = referrals.referrer_id").
+		Joins("LEFT JOIN user_profiles ON user_profiles.uid = users.uid").
+		Where("referrals.status = ?", models.ReferralStatusVerified).
+		Where("referrals.referrer_id NOT IN (?)", bannedUsers).
+		Group("users.uid, users.username, users.display_name, user_profiles.avatar_url").
+		Order("referrals DESC").
+		Limit(50).
+		Scan(&leaderboardUsers).Error
+	if err != nil {
+		return nil, err
+	}
+
+	encoded, err := json.Marshal(leaderboardUsers)
+	if err != nil {
+		log.Printf("Error encoding referral leaderboard for cache: %v", err)
+	} else {
+		err = ps.Client.Set(cacheKey, string(encoded), time.Minute*30).Err()
+		if err != nil {
+			log.Printf("Error setting referral leaderboard in cache: %v", err)
+		}
+	}
+
+	return leaderboardUsers, nil
+}
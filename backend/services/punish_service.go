@@ -17,8 +17,10 @@ type PunishService struct {
 	DB     *gorm.DB
 	Client *redis.Client
 
-	UserService  *UserService
-	EmailService *EmailService
+	UserService         *UserService
+	EmailService        *EmailService
+	NotificationService *NotificationService
+	EventService        *EventService
 }
 
 func NewPunishService(db *gorm.DB, client *redis.Client) *PunishService {
@@ -51,6 +53,13 @@ func (p *PunishService) CreatePunishment(punishment *models.Punishment) error {
 		return err
 	}
 
+	if p.NotificationService != nil {
+		message := fmt.Sprintf("Your account has been restricted until %s. Reason: %s", utils.FormatDate(punishment.EndDate), punishment.Reason)
+		if err := p.NotificationService.NotifyWithoutEmail(punishment.UserID, models.NotificationCategoryModeration, "Your account has been restricted", message, "/dashboard"); err != nil {
+			log.Printf("Error sending restriction notification: %v", err)
+		}
+	}
+
 	if user.Email == nil {
 		log.Println("User has no email, skipping email notification")
 		return nil
@@ -207,6 +216,13 @@ func (p *PunishService) DeactivatePunishment(punishmentID uint, staffID uint) er
 		log.Printf("Error logging moderation action: %v", err)
 	}
 
+	if p.NotificationService != nil {
+		message := fmt.Sprintf("Your account restriction (%s) has been removed.", punishment.Reason)
+		if err := p.NotificationService.NotifyWithoutEmail(punishment.UserID, models.NotificationCategoryModeration, "Your account restriction has been removed", message, "/dashboard"); err != nil {
+			log.Printf("Error sending unrestriction notification: %v", err)
+		}
+	}
+
 	user, err := p.UserService.GetUserByUID(punishment.UserID)
 	if err == nil && user.Email != nil {
 		content := &models.EmailContent{
@@ -278,6 +294,39 @@ func (p *PunishService) CreateReport(reporterID uint, reportedUsername string, r
 		log.Printf("Error incrementing report count: %v", err)
 	}
 
+	p.publishReportEvent(models.EventReportCreated, report)
+
+	return report, nil
+}
+
+/* Create a report raised by the system instead of a user, such as for an upload flagged by content scanning */
+func (p *PunishService) CreateSystemReport(reportedUID uint, reason string, details string) (*models.Report, error) {
+	if !models.IsValidReportReason(reason) {
+		return nil, errors.New("invalid report reason")
+	}
+
+	report := &models.Report{
+		ReporterUserID: models.SystemReporterID,
+		ReportedUserID: reportedUID,
+		Reason:         reason,
+		Details:        details,
+		Handled:        false,
+		HandledBy:      0,
+		CreatedAt:      time.Now(),
+	}
+
+	if err := p.DB.Create(report).Error; err != nil {
+		log.Printf("Error creating system report: %v", err)
+		return nil, errors.New("failed to create report")
+	}
+
+	reportCountKey := fmt.Sprintf("report_count:%d", reportedUID)
+	if err := p.Client.Incr(reportCountKey).Err(); err != nil {
+		log.Printf("Error incrementing report count: %v", err)
+	}
+
+	p.publishReportEvent(models.EventReportCreated, report)
+
 	return report, nil
 }
 
@@ -318,8 +367,9 @@ func (p *PunishService) GetOpenReports() ([]*models.ReportWithDetails, error) {
 			}
 		}
 
-		reporterUser, err := p.UserService.GetUserByUID(report.ReporterUserID)
-		if err == nil {
+		if report.ReporterUserID == models.SystemReporterID {
+			reportDetail.ReporterUsername = "System"
+		} else if reporterUser, err := p.UserService.GetUserByUID(report.ReporterUserID); err == nil {
 			reportDetail.ReporterUsername = reporterUser.Username
 		}
 
@@ -353,6 +403,8 @@ func (p *PunishService) HandleReport(reportID uint, staffID uint) error {
 		return err
 	}
 
+	p.publishReportEvent(models.EventReportUpdated, report)
+
 	return nil
 }
 
@@ -395,7 +447,13 @@ func (p *PunishService) AssignReportToStaff(reportID uint, staffID uint) error {
 	}
 
 	report.HandledBy = staffID
-	return p.DB.Save(report).Error
+	if err := p.DB.Save(report).Error; err != nil {
+		return err
+	}
+
+	p.publishReportEvent(models.EventReportUpdated, report)
+
+	return nil
 }
 
 /* Get report count */
@@ -425,9 +483,30 @@ func (p *PunishService) GetReport(reportID uint, staffID uint) (*models.ReportWi
 		report.HandledBy = staffID
 		if err := p.DB.Save(&report).Error; err != nil {
 			log.Printf("Error assigning report to staff: %v", err)
+		} else {
+			p.publishReportEvent(models.EventReportUpdated, &report)
 		}
 	}
 
+	return p.buildReportDetails(report), nil
+}
+
+/* Get report by ID without assigning it to a staff member */
+func (p *PunishService) GetReportDetails(reportID uint) (*models.ReportWithDetails, error) {
+	var report models.Report
+
+	err := p.DB.First(&report, reportID).Error
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, errors.New("report not found")
+		}
+		return nil, err
+	}
+
+	return p.buildReportDetails(report), nil
+}
+
+func (p *PunishService) buildReportDetails(report models.Report) *models.ReportWithDetails {
 	reportDetail := &models.ReportWithDetails{
 		Report: report,
 	}
@@ -466,10 +545,29 @@ func (p *PunishService) GetReport(reportID uint, staffID uint) (*models.ReportWi
 		reportDetail.OtherReporters = reporters
 	}
 
-	reporterUser, err := p.UserService.GetUserByUID(report.ReporterUserID)
-	if err == nil {
+	if report.ReporterUserID == models.SystemReporterID {
+		reportDetail.ReporterUsername = "System"
+	} else if reporterUser, err := p.UserService.GetUserByUID(report.ReporterUserID); err == nil {
 		reportDetail.ReporterUsername = reporterUser.Username
 	}
 
-	return reportDetail, nil
+	return reportDetail
+}
+
+/* Publish a report event so the Discord moderation queue can post or refresh the report */
+func (p *PunishService) publishReportEvent(eventType models.EventType, report *models.Report) {
+	if p.EventService == nil {
+		return
+	}
+
+	data := models.ReportEventData{
+		ReportID:       report.ID,
+		ReportedUserID: report.ReportedUserID,
+		Handled:        report.Handled,
+		HandledBy:      report.HandledBy,
+	}
+
+	if _, err := p.EventService.Publish(eventType, data); err != nil {
+		log.Printf("Error publishing report event: %v", err)
+	}
 }
@@ -4,6 +4,7 @@ import (
 	"encoding/json"
 	"errors"
 	"fmt"
+	"log"
 	"math/rand"
 	"time"
 
@@ -14,19 +15,20 @@ import (
 )
 
 type RedeemService struct {
-	DB           *gorm.DB
-	Client       *redis.Client
-	UserService  *UserService
-	BadgeService *BadgeService
-	EventService *EventService
+	DB                  *gorm.DB
+	Client              *redis.Client
+	UserService         *UserService
+	BadgeService        *BadgeService
+	EventService        *EventService
+	NotificationService *NotificationService
 }
 
-func NewRedeemService(db *gorm.DB, client *redis.Client) *RedeemService {
+func NewRedeemService(db *gorm.DB, client *redis.Client, badgeService *BadgeService) *RedeemService {
 	return &RedeemService{
 		DB:           db,
 		Client:       client,
 		UserService:  &UserService{DB: db, Client: client},
-		BadgeService: &BadgeService{DB: db, Client: client},
+		BadgeService: badgeService,
 	}
 }
 
@@ -94,9 +96,22 @@ func (rs *RedeemService) HandlePurchase(uid uint, productData models.StripeProdu
 		return errors.New("invalid product type")
 	}
 
+	rs.notifyPurchase(uid, productData.ProductName)
+
 	return nil
 }
 
+func (rs *RedeemService) notifyPurchase(uid uint, productName string) {
+	if rs.NotificationService == nil {
+		return
+	}
+
+	message := fmt.Sprintf("Your purchase of %s was successful. Thank you for supporting cutz.lol!", productName)
+	if err := rs.NotificationService.Notify(uid, models.NotificationCategoryBilling, "Purchase successful", message, "/dashboard"); err != nil {
+		log.Printf("Error sending purchase notification: %v", err)
+	}
+}
+
 func (rs *RedeemService) DeleteRedeemCode(invoiceID string) error {
 	if err := rs.DB.Where("invoice_id = ?", invoiceID).Delete(&models.RedeemCode{}).Error; err != nil {
 		return err
@@ -140,6 +155,13 @@ func (rs *RedeemService) RedeemCode(uid uint, code string) (string, error) {
 		return "", err
 	}
 
+	if rs.NotificationService != nil {
+		message := fmt.Sprintf("You successfully redeemed a %s code.", productData.ProductName)
+		if err := rs.NotificationService.Notify(uid, models.NotificationCategoryBilling, "Code redeemed", message, "/dashboard"); err != nil {
+			log.Printf("Error sending redeem notification: %v", err)
+		}
+	}
+
 	// Publish redeem code event
 	if rs.EventService != nil {
 		user, err := rs.UserService.GetUserByUID(uid)
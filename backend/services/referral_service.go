@@ -0,0 +1,462 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/go-redis/redis"
+	"github.com/hazebio/haze.bio_backend/models"
+	"github.com/hazebio/haze.bio_backend/utils"
+	"gorm.io/gorm"
+)
+
+type ReferralService struct {
+	DB                  *gorm.DB
+	Client              *redis.Client
+	UserService         *UserService
+	BadgeService        *BadgeService
+	AltAccountService   *AltAccountService
+	NotificationService *NotificationService
+}
+
+const (
+	referralCodeLength        = 8
+	maxReferralsPerDay        = 25
+	ReferralVerificationDelay = 24 * time.Hour
+)
+
+func NewReferralService(db *gorm.DB, client *redis.Client, badgeService *BadgeService) *ReferralService {
+	return &ReferralService{
+		DB:                db,
+		Client:            client,
+		UserService:       &UserService{DB: db, Client: client},
+		BadgeService:      badgeService,
+		AltAccountService: &AltAccountService{DB: db, Client: client},
+	}
+}
+
+/* Get the personal referral code of a user, creating it on first use */
+func (rs *ReferralService) GetOrCreateReferralCode(uid uint) (*models.InviteCode, error) {
+	var inviteCode models.InviteCode
+	err := rs.DB.Where("created_by = ? AND is_referral = ?", uid, true).First(&inviteCode).Error
+	if err == nil {
+		return &inviteCode, nil
+	}
+
+	if !errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, fmt.Errorf("error getting referral code: %w", err)
+	}
+
+	code := utils.GenerateRandomString(referralCodeLength)
+	for {
+		var existingCode models.InviteCode
+		if err := rs.DB.Where("code = ?", code).First(&existingCode).Error; err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				break
+			}
+			return nil, fmt.Errorf("error checking code uniqueness: %w", err)
+		}
+		code = utils.GenerateRandomString(referralCodeLength)
+	}
+
+	unlimited := 0
+	inviteCode = models.InviteCode{
+		Code:       code,
+		CreatedBy:  uid,
+		MaxUses:    &unlimited,
+		IsReferral: true,
+	}
+
+	if err := rs.DB.Create(&inviteCode).Error; err != nil {
+		return nil, fmt.Errorf("error creating referral code: %w", err)
+	}
+
+	log.Printf("Created referral code %s for user %d", code, uid)
+	return &inviteCode, nil
+}
+
+/* Attribute a new registration to the owner of the referral code */
+func (rs *ReferralService) RecordReferral(code string, referredID uint, ipAddress string) error {
+	var inviteCode models.InviteCode
+	if err := rs.DB.Where("code = ?", code).First(&inviteCode).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil
+		}
+		return err
+	}
+
+	if !inviteCode.IsReferral || inviteCode.CreatedBy == referredID {
+		return nil
+	}
+
+	referral := &models.Referral{
+		ReferrerID:   inviteCode.CreatedBy,
+		ReferredID:   referredID,
+		InviteCodeID: inviteCode.ID,
+		Status:       models.ReferralStatusPending,
+		IPAddress:    ipAddress,
+	}
+
+	if campaign, err := rs.GetRunningCampaign(); err == nil && campaign != nil {
+		referral.CampaignID = &campaign.ID
+	}
+
+	var todayCount int64
+	if err := rs.DB.Model(&models.Referral{}).
+		Where("referrer_id = ? AND created_at > ?", inviteCode.CreatedBy, time.Now().Add(-24*time.Hour)).
+		Count(&todayCount).Error; err != nil {
+		return err
+	}
+
+	if todayCount >= maxReferralsPerDay {
+		referral.Status = models.ReferralStatusRejected
+		referral.RejectReason = "daily referral limit reached"
+	}
+
+	if err := rs.DB.Create(referral).Error; err != nil {
+		if utils.IsError(err, utils.ErrDuplicateKey) {
+			return nil
+		}
+		log.Println("Error recording referral:", err)
+		return err
+	}
+
+	log.Printf("Recorded referral of user %d by user %d", referredID, inviteCode.CreatedBy)
+	return nil
+}
+
+/* Run the anti-abuse checks on a pending referral and grant rewards if it passes */
+func (rs *ReferralService) VerifyReferral(referralID uint) error {
+	var referral models.Referral
+	if err := rs.DB.Where("id = ? AND status = ?", referralID, models.ReferralStatusPending).First(&referral).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return errors.New("pending referral not found")
+		}
+		return err
+	}
+
+	reason, err := rs.checkReferralAbuse(&referral)
+	if err != nil {
+		return err
+	}
+
+	updates := map[string]interface{}{}
+	if reason != "" {
+		updates["status"] = models.ReferralStatusRejected
+		updates["reject_reason"] = reason
+	} else {
+		updates["status"] = models.ReferralStatusVerified
+		updates["verified_at"] = time.Now()
+	}
+
+	if err := rs.DB.Model(&referral).Updates(updates).Error; err != nil {
+		log.Println("Error updating referral:", err)
+		return err
+	}
+
+	if reason != "" {
+		log.Printf("Rejected referral %d: %s", referral.ID, reason)
+		return nil
+	}
+
+	rs.Client.Del("leaderboard_users_referrals")
+
+	return rs.EvaluateRewards(referral.ReferrerID)
+}
+
+func (rs *ReferralService) checkReferralAbuse(referral *models.Referral) (string, error) {
+	var referred models.User
+	if err := rs.DB.Where("uid = ?", referral.ReferredID).First(&referred).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return "referred account was deleted", nil
+		}
+		return "", err
+	}
+
+	var activePunishments int64
+	if err := rs.DB.Model(&models.Punishment{}).Where("user_id = ? AND active = ?", referral.ReferredID, true).Count(&activePunishments).Error; err != nil {
+		return "", err
+	}
+
+	if activePunishments > 0 {
+		return "referred account is restricted", nil
+	}
+
+	if rs.AltAccountService != nil {
+		alts, err := rs.AltAccountService.GetAltAccounts(referral.ReferredID)
+		if err != nil {
+			return "", err
+		}
+
+		for _, alt := range alts {
+			if alt.UID == referral.ReferrerID {
+				return "referred account is a flagged alt of the referrer", nil
+			}
+		}
+	}
+
+	sharedIPs, err := rs.Client.SInter(
+		fmt.Sprintf("user:%d:ips", referral.ReferrerID),
+		fmt.Sprintf("user:%d:ips", referral.ReferredID),
+	).Result()
+	if err != nil && err != redis.Nil {
+		return "", err
+	}
+
+	if len(sharedIPs) > 0 {
+		return "referred account shares an IP address with the referrer", nil
+	}
+
+	return "", nil
+}
+
+/* Grant every reward rule the referrer qualifies for and has not received yet */
+func (rs *ReferralService) EvaluateRewards(referrerID uint) error {
+	var rules []models.ReferralRewardRule
+	if err := rs.DB.Order("threshold ASC").Find(&rules).Error; err != nil {
+		return err
+	}
+
+	for _, rule := range rules {
+		query := rs.DB.Model(&models.Referral{}).Where("referrer_id = ? AND status = ?", referrerID, models.ReferralStatusVerified)
+		if rule.CampaignID != nil {
+			query = query.Where("campaign_id = ?", *rule.CampaignID)
+		}
+
+		var verified int64
+		if err := query.Count(&verified).Error; err != nil {
+			return err
+		}
+
+		if verified < int64(rule.Threshold) {
+			continue
+		}
+
+		reward := &models.ReferralReward{
+			ReferrerID: referrerID,
+			RuleID:     rule.ID,
+		}
+
+		if err := rs.DB.Create(reward).Error; err != nil {
+			if utils.IsError(err, utils.ErrDuplicateKey) {
+				continue
+			}
+			return err
+		}
+
+		if err := rs.grantReward(referrerID, &rule); err != nil {
+			log.Printf("Error granting referral reward %d to user %d: %v", rule.ID, referrerID, err)
+			rs.DB.Delete(reward)
+			continue
+		}
+
+		log.Printf("Granted referral reward %d to user %d", rule.ID, referrerID)
+	}
+
+	return nil
+}
+
+func (rs *ReferralService) grantReward(referrerID uint, rule *models.ReferralRewardRule) error {
+	var message string
+
+	switch rule.RewardType {
+	case models.ReferralRewardBadgeEditCredits:
+		if err := rs.UserService.AddBadgeEditCredits(referrerID, rule.Amount); err != nil {
+			return err
+		}
+		message = fmt.Sprintf("You invited %d verified users and received %d badge edit credits.", rule.Threshold, rule.Amount)
+	case models.ReferralRewardBadge:
+		if err := rs.BadgeService.AssignBadge(referrerID, rule.BadgeName); err != nil {
+			return err
+		}
+		message = fmt.Sprintf("You invited %d verified users and received the %s badge.", rule.Threshold, rule.BadgeName)
+	default:
+		return errors.New("invalid reward type")
+	}
+
+	if rs.NotificationService != nil {
+		if err := rs.NotificationService.Notify(referrerID, models.NotificationCategorySocial, "Referral reward unlocked", message, "/dashboard/referrals"); err != nil {
+			log.Printf("Error sending referral reward notification: %v", err)
+		}
+	}
+
+	return nil
+}
+
+/* Get the referral code, counts and referred users of a referrer */
+func (rs *ReferralService) GetReferralSummary(uid uint) (*models.ReferralSummary, error) {
+	inviteCode, err := rs.GetOrCreateReferralCode(uid)
+	if err != nil {
+		return nil, err
+	}
+
+	summary := &models.ReferralSummary{
+		Code:     inviteCode.Code,
+		Referred: []*models.ReferredUser{},
+	}
+
+	var counts []struct {
+		Status models.ReferralStatus
+		Count  int64
+	}
+	if err := rs.DB.Model(&models.Referral{}).
+		Select("status, COUNT(*) AS count").
+		Where("referrer_id = ?", uid).
+		Group("status").
+		Scan(&counts).Error; err != nil {
+		return nil, err
+	}
+
+	for _, c := range counts {
+		switch c.Status {
+		case models.ReferralStatusPending:
+			summary.Pending = c.Count
+		case models.ReferralStatusVerified:
+			summary.Verified = c.Count
+		case models.ReferralStatusRejected:
+			summary.Rejected = c.Count
+		}
+	}
+
+	if err := rs.DB.Table("referrals").
+		Select("users.username, users.display_name, referrals.status, referrals.created_at").
+		Joins("JOIN users ON users.uid = referrals.referred_id").
+		Where("referrals.referrer_id = ?", uid).
+		Order("referrals.created_at DESC").
+		Limit(100).
+		Scan(&summary.Referred).Error; err != nil {
+		return nil, err
+	}
+
+	return summary, nil
+}
+
+/* Get the campaign that new referrals are attributed to */
+func (rs *ReferralService) GetRunningCampaign() (*models.InviteCampaign, error) {
+	var campaigns []models.InviteCampaign
+	if err := rs.DB.Where("active = ?", true).Order("created_at DESC").Find(&campaigns).Error; err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	for _, campaign := range campaigns {
+		if campaign.IsRunning(now) {
+			return &campaign, nil
+		}
+	}
+
+	return nil, nil
+}
+
+/* Get all campaigns with their reward rules */
+func (rs *ReferralService) GetCampaigns() ([]*models.InviteCampaign, error) {
+	campaigns := []*models.InviteCampaign{}
+	if err := rs.DB.Preload("RewardRules").Order("created_at DESC").Find(&campaigns).Error; err != nil {
+		log.Println("Error getting invite campaigns:", err)
+		return nil, err
+	}
+
+	return campaigns, nil
+}
+
+/* Create a campaign */
+func (rs *ReferralService) CreateCampaign(campaign *models.InviteCampaign) error {
+	if campaign.Name == "" {
+		return errors.New("campaign name is required")
+	}
+
+	if campaign.StartsAt != nil && campaign.EndsAt != nil && campaign.EndsAt.Before(*campaign.StartsAt) {
+		return errors.New("end date must be after start date")
+	}
+
+	if err := rs.DB.Omit("RewardRules").Create(campaign).Error; err != nil {
+		if utils.IsError(err, utils.ErrDuplicateKey) {
+			return errors.New("campaign name already exists")
+		}
+		log.Println("Error creating invite campaign:", err)
+		return err
+	}
+
+	return nil
+}
+
+/* Update the fields of a campaign */
+func (rs *ReferralService) UpdateCampaign(campaignID uint, fields map[string]interface{}) error {
+	allowed := map[string]bool{"name": true, "description": true, "active": true, "starts_at": true, "ends_at": true}
+	updates := make(map[string]interface{})
+	for key, value := range fields {
+		if allowed[key] {
+			updates[key] = value
+		}
+	}
+
+	if len(updates) == 0 {
+		return errors.New("no valid fields to update")
+	}
+
+	result := rs.DB.Model(&models.InviteCampaign{}).Where("id = ?", campaignID).Updates(updates)
+	if result.Error != nil {
+		log.Println("Error updating invite campaign:", result.Error)
+		return result.Error
+	}
+
+	if result.RowsAffected == 0 {
+		return errors.New("campaign not found")
+	}
+
+	return nil
+}
+
+/* Add a reward rule, optionally scoped to a campaign */
+func (rs *ReferralService) AddRewardRule(rule *models.ReferralRewardRule) error {
+	if rule.Threshold <= 0 {
+		return errors.New("threshold must be greater than 0")
+	}
+
+	switch rule.RewardType {
+	case models.ReferralRewardBadgeEditCredits:
+		if rule.Amount <= 0 {
+			return errors.New("amount must be greater than 0")
+		}
+	case models.ReferralRewardBadge:
+		if _, err := rs.BadgeService.GetBadge(rule.BadgeName); err != nil {
+			return errors.New("badge not found")
+		}
+	default:
+		return errors.New("invalid reward type")
+	}
+
+	if rule.CampaignID != nil {
+		var count int64
+		if err := rs.DB.Model(&models.InviteCampaign{}).Where("id = ?", *rule.CampaignID).Count(&count).Error; err != nil {
+			return err
+		}
+		if count == 0 {
+			return errors.New("campaign not found")
+		}
+	}
+
+	if err := rs.DB.Create(rule).Error; err != nil {
+		log.Println("Error creating referral reward rule:", err)
+		return err
+	}
+
+	return nil
+}
+
+/* Delete a reward rule */
+func (rs *ReferralService) DeleteRewardRule(ruleID uint) error {
+	result := rs.DB.Where("id = ?", ruleID).Delete(&models.ReferralRewardRule{})
+	if result.Error != nil {
+		log.Println("Error deleting referral reward rule:", result.Error)
+		return result.Error
+	}
+
+	if result.RowsAffected == 0 {
+		return errors.New("reward rule not found")
+	}
+
+	return nil
+}
@@ -0,0 +1,34 @@
+package services
+
+import (
+	"testing"
+
+	"github.com/hazebio/haze.bio_backend/models"
+)
+
+func TestReferralCodeUnlimitedUses(t *testing.T) {
+	db := newTestDB(t, &models.InviteCode{})
+	client, _ := newFakeRedis(t)
+
+	rs := NewReferralService(db, client, NewBadgeService(db, client))
+	is := NewInviteService(db, client)
+
+	inviteCode, err := rs.GetOrCreateReferralCode(1)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for uid := uint(2); uid <= 4; uid++ {
+		if err := is.UseInviteCode(inviteCode.Code, uid); err != nil {
+			t.Fatalf("signup %d with referral code: %v", uid-1, err)
+		}
+	}
+
+	var stored models.InviteCode
+	if err := db.First(&stored, inviteCode.ID).Error; err != nil {
+		t.Fatal(err)
+	}
+	if stored.Limit() != 0 || stored.CurrentUses != 3 || stored.UsedBy != nil {
+		t.Fatalf("stored code = max %d, uses %d, used by %v", stored.Limit(), stored.CurrentUses, stored.UsedBy)
+	}
+}
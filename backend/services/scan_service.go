@@ -0,0 +1,247 @@
+package services
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/hazebio/haze.bio_backend/config"
+	"github.com/hazebio/haze.bio_backend/utils"
+)
+
+const (
+	scanTimeout = 30 * time.Second
+
+	clamAVScannerName = "clamav"
+	nsfwScannerName   = "nsfw"
+
+	// clamd reads INSTREAM data in chunks prefixed with their length
+	clamdChunkSize = 64 << 10
+)
+
+// ScanFinding is why a scanner refused to publish an upload
+type ScanFinding struct {
+	Scanner string
+	Label   string
+	Score   float64
+	// Report reason the uploader is reported for
+	Reason string
+}
+
+// ContentScanner inspects uploads before they are published. Scan returns a
+// finding for content that must not be published and an error when the
+// content could not be scanned at all.
+type ContentScanner interface {
+	Name() string
+	Scan(data []byte, kind utils.MediaKind) (*ScanFinding, error)
+}
+
+type ScanService struct {
+	Scanners []ContentScanner
+	// Reject uploads when a scanner fails instead of publishing them unscanned
+	FailClosed bool
+}
+
+func NewScanService() *ScanService {
+	scanners := []ContentScanner{}
+	if config.ClamAVAddress != "" {
+		scanners = append(scanners, &ClamAVScanner{Address: config.ClamAVAddress})
+	}
+
+	var classifier NSFWClassifier = StubNSFWClassifier{}
+	if config.NSFWClassifierURL != "" {
+		classifier = &HTTPNSFWClassifier{
+			URL:    config.NSFWClassifierURL,
+			Client: &http.Client{Timeout: scanTimeout},
+		}
+	}
+
+	scanners = append(scanners, &NSFWScanner{
+		Classifier: classifier,
+		Threshold:  config.NSFWThreshold,
+	})
+
+	return &ScanService{
+		Scanners:   scanners,
+		FailClosed: config.ScanFailClosed,
+	}
+}
+
+/* Run the upload through every scanner and return the first finding */
+func (ss *ScanService) Scan(data []byte, kind utils.MediaKind) (*ScanFinding, error) {
+	for _, scanner := range ss.Scanners {
+		finding, err := scanner.Scan(data, kind)
+		if err != nil {
+			if ss.FailClosed {
+				return nil, fmt.Errorf("%s scan failed: %w", scanner.Name(), err)
+			}
+
+			log.Printf("Error scanning upload with %s, continuing without it: %v", scanner.Name(), err)
+			continue
+		}
+
+		if finding != nil {
+			return finding, nil
+		}
+	}
+
+	return nil, nil
+}
+
+// ClamAVScanner streams uploads to a clamd daemon, Address is the path of its
+// local socket or host:port when clamd listens on TCP
+type ClamAVScanner struct {
+	Address string
+}
+
+func (s *ClamAVScanner) Name() string {
+	return clamAVScannerName
+}
+
+/* Scan with clamd's INSTREAM command, which answers "stream: OK" or "stream: {signature} FOUND" */
+func (s *ClamAVScanner) Scan(data []byte, kind utils.MediaKind) (*ScanFinding, error) {
+	network := "tcp"
+	if strings.HasPrefix(s.Address, "/") {
+		network = "unix"
+	}
+
+	conn, err := net.DialTimeout(network, s.Address, scanTimeout)
+	if err != nil {
+		return nil, fmt.Errorf("failed to connect to clamd: %w", err)
+	}
+	defer conn.Close()
+
+	conn.SetDeadline(time.Now().Add(scanTimeout))
+
+	if _, err := conn.Write([]byte("zINSTREAM\x00")); err != nil {
+		return nil, err
+	}
+
+	size := make([]byte, 4)
+	for offset := 0; offset < len(data); offset += clamdChunkSize {
+		end := offset + clamdChunkSize
+		if end > len(data) {
+			end = len(data)
+		}
+
+		binary.BigEndian.PutUint32(size, uint32(end-offset))
+		if _, err := conn.Write(size); err != nil {
+			return nil, err
+		}
+		if _, err := conn.Write(data[offset:end]); err != nil {
+			return nil, err
+		}
+	}
+
+	// A zero length chunk ends the stream
+	binary.BigEndian.PutUint32(size, 0)
+	if _, err := conn.Write(size); err != nil {
+		return nil, err
+	}
+
+	reply, err := io.ReadAll(conn)
+	if err != nil {
+		return nil, err
+	}
+
+	result := strings.TrimSpace(strings.TrimRight(string(reply), "\x00"))
+	result = strings.TrimPrefix(result, "stream: ")
+
+	switch {
+	case result == "OK":
+		return nil, nil
+	case strings.HasSuffix(result, " FOUND"):
+		return &ScanFinding{
+			Scanner: s.Name(),
+			Label:   strings.TrimSuffix(result, " FOUND"),
+			Score:   1,
+			Reason:  "Other",
+		}, nil
+	}
+
+	return nil, fmt.Errorf("clamd: %s", result)
+}
+
+// NSFWClassifier scores how likely an image is explicit, from 0 to 1, and
+// names what it detected
+type NSFWClassifier interface {
+	Classify(data []byte) (float64, string, error)
+}
+
+// HTTPNSFWClassifier posts images to a classification service, which answers
+// with JSON like {"score": 0.97, "label": "porn"}
+type HTTPNSFWClassifier struct {
+	URL    string
+	Client *http.Client
+}
+
+func (c *HTTPNSFWClassifier) Classify(data []byte) (float64, string, error) {
+	resp, err := c.Client.Post(c.URL, "application/octet-stream", bytes.NewReader(data))
+	if err != nil {
+		return 0, "", err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+		return 0, "", fmt.Errorf("classifier returned status %d: %s", resp.StatusCode, body)
+	}
+
+	var result struct {
+		Score float64 `json:"score"`
+		Label string  `json:"label"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return 0, "", fmt.Errorf("invalid classifier response: %w", err)
+	}
+
+	return result.Score, result.Label, nil
+}
+
+// StubNSFWClassifier scores every image as safe, it stands in for the
+// classification service in development
+type StubNSFWClassifier struct{}
+
+func (StubNSFWClassifier) Classify(data []byte) (float64, string, error) {
+	return 0, "", nil
+}
+
+// NSFWScanner flags images the classifier scores at or above Threshold.
+// Video and audio are not classified.
+type NSFWScanner struct {
+	Classifier NSFWClassifier
+	Threshold  float64
+}
+
+func (s *NSFWScanner) Name() string {
+	return nsfwScannerName
+}
+
+func (s *NSFWScanner) Scan(data []byte, kind utils.MediaKind) (*ScanFinding, error) {
+	if kind != utils.MediaKindImage {
+		return nil, nil
+	}
+
+	score, label, err := s.Classifier.Classify(data)
+	if err != nil {
+		return nil, err
+	}
+
+	if score < s.Threshold {
+		return nil, nil
+	}
+
+	return &ScanFinding{
+		Scanner: s.Name(),
+		Label:   label,
+		Score:   score,
+		Reason:  "Inappropriate Content",
+	}, nil
+}
@@ -73,6 +73,8 @@ func (s *SocialService) CreateUserSocial(social *models.UserSocial) error {
 		}
 	}
 
+	social.ImageURL = ProxyMediaURL(social.ImageURL)
+
 	if err := s.DB.Create(social).Error; err != nil {
 		log.Println("Error creating user social:", err)
 		return err
@@ -104,6 +106,8 @@ func (s *SocialService) UpdateUserSocial(social *models.UserSocial) error {
 		return err
 	}
 
+	social.ImageURL = ProxyMediaURL(social.ImageURL)
+
 	err := s.DB.Model(social).Where("uid = ? AND id = ?", social.UID, social.ID).Omit("platform").Select("*").Updates(social).Error
 	if err != nil {
 		return err
@@ -0,0 +1,255 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/go-redis/redis"
+	"github.com/hazebio/haze.bio_backend/config"
+	"github.com/hazebio/haze.bio_backend/models"
+	"gorm.io/gorm"
+)
+
+// Objects younger than this are never treated as orphans, they may belong to
+// an upload whose database row has not been written yet
+const storageOrphanGracePeriod = 24 * time.Hour
+
+type StorageService struct {
+	DB          *gorm.DB
+	Client      *redis.Client
+	FileService *FileService
+}
+
+func NewStorageService(db *gorm.DB, client *redis.Client) *StorageService {
+	return &StorageService{
+		DB:          db,
+		Client:      client,
+		FileService: NewFileService(db, client),
+	}
+}
+
+// storageReference is a database row pointing at a URL in the bucket
+type storageReference struct {
+	table string
+	rowID uint
+	field string
+	url   string
+}
+
+/*
+Cross-reference every object in the bucket with the URLs stored in the
+database. Objects nothing points at are reported as orphans and deleted
+unless dryRun is set, rows pointing at objects that don't exist are reported
+as missing. Rows are only ever reported, never changed.
+*/
+func (ss *StorageService) Reconcile(dryRun bool) (*models.StorageReconcileReport, error) {
+	if config.R2PublicURL == "" {
+		return nil, errors.New("storage is not configured")
+	}
+
+	references, err := ss.collectReferences()
+	if err != nil {
+		return nil, err
+	}
+
+	referenced := make(map[string]bool, len(references))
+	for _, ref := range references {
+		if key, ok := storageKey(ref.url); ok {
+			referenced[key] = true
+		}
+	}
+
+	report := &models.StorageReconcileReport{
+		DryRun:     dryRun,
+		Referenced: len(referenced),
+		Orphans:    []models.StoredObject{},
+		Missing:    []models.MissingObject{},
+	}
+
+	stored := make(map[string]bool)
+	cutoff := time.Now().Add(-storageOrphanGracePeriod)
+	// Media proxy copies are only referenced from the cache, they are kept until it expires
+	proxyCutoff := cutoff.Add(-mediaProxyCacheTTL)
+	cursor := ""
+	for {
+		objects, next, err := ss.FileService.ListObjects("", cursor)
+		if err != nil {
+			return nil, err
+		}
+
+		for _, obj := range objects {
+			stored[obj.Key] = true
+			report.Objects++
+
+			if referenced[obj.Key] || obj.LastModified.After(cutoff) {
+				continue
+			}
+
+			if strings.HasPrefix(obj.Key, mediaProxyKeyPrefix) && obj.LastModified.After(proxyCutoff) {
+				continue
+			}
+
+			report.Orphans = append(report.Orphans, obj)
+			report.OrphanSize += obj.Size
+		}
+
+		if next == "" {
+			break
+		}
+		cursor = next
+	}
+
+	for _, ref := range references {
+		key, ok := storageKey(ref.url)
+		if !ok || stored[key] {
+			continue
+		}
+
+		report.Missing = append(report.Missing, models.MissingObject{
+			Table: ref.table,
+			RowID: ref.rowID,
+			Field: ref.field,
+			URL:   ref.url,
+		})
+	}
+
+	if !dryRun {
+		for _, obj := range report.Orphans {
+			if err := ss.FileService.deleteObject(obj.Key); err != nil {
+				log.Printf("Error deleting orphaned object %s: %v", obj.Key, err)
+				continue
+			}
+			report.Deleted++
+		}
+	}
+
+	return report, nil
+}
+
+/* Collect every URL stored in the database that may point into the bucket */
+func (ss *StorageService) collectReferences() ([]storageReference, error) {
+	references := []storageReference{}
+	add := func(table string, rowID uint, field string, url string) {
+		if url != "" {
+			references = append(references, storageReference{table, rowID, field, url})
+		}
+	}
+
+	var profiles []models.UserProfile
+	err := ss.DB.Select("uid", "avatar_url", "background_url", "audio_url", "cursor_url", "banner_url", "media_variants").
+		Find(&profiles).Error
+	if err != nil {
+		return nil, err
+	}
+
+	for _, profile := range profiles {
+		for _, field := range profileMediaFields {
+			add("user_profiles", profile.UID, field, profileMediaURL(&profile, field))
+		}
+
+		for field, media := range profile.MediaVariants {
+			for _, variant := range media.Variants {
+				add("user_profiles", profile.UID, fmt.Sprintf("media_variants.%s.%s", field, variant.Label), variant.URL)
+			}
+		}
+	}
+
+	var badges []models.Badge
+	if err := ss.DB.Select("id", "media_url").Find(&badges).Error; err != nil {
+		return nil, err
+	}
+
+	for _, badge := range badges {
+		add("badges", badge.ID, "media_url", badge.MediaURL)
+	}
+
+	var edits []models.BadgeEdit
+	err = ss.DB.Select("id", "old_media_url", "new_media_url").
+		Where("status = ?", models.BadgeEditPending).
+		Find(&edits).Error
+	if err != nil {
+		return nil, err
+	}
+
+	for _, edit := range edits {
+		add("badge_edits", edit.ID, "old_media_url", edit.OldMediaURL)
+		add("badge_edits", edit.ID, "new_media_url", edit.NewMediaURL)
+	}
+
+	var socials []models.UserSocial
+	if err := ss.DB.Select("id", "image_url").Where("image_url IS NOT NULL AND image_url <> ''").Find(&socials).Error; err != nil {
+		return nil, err
+	}
+
+	for _, social := range socials {
+		add("user_socials", social.ID, "image_url", social.ImageURL)
+	}
+
+	// Expired exports are removed from the bucket by the file-upload service
+	var exports []models.DataExport
+	err = ss.DB.Select("id", "file_url").
+		Where("file_url IS NOT NULL AND file_url <> '' AND expires_at > ?", time.Now()).
+		Find(&exports).Error
+	if err != nil {
+		return nil, err
+	}
+
+	for _, export := range exports {
+		add("data_exports", export.ID, "file_url", export.FileURL)
+	}
+
+	var templates []models.Template
+	if err := ss.DB.Select("id", "banner_url", "template_data").Find(&templates).Error; err != nil {
+		return nil, err
+	}
+
+	urlPattern := regexp.MustCompile(regexp.QuoteMeta(config.R2PublicURL+"/") + `[^"'\s\\)]+`)
+	for _, template := range templates {
+		add("templates", template.ID, "banner_url", template.BannerURL)
+		for _, url := range urlPattern.FindAllString(template.TemplateData, -1) {
+			add("templates", template.ID, "template_data", url)
+		}
+	}
+
+	// Quarantined objects are deleted when a moderator resolves them
+	var quarantined []models.QuarantinedFile
+	err = ss.DB.Select("id", "key").Where("status = ?", models.QuarantinePending).Find(&quarantined).Error
+	if err != nil {
+		return nil, err
+	}
+
+	for _, file := range quarantined {
+		add("quarantined_files", file.ID, "key", fmt.Sprintf("%s/%s", config.R2PublicURL, file.Key))
+	}
+
+	// Removed library files stay referenced until the garbage collector deletes them
+	var files []models.UserFile
+	if err := ss.DB.Select("id", "url").Find(&files).Error; err != nil {
+		return nil, err
+	}
+
+	for _, file := range files {
+		add("user_files", file.ID, "url", file.URL)
+	}
+
+	return references, nil
+}
+
+/* Bucket key of a public URL, false for URLs outside the bucket */
+func storageKey(fileURL string) (string, bool) {
+	prefix := config.R2PublicURL + "/"
+	if !strings.HasPrefix(fileURL, prefix) {
+		return "", false
+	}
+
+	key := strings.TrimPrefix(fileURL, prefix)
+	if i := strings.IndexAny(key, "?#"); i >= 0 {
+		key = key[:i]
+	}
+
+	return key, key != ""
+}
@@ -337,6 +337,21 @@ func (ts *TemplateService) ApplyTemplate(userID uint, templateID uint, confirmPr
 	return nil
 }
 
+/* Profile a template produces when applied to an empty profile, used for previews */
+func (ts *TemplateService) PreviewProfile(template *models.Template) (*models.UserProfile, error) {
+	var templateData map[string]interface{}
+	if err := json.Unmarshal([]byte(template.TemplateData), &templateData); err != nil {
+		return nil, fmt.Errorf("invalid template data: %w", err)
+	}
+
+	profile := &models.UserProfile{UID: template.CreatorID}
+	if err := ts.applyTemplateToProfile(profile, templateData, true); err != nil {
+		return nil, err
+	}
+
+	return profile, nil
+}
+
 /* Apply template data to user profile */
 func (ts *TemplateService) applyTemplateToProfile(profile *models.UserProfile, templateData map[string]interface{}, isPremium bool) error {
 	premiumFeatures := models.NewPremiumFeatures()
@@ -345,11 +360,11 @@ func (ts *TemplateService) applyTemplateToProfile(profile *models.UserProfile, t
 
 	// Images
 	if avatarURL, exists := templateData["avatar_url"].(string); exists {
-		profile.AvatarURL = avatarURL
+		profile.AvatarURL = ProxyMediaURL(avatarURL)
 	}
 
 	if backgroundURL, exists := templateData["background_url"].(string); exists {
-		profile.BackgroundURL = backgroundURL
+		profile.BackgroundURL = ProxyMediaURL(backgroundURL)
 	}
 
 	if audioURL, exists := templateData["audio_url"].(string); exists {
@@ -357,11 +372,11 @@ func (ts *TemplateService) applyTemplateToProfile(profile *models.UserProfile, t
 	}
 
 	if cursorURL, exists := templateData["cursor_url"].(string); exists {
-		profile.CursorURL = cursorURL
+		profile.CursorURL = ProxyMediaURL(cursorURL)
 	}
 
 	if bannerURL, exists := templateData["banner_url"].(string); exists {
-		profile.BannerURL = bannerURL
+		profile.BannerURL = ProxyMediaURL(bannerURL)
 	}
 
 	// Colors
@@ -748,6 +763,7 @@ func (ts *TemplateService) GetTemplateStats() (map[string]interface{}, error) {
 func (ts *TemplateService) invalidateTemplateCache(templateID uint) {
 	cacheKey := fmt.Sprintf("%s%d", templateCachePrefix, templateID)
 	ts.Client.Del(cacheKey)
+	invalidateCards(ts.Client, templateCardSubject(templateID))
 }
 
 /* Helper to invalidate shareable templates cache */
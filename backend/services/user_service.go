@@ -26,6 +26,7 @@ type UserService struct {
 	BotSession          *discordgo.Session
 
 	EventService        *EventService
+	NotificationService *NotificationService
 }
 
 const (
@@ -200,6 +201,24 @@ func (us *UserService) SearchUsers(query string, limit int) ([]*models.User, err
 	return users, nil
 }
 
+/* Get usernames starting with the given prefix, used for command autocompletion */
+func (us *UserService) AutocompleteUsernames(prefix string, limit int) ([]string, error) {
+	usernames := []string{}
+
+	prefix = strings.NewReplacer("\\", "\\\\", "%", "\\%", "_", "\\_").Replace(strings.ToLower(prefix))
+	err := us.DB.Model(&models.User{}).
+		Where("LOWER(username) LIKE ?", prefix+"%").
+		Order("username ASC").
+		Limit(limit).
+		Pluck("username", &usernames).Error
+	if err != nil {
+		log.Println("Error autocompleting usernames:", err)
+		return nil, err
+	}
+
+	return usernames, nil
+}
+
 /* Get Stats */
 func (us *UserService) GetStats() (*models.Stats, error) {
 	cacheKey := "site:stats"
@@ -804,6 +823,8 @@ func (us *UserService) UpdatePassword(uid uint, currentPassword string, newPassw
 		return err
 	}
 
+	us.notifyPasswordChanged(uid)
+
 	return nil
 }
 
@@ -820,9 +841,22 @@ func (us *UserService) UpdatePasswordWithoutCurrentPassword(uid uint, newPasswor
 		return err
 	}
 
+	us.notifyPasswordChanged(uid)
+
 	return nil
 }
 
+func (us *UserService) notifyPasswordChanged(uid uint) {
+	if us.NotificationService == nil {
+		return
+	}
+
+	message := "The password of your account was just changed. If this wasn't you, reset your password immediately and contact support."
+	if err := us.NotificationService.Notify(uid, models.NotificationCategorySecurity, "Your password was changed", message, "/dashboard/settings"); err != nil {
+		log.Printf("Error sending password change notification: %v", err)
+	}
+}
+
 /* Top 10 users by views */
 type TopUser struct {
 	Username string `json:"username"`
@@ -968,6 +1002,16 @@ func (us *UserService) DeleteUser(uid uint, deletedBy uint) error {
 		return fmt.Errorf("error deleting user badges: %w", err)
 	}
 
+	if err := tx.Where("uid = ?", uid).Delete(&models.BadgeRuleGrant{}).Error; err != nil {
+		tx.Rollback()
+		return fmt.Errorf("error deleting badge rule grants: %w", err)
+	}
+
+	if err := tx.Where("uid = ?", uid).Delete(&models.BadgeEdit{}).Error; err != nil {
+		tx.Rollback()
+		return fmt.Errorf("error deleting badge edits: %w", err)
+	}
+
 	// 4. User socials
 	if err := tx.Where("uid = ?", uid).Delete(&models.UserSocial{}).Error; err != nil {
 		tx.Rollback()
@@ -986,6 +1030,17 @@ func (us *UserService) DeleteUser(uid uint, deletedBy uint) error {
 		return fmt.Errorf("error deleting user subscription: %w", err)
 	}
 
+	// Uploaded files are deleted from the bucket by the garbage collector
+	if err := tx.Model(&models.UserFile{}).Where("uid = ? AND removed_at IS NULL", uid).Update("removed_at", time.Now()).Error; err != nil {
+		tx.Rollback()
+		return fmt.Errorf("error removing user files: %w", err)
+	}
+
+	if err := tx.Where("uid = ?", uid).Delete(&models.UploadGrant{}).Error; err != nil {
+		tx.Rollback()
+		return fmt.Errorf("error deleting user upload grants: %w", err)
+	}
+
 	// 7. User profile
 	if err := tx.Where("uid = ?", uid).Delete(&models.UserProfile{}).Error; err != nil {
 		tx.Rollback()
@@ -1028,7 +1083,29 @@ func (us *UserService) DeleteUser(uid uint, deletedBy uint) error {
 		return fmt.Errorf("error deleting user invite codes: %w", err)
 	}
 
-	// 14. Finally, delete the user
+	// 14. Referrals (as referrer and referred) and granted referral rewards
+	if err := tx.Where("referrer_id = ? OR referred_id = ?", uid, uid).Delete(&models.Referral{}).Error; err != nil {
+		tx.Rollback()
+		return fmt.Errorf("error deleting user referrals: %w", err)
+	}
+
+	if err := tx.Where("referrer_id = ?", uid).Delete(&models.ReferralReward{}).Error; err != nil {
+		tx.Rollback()
+		return fmt.Errorf("error deleting user referral rewards: %w", err)
+	}
+
+	// 15. User notifications and notification preferences
+	if err := tx.Where("uid = ?", uid).Delete(&models.Notification{}).Error; err != nil {
+		tx.Rollback()
+		return fmt.Errorf("error deleting user notifications: %w", err)
+	}
+
+	if err := tx.Where("uid = ?", uid).Delete(&models.NotificationPreference{}).Error; err != nil {
+		tx.Rollback()
+		return fmt.Errorf("error deleting user notification preferences: %w", err)
+	}
+
+	// 16. Finally, delete the user
 	if err := tx.Where("uid = ?", uid).Delete(&models.User{}).Error; err != nil {
 		tx.Rollback()
 		return fmt.Errorf("error deleting user: %w", err)
@@ -16,10 +16,11 @@ import (
 )
 
 type ViewService struct {
-	DB               *gorm.DB
-	Client           *redis.Client
-	ProfileService   *ProfileService
-	AnalyticsService *AnalyticsService
+	DB                  *gorm.DB
+	Client              *redis.Client
+	ProfileService      *ProfileService
+	AnalyticsService    *AnalyticsService
+	NotificationService *NotificationService
 }
 
 type IPValidationResponse struct {
@@ -215,6 +216,12 @@ func (vs *ViewService) IncrementViewCount(uid uint, sessionID string, headers ma
 		}
 	}
 
+	if vs.NotificationService != nil {
+		if err := vs.NotificationService.CheckViewMilestone(uid); err != nil {
+			log.Printf("Error checking view milestone: %v", err)
+		}
+	}
+
 	log.Println("Incremented view count for user:", uid)
 	return nil
 }
@@ -23,6 +23,8 @@ func (ets *EmailTemplateService) GetTemplate(templateName string) (string, error
 		return ets.getPunishmentNotificationTemplate(), nil
 	case "application_status":
 		return ets.getApplicationStatusTemplate(), nil
+	case "notification":
+		return ets.getNotificationTemplate(), nil
 	default:
 		return "", fmt.Errorf("template '%s' not found", templateName)
 	}
@@ -711,3 +713,137 @@ func (ets *EmailTemplateService) getApplicationStatusTemplate() string {
 </body>
 </html>`
 }
+
+// getNotificationTemplate returns the generic notification template
+func (ets *EmailTemplateService) getNotificationTemplate() string {
+	return `<!DOCTYPE html>
+<html lang="en">
+<head>
+    <meta charset="UTF-8">
+    <meta name="viewport" content="width=device-width, initial-scale=1.0">
+    <title>%Title%</title>
+    <style>
+        body {
+            font-family: -apple-system, BlinkMacSystemFont, 'Segoe UI', Roboto, sans-serif;
+            background-color: #0a0a0a;
+            margin: 0;
+            padding: 20px;
+            color: #ffffff;
+        }
+        .container {
+            max-width: 600px;
+            margin: 0 auto;
+            background-color: #1a1a1a;
+            border-radius: 12px;
+            overflow: hidden;
+            box-shadow: 0 4px 6px rgba(0, 0, 0, 0.3);
+        }
+        .header {
+            background: #4c1d95;
+            padding: 30px;
+            text-align: center;
+        }
+        .header h1 {
+            margin: 0;
+            font-size: 24px;
+            font-weight: 600;
+            color: #ffffff;
+        }
+        .content {
+            padding: 40px 30px;
+        }
+        .intro-text {
+            font-size: 16px;
+            line-height: 1.6;
+            margin-bottom: 30px;
+            color: #ffffff;
+        }
+        .button {
+            display: inline-block;
+            background: #4c1d95;
+            color: #ffffff;
+            text-decoration: none;
+            padding: 15px 30px;
+            border-radius: 8px;
+            font-weight: 600;
+            font-size: 16px;
+            margin: 20px 0;
+            transition: transform 0.2s ease;
+        }
+        .button:hover {
+            transform: translateY(-2px);
+        }
+        .footer {
+            background-color: #0f0f0f;
+            padding: 30px;
+            text-align: center;
+        }
+        .help-links {
+            margin-bottom: 20px;
+        }
+        .help-links a {
+            color: #667eea;
+            text-decoration: none;
+            margin: 0 10px;
+        }
+        .help-links a:hover {
+            text-decoration: underline;
+        }
+        .legal-links {
+            margin-bottom: 15px;
+        }
+        .legal-links a {
+            color: #ffffff;
+            text-decoration: none;
+            margin: 0 10px;
+            font-size: 14px;
+        }
+        .legal-links a:hover {
+            text-decoration: underline;
+        }
+        .copyright {
+            font-size: 12px;
+            color: #808080;
+        }
+    </style>
+</head>
+<body>
+    <div class="container">
+        <div class="header">
+            <h1>%Title%</h1>
+        </div>
+        
+        <div class="content">
+            <p class="intro-text">
+                %Message%
+            </p>
+            
+            <div style="text-align: center;">
+                <a href="%Link%" class="button">
+                    Open cutz.lol
+                </a>
+            </div>
+            
+            <p style="font-size: 14px; color: #b0b0b0; margin-top: 30px;">
+                You are receiving this email because of your notification settings. You can change them in your dashboard at any time.
+            </p>
+        </div>
+        
+        <div class="footer">
+            <div class="help-links">
+                Need help? Contact us via <a href="https://discord.gg/cutz">Discord</a> or email <a href="mailto:help@cutz.lol">help@cutz.lol</a>
+            </div>
+            
+            <div class="legal-links">
+                <a href="https://cutz.lol/terms">Terms</a>
+                <a href="https://cutz.lol/privacy">Privacy</a>
+            </div>
+            
+            <div class="copyright">
+                © 2025 cutz.lol. All rights reserved.
+            </div>
+        </div>
+    </div>
+</body>
+</html>`
+}
@@ -0,0 +1,204 @@
+package utils
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"image"
+	_ "image/gif"
+	_ "image/jpeg"
+	_ "image/png"
+
+	_ "golang.org/x/image/webp"
+)
+
+// ImageLimits bounds the size, dimensions and frame count of an uploaded image
+type ImageLimits struct {
+	MaxBytes  int
+	MaxWidth  int
+	MaxHeight int
+	MaxFrames int
+}
+
+// CustomBadgeImageLimits applies to custom badge media
+var CustomBadgeImageLimits = ImageLimits{
+	MaxBytes:  2 * 1024 * 1024,
+	MaxWidth:  512,
+	MaxHeight: 512,
+	MaxFrames: 150,
+}
+
+// MediaError is returned when an upload is rejected because of its content, the message is safe to show to users
+type MediaError struct {
+	message string
+}
+
+func (e *MediaError) Error() string {
+	return e.message
+}
+
+func NewMediaError(format string, args ...interface{}) error {
+	return &MediaError{message: fmt.Sprintf(format, args...)}
+}
+
+func IsMediaError(err error) bool {
+	var mediaErr *MediaError
+	return errors.As(err, &mediaErr)
+}
+
+/* Check an image against the limits and return its format as detected from the content */
+func ValidateImage(data []byte, limits ImageLimits) (string, error) {
+	if len(data) == 0 {
+		return "", NewMediaError("file is empty")
+	}
+
+	if limits.MaxBytes > 0 && len(data) > limits.MaxBytes {
+		return "", NewMediaError("file size should not exceed %dKB", limits.MaxBytes/1024)
+	}
+
+	config, format, err := image.DecodeConfig(bytes.NewReader(data))
+	if err != nil {
+		return "", NewMediaError("unsupported image format, use png, jpeg, gif or webp")
+	}
+
+	if config.Width <= 0 || config.Height <= 0 {
+		return "", NewMediaError("invalid image dimensions")
+	}
+
+	if (limits.MaxWidth > 0 && config.Width > limits.MaxWidth) || (limits.MaxHeight > 0 && config.Height > limits.MaxHeight) {
+		return "", NewMediaError("image should not be larger than %dx%d pixels", limits.MaxWidth, limits.MaxHeight)
+	}
+
+	if limits.MaxFrames > 0 {
+		frames, err := ImageFrameCount(data, format, limits.MaxFrames)
+		if err != nil {
+			return "", NewMediaError("image is corrupted")
+		}
+
+		if frames > limits.MaxFrames {
+			return "", NewMediaError("animated images should not have more than %d frames", limits.MaxFrames)
+		}
+	}
+
+	return format, nil
+}
+
+/* Count the frames of a gif or webp image, counting stops once the limit is exceeded */
+func ImageFrameCount(data []byte, format string, limit int) (int, error) {
+	switch format {
+	case "gif":
+		return countGIFFrames(data, limit)
+	case "webp":
+		return countWebPFrames(data, limit)
+	}
+	return 1, nil
+}
+
+/* File extension for an image format returned by ValidateImage */
+func ImageExtension(format string) string {
+	if format == "jpeg" {
+		return ".jpg"
+	}
+	return "." + format
+}
+
+/*
+Count the frames of a GIF by walking its blocks instead of decoding it, a
+small file can hold thousands of tiny frames that are expensive to decode.
+Counting stops once the limit is exceeded.
+*/
+func countGIFFrames(data []byte, limit int) (int, error) {
+	if len(data) < 13 {
+		return 0, errors.New("gif header too short")
+	}
+
+	pos := 13
+	if flags := data[10]; flags&0x80 != 0 {
+		pos += 3 * (1 << ((flags & 0x07) + 1))
+	}
+
+	skipSubBlocks := func() error {
+		for {
+			if pos >= len(data) {
+				return errors.New("unexpected end of gif")
+			}
+			size := int(data[pos])
+			pos++
+			if size == 0 {
+				return nil
+			}
+			pos += size
+		}
+	}
+
+	frames := 0
+	for pos < len(data) {
+		switch data[pos] {
+		case 0x21: // Extension
+			pos += 2
+			if err := skipSubBlocks(); err != nil {
+				return 0, err
+			}
+		case 0x2C: // Image descriptor
+			frames++
+			if frames > limit {
+				return frames, nil
+			}
+
+			if pos+10 > len(data) {
+				return 0, errors.New("unexpected end of gif")
+			}
+			flags := data[pos+9]
+			pos += 10
+			if flags&0x80 != 0 {
+				pos += 3 * (1 << ((flags & 0x07) + 1))
+			}
+
+			// LZW minimum code size
+			pos++
+			if err := skipSubBlocks(); err != nil {
+				return 0, err
+			}
+		case 0x3B: // Trailer
+			return frames, nil
+		default:
+			return 0, errors.New("invalid gif block")
+		}
+	}
+
+	return frames, nil
+}
+
+/* Count the ANMF chunks of an animated WebP, still images count as one frame */
+func countWebPFrames(data []byte, limit int) (int, error) {
+	if len(data) < 12 || string(data[0:4]) != "RIFF" || string(data[8:12]) != "WEBP" {
+		return 0, errors.New("invalid webp header")
+	}
+
+	frames := 0
+	pos := 12
+	for pos+8 <= len(data) {
+		chunk := string(data[pos : pos+4])
+		size := int(binary.LittleEndian.Uint32(data[pos+4 : pos+8]))
+		if size < 0 || pos+8+size > len(data) {
+			return 0, errors.New("invalid webp chunk")
+		}
+
+		if chunk == "ANMF" {
+			frames++
+			if frames > limit {
+				return frames, nil
+			}
+		}
+
+		// Chunks are padded to an even size
+		pos += 8 + size + size%2
+	}
+
+	if frames == 0 {
+		frames = 1
+	}
+
+	return frames, nil
+}
@@ -0,0 +1,131 @@
+package utils
+
+import (
+	"bytes"
+	"encoding/binary"
+)
+
+type MediaKind string
+
+const (
+	MediaKindImage MediaKind = "image"
+	MediaKindVideo MediaKind = "video"
+	MediaKindAudio MediaKind = "audio"
+)
+
+// MediaType is the type of an upload as detected from its content
+type MediaType struct {
+	Kind      MediaKind
+	Format    string
+	Extension string
+}
+
+/* Detect the type of an upload from its magic bytes, the file name is never trusted */
+func DetectMediaType(data []byte) (MediaType, error) {
+	switch {
+	case bytes.HasPrefix(data, []byte("\x89PNG\r\n\x1a\n")):
+		return MediaType{MediaKindImage, "png", ".png"}, nil
+	case bytes.HasPrefix(data, []byte("\xff\xd8\xff")):
+		return MediaType{MediaKindImage, "jpeg", ".jpg"}, nil
+	case bytes.HasPrefix(data, []byte("GIF87a")), bytes.HasPrefix(data, []byte("GIF89a")):
+		return MediaType{MediaKindImage, "gif", ".gif"}, nil
+	case isRIFF(data, "WEBP"):
+		return MediaType{MediaKindImage, "webp", ".webp"}, nil
+	case isRIFF(data, "WAVE"):
+		return MediaType{MediaKindAudio, "wav", ".wav"}, nil
+	case bytes.HasPrefix(data, []byte("\x1a\x45\xdf\xa3")):
+		return MediaType{MediaKindVideo, "webm", ".webm"}, nil
+	case len(data) >= 12 && string(data[4:8]) == "ftyp":
+		// M4A files are mp4 containers too but are not accepted as audio
+		return MediaType{MediaKindVideo, "mp4", ".mp4"}, nil
+	case bytes.HasPrefix(data, []byte("OggS")):
+		return MediaType{MediaKindAudio, "ogg", ".ogg"}, nil
+	case bytes.HasPrefix(data, []byte("ID3")), isMP3Frame(data):
+		return MediaType{MediaKindAudio, "mp3", ".mp3"}, nil
+	}
+
+	return MediaType{}, NewMediaError("unsupported file type, use png, jpeg, gif, webp, mp4, webm, mp3, ogg or wav")
+}
+
+func isRIFF(data []byte, format string) bool {
+	return len(data) >= 12 && string(data[0:4]) == "RIFF" && string(data[8:12]) == format
+}
+
+/* MP3 files without an ID3 tag start directly with an MPEG audio frame sync */
+func isMP3Frame(data []byte) bool {
+	return len(data) >= 2 && data[0] == 0xFF && data[1]&0xE0 == 0xE0 && data[1]&0x06 != 0
+}
+
+/*
+Read the EXIF orientation of a JPEG. Re-encoding drops the EXIF data, so
+the orientation has to be applied to the pixels or photos end up rotated.
+Returns 1 (normal) when the tag is missing or cannot be parsed.
+*/
+func JPEGOrientation(data []byte) int {
+	pos := 2
+	for pos+4 <= len(data) {
+		if data[pos] != 0xFF {
+			return 1
+		}
+
+		marker := data[pos+1]
+		size := int(binary.BigEndian.Uint16(data[pos+2 : pos+4]))
+
+		// Start of scan, no metadata follows
+		if marker == 0xDA {
+			return 1
+		}
+
+		if marker == 0xE1 && pos+4+size-2 <= len(data) {
+			if orientation := exifOrientation(data[pos+4 : pos+2+size]); orientation != 0 {
+				return orientation
+			}
+		}
+
+		pos += 2 + size
+	}
+
+	return 1
+}
+
+func exifOrientation(segment []byte) int {
+	if len(segment) < 14 || string(segment[0:6]) != "Exif\x00\x00" {
+		return 0
+	}
+
+	tiff := segment[6:]
+
+	var order binary.ByteOrder
+	switch string(tiff[0:2]) {
+	case "II":
+		order = binary.LittleEndian
+	case "MM":
+		order = binary.BigEndian
+	default:
+		return 0
+	}
+
+	offset := int(order.Uint32(tiff[4:8]))
+	if offset+2 > len(tiff) {
+		return 0
+	}
+
+	entries := int(order.Uint16(tiff[offset : offset+2]))
+	for i := 0; i < entries; i++ {
+		entry := offset + 2 + i*12
+		if entry+12 > len(tiff) {
+			return 0
+		}
+
+		// 0x0112 is the orientation tag, a SHORT stored inline
+		if order.Uint16(tiff[entry:entry+2]) == 0x0112 {
+			orientation := int(order.Uint16(tiff[entry+8 : entry+10]))
+			if orientation >= 1 && orientation <= 8 {
+				return orientation
+			}
+			return 0
+		}
+	}
+
+	return 0
+}
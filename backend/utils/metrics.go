@@ -35,4 +35,39 @@ var (
 		},
 		[]string{"username", "uid", "date"},
 	)
+
+	// Discord role sync metrics
+	RoleSyncChanges = promauto.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "hazebio_discord_role_sync_changes_total",
+			Help: "Discord role changes applied to correct drift between badges and roles",
+		},
+		[]string{"guild", "action", "trigger"},
+	)
+
+	RoleSyncErrors = promauto.NewCounter(prometheus.CounterOpts{
+		Name: "hazebio_discord_role_sync_errors_total",
+		Help: "Total number of failed Discord role sync operations",
+	})
+
+	RoleSyncQueueLength = promauto.NewGauge(prometheus.GaugeOpts{
+		Name: "hazebio_discord_role_sync_queue_length",
+		Help: "Members waiting to be reconciled",
+	})
+
+	RoleSyncDriftedMembers = promauto.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "hazebio_discord_role_sync_drifted_members",
+			Help: "Members whose roles had drifted during the last full reconcile",
+		},
+		[]string{"guild"},
+	)
+
+	RoleSyncFullReconcileDuration = promauto.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "hazebio_discord_role_sync_full_reconcile_seconds",
+			Help: "Duration of the last full role reconcile",
+		},
+		[]string{"guild"},
+	)
 )
@@ -2,6 +2,7 @@ package utils
 
 import (
 	"encoding/json"
+	"fmt"
 	"net"
 	"net/http"
 	"os"
@@ -92,3 +93,72 @@ func ExtractIP(r *http.Request) string {
 
 	return ip
 }
+
+/*
+Host a request was made to without its port. X-Forwarded-Host is preferred
+so proxied requests resolve, but only when the request comes from one of the
+trusted proxies, anyone else could claim any host with it.
+*/
+func RequestHost(r *http.Request, trustedProxies []*net.IPNet) string {
+	host := r.Host
+	if forwarded := r.Header.Get("X-Forwarded-Host"); forwarded != "" && IsTrustedProxy(r.RemoteAddr, trustedProxies) {
+		host = strings.TrimSpace(strings.Split(forwarded, ",")[0])
+	}
+
+	if h, _, err := net.SplitHostPort(host); err == nil {
+		host = h
+	}
+
+	return strings.TrimSuffix(strings.ToLower(host), ".")
+}
+
+/* Whether the address, with or without a port, belongs to one of the trusted proxies */
+func IsTrustedProxy(address string, trustedProxies []*net.IPNet) bool {
+	if host, _, err := net.SplitHostPort(address); err == nil {
+		address = host
+	}
+
+	ip := net.ParseIP(address)
+	if ip == nil {
+		return false
+	}
+
+	for _, network := range trustedProxies {
+		if network.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
+
+/* Parse a comma separated list of IPs and CIDRs */
+func ParseTrustedProxies(value string) ([]*net.IPNet, error) {
+	var proxies []*net.IPNet
+	for _, entry := range strings.Split(value, ",") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+
+		if !strings.Contains(entry, "/") {
+			ip := net.ParseIP(entry)
+			if ip == nil {
+				return nil, fmt.Errorf("invalid trusted proxy %q", entry)
+			}
+			bits := 8 * net.IPv4len
+			if ip.To4() == nil {
+				bits = 8 * net.IPv6len
+			}
+			proxies = append(proxies, &net.IPNet{IP: ip, Mask: net.CIDRMask(bits, bits)})
+			continue
+		}
+
+		_, network, err := net.ParseCIDR(entry)
+		if err != nil {
+			return nil, fmt.Errorf("invalid trusted proxy %q: %w", entry, err)
+		}
+		proxies = append(proxies, network)
+	}
+
+	return proxies, nil
+}
@@ -0,0 +1,49 @@
+package utils
+
+import (
+	"net/http/httptest"
+	"testing"
+)
+
+func TestRequestHost(t *testing.T) {
+	trustedProxies, err := ParseTrustedProxies("10.0.0.0/8, 192.168.1.1")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name       string
+		remoteAddr string
+		host       string
+		forwarded  string
+		want       string
+	}{
+		{"direct", "203.0.113.7:4000", "Example.com:8080", "", "example.com"},
+		{"forwarded by a stranger", "203.0.113.7:4000", "haze.bio", "victim.example", "haze.bio"},
+		{"forwarded by a trusted network", "10.1.2.3:4000", "haze.bio", "custom.example, haze.bio", "custom.example"},
+		{"forwarded by a trusted address", "192.168.1.1:4000", "haze.bio", "custom.example.", "custom.example"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest("GET", "/", nil)
+			r.RemoteAddr = tt.remoteAddr
+			r.Host = tt.host
+			if tt.forwarded != "" {
+				r.Header.Set("X-Forwarded-Host", tt.forwarded)
+			}
+
+			if got := RequestHost(r, trustedProxies); got != tt.want {
+				t.Fatalf("RequestHost = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseTrustedProxiesInvalid(t *testing.T) {
+	for _, value := range []string{"not-an-ip", "10.0.0.0/33"} {
+		if _, err := ParseTrustedProxies(value); err == nil {
+			t.Fatalf("ParseTrustedProxies(%q) accepted an invalid entry", value)
+		}
+	}
+}
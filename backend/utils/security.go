@@ -3,6 +3,7 @@ package utils
 import (
 	"crypto/aes"
 	"crypto/cipher"
+	"crypto/hmac"
 	"crypto/rand"
 	"crypto/sha256"
 	"encoding/base64"
@@ -10,7 +11,10 @@ import (
 	"encoding/json"
 	"fmt"
 	"io"
+	"net/url"
 	"os"
+	"path"
+	"strconv"
 	"strings"
 	"time"
 
@@ -167,6 +171,16 @@ func decryptAES(encryptedData string) (string, error) {
 	return string(plaintext), nil
 }
 
+/* Encrypt a secret for storage with the ENCRYPTION_KEY */
+func EncryptString(plaintext string) (string, error) {
+	return encryptAES(plaintext)
+}
+
+/* Decrypt a secret encrypted with EncryptString */
+func DecryptString(encrypted string) (string, error) {
+	return decryptAES(encrypted)
+}
+
 func Encode(obj interface{}) (string, error) {
 	bytes, err := json.Marshal(obj)
 	if err != nil {
@@ -214,3 +228,96 @@ func MaskEmail(email string) string {
 	maskedUsername := username[0:2] + strings.Repeat("*", len(username)-2)
 	return maskedUsername + "@" + domain
 }
+
+// UploadGrantClaims are verified by the file-upload service before it accepts a direct upload
+type UploadGrantClaims struct {
+	ID          string `json:"id"`
+	Key         string `json:"key"`
+	MaxSize     int64  `json:"max_size"`
+	ContentType string `json:"content_type"`
+	ExpiresAt   int64  `json:"exp"`
+}
+
+/* Sign upload grant claims as payload.signature with HMAC-SHA256, both parts base64url encoded */
+func SignUploadGrant(claims UploadGrantClaims, secret string) (string, error) {
+	data, err := json.Marshal(claims)
+	if err != nil {
+		return "", err
+	}
+
+	payload := base64.RawURLEncoding.EncodeToString(data)
+
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(payload))
+
+	return payload + "." + base64.RawURLEncoding.EncodeToString(mac.Sum(nil)), nil
+}
+
+/*
+Query string granting access to a file-upload service key until expiresAt,
+as verified by the file-upload service. URLs with a nonce can only be used
+once, pass an empty nonce for URLs that may be reused.
+*/
+func SignFileQuery(key string, expiresAt time.Time, nonce string, secret string) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	fmt.Fprintf(mac, "%s\n%d\n%s", key, expiresAt.Unix(), nonce)
+
+	query := url.Values{}
+	query.Set("expires", strconv.FormatInt(expiresAt.Unix(), 10))
+	if nonce != "" {
+		query.Set("nonce", nonce)
+	}
+	query.Set("sig", hex.EncodeToString(mac.Sum(nil)))
+	return query.Encode()
+}
+
+// Extensions kept at the end of media proxy paths, so clients that pick the
+// element to render by extension keep working
+var mediaProxyExtensions = map[string]bool{
+	".png": true, ".jpg": true, ".jpeg": true, ".gif": true, ".webp": true, ".mp4": true, ".webm": true,
+}
+
+func mediaProxySignature(rawURL string, secret string) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte("media-proxy\n" + rawURL))
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+/* Path of a URL behind the media proxy, /media/proxy/{signature}/{base64url(url)}{extension} */
+func MediaProxyPath(rawURL string, secret string) string {
+	extension := ""
+	if parsed, err := url.Parse(rawURL); err == nil {
+		if ext := strings.ToLower(path.Ext(parsed.Path)); mediaProxyExtensions[ext] {
+			extension = ext
+		}
+	}
+
+	return fmt.Sprintf("/media/proxy/%s/%s%s", mediaProxySignature(rawURL, secret),
+		base64.RawURLEncoding.EncodeToString([]byte(rawURL)), extension)
+}
+
+/* URL behind a media proxy path, false unless it was signed with secret */
+func ParseMediaProxyPath(signature string, target string, secret string) (string, bool) {
+	// The URL is base64url encoded, which has no dots, so the extension is everything after one
+	if i := strings.IndexByte(target, '.'); i >= 0 {
+		target = target[:i]
+	}
+
+	decoded, err := base64.RawURLEncoding.DecodeString(target)
+	if err != nil {
+		return "", false
+	}
+
+	provided, err := hex.DecodeString(signature)
+	if err != nil {
+		return "", false
+	}
+
+	rawURL := string(decoded)
+	expected, _ := hex.DecodeString(mediaProxySignature(rawURL, secret))
+	if !hmac.Equal(provided, expected) {
+		return "", false
+	}
+
+	return rawURL, true
+}
@@ -0,0 +1,46 @@
+package utils
+
+import (
+	"strings"
+	"unicode"
+)
+
+// Shingles splits a text into the set of overlapping word sequences of the given size.
+// Texts shorter than the size result in a single shingle containing all words.
+func Shingles(text string, size int) map[string]struct{} {
+	words := strings.FieldsFunc(strings.ToLower(text), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsNumber(r)
+	})
+
+	shingles := make(map[string]struct{})
+	if len(words) == 0 {
+		return shingles
+	}
+
+	if len(words) <= size {
+		shingles[strings.Join(words, " ")] = struct{}{}
+		return shingles
+	}
+
+	for i := 0; i+size <= len(words); i++ {
+		shingles[strings.Join(words[i:i+size], " ")] = struct{}{}
+	}
+	return shingles
+}
+
+// JaccardSimilarity returns the overlap of two shingle sets between 0 and 1
+func JaccardSimilarity(a, b map[string]struct{}) float64 {
+	if len(a) == 0 || len(b) == 0 {
+		return 0
+	}
+
+	intersection := 0
+	for shingle := range a {
+		if _, ok := b[shingle]; ok {
+			intersection++
+		}
+	}
+
+	union := len(a) + len(b) - intersection
+	return float64(intersection) / float64(union)
+}
@@ -14,35 +14,43 @@ import (
 )
 
 func StartServer() error {
-	r2Service, err := services.NewR2Service()
+	store, err := services.NewObjectStore()
 	if err != nil {
-		return fmt.Errorf("failed to initialize R2 service: %w", err)
+		return fmt.Errorf("failed to initialize storage backend: %w", err)
 	}
 
-	startCleanupTask(r2Service)
+	storageService := services.NewStorageService(store)
+
+	tokenStore, err := services.NewTokenStore()
+	if err != nil {
+		return fmt.Errorf("failed to connect to redis: %w", err)
+	}
+
+	startCleanupTask(storageService)
 
 	r := mux.NewRouter()
-	routes.RegisterRoutes(r, r2Service)
+	routes.RegisterRoutes(r, storageService, tokenStore)
 
 	log.Println("Server started on port:", config.HttpPort)
 	log.Println("Environment:", config.Environment)
-	log.Println("R2 Bucket:", config.R2BucketName)
-	log.Println("R2 Region:", config.R2Region)
+	log.Println("Storage backend:", store.Name())
 
 	server := &http.Server{
-		Addr:         fmt.Sprintf(":%d", config.HttpPort),
-		Handler:      middlewares.CORSMiddleware(r),
-		ReadTimeout:  15 * time.Second,
-		WriteTimeout: 120 * time.Second,
+		Addr:              fmt.Sprintf(":%d", config.HttpPort),
+		Handler:           middlewares.CORSMiddleware(r),
+		ReadHeaderTimeout: 15 * time.Second,
+		// Browsers upload directly, slow connections need time to send a part
+		ReadTimeout:  10 * time.Minute,
+		WriteTimeout: 10 * time.Minute,
 	}
 
 	return server.ListenAndServe()
 }
 
-func startCleanupTask(r2Service *services.R2Service) {
+func startCleanupTask(storageService *services.StorageService) {
 	go func() {
 		log.Println("Running initial cleanup of expired files...")
-		if err := r2Service.CleanupExpiredFiles(); err != nil {
+		if err := storageService.CleanupExpiredFiles(); err != nil {
 			log.Printf("Error during initial cleanup of expired files: %v", err)
 		}
 	}()
@@ -52,7 +60,7 @@ func startCleanupTask(r2Service *services.R2Service) {
 	go func() {
 		for range ticker.C {
 			log.Println("Running scheduled cleanup of expired files...")
-			if err := r2Service.CleanupExpiredFiles(); err != nil {
+			if err := storageService.CleanupExpiredFiles(); err != nil {
 				log.Printf("Error cleaning up expired files: %v", err)
 			} else {
 				log.Println("Completed expired files cleanup")
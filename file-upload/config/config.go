@@ -2,7 +2,9 @@ package config
 
 import (
 	"fmt"
+	"net"
 	"os"
+	"strings"
 
 	"github.com/hazebio/haze.bio_file-upload/utils"
 	"github.com/joho/godotenv"
@@ -14,12 +16,42 @@ var (
 	APIKey      string
 	Origin      string
 
+	// Proxies whose CF-Connecting-IP and X-Forwarded-For headers are trusted, comma separated IPs or CIDRs
+	TrustedProxies []*net.IPNet
+
+	// Shared with the backend, which signs the upload grants browsers use to upload directly
+	UploadGrantSecret string
+
+	// Shared with the backend, which signs download URLs for protected files
+	FileSigningSecret string
+
+	// Optional, tracks single-use download nonces and password attempts across instances
+	RedisAddr     string
+	RedisPassword string
+	RedisDB       int
+
+	// Storage backend: r2 (default), s3 or local
+	StorageBackend string
+	// Store plain uploads once per content, overridden per upload by ?dedupe=
+	ContentAddressedUploads bool
+
 	// Cloudflare R2 Configuration
 	R2Region          string
 	R2AccessKeyId     string
 	R2SecretAccessKey string
 	R2BucketName      string
 	R2Endpoint        string
+
+	// Generic S3-compatible storage configuration
+	S3Region          string
+	S3AccessKeyId     string
+	S3SecretAccessKey string
+	S3BucketName      string
+	S3Endpoint        string
+	S3UsePathStyle    bool
+
+	// Local filesystem storage configuration
+	LocalStoragePath string
 )
 
 func LoadConfig() error {
@@ -33,11 +65,67 @@ func LoadConfig() error {
 	APIKey = os.Getenv("API_KEY")
 	Origin = os.Getenv("ORIGIN")
 
+	TrustedProxies, err = parseTrustedProxies(os.Getenv("TRUSTED_PROXIES"))
+	if err != nil {
+		return err
+	}
+
+	UploadGrantSecret = os.Getenv("UPLOAD_GRANT_SECRET")
+
+	FileSigningSecret = os.Getenv("FILE_SIGNING_SECRET")
+
+	RedisAddr = os.Getenv("REDIS_ADDR")
+	RedisPassword = os.Getenv("REDIS_PASSWORD")
+	RedisDB = utils.StringToInt(os.Getenv("REDIS_DB"))
+
+	StorageBackend = os.Getenv("STORAGE_BACKEND")
+	ContentAddressedUploads = utils.StringToBool(os.Getenv("CONTENT_ADDRESSED_UPLOADS"))
+
 	R2Region = os.Getenv("R2_REGION")
 	R2AccessKeyId = os.Getenv("R2_ACCESS_KEY_ID")
 	R2SecretAccessKey = os.Getenv("R2_SECRET_ACCESS_KEY")
 	R2BucketName = os.Getenv("R2_BUCKET_NAME")
 	R2Endpoint = os.Getenv("R2_ENDPOINT")
 
+	S3Region = os.Getenv("S3_REGION")
+	S3AccessKeyId = os.Getenv("S3_ACCESS_KEY_ID")
+	S3SecretAccessKey = os.Getenv("S3_SECRET_ACCESS_KEY")
+	S3BucketName = os.Getenv("S3_BUCKET_NAME")
+	S3Endpoint = os.Getenv("S3_ENDPOINT")
+	S3UsePathStyle = utils.StringToBool(os.Getenv("S3_USE_PATH_STYLE"))
+
+	LocalStoragePath = os.Getenv("LOCAL_STORAGE_PATH")
+
 	return nil
 }
+
+func parseTrustedProxies(value string) ([]*net.IPNet, error) {
+	var proxies []*net.IPNet
+	for _, entry := range strings.Split(value, ",") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+
+		if !strings.Contains(entry, "/") {
+			ip := net.ParseIP(entry)
+			if ip == nil {
+				return nil, fmt.Errorf("invalid trusted proxy %q", entry)
+			}
+			bits := 8 * net.IPv4len
+			if ip.To4() == nil {
+				bits = 8 * net.IPv6len
+			}
+			proxies = append(proxies, &net.IPNet{IP: ip, Mask: net.CIDRMask(bits, bits)})
+			continue
+		}
+
+		_, network, err := net.ParseCIDR(entry)
+		if err != nil {
+			return nil, fmt.Errorf("invalid trusted proxy %q: %w", entry, err)
+		}
+		proxies = append(proxies, network)
+	}
+
+	return proxies, nil
+}
@@ -1,12 +1,15 @@
 package handlers
 
 import (
-	"crypto/sha256"
+	"crypto/rand"
 	"encoding/hex"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"log"
+	"net"
 	"net/http"
+	"path"
 	"path/filepath"
 	"regexp"
 	"strconv"
@@ -33,13 +36,27 @@ type PasswordVerificationRequest struct {
 	Password string `json:"password"`
 }
 
+const (
+	// Signed URLs handed out by /verify
+	verifiedURLTTL = 5 * time.Minute
+
+	// Password attempts per file and client, and per client across all files.
+	// Nothing is counted per file alone, that would let anyone lock a file.
+	verifyAttemptsPerFile   = 5
+	verifyFileWindow        = 15 * time.Minute
+	verifyAttemptsPerClient = 30
+	verifyClientWindow      = time.Hour
+)
+
 type FileHandler struct {
-	R2Service *services.R2Service
+	StorageService *services.StorageService
+	TokenStore     services.TokenStore
 }
 
-func NewFileHandler(r2Service *services.R2Service) *FileHandler {
+func NewFileHandler(storageService *services.StorageService, tokenStore services.TokenStore) *FileHandler {
 	return &FileHandler{
-		R2Service: r2Service,
+		StorageService: storageService,
+		TokenStore:     tokenStore,
 	}
 }
 
@@ -54,6 +71,17 @@ func (fh *FileHandler) HandleFileOperations(w http.ResponseWriter, r *http.Reque
 
 	key = strings.TrimPrefix(key, "/")
 
+	// Keys are relative paths, anything climbing out of the bucket root is rejected before it reaches a store
+	if path.Clean("/"+key) != "/"+key {
+		utils.RespondError(w, http.StatusBadRequest, "Invalid file key")
+		return
+	}
+
+	if services.IsInternalKey(key) {
+		utils.RespondError(w, http.StatusBadRequest, "Reserved file key")
+		return
+	}
+
 	if r.Method == http.MethodPut || r.Method == http.MethodDelete {
 		providedKey := r.Header.Get("X-Api-Key")
 		if providedKey != config.APIKey {
@@ -66,15 +94,20 @@ func (fh *FileHandler) HandleFileOperations(w http.ResponseWriter, r *http.Reque
 
 	switch r.Method {
 	case http.MethodPut:
+		access, ok := parseFileAccess(w, r)
+		if !ok {
+			return
+		}
+
 		switch fileType {
 		case "temporary":
-			fh.handlePutTemporaryFile(w, r, key)
+			fh.handlePutTemporaryFile(w, r, key, access)
 		case "protected":
-			fh.handlePutProtectedFile(w, r, key)
+			fh.handlePutProtectedFile(w, r, key, access)
 		case "temporary-protected":
-			fh.handlePutTemporaryProtectedFile(w, r, key)
+			fh.handlePutTemporaryProtectedFile(w, r, key, access)
 		default:
-			fh.handlePutFile(w, r, key)
+			fh.handlePutFile(w, r, key, access)
 		}
 	case http.MethodGet:
 		fh.handleGetFile(w, r, key)
@@ -91,8 +124,45 @@ func (fh *FileHandler) HandleFileOperations(w http.ResponseWriter, r *http.Reque
 	}
 }
 
-func (fh *FileHandler) handlePutFile(w http.ResponseWriter, r *http.Request, key string) {
-	err := fh.R2Service.UploadFile(key, r.Body)
+/* Read the access policy of an upload from the access and single_use query parameters */
+func parseFileAccess(w http.ResponseWriter, r *http.Request) (services.FileAccess, bool) {
+	access := services.FileAccess{
+		Policy:    services.AccessPolicy(r.URL.Query().Get("access")),
+		SingleUse: utils.StringToBool(r.URL.Query().Get("single_use")),
+	}
+
+	switch access.Policy {
+	case "", services.AccessPublic, services.AccessSigned:
+		return access, true
+	}
+
+	utils.RespondError(w, http.StatusBadRequest, "Invalid access policy")
+	return access, false
+}
+
+/* Whether to store an upload content-addressed, the dedupe parameter overrides the configured default */
+func useContentAddressing(r *http.Request) bool {
+	if dedupe := r.URL.Query().Get("dedupe"); dedupe != "" {
+		return utils.StringToBool(dedupe)
+	}
+	return config.ContentAddressedUploads
+}
+
+func (fh *FileHandler) handlePutFile(w http.ResponseWriter, r *http.Request, key string, access services.FileAccess) {
+	if useContentAddressing(r) {
+		file, err := fh.StorageService.UploadContentAddressedFile(key, r.Body, access)
+		if err != nil {
+			utils.RespondError(w, http.StatusInternalServerError, "Failed to upload file: "+err.Error())
+			return
+		}
+
+		log.Printf("PUT %s, content %s, deduplicated: %t", key, file.Hash, file.Deduplicated)
+
+		utils.RespondSuccess(w, "Put "+key+" successfully!", file)
+		return
+	}
+
+	err := fh.StorageService.UploadFile(key, r.Body, access)
 	if err != nil {
 		utils.RespondError(w, http.StatusInternalServerError, "Failed to upload file: "+err.Error())
 		return
@@ -103,13 +173,13 @@ func (fh *FileHandler) handlePutFile(w http.ResponseWriter, r *http.Request, key
 	utils.RespondSuccess(w, "Put "+key+" successfully!", nil)
 }
 
-func (fh *FileHandler) handlePutTemporaryFile(w http.ResponseWriter, r *http.Request, key string) {
+func (fh *FileHandler) handlePutTemporaryFile(w http.ResponseWriter, r *http.Request, key string, access services.FileAccess) {
 	expirationHours, err := strconv.Atoi(r.URL.Query().Get("expiration"))
 	if err != nil || expirationHours <= 0 {
 		expirationHours = 24
 	}
 
-	err = fh.R2Service.UploadTemporaryFile(key, r.Body, time.Duration(expirationHours)*time.Hour)
+	err = fh.StorageService.UploadTemporaryFile(key, r.Body, time.Duration(expirationHours)*time.Hour, access)
 	if err != nil {
 		utils.RespondError(w, http.StatusInternalServerError, "Failed to upload temporary file: "+err.Error())
 		return
@@ -119,18 +189,15 @@ func (fh *FileHandler) handlePutTemporaryFile(w http.ResponseWriter, r *http.Req
 	utils.RespondSuccess(w, "Put temporary file "+key+" successfully! Expires in "+strconv.Itoa(expirationHours)+" hours", nil)
 }
 
-func (fh *FileHandler) handlePutProtectedFile(w http.ResponseWriter, r *http.Request, key string) {
-	password := r.URL.Query().Get("password")
-	if password == "" {
-		password = r.Header.Get("X-Password")
-	}
-
+func (fh *FileHandler) handlePutProtectedFile(w http.ResponseWriter, r *http.Request, key string, access services.FileAccess) {
+	// Never read from the query, URLs end up in access logs
+	password := r.Header.Get("X-Password")
 	if password == "" {
 		utils.RespondError(w, http.StatusBadRequest, "Password required for protected files")
 		return
 	}
 
-	err := fh.R2Service.UploadPasswordProtectedFile(key, r.Body, password)
+	err := fh.StorageService.UploadPasswordProtectedFile(key, r.Body, password, access)
 	if err != nil {
 		utils.RespondError(w, http.StatusInternalServerError, "Failed to upload protected file: "+err.Error())
 		return
@@ -141,67 +208,78 @@ func (fh *FileHandler) handlePutProtectedFile(w http.ResponseWriter, r *http.Req
 }
 
 func (fh *FileHandler) handleGetFile(w http.ResponseWriter, r *http.Request, key string) {
-	exists, err := fh.R2Service.FileExists(key)
+	info, err := fh.StorageService.GetFileInfo(key)
 	if err != nil {
-		utils.RespondError(w, http.StatusInternalServerError, "Failed to check file: "+err.Error())
-		return
-	}
-
-	if !exists {
-		utils.RespondError(w, http.StatusNotFound, "Object not found")
+		if errors.Is(err, services.ErrObjectNotFound) {
+			utils.RespondError(w, http.StatusNotFound, "Object not found")
+			return
+		}
+		utils.RespondError(w, http.StatusInternalServerError, "Failed to get file info: "+err.Error())
 		return
 	}
 
-	metadata, err := fh.R2Service.GetFileMetadata(key)
+	metadata, err := services.ParseFileMetadata(info)
 	if err != nil {
 		utils.RespondError(w, http.StatusInternalServerError, "Failed to get file metadata: "+err.Error())
 		return
 	}
 
-	if metadata.ExpiresAt != nil && metadata.ExpiresAt.Before(time.Now()) {
-		utils.RespondError(w, http.StatusGone, "File has expired")
+	if !fh.authorizeDownload(w, r, key, metadata) {
 		return
 	}
 
-	if metadata.PasswordHash != "" {
-		password := r.URL.Query().Get("password")
+	setCacheHeaders(w, info, metadata)
 
-		if password == "" {
-			utils.RespondError(w, http.StatusForbidden, "Password required")
-			return
-		}
-
-		hashedPassword := hashPassword(password)
-		if hashedPassword != metadata.PasswordHash {
-			utils.RespondError(w, http.StatusForbidden, "Invalid password")
-			return
-		}
+	if notModified(r, info) {
+		log.Printf("GET %s, not modified", key)
+		w.WriteHeader(http.StatusNotModified)
+		return
 	}
 
 	rangeHeader := r.Header.Get("Range")
 	if rangeHeader != "" {
-		fh.handleRangeRequest(w, r, key, rangeHeader)
+		fh.handleRangeRequest(w, key, info, rangeHeader)
 		return
 	}
 
-	obj, err := fh.R2Service.GetFile(key)
+	obj, err := fh.StorageService.GetFile(key)
 	if err != nil {
 		utils.RespondError(w, http.StatusInternalServerError, "Failed to get file: "+err.Error())
 		return
 	}
+	defer obj.Body.Close()
 
-	w.Header().Set("Content-Length", strconv.FormatInt(*obj.ContentLength, 10))
+	w.Header().Set("Content-Length", strconv.FormatInt(obj.Size, 10))
 
 	contentType := getContentTypeByExtension(key)
-	if contentType == "" && obj.ContentType != nil {
-		contentType = *obj.ContentType
+	if contentType == "" {
+		contentType = obj.ContentType
 	}
 	w.Header().Set("Content-Type", contentType)
-
 	w.Header().Set("Content-Disposition", "inline")
-	w.Header().Set("Cache-Control", "public, max-age=86400, immutable")
-	if obj.ETag != nil {
-		w.Header().Set("ETag", *obj.ETag)
+
+	log.Printf("GET %s, Content-Type: %s, Content-Length: %d", key, contentType, obj.Size)
+
+	io.Copy(w, obj.Body)
+}
+
+/*
+Validators and caching headers shared by full, partial and not modified
+responses. Only public files may be kept by shared caches, signed links must
+not be replayed from a CDN.
+*/
+func setCacheHeaders(w http.ResponseWriter, info *services.ObjectInfo, metadata *services.FileMetadata) {
+	if metadata.Policy() == services.AccessPublic {
+		w.Header().Set("Cache-Control", "public, max-age=86400, immutable")
+	} else {
+		w.Header().Set("Cache-Control", "private, no-store")
+	}
+
+	if info.ETag != "" {
+		w.Header().Set("ETag", info.ETag)
+	}
+	if !info.LastModified.IsZero() {
+		w.Header().Set("Last-Modified", info.LastModified.UTC().Format(http.TimeFormat))
 	}
 	w.Header().Set("Accept-Ranges", "bytes")
 
@@ -211,43 +289,36 @@ func (fh *FileHandler) handleGetFile(w http.ResponseWriter, r *http.Request, key
 	if metadata.ExpiresAt != nil {
 		w.Header().Set("X-Expires-At", metadata.ExpiresAt.Format(time.RFC3339))
 	}
-
-	log.Printf("GET %s, Content-Type: %s, Content-Length: %d", key, contentType, *obj.ContentLength)
-
-	io.Copy(w, obj.Body)
-	defer obj.Body.Close()
 }
 
-func (fh *FileHandler) handleRangeRequest(w http.ResponseWriter, r *http.Request, key string, rangeHeader string) {
-	metadata, err := fh.R2Service.GetFileMetadata(key)
-	if err != nil {
-		utils.RespondError(w, http.StatusInternalServerError, "Failed to get file metadata: "+err.Error())
-		return
-	}
-
-	if metadata.ExpiresAt != nil && metadata.ExpiresAt.Before(time.Now()) {
-		utils.RespondError(w, http.StatusGone, "File has expired")
-		return
-	}
-
-	if metadata.PasswordHash != "" {
-		password := w.Header().Get("X-Password")
-		if password == "" {
-			password = r.URL.Query().Get("password")
+/* Conditional GET, If-None-Match takes precedence over If-Modified-Since */
+func notModified(r *http.Request, info *services.ObjectInfo) bool {
+	if match := r.Header.Get("If-None-Match"); match != "" {
+		if info.ETag == "" {
+			return false
 		}
 
-		if password == "" {
-			utils.RespondError(w, http.StatusForbidden, "Password required")
-			return
+		etag := strings.TrimPrefix(info.ETag, "W/")
+		for _, candidate := range strings.Split(match, ",") {
+			candidate = strings.TrimSpace(candidate)
+			if candidate == "*" || strings.TrimPrefix(candidate, "W/") == etag {
+				return true
+			}
 		}
+		return false
+	}
 
-		hashedPassword := hashPassword(password)
-		if hashedPassword != metadata.PasswordHash {
-			utils.RespondError(w, http.StatusForbidden, "Invalid password")
-			return
-		}
+	since, err := http.ParseTime(r.Header.Get("If-Modified-Since"))
+	if err != nil || info.LastModified.IsZero() {
+		return false
 	}
 
+	// Last-Modified has a resolution of one second
+	return !info.LastModified.Truncate(time.Second).After(since)
+}
+
+/* Serve part of a file, access and caching headers are handled by handleGetFile */
+func (fh *FileHandler) handleRangeRequest(w http.ResponseWriter, key string, info *services.ObjectInfo, rangeHeader string) {
 	regex := regexp.MustCompile(`bytes=(\d+)-(\d*)`)
 	matches := regex.FindStringSubmatch(rangeHeader)
 
@@ -262,13 +333,7 @@ func (fh *FileHandler) handleRangeRequest(w http.ResponseWriter, r *http.Request
 		return
 	}
 
-	objInfo, err := fh.R2Service.GetFileInfo(key)
-	if err != nil {
-		utils.RespondError(w, http.StatusInternalServerError, "Failed to get file info: "+err.Error())
-		return
-	}
-
-	totalSize := *objInfo.ContentLength
+	totalSize := info.Size
 
 	var endByte int64
 	if matches[2] == "" {
@@ -281,43 +346,164 @@ func (fh *FileHandler) handleRangeRequest(w http.ResponseWriter, r *http.Request
 		}
 	}
 
-	rangeObj, err := fh.R2Service.GetFileRange(key, startByte, endByte)
+	rangeObj, err := fh.StorageService.GetFileRange(key, startByte, endByte)
 	if err != nil {
 		utils.RespondError(w, http.StatusInternalServerError, "Failed to get file range: "+err.Error())
 		return
 	}
+	defer rangeObj.Body.Close()
 
 	contentLength := endByte - startByte + 1
 
 	contentType := getContentTypeByExtension(key)
-	if contentType == "" && rangeObj.ContentType != nil {
-		contentType = *rangeObj.ContentType
+	if contentType == "" {
+		contentType = rangeObj.ContentType
 	}
 
 	w.Header().Set("Content-Range", "bytes "+strconv.FormatInt(startByte, 10)+"-"+strconv.FormatInt(endByte, 10)+"/"+strconv.FormatInt(totalSize, 10))
 	w.Header().Set("Content-Length", strconv.FormatInt(contentLength, 10))
 	w.Header().Set("Content-Type", contentType)
 	w.Header().Set("Content-Disposition", "inline")
-	w.Header().Set("Accept-Ranges", "bytes")
-	w.Header().Set("Cache-Control", "public, max-age=86400, immutable")
-
-	w.Header().Set("Access-Control-Allow-Origin", config.Origin)
-	w.Header().Set("Cross-Origin-Resource-Policy", "cross-origin")
-
-	if metadata.ExpiresAt != nil {
-		w.Header().Set("X-Expires-At", metadata.ExpiresAt.Format(time.RFC3339))
-	}
 
 	log.Printf("Range request for %s: %s, Content-Type: %s, Content-Length: %d", key, rangeHeader, contentType, contentLength)
 
 	w.WriteHeader(http.StatusPartialContent)
 
 	io.Copy(w, rangeObj.Body)
-	defer rangeObj.Body.Close()
 }
 
+/*
+Check the access policy of a file before serving it. Signed and password
+protected files need a valid signed URL, single-use URLs are rejected once
+their nonce was seen.
+*/
+func (fh *FileHandler) authorizeDownload(w http.ResponseWriter, r *http.Request, key string, metadata *services.FileMetadata) bool {
+	if metadata.ExpiresAt != nil && metadata.ExpiresAt.Before(time.Now()) {
+		utils.RespondError(w, http.StatusGone, "File has expired")
+		return false
+	}
+
+	policy := metadata.Policy()
+	if policy == services.AccessPublic {
+		return true
+	}
+
+	query := r.URL.Query()
+	expiresAt, err := services.VerifySignedQuery(key, query)
+	if err != nil {
+		if policy == services.AccessPassword {
+			utils.RespondError(w, http.StatusForbidden, "Password required")
+		} else {
+			utils.RespondError(w, http.StatusForbidden, "Invalid or expired link")
+		}
+		return false
+	}
+
+	nonce := query.Get("nonce")
+	if metadata.SingleUse && nonce == "" {
+		utils.RespondError(w, http.StatusForbidden, "Invalid or expired link")
+		return false
+	}
+
+	if nonce != "" {
+		unused, err := fh.TokenStore.UseOnce("nonce:"+nonce, time.Until(expiresAt)+time.Minute)
+		if err != nil {
+			utils.RespondError(w, http.StatusInternalServerError, "Failed to check link: "+err.Error())
+			return false
+		}
+
+		if !unused {
+			utils.RespondError(w, http.StatusForbidden, "This link has already been used")
+			return false
+		}
+	}
+
+	return true
+}
+
+/*
+Client address used to limit password attempts. Forwarded headers are only
+honoured from trusted proxies, Cloudflare's header first, otherwise the
+nearest untrusted hop of X-Forwarded-For.
+*/
+func clientIP(r *http.Request) string {
+	remote, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		remote = r.RemoteAddr
+	}
+
+	if !isTrustedProxy(remote) {
+		return remote
+	}
+
+	if ip := strings.TrimSpace(r.Header.Get("CF-Connecting-IP")); net.ParseIP(ip) != nil {
+		return ip
+	}
+
+	hops := strings.Split(strings.Join(r.Header.Values("X-Forwarded-For"), ","), ",")
+	for i := len(hops) - 1; i >= 0; i-- {
+		hop := strings.TrimSpace(hops[i])
+		if net.ParseIP(hop) == nil {
+			break
+		}
+		if !isTrustedProxy(hop) {
+			return hop
+		}
+	}
+
+	return remote
+}
+
+func isTrustedProxy(address string) bool {
+	ip := net.ParseIP(address)
+	if ip == nil {
+		return false
+	}
+
+	for _, network := range config.TrustedProxies {
+		if network.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
+
+/* Count a password attempt, false once the client ran out of attempts for the file or overall */
+func (fh *FileHandler) allowVerifyAttempt(w http.ResponseWriter, r *http.Request, key string) bool {
+	client := clientIP(r)
+	limits := []struct {
+		counter string
+		limit   int64
+		window  time.Duration
+	}{
+		{"verify:" + key + ":" + client, verifyAttemptsPerFile, verifyFileWindow},
+		{"verify-client:" + client, verifyAttemptsPerClient, verifyClientWindow},
+	}
+
+	for _, l := range limits {
+		count, err := fh.TokenStore.Increment(l.counter, l.window)
+		if err != nil {
+			utils.RespondError(w, http.StatusInternalServerError, "Failed to verify password: "+err.Error())
+			return false
+		}
+
+		if count > l.limit {
+			w.Header().Set("Retry-After", strconv.Itoa(int(l.window.Seconds())))
+			utils.RespondError(w, http.StatusTooManyRequests, "Too many attempts, please try again later")
+			return false
+		}
+	}
+
+	return true
+}
+
+/* Exchange the password of a protected file for a short-lived single-use signed URL */
 func (fh *FileHandler) handleVerifyPassword(w http.ResponseWriter, r *http.Request, key string) {
-	exists, err := fh.R2Service.FileExists(key)
+	if !fh.allowVerifyAttempt(w, r, key) {
+		return
+	}
+
+	exists, err := fh.StorageService.FileExists(key)
 	if err != nil {
 		utils.RespondError(w, http.StatusInternalServerError, "Failed to check file: "+err.Error())
 		return
@@ -328,49 +514,68 @@ func (fh *FileHandler) handleVerifyPassword(w http.ResponseWriter, r *http.Reque
 		return
 	}
 
-	metadata, err := fh.R2Service.GetFileMetadata(key)
+	metadata, err := fh.StorageService.GetFileMetadata(key)
 	if err != nil {
 		utils.RespondError(w, http.StatusInternalServerError, "Failed to get file metadata: "+err.Error())
 		return
 	}
 
-	if metadata.PasswordHash == "" {
+	if metadata.Policy() != services.AccessPassword {
 		utils.RespondError(w, http.StatusBadRequest, "File is not password protected")
 		return
 	}
 
+	if metadata.ExpiresAt != nil && metadata.ExpiresAt.Before(time.Now()) {
+		utils.RespondError(w, http.StatusGone, "File has expired")
+		return
+	}
+
 	var req PasswordVerificationRequest
 	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
 		utils.RespondError(w, http.StatusBadRequest, "Invalid request body: "+err.Error())
 		return
 	}
 
-	hashedPassword := hashPassword(req.Password)
-	if hashedPassword != metadata.PasswordHash {
+	if !services.VerifyPassword(req.Password, metadata.PasswordHash) {
 		utils.RespondError(w, http.StatusForbidden, "Invalid password")
 		return
 	}
 
-	utils.RespondSuccess(w, "Password verified", nil)
+	nonce, err := randomNonce()
+	if err != nil {
+		utils.RespondError(w, http.StatusInternalServerError, "Failed to create link: "+err.Error())
+		return
+	}
+
+	expiresAt := time.Now().Add(verifiedURLTTL)
+	utils.RespondSuccess(w, "Password verified", map[string]interface{}{
+		"url":       "/" + key + "?" + services.SignedQuery(key, expiresAt, nonce),
+		"expiresAt": expiresAt,
+	})
 }
 
-func (fh *FileHandler) handlePutTemporaryProtectedFile(w http.ResponseWriter, r *http.Request, key string) {
+func randomNonce() (string, error) {
+	nonce := make([]byte, 16)
+	if _, err := rand.Read(nonce); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(nonce), nil
+}
+
+func (fh *FileHandler) handlePutTemporaryProtectedFile(w http.ResponseWriter, r *http.Request, key string, access services.FileAccess) {
 	expirationHours, err := strconv.Atoi(r.URL.Query().Get("expiration"))
 	if err != nil || expirationHours <= 0 {
 		expirationHours = 24
 	}
 
-	password := r.URL.Query().Get("password")
-	if password == "" {
-		password = r.Header.Get("X-Password")
-	}
+	password := r.Header.Get("X-Password")
 
 	if password == "" {
 		utils.RespondError(w, http.StatusBadRequest, "Password required for protected files")
 		return
 	}
 
-	err = fh.R2Service.UploadTemporaryProtectedFile(key, r.Body, password, time.Duration(expirationHours)*time.Hour)
+	err = fh.StorageService.UploadTemporaryProtectedFile(key, r.Body, password, time.Duration(expirationHours)*time.Hour, access)
 	if err != nil {
 		utils.RespondError(w, http.StatusInternalServerError, "Failed to upload temporary protected file: "+err.Error())
 		return
@@ -380,8 +585,28 @@ func (fh *FileHandler) handlePutTemporaryProtectedFile(w http.ResponseWriter, r
 	utils.RespondSuccess(w, fmt.Sprintf("Put temporary password-protected file %s successfully! Expires in %d hours", key, expirationHours), nil)
 }
 
+func (fh *FileHandler) ListObjects(w http.ResponseWriter, r *http.Request) {
+	if r.Header.Get("X-Api-Key") != config.APIKey {
+		utils.RespondError(w, http.StatusUnauthorized, "Unauthorized")
+		return
+	}
+
+	limit, err := strconv.Atoi(r.URL.Query().Get("limit"))
+	if err != nil || limit <= 0 || limit > 1000 {
+		limit = 1000
+	}
+
+	list, err := fh.StorageService.ListObjects(r.URL.Query().Get("prefix"), r.URL.Query().Get("cursor"), int32(limit))
+	if err != nil {
+		utils.RespondError(w, http.StatusInternalServerError, "Failed to list objects: "+err.Error())
+		return
+	}
+
+	utils.RespondSuccess(w, "Listed objects successfully", list)
+}
+
 func (fh *FileHandler) handleDeleteFile(w http.ResponseWriter, key string) {
-	err := fh.R2Service.DeleteFile(key)
+	err := fh.StorageService.DeleteFile(key)
 	if err != nil {
 		utils.RespondError(w, http.StatusInternalServerError, "Failed to delete file: "+err.Error())
 		return
@@ -413,8 +638,3 @@ func getContentTypeByExtension(filename string) string {
 		return ""
 	}
 }
-
-func hashPassword(password string) string {
-	hash := sha256.Sum256([]byte(password))
-	return hex.EncodeToString(hash[:])
-}
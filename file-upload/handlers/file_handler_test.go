@@ -0,0 +1,333 @@
+package handlers
+
+import (
+	"bytes"
+	"encoding/json"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/hazebio/haze.bio_file-upload/config"
+	"github.com/hazebio/haze.bio_file-upload/services"
+)
+
+const testAPIKey = "test-api-key"
+
+/* Router with the file and direct upload routes of a service backed by a temporary local store */
+func newTestServer(t *testing.T) (*mux.Router, *services.StorageService) {
+	t.Helper()
+
+	store, err := services.NewLocalStore(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	config.APIKey = testAPIKey
+	config.FileSigningSecret = "test-signing-secret"
+	config.ContentAddressedUploads = false
+	config.TrustedProxies = nil
+
+	storageService := services.NewStorageService(store)
+	tokenStore := services.NewMemoryTokenStore()
+	fileHandler := NewFileHandler(storageService, tokenStore)
+	uploadHandler := NewUploadHandler(storageService, tokenStore)
+
+	router := mux.NewRouter()
+	router.HandleFunc("/api/uploads", uploadHandler.PutObject).Methods("PUT")
+	router.HandleFunc("/api/uploads/multipart", uploadHandler.CreateMultipartUpload).Methods("POST")
+	router.HandleFunc("/api/uploads/multipart/{uploadId}", uploadHandler.AbortMultipartUpload).Methods("DELETE")
+	router.HandleFunc("/api/uploads/multipart/{uploadId}/complete", uploadHandler.CompleteMultipartUpload).Methods("POST")
+	router.HandleFunc("/api/uploads/multipart/{uploadId}/{part:[0-9]+}", uploadHandler.UploadPart).Methods("PUT")
+	router.HandleFunc("/{key:.*}/verify", fileHandler.HandleFileOperations).Methods("POST")
+	router.HandleFunc("/{key:.*}", fileHandler.HandleFileOperations).Methods("GET", "PUT", "DELETE")
+
+	return router, storageService
+}
+
+func serve(router http.Handler, r *http.Request) *httptest.ResponseRecorder {
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, r)
+	return w
+}
+
+func putFile(t *testing.T, router http.Handler, target string, body string, headers map[string]string) {
+	t.Helper()
+
+	r := httptest.NewRequest(http.MethodPut, target, bytes.NewBufferString(body))
+	r.Header.Set("X-Api-Key", testAPIKey)
+	for name, value := range headers {
+		r.Header.Set(name, value)
+	}
+
+	if w := serve(router, r); w.Code != http.StatusOK {
+		t.Fatalf("PUT %s = %d: %s", target, w.Code, w.Body.String())
+	}
+}
+
+func verifyRequest(password string, remoteAddr string) *http.Request {
+	body, _ := json.Marshal(PasswordVerificationRequest{Password: password})
+	r := httptest.NewRequest(http.MethodPost, "/secret.txt/verify", bytes.NewReader(body))
+	r.RemoteAddr = remoteAddr
+	return r
+}
+
+func TestClientIP(t *testing.T) {
+	defer func() { config.TrustedProxies = nil }()
+
+	_, proxies, _ := net.ParseCIDR("10.0.0.0/8")
+	config.TrustedProxies = []*net.IPNet{proxies}
+
+	tests := []struct {
+		name       string
+		remoteAddr string
+		headers    map[string]string
+		want       string
+	}{
+		{"direct client", "203.0.113.7:4000", nil, "203.0.113.7"},
+		{"forged headers from an untrusted client", "203.0.113.7:4000", map[string]string{
+			"CF-Connecting-IP": "198.51.100.1",
+			"X-Forwarded-For":  "198.51.100.2",
+		}, "203.0.113.7"},
+		{"cloudflare header from a trusted proxy", "10.1.2.3:4000", map[string]string{
+			"CF-Connecting-IP": "198.51.100.1",
+		}, "198.51.100.1"},
+		{"nearest untrusted forwarded hop", "10.1.2.3:4000", map[string]string{
+			"X-Forwarded-For": "192.0.2.9, 198.51.100.2, 10.4.5.6",
+		}, "198.51.100.2"},
+		{"trusted proxy without forwarded headers", "10.1.2.3:4000", nil, "10.1.2.3"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest(http.MethodGet, "/", nil)
+			r.RemoteAddr = tt.remoteAddr
+			for name, value := range tt.headers {
+				r.Header.Set(name, value)
+			}
+
+			if got := clientIP(r); got != tt.want {
+				t.Errorf("clientIP() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestVerifyAttemptsAreLimitedPerClient(t *testing.T) {
+	router, _ := newTestServer(t)
+	putFile(t, router, "/secret.txt?type=protected", "hidden", map[string]string{"X-Password": "correct horse"})
+
+	attacker := "203.0.113.7:4000"
+	for i := 0; i < verifyAttemptsPerFile; i++ {
+		if w := serve(router, verifyRequest("wrong", attacker)); w.Code != http.StatusForbidden {
+			t.Fatalf("attempt %d = %d, want 403", i+1, w.Code)
+		}
+	}
+
+	w := serve(router, verifyRequest("wrong", attacker))
+	if w.Code != http.StatusTooManyRequests {
+		t.Fatalf("attempt over the limit = %d, want 429", w.Code)
+	}
+	if w.Header().Get("Retry-After") == "" {
+		t.Error("missing Retry-After header")
+	}
+
+	// Another client can still unlock the file
+	if w := serve(router, verifyRequest("correct horse", "198.51.100.1:4000")); w.Code != http.StatusOK {
+		t.Fatalf("verify from another client = %d: %s", w.Code, w.Body.String())
+	}
+}
+
+func TestPutAndGetFile(t *testing.T) {
+	router, _ := newTestServer(t)
+
+	r := httptest.NewRequest(http.MethodPut, "/docs/hello.txt", strings.NewReader("hello world"))
+	if w := serve(router, r); w.Code != http.StatusUnauthorized {
+		t.Fatalf("PUT without API key = %d, want 401", w.Code)
+	}
+
+	putFile(t, router, "/docs/hello.txt", "hello world", nil)
+
+	w := serve(router, httptest.NewRequest(http.MethodGet, "/docs/hello.txt", nil))
+	if w.Code != http.StatusOK {
+		t.Fatalf("GET = %d: %s", w.Code, w.Body.String())
+	}
+	if w.Body.String() != "hello world" {
+		t.Errorf("body = %q, want hello world", w.Body.String())
+	}
+	if got := w.Header().Get("Content-Length"); got != "11" {
+		t.Errorf("Content-Length = %q, want 11", got)
+	}
+	if got := w.Header().Get("Cache-Control"); !strings.HasPrefix(got, "public") {
+		t.Errorf("Cache-Control = %q, public files should be cacheable", got)
+	}
+	if w.Header().Get("ETag") == "" || w.Header().Get("Last-Modified") == "" {
+		t.Error("missing validators")
+	}
+
+	w = serve(router, httptest.NewRequest(http.MethodGet, "/docs/missing.txt", nil))
+	if w.Code != http.StatusNotFound {
+		t.Errorf("GET missing file = %d, want 404", w.Code)
+	}
+}
+
+func TestGetFileRange(t *testing.T) {
+	router, _ := newTestServer(t)
+	putFile(t, router, "/digits.txt", "0123456789", nil)
+
+	tests := []struct {
+		rangeHeader  string
+		body         string
+		contentRange string
+	}{
+		{"bytes=2-5", "2345", "bytes 2-5/10"},
+		{"bytes=7-", "789", "bytes 7-9/10"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.rangeHeader, func(t *testing.T) {
+			r := httptest.NewRequest(http.MethodGet, "/digits.txt", nil)
+			r.Header.Set("Range", tt.rangeHeader)
+
+			w := serve(router, r)
+			if w.Code != http.StatusPartialContent {
+				t.Fatalf("GET = %d, want 206: %s", w.Code, w.Body.String())
+			}
+			if w.Body.String() != tt.body {
+				t.Errorf("body = %q, want %q", w.Body.String(), tt.body)
+			}
+			if got := w.Header().Get("Content-Range"); got != tt.contentRange {
+				t.Errorf("Content-Range = %q, want %q", got, tt.contentRange)
+			}
+		})
+	}
+
+	r := httptest.NewRequest(http.MethodGet, "/digits.txt", nil)
+	r.Header.Set("Range", "lines=1-2")
+	if w := serve(router, r); w.Code != http.StatusBadRequest {
+		t.Errorf("GET with an invalid range = %d, want 400", w.Code)
+	}
+}
+
+func TestConditionalGet(t *testing.T) {
+	router, _ := newTestServer(t)
+	putFile(t, router, "/cached.txt", "cache me", nil)
+
+	first := serve(router, httptest.NewRequest(http.MethodGet, "/cached.txt", nil))
+	etag := first.Header().Get("ETag")
+	lastModified := first.Header().Get("Last-Modified")
+
+	modifiedAt, err := http.ParseTime(lastModified)
+	if err != nil {
+		t.Fatalf("invalid Last-Modified %q: %v", lastModified, err)
+	}
+
+	tests := []struct {
+		name    string
+		headers map[string]string
+		want    int
+	}{
+		{"matching etag", map[string]string{"If-None-Match": etag}, http.StatusNotModified},
+		{"weak matching etag in a list", map[string]string{"If-None-Match": `"other", W/` + etag}, http.StatusNotModified},
+		{"different etag", map[string]string{"If-None-Match": `"other"`}, http.StatusOK},
+		{"not modified since", map[string]string{"If-Modified-Since": lastModified}, http.StatusNotModified},
+		{"modified since", map[string]string{"If-Modified-Since": modifiedAt.Add(-time.Hour).Format(http.TimeFormat)}, http.StatusOK},
+		{"etag takes precedence", map[string]string{"If-None-Match": `"other"`, "If-Modified-Since": lastModified}, http.StatusOK},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest(http.MethodGet, "/cached.txt", nil)
+			for name, value := range tt.headers {
+				r.Header.Set(name, value)
+			}
+
+			w := serve(router, r)
+			if w.Code != tt.want {
+				t.Fatalf("GET = %d, want %d", w.Code, tt.want)
+			}
+			if w.Code == http.StatusNotModified && w.Body.Len() != 0 {
+				t.Errorf("304 response has a body: %q", w.Body.String())
+			}
+		})
+	}
+}
+
+func TestPathTraversalKeysAreRejected(t *testing.T) {
+	router, _ := newTestServer(t)
+	handler := NewFileHandler(nil, services.NewMemoryTokenStore())
+
+	for _, key := range []string{"../outside.txt", "docs/../../outside.txt", "docs/./hello.txt", "docs//hello.txt", "docs/"} {
+		for _, method := range []string{http.MethodGet, http.MethodPut} {
+			t.Run(method+" "+key, func(t *testing.T) {
+				r := httptest.NewRequest(method, "/placeholder", strings.NewReader("escaped"))
+				r.Header.Set("X-Api-Key", testAPIKey)
+				r = mux.SetURLVars(r, map[string]string{"key": key})
+
+				w := httptest.NewRecorder()
+				handler.HandleFileOperations(w, r)
+
+				if w.Code != http.StatusBadRequest {
+					t.Errorf("%s %s = %d, want 400", method, key, w.Code)
+				}
+			})
+		}
+	}
+
+	// The router cleans dot segments from request paths, they never reach the handler
+	w := serve(router, httptest.NewRequest(http.MethodGet, "/docs/../../outside.txt", nil))
+	if w.Code == http.StatusOK {
+		t.Errorf("GET with dot segments = %d", w.Code)
+	}
+}
+
+func TestVerifyPassword(t *testing.T) {
+	router, _ := newTestServer(t)
+	putFile(t, router, "/secret.txt?type=protected", "hidden", map[string]string{"X-Password": "correct horse"})
+
+	if w := serve(router, httptest.NewRequest(http.MethodGet, "/secret.txt", nil)); w.Code != http.StatusForbidden {
+		t.Fatalf("GET without a signed URL = %d, want 403", w.Code)
+	}
+
+	if w := serve(router, verifyRequest("wrong", "203.0.113.7:4000")); w.Code != http.StatusForbidden {
+		t.Fatalf("verify with a wrong password = %d, want 403", w.Code)
+	}
+
+	w := serve(router, verifyRequest("correct horse", "203.0.113.7:4000"))
+	if w.Code != http.StatusOK {
+		t.Fatalf("verify = %d: %s", w.Code, w.Body.String())
+	}
+
+	var response struct {
+		Data struct {
+			URL string `json:"url"`
+		} `json:"data"`
+	}
+	if err := json.Unmarshal(w.Body.Bytes(), &response); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasPrefix(response.Data.URL, "/secret.txt?") {
+		t.Fatalf("url = %q, want a signed URL of the file", response.Data.URL)
+	}
+
+	w = serve(router, httptest.NewRequest(http.MethodGet, response.Data.URL, nil))
+	if w.Code != http.StatusOK || w.Body.String() != "hidden" {
+		t.Fatalf("GET with the signed URL = %d %q", w.Code, w.Body.String())
+	}
+	if got := w.Header().Get("Cache-Control"); got != "private, no-store" {
+		t.Errorf("Cache-Control = %q, protected files must not be cached", got)
+	}
+
+	if w := serve(router, httptest.NewRequest(http.MethodGet, response.Data.URL, nil)); w.Code != http.StatusForbidden {
+		t.Errorf("second GET with the single-use URL = %d, want 403", w.Code)
+	}
+
+	putFile(t, router, "/public.txt", "open", nil)
+	r := httptest.NewRequest(http.MethodPost, "/public.txt/verify", strings.NewReader(`{"password":"x"}`))
+	if w := serve(router, r); w.Code != http.StatusBadRequest {
+		t.Errorf("verify of a public file = %d, want 400", w.Code)
+	}
+}
@@ -0,0 +1,326 @@
+package handlers
+
+import (
+	"errors"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/hazebio/haze.bio_file-upload/services"
+	"github.com/hazebio/haze.bio_file-upload/utils"
+)
+
+// Recommended part size for multipart uploads, S3 requires at least 5MB for every part but the last
+const multipartPartSize = 8 * 1024 * 1024
+
+// S3 allows part numbers from 1 to 10000
+const maxPartNumber = 10000
+
+// UploadHandler serves uploads made directly by browsers with a grant signed by the backend
+type UploadHandler struct {
+	StorageService *services.StorageService
+	TokenStore     services.TokenStore
+}
+
+func NewUploadHandler(storageService *services.StorageService, tokenStore services.TokenStore) *UploadHandler {
+	return &UploadHandler{
+		StorageService: storageService,
+		TokenStore:     tokenStore,
+	}
+}
+
+/* Read the grant from the Authorization header and check it covers the key in the query */
+func (uh *UploadHandler) authorize(w http.ResponseWriter, r *http.Request) (*services.UploadGrant, string, bool) {
+	token := strings.TrimPrefix(r.Header.Get("Authorization"), "Bearer ")
+
+	grant, err := services.ParseUploadGrant(token)
+	if err != nil {
+		utils.RespondError(w, http.StatusUnauthorized, "Invalid or expired upload grant")
+		return nil, "", false
+	}
+
+	key := r.URL.Query().Get("key")
+	if !grant.Allows(key) {
+		utils.RespondError(w, http.StatusForbidden, "Upload grant does not cover this key")
+		return nil, "", false
+	}
+
+	return grant, key, true
+}
+
+/* Check the declared size of a request body against the limit */
+func checkContentLength(w http.ResponseWriter, r *http.Request, limit int64) bool {
+	if r.ContentLength <= 0 {
+		utils.RespondError(w, http.StatusLengthRequired, "Content-Length is required")
+		return false
+	}
+
+	if r.ContentLength > limit {
+		utils.RespondError(w, http.StatusRequestEntityTooLarge, "File exceeds the size allowed by the upload grant")
+		return false
+	}
+
+	// The body is streamed to storage, never trust the header alone
+	r.Body = http.MaxBytesReader(w, r.Body, r.ContentLength)
+	return true
+}
+
+/*
+Count part bytes received with a grant, across every multipart upload made
+with it. The counter outlives the grant, parts can't be uploaded after that.
+*/
+func (uh *UploadHandler) addPartBytes(grant *services.UploadGrant, delta int64) (int64, error) {
+	return uh.TokenStore.Add("grant-bytes:"+grant.ID, delta, time.Until(grant.Expiry())+time.Minute)
+}
+
+/* Give back the bytes of parts that were removed from storage */
+func (uh *UploadHandler) releaseParts(grant *services.UploadGrant, parts []services.Part) {
+	size := int64(0)
+	for _, part := range parts {
+		size += part.Size
+	}
+
+	if size > 0 {
+		if _, err := uh.addPartBytes(grant, -size); err != nil {
+			log.Printf("Error releasing parts of grant %s: %v", grant.ID, err)
+		}
+	}
+}
+
+func (uh *UploadHandler) PutObject(w http.ResponseWriter, r *http.Request) {
+	grant, key, ok := uh.authorize(w, r)
+	if !ok {
+		return
+	}
+
+	if r.Header.Get("Content-Type") != grant.ContentType {
+		utils.RespondError(w, http.StatusUnsupportedMediaType, "Content-Type does not match the upload grant")
+		return
+	}
+
+	if !checkContentLength(w, r, grant.MaxSize) {
+		return
+	}
+
+	opts, err := grant.PutOptions()
+	if err != nil {
+		utils.RespondError(w, http.StatusInternalServerError, "Failed to upload file")
+		return
+	}
+
+	err = uh.StorageService.Store.Put(key, r.Body, r.ContentLength, opts)
+	if err != nil {
+		log.Printf("Error storing direct upload %s: %v", key, err)
+		utils.RespondError(w, http.StatusInternalServerError, "Failed to upload file")
+		return
+	}
+
+	log.Printf("PUT direct %s, grant %s, %d bytes", key, grant.ID, r.ContentLength)
+	utils.RespondSuccess(w, "Uploaded "+key+" successfully!", map[string]interface{}{
+		"key":  key,
+		"size": r.ContentLength,
+	})
+}
+
+/* The multipart store or a 501 response when the backend doesn't support it */
+func (uh *UploadHandler) multipart(w http.ResponseWriter) (services.MultipartStore, bool) {
+	store, ok := uh.StorageService.Multipart()
+	if !ok {
+		utils.RespondError(w, http.StatusNotImplemented, "Multipart uploads are not supported by this storage backend")
+	}
+	return store, ok
+}
+
+func (uh *UploadHandler) CreateMultipartUpload(w http.ResponseWriter, r *http.Request) {
+	grant, key, ok := uh.authorize(w, r)
+	if !ok {
+		return
+	}
+
+	store, ok := uh.multipart(w)
+	if !ok {
+		return
+	}
+
+	opts, err := grant.PutOptions()
+	if err != nil {
+		utils.RespondError(w, http.StatusInternalServerError, "Failed to create upload")
+		return
+	}
+
+	uploadID, err := store.CreateMultipartUpload(key, opts)
+	if err != nil {
+		log.Printf("Error creating multipart upload for %s: %v", key, err)
+		utils.RespondError(w, http.StatusInternalServerError, "Failed to create upload")
+		return
+	}
+
+	utils.RespondSuccess(w, "Multipart upload created", map[string]interface{}{
+		"key":      key,
+		"uploadId": uploadID,
+		"partSize": multipartPartSize,
+	})
+}
+
+/* List the parts uploaded so far, clients call this to resume after losing the connection */
+func (uh *UploadHandler) ListParts(w http.ResponseWriter, r *http.Request) {
+	_, key, ok := uh.authorize(w, r)
+	if !ok {
+		return
+	}
+
+	store, ok := uh.multipart(w)
+	if !ok {
+		return
+	}
+
+	parts, err := store.ListParts(key, mux.Vars(r)["uploadId"])
+	if err != nil {
+		respondMultipartError(w, key, err)
+		return
+	}
+
+	utils.RespondSuccess(w, "Listed parts successfully", parts)
+}
+
+func (uh *UploadHandler) UploadPart(w http.ResponseWriter, r *http.Request) {
+	grant, key, ok := uh.authorize(w, r)
+	if !ok {
+		return
+	}
+
+	store, ok := uh.multipart(w)
+	if !ok {
+		return
+	}
+
+	number, err := strconv.Atoi(mux.Vars(r)["part"])
+	if err != nil || number < 1 || number > maxPartNumber {
+		utils.RespondError(w, http.StatusBadRequest, "Invalid part number")
+		return
+	}
+
+	if !checkContentLength(w, r, grant.MaxSize) {
+		return
+	}
+
+	// Parts are stored until the upload completes or is aborted, so all of
+	// them together have to fit into the grant, not just each one
+	received, err := uh.addPartBytes(grant, r.ContentLength)
+	if err != nil {
+		log.Printf("Error counting parts of grant %s: %v", grant.ID, err)
+		utils.RespondError(w, http.StatusInternalServerError, "Failed to process upload")
+		return
+	}
+
+	if received > grant.MaxSize {
+		uh.addPartBytes(grant, -r.ContentLength)
+		utils.RespondError(w, http.StatusRequestEntityTooLarge, "Parts exceed the size allowed by the upload grant")
+		return
+	}
+
+	part, err := store.UploadPart(key, mux.Vars(r)["uploadId"], int32(number), r.Body, r.ContentLength)
+	if err != nil {
+		uh.addPartBytes(grant, -r.ContentLength)
+		respondMultipartError(w, key, err)
+		return
+	}
+
+	utils.RespondSuccess(w, "Uploaded part successfully", part)
+}
+
+/*
+Complete an upload from the parts stored so far. The parts are listed on the
+server, so clients that lost their part ETags can still complete.
+*/
+func (uh *UploadHandler) CompleteMultipartUpload(w http.ResponseWriter, r *http.Request) {
+	grant, key, ok := uh.authorize(w, r)
+	if !ok {
+		return
+	}
+
+	store, ok := uh.multipart(w)
+	if !ok {
+		return
+	}
+
+	uploadID := mux.Vars(r)["uploadId"]
+	parts, err := store.ListParts(key, uploadID)
+	if err != nil {
+		respondMultipartError(w, key, err)
+		return
+	}
+
+	if len(parts) == 0 {
+		utils.RespondError(w, http.StatusBadRequest, "No parts were uploaded")
+		return
+	}
+
+	size := int64(0)
+	for i, part := range parts {
+		if part.Number != int32(i+1) {
+			utils.RespondError(w, http.StatusBadRequest, "Part "+strconv.Itoa(i+1)+" is missing")
+			return
+		}
+		size += part.Size
+	}
+
+	if size > grant.MaxSize {
+		if store.AbortMultipartUpload(key, uploadID) == nil {
+			uh.releaseParts(grant, parts)
+		}
+		utils.RespondError(w, http.StatusRequestEntityTooLarge, "File exceeds the size allowed by the upload grant")
+		return
+	}
+
+	if err := store.CompleteMultipartUpload(key, uploadID, parts); err != nil {
+		respondMultipartError(w, key, err)
+		return
+	}
+
+	log.Printf("Completed multipart upload %s, grant %s, %d parts, %d bytes", key, grant.ID, len(parts), size)
+	utils.RespondSuccess(w, "Uploaded "+key+" successfully!", map[string]interface{}{
+		"key":  key,
+		"size": size,
+	})
+}
+
+func (uh *UploadHandler) AbortMultipartUpload(w http.ResponseWriter, r *http.Request) {
+	grant, key, ok := uh.authorize(w, r)
+	if !ok {
+		return
+	}
+
+	store, ok := uh.multipart(w)
+	if !ok {
+		return
+	}
+
+	uploadID := mux.Vars(r)["uploadId"]
+	parts, err := store.ListParts(key, uploadID)
+	if err != nil {
+		respondMultipartError(w, key, err)
+		return
+	}
+
+	if err := store.AbortMultipartUpload(key, uploadID); err != nil {
+		respondMultipartError(w, key, err)
+		return
+	}
+	uh.releaseParts(grant, parts)
+
+	utils.RespondSuccess(w, "Upload aborted", nil)
+}
+
+func respondMultipartError(w http.ResponseWriter, key string, err error) {
+	if errors.Is(err, services.ErrUploadNotFound) {
+		utils.RespondError(w, http.StatusNotFound, "Upload not found")
+		return
+	}
+
+	log.Printf("Error in multipart upload %s: %v", key, err)
+	utils.RespondError(w, http.StatusInternalServerError, "Failed to process upload")
+}
@@ -0,0 +1,143 @@
+package handlers
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/hazebio/haze.bio_file-upload/config"
+	"github.com/hazebio/haze.bio_file-upload/services"
+)
+
+/* Token for a grant, signed the way the backend signs them */
+func signGrant(t *testing.T, grant services.UploadGrant) string {
+	t.Helper()
+
+	data, err := json.Marshal(grant)
+	if err != nil {
+		t.Fatal(err)
+	}
+	payload := base64.RawURLEncoding.EncodeToString(data)
+
+	mac := hmac.New(sha256.New, []byte(config.UploadGrantSecret))
+	mac.Write([]byte(payload))
+	return payload + "." + base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+}
+
+func TestDirectUploadsAreStagedPrivately(t *testing.T) {
+	router, _ := newTestServer(t)
+	config.UploadGrantSecret = "test-grant-secret"
+
+	token := signGrant(t, services.UploadGrant{
+		ID:          "grant",
+		Key:         "uploads/1/grant/original.png",
+		MaxSize:     1024,
+		ContentType: "image/png",
+		ExpiresAt:   time.Now().Add(time.Hour).Unix(),
+	})
+
+	key := "uploads/1/grant/original.png"
+	r := httptest.NewRequest(http.MethodPut, "/api/uploads?key="+key, strings.NewReader("staged bytes"))
+	r.Header.Set("Authorization", "Bearer "+token)
+	r.Header.Set("Content-Type", "image/png")
+	if w := serve(router, r); w.Code != http.StatusOK {
+		t.Fatalf("direct upload = %d: %s", w.Code, w.Body.String())
+	}
+
+	if w := serve(router, httptest.NewRequest(http.MethodGet, "/"+key, nil)); w.Code != http.StatusForbidden {
+		t.Fatalf("GET staged upload without a signature = %d, want 403", w.Code)
+	}
+
+	signedURL := "/" + key + "?" + services.SignedQuery(key, time.Now().Add(time.Minute), "")
+	w := serve(router, httptest.NewRequest(http.MethodGet, signedURL, nil))
+	if w.Code != http.StatusOK || w.Body.String() != "staged bytes" {
+		t.Fatalf("GET staged upload with a signature = %d %q", w.Code, w.Body.String())
+	}
+}
+
+func TestDirectUploadsOnlyWriteTheGrantedKey(t *testing.T) {
+	router, _ := newTestServer(t)
+	config.UploadGrantSecret = "test-grant-secret"
+
+	token := signGrant(t, services.UploadGrant{
+		ID:          "grant",
+		Key:         "uploads/1/grant/original.png",
+		MaxSize:     1024,
+		ContentType: "image/png",
+		ExpiresAt:   time.Now().Add(time.Hour).Unix(),
+	})
+
+	for _, key := range []string{"uploads/1/grant/other.png", "uploads/1/grant/original.png.2", "uploads/1/grant/", "uploads/1/grant/../original.png"} {
+		r := httptest.NewRequest(http.MethodPut, "/api/uploads?key="+key, strings.NewReader("bytes"))
+		r.Header.Set("Authorization", "Bearer "+token)
+		r.Header.Set("Content-Type", "image/png")
+		if w := serve(router, r); w.Code != http.StatusForbidden {
+			t.Fatalf("direct upload to %s = %d, want 403", key, w.Code)
+		}
+	}
+}
+
+func TestMultipartPartsCountTowardsTheGrant(t *testing.T) {
+	router, _ := newTestServer(t)
+	config.UploadGrantSecret = "test-grant-secret"
+
+	key := "uploads/1/grant/original.mp4"
+	token := signGrant(t, services.UploadGrant{
+		ID:          "grant",
+		Key:         key,
+		MaxSize:     10,
+		ContentType: "video/mp4",
+		ExpiresAt:   time.Now().Add(time.Hour).Unix(),
+	})
+
+	request := func(method string, path string, body string) *httptest.ResponseRecorder {
+		r := httptest.NewRequest(method, path+"?key="+key, strings.NewReader(body))
+		r.Header.Set("Authorization", "Bearer "+token)
+		return serve(router, r)
+	}
+
+	create := func() string {
+		w := request(http.MethodPost, "/api/uploads/multipart", "")
+		var response struct {
+			Data struct {
+				UploadID string `json:"uploadId"`
+			} `json:"data"`
+		}
+		if err := json.Unmarshal(w.Body.Bytes(), &response); err != nil || response.Data.UploadID == "" {
+			t.Fatalf("create multipart upload = %d: %s", w.Code, w.Body.String())
+		}
+		return response.Data.UploadID
+	}
+
+	first := create()
+	if w := request(http.MethodPut, fmt.Sprintf("/api/uploads/multipart/%s/1", first), "123456"); w.Code != http.StatusOK {
+		t.Fatalf("part 1 = %d: %s", w.Code, w.Body.String())
+	}
+	if w := request(http.MethodPut, fmt.Sprintf("/api/uploads/multipart/%s/2", first), "123456"); w.Code != http.StatusRequestEntityTooLarge {
+		t.Fatalf("part 2 over the grant = %d, want 413", w.Code)
+	}
+
+	// A second upload with the same grant shares the limit
+	second := create()
+	if w := request(http.MethodPut, fmt.Sprintf("/api/uploads/multipart/%s/1", second), "123456"); w.Code != http.StatusRequestEntityTooLarge {
+		t.Fatalf("part of a second upload over the grant = %d, want 413", w.Code)
+	}
+
+	// Aborting gives the bytes of its parts back
+	if w := request(http.MethodDelete, "/api/uploads/multipart/"+first, ""); w.Code != http.StatusOK {
+		t.Fatalf("abort = %d: %s", w.Code, w.Body.String())
+	}
+	if w := request(http.MethodPut, fmt.Sprintf("/api/uploads/multipart/%s/1", second), "123456"); w.Code != http.StatusOK {
+		t.Fatalf("part after abort = %d: %s", w.Code, w.Body.String())
+	}
+	if w := request(http.MethodPost, fmt.Sprintf("/api/uploads/multipart/%s/complete", second), ""); w.Code != http.StatusOK {
+		t.Fatalf("complete = %d: %s", w.Code, w.Body.String())
+	}
+}
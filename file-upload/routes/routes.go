@@ -9,8 +9,9 @@ import (
 	"github.com/hazebio/haze.bio_file-upload/utils"
 )
 
-func RegisterRoutes(router *mux.Router, r2Service *services.R2Service) {
-	fileHandler := handlers.NewFileHandler(r2Service)
+func RegisterRoutes(router *mux.Router, storageService *services.StorageService, tokenStore services.TokenStore) {
+	fileHandler := handlers.NewFileHandler(storageService, tokenStore)
+	uploadHandler := handlers.NewUploadHandler(storageService, tokenStore)
 
 	apiRouter := router.PathPrefix("/api").Subrouter()
 
@@ -18,6 +19,17 @@ func RegisterRoutes(router *mux.Router, r2Service *services.R2Service) {
 		utils.RespondSuccess(w, "Service is healthy", nil)
 	}).Methods("GET")
 
+	apiRouter.HandleFunc("/objects", fileHandler.ListObjects).Methods("GET")
+
+	// Direct uploads authorized by a grant from the backend
+	apiRouter.HandleFunc("/uploads", uploadHandler.PutObject).Methods("PUT")
+	apiRouter.HandleFunc("/uploads/multipart", uploadHandler.CreateMultipartUpload).Methods("POST")
+	apiRouter.HandleFunc("/uploads/multipart/{uploadId}", uploadHandler.ListParts).Methods("GET")
+	apiRouter.HandleFunc("/uploads/multipart/{uploadId}", uploadHandler.AbortMultipartUpload).Methods("DELETE")
+	apiRouter.HandleFunc("/uploads/multipart/{uploadId}/complete", uploadHandler.CompleteMultipartUpload).Methods("POST")
+	apiRouter.HandleFunc("/uploads/multipart/{uploadId}/{part:[0-9]+}", uploadHandler.UploadPart).Methods("PUT")
+
 	fileRouter := router.NewRoute().Subrouter()
-	fileRouter.HandleFunc("/{key:.*}", fileHandler.HandleFileOperations).Methods("PUT", "DELETE")
+	fileRouter.HandleFunc("/{key:.*}/verify", fileHandler.HandleFileOperations).Methods("POST")
+	fileRouter.HandleFunc("/{key:.*}", fileHandler.HandleFileOperations).Methods("GET", "PUT", "DELETE")
 }
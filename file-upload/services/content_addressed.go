@@ -0,0 +1,204 @@
+package services
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/hazebio/haze.bio_file-upload/utils"
+)
+
+// Content-addressed files store their bytes once under casPrefix, named by
+// the SHA-256 of the content. The key a file was uploaded under holds an
+// empty pointer object naming the blob, and each pointer has a marker under
+// refsPrefix. The markers are the reference count of the blob, it is deleted
+// together with its last marker.
+const (
+	casPrefix  = "cas/"
+	refsPrefix = "refs/"
+
+	contentHashMetadata = "content-hash"
+)
+
+// ContentAddressedFile is the result of a content-addressed upload
+type ContentAddressedFile struct {
+	Hash         string `json:"hash"`
+	Size         int64  `json:"size"`
+	Deduplicated bool   `json:"deduplicated"`
+}
+
+// Reference changes of a blob are serialized within an instance, striped by
+// the first byte of the hash
+var blobLocks [64]sync.Mutex
+
+func lockBlob(hash string) *sync.Mutex {
+	b, _ := strconv.ParseUint(hash[:2], 16, 8)
+	return &blobLocks[b%uint64(len(blobLocks))]
+}
+
+/* Whether a key belongs to the blobs and reference markers, these are never served or listed directly */
+func IsInternalKey(key string) bool {
+	return strings.HasPrefix(key, casPrefix) || strings.HasPrefix(key, refsPrefix)
+}
+
+func blobKey(hash string) string {
+	return casPrefix + hash
+}
+
+/* Marker of the reference from key to a blob, the key is hashed so any key maps to a flat name */
+func refKey(hash string, key string) string {
+	sum := sha256.Sum256([]byte(key))
+	return refsPrefix + hash + "/" + hex.EncodeToString(sum[:])
+}
+
+/*
+Upload a file under key, storing its content only if no other file has the
+same bytes. Uploading again under the same key replaces the reference, so
+retries never inflate the count.
+*/
+func (s *StorageService) UploadContentAddressedFile(key string, body io.Reader, access FileAccess) (*ContentAddressedFile, error) {
+	tmp, err := os.CreateTemp("", "upload-*")
+	if err != nil {
+		return nil, fmt.Errorf("failed to buffer upload: %w", err)
+	}
+	defer os.Remove(tmp.Name())
+	defer tmp.Close()
+
+	hasher := sha256.New()
+	size, err := io.Copy(io.MultiWriter(tmp, hasher), body)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read body: %w", err)
+	}
+
+	file := &ContentAddressedFile{
+		Hash: hex.EncodeToString(hasher.Sum(nil)),
+		Size: size,
+	}
+
+	previous, err := s.contentHash(key)
+	if err != nil {
+		return nil, err
+	}
+
+	metadata := map[string]string{contentHashMetadata: file.Hash}
+	if access.Policy != "" && access.Policy != AccessPublic {
+		metadataJSON, err := accessMetadata(access)
+		if err != nil {
+			return nil, err
+		}
+		metadata["file-metadata"] = metadataJSON
+	}
+
+	err = func() error {
+		mu := lockBlob(file.Hash)
+		mu.Lock()
+		defer mu.Unlock()
+
+		// The marker goes first, a concurrent delete of the last other
+		// reference then keeps the blob
+		if err := s.Store.Put(refKey(file.Hash, key), bytes.NewReader(nil), 0, PutOptions{}); err != nil {
+			return err
+		}
+
+		_, err := s.Store.Head(blobKey(file.Hash))
+		switch {
+		case err == nil:
+			file.Deduplicated = true
+		case errors.Is(err, ErrObjectNotFound):
+			if _, err := tmp.Seek(0, io.SeekStart); err != nil {
+				return err
+			}
+			if err := s.Store.Put(blobKey(file.Hash), tmp, size, PutOptions{ContentType: utils.GetContentType(key)}); err != nil {
+				return err
+			}
+		default:
+			return err
+		}
+
+		return s.Store.Put(key, bytes.NewReader(nil), 0, PutOptions{
+			ContentType: utils.GetContentType(key),
+			Metadata:    metadata,
+		})
+	}()
+	if err != nil {
+		return nil, err
+	}
+
+	if previous != "" && previous != file.Hash {
+		if err := s.releaseBlob(previous, key); err != nil {
+			log.Printf("Error releasing previous content of %s: %v", key, err)
+		}
+	}
+
+	return file, nil
+}
+
+/* Hash of the content a key points at, empty for missing keys and regular objects */
+func (s *StorageService) contentHash(key string) (string, error) {
+	info, err := s.Store.Head(key)
+	if err != nil {
+		if errors.Is(err, ErrObjectNotFound) {
+			return "", nil
+		}
+		return "", err
+	}
+
+	return info.Metadata[contentHashMetadata], nil
+}
+
+/* Drop the reference from key to a blob and delete the blob if it was the last one */
+func (s *StorageService) releaseBlob(hash string, key string) error {
+	mu := lockBlob(hash)
+	mu.Lock()
+	defer mu.Unlock()
+
+	if err := s.Store.Delete(refKey(hash, key)); err != nil {
+		return err
+	}
+
+	refs, err := s.Store.List(refsPrefix+hash+"/", "", 1)
+	if err != nil {
+		return err
+	}
+
+	if len(refs.Objects) > 0 {
+		return nil
+	}
+
+	log.Printf("Deleting unreferenced content %s", hash)
+	return s.Store.Delete(blobKey(hash))
+}
+
+/*
+Resolve a key to the info of the file and the key its bytes are stored
+under. Content-addressed files report the size of their blob and the
+content hash as a strong ETag, everything else comes from the pointer.
+*/
+func (s *StorageService) resolve(key string) (*ObjectInfo, string, error) {
+	info, err := s.Store.Head(key)
+	if err != nil {
+		return nil, "", err
+	}
+
+	hash := info.Metadata[contentHashMetadata]
+	if hash == "" {
+		return info, key, nil
+	}
+
+	blob, err := s.Store.Head(blobKey(hash))
+	if err != nil {
+		return nil, "", err
+	}
+
+	info.Size = blob.Size
+	info.ETag = `"` + hash + `"`
+	return info, blobKey(hash), nil
+}
@@ -0,0 +1,448 @@
+package services
+
+import (
+	"crypto/md5"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// LocalStore keeps objects on the local filesystem for development and
+// tests. Object content lives under objects/ and the content type, metadata
+// and ETag of each object in a JSON sidecar under meta/. Multipart uploads
+// keep their parts under multipart/{uploadID}/ until they are completed.
+type LocalStore struct {
+	root string
+}
+
+type localObjectMeta struct {
+	ContentType string            `json:"contentType"`
+	Metadata    map[string]string `json:"metadata,omitempty"`
+	ETag        string            `json:"etag"`
+}
+
+func NewLocalStore(root string) (*LocalStore, error) {
+	if root == "" {
+		root = "storage"
+	}
+
+	for _, dir := range []string{"objects", "meta", "multipart"} {
+		if err := os.MkdirAll(filepath.Join(root, dir), 0o755); err != nil {
+			return nil, fmt.Errorf("failed to create storage directory: %w", err)
+		}
+	}
+
+	return &LocalStore{root: root}, nil
+}
+
+func (s *LocalStore) Name() string {
+	return "local"
+}
+
+/* Resolve a key to its object and sidecar paths, keys escaping the root are rejected */
+func (s *LocalStore) paths(key string) (string, string, error) {
+	if key == "" || strings.HasSuffix(key, "/") || path.Clean("/"+key) != "/"+key {
+		return "", "", ErrInvalidKey
+	}
+
+	objectPath := filepath.Join(s.root, "objects", filepath.FromSlash(key))
+	metaPath := filepath.Join(s.root, "meta", filepath.FromSlash(key)+".json")
+	return objectPath, metaPath, nil
+}
+
+func (s *LocalStore) Put(key string, body io.Reader, size int64, opts PutOptions) error {
+	objectPath, metaPath, err := s.paths(key)
+	if err != nil {
+		return err
+	}
+
+	for _, dir := range []string{filepath.Dir(objectPath), filepath.Dir(metaPath)} {
+		if err := os.MkdirAll(dir, 0o755); err != nil {
+			return err
+		}
+	}
+
+	// Write to a temporary file first so readers never see a partial object
+	tmp, err := os.CreateTemp(filepath.Dir(objectPath), ".upload-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+
+	hash := md5.New()
+	written, err := io.Copy(io.MultiWriter(tmp, hash), body)
+	if closeErr := tmp.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return err
+	}
+
+	if size >= 0 && written != size {
+		return fmt.Errorf("expected %d bytes, got %d", size, written)
+	}
+
+	meta, err := json.Marshal(localObjectMeta{
+		ContentType: opts.ContentType,
+		Metadata:    opts.Metadata,
+		ETag:        `"` + hex.EncodeToString(hash.Sum(nil)) + `"`,
+	})
+	if err != nil {
+		return err
+	}
+
+	if err := os.WriteFile(metaPath, meta, 0o644); err != nil {
+		return err
+	}
+
+	return os.Rename(tmp.Name(), objectPath)
+}
+
+func (s *LocalStore) Get(key string) (*Object, error) {
+	info, err := s.Head(key)
+	if err != nil {
+		return nil, err
+	}
+
+	objectPath, _, _ := s.paths(key)
+	file, err := os.Open(objectPath)
+	if err != nil {
+		return nil, localError(err)
+	}
+
+	return &Object{ObjectInfo: *info, Body: file}, nil
+}
+
+func (s *LocalStore) GetRange(key string, start, end int64) (*Object, error) {
+	object, err := s.Get(key)
+	if err != nil {
+		return nil, err
+	}
+
+	if end >= object.Size {
+		end = object.Size - 1
+	}
+
+	if start < 0 || start > end {
+		object.Body.Close()
+		return nil, fmt.Errorf("invalid range %d-%d", start, end)
+	}
+
+	file := object.Body.(*os.File)
+	if _, err := file.Seek(start, io.SeekStart); err != nil {
+		file.Close()
+		return nil, err
+	}
+
+	object.Size = end - start + 1
+	object.Body = struct {
+		io.Reader
+		io.Closer
+	}{io.LimitReader(file, object.Size), file}
+
+	return object, nil
+}
+
+func (s *LocalStore) Head(key string) (*ObjectInfo, error) {
+	objectPath, metaPath, err := s.paths(key)
+	if err != nil {
+		return nil, err
+	}
+
+	stat, err := os.Stat(objectPath)
+	if err != nil {
+		return nil, localError(err)
+	}
+
+	info := &ObjectInfo{
+		Key:          key,
+		Size:         stat.Size(),
+		LastModified: stat.ModTime(),
+	}
+
+	// Objects copied into the directory by hand have no sidecar
+	if data, err := os.ReadFile(metaPath); err == nil {
+		var meta localObjectMeta
+		if err := json.Unmarshal(data, &meta); err != nil {
+			return nil, fmt.Errorf("failed to read metadata: %w", err)
+		}
+		info.ContentType = meta.ContentType
+		info.Metadata = meta.Metadata
+		info.ETag = meta.ETag
+	}
+
+	return info, nil
+}
+
+func (s *LocalStore) Delete(key string) error {
+	objectPath, metaPath, err := s.paths(key)
+	if err != nil {
+		return err
+	}
+
+	for _, p := range []string{objectPath, metaPath} {
+		if err := os.Remove(p); err != nil && !errors.Is(err, fs.ErrNotExist) {
+			return err
+		}
+	}
+
+	return nil
+}
+
+/* List keys in lexical order like S3, the cursor is the last key of the previous page */
+func (s *LocalStore) List(prefix string, cursor string, limit int32) (*ObjectList, error) {
+	objectsDir := filepath.Join(s.root, "objects")
+
+	keys := []string{}
+	err := filepath.WalkDir(objectsDir, func(p string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.IsDir() || strings.HasPrefix(d.Name(), ".upload-") {
+			return nil
+		}
+
+		rel, err := filepath.Rel(objectsDir, p)
+		if err != nil {
+			return err
+		}
+
+		key := filepath.ToSlash(rel)
+		if strings.HasPrefix(key, prefix) && key > cursor {
+			keys = append(keys, key)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	sort.Strings(keys)
+
+	list := &ObjectList{Objects: []ObjectInfo{}}
+	for _, key := range keys {
+		if int32(len(list.Objects)) == limit {
+			list.NextCursor = list.Objects[len(list.Objects)-1].Key
+			break
+		}
+
+		info, err := s.Head(key)
+		if err != nil {
+			// Deleted while listing
+			continue
+		}
+		list.Objects = append(list.Objects, *info)
+	}
+
+	return list, nil
+}
+
+type localUpload struct {
+	Key  string     `json:"key"`
+	Opts PutOptions `json:"opts"`
+}
+
+var localUploadIDPattern = regexp.MustCompile(`^[0-9a-f]{32}$`)
+
+/* Directory of a multipart upload, the upload has to exist and belong to the key */
+func (s *LocalStore) uploadDir(key string, uploadID string) (string, *localUpload, error) {
+	if !localUploadIDPattern.MatchString(uploadID) {
+		return "", nil, ErrUploadNotFound
+	}
+
+	dir := filepath.Join(s.root, "multipart", uploadID)
+	data, err := os.ReadFile(filepath.Join(dir, "upload.json"))
+	if err != nil {
+		if errors.Is(err, fs.ErrNotExist) {
+			return "", nil, ErrUploadNotFound
+		}
+		return "", nil, err
+	}
+
+	var upload localUpload
+	if err := json.Unmarshal(data, &upload); err != nil {
+		return "", nil, err
+	}
+
+	if upload.Key != key {
+		return "", nil, ErrUploadNotFound
+	}
+
+	return dir, &upload, nil
+}
+
+func (s *LocalStore) CreateMultipartUpload(key string, opts PutOptions) (string, error) {
+	if _, _, err := s.paths(key); err != nil {
+		return "", err
+	}
+
+	id := make([]byte, 16)
+	if _, err := rand.Read(id); err != nil {
+		return "", err
+	}
+	uploadID := hex.EncodeToString(id)
+
+	dir := filepath.Join(s.root, "multipart", uploadID)
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return "", err
+	}
+
+	data, err := json.Marshal(localUpload{Key: key, Opts: opts})
+	if err != nil {
+		return "", err
+	}
+
+	if err := os.WriteFile(filepath.Join(dir, "upload.json"), data, 0o644); err != nil {
+		return "", err
+	}
+
+	return uploadID, nil
+}
+
+func (s *LocalStore) UploadPart(key string, uploadID string, number int32, body io.Reader, size int64) (*Part, error) {
+	dir, _, err := s.uploadDir(key, uploadID)
+	if err != nil {
+		return nil, err
+	}
+
+	tmp, err := os.CreateTemp(dir, ".part-*")
+	if err != nil {
+		return nil, err
+	}
+	defer os.Remove(tmp.Name())
+
+	hash := md5.New()
+	written, err := io.Copy(io.MultiWriter(tmp, hash), body)
+	if closeErr := tmp.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if size >= 0 && written != size {
+		return nil, fmt.Errorf("expected %d bytes, got %d", size, written)
+	}
+
+	// Re-uploading a part replaces it, like S3
+	partPath := filepath.Join(dir, fmt.Sprintf("%05d", number))
+	if err := os.Rename(tmp.Name(), partPath); err != nil {
+		return nil, err
+	}
+
+	etag := `"` + hex.EncodeToString(hash.Sum(nil)) + `"`
+	if err := os.WriteFile(partPath+".etag", []byte(etag), 0o644); err != nil {
+		return nil, err
+	}
+
+	return &Part{Number: number, ETag: etag, Size: written}, nil
+}
+
+func (s *LocalStore) ListParts(key string, uploadID string) ([]Part, error) {
+	dir, _, err := s.uploadDir(key, uploadID)
+	if err != nil {
+		return nil, err
+	}
+
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	parts := []Part{}
+	for _, entry := range entries {
+		number, err := strconv.Atoi(entry.Name())
+		if err != nil {
+			continue
+		}
+
+		info, err := entry.Info()
+		if err != nil {
+			return nil, err
+		}
+
+		etag, err := os.ReadFile(filepath.Join(dir, entry.Name()+".etag"))
+		if err != nil {
+			// The part is still being written
+			continue
+		}
+
+		parts = append(parts, Part{Number: int32(number), ETag: string(etag), Size: info.Size()})
+	}
+
+	sort.Slice(parts, func(i, j int) bool { return parts[i].Number < parts[j].Number })
+
+	return parts, nil
+}
+
+func (s *LocalStore) CompleteMultipartUpload(key string, uploadID string, parts []Part) error {
+	dir, upload, err := s.uploadDir(key, uploadID)
+	if err != nil {
+		return err
+	}
+
+	uploaded, err := s.ListParts(key, uploadID)
+	if err != nil {
+		return err
+	}
+
+	etags := make(map[int32]string, len(uploaded))
+	for _, part := range uploaded {
+		etags[part.Number] = part.ETag
+	}
+
+	readers := []io.Reader{}
+	size := int64(0)
+	for _, part := range parts {
+		if etags[part.Number] == "" || etags[part.Number] != part.ETag {
+			return fmt.Errorf("part %d was not uploaded", part.Number)
+		}
+
+		file, err := os.Open(filepath.Join(dir, fmt.Sprintf("%05d", part.Number)))
+		if err != nil {
+			return err
+		}
+		defer file.Close()
+
+		stat, err := file.Stat()
+		if err != nil {
+			return err
+		}
+
+		readers = append(readers, file)
+		size += stat.Size()
+	}
+
+	if err := s.Put(key, io.MultiReader(readers...), size, upload.Opts); err != nil {
+		return err
+	}
+
+	return os.RemoveAll(dir)
+}
+
+func (s *LocalStore) AbortMultipartUpload(key string, uploadID string) error {
+	dir, _, err := s.uploadDir(key, uploadID)
+	if err != nil {
+		return err
+	}
+
+	return os.RemoveAll(dir)
+}
+
+func localError(err error) error {
+	if errors.Is(err, fs.ErrNotExist) {
+		return ErrObjectNotFound
+	}
+	return err
+}
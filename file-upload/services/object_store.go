@@ -0,0 +1,105 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"time"
+
+	"github.com/hazebio/haze.bio_file-upload/config"
+)
+
+var (
+	ErrObjectNotFound = errors.New("object not found")
+	ErrInvalidKey     = errors.New("invalid object key")
+	ErrUploadNotFound = errors.New("upload not found")
+)
+
+// ObjectInfo describes a stored object as returned by ListObjects
+type ObjectInfo struct {
+	Key          string            `json:"key"`
+	Size         int64             `json:"size"`
+	LastModified time.Time         `json:"lastModified"`
+	ETag         string            `json:"etag"`
+	ContentType  string            `json:"-"`
+	Metadata     map[string]string `json:"-"`
+}
+
+// ObjectList is one page of ListObjects, NextCursor is empty on the last page
+type ObjectList struct {
+	Objects    []ObjectInfo `json:"objects"`
+	NextCursor string       `json:"nextCursor,omitempty"`
+}
+
+// Object is the content of a stored object, the caller closes Body
+type Object struct {
+	ObjectInfo
+	Body io.ReadCloser
+}
+
+type PutOptions struct {
+	ContentType string
+	Metadata    map[string]string
+}
+
+// ObjectStore is a bucket of objects addressed by slash separated keys. Get,
+// GetRange and Head return ErrObjectNotFound for missing keys, Delete of a
+// missing key is not an error.
+type ObjectStore interface {
+	Name() string
+	Put(key string, body io.Reader, size int64, opts PutOptions) error
+	Get(key string) (*Object, error)
+	// GetRange returns the bytes from start to end inclusive
+	GetRange(key string, start, end int64) (*Object, error)
+	Head(key string) (*ObjectInfo, error)
+	Delete(key string) error
+	List(prefix string, cursor string, limit int32) (*ObjectList, error)
+}
+
+// Part is one uploaded part of a multipart upload
+type Part struct {
+	Number int32  `json:"number"`
+	ETag   string `json:"etag"`
+	Size   int64  `json:"size"`
+}
+
+// MultipartStore is implemented by stores that can assemble an object from
+// parts uploaded separately, so a large upload can resume after a network
+// failure instead of starting over. Missing uploads return ErrUploadNotFound.
+type MultipartStore interface {
+	CreateMultipartUpload(key string, opts PutOptions) (string, error)
+	UploadPart(key string, uploadID string, number int32, body io.Reader, size int64) (*Part, error)
+	// ListParts returns the parts uploaded so far ordered by number
+	ListParts(key string, uploadID string) ([]Part, error)
+	CompleteMultipartUpload(key string, uploadID string, parts []Part) error
+	AbortMultipartUpload(key string, uploadID string) error
+}
+
+// NewObjectStore creates the store selected by STORAGE_BACKEND
+func NewObjectStore() (ObjectStore, error) {
+	switch config.StorageBackend {
+	case "", "r2":
+		return NewS3Store(S3StoreConfig{
+			Name:            "r2",
+			Endpoint:        config.R2Endpoint,
+			Region:          config.R2Region,
+			AccessKeyID:     config.R2AccessKeyId,
+			SecretAccessKey: config.R2SecretAccessKey,
+			Bucket:          config.R2BucketName,
+		})
+	case "s3":
+		return NewS3Store(S3StoreConfig{
+			Name:            "s3",
+			Endpoint:        config.S3Endpoint,
+			Region:          config.S3Region,
+			AccessKeyID:     config.S3AccessKeyId,
+			SecretAccessKey: config.S3SecretAccessKey,
+			Bucket:          config.S3BucketName,
+			UsePathStyle:    config.S3UsePathStyle,
+		})
+	case "local":
+		return NewLocalStore(config.LocalStoragePath)
+	}
+
+	return nil, fmt.Errorf("unknown storage backend: %s", config.StorageBackend)
+}
@@ -0,0 +1,306 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	awsConfig "github.com/aws/aws-sdk-go-v2/config"
+	"github.com/aws/aws-sdk-go-v2/credentials"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/aws/aws-sdk-go-v2/service/s3/types"
+	"github.com/aws/smithy-go"
+	"github.com/hazebio/haze.bio_file-upload/utils"
+)
+
+type S3StoreConfig struct {
+	Name            string
+	Endpoint        string
+	Region          string
+	AccessKeyID     string
+	SecretAccessKey string
+	Bucket          string
+	// Needed by most self-hosted S3-compatible servers such as MinIO
+	UsePathStyle bool
+}
+
+// S3Store stores objects in an S3-compatible bucket. Cloudflare R2 is S3
+// compatible, so it is served by this store with the R2 endpoint.
+type S3Store struct {
+	name     string
+	bucket   string
+	s3Client *s3.Client
+}
+
+func NewS3Store(storeConfig S3StoreConfig) (*S3Store, error) {
+	if storeConfig.Bucket == "" {
+		return nil, fmt.Errorf("%s bucket name is not configured", storeConfig.Name)
+	}
+
+	cfg, err := awsConfig.LoadDefaultConfig(context.TODO(),
+		awsConfig.WithRegion(storeConfig.Region),
+		awsConfig.WithCredentialsProvider(credentials.NewStaticCredentialsProvider(
+			storeConfig.AccessKeyID,
+			storeConfig.SecretAccessKey,
+			"",
+		)),
+	)
+	if err != nil {
+		return nil, fmt.Errorf("unable to load SDK config: %w", err)
+	}
+
+	client := s3.NewFromConfig(cfg, func(o *s3.Options) {
+		if storeConfig.Endpoint != "" {
+			o.BaseEndpoint = aws.String(storeConfig.Endpoint)
+		}
+		o.UsePathStyle = storeConfig.UsePathStyle
+	})
+
+	return &S3Store{
+		name:     storeConfig.Name,
+		bucket:   storeConfig.Bucket,
+		s3Client: client,
+	}, nil
+}
+
+func (s *S3Store) Name() string {
+	return s.name
+}
+
+func (s *S3Store) Put(key string, body io.Reader, size int64, opts PutOptions) error {
+	_, err := s.s3Client.PutObject(context.TODO(), &s3.PutObjectInput{
+		Bucket:        aws.String(s.bucket),
+		Key:           aws.String(key),
+		Body:          body,
+		ContentLength: aws.Int64(size),
+		ContentType:   aws.String(opts.ContentType),
+		Metadata:      opts.Metadata,
+	})
+	return err
+}
+
+func (s *S3Store) Get(key string) (*Object, error) {
+	return s.getObject(key, nil)
+}
+
+func (s *S3Store) GetRange(key string, start, end int64) (*Object, error) {
+	return s.getObject(key, aws.String(fmt.Sprintf("bytes=%d-%d", start, end)))
+}
+
+func (s *S3Store) getObject(key string, byteRange *string) (*Object, error) {
+	result, err := s.s3Client.GetObject(context.TODO(), &s3.GetObjectInput{
+		Bucket: aws.String(s.bucket),
+		Key:    aws.String(key),
+		Range:  byteRange,
+	})
+	if err != nil {
+		return nil, s3Error(err)
+	}
+
+	return &Object{
+		ObjectInfo: ObjectInfo{
+			Key:          key,
+			Size:         aws.ToInt64(result.ContentLength),
+			LastModified: aws.ToTime(result.LastModified),
+			ETag:         aws.ToString(result.ETag),
+			ContentType:  aws.ToString(result.ContentType),
+			Metadata:     result.Metadata,
+		},
+		Body: result.Body,
+	}, nil
+}
+
+func (s *S3Store) Head(key string) (*ObjectInfo, error) {
+	result, err := s.s3Client.HeadObject(context.TODO(), &s3.HeadObjectInput{
+		Bucket: aws.String(s.bucket),
+		Key:    aws.String(key),
+	})
+	if err != nil {
+		return nil, s3Error(err)
+	}
+
+	return &ObjectInfo{
+		Key:          key,
+		Size:         aws.ToInt64(result.ContentLength),
+		LastModified: aws.ToTime(result.LastModified),
+		ETag:         aws.ToString(result.ETag),
+		ContentType:  aws.ToString(result.ContentType),
+		Metadata:     result.Metadata,
+	}, nil
+}
+
+func (s *S3Store) Delete(key string) error {
+	_, err := s.s3Client.DeleteObject(context.TODO(), &s3.DeleteObjectInput{
+		Bucket: aws.String(s.bucket),
+		Key:    aws.String(key),
+	})
+	return err
+}
+
+func (s *S3Store) List(prefix string, cursor string, limit int32) (*ObjectList, error) {
+	input := &s3.ListObjectsV2Input{
+		Bucket:  aws.String(s.bucket),
+		MaxKeys: aws.Int32(limit),
+	}
+	if prefix != "" {
+		input.Prefix = aws.String(prefix)
+	}
+	if cursor != "" {
+		input.ContinuationToken = aws.String(cursor)
+	}
+
+	page, err := s.s3Client.ListObjectsV2(context.TODO(), input)
+	if err != nil {
+		return nil, err
+	}
+
+	list := &ObjectList{Objects: make([]ObjectInfo, 0, len(page.Contents))}
+	for _, obj := range page.Contents {
+		list.Objects = append(list.Objects, ObjectInfo{
+			Key:          aws.ToString(obj.Key),
+			Size:         aws.ToInt64(obj.Size),
+			LastModified: aws.ToTime(obj.LastModified),
+			ETag:         aws.ToString(obj.ETag),
+		})
+	}
+
+	if aws.ToBool(page.IsTruncated) {
+		list.NextCursor = aws.ToString(page.NextContinuationToken)
+	}
+
+	return list, nil
+}
+
+func (s *S3Store) CreateMultipartUpload(key string, opts PutOptions) (string, error) {
+	result, err := s.s3Client.CreateMultipartUpload(context.TODO(), &s3.CreateMultipartUploadInput{
+		Bucket:      aws.String(s.bucket),
+		Key:         aws.String(key),
+		ContentType: aws.String(opts.ContentType),
+		Metadata:    opts.Metadata,
+	})
+	if err != nil {
+		return "", err
+	}
+
+	return aws.ToString(result.UploadId), nil
+}
+
+func (s *S3Store) UploadPart(key string, uploadID string, number int32, body io.Reader, size int64) (*Part, error) {
+	result, err := s.s3Client.UploadPart(context.TODO(), &s3.UploadPartInput{
+		Bucket:        aws.String(s.bucket),
+		Key:           aws.String(key),
+		UploadId:      aws.String(uploadID),
+		PartNumber:    aws.Int32(number),
+		Body:          body,
+		ContentLength: aws.Int64(size),
+	})
+	if err != nil {
+		return nil, s3Error(err)
+	}
+
+	return &Part{Number: number, ETag: aws.ToString(result.ETag), Size: size}, nil
+}
+
+func (s *S3Store) ListParts(key string, uploadID string) ([]Part, error) {
+	parts := []Part{}
+
+	paginator := s3.NewListPartsPaginator(s.s3Client, &s3.ListPartsInput{
+		Bucket:   aws.String(s.bucket),
+		Key:      aws.String(key),
+		UploadId: aws.String(uploadID),
+	})
+
+	for paginator.HasMorePages() {
+		page, err := paginator.NextPage(context.TODO())
+		if err != nil {
+			return nil, s3Error(err)
+		}
+
+		for _, part := range page.Parts {
+			parts = append(parts, Part{
+				Number: aws.ToInt32(part.PartNumber),
+				ETag:   aws.ToString(part.ETag),
+				Size:   aws.ToInt64(part.Size),
+			})
+		}
+	}
+
+	return parts, nil
+}
+
+func (s *S3Store) CompleteMultipartUpload(key string, uploadID string, parts []Part) error {
+	completed := make([]types.CompletedPart, 0, len(parts))
+	for _, part := range parts {
+		completed = append(completed, types.CompletedPart{
+			ETag:       aws.String(part.ETag),
+			PartNumber: aws.Int32(part.Number),
+		})
+	}
+
+	_, err := s.s3Client.CompleteMultipartUpload(context.TODO(), &s3.CompleteMultipartUploadInput{
+		Bucket:          aws.String(s.bucket),
+		Key:             aws.String(key),
+		UploadId:        aws.String(uploadID),
+		MultipartUpload: &types.CompletedMultipartUpload{Parts: completed},
+	})
+	return s3Error(err)
+}
+
+func (s *S3Store) AbortMultipartUpload(key string, uploadID string) error {
+	_, err := s.s3Client.AbortMultipartUpload(context.TODO(), &s3.AbortMultipartUploadInput{
+		Bucket:   aws.String(s.bucket),
+		Key:      aws.String(key),
+		UploadId: aws.String(uploadID),
+	})
+	return s3Error(err)
+}
+
+// FixContentTypes rewrites the Content-Type of every object from its extension
+func (s *S3Store) FixContentTypes() error {
+	paginator := s3.NewListObjectsV2Paginator(s.s3Client, &s3.ListObjectsV2Input{
+		Bucket: aws.String(s.bucket),
+	})
+
+	for paginator.HasMorePages() {
+		page, err := paginator.NextPage(context.TODO())
+		if err != nil {
+			return err
+		}
+
+		for _, obj := range page.Contents {
+			key := *obj.Key
+			contentType := utils.GetContentType(key)
+
+			_, err := s.s3Client.CopyObject(context.TODO(), &s3.CopyObjectInput{
+				Bucket:            aws.String(s.bucket),
+				CopySource:        aws.String(s.bucket + "/" + key),
+				Key:               aws.String(key),
+				ContentType:       aws.String(contentType),
+				MetadataDirective: types.MetadataDirectiveReplace,
+			})
+			if err != nil {
+				log.Printf("Error updating Content-Type for %s: %v", key, err)
+			} else {
+				log.Printf("Updated Content-Type for %s to %s", key, contentType)
+			}
+		}
+	}
+	return nil
+}
+
+/* Map the not found errors of the S3 API to ErrObjectNotFound and ErrUploadNotFound */
+func s3Error(err error) error {
+	var apiErr smithy.APIError
+	if errors.As(err, &apiErr) {
+		switch apiErr.ErrorCode() {
+		case "NotFound", "NoSuchKey":
+			return ErrObjectNotFound
+		case "NoSuchUpload":
+			return ErrUploadNotFound
+		}
+	}
+	return err
+}
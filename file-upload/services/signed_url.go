@@ -0,0 +1,65 @@
+package services
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net/url"
+	"strconv"
+	"time"
+
+	"github.com/hazebio/haze.bio_file-upload/config"
+)
+
+var ErrInvalidSignature = errors.New("invalid or expired signature")
+
+func signature(key string, expires int64, nonce string) string {
+	mac := hmac.New(sha256.New, []byte(config.FileSigningSecret))
+	fmt.Fprintf(mac, "%s\n%d\n%s", key, expires, nonce)
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+/*
+Query string granting access to a key until expiresAt. URLs with a nonce can
+only be used once, pass an empty nonce for URLs that may be reused.
+*/
+func SignedQuery(key string, expiresAt time.Time, nonce string) string {
+	query := url.Values{}
+	query.Set("expires", strconv.FormatInt(expiresAt.Unix(), 10))
+	if nonce != "" {
+		query.Set("nonce", nonce)
+	}
+	query.Set("sig", signature(key, expiresAt.Unix(), nonce))
+	return query.Encode()
+}
+
+/* Verify the signature and expiry of a signed URL and return when it expires */
+func VerifySignedQuery(key string, query url.Values) (time.Time, error) {
+	if config.FileSigningSecret == "" {
+		return time.Time{}, ErrInvalidSignature
+	}
+
+	expires, err := strconv.ParseInt(query.Get("expires"), 10, 64)
+	if err != nil {
+		return time.Time{}, ErrInvalidSignature
+	}
+
+	provided, err := hex.DecodeString(query.Get("sig"))
+	if err != nil {
+		return time.Time{}, ErrInvalidSignature
+	}
+
+	expected, _ := hex.DecodeString(signature(key, expires, query.Get("nonce")))
+	if !hmac.Equal(provided, expected) {
+		return time.Time{}, ErrInvalidSignature
+	}
+
+	expiresAt := time.Unix(expires, 0)
+	if time.Now().After(expiresAt) {
+		return time.Time{}, ErrInvalidSignature
+	}
+
+	return expiresAt, nil
+}
@@ -0,0 +1,360 @@
+package services
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"time"
+
+	"github.com/hazebio/haze.bio_file-upload/utils"
+)
+
+// AccessPolicy decides how a file can be downloaded
+type AccessPolicy string
+
+const (
+	AccessPublic AccessPolicy = "public"
+	// Only through URLs signed by the backend
+	AccessSigned AccessPolicy = "signed"
+	// Through signed URLs, /verify hands one out for the correct password
+	AccessPassword AccessPolicy = "password"
+)
+
+// FileAccess is the access policy set when a file is uploaded
+type FileAccess struct {
+	Policy AccessPolicy
+	// Signed URLs for the file must carry a nonce and work only once
+	SingleUse bool
+}
+
+type FileMetadata struct {
+	ExpiresAt    *time.Time   `json:"expiresAt,omitempty"`
+	Access       AccessPolicy `json:"access,omitempty"`
+	SingleUse    bool         `json:"singleUse,omitempty"`
+	PasswordHash string       `json:"passwordHash,omitempty"`
+	CreatedAt    time.Time    `json:"createdAt"`
+}
+
+/* Access policy of the file, files uploaded before policies existed are public unless they have a password */
+func (m *FileMetadata) Policy() AccessPolicy {
+	if m.Access != "" {
+		return m.Access
+	}
+	if m.PasswordHash != "" {
+		return AccessPassword
+	}
+	return AccessPublic
+}
+
+// StorageService implements expiring and password protected files on top of
+// the configured ObjectStore
+type StorageService struct {
+	Store ObjectStore
+}
+
+func NewStorageService(store ObjectStore) *StorageService {
+	return &StorageService{
+		Store: store,
+	}
+}
+
+func (s *StorageService) UploadFile(key string, body io.Reader, access FileAccess) error {
+	if access.Policy == "" || access.Policy == AccessPublic {
+		return s.uploadFileWithMetadata(key, body, "")
+	}
+
+	metadataJSON, err := accessMetadata(access)
+	if err != nil {
+		return err
+	}
+
+	return s.uploadFileWithMetadata(key, body, metadataJSON)
+}
+
+/* Metadata of a file that only has an access policy */
+func accessMetadata(access FileAccess) (string, error) {
+	metadata := FileMetadata{
+		Access:    access.Policy,
+		SingleUse: access.SingleUse,
+		CreatedAt: time.Now(),
+	}
+
+	metadataJSON, err := json.Marshal(metadata)
+	if err != nil {
+		return "", fmt.Errorf("failed to marshal metadata: %w", err)
+	}
+
+	return string(metadataJSON), nil
+}
+
+func (s *StorageService) UploadTemporaryFile(key string, body io.Reader, expiration time.Duration, access FileAccess) error {
+	expiresAt := time.Now().Add(expiration)
+
+	metadata := FileMetadata{
+		ExpiresAt: &expiresAt,
+		Access:    access.Policy,
+		SingleUse: access.SingleUse,
+		CreatedAt: time.Now(),
+	}
+
+	metadataJSON, err := json.Marshal(metadata)
+	if err != nil {
+		return fmt.Errorf("failed to marshal metadata: %w", err)
+	}
+
+	return s.uploadFileWithMetadata(key, body, string(metadataJSON))
+}
+
+func (s *StorageService) UploadPasswordProtectedFile(key string, body io.Reader, password string, access FileAccess) error {
+	passwordHash, err := HashPassword(password)
+	if err != nil {
+		return fmt.Errorf("failed to hash password: %w", err)
+	}
+
+	metadata := FileMetadata{
+		Access:       AccessPassword,
+		SingleUse:    access.SingleUse,
+		PasswordHash: passwordHash,
+		CreatedAt:    time.Now(),
+	}
+
+	metadataJSON, err := json.Marshal(metadata)
+	if err != nil {
+		return fmt.Errorf("failed to marshal metadata: %w", err)
+	}
+
+	return s.uploadFileWithMetadata(key, body, string(metadataJSON))
+}
+
+func (s *StorageService) uploadFileWithMetadata(key string, body io.Reader, metadataJSON string) error {
+	var contentLength int64
+	var bodyToUse io.Reader = body
+
+	if seeker, ok := body.(io.Seeker); ok {
+		currentPos, err := seeker.Seek(0, io.SeekCurrent)
+		if err != nil {
+			return fmt.Errorf("failed to get current position: %w", err)
+		}
+
+		size, err := seeker.Seek(0, io.SeekEnd)
+		if err != nil {
+			return fmt.Errorf("failed to seek to end: %w", err)
+		}
+
+		_, err = seeker.Seek(currentPos, io.SeekStart)
+		if err != nil {
+			return fmt.Errorf("failed to reset position: %w", err)
+		}
+
+		contentLength = size - currentPos
+	} else {
+		bodyBytes, err := io.ReadAll(body)
+		if err != nil {
+			return fmt.Errorf("failed to read body: %w", err)
+		}
+		bodyToUse = bytes.NewReader(bodyBytes)
+		contentLength = int64(len(bodyBytes))
+	}
+
+	opts := PutOptions{ContentType: utils.GetContentType(key)}
+	if metadataJSON != "" {
+		opts.Metadata = map[string]string{
+			"file-metadata": metadataJSON,
+		}
+	}
+
+	// A content-addressed file under the same key is replaced by a regular
+	// object, its blob reference has to go with it
+	previous, _ := s.contentHash(key)
+
+	if err := s.Store.Put(key, bodyToUse, contentLength, opts); err != nil {
+		return err
+	}
+
+	if previous != "" {
+		if err := s.releaseBlob(previous, key); err != nil {
+			log.Printf("Error releasing previous content of %s: %v", key, err)
+		}
+	}
+
+	return nil
+}
+
+func (s *StorageService) GetFile(key string) (*Object, error) {
+	info, storedKey, err := s.resolve(key)
+	if err != nil {
+		return nil, err
+	}
+
+	obj, err := s.Store.Get(storedKey)
+	if err != nil {
+		return nil, err
+	}
+
+	obj.ObjectInfo = *info
+	return obj, nil
+}
+
+func (s *StorageService) GetFileInfo(key string) (*ObjectInfo, error) {
+	info, _, err := s.resolve(key)
+	return info, err
+}
+
+func (s *StorageService) GetFileRange(key string, start, end int64) (*Object, error) {
+	info, storedKey, err := s.resolve(key)
+	if err != nil {
+		return nil, err
+	}
+
+	obj, err := s.Store.GetRange(storedKey, start, end)
+	if err != nil {
+		return nil, err
+	}
+
+	obj.Key = info.Key
+	obj.ContentType = info.ContentType
+	obj.ETag = info.ETag
+	obj.Metadata = info.Metadata
+	return obj, nil
+}
+
+/* Delete a file, the content of a content-addressed file goes with its last reference */
+func (s *StorageService) DeleteFile(key string) error {
+	hash, err := s.contentHash(key)
+	if err != nil {
+		return err
+	}
+
+	if err := s.Store.Delete(key); err != nil {
+		return err
+	}
+
+	if hash != "" {
+		return s.releaseBlob(hash, key)
+	}
+
+	return nil
+}
+
+func (s *StorageService) FileExists(key string) (bool, error) {
+	_, err := s.Store.Head(key)
+	if err != nil {
+		if errors.Is(err, ErrObjectNotFound) {
+			return false, nil
+		}
+		return false, err
+	}
+	return true, nil
+}
+
+func (s *StorageService) GetFileMetadata(key string) (*FileMetadata, error) {
+	info, err := s.Store.Head(key)
+	if err != nil {
+		return nil, err
+	}
+
+	return ParseFileMetadata(info)
+}
+
+/* Expiry and access policy stored with an object */
+func ParseFileMetadata(info *ObjectInfo) (*FileMetadata, error) {
+	if metadataJSON, ok := info.Metadata["file-metadata"]; ok {
+		var metadata FileMetadata
+		if err := json.Unmarshal([]byte(metadataJSON), &metadata); err != nil {
+			return nil, fmt.Errorf("failed to unmarshal metadata: %w", err)
+		}
+		return &metadata, nil
+	}
+
+	return &FileMetadata{
+		CreatedAt: time.Now(),
+	}, nil
+}
+
+// ListObjects returns one page of objects under a prefix, pass the previous
+// page's NextCursor to continue listing. The blobs and reference markers of
+// content-addressed files are left out, so a page can be shorter than limit.
+func (s *StorageService) ListObjects(prefix string, cursor string, limit int32) (*ObjectList, error) {
+	list, err := s.Store.List(prefix, cursor, limit)
+	if err != nil {
+		return nil, err
+	}
+
+	objects := list.Objects[:0]
+	for _, obj := range list.Objects {
+		if !IsInternalKey(obj.Key) {
+			objects = append(objects, obj)
+		}
+	}
+	list.Objects = objects
+
+	return list, nil
+}
+
+/* Multipart support of the store, false when the store can only take whole objects */
+func (s *StorageService) Multipart() (MultipartStore, bool) {
+	store, ok := s.Store.(MultipartStore)
+	return store, ok
+}
+
+func (s *StorageService) UploadTemporaryProtectedFile(key string, body io.Reader, password string, expiration time.Duration, access FileAccess) error {
+	expiresAt := time.Now().Add(expiration)
+	passwordHash, err := HashPassword(password)
+	if err != nil {
+		return fmt.Errorf("failed to hash password: %w", err)
+	}
+
+	metadata := FileMetadata{
+		ExpiresAt:    &expiresAt,
+		Access:       AccessPassword,
+		SingleUse:    access.SingleUse,
+		PasswordHash: passwordHash,
+		CreatedAt:    time.Now(),
+	}
+
+	metadataJSON, err := json.Marshal(metadata)
+	if err != nil {
+		return fmt.Errorf("failed to marshal metadata: %w", err)
+	}
+
+	return s.uploadFileWithMetadata(key, body, string(metadataJSON))
+}
+
+func (s *StorageService) CleanupExpiredFiles() error {
+	cursor := ""
+	for {
+		page, err := s.Store.List("", cursor, 1000)
+		if err != nil {
+			return err
+		}
+
+		for _, obj := range page.Objects {
+			key := obj.Key
+			if IsInternalKey(key) {
+				continue
+			}
+
+			metadata, err := s.GetFileMetadata(key)
+			if err != nil {
+				log.Printf("Error getting metadata for %s: %v", key, err)
+				continue
+			}
+
+			if metadata.ExpiresAt != nil && metadata.ExpiresAt.Before(time.Now()) {
+				log.Printf("Deleting expired file: %s (expired at %v)", key, metadata.ExpiresAt)
+
+				if err := s.DeleteFile(key); err != nil {
+					log.Printf("Error deleting expired file %s: %v", key, err)
+				}
+			}
+		}
+
+		if page.NextCursor == "" {
+			return nil
+		}
+		cursor = page.NextCursor
+	}
+}
@@ -0,0 +1,59 @@
+package services
+
+import (
+	"errors"
+	"io"
+	"strings"
+	"testing"
+)
+
+func newTestStorage(t *testing.T) *StorageService {
+	t.Helper()
+
+	store, err := NewLocalStore(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	return NewStorageService(store)
+}
+
+func TestOverwritingContentAddressedFileReleasesBlob(t *testing.T) {
+	s := newTestStorage(t)
+
+	shared, err := s.UploadContentAddressedFile("shared.txt", strings.NewReader("same bytes"), FileAccess{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := s.UploadContentAddressedFile("other.txt", strings.NewReader("same bytes"), FileAccess{}); err != nil {
+		t.Fatal(err)
+	}
+	only, err := s.UploadContentAddressedFile("only.txt", strings.NewReader("unique bytes"), FileAccess{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := s.UploadFile("shared.txt", strings.NewReader("plain"), FileAccess{}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := s.Store.Head(blobKey(shared.Hash)); err != nil {
+		t.Errorf("blob still referenced by other.txt was deleted: %v", err)
+	}
+
+	if err := s.UploadPasswordProtectedFile("only.txt", strings.NewReader("plain"), "hunter2", FileAccess{}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := s.Store.Head(blobKey(only.Hash)); !errors.Is(err, ErrObjectNotFound) {
+		t.Errorf("blob without references should be deleted, Head returned %v", err)
+	}
+
+	obj, err := s.GetFile("only.txt")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer obj.Body.Close()
+
+	body, _ := io.ReadAll(obj.Body)
+	if string(body) != "plain" {
+		t.Errorf("only.txt = %q, want the new content", body)
+	}
+}
@@ -0,0 +1,134 @@
+package services
+
+import (
+	"sync"
+	"time"
+
+	"github.com/go-redis/redis"
+	"github.com/hazebio/haze.bio_file-upload/config"
+)
+
+// TokenStore tracks single-use nonces and attempt counters. Redis is used
+// when configured so every instance shares the state, a single instance
+// setup can keep it in memory.
+type TokenStore interface {
+	// UseOnce marks a token as used and reports whether it was unused before
+	UseOnce(token string, ttl time.Duration) (bool, error)
+	// Increment counts an attempt within a window that starts at the first attempt
+	Increment(counter string, window time.Duration) (int64, error)
+	// Add adds delta to a counter within a window that starts at the first addition
+	Add(counter string, delta int64, window time.Duration) (int64, error)
+}
+
+func NewTokenStore() (TokenStore, error) {
+	if config.RedisAddr == "" {
+		return NewMemoryTokenStore(), nil
+	}
+
+	client := redis.NewClient(&redis.Options{
+		Addr:     config.RedisAddr,
+		Password: config.RedisPassword,
+		DB:       config.RedisDB,
+	})
+
+	if err := client.Ping().Err(); err != nil {
+		return nil, err
+	}
+
+	return &RedisTokenStore{client: client}, nil
+}
+
+type RedisTokenStore struct {
+	client *redis.Client
+}
+
+func (s *RedisTokenStore) UseOnce(token string, ttl time.Duration) (bool, error) {
+	return s.client.SetNX("file-upload:token:"+token, 1, ttl).Result()
+}
+
+func (s *RedisTokenStore) Increment(counter string, window time.Duration) (int64, error) {
+	return s.Add(counter, 1, window)
+}
+
+func (s *RedisTokenStore) Add(counter string, delta int64, window time.Duration) (int64, error) {
+	key := "file-upload:counter:" + counter
+
+	count, err := s.client.IncrBy(key, delta).Result()
+	if err != nil {
+		return 0, err
+	}
+
+	if count == delta {
+		s.client.Expire(key, window)
+	}
+
+	return count, nil
+}
+
+type MemoryTokenStore struct {
+	mu       sync.Mutex
+	tokens   map[string]time.Time
+	counters map[string]*memoryCounter
+}
+
+type memoryCounter struct {
+	count     int64
+	expiresAt time.Time
+}
+
+func NewMemoryTokenStore() *MemoryTokenStore {
+	return &MemoryTokenStore{
+		tokens:   make(map[string]time.Time),
+		counters: make(map[string]*memoryCounter),
+	}
+}
+
+func (s *MemoryTokenStore) UseOnce(token string, ttl time.Duration) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := time.Now()
+	s.prune(now)
+
+	if _, used := s.tokens[token]; used {
+		return false, nil
+	}
+
+	s.tokens[token] = now.Add(ttl)
+	return true, nil
+}
+
+func (s *MemoryTokenStore) Increment(counter string, window time.Duration) (int64, error) {
+	return s.Add(counter, 1, window)
+}
+
+func (s *MemoryTokenStore) Add(counter string, delta int64, window time.Duration) (int64, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := time.Now()
+	s.prune(now)
+
+	c, ok := s.counters[counter]
+	if !ok {
+		c = &memoryCounter{expiresAt: now.Add(window)}
+		s.counters[counter] = c
+	}
+
+	c.count += delta
+	return c.count, nil
+}
+
+func (s *MemoryTokenStore) prune(now time.Time) {
+	for token, expiresAt := range s.tokens {
+		if now.After(expiresAt) {
+			delete(s.tokens, token)
+		}
+	}
+
+	for counter, c := range s.counters {
+		if now.After(c.expiresAt) {
+			delete(s.counters, counter)
+		}
+	}
+}
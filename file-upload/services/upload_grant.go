@@ -0,0 +1,89 @@
+package services
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"strings"
+	"time"
+
+	"github.com/hazebio/haze.bio_file-upload/config"
+)
+
+var ErrInvalidGrant = errors.New("invalid upload grant")
+
+// UploadGrant lets a browser upload one file directly to storage. The backend
+// signs it, only Key can be written and the upload may not exceed MaxSize.
+type UploadGrant struct {
+	ID          string `json:"id"`
+	Key         string `json:"key"`
+	MaxSize     int64  `json:"max_size"`
+	ContentType string `json:"content_type"`
+	ExpiresAt   int64  `json:"exp"`
+}
+
+/* Verify the signature and expiry of a grant token in the form payload.signature */
+func ParseUploadGrant(token string) (*UploadGrant, error) {
+	if config.UploadGrantSecret == "" {
+		return nil, ErrInvalidGrant
+	}
+
+	payload, signature, ok := strings.Cut(token, ".")
+	if !ok {
+		return nil, ErrInvalidGrant
+	}
+
+	mac := hmac.New(sha256.New, []byte(config.UploadGrantSecret))
+	mac.Write([]byte(payload))
+	expected := mac.Sum(nil)
+
+	provided, err := base64.RawURLEncoding.DecodeString(signature)
+	if err != nil || !hmac.Equal(provided, expected) {
+		return nil, ErrInvalidGrant
+	}
+
+	data, err := base64.RawURLEncoding.DecodeString(payload)
+	if err != nil {
+		return nil, ErrInvalidGrant
+	}
+
+	var grant UploadGrant
+	if err := json.Unmarshal(data, &grant); err != nil {
+		return nil, ErrInvalidGrant
+	}
+
+	if grant.Key == "" || time.Now().Unix() > grant.ExpiresAt {
+		return nil, ErrInvalidGrant
+	}
+
+	return &grant, nil
+}
+
+/* Whether the grant covers a key, only the exact key it was signed for */
+func (g *UploadGrant) Allows(key string) bool {
+	return key == g.Key
+}
+
+/* When the grant stops being accepted */
+func (g *UploadGrant) Expiry() time.Time {
+	return time.Unix(g.ExpiresAt, 0)
+}
+
+/*
+Options for an object uploaded with a grant. Staged uploads are only readable
+with a signed URL, nothing is public before the backend processed and scanned
+the file.
+*/
+func (g *UploadGrant) PutOptions() (PutOptions, error) {
+	metadataJSON, err := accessMetadata(FileAccess{Policy: AccessSigned})
+	if err != nil {
+		return PutOptions{}, err
+	}
+
+	return PutOptions{
+		ContentType: g.ContentType,
+		Metadata:    map[string]string{"file-metadata": metadataJSON},
+	}, nil
+}
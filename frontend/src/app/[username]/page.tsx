@@ -25,9 +25,16 @@ export async function generateMetadata({ params }: { params: Promise<{username:
   let imageUrl;
   imageUrl = `https://api.cutz.lol/api/images/user/${user.uid}?t=${Date.now()}`;
 
+  const oembedUrl = `https://api.cutz.lol/api/oembed?url=${encodeURIComponent(`https://cutz.lol/${username}`)}`;
+
   return {
     title,
     description,
+    alternates: {
+      types: {
+        'application/json+oembed': oembedUrl,
+      },
+    },
     openGraph: {
       title,
       description,
@@ -6,12 +6,10 @@ require (
 	github.com/gorilla/handlers v1.5.2
 	github.com/gorilla/mux v1.8.1
 	github.com/joho/godotenv v1.5.1
+	github.com/stripe/stripe-go/v72 v72.122.0
 )
 
-require (
-	github.com/stripe/stripe-go v70.15.0+incompatible // indirect
-	github.com/stripe/stripe-go/v72 v72.122.0 // indirect
-)
+require github.com/stripe/stripe-go v70.15.0+incompatible // indirect
 
 require (
 	github.com/felixge/httpsnoop v1.0.3 // indirect